@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/ksf"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+var (
+	// ErrConfigurationAdvertisement indicates that a configuration advertisement is malformed, or that its fingerprint
+	// is not the one of its configuration.
+	ErrConfigurationAdvertisement = errors.New("invalid configuration advertisement")
+
+	// ErrConfigurationPolicy indicates that an advertised configuration is not accepted by the client's policy.
+	ErrConfigurationPolicy = errors.New("configuration rejected by policy")
+)
+
+// Advertisement returns the ConfigurationAdvertisement of the configuration, for the server to send to its clients
+// before the handshake, so that they don't need to be given the configuration by hand.
+func (c *Configuration) Advertisement() *message.ConfigurationAdvertisement {
+	return &message.ConfigurationAdvertisement{
+		Configuration: c.Serialize(),
+		Fingerprint:   c.Fingerprint(),
+	}
+}
+
+// DeserializeConfigurationAdvertisement decodes the encoding of ConfigurationAdvertisement.Serialize.
+func DeserializeConfigurationAdvertisement(encoded []byte) (*message.ConfigurationAdvertisement, error) {
+	conf, offset, err := encoding.DecodeVector(encoded)
+	if err != nil || len(encoded)-offset != sha256.Size {
+		return nil, ErrConfigurationAdvertisement
+	}
+
+	return &message.ConfigurationAdvertisement{
+		Configuration: conf,
+		Fingerprint:   encoded[offset:],
+	}, nil
+}
+
+// ConfigurationPolicy is what a client accepts of an advertised configuration. Its empty fields accept any value.
+//
+// The advertisement is not authenticated: its fingerprint only detects a corrupted configuration, and a network
+// attacker can advertise any configuration the policy accepts. Pinning the configuration with Fingerprints prevents
+// that, and otherwise the policy should only accept configurations the client considers strong enough.
+type ConfigurationPolicy struct {
+	// Fingerprints, if set, are the fingerprints of the only accepted configurations, as returned by
+	// Configuration.Fingerprint.
+	Fingerprints [][]byte
+
+	// Groups are the accepted OPRF and AKE groups.
+	Groups []Group
+
+	// Hashes are the accepted KDF, MAC, and Hash functions.
+	Hashes []crypto.Hash
+
+	// KSFs are the accepted key stretching functions.
+	KSFs []ksf.Identifier
+
+	// Protocols are the accepted AKE protocols.
+	Protocols []Protocol
+
+	// Context, if set, is the required configuration context.
+	Context []byte
+
+	// RequireKEM rejects the configurations without a post-quantum KEM.
+	RequireKEM bool
+}
+
+// Accept returns the advertised configuration if its fingerprint matches, and the policy accepts it. It returns an
+// error wrapping ErrConfigurationAdvertisement if the advertisement is invalid, and ErrConfigurationPolicy if the
+// policy rejects the configuration.
+func (p *ConfigurationPolicy) Accept(advertisement *message.ConfigurationAdvertisement) (*Configuration, error) {
+	if advertisement == nil {
+		return nil, ErrConfigurationAdvertisement
+	}
+
+	conf, err := DeserializeConfiguration(advertisement.Configuration)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfigurationAdvertisement, err)
+	}
+
+	fingerprint := conf.Fingerprint()
+	if !internal.ConstantTimeEqual(fingerprint, advertisement.Fingerprint) {
+		return nil, fmt.Errorf("%w: fingerprint mismatch", ErrConfigurationAdvertisement)
+	}
+
+	if err = p.check(conf, fingerprint); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfigurationPolicy, err)
+	}
+
+	return conf, nil
+}
+
+var (
+	errPolicyFingerprint = errors.New("configuration not pinned")
+	errPolicyGroup       = errors.New("group not accepted")
+	errPolicyHash        = errors.New("hash function not accepted")
+	errPolicyKSF         = errors.New("key stretching function not accepted")
+	errPolicyProtocol    = errors.New("AKE protocol not accepted")
+	errPolicyContext     = errors.New("unexpected context")
+	errPolicyKEM         = errors.New("missing KEM")
+)
+
+func (p *ConfigurationPolicy) check(conf *Configuration, fingerprint []byte) error {
+	if len(p.Fingerprints) != 0 && !pinned(p.Fingerprints, fingerprint) {
+		return errPolicyFingerprint
+	}
+
+	if !accepted(p.Groups, conf.OPRF, conf.AKE) {
+		return errPolicyGroup
+	}
+
+	if !accepted(p.Hashes, conf.KDF, conf.MAC, conf.Hash) {
+		return errPolicyHash
+	}
+
+	if !accepted(p.KSFs, conf.KSF) {
+		return errPolicyKSF
+	}
+
+	if !accepted(p.Protocols, conf.Protocol) {
+		return errPolicyProtocol
+	}
+
+	if p.Context != nil && !internal.ConstantTimeEqual(p.Context, conf.Context) {
+		return errPolicyContext
+	}
+
+	if p.RequireKEM && conf.KEM == NoKEM {
+		return errPolicyKEM
+	}
+
+	return nil
+}
+
+func pinned(fingerprints [][]byte, fingerprint []byte) bool {
+	for _, f := range fingerprints {
+		if internal.ConstantTimeEqual(f, fingerprint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// accepted returns whether all the values are in the list, or true if the list is empty.
+func accepted[T comparable](list []T, values ...T) bool {
+	if len(list) == 0 {
+		return true
+	}
+
+	for _, v := range values {
+		found := false
+
+		for _, a := range list {
+			if a == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"github.com/bytemare/opaque/message"
+)
+
+// RegistrationFinalizeResult is the outcome of RegistrationFinalizeAsync.
+type RegistrationFinalizeResult struct {
+	Record    *message.RegistrationRecord
+	ExportKey ExportKey
+	Err       error
+}
+
+// LoginFinishResult is the outcome of LoginFinishAsync.
+type LoginFinishResult struct {
+	KE3       *message.KE3
+	ExportKey ExportKey
+	Err       error
+}
+
+// RegistrationFinalizeAsync runs RegistrationFinalize, and its key stretching function, on a worker goroutine, so
+// that the calling thread, e.g. a UI thread or a single-threaded WASM host, isn't blocked for the stretching duration.
+// The result is sent on the returned channel, which is buffered, so it needn't be received if the caller gives up.
+// The Client must not be used before the result is received.
+func (c *Client) RegistrationFinalizeAsync(
+	resp *message.RegistrationResponse,
+	clientIdentity, serverIdentity []byte,
+) <-chan *RegistrationFinalizeResult {
+	out := make(chan *RegistrationFinalizeResult, 1)
+
+	go func() {
+		record, exportKey, err := c.RegistrationFinalize(resp, clientIdentity, serverIdentity)
+		out <- &RegistrationFinalizeResult{Record: record, ExportKey: exportKey, Err: err}
+	}()
+
+	return out
+}
+
+// LoginFinishAsync runs LoginFinish, and its key stretching function, on a worker goroutine, so that the calling
+// thread isn't blocked for the stretching duration. The result is sent on the returned channel, which is buffered, so
+// it needn't be received if the caller gives up. The Client must not be used before the result is received.
+func (c *Client) LoginFinishAsync(
+	clientIdentity, serverIdentity []byte,
+	ke2 *message.KE2,
+) <-chan *LoginFinishResult {
+	out := make(chan *LoginFinishResult, 1)
+
+	go func() {
+		ke3, exportKey, err := c.LoginFinish(clientIdentity, serverIdentity, ke2)
+		out <- &LoginFinishResult{KE3: ke3, ExportKey: exportKey, Err: err}
+	}()
+
+	return out
+}
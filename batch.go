@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+
+	"github.com/bytemare/crypto/group"
+)
+
+// errBatchLength happens when the batch has not as many credential identifiers as blinded elements.
+var errBatchLength = errors.New("batch has different numbers of credential identifiers and blinded elements")
+
+// EvaluateBatch evaluates many blinded elements in one call, e.g. for credential migration jobs or bursts of logins:
+// blindedElements[i] is evaluated with the OPRF key of credentialIdentifiers[i], and the public info set with
+// SetOPRFInfo, if any. The result at each index is the same as the evaluation in the RegistrationResponse or KE2 for
+// that credential identifier. The key of a credential identifier appearing several times is derived once, and in the
+// POPRF mode the tweaked keys are inverted with a single inversion for the whole batch.
+func (s *Server) EvaluateBatch(
+	oprfSeed []byte,
+	credentialIdentifiers [][]byte,
+	blindedElements []*group.Point,
+) ([]*group.Point, error) {
+	if len(oprfSeed) != s.conf.Hash.Size() {
+		return nil, ErrInvalidOPRFSeedLength
+	}
+
+	if len(credentialIdentifiers) != len(blindedElements) {
+		return nil, errBatchLength
+	}
+
+	keys := make([]*group.Scalar, len(credentialIdentifiers))
+	derived := make(map[string]*group.Scalar, len(credentialIdentifiers))
+
+	for i, id := range credentialIdentifiers {
+		if blindedElements[i] == nil {
+			return nil, errIncompleteMessage
+		}
+
+		ku, ok := derived[string(id)]
+		if !ok {
+			var err error
+			if ku, err = s.oprfKey(oprfSeed, id); err != nil {
+				return nil, err
+			}
+
+			derived[string(id)] = ku
+		}
+
+		keys[i] = ku
+	}
+
+	return s.conf.OPRF.EvaluateBatch(keys, blindedElements, s.oprfInfo)
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package benchmarks_test
+
+import (
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/benchmarks"
+	"github.com/bytemare/opaque/message"
+)
+
+var (
+	credentialIdentifier = []byte("client")
+	serverIdentity       = []byte("server")
+	password             = []byte("password")
+)
+
+// fixture holds the server's keys and a client record of a suite.
+type fixture struct {
+	conf            *opaque.Configuration
+	serverSecretKey []byte
+	serverPublicKey []byte
+	oprfSeed        []byte
+	record          *opaque.ClientRecord
+}
+
+func newFixture(tb testing.TB, conf *opaque.Configuration) *fixture {
+	f := &fixture{conf: conf}
+	f.oprfSeed, _ = conf.GenerateOPRFSeed()
+	f.serverSecretKey, f.serverPublicKey, _ = conf.KeyGen()
+	f.record = &opaque.ClientRecord{
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       credentialIdentifier,
+		RegistrationRecord:   f.register(tb),
+	}
+
+	return f
+}
+
+func (f *fixture) register(tb testing.TB) *message.RegistrationRecord {
+	client, err := f.conf.Client()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	server, err := f.conf.Server()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(f.serverPublicKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	resp, _ := server.RegistrationResponse(client.RegistrationInit(password), pks, credentialIdentifier, f.oprfSeed)
+	record, _, _ := client.RegistrationFinalize(resp, credentialIdentifier, serverIdentity)
+
+	return record
+}
+
+func (f *fixture) loginInit(tb testing.TB, server *opaque.Server, ke1 *message.KE1) *message.KE2 {
+	ke2, err := server.LoginInit(ke1, serverIdentity, f.serverSecretKey, f.serverPublicKey, f.oprfSeed, f.record)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return ke2
+}
+
+func (f *fixture) login(tb testing.TB) {
+	client, _ := f.conf.Client()
+	server, _ := f.conf.Server()
+	ke2 := f.loginInit(tb, server, client.LoginInit(password))
+
+	ke3, _, err := client.LoginFinish(credentialIdentifier, serverIdentity, ke2)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if err := server.LoginFinish(ke3); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// TestSuites checks that every suite completes a login, and in short mode only those without key stretching.
+func TestSuites(t *testing.T) {
+	for _, s := range benchmarks.Suites() {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			if testing.Short() && s.Configuration.KSF != 0 {
+				t.Skip("key stretching in short mode")
+			}
+
+			t.Parallel()
+			newFixture(t, s.Configuration).login(t)
+		})
+	}
+}
+
+func BenchmarkRegistration(b *testing.B) {
+	for _, s := range benchmarks.Suites() {
+		b.Run(s.Name, func(b *testing.B) {
+			f := newFixture(b, s.Configuration)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				f.register(b)
+			}
+		})
+	}
+}
+
+func BenchmarkLogin(b *testing.B) {
+	for _, s := range benchmarks.Suites() {
+		b.Run(s.Name, func(b *testing.B) {
+			f := newFixture(b, s.Configuration)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				f.login(b)
+			}
+		})
+	}
+}
+
+// BenchmarkLoginServer measures the server's response to KE1, without the client's key stretching.
+func BenchmarkLoginServer(b *testing.B) {
+	for _, s := range benchmarks.Suites() {
+		b.Run(s.Name, func(b *testing.B) {
+			f := newFixture(b, s.Configuration)
+			client, _ := f.conf.Client()
+			ke1 := client.LoginInit(password)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				server, _ := f.conf.Server()
+				f.loginInit(b, server, ke1)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package benchmarks measures the registration and login of OPAQUE for every supported combination of group, KDF, and
+// KSF, to help choose a ciphersuite and catch performance regressions. Run it with
+//
+//	go test -bench . -benchmem github.com/bytemare/opaque/benchmarks
+//
+// and select suites by name, e.g. with -bench 'Login/P256', or by step: Registration, Login, or LoginServer, which is
+// the server's share of a login.
+package benchmarks
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/bytemare/crypto/ksf"
+
+	"github.com/bytemare/opaque"
+)
+
+// Suite is a named Configuration.
+type Suite struct {
+	Name          string
+	Configuration *opaque.Configuration
+}
+
+var (
+	groups = []struct {
+		name  string
+		group opaque.Group
+	}{
+		{"Ristretto255", opaque.RistrettoSha512},
+		{"P256", opaque.P256Sha256},
+		{"P384", opaque.P384Sha512},
+		{"P521", opaque.P521Sha512},
+	}
+
+	kdfs = []crypto.Hash{crypto.SHA256, crypto.SHA512}
+
+	// The zero identifier is the identity KSF, which measures the protocol without key stretching.
+	ksfs = []ksf.Identifier{0, ksf.Argon2id, ksf.Scrypt, ksf.PBKDF2Sha512}
+)
+
+func ksfName(id ksf.Identifier) string {
+	switch id {
+	case ksf.Argon2id:
+		return "Argon2id"
+	case ksf.Scrypt:
+		return "Scrypt"
+	case ksf.PBKDF2Sha512:
+		return "PBKDF2"
+	default:
+		return "Identity"
+	}
+}
+
+// Suites returns the combinations of the supported groups, KDFs, and KSFs, named as group/KDF/KSF. The MAC and the
+// hash function are the KDF's hash function.
+func Suites() []Suite {
+	suites := make([]Suite, 0, len(groups)*len(kdfs)*len(ksfs))
+
+	for _, g := range groups {
+		for _, kdf := range kdfs {
+			for _, k := range ksfs {
+				suites = append(suites, Suite{
+					Name: fmt.Sprintf("%s/%s/%s", g.name, kdf, ksfName(k)),
+					Configuration: &opaque.Configuration{
+						OPRF: g.group,
+						AKE:  g.group,
+						KDF:  kdf,
+						MAC:  kdf,
+						Hash: kdf,
+						KSF:  k,
+					},
+				})
+			}
+		}
+	}
+
+	return suites
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/message"
+)
+
+// A client can check a new password against known breaches before registering it, e.g. with a k-anonymity range query
+// to a service like Have I Been Pwned's Pwned Passwords, which only learns a short prefix of the password's hash. The
+// check runs before the OPRF starts, so that neither the application's server nor the breach service learns the
+// password.
+
+// pwnedPrefixLength is the number of hexadecimal characters of the SHA-1 hash sent in a range query.
+const pwnedPrefixLength = 5
+
+// ErrBreachedPassword indicates a password found in a known breach by the client's BreachChecker.
+var ErrBreachedPassword = errors.New("password found in a known breach")
+
+// BreachChecker checks passwords against known breaches.
+type BreachChecker interface {
+	// Breached returns whether the password is known to be breached. It must not send the password, nor enough of its
+	// hash to identify it, to the breach service.
+	Breached(password []byte) (bool, error)
+}
+
+// SetBreachChecker sets the checker with which RegistrationStart and RegistrationInit reject known breached passwords.
+// A nil checker disables it.
+func (c *Client) SetBreachChecker(checker BreachChecker) {
+	c.breach = checker
+}
+
+// RegistrationStart is like RegistrationInit, but first checks the password against the PasswordPolicy and then with
+// the BreachChecker, if any. It returns an error wrapping ErrPasswordPolicy if the policy rejects the password,
+// ErrBreachedPassword if the password is breached, or the checker's error if it fails, without starting the OPRF.
+func (c *Client) RegistrationStart(password []byte) (*message.RegistrationRequest, error) {
+	if err := c.checkNewPassword(password); err != nil {
+		return nil, err
+	}
+
+	c.passwordErr = nil
+
+	return c.registrationInit(password), nil
+}
+
+// checkNewPassword checks a password to register against the PasswordPolicy and then with the BreachChecker, if any.
+func (c *Client) checkNewPassword(password []byte) error {
+	if err := c.checkPassword(password); err != nil {
+		return err
+	}
+
+	if c.breach == nil {
+		return nil
+	}
+
+	breached, err := c.breach.Breached(password)
+	if err != nil {
+		return fmt.Errorf("checking the password for breaches: %w", err)
+	}
+
+	if breached {
+		return ErrBreachedPassword
+	}
+
+	return nil
+}
+
+var errPwnedRange = errors.New("invalid range query response")
+
+// PwnedPasswords is a BreachChecker querying a k-anonymity range API in the format of the Pwned Passwords API: the
+// query is the first five hexadecimal characters of the SHA-1 hash of the password, and the response lists the
+// suffixes of the known hashes with that prefix, one per line, followed by a colon and the number of times they were
+// seen.
+type PwnedPasswords struct {
+	// Range returns the response to the range query for the prefix, e.g. the body of a GET request to
+	// https://api.pwnedpasswords.com/range/{prefix}.
+	Range func(prefix string) ([]byte, error)
+
+	// Threshold is the number of times a password must have been seen to be considered breached, and defaults to 1.
+	// The entries with a count of zero, e.g. the padding added to hide the size of the response, are never breached.
+	Threshold int
+}
+
+// Breached returns whether the password's hash is listed in the range query response with at least Threshold
+// occurrences.
+func (p *PwnedPasswords) Breached(password []byte) (bool, error) {
+	digest := sha1.Sum(password)
+	hash := bytes.ToUpper([]byte(hex.EncodeToString(digest[:])))
+
+	response, err := p.Range(string(hash[:pwnedPrefixLength]))
+	if err != nil {
+		return false, err
+	}
+
+	threshold := p.Threshold
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	suffix := hash[pwnedPrefixLength:]
+
+	for _, line := range bytes.Split(response, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		entry, count, found := bytes.Cut(line, []byte(":"))
+		if !found {
+			return false, errPwnedRange
+		}
+
+		n, err := strconv.Atoi(string(count))
+		if err != nil {
+			return false, errPwnedRange
+		}
+
+		if internal.ConstantTimeEqual(bytes.ToUpper(entry), suffix) {
+			return n >= threshold, nil
+		}
+	}
+
+	return false, nil
+}
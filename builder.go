@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+	"github.com/bytemare/crypto/ksf"
+)
+
+// Warning describes a valid but discouraged combination of parameters, reported by ConfigurationBuilder.Build.
+type Warning string
+
+// ConfigurationBuilder builds a Configuration step by step from the DefaultConfiguration, and validates it at once in
+// Build, e.g.
+//
+//	conf, warnings, err := opaque.NewConfigurationBuilder().
+//		WithOPRF(opaque.P256Sha256).
+//		WithAKE(opaque.P256Sha256).
+//		WithHashes(crypto.SHA256).
+//		WithKSF(ksf.Argon2id, 3, 65536, 4).
+//		Build()
+type ConfigurationBuilder struct {
+	conf Configuration
+}
+
+// NewConfigurationBuilder returns a ConfigurationBuilder starting from the DefaultConfiguration.
+func NewConfigurationBuilder() *ConfigurationBuilder {
+	return &ConfigurationBuilder{conf: *DefaultConfiguration()}
+}
+
+// WithOPRF sets the OPRF group.
+func (b *ConfigurationBuilder) WithOPRF(g Group) *ConfigurationBuilder {
+	b.conf.OPRF = g
+	return b
+}
+
+// WithAKE sets the AKE group.
+func (b *ConfigurationBuilder) WithAKE(g Group) *ConfigurationBuilder {
+	b.conf.AKE = g
+	return b
+}
+
+// WithKDF sets the hash function of the KDF.
+func (b *ConfigurationBuilder) WithKDF(h crypto.Hash) *ConfigurationBuilder {
+	b.conf.KDF = h
+	return b
+}
+
+// WithMAC sets the hash function of the MAC.
+func (b *ConfigurationBuilder) WithMAC(h crypto.Hash) *ConfigurationBuilder {
+	b.conf.MAC = h
+	return b
+}
+
+// WithHash sets the hash function.
+func (b *ConfigurationBuilder) WithHash(h crypto.Hash) *ConfigurationBuilder {
+	b.conf.Hash = h
+	return b
+}
+
+// WithHashes sets the same hash function for the KDF, the MAC, and hashing.
+func (b *ConfigurationBuilder) WithHashes(h crypto.Hash) *ConfigurationBuilder {
+	return b.WithKDF(h).WithMAC(h).WithHash(h)
+}
+
+// WithKSF sets the KSF, with parameters replacing its defaults if any are given.
+func (b *ConfigurationBuilder) WithKSF(id ksf.Identifier, parameters ...int) *ConfigurationBuilder {
+	b.conf.KSF = id
+	return b.WithKSFParams(parameters...)
+}
+
+// WithKSFParams sets the parameters replacing the defaults of the KSF. Without parameters, the defaults are restored.
+func (b *ConfigurationBuilder) WithKSFParams(parameters ...int) *ConfigurationBuilder {
+	b.conf.KSFParameters = append([]int(nil), parameters...)
+	return b
+}
+
+// WithMode sets the envelope mode.
+func (b *ConfigurationBuilder) WithMode(mode EnvelopeMode) *ConfigurationBuilder {
+	b.conf.Mode = mode
+	return b
+}
+
+// WithAppDataLength sets the maximum length of the application data sealed in the envelope.
+func (b *ConfigurationBuilder) WithAppDataLength(length uint16) *ConfigurationBuilder {
+	b.conf.AppDataLength = length
+	return b
+}
+
+// WithAEAD sets the AEAD sealing the envelope.
+func (b *ConfigurationBuilder) WithAEAD(aead EnvelopeAEAD) *ConfigurationBuilder {
+	b.conf.AEAD = aead
+	return b
+}
+
+// WithKEM sets the KEM of the hybrid AKE.
+func (b *ConfigurationBuilder) WithKEM(kem KEM) *ConfigurationBuilder {
+	b.conf.KEM = kem
+	return b
+}
+
+// WithProtocol sets the AKE protocol.
+func (b *ConfigurationBuilder) WithProtocol(protocol Protocol) *ConfigurationBuilder {
+	b.conf.Protocol = protocol
+	return b
+}
+
+// WithCompatibility sets the compatibility mode.
+func (b *ConfigurationBuilder) WithCompatibility(compatibility Compatibility) *ConfigurationBuilder {
+	b.conf.Compatibility = compatibility
+	return b
+}
+
+// WithContext sets the context included in the AKE transcript.
+func (b *ConfigurationBuilder) WithContext(context []byte) *ConfigurationBuilder {
+	b.conf.Context = append([]byte(nil), context...)
+	return b
+}
+
+// WithConfigurationCommitment binds the whole configuration into the AKE transcript: see
+// Configuration.CommitConfiguration.
+func (b *ConfigurationBuilder) WithConfigurationCommitment() *ConfigurationBuilder {
+	b.conf.CommitConfiguration = true
+	return b
+}
+
+// Build returns a copy of the configuration built so far, or an error if it is invalid. The warnings report the valid
+// but discouraged combinations of parameters, e.g. different OPRF and AKE groups, or hash functions of different sizes.
+func (b *ConfigurationBuilder) Build() (*Configuration, []Warning, error) {
+	conf := b.conf
+	conf.KSFParameters = append([]int(nil), b.conf.KSFParameters...)
+	conf.Context = append([]byte(nil), b.conf.Context...)
+
+	if len(conf.Context) == 0 {
+		conf.Context = nil
+	}
+
+	if len(conf.KSFParameters) == 0 {
+		conf.KSFParameters = nil
+	}
+
+	if err := conf.verify(); err != nil {
+		return nil, nil, err
+	}
+
+	return &conf, conf.warnings(), nil
+}
+
+// warnings returns the discouraged combinations of parameters of a valid configuration.
+func (c *Configuration) warnings() []Warning {
+	var warnings []Warning
+
+	if c.OPRF != c.AKE {
+		warnings = append(warnings, Warning(fmt.Sprintf(
+			"the OPRF group (%s) and the AKE group (%s) should be the same",
+			group.Group(c.OPRF), group.Group(c.AKE))))
+	}
+
+	if c.KDF.Size() != c.MAC.Size() || c.KDF.Size() != c.Hash.Size() {
+		warnings = append(warnings, Warning(fmt.Sprintf(
+			"the KDF (%s), MAC (%s), and Hash (%s) should have the same output size", c.KDF, c.MAC, c.Hash)))
+	}
+
+	// The hash functions should provide at least the security level of the groups.
+	if level := securityLevel(c.AKE); c.Hash.Size() < 2*level || c.KDF.Size() < 2*level {
+		warnings = append(warnings, Warning(fmt.Sprintf(
+			"the hash functions are shorter than the %d-bit security level of the AKE group", 8*level)))
+	}
+
+	if c.KSF == 0 {
+		warnings = append(warnings, "the identity KSF doesn't slow down offline password guessing")
+	}
+
+	return warnings
+}
+
+// securityLevel returns the security level of the group, in bytes.
+func securityLevel(g Group) int {
+	switch g {
+	case P384Sha512:
+		return 24
+	case P521Sha512:
+		return 32
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,450 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Command capi is the C interface of OPAQUE, built as a shared library with its header:
+//
+//	go build -buildmode=c-shared -o libopaquego.so ./capi
+//
+// Clients and servers are referred to by opaque handles, created with opaque_client_new and opaque_server_new, and
+// released with opaque_client_free and opaque_server_free, which zero their secrets. They wrap the Client and Server
+// of the mobile package, and must not be used concurrently. Using a released handle is a fatal error.
+//
+// The functions return OPAQUE_OK or a negative error code. Inputs are borrowed for the duration of the call: they
+// are copied, and remain owned by the caller. Outputs are written to the given pointers, and are owned by the caller,
+// who must release them with opaque_free. On error, nothing is written. Configurations are passed in their
+// serialization, as returned by opaque_default_configuration, and messages in their wire encoding. Empty identities
+// default to the public keys.
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+#define OPAQUE_OK 0
+#define OPAQUE_ERROR_INVALID_ARGUMENT -1
+#define OPAQUE_ERROR_INVALID_HANDLE -2
+#define OPAQUE_ERROR_PROTOCOL -3
+
+typedef uintptr_t opaque_client;
+typedef uintptr_t opaque_server;
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+
+	"github.com/bytemare/opaque/mobile"
+)
+
+func main() {}
+
+// goBytes copies the C input buffer, which may be NULL if its length is 0.
+func goBytes(p *C.uint8_t, length C.size_t) []byte {
+	if p == nil || length == 0 {
+		return nil
+	}
+
+	return C.GoBytes(unsafe.Pointer(p), C.int(length))
+}
+
+// output copies b to a buffer allocated with malloc, owned by the caller, and sets the output pointers.
+func output(b []byte, out **C.uint8_t, outLength *C.size_t) {
+	*out = (*C.uint8_t)(C.CBytes(b))
+	*outLength = C.size_t(len(b))
+}
+
+func validOutputs(outs ...unsafe.Pointer) bool {
+	for _, o := range outs {
+		if o == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func client(handle C.opaque_client) (*mobile.Client, bool) {
+	if handle == 0 {
+		return nil, false
+	}
+
+	c, ok := cgo.Handle(handle).Value().(*mobile.Client)
+
+	return c, ok
+}
+
+func server(handle C.opaque_server) (*mobile.Server, bool) {
+	if handle == 0 {
+		return nil, false
+	}
+
+	s, ok := cgo.Handle(handle).Value().(*mobile.Server)
+
+	return s, ok
+}
+
+// opaque_free releases a buffer output by the library. It is a no-op on NULL.
+//
+//export opaque_free
+func opaque_free(p unsafe.Pointer) {
+	C.free(p)
+}
+
+// opaque_default_configuration outputs the serialization of the default configuration.
+//
+//export opaque_default_configuration
+func opaque_default_configuration(out **C.uint8_t, outLength *C.size_t) C.int {
+	if !validOutputs(unsafe.Pointer(out), unsafe.Pointer(outLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	output(mobile.DefaultConfiguration(), out, outLength)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_generate_key_pair outputs a random AKE key pair for the configuration.
+//
+//export opaque_generate_key_pair
+func opaque_generate_key_pair(
+	conf *C.uint8_t, confLength C.size_t,
+	secretKey **C.uint8_t, secretKeyLength *C.size_t,
+	publicKey **C.uint8_t, publicKeyLength *C.size_t,
+) C.int {
+	if !validOutputs(unsafe.Pointer(secretKey), unsafe.Pointer(secretKeyLength),
+		unsafe.Pointer(publicKey), unsafe.Pointer(publicKeyLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	keys, err := mobile.GenerateKeyPair(goBytes(conf, confLength))
+	if err != nil {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	output(keys.SecretKey, secretKey, secretKeyLength)
+	output(keys.PublicKey, publicKey, publicKeyLength)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_generate_oprf_seed outputs a random OPRF seed for the configuration.
+//
+//export opaque_generate_oprf_seed
+func opaque_generate_oprf_seed(conf *C.uint8_t, confLength C.size_t, out **C.uint8_t, outLength *C.size_t) C.int {
+	if !validOutputs(unsafe.Pointer(out), unsafe.Pointer(outLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	seed, err := mobile.GenerateOPRFSeed(goBytes(conf, confLength))
+	if err != nil {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	output(seed, out, outLength)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_client_new outputs the handle of a new client for the configuration.
+//
+//export opaque_client_new
+func opaque_client_new(conf *C.uint8_t, confLength C.size_t, handle *C.opaque_client) C.int {
+	if handle == nil {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	c, err := mobile.NewClient(goBytes(conf, confLength))
+	if err != nil {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	*handle = C.opaque_client(cgo.NewHandle(c))
+
+	return C.OPAQUE_OK
+}
+
+// opaque_client_free zeroes the client's secrets and releases its handle, which must not be used afterwards.
+//
+//export opaque_client_free
+func opaque_client_free(handle C.opaque_client) C.int {
+	c, ok := client(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	c.Wipe()
+	cgo.Handle(handle).Delete()
+
+	return C.OPAQUE_OK
+}
+
+// opaque_client_registration_init starts a registration, and outputs the RegistrationRequest message.
+//
+//export opaque_client_registration_init
+func opaque_client_registration_init(
+	handle C.opaque_client,
+	password *C.uint8_t, passwordLength C.size_t,
+	request **C.uint8_t, requestLength *C.size_t,
+) C.int {
+	c, ok := client(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	if !validOutputs(unsafe.Pointer(request), unsafe.Pointer(requestLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	output(c.RegistrationInit(goBytes(password, passwordLength)), request, requestLength)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_client_registration_finalize outputs the RegistrationRecord message and the export key, given the server's
+// RegistrationResponse message.
+//
+//export opaque_client_registration_finalize
+func opaque_client_registration_finalize(
+	handle C.opaque_client,
+	response *C.uint8_t, responseLength C.size_t,
+	clientIdentity *C.uint8_t, clientIdentityLength C.size_t,
+	serverIdentity *C.uint8_t, serverIdentityLength C.size_t,
+	record **C.uint8_t, recordLength *C.size_t,
+	exportKey **C.uint8_t, exportKeyLength *C.size_t,
+) C.int {
+	c, ok := client(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	if !validOutputs(unsafe.Pointer(record), unsafe.Pointer(recordLength),
+		unsafe.Pointer(exportKey), unsafe.Pointer(exportKeyLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	result, err := c.RegistrationFinalize(
+		goBytes(response, responseLength),
+		goBytes(clientIdentity, clientIdentityLength),
+		goBytes(serverIdentity, serverIdentityLength),
+	)
+	if err != nil {
+		return C.OPAQUE_ERROR_PROTOCOL
+	}
+
+	output(result.Record, record, recordLength)
+	output(result.ExportKey, exportKey, exportKeyLength)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_client_login_init starts a login, and outputs the KE1 message.
+//
+//export opaque_client_login_init
+func opaque_client_login_init(
+	handle C.opaque_client,
+	password *C.uint8_t, passwordLength C.size_t,
+	ke1 **C.uint8_t, ke1Length *C.size_t,
+) C.int {
+	c, ok := client(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	if !validOutputs(unsafe.Pointer(ke1), unsafe.Pointer(ke1Length)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	output(c.LoginInit(goBytes(password, passwordLength)), ke1, ke1Length)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_client_login_finish outputs the KE3 message, the session key, and the export key, given the server's KE2
+// message. It returns OPAQUE_ERROR_PROTOCOL if the password or the server are not those of the registration.
+//
+//export opaque_client_login_finish
+func opaque_client_login_finish(
+	handle C.opaque_client,
+	ke2 *C.uint8_t, ke2Length C.size_t,
+	clientIdentity *C.uint8_t, clientIdentityLength C.size_t,
+	serverIdentity *C.uint8_t, serverIdentityLength C.size_t,
+	ke3 **C.uint8_t, ke3Length *C.size_t,
+	sessionKey **C.uint8_t, sessionKeyLength *C.size_t,
+	exportKey **C.uint8_t, exportKeyLength *C.size_t,
+) C.int {
+	c, ok := client(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	if !validOutputs(unsafe.Pointer(ke3), unsafe.Pointer(ke3Length), unsafe.Pointer(sessionKey),
+		unsafe.Pointer(sessionKeyLength), unsafe.Pointer(exportKey), unsafe.Pointer(exportKeyLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	result, err := c.LoginFinish(
+		goBytes(ke2, ke2Length),
+		goBytes(clientIdentity, clientIdentityLength),
+		goBytes(serverIdentity, serverIdentityLength),
+	)
+	if err != nil {
+		return C.OPAQUE_ERROR_PROTOCOL
+	}
+
+	output(result.KE3, ke3, ke3Length)
+	output(result.SessionKey, sessionKey, sessionKeyLength)
+	output(result.ExportKey, exportKey, exportKeyLength)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_server_new outputs the handle of a new server for the configuration, with its identity, AKE key pair, and
+// OPRF seed. It returns OPAQUE_ERROR_INVALID_ARGUMENT if the keys or the seed are not valid for the configuration.
+//
+//export opaque_server_new
+func opaque_server_new(
+	conf *C.uint8_t, confLength C.size_t,
+	serverIdentity *C.uint8_t, serverIdentityLength C.size_t,
+	secretKey *C.uint8_t, secretKeyLength C.size_t,
+	publicKey *C.uint8_t, publicKeyLength C.size_t,
+	oprfSeed *C.uint8_t, oprfSeedLength C.size_t,
+	handle *C.opaque_server,
+) C.int {
+	if handle == nil {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	s, err := mobile.NewServer(
+		goBytes(conf, confLength),
+		goBytes(serverIdentity, serverIdentityLength),
+		goBytes(secretKey, secretKeyLength),
+		goBytes(publicKey, publicKeyLength),
+		goBytes(oprfSeed, oprfSeedLength),
+	)
+	if err != nil {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	*handle = C.opaque_server(cgo.NewHandle(s))
+
+	return C.OPAQUE_OK
+}
+
+// opaque_server_free zeroes the server's secrets, including its keys, and releases its handle, which must not be used
+// afterwards.
+//
+//export opaque_server_free
+func opaque_server_free(handle C.opaque_server) C.int {
+	s, ok := server(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	s.Wipe()
+	cgo.Handle(handle).Delete()
+
+	return C.OPAQUE_OK
+}
+
+// opaque_server_registration_response outputs the RegistrationResponse message for the client's RegistrationRequest
+// message, for the credential identifier under which the record will be stored.
+//
+//export opaque_server_registration_response
+func opaque_server_registration_response(
+	handle C.opaque_server,
+	request *C.uint8_t, requestLength C.size_t,
+	credentialIdentifier *C.uint8_t, credentialIdentifierLength C.size_t,
+	response **C.uint8_t, responseLength *C.size_t,
+) C.int {
+	s, ok := server(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	if !validOutputs(unsafe.Pointer(response), unsafe.Pointer(responseLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	resp, err := s.RegistrationResponse(
+		goBytes(request, requestLength),
+		goBytes(credentialIdentifier, credentialIdentifierLength),
+	)
+	if err != nil {
+		return C.OPAQUE_ERROR_PROTOCOL
+	}
+
+	output(resp, response, responseLength)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_server_login_init starts a login, and outputs the KE2 message for the client's KE1 message, given the
+// client's registration record, its credential identifier, and the client identity given at registration, if any.
+//
+//export opaque_server_login_init
+func opaque_server_login_init(
+	handle C.opaque_server,
+	ke1 *C.uint8_t, ke1Length C.size_t,
+	record *C.uint8_t, recordLength C.size_t,
+	credentialIdentifier *C.uint8_t, credentialIdentifierLength C.size_t,
+	clientIdentity *C.uint8_t, clientIdentityLength C.size_t,
+	ke2 **C.uint8_t, ke2Length *C.size_t,
+) C.int {
+	s, ok := server(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	if !validOutputs(unsafe.Pointer(ke2), unsafe.Pointer(ke2Length)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	m, err := s.LoginInit(
+		goBytes(ke1, ke1Length),
+		goBytes(record, recordLength),
+		goBytes(credentialIdentifier, credentialIdentifierLength),
+		goBytes(clientIdentity, clientIdentityLength),
+	)
+	if err != nil {
+		return C.OPAQUE_ERROR_PROTOCOL
+	}
+
+	output(m, ke2, ke2Length)
+
+	return C.OPAQUE_OK
+}
+
+// opaque_server_login_finish verifies the client's KE3 message, and outputs the session key shared with the client.
+// It returns OPAQUE_ERROR_PROTOCOL if the client failed to authenticate.
+//
+//export opaque_server_login_finish
+func opaque_server_login_finish(
+	handle C.opaque_server,
+	ke3 *C.uint8_t, ke3Length C.size_t,
+	sessionKey **C.uint8_t, sessionKeyLength *C.size_t,
+) C.int {
+	s, ok := server(handle)
+	if !ok {
+		return C.OPAQUE_ERROR_INVALID_HANDLE
+	}
+
+	if !validOutputs(unsafe.Pointer(sessionKey), unsafe.Pointer(sessionKeyLength)) {
+		return C.OPAQUE_ERROR_INVALID_ARGUMENT
+	}
+
+	key, err := s.LoginFinish(goBytes(ke3, ke3Length))
+	if err != nil {
+		return C.OPAQUE_ERROR_PROTOCOL
+	}
+
+	output(key, sessionKey, sessionKeyLength)
+
+	return C.OPAQUE_OK
+}
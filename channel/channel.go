@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package channel provides an encrypted and authenticated channel over any transport, keyed by an OPAQUE session key.
+//
+// Each direction has its own AES-256-GCM key and IV derived from the session key. Data is sent in records of at most
+// MaxRecordSize bytes of plaintext, each framed as a one-byte record type and a two-byte ciphertext length, which are
+// authenticated as additional data. The nonce of a record is its sequence number xored with the IV, as in TLS 1.3.
+// After RekeyInterval records, or on Rekey, the writer sends a rekey record and both ends ratchet the key of that
+// direction forward.
+package channel
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+const (
+	// MaxRecordSize is the maximum plaintext length of a record.
+	MaxRecordSize = 1 << 14
+
+	// DefaultRekeyInterval is the number of records after which a direction is rekeyed, if none is set.
+	DefaultRekeyInterval = 1 << 24
+
+	keyLength    = 32
+	nonceLength  = 12
+	headerLength = 3
+
+	labelClientWrite = "OPAQUE-Channel-ClientWrite"
+	labelServerWrite = "OPAQUE-Channel-ServerWrite"
+	labelRekey       = "OPAQUE-Channel-Rekey"
+)
+
+const (
+	recordData byte = iota
+	recordRekey
+)
+
+var (
+	// ErrAuthentication indicates a record that failed authentication, in which case the channel must be closed.
+	ErrAuthentication = errors.New("channel: record authentication failed")
+
+	// ErrRecord indicates a malformed record.
+	ErrRecord = errors.New("channel: invalid record")
+
+	errSessionKey = errors.New("channel: empty session key")
+)
+
+var kdf = internal.NewKDF(crypto.SHA256)
+
+// halfConn holds the state of one direction of the channel.
+type halfConn struct {
+	aead cipher.AEAD
+	key  []byte
+	iv   []byte
+	seq  uint64
+}
+
+func newHalfConn(sessionKey []byte, label string) (*halfConn, error) {
+	material := kdf.Expand(sessionKey, []byte(label), keyLength+nonceLength)
+	h := &halfConn{iv: material[keyLength:]}
+
+	return h, h.setKey(material[:keyLength])
+}
+
+func (h *halfConn) setKey(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	h.aead, h.key, h.seq = aead, key, 0
+
+	return nil
+}
+
+// rekey ratchets the key forward, and restarts the sequence numbers.
+func (h *halfConn) rekey() error {
+	return h.setKey(kdf.Expand(h.key, []byte(labelRekey), keyLength))
+}
+
+func (h *halfConn) nonce() []byte {
+	nonce := make([]byte, nonceLength)
+	binary.BigEndian.PutUint64(nonce[nonceLength-8:], h.seq)
+
+	for i := range nonce {
+		nonce[i] ^= h.iv[i]
+	}
+
+	h.seq++
+
+	return nonce
+}
+
+// Conn is an encrypted channel over a transport. Reads and writes may happen concurrently, but not several reads or
+// several writes.
+type Conn struct {
+	rw  io.ReadWriter
+	in  *halfConn
+	out *halfConn
+	buf []byte
+
+	// RekeyInterval is the number of records after which the writing direction is rekeyed.
+	RekeyInterval uint64
+}
+
+// New returns a channel over the transport rw, keyed by the session key, for the client end if client is true and
+// for the server end otherwise.
+func New(rw io.ReadWriter, sessionKey []byte, client bool) (*Conn, error) {
+	if len(sessionKey) == 0 {
+		return nil, errSessionKey
+	}
+
+	clientWrite, err := newHalfConn(sessionKey, labelClientWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	serverWrite, err := newHalfConn(sessionKey, labelServerWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{rw: rw, in: serverWrite, out: clientWrite, RekeyInterval: DefaultRekeyInterval}
+	if !client {
+		c.in, c.out = clientWrite, serverWrite
+	}
+
+	return c, nil
+}
+
+// Write encrypts p and sends it in one or more records.
+func (c *Conn) Write(p []byte) (int, error) {
+	n := 0
+
+	for len(p) > 0 {
+		if c.out.seq >= c.RekeyInterval {
+			if err := c.Rekey(); err != nil {
+				return n, err
+			}
+		}
+
+		chunk := p
+		if len(chunk) > MaxRecordSize {
+			chunk = chunk[:MaxRecordSize]
+		}
+
+		if err := c.writeRecord(recordData, chunk); err != nil {
+			return n, err
+		}
+
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+
+	return n, nil
+}
+
+// Rekey tells the peer to ratchet the key of the writing direction forward, and does so.
+func (c *Conn) Rekey() error {
+	if err := c.writeRecord(recordRekey, nil); err != nil {
+		return err
+	}
+
+	return c.out.rekey()
+}
+
+func header(recordType byte, length int) []byte {
+	return encoding.Concat([]byte{recordType}, encoding.I2OSP(length, 2))
+}
+
+func (c *Conn) writeRecord(recordType byte, plaintext []byte) error {
+	h := header(recordType, len(plaintext)+c.out.aead.Overhead())
+	record := c.out.aead.Seal(h, c.out.nonce(), plaintext, h)
+
+	_, err := c.rw.Write(record)
+
+	return err
+}
+
+// Read reads and decrypts data from the channel.
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		recordType, plaintext, err := c.readRecord()
+		if err != nil {
+			return 0, err
+		}
+
+		switch recordType {
+		case recordData:
+			c.buf = plaintext
+		case recordRekey:
+			if len(plaintext) != 0 {
+				return 0, ErrRecord
+			}
+
+			if err := c.in.rekey(); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, ErrRecord
+		}
+	}
+
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+
+	return n, nil
+}
+
+func (c *Conn) readRecord() (byte, []byte, error) {
+	h := make([]byte, headerLength)
+	if _, err := io.ReadFull(c.rw, h); err != nil {
+		return 0, nil, err
+	}
+
+	length := encoding.OS2IP(h[1:])
+	if length < c.in.aead.Overhead() || length > MaxRecordSize+c.in.aead.Overhead() {
+		return 0, nil, ErrRecord
+	}
+
+	ciphertext := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, ciphertext); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return 0, nil, err
+	}
+
+	plaintext, err := c.in.aead.Open(nil, c.in.nonce(), ciphertext, h)
+	if err != nil {
+		return 0, nil, ErrAuthentication
+	}
+
+	return h[0], plaintext, nil
+}
@@ -10,6 +10,8 @@ package opaque
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/bytemare/crypto/group"
 
@@ -21,6 +23,7 @@ import (
 	"github.com/bytemare/opaque/internal/oprf"
 	"github.com/bytemare/opaque/internal/tag"
 	"github.com/bytemare/opaque/message"
+	"github.com/bytemare/opaque/securemem"
 )
 
 var (
@@ -29,14 +32,80 @@ var (
 
 	// errKe1Missing happens when LoginFinish is called and the client has no Ke1 in state.
 	errKe1Missing = errors.New("missing KE1 in client state")
+
+	// errBlindMissing happens when the OPRF is finalized and the client has no blind in state, i.e. when
+	// RegistrationFinalize or LoginFinish are called without RegistrationInit or LoginInit, or twice.
+	errBlindMissing = errors.New("missing OPRF blind in client state")
+
+	// errInvalidClientSecretKey happens when a client supplied private key can't be decoded in the AKE group.
+	errInvalidClientSecretKey = errors.New("invalid client secret key")
+
+	// errZeroClientSecretKey happens when a client supplied private key is a zero scalar.
+	errZeroClientSecretKey = errors.New("client private key is zero")
+
+	// ErrInfoLength indicates an application message piggybacked on KE1 or KE2 that is longer than 65535 bytes, or, on
+	// KE2, than the 255 blocks of the KDF that encrypt it, e.g. 16320 bytes with SHA-512.
+	ErrInfoLength = errors.New("application message is too long")
+
+	// errIdentityLength happens when a client or server identity is longer than 65535 bytes.
+	errIdentityLength = errors.New("identity is too long")
+
+	// errChannelBindingLength happens when a channel binding is longer than 65535 bytes.
+	errChannelBindingLength = errors.New("channel binding is too long")
 )
 
+const (
+	// maxInfoLength is the maximum length of an application message piggybacked on KE1 or KE2.
+	maxInfoLength = 1<<16 - 1
+
+	// maxIdentityLength is the maximum length of a client or server identity.
+	maxIdentityLength = 1<<16 - 1
+
+	// maxChannelBindingLength is the maximum length of a channel binding.
+	maxChannelBindingLength = 1<<16 - 1
+
+	// maxExpandBlocks is the maximum number of blocks of a single HKDF-Expand.
+	maxExpandBlocks = 255
+)
+
+// maxServerInfoLength returns the maximum length of the application message piggybacked on KE2, which is encrypted
+// with the pad of a single KDF expansion.
+func maxServerInfoLength(conf *internal.Configuration) int {
+	if length := maxExpandBlocks * conf.KDF.Size(); length < maxInfoLength {
+		return length
+	}
+
+	return maxInfoLength
+}
+
+// checkIdentities returns an error if an identity is too long to be encoded.
+func checkIdentities(clientIdentity, serverIdentity []byte) error {
+	if len(clientIdentity) > maxIdentityLength || len(serverIdentity) > maxIdentityLength {
+		return errIdentityLength
+	}
+
+	return nil
+}
+
 // Client represents an OPAQUE Client, exposing its functions and holding its state.
 type Client struct {
-	Deserialize *Deserializer
+	// Deserialize parses the messages the Client consumes, i.e. the RegistrationResponse, KE2, and KE4.
+	Deserialize *ClientDeserializer
+
 	OPRF        *oprf.Client
 	Ake         *ake.Client
 	conf        *internal.Configuration
+	appData     []byte
+	fingerprint []byte
+	identities  [2][]byte
+	locked      []*securemem.Buffer
+	kat         *knownAnswers
+	logger      eventLogger
+	breach      BreachChecker
+	policy      PasswordPolicy
+
+	// passwordErr is the error of the password checks of RegistrationInit or LoginInit, returned by the finalization.
+	passwordErr error
 }
 
 // NewClient returns a new Client instantiation given the application Configuration.
@@ -53,8 +122,9 @@ func NewClient(c *Configuration) (*Client, error) {
 	return &Client{
 		OPRF:        conf.OPRF.Client(),
 		Ake:         ake.NewClient(),
-		Deserialize: &Deserializer{conf: conf},
+		Deserialize: &ClientDeserializer{d: newDeserializer(conf)},
 		conf:        conf,
+		fingerprint: c.Fingerprint(),
 	}, nil
 }
 
@@ -63,50 +133,187 @@ func (c *Client) GetConf() *internal.Configuration {
 	return c.conf
 }
 
-// buildPRK derives the randomized password from the OPRF output.
+// SetOPRFInfo sets the public info string bound into the OPRF evaluation, using the partially-oblivious (POPRF)
+// mode. It must match the info set on the server, and must be set before RegistrationInit or LoginInit.
+// A nil info uses the base OPRF.
+func (c *Client) SetOPRFInfo(info []byte) {
+	c.OPRF.SetInfo(info)
+}
+
+// SetChannelBinding binds the login to the underlying transport channel, e.g. with a TLS exporter value, by mixing
+// binding into the AKE transcript. The server must set the binding it observes on its end of the channel, so that a
+// man-in-the-middle terminating the transport on both sides can't forward the login. It must be set before
+// LoginFinish, and a nil binding disables it. It returns an error if the binding is longer than 65535 bytes.
+func (c *Client) SetChannelBinding(binding []byte) error {
+	if len(binding) > maxChannelBindingLength {
+		return errChannelBindingLength
+	}
+
+	c.conf.ChannelBinding = binding
+
+	return nil
+}
+
+// buildPRK derives the randomized password from the OPRF output, and zeroes the intermediate values.
 func (c *Client) buildPRK(evaluation *group.Point) []byte {
 	output := c.OPRF.Finalize(evaluation)
 	stretched := c.conf.KSF.Harden(output, nil, c.conf.OPRFPointLength)
+	ikm := encoding.Concat(output, stretched)
+
+	defer func() {
+		for _, secret := range [][]byte{output, stretched, ikm} {
+			internal.Zero(secret)
+		}
+	}()
+
+	randomizedPwd := c.conf.KDF.Extract(nil, ikm)
+	c.conf.Trace(internal.TraceRandomizedPassword, randomizedPwd)
 
-	return c.conf.KDF.Extract(nil, encoding.Concat(output, stretched))
+	return randomizedPwd
 }
 
-// RegistrationInit returns a RegistrationRequest message blinding the given password.
+// blind blinds the password, with a blind hedged with the password unless one has been set for testing.
+func (c *Client) blind(password []byte) *group.Point {
+	if c.kat != nil && c.kat.blind != nil {
+		c.OPRF.SetBlind(c.kat.blind.Copy())
+	}
+
+	if !c.OPRF.HasBlind() {
+		c.OPRF.SetBlind(c.conf.HedgedScalar(c.conf.OPRF.Group(), tag.HedgedBlind, password))
+	}
+
+	return c.OPRF.Blind(password)
+}
+
+// RegistrationInit returns a RegistrationRequest message blinding the given password. If a PasswordPolicy or a
+// BreachChecker is set, the password is checked as in RegistrationStart, and the finalization of the registration
+// returns the error of a rejected password. RegistrationStart rejects it before the request is sent.
 func (c *Client) RegistrationInit(password []byte) *message.RegistrationRequest {
-	m := c.OPRF.Blind(password)
+	c.passwordErr = c.checkNewPassword(password)
+
+	return c.registrationInit(password)
+}
 
-	return &message.RegistrationRequest{
+func (c *Client) registrationInit(password []byte) *message.RegistrationRequest {
+	start := time.Now()
+	request := &message.RegistrationRequest{
 		C:              c.conf.OPRF,
-		BlindedMessage: m,
+		BlindedMessage: c.blind(password),
 	}
-}
 
-// RegistrationFinalizeWithNonce returns a RegistrationRecord message given the identities, server's
-// RegistrationResponse, and the envelope nonce to be used.
-// This function is primarily used for testing purposes and will most probably be removed at some point.
-func (c *Client) RegistrationFinalizeWithNonce(
-	resp *message.RegistrationResponse,
-	clientIdentity, serverIdentity, envelopeNonce []byte,
-) (upload *message.RegistrationRecord, exportKey []byte) {
-	return c.registrationFinalize(clientIdentity, serverIdentity, envelopeNonce, resp)
+	c.logger.log(phaseRegistrationInit, start, request, nil)
+
+	return request
 }
 
 // RegistrationFinalize returns a RegistrationRecord message given the identities and the server's RegistrationResponse.
 func (c *Client) RegistrationFinalize(
 	resp *message.RegistrationResponse,
 	clientIdentity, serverIdentity []byte,
-) (record *message.RegistrationRecord, exportKey []byte) {
-	return c.registrationFinalize(clientIdentity, serverIdentity, nil, resp)
+) (record *message.RegistrationRecord, exportKey ExportKey, err error) {
+	return c.registrationFinalize(clientIdentity, serverIdentity, nil, nil, resp)
+}
+
+// RegistrationOptions holds the optional parameters to finalize the client registration.
+type RegistrationOptions struct {
+	// ClientSecretKey is an existing client private key to use instead of a key derived from the password in the
+	// internal mode, or randomly generated in the external mode.
+	ClientSecretKey []byte
+
+	// AppData is application data sealed in the envelope and returned on login, of at most the configuration's
+	// AppDataLength bytes.
+	AppData []byte
+}
+
+// RegistrationFinalizeWithOptions returns a RegistrationRecord message given the identities, the server's
+// RegistrationResponse, and the registration options.
+func (c *Client) RegistrationFinalizeWithOptions(
+	resp *message.RegistrationResponse,
+	clientIdentity, serverIdentity []byte,
+	options *RegistrationOptions,
+) (record *message.RegistrationRecord, exportKey ExportKey, err error) {
+	if options == nil {
+		return c.registrationFinalize(clientIdentity, serverIdentity, nil, nil, resp)
+	}
+
+	var sk *group.Scalar
+
+	if options.ClientSecretKey != nil {
+		sk, err = c.decodeClientSecretKey(options.ClientSecretKey)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return c.registrationFinalize(clientIdentity, serverIdentity, sk, options.AppData, resp)
+}
+
+// RegistrationFinalizeWithClientKey returns a RegistrationRecord message given the identities, the server's
+// RegistrationResponse, and an existing client private key that is used instead of a key derived from the password.
+// The same key must then be supplied at login with LoginFinishWithClientKey.
+func (c *Client) RegistrationFinalizeWithClientKey(
+	resp *message.RegistrationResponse,
+	clientIdentity, serverIdentity, clientSecretKey []byte,
+) (record *message.RegistrationRecord, exportKey ExportKey, err error) {
+	if clientSecretKey == nil {
+		return nil, nil, errInvalidClientSecretKey
+	}
+
+	return c.RegistrationFinalizeWithOptions(
+		resp,
+		clientIdentity,
+		serverIdentity,
+		&RegistrationOptions{ClientSecretKey: clientSecretKey},
+	)
+}
+
+// decodeClientSecretKey verifies that the given private key is a valid non-zero scalar in the AKE group.
+func (c *Client) decodeClientSecretKey(clientSecretKey []byte) (*group.Scalar, error) {
+	if len(clientSecretKey) != encoding.ScalarLength[c.conf.Group] {
+		return nil, errInvalidClientSecretKey
+	}
+
+	sk, err := c.conf.Group.NewScalar().Decode(clientSecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errInvalidClientSecretKey, err)
+	}
+
+	if sk.IsZero() {
+		return nil, errZeroClientSecretKey
+	}
+
+	return sk, nil
 }
 
 func (c *Client) registrationFinalize(
-	clientIdentity, serverIdentity, envelopeNonce []byte,
+	clientIdentity, serverIdentity []byte,
+	clientSecretKey *group.Scalar,
+	appData []byte,
 	resp *message.RegistrationResponse,
-) (upload *message.RegistrationRecord, exportKey []byte) {
-	creds2 := &keyrecovery.Credentials{
-		ClientIdentity: clientIdentity,
-		ServerIdentity: serverIdentity,
-		EnvelopeNonce:  envelopeNonce,
+) (upload *message.RegistrationRecord, exportKey ExportKey, err error) {
+	defer func(start time.Time) {
+		c.logger.log(phaseRegistrationFinalize, start, upload, err)
+	}(time.Now())
+
+	if c.passwordErr != nil {
+		return nil, nil, c.passwordErr
+	}
+
+	if !c.OPRF.HasBlind() {
+		return nil, nil, errBlindMissing
+	}
+
+	if resp == nil || resp.EvaluatedMessage == nil || resp.Pks == nil {
+		return nil, nil, errIncompleteMessage
+	}
+
+	if err = checkIdentities(clientIdentity, serverIdentity); err != nil {
+		return nil, nil, err
+	}
+
+	var envelopeNonce []byte
+	if c.kat != nil {
+		envelopeNonce = c.kat.envelopeNonce
 	}
 
 	// this check is very important: it verifies the server's public key validity in the group.
@@ -115,33 +322,73 @@ func (c *Client) registrationFinalize(
 	// }
 
 	randomizedPwd := c.buildPRK(resp.EvaluatedMessage)
+	defer internal.Zero(randomizedPwd)
+
 	maskingKey := c.conf.KDF.Expand(randomizedPwd, []byte(tag.MaskingKey), c.conf.KDF.Size())
-	envelope, clientPublicKey, exportKey := keyrecovery.Store(
+	envelope, clientPublicKey, exportKey, err := keyrecovery.Store(
 		c.conf,
 		randomizedPwd,
 		encoding.SerializePoint(resp.Pks, c.conf.Group),
-		creds2,
+		clientSecretKey,
+		appData,
+		&keyrecovery.Identities{Client: clientIdentity, Server: serverIdentity},
+		envelopeNonce,
 	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.OPRF.Wipe()
+
+	exportKey = c.lock(exportKey)
 
 	return &message.RegistrationRecord{
 		G:          c.conf.Group,
 		PublicKey:  clientPublicKey,
 		MaskingKey: maskingKey,
 		Envelope:   envelope.Serialize(),
-	}, exportKey
+	}, exportKey, nil
 }
 
-// LoginInit initiates the authentication process, returning a KE1 message blinding the given password.
-// clientInfo is optional client information sent in clear, and only authenticated in KE3.
+// LoginInit initiates the authentication process, returning a KE1 message blinding the given password. If a
+// PasswordPolicy is set, the password is checked as in AuthenticationStart, and LoginFinish returns the error of a
+// rejected password. AuthenticationStart rejects it before KE1 is sent.
 func (c *Client) LoginInit(password []byte) *message.KE1 {
-	m := c.OPRF.Blind(password)
+	c.passwordErr = c.checkPassword(password)
+
+	return c.loginInit(password, nil)
+}
+
+// LoginInitWithInfo is like LoginInit, but piggybacks the clientInfo application message on KE1. clientInfo is sent
+// in clear, and only authenticated to the server in KE3: it must not be confidential, nor acted upon before
+// LoginFinish succeeds on the server.
+func (c *Client) LoginInitWithInfo(password, clientInfo []byte) (*message.KE1, error) {
+	if len(clientInfo) > maxInfoLength {
+		return nil, ErrInfoLength
+	}
+
+	if err := c.checkPassword(password); err != nil {
+		return nil, err
+	}
+
+	c.passwordErr = nil
+
+	return c.loginInit(password, clientInfo), nil
+}
+
+func (c *Client) loginInit(password, clientInfo []byte) *message.KE1 {
+	start := time.Now()
+	m := c.blind(password)
 	credReq := &message.CredentialRequest{
 		C:              c.conf.OPRF,
 		BlindedMessage: m,
 	}
-	ke1 := c.Ake.Start(c.conf.Group)
+	c.kat.setAkeValues(c.conf.Group, c.Ake.SetValues)
+	ke1 := c.Ake.Start(c.conf, credReq.Serialize(), clientInfo)
 	ke1.CredentialRequest = credReq
+	ke1.ClientInfo = clientInfo
 	c.Ake.Ke1 = ke1.Serialize()
+	c.logger.log(phaseLoginInit, start, ke1, nil)
 
 	return ke1
 }
@@ -151,18 +398,82 @@ func (c *Client) LoginInit(password []byte) *message.KE1 {
 func (c *Client) LoginFinish(
 	clientIdentity, serverIdentity []byte,
 	ke2 *message.KE2,
-) (ke3 *message.KE3, exportKey []byte, err error) {
+) (ke3 *message.KE3, exportKey ExportKey, err error) {
+	return c.loginFinish(clientIdentity, serverIdentity, nil, nil, ke2)
+}
+
+// LoginFinishWithClientKey returns a KE3 message given the server's KE2 response message, the identities, and the
+// client private key that was given at registration with RegistrationFinalizeWithClientKey.
+func (c *Client) LoginFinishWithClientKey(
+	clientIdentity, serverIdentity, clientSecretKey []byte,
+	ke2 *message.KE2,
+) (ke3 *message.KE3, exportKey ExportKey, err error) {
+	sk, err := c.decodeClientSecretKey(clientSecretKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.loginFinish(clientIdentity, serverIdentity, sk, nil, ke2)
+}
+
+// lock moves the secret into locked memory if the configuration enables it, and keeps track of its buffer until Wipe.
+func (c *Client) lock(secret []byte) []byte {
+	secret, buf := c.conf.Lock(secret)
+	if buf != nil {
+		c.locked = append(c.locked, buf)
+	}
+
+	return secret
+}
+
+func (c *Client) loginFinish(
+	clientIdentity, serverIdentity []byte,
+	knownSecretKey *group.Scalar,
+	evaluation *group.Point,
+	ke2 *message.KE2,
+) (ke3 *message.KE3, exportKey ExportKey, err error) {
+	defer func(start time.Time) {
+		c.logger.log(phaseLoginFinish, start, ke3, err)
+	}(time.Now())
+
+	if c.passwordErr != nil {
+		return nil, nil, c.passwordErr
+	}
+
 	if len(c.Ake.Ke1) == 0 {
 		return nil, nil, errKe1Missing
 	}
 
+	if !c.OPRF.HasBlind() {
+		return nil, nil, errBlindMissing
+	}
+
+	if ke2 == nil || ke2.CredentialResponse == nil || ke2.EpkS == nil ||
+		(evaluation == nil && ke2.EvaluatedMessage == nil) {
+		return nil, nil, errIncompleteMessage
+	}
+
+	if err = checkIdentities(clientIdentity, serverIdentity); err != nil {
+		return nil, nil, err
+	}
+
+	if len(ke2.EncryptedServerInfo) > maxServerInfoLength(c.conf) {
+		return nil, nil, ErrInfoLength
+	}
+
 	// This test is very important as it avoids buffer overflows in subsequent parsing.
 	if len(ke2.MaskedResponse) != c.conf.AkePointLength+c.conf.EnvelopeSize {
-		return nil, nil, errInvalidMaskedLength
+		return nil, nil, internal.Detail(errInvalidMaskedLength, "masked response of %d bytes, expected %d",
+			len(ke2.MaskedResponse), c.conf.AkePointLength+c.conf.EnvelopeSize)
+	}
+
+	// Finalize the OPRF, with the combined evaluation in threshold mode.
+	if evaluation == nil {
+		evaluation = ke2.EvaluatedMessage
 	}
 
-	// Finalize the OPRF.
-	randomizedPwd := c.buildPRK(ke2.EvaluatedMessage)
+	randomizedPwd := c.buildPRK(evaluation)
+	defer internal.Zero(randomizedPwd)
 
 	// Decrypt the masked response.
 	serverPublicKey, serverPublicKeyBytes,
@@ -173,12 +484,12 @@ func (c *Client) LoginFinish(
 
 	// Recover the client keys.
 	clientSecretKey, clientPublicKey,
-		exportKey, err := keyrecovery.Recover(
+		exportKey, appData, err := keyrecovery.Recover(
 		c.conf,
 		randomizedPwd,
 		serverPublicKeyBytes,
-		clientIdentity,
-		serverIdentity,
+		&keyrecovery.Identities{Client: clientIdentity, Server: serverIdentity},
+		knownSecretKey,
 		envelope)
 	if err != nil {
 		return nil, nil, err
@@ -198,10 +509,63 @@ func (c *Client) LoginFinish(
 		return nil, nil, err
 	}
 
-	return ke3, exportKey, nil
+	c.OPRF.Wipe()
+	c.appData = appData
+	c.identities = [2][]byte{clientIdentity, serverIdentity}
+
+	return ke3, c.lock(exportKey), nil
+}
+
+// KE1 returns a copy of the serialized KE1 of the last call to LoginInit, or nil if there is none.
+func (c *Client) KE1() []byte {
+	if len(c.Ake.Ke1) == 0 {
+		return nil
+	}
+
+	return append([]byte(nil), c.Ake.Ke1...)
+}
+
+// EphemeralPublicKey returns the serialized ephemeral AKE public key of the last call to LoginInit, or nil if there is
+// none. The ephemeral private key is never exposed.
+func (c *Client) EphemeralPublicKey() []byte {
+	epk := c.Ake.EphemeralPublicKey()
+	if epk == nil {
+		return nil
+	}
+
+	return encoding.SerializePoint(epk, c.conf.Group)
+}
+
+// BlindedElement returns the serialized blinded OPRF element of the last call to RegistrationInit or LoginInit, until
+// the registration or login is finalized, or nil otherwise. The blind is never exposed.
+func (c *Client) BlindedElement() []byte {
+	blinded := c.OPRF.Blinded()
+	if blinded == nil {
+		return nil
+	}
+
+	return c.conf.OPRF.SerializePoint(blinded)
 }
 
 // SessionKey returns the session key if the previous call to LoginFinish() was successful.
 func (c *Client) SessionKey() []byte {
 	return c.Ake.SessionKey()
 }
+
+// TranscriptHash returns the hash of the handshake transcript if the previous call to LoginFinish() was successful.
+// Both ends compute the same hash, which commits to the whole handshake, up to the server MAC.
+func (c *Client) TranscriptHash() []byte {
+	return c.Ake.TranscriptHash()
+}
+
+// ServerInfo returns the application message the server piggybacked on KE2, decrypted and authenticated, if the
+// previous call to LoginFinish() was successful.
+func (c *Client) ServerInfo() []byte {
+	return c.Ake.ServerInfo()
+}
+
+// AppData returns the application data sealed in the envelope at registration, if the previous call to LoginFinish()
+// was successful.
+func (c *Client) AppData() []byte {
+	return c.appData
+}
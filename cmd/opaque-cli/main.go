@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Command opaque-cli is an OPAQUE client for the endpoints of the opaquehttp package, e.g. served by opaqued, for
+// debugging and scripting:
+//
+//	opaque-cli -server http://localhost:8080/opaque -id alice register
+//	opaque-cli -server http://localhost:8080/opaque -id alice -dump login
+//
+// The password is read from the OPAQUE_PASSWORD environment variable, or else from the first line of the standard
+// input. The configuration and the server's public key and identity are fetched from the server's configuration
+// endpoint, e.g. of opaqued, unless the configuration is given in hex with -configuration, for servers without one.
+// With -fingerprint, the configuration must be the one of the fingerprint, e.g. as printed by the server's operator.
+// With -pwned-range, e.g. https://api.pwnedpasswords.com/range, a password is only registered if the range API doesn't
+// list it as breached: the API only learns the first five hexadecimal characters of its SHA-1 hash.
+// The server's public key in a registration response must be the one fetched or given with -server-public-key, if
+// any, and the login fails if the server answers with the hash of a session key other than the client's.
+//
+// The results are printed as name=hex lines: the export key after a registration, the export and session keys after
+// a login, and with -dump, the messages.
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bytemare/opaque"
+)
+
+var errUsage = errors.New("usage: opaque-cli [flags] register|login")
+
+// cli holds the parameters of a command.
+type cli struct {
+	server         *server
+	conf           *opaque.Configuration
+	out            io.Writer
+	id             []byte
+	password       []byte
+	clientIdentity []byte
+	serverIdentity []byte
+	pwnedRange     string
+	dump           bool
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), errUsage)
+		flag.PrintDefaults()
+	}
+
+	url := flag.String("server", "http://localhost:8080/opaque", "the URL prefix of the server's endpoints")
+	id := flag.String("id", "", "the credential identifier")
+	clientIdentity := flag.String("client-identity", "", "the client identity, defaults to the client public key")
+	serverIdentity := flag.String("server-identity", "", "the server identity, defaults to the server's")
+	configuration := flag.String("configuration", "", "the serialized configuration in hex, defaults to the server's")
+	publicKey := flag.String("server-public-key", "", "the server public key in hex, defaults to the server's")
+	fingerprint := flag.String("fingerprint", "", "the hex fingerprint of the only accepted configuration")
+	pwned := flag.String("pwned-range", "", "the URL of a Pwned Passwords range API to check new passwords against")
+	dump := flag.Bool("dump", false, "print the messages in hex")
+	flag.Parse()
+
+	c := &cli{
+		server:         &server{url: strings.TrimSuffix(*url, "/")},
+		out:            os.Stdout,
+		id:             []byte(*id),
+		clientIdentity: optional(*clientIdentity),
+		serverIdentity: optional(*serverIdentity),
+		pwnedRange:     strings.TrimSuffix(*pwned, "/"),
+		dump:           *dump,
+	}
+
+	if err := c.run(flag.Args(), *configuration, *publicKey, *fingerprint); err != nil {
+		fmt.Fprintln(os.Stderr, "opaque-cli:", err)
+		os.Exit(1)
+	}
+}
+
+func optional(s string) []byte {
+	if s == "" {
+		return nil
+	}
+
+	return []byte(s)
+}
+
+func (c *cli) run(args []string, configuration, publicKey, fingerprint string) error {
+	if len(args) != 1 || len(c.id) == 0 {
+		return errUsage
+	}
+
+	if err := c.configure(configuration, publicKey, fingerprint); err != nil {
+		return err
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	c.password = password
+
+	switch args[0] {
+	case "register":
+		return c.register()
+	case "login":
+		return c.login()
+	default:
+		return errUsage
+	}
+}
+
+// configure sets the configuration and the server's public key from the flags, or else from the server's
+// advertisement, with its identity if the client doesn't set one. The configuration must have the fingerprint, if set.
+func (c *cli) configure(configuration, publicKey, fingerprint string) error {
+	encoded, err := hex.DecodeString(configuration)
+	if err != nil {
+		return fmt.Errorf("-configuration: %w", err)
+	}
+
+	policy := new(opaque.ConfigurationPolicy)
+
+	if fingerprint != "" {
+		pin, err := hex.DecodeString(fingerprint)
+		if err != nil {
+			return fmt.Errorf("-fingerprint: %w", err)
+		}
+
+		policy.Fingerprints = [][]byte{pin}
+	}
+
+	if c.server.publicKey, err = hex.DecodeString(publicKey); err != nil {
+		return fmt.Errorf("-server-public-key: %w", err)
+	}
+
+	hash := sha256.Sum256(encoded)
+	params := new(parameters)
+	params.Configuration, params.Fingerprint = encoded, hash[:]
+
+	if configuration == "" {
+		if params, err = c.server.configuration(); err != nil {
+			return err
+		}
+
+		if publicKey == "" {
+			c.server.publicKey = params.ServerPublicKey
+		}
+
+		if c.serverIdentity == nil && len(params.ServerIdentity) != 0 {
+			c.serverIdentity = params.ServerIdentity
+		}
+	}
+
+	c.conf, err = policy.Accept(&params.ConfigurationAdvertisement)
+
+	return err
+}
+
+func readPassword() ([]byte, error) {
+	if password, ok := os.LookupEnv("OPAQUE_PASSWORD"); ok {
+		return []byte(password), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+// print writes the value as a name=hex line, if it's not a dump or dumps are enabled.
+func (c *cli) print(name string, value []byte, dump bool) {
+	if !dump || c.dump {
+		fmt.Fprintf(c.out, "%s=%s\n", name, hex.EncodeToString(value))
+	}
+}
+
+func (c *cli) register() error {
+	client, err := c.conf.Client()
+	if err != nil {
+		return err
+	}
+
+	if c.pwnedRange != "" {
+		client.SetBreachChecker(&opaque.PwnedPasswords{Range: c.server.pwnedRange(c.pwnedRange)})
+	}
+
+	request, err := client.RegistrationStart(c.password)
+	if err != nil {
+		return err
+	}
+
+	c.print("registration_request", request.Serialize(), true)
+
+	encoded, err := c.server.call("/register/init", &message{CredentialIdentifier: c.id, Message: request.Serialize()})
+	if err != nil {
+		return err
+	}
+
+	c.print("registration_response", encoded.Message, true)
+
+	response, err := client.Deserialize.RegistrationResponse(encoded.Message)
+	if err != nil {
+		return err
+	}
+
+	if !c.server.knows(response.Pks) {
+		return errServerKey
+	}
+
+	record, exportKey, err := client.RegistrationFinalize(response, c.clientIdentity, c.serverIdentity)
+	if err != nil {
+		return err
+	}
+
+	c.print("registration_record", record.Serialize(), true)
+
+	if _, err = c.server.call("/register/finish", &message{
+		Session:              encoded.Session,
+		CredentialIdentifier: c.id,
+		ClientIdentity:       c.clientIdentity,
+		Message:              record.Serialize(),
+	}); err != nil {
+		return err
+	}
+
+	c.print("export_key", exportKey, false)
+
+	return nil
+}
+
+func (c *cli) login() error {
+	client, err := c.conf.Client()
+	if err != nil {
+		return err
+	}
+
+	ke1 := client.LoginInit(c.password)
+	c.print("ke1", ke1.Serialize(), true)
+
+	encoded, err := c.server.call("/login/init", &message{CredentialIdentifier: c.id, Message: ke1.Serialize()})
+	if err != nil {
+		return err
+	}
+
+	c.print("ke2", encoded.Message, true)
+
+	ke2, err := client.Deserialize.KE2(encoded.Message)
+	if err != nil {
+		return err
+	}
+
+	ke3, exportKey, err := client.LoginFinish(c.clientIdentity, c.serverIdentity, ke2)
+	if err != nil {
+		return err
+	}
+
+	c.print("ke3", ke3.Serialize(), true)
+
+	finish, err := c.server.call("/login/finish", &message{Session: encoded.Session, Message: ke3.Serialize()})
+	if err != nil {
+		return err
+	}
+
+	if finish != nil && finish.SessionKeyHash != nil {
+		hash := sha256.Sum256(client.SessionKey())
+		if subtle.ConstantTimeCompare(hash[:], finish.SessionKeyHash) != 1 {
+			return errSessionKey
+		}
+	}
+
+	c.print("export_key", exportKey, false)
+	c.print("session_key", client.SessionKey(), false)
+
+	return nil
+}
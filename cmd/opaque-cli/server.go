@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bytemare/crypto/group"
+
+	opaquemessage "github.com/bytemare/opaque/message"
+)
+
+// maxResponseSize is the maximum size of a response body.
+const maxResponseSize = 1 << 16
+
+// maxRangeSize is the maximum size of a range API response, which lists around a thousand hashes.
+const maxRangeSize = 1 << 20
+
+var (
+	errServer     = errors.New("server error")
+	errServerKey  = errors.New("the registration response holds another server public key")
+	errSessionKey = errors.New("the server's session key differs")
+)
+
+// message is the JSON object of the requests and responses of the opaquehttp endpoints, with their bytes base64
+// encoded, and the hash of the session key returned by opaqued after a login.
+type message struct {
+	CredentialIdentifier []byte `json:"credential_identifier,omitempty"`
+	ClientIdentity       []byte `json:"client_identity,omitempty"`
+	Session              string `json:"session,omitempty"`
+	Message              []byte `json:"message,omitempty"`
+	SessionKeyHash       []byte `json:"session_key_hash,omitempty"`
+}
+
+// parameters are the client's parameters served by the configuration handler of opaquehttp.
+type parameters struct {
+	opaquemessage.ConfigurationAdvertisement
+
+	ServerPublicKey []byte `json:"server_public_key"`
+	ServerIdentity  []byte `json:"server_identity"`
+}
+
+// server is the client of the server's endpoints.
+type server struct {
+	client    http.Client
+	url       string
+	publicKey []byte
+}
+
+// knows returns whether the public key is the server's, or true if the server's public key is not known.
+func (s *server) knows(pk *group.Point) bool {
+	if len(s.publicKey) == 0 {
+		return true
+	}
+
+	return subtle.ConstantTimeCompare(pk.Bytes(), s.publicKey) == 1
+}
+
+func (s *server) configuration() (*parameters, error) {
+	s.client.Timeout = 30 * time.Second
+
+	resp, err := s.client.Get(s.url + "/configuration")
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(parameters)
+	if err = decode(resp, p); err != nil {
+		return nil, fmt.Errorf("fetching the configuration: %w", err)
+	}
+
+	return p, nil
+}
+
+// call posts the request to the endpoint, and returns the response, or nil if it's empty.
+func (s *server) call(endpoint string, req *message) (*message, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client.Timeout = 30 * time.Second
+
+	resp, err := s.client.Post(s.url+endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, resp.Body.Close()
+	}
+
+	m := new(message)
+	if err = decode(resp, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", endpoint, err)
+	}
+
+	return m, nil
+}
+
+// pwnedRange returns the function querying the Pwned Passwords range API at the URL for a hash prefix.
+func (s *server) pwnedRange(url string) func(prefix string) ([]byte, error) {
+	return func(prefix string) ([]byte, error) {
+		s.client.Timeout = 30 * time.Second
+
+		resp, err := s.client.Get(url + "/" + prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%w: %s", errServer, resp.Status)
+		}
+
+		return io.ReadAll(io.LimitReader(resp.Body, maxRangeSize))
+	}
+}
+
+// decode decodes the JSON body of a successful response, and closes it.
+func decode(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", errServer, resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
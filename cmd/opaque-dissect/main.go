@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Command opaque-dissect prints the fields of captured OPAQUE messages, with their offsets, lengths, and hexadecimal
+// values, to debug the exchanges between implementations. The message is read in hexadecimal from the arguments, or
+// from the standard input, raw with -raw. Its type is given with -type, or else guessed from its length, and the
+// configuration is given as the hexadecimal encoding of Configuration.Serialize, defaulting to DefaultConfiguration:
+//
+//	opaque-dissect -config 0107070702010000 -type KE2 4a7f3b...
+//	opaque-dissect -raw < ke2.bin
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bytemare/opaque"
+)
+
+var errUnknownType = errors.New("unknown message type")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "opaque-dissect:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, r io.Reader, w io.Writer) error {
+	var (
+		config string
+		name   string
+		raw    bool
+	)
+
+	flags := flag.NewFlagSet("opaque-dissect", flag.ContinueOnError)
+	flags.StringVar(&config, "config", "", "the hex-encoded serialized configuration, defaulting to the default one")
+	flags.StringVar(&name, "type", "", "the message type, e.g. KE1, or empty to guess it from the length")
+	flags.BoolVar(&raw, "raw", false, "read the message in binary from the standard input")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	d, err := deserializer(config)
+	if err != nil {
+		return err
+	}
+
+	m, err := readMessage(flags.Args(), r, raw)
+	if err != nil {
+		return err
+	}
+
+	types, err := messageTypes(d, name, m)
+	if err != nil {
+		return err
+	}
+
+	if len(types) == 0 {
+		return fmt.Errorf("no message of the configuration has length %d, set -type to dissect it anyway", len(m))
+	}
+
+	for _, t := range types {
+		fields, err := d.Dissect(t, m)
+
+		fmt.Fprintf(w, "%v (%d bytes)\n", t, len(m))
+
+		for _, f := range fields {
+			fmt.Fprintf(w, "  %v\n", f)
+		}
+
+		if err != nil {
+			fmt.Fprintf(w, "  error: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+func deserializer(config string) (*opaque.Deserializer, error) {
+	conf := opaque.DefaultConfiguration()
+
+	if config != "" {
+		encoded, err := hex.DecodeString(config)
+		if err != nil {
+			return nil, fmt.Errorf("decoding the configuration: %w", err)
+		}
+
+		if conf, err = opaque.DeserializeConfiguration(encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	return conf.Deserializer()
+}
+
+func readMessage(args []string, r io.Reader, raw bool) ([]byte, error) {
+	if len(args) != 0 {
+		return hex.DecodeString(strings.Join(args, ""))
+	}
+
+	input, err := io.ReadAll(r)
+	if err != nil || raw {
+		return input, err
+	}
+
+	return hex.DecodeString(strings.Join(strings.Fields(string(input)), ""))
+}
+
+func messageTypes(d *opaque.Deserializer, name string, m []byte) ([]opaque.MessageType, error) {
+	if name == "" {
+		return d.MessageTypes(m), nil
+	}
+
+	for t := opaque.RegistrationRequestMessage; t <= opaque.PartialEvaluationMessage; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return []opaque.MessageType{t}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errUnknownType, name)
+}
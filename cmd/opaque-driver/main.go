@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Command opaque-driver is the interoperability driver of this implementation: it answers the requests of the
+// interop package's protocol read from its standard input on its standard output, so that the harnesses of other
+// implementations can run handshakes against it.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bytemare/opaque/interop"
+)
+
+func main() {
+	if err := interop.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "opaque-driver:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Command opaque-migrate flags the records of a file credential store for re-registration, so that a server with the
+// store, like opaqued, asks their clients to register again on their next login, e.g. before retiring the records'
+// configuration or after a leak of the store. Records can't be converted offline, since that needs the passwords.
+//
+// The configuration of the records is given as the hexadecimal encoding of Configuration.Serialize, of this or an
+// earlier version of the library, defaulting to DefaultConfiguration. The records that aren't valid in it are counted
+// as invalid and not flagged, and -dry-run only reports what would be flagged:
+//
+//	opaque-migrate -dir records -config 0107070702010000 -dry-run
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaquehttp"
+)
+
+var errMissingDir = errors.New("missing the -dir of the records")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "opaque-migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	var (
+		config string
+		dir    string
+		dryRun bool
+	)
+
+	flags := flag.NewFlagSet("opaque-migrate", flag.ContinueOnError)
+	flags.StringVar(&config, "config", "", "the hex-encoded serialized configuration, defaulting to the default one")
+	flags.StringVar(&dir, "dir", "", "the directory of the file credential store")
+	flags.BoolVar(&dryRun, "dry-run", false, "only report the records that would be flagged")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if dir == "" {
+		return errMissingDir
+	}
+
+	conf, err := configuration(config)
+	if err != nil {
+		return err
+	}
+
+	if _, err = os.Stat(dir); err != nil {
+		return err
+	}
+
+	store, err := opaquehttp.NewFileCredentialStore(dir, conf)
+	if err != nil {
+		return err
+	}
+
+	var rehash opaquehttp.RehashStore
+	if !dryRun {
+		rehash = store
+	}
+
+	report, err := opaquehttp.Migrate(conf, store, rehash, nil)
+	if err != nil {
+		return err
+	}
+
+	verb := "flagged"
+	if dryRun {
+		verb = "would flag"
+	}
+
+	fmt.Fprintf(w, "%d records, %s %d for re-registration, %d invalid in the configuration\n",
+		report.Records, verb, report.Flagged, report.Invalid)
+
+	return nil
+}
+
+func configuration(config string) (*opaque.Configuration, error) {
+	if config == "" {
+		return opaque.DefaultConfiguration(), nil
+	}
+
+	encoded, err := hex.DecodeString(config)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the configuration: %w", err)
+	}
+
+	return opaque.DeserializeConfiguration(encoded)
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Command opaque-vectors writes deterministic test vectors of this implementation in the draft's JSON format, for
+// other implementations to validate against. It writes the vector of a registration and login, and the vector of a
+// login with fake credentials, of the suite given by the flags, named as in the draft:
+//
+//	opaque-vectors -group P256_XMD:SHA-256_SSWU_RO_ -hash SHA256 -ksf Identity -seed 00 > vectors.json
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bytemare/opaque/vectors"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "opaque-vectors:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	var (
+		config vectors.Config
+		params vectors.Parameters
+		seed   string
+		hash   string
+		ctx    string
+		idc    string
+		ids    string
+	)
+
+	flags := flag.NewFlagSet("opaque-vectors", flag.ContinueOnError)
+	flags.StringVar(&config.Group, "group", "ristretto255", "the OPRF and AKE group")
+	flags.StringVar(&hash, "hash", "SHA512", "the hash function of the Hash, KDF, and MAC")
+	flags.StringVar(&config.KSF, "ksf", "Identity", "the key stretching function")
+	flags.StringVar(&config.Name, "name", "3DH", "the AKE protocol")
+	flags.StringVar(&ctx, "context", "OPAQUE-POC", "the context")
+	flags.StringVar(&seed, "seed", "00", "the hex-encoded seed of the random values")
+	flags.StringVar(&idc, "client-identity", "", "the client identity, defaulting to its public key")
+	flags.StringVar(&ids, "server-identity", "", "the server identity, defaulting to its public key")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config.Hash, config.KDF, config.MAC, config.Context = hash, "HKDF-"+hash, "HMAC-"+hash, []byte(ctx)
+
+	conf, err := config.Configuration()
+	if err != nil {
+		return err
+	}
+
+	if params.Seed, err = hex.DecodeString(seed); err != nil {
+		return fmt.Errorf("decoding the seed: %w", err)
+	}
+
+	if idc != "" {
+		params.ClientIdentity = []byte(idc)
+	}
+
+	if ids != "" {
+		params.ServerIdentity = []byte(ids)
+	}
+
+	registered, err := vectors.Generate(conf, &params)
+	if err != nil {
+		return err
+	}
+
+	params.Fake = true
+
+	fake, err := vectors.Generate(conf, &params)
+	if err != nil {
+		return err
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "    ")
+
+	return e.Encode([]*vectors.Vector{registered, fake})
+}
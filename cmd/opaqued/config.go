@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaquehttp"
+)
+
+var (
+	errUnknownStore    = errors.New("unknown record store")
+	errUnknownOverflow = errors.New("unknown session overflow policy")
+	errMissingKeys     = errors.New("missing server keys or OPRF seed, see -init")
+)
+
+// config is the configuration file of the server.
+type config struct {
+	// Listen is the address to listen on, e.g. ":8080".
+	Listen string `json:"listen"`
+
+	// Prefix is the path prefix of the endpoints, e.g. "/opaque".
+	Prefix string `json:"prefix"`
+
+	// Configuration is the OPAQUE configuration, in the JSON encoding of opaque.Configuration.
+	Configuration *opaque.Configuration `json:"configuration"`
+
+	// The server's identity is optional, and the keys and OPRF seed are base64 encoded.
+	ServerIdentity  []byte `json:"server_identity,omitempty"`
+	ServerSecretKey []byte `json:"server_secret_key"`
+	ServerPublicKey []byte `json:"server_public_key"`
+	OPRFSeed        []byte `json:"oprf_seed"`
+
+	// Store selects where the records are kept.
+	Store storeConfig `json:"store"`
+
+	// SessionTTL is how long a login session waits for the client's KE3, e.g. "1m".
+	SessionTTL string `json:"session_ttl"`
+
+	// MaxSessions caps the number of pending login sessions, or is 0 for no cap, and SessionOverflow selects what
+	// happens to the sessions beyond it: "reject" them, or "evict" the oldest pending ones.
+	MaxSessions     int    `json:"max_sessions,omitempty"`
+	SessionOverflow string `json:"session_overflow,omitempty"`
+}
+
+// overflowPolicies are the policies selectable as session_overflow in the configuration file.
+var overflowPolicies = map[string]opaquehttp.OverflowPolicy{
+	"":       opaquehttp.RejectNew,
+	"reject": opaquehttp.RejectNew,
+	"evict":  opaquehttp.EvictOldest,
+}
+
+// storeConfig selects a record store, by type: "memory", or "file" in the Path directory.
+type storeConfig struct {
+	Type string `json:"type"`
+	Path string `json:"path,omitempty"`
+}
+
+// stores are the record stores selectable in the configuration file. Other stores plug in here.
+var stores = map[string]func(s storeConfig, conf *opaque.Configuration) (opaquehttp.CredentialStore, error){
+	"memory": func(storeConfig, *opaque.Configuration) (opaquehttp.CredentialStore, error) {
+		return opaquehttp.NewMemoryCredentialStore(), nil
+	},
+	"file": func(s storeConfig, conf *opaque.Configuration) (opaquehttp.CredentialStore, error) {
+		return opaquehttp.NewFileCredentialStore(s.Path, conf)
+	},
+}
+
+// newConfig returns a configuration with the default OPAQUE configuration, and new keys and OPRF seed.
+func newConfig() (*config, error) {
+	c := &config{
+		Listen:        "localhost:8080",
+		Prefix:        "/opaque",
+		Configuration: opaque.DefaultConfiguration(),
+		Store:         storeConfig{Type: "file", Path: "records"},
+		SessionTTL:    "1m",
+		MaxSessions:   100000,
+	}
+
+	var err error
+	if c.ServerSecretKey, c.ServerPublicKey, err = c.Configuration.KeyGen(); err != nil {
+		return nil, err
+	}
+
+	if c.OPRFSeed, err = c.Configuration.GenerateOPRFSeed(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// write writes the configuration to a new file, readable only by its owner since it holds the secrets.
+func (c *config) write(path string) error {
+	encoded, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(encoded, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func readConfig(path string) (*config, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &config{Configuration: opaque.DefaultConfiguration()}
+	if err = json.Unmarshal(encoded, c); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// handler returns the OPAQUE handler of the configuration, after checking its keys.
+func (c *config) handler() (*opaquehttp.Handler, error) {
+	if len(c.ServerSecretKey) == 0 || len(c.ServerPublicKey) == 0 || len(c.OPRFSeed) == 0 {
+		return nil, errMissingKeys
+	}
+
+	d, err := c.Configuration.Deserializer()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = d.DecodeAkePrivateKey(c.ServerSecretKey); err != nil {
+		return nil, fmt.Errorf("server_secret_key: %w", err)
+	}
+
+	if _, err = d.DecodeAkePublicKey(c.ServerPublicKey); err != nil {
+		return nil, fmt.Errorf("server_public_key: %w", err)
+	}
+
+	if len(c.OPRFSeed) != c.Configuration.Hash.Size() {
+		return nil, fmt.Errorf("oprf_seed: %w", opaque.ErrInvalidOPRFSeedLength)
+	}
+
+	ttl, err := time.ParseDuration(c.SessionTTL)
+	if err != nil {
+		return nil, fmt.Errorf("session_ttl: %w", err)
+	}
+
+	overflow, ok := overflowPolicies[c.SessionOverflow]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownOverflow, c.SessionOverflow)
+	}
+
+	sessions := opaquehttp.NewMemorySessionStore(ttl)
+	sessions.SetLimit(c.MaxSessions, overflow)
+
+	newStore, ok := stores[c.Store.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownStore, c.Store.Type)
+	}
+
+	credentials, err := newStore(c.Store, c.Configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	// The records flagged by opaque-migrate are re-registered on their next login.
+	rehash, _ := credentials.(opaquehttp.RehashStore)
+
+	return &opaquehttp.Handler{
+		Configuration:   c.Configuration,
+		Credentials:     credentials,
+		Sessions:        sessions,
+		Rehash:          rehash,
+		ServerIdentity:  c.ServerIdentity,
+		ServerSecretKey: c.ServerSecretKey,
+		ServerPublicKey: c.ServerPublicKey,
+		OPRFSeed:        c.OPRFSeed,
+	}, nil
+}
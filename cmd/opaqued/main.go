@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Command opaqued is a reference OPAQUE server, running the registration and login flows of the opaquehttp package
+// over HTTP, for client developers to test against and as a working example of a deployment.
+//
+// It reads its configuration from a JSON file, which -init creates with the default OPAQUE configuration and new
+// server keys:
+//
+//	opaqued -config opaqued.json -init
+//	opaqued -config opaqued.json
+//
+// It serves the opaquehttp endpoints under the configured prefix, including the configuration advertisement at
+// GET <prefix>/configuration, from which clients get their parameters. A successful login is answered with
+// {"credential_identifier", "session_key_hash"}, where the hash is the SHA-256 of the session key, so that clients can
+// check that they agree with the server. The records are kept in memory or in a directory, as set in the configuration
+// file.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	path := flag.String("config", "opaqued.json", "the configuration file")
+	initialize := flag.Bool("init", false, "write a new configuration file with new keys, and exit")
+	flag.Parse()
+
+	if err := run(*path, *initialize); err != nil {
+		fmt.Fprintln(os.Stderr, "opaqued:", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string, initialize bool) error {
+	if initialize {
+		c, err := newConfig()
+		if err != nil {
+			return err
+		}
+
+		return c.write(path)
+	}
+
+	c, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+
+	h, err := c.handler()
+	if err != nil {
+		return err
+	}
+
+	h.OnLogin = func(w http.ResponseWriter, _ *http.Request, credentialIdentifier, sessionKey []byte) {
+		hash := sha256.Sum256(sessionKey)
+		writeJSON(w, map[string][]byte{"credential_identifier": credentialIdentifier, "session_key_hash": hash[:]})
+	}
+
+	prefix := strings.TrimSuffix(c.Prefix, "/")
+	mux := http.NewServeMux()
+	h.Mount(mux, prefix)
+
+	return serve(&http.Server{Addr: c.Listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// serve runs the server until it fails, or until an interrupt or termination signal shuts it down gracefully.
+func serve(srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+
+	go func() {
+		log.Printf("opaqued: listening on %s", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdown); err != nil {
+		return err
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/message"
+)
+
+// In the three-message flow, the client is assured the server holds the session key when it verifies the server MAC
+// in KE2, but the server only learns that the client does when it verifies KE3, and the client never learns the
+// server finished the protocol. Deployments that require bilateral explicit key confirmation before any application
+// data flows can add a fourth message: the server answers KE3 with LoginFinishWithConfirmation, and the client
+// verifies the resulting KE4 with VerifyConfirmation before using the session key.
+
+// ErrAkeInvalidServerConfirmation indicates that the server's key confirmation MAC in KE4 is not valid in the session.
+var ErrAkeInvalidServerConfirmation = errors.New("failed to confirm server key: invalid confirmation mac")
+
+// LoginFinishWithConfirmation returns a KE4 key confirmation message if the KE3 received from the client holds a
+// valid mac, and an error otherwise.
+func (s *Server) LoginFinishWithConfirmation(ke3 *message.KE3) (*message.KE4, error) {
+	if err := s.LoginFinish(ke3); err != nil {
+		return nil, err
+	}
+
+	return &message.KE4{Mac: s.Ake.ConfirmationMAC(s.conf)}, nil
+}
+
+// VerifyConfirmation returns an error if the KE4 received from the server does not confirm the session key of the
+// previous successful call to LoginFinish(), and nil if correct.
+func (c *Client) VerifyConfirmation(ke4 *message.KE4) error {
+	if !c.Ake.VerifyConfirmation(c.conf, ke4.Mac) {
+		return internal.Detail(ErrAkeInvalidServerConfirmation, "confirmation MAC of %d bytes, expected %d",
+			len(ke4.Mac), c.conf.MAC.Size())
+	}
+
+	return nil
+}
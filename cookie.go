@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// A server flooded with KE1 messages from spoofed sources can first answer them with a cookie, which is cheap to issue
+// and verify, and only process the KE1 messages that come back with a valid cookie for their source, as TLS does with
+// HelloRetryRequest. The group operations then only run for the clients that completed a round trip. The cookies are
+// stateless: the server verifies them with the key it issued them with.
+
+const (
+	cookieKeyLength = 32
+	cookieDST       = "OPAQUE-Cookie"
+)
+
+var (
+	// ErrCookie indicates that a cookie is invalid, expired, or was issued to another source.
+	ErrCookie = errors.New("invalid or expired cookie")
+
+	errCookieKeyLength = errors.New("invalid cookie key length")
+)
+
+// Cookie is issued by the server to a source, to be sent back with its KE1.
+type Cookie []byte
+
+// NewCookie returns a cookie for the source, valid until expiry, authenticated under cookieKey. The source is what the
+// cookie is bound to, e.g. the client's network address. cookieKey must be a 32-byte server secret.
+func NewCookie(cookieKey, source []byte, expiry time.Time) (Cookie, error) {
+	if len(cookieKey) != cookieKeyLength {
+		return nil, errCookieKeyLength
+	}
+
+	exp := make([]byte, 8)
+	binary.BigEndian.PutUint64(exp, uint64(expiry.Unix()))
+
+	return encoding.Concat(exp, cookieMac(cookieKey, exp, source)), nil
+}
+
+func cookieMac(cookieKey, expiry, source []byte) []byte {
+	mac := hmac.New(sha256.New, cookieKey)
+	_, _ = mac.Write([]byte(cookieDST))
+	_, _ = mac.Write(expiry)
+	_, _ = mac.Write(source)
+
+	return mac.Sum(nil)
+}
+
+// VerifyCookie returns nil if the cookie was issued under cookieKey to the source, and has not expired at now, and
+// ErrCookie otherwise.
+func VerifyCookie(cookieKey []byte, cookie Cookie, source []byte, now time.Time) error {
+	if len(cookieKey) != cookieKeyLength {
+		return errCookieKeyLength
+	}
+
+	if len(cookie) != 8+sha256.Size {
+		return ErrCookie
+	}
+
+	if !internal.ConstantTimeEqual(cookieMac(cookieKey, cookie[:8], source), cookie[8:]) {
+		return ErrCookie
+	}
+
+	if now.Unix() > int64(binary.BigEndian.Uint64(cookie[:8])) {
+		return ErrCookie
+	}
+
+	return nil
+}
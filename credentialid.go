@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/message"
+)
+
+const (
+	// CredentialIdentifierLength is the length of the credential identifiers minted by NewCredentialIdentifier, and
+	// returned by AnonymizeCredentialIdentifier.
+	CredentialIdentifierLength = sha256.Size
+
+	credentialIdentifierKeyLength = 32
+	credentialIdentifierDST       = "OPAQUE-CredentialIdentifier"
+)
+
+var errCredentialIdentifierKeyLength = errors.New("invalid credential identifier key length")
+
+// NewCredentialIdentifier returns a new random credential identifier.
+func NewCredentialIdentifier() ([]byte, error) {
+	return internal.RandomBytes(CredentialIdentifierLength)
+}
+
+// MintRegistrationResponse answers the RegistrationRequest like RegistrationResponse, under a new random credential
+// identifier that it returns. The OPRF key of the client is derived from that identifier, which the client must store
+// and send to log in, so that the identifiers the server stores records under and derives OPRF keys from are not
+// guessable, e.g. from a username. The record must be stored under the returned identifier.
+func (s *Server) MintRegistrationResponse(
+	req *message.RegistrationRequest,
+	serverPublicKey *group.Point,
+	oprfSeed []byte,
+) (response *message.RegistrationResponse, credentialIdentifier []byte, err error) {
+	credentialIdentifier, err = NewCredentialIdentifier()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err = s.RegistrationResponse(req, serverPublicKey, credentialIdentifier, oprfSeed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response, credentialIdentifier, nil
+}
+
+// AnonymizeCredentialIdentifier maps the identity a client registers or logs in with, e.g. a username or an email
+// address, to a credential identifier, with HMAC-SHA256 under key. The credential identifier is then used to store the
+// record and derive the OPRF key, so that the database doesn't hold the raw identities next to the records, and they
+// can't be recovered from it without the key. key must be a 32-byte server secret, that can't be rotated without
+// re-registering all clients. The identity should be normalized beforehand, e.g. with NormalizeIdentity.
+func AnonymizeCredentialIdentifier(key, identity []byte) ([]byte, error) {
+	if len(key) != credentialIdentifierKeyLength {
+		return nil, errCredentialIdentifierKeyLength
+	}
+
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(credentialIdentifierDST))
+	_, _ = mac.Write(identity)
+
+	return mac.Sum(nil), nil
+}
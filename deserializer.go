@@ -9,39 +9,360 @@
 package opaque
 
 import (
+	"crypto/subtle"
 	"errors"
+	"fmt"
 
 	"github.com/bytemare/crypto/group"
 
 	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
 	"github.com/bytemare/opaque/message"
 )
 
 var (
-	errInvalidMessageLength = errors.New("invalid message length for the configuration")
-	errInvalidBlindedData   = errors.New("blinded data is an invalid point")
-	errInvalidClientEPK     = errors.New("invalid ephemeral client public key")
-	errInvalidEvaluatedData = errors.New("invalid OPRF evaluation")
-	errInvalidServerEPK     = errors.New("invalid ephemeral server public key")
-	errInvalidServerPK      = errors.New("invalid server public key")
-	errInvalidClientPK      = errors.New("invalid client public key")
+	errInvalidMessageLength  = errors.New("invalid message length for the configuration")
+	errMessageTooLong        = errors.New("message is longer than the accepted maximum")
+	errUnknownMessageType    = errors.New("unknown message type")
+	errInvalidKeyLength      = errors.New("invalid key length")
+	errInvalidBlindedData    = errors.New("blinded data is an invalid point")
+	errInvalidClientEPK      = errors.New("invalid ephemeral client public key")
+	errInvalidEvaluatedData  = errors.New("invalid OPRF evaluation")
+	errInvalidServerEPK      = errors.New("invalid ephemeral server public key")
+	errInvalidServerPK       = errors.New("invalid server public key")
+	errInvalidClientPK       = errors.New("invalid client public key")
+	errInvalidAuthCiphertext = errors.New("invalid authentication ciphertext")
+	errIdentityElement       = errors.New("identity element")
+	errNonCanonicalEncoding  = errors.New("non-canonical encoding")
+	errInvalidPublicKey      = errors.New("invalid public key")
+	errInvalidPrivateKey     = errors.New("invalid private key")
+	errZeroScalar            = errors.New("zero scalar")
 )
 
-// Deserializer exposes the message deserialization functions.
+// MessageType identifies a serialized protocol message for Deserializer.Check.
+type MessageType byte
+
+const (
+	// RegistrationRequestMessage identifies a serialized RegistrationRequest.
+	RegistrationRequestMessage MessageType = 1 + iota
+
+	// RegistrationResponseMessage identifies a serialized RegistrationResponse.
+	RegistrationResponseMessage
+
+	// RegistrationRecordMessage identifies a serialized RegistrationRecord.
+	RegistrationRecordMessage
+
+	// KE1Message identifies a serialized KE1.
+	KE1Message
+
+	// KE2Message identifies a serialized KE2.
+	KE2Message
+
+	// KE3Message identifies a serialized KE3.
+	KE3Message
+
+	// KE4Message identifies a serialized KE4.
+	KE4Message
+
+	// ReauthRequestMessage identifies a serialized ReauthRequest.
+	ReauthRequestMessage
+
+	// ReauthResponseMessage identifies a serialized ReauthResponse.
+	ReauthResponseMessage
+
+	// ReauthFinishMessage identifies a serialized ReauthFinish.
+	ReauthFinishMessage
+
+	// PartialEvaluationMessage identifies a serialized PartialEvaluation.
+	PartialEvaluationMessage
+)
+
+var messageNames = map[MessageType]string{
+	RegistrationRequestMessage:  "RegistrationRequest",
+	RegistrationResponseMessage: "RegistrationResponse",
+	RegistrationRecordMessage:   "RegistrationRecord",
+	KE1Message:                  "KE1",
+	KE2Message:                  "KE2",
+	KE3Message:                  "KE3",
+	KE4Message:                  "KE4",
+	ReauthRequestMessage:        "ReauthRequest",
+	ReauthResponseMessage:       "ReauthResponse",
+	ReauthFinishMessage:         "ReauthFinish",
+	PartialEvaluationMessage:    "PartialEvaluation",
+}
+
+// String returns the name of the message type.
+func (t MessageType) String() string {
+	if name, ok := messageNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("MessageType(%d)", byte(t))
+}
+
+// LengthError reports a serialized message that doesn't have the length expected in the configuration. Field names the
+// first truncated field of the message, the trailing data past its end, or its application message, with the
+// expected and received lengths of that segment. It unwraps to the generic invalid message length error.
+type LengthError struct {
+	Message  MessageType
+	Field    string
+	Expected int
+	Received int
+}
+
+// Error implements the error interface.
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("%v: %v %s: expected %d bytes, got %d",
+		errInvalidMessageLength, e.Message, e.Field, e.Expected, e.Received)
+}
+
+// Unwrap returns the generic invalid message length error.
+func (e *LengthError) Unwrap() error {
+	return errInvalidMessageLength
+}
+
+// Deserializer exposes the message deserialization functions. All of them check the length and framing of their input
+// before decoding any group element, so that oversized or garbage messages are rejected early and cheaply.
 type Deserializer struct {
-	conf *internal.Configuration
+	conf          *internal.Configuration
+	maxInfoLength int
+}
+
+func newDeserializer(conf *internal.Configuration) *Deserializer {
+	return &Deserializer{conf: conf, maxInfoLength: maxInfoLength}
+}
+
+// SetMaxInfoLength sets the maximum length of the application messages accepted in KE1 and KE2, which defaults to, and
+// can't exceed, 65535 bytes, and for KE2 the 255 blocks of the KDF that encrypt its message. A length of 0 rejects any
+// application message. Longer messages are rejected before any other processing.
+func (d *Deserializer) SetMaxInfoLength(length int) {
+	switch {
+	case length < 0:
+		length = 0
+	case length > maxInfoLength:
+		length = maxInfoLength
+	}
+
+	d.maxInfoLength = length
+}
+
+// MaxMessageLength returns the length of the longest message the Deserializer accepts, i.e. a KE1 or KE2 with an
+// application message of the maximum length, e.g. to bound the reads from the network.
+func (d *Deserializer) MaxMessageLength() int {
+	longest := 0
+
+	for _, t := range [...]MessageType{KE1Message, KE2Message} {
+		length, _ := d.messageLength(t)
+		if limit := d.infoLimit(t); limit != 0 {
+			length += 2 + limit
+		}
+
+		if length > longest {
+			longest = length
+		}
+	}
+
+	return longest
+}
+
+// infoLimit returns the maximum length of the application message of the given type.
+func (d *Deserializer) infoLimit(t MessageType) int {
+	if t == KE2Message && d.maxInfoLength > maxServerInfoLength(d.conf) {
+		return maxServerInfoLength(d.conf)
+	}
+
+	return d.maxInfoLength
+}
+
+// messageLength returns the length of the message of the given type, without the optional application message of
+// KE1 and KE2.
+func (d *Deserializer) messageLength(t MessageType) (int, error) {
+	switch t {
+	case RegistrationRequestMessage:
+		return d.conf.OPRFPointLength, nil
+	case RegistrationResponseMessage:
+		return d.registrationResponseLength(), nil
+	case RegistrationRecordMessage:
+		return d.recordLength(), nil
+	case KE1Message:
+		return d.ke1Length(), nil
+	case KE2Message:
+		return d.credentialResponseLength() + d.ke2LengthWithoutCreds(), nil
+	case KE3Message:
+		return d.authCiphertextLength() + d.conf.MAC.Size(), nil
+	case KE4Message, ReauthFinishMessage:
+		return d.conf.MAC.Size(), nil
+	case ReauthRequestMessage:
+		return d.conf.NonceLen, nil
+	case ReauthResponseMessage:
+		return d.conf.NonceLen + d.conf.MAC.Size(), nil
+	case PartialEvaluationMessage:
+		return 2 + d.conf.OPRFPointLength, nil
+	default:
+		return 0, errUnknownMessageType
+	}
+}
+
+// field is a fixed length segment of a serialized message.
+type field struct {
+	name   string
+	length int
+}
+
+// layout returns the fields of the message of the given type, without the optional application message of KE1 and
+// KE2. Fields that are absent in the configuration are omitted.
+func (d *Deserializer) layout(t MessageType) []field {
+	var fields []field
+
+	switch t {
+	case RegistrationRequestMessage:
+		fields = []field{{"blinded message", d.conf.OPRFPointLength}}
+	case RegistrationResponseMessage:
+		fields = []field{
+			{"evaluated message", d.conf.OPRFPointLength},
+			{"server public key", d.conf.AkePointLength},
+		}
+	case RegistrationRecordMessage:
+		fields = []field{
+			{"client public key", d.conf.AkePointLength},
+			{"masking key", d.conf.Hash.Size()},
+			{"envelope", d.conf.EnvelopeSize},
+		}
+	case KE1Message:
+		fields = []field{
+			{"blinded message", d.conf.OPRFPointLength},
+			{"client nonce", d.conf.NonceLen},
+			{"client ephemeral public key", d.conf.AkePointLength},
+			{"KEM public key", d.conf.KEMPublicKeyLength},
+		}
+	case KE2Message:
+		fields = []field{
+			{"evaluated message", d.conf.OPRFPointLength},
+			{"masking nonce", d.conf.NonceLen},
+			{"masked response", d.conf.AkePointLength + d.conf.EnvelopeSize},
+			{"server nonce", d.conf.NonceLen},
+			{"server ephemeral public key", d.conf.AkePointLength},
+			{"KEM ciphertext", d.conf.KEMCiphertextLength},
+			{"authentication ciphertext", d.authCiphertextLength()},
+			{"server MAC", d.conf.MAC.Size()},
+		}
+	case KE3Message:
+		fields = []field{
+			{"authentication ciphertext", d.authCiphertextLength()},
+			{"client MAC", d.conf.MAC.Size()},
+		}
+	case KE4Message:
+		fields = []field{{"server MAC", d.conf.MAC.Size()}}
+	case ReauthRequestMessage:
+		fields = []field{{"nonce", d.conf.NonceLen}}
+	case ReauthResponseMessage:
+		fields = []field{{"nonce", d.conf.NonceLen}, {"MAC", d.conf.MAC.Size()}}
+	case ReauthFinishMessage:
+		fields = []field{{"MAC", d.conf.MAC.Size()}}
+	case PartialEvaluationMessage:
+		fields = []field{{"index", 2}, {"evaluated message", d.conf.OPRFPointLength}}
+	}
+
+	present := fields[:0]
+
+	for _, f := range fields {
+		if f.length != 0 {
+			present = append(present, f)
+		}
+	}
+
+	return present
+}
+
+// lengthError returns a LengthError for the message of the given type and base length, naming its first truncated
+// field, or its trailing data if it is too long.
+func (d *Deserializer) lengthError(t MessageType, m []byte, length int) error {
+	if len(m) > length {
+		return &LengthError{Message: t, Field: "trailing data", Expected: 0, Received: len(m) - length}
+	}
+
+	offset := 0
+
+	for _, f := range d.layout(t) {
+		if offset+f.length > len(m) {
+			return &LengthError{Message: t, Field: f.name, Expected: f.length, Received: len(m) - offset}
+		}
+
+		offset += f.length
+	}
+
+	return &LengthError{Message: t, Field: "message", Expected: length, Received: len(m)}
+}
+
+// infoLengthError returns a LengthError for the malformed application message trailing a message of the given type
+// and base length.
+func infoLengthError(t MessageType, m []byte, length int) error {
+	info := m[length:]
+	expected := 2
+
+	if len(info) >= 2 {
+		expected += encoding.OS2IP(info[:2])
+	}
+
+	if expected == 2 {
+		// An empty application message must be omitted rather than encoded.
+		expected++
+	}
+
+	return &LengthError{Message: t, Field: "application message", Expected: expected, Received: len(info)}
+}
+
+// Check verifies the length and framing of the serialized message of the given type without decoding any group
+// element, so that a flood of oversized or garbage messages can be rejected before any expensive operation. A message
+// passing Check can still fail to deserialize.
+func (d *Deserializer) Check(t MessageType, m []byte) error {
+	length, err := d.messageLength(t)
+	if err != nil {
+		return err
+	}
+
+	if t == KE1Message || t == KE2Message {
+		_, _, err = d.splitInfo(t, m, length)
+		return err
+	}
+
+	if len(m) != length {
+		return d.lengthError(t, m, length)
+	}
+
+	return nil
+}
+
+// decodePoint decodes a canonically encoded element of the group, rejecting the identity element, and returns fieldErr
+// wrapping the reason otherwise.
+func decodePoint(g group.Group, encoded []byte, fieldErr error) (*group.Point, error) {
+	p, err := g.NewElement().Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", fieldErr, err)
+	}
+
+	if p.IsIdentity() {
+		return nil, fmt.Errorf("%w: %v", fieldErr, errIdentityElement)
+	}
+
+	// Some groups accept several encodings of the same element, which would make the messages malleable.
+	if subtle.ConstantTimeCompare(encoding.SerializePoint(p, g), encoded) != 1 {
+		return nil, fmt.Errorf("%w: %v", fieldErr, errNonCanonicalEncoding)
+	}
+
+	return p, nil
 }
 
 // RegistrationRequest takes a serialized RegistrationRequest message and returns a deserialized
 // RegistrationRequest structure.
 func (d *Deserializer) RegistrationRequest(registrationRequest []byte) (*message.RegistrationRequest, error) {
-	if len(registrationRequest) != d.conf.OPRFPointLength {
-		return nil, errInvalidMessageLength
+	if err := d.Check(RegistrationRequestMessage, registrationRequest); err != nil {
+		return nil, err
 	}
 
-	blindedMessage, err := d.conf.OPRF.Group().NewElement().Decode(registrationRequest[:d.conf.OPRFPointLength])
+	blindedMessage, err := decodePoint(d.conf.OPRF.Group(), registrationRequest, errInvalidBlindedData)
 	if err != nil {
-		return nil, errInvalidBlindedData
+		return nil, err
 	}
 
 	return &message.RegistrationRequest{C: d.conf.OPRF, BlindedMessage: blindedMessage}, nil
@@ -54,20 +375,22 @@ func (d *Deserializer) registrationResponseLength() int {
 // RegistrationResponse takes a serialized RegistrationResponse message and returns a deserialized
 // RegistrationResponse structure.
 func (d *Deserializer) RegistrationResponse(registrationResponse []byte) (*message.RegistrationResponse, error) {
-	if len(registrationResponse) != d.registrationResponseLength() {
-		return nil, errInvalidMessageLength
+	if err := d.Check(RegistrationResponseMessage, registrationResponse); err != nil {
+		return nil, err
 	}
 
-	evaluatedMessage, err := d.conf.OPRF.Group().
-		NewElement().
-		Decode(registrationResponse[:d.conf.OPRFPointLength])
+	evaluatedMessage, err := decodePoint(
+		d.conf.OPRF.Group(),
+		registrationResponse[:d.conf.OPRFPointLength],
+		errInvalidEvaluatedData,
+	)
 	if err != nil {
-		return nil, errInvalidEvaluatedData
+		return nil, err
 	}
 
-	pks, err := d.conf.Group.NewElement().Decode(registrationResponse[d.conf.OPRFPointLength:])
+	pks, err := decodePoint(d.conf.Group, registrationResponse[d.conf.OPRFPointLength:], errInvalidServerPK)
 	if err != nil {
-		return nil, errInvalidServerPK
+		return nil, err
 	}
 
 	return &message.RegistrationResponse{
@@ -85,17 +408,17 @@ func (d *Deserializer) recordLength() int {
 // RegistrationRecord takes a serialized RegistrationRecord message and returns a deserialized
 // RegistrationRecord structure.
 func (d *Deserializer) RegistrationRecord(record []byte) (*message.RegistrationRecord, error) {
-	if len(record) != d.recordLength() {
-		return nil, errInvalidMessageLength
+	if err := d.Check(RegistrationRecordMessage, record); err != nil {
+		return nil, err
 	}
 
 	pk := record[:d.conf.AkePointLength]
 	maskingKey := record[d.conf.AkePointLength : d.conf.AkePointLength+d.conf.Hash.Size()]
 	env := record[d.conf.AkePointLength+d.conf.Hash.Size():]
 
-	pku, err := d.conf.Group.NewElement().Decode(pk)
+	pku, err := decodePoint(d.conf.Group, pk, errInvalidClientPK)
 	if err != nil {
-		return nil, errInvalidClientPK
+		return nil, err
 	}
 
 	return &message.RegistrationRecord{
@@ -110,9 +433,9 @@ func (d *Deserializer) deserializeCredentialResponse(
 	input []byte,
 	maxResponseLength int,
 ) (*message.CredentialResponse, error) {
-	data, err := d.conf.Group.NewElement().Decode(input[:d.conf.OPRFPointLength])
+	data, err := decodePoint(d.conf.OPRF.Group(), input[:d.conf.OPRFPointLength], errInvalidEvaluatedData)
 	if err != nil {
-		return nil, errInvalidEvaluatedData
+		return nil, err
 	}
 
 	return &message.CredentialResponse{
@@ -124,25 +447,55 @@ func (d *Deserializer) deserializeCredentialResponse(
 }
 
 func (d *Deserializer) ke1Length() int {
-	return d.conf.OPRFPointLength + d.conf.NonceLen + d.conf.AkePointLength
+	return d.conf.OPRFPointLength + d.conf.NonceLen + d.conf.AkePointLength + d.conf.KEMPublicKeyLength
+}
+
+// splitInfo separates a message of the given base length from the optional application message appended to it.
+func (d *Deserializer) splitInfo(t MessageType, m []byte, length int) ([]byte, []byte, error) {
+	if len(m) == length {
+		return m, nil, nil
+	}
+
+	if len(m) < length {
+		return nil, nil, d.lengthError(t, m, length)
+	}
+
+	if limit := d.infoLimit(t); limit == 0 || len(m) > length+2+limit {
+		return nil, nil, errMessageTooLong
+	}
+
+	info, offset, err := encoding.DecodeVector(m[length:])
+	if err != nil || len(info) == 0 || length+offset != len(m) {
+		return nil, nil, infoLengthError(t, m, length)
+	}
+
+	return m[:length], info, nil
 }
 
 // KE1 takes a serialized KE1 message and returns a deserialized KE1 structure.
 func (d *Deserializer) KE1(ke1 []byte) (*message.KE1, error) {
-	if len(ke1) != d.ke1Length() {
-		return nil, errInvalidMessageLength
+	ke1, clientInfo, err := d.splitInfo(KE1Message, ke1, d.ke1Length())
+	if err != nil {
+		return nil, err
 	}
 
-	blindedMessage, err := d.conf.Group.NewElement().Decode(ke1[:d.conf.OPRFPointLength])
+	blindedMessage, err := decodePoint(d.conf.OPRF.Group(), ke1[:d.conf.OPRFPointLength], errInvalidBlindedData)
 	if err != nil {
-		return nil, errInvalidBlindedData
+		return nil, err
 	}
 
 	nonceU := ke1[d.conf.OPRFPointLength : d.conf.OPRFPointLength+d.conf.NonceLen]
 
-	epku, err := d.conf.Group.NewElement().Decode(ke1[d.conf.OPRFPointLength+d.conf.NonceLen:])
+	offset := d.conf.OPRFPointLength + d.conf.NonceLen
+
+	epku, err := decodePoint(d.conf.Group, ke1[offset:offset+d.conf.AkePointLength], errInvalidClientEPK)
 	if err != nil {
-		return nil, errInvalidClientEPK
+		return nil, err
+	}
+
+	var kemPublicKey []byte
+	if d.conf.KEMPublicKeyLength != 0 {
+		kemPublicKey = ke1[offset+d.conf.AkePointLength:]
 	}
 
 	return &message.KE1{
@@ -151,13 +504,25 @@ func (d *Deserializer) KE1(ke1 []byte) (*message.KE1, error) {
 			C:              d.conf.OPRF,
 			BlindedMessage: blindedMessage,
 		},
-		NonceU: nonceU,
-		EpkU:   epku,
+		NonceU:       nonceU,
+		EpkU:         epku,
+		KEMPublicKey: kemPublicKey,
+		ClientInfo:   clientInfo,
 	}, nil
 }
 
 func (d *Deserializer) ke2LengthWithoutCreds() int {
-	return d.conf.NonceLen + d.conf.AkePointLength + d.conf.MAC.Size()
+	return d.conf.NonceLen + d.conf.AkePointLength + d.conf.KEMCiphertextLength + d.authCiphertextLength() +
+		d.conf.MAC.Size()
+}
+
+// authCiphertextLength returns the length of the encapsulations to the long-term keys in KE2 and KE3.
+func (d *Deserializer) authCiphertextLength() int {
+	if d.conf.Protocol == internal.KEMAKE {
+		return d.conf.AkePointLength
+	}
+
+	return 0
 }
 
 func (d *Deserializer) credentialResponseLength() int {
@@ -170,8 +535,9 @@ func (d *Deserializer) KE2(ke2 []byte) (*message.KE2, error) {
 	maxResponseLength := d.credentialResponseLength()
 
 	// Verify it matches the size of a legal KE2
-	if len(ke2) != maxResponseLength+d.ke2LengthWithoutCreds() {
-		return nil, errInvalidMessageLength
+	ke2, encryptedInfo, err := d.splitInfo(KE2Message, ke2, maxResponseLength+d.ke2LengthWithoutCreds())
+	if err != nil {
+		return nil, err
 	}
 
 	cresp, err := d.deserializeCredentialResponse(ke2, maxResponseLength)
@@ -183,37 +549,203 @@ func (d *Deserializer) KE2(ke2 []byte) (*message.KE2, error) {
 	offset := maxResponseLength + d.conf.NonceLen
 	epk := ke2[offset : offset+d.conf.AkePointLength]
 	offset += d.conf.AkePointLength
+
+	var kemCiphertext []byte
+	if d.conf.KEMCiphertextLength != 0 {
+		kemCiphertext = ke2[offset : offset+d.conf.KEMCiphertextLength]
+		offset += d.conf.KEMCiphertextLength
+	}
+
+	var authCiphertext []byte
+	if length := d.authCiphertextLength(); length != 0 {
+		authCiphertext = ke2[offset : offset+length]
+		offset += length
+
+		if _, err := decodePoint(d.conf.Group, authCiphertext, errInvalidAuthCiphertext); err != nil {
+			return nil, err
+		}
+	}
+
 	mac := ke2[offset:]
 
-	epks, err := d.conf.Group.NewElement().Decode(epk)
+	epks, err := decodePoint(d.conf.Group, epk, errInvalidServerEPK)
 	if err != nil {
-		return nil, errInvalidServerEPK
+		return nil, err
 	}
 
 	return &message.KE2{
-		G:                  d.conf.Group,
-		CredentialResponse: cresp,
-		NonceS:             nonceS,
-		EpkS:               epks,
-		Mac:                mac,
+		G:                   d.conf.Group,
+		CredentialResponse:  cresp,
+		NonceS:              nonceS,
+		EpkS:                epks,
+		KEMCiphertext:       kemCiphertext,
+		AuthCiphertext:      authCiphertext,
+		Mac:                 mac,
+		EncryptedServerInfo: encryptedInfo,
 	}, nil
 }
 
 // KE3 takes a serialized KE3 message and returns a deserialized KE3 structure.
 func (d *Deserializer) KE3(ke3 []byte) (*message.KE3, error) {
-	if len(ke3) != d.conf.MAC.Size() {
-		return nil, errInvalidMessageLength
+	if err := d.Check(KE3Message, ke3); err != nil {
+		return nil, err
+	}
+
+	length := d.authCiphertextLength()
+
+	if length == 0 {
+		return &message.KE3{Mac: ke3}, nil
 	}
 
-	return &message.KE3{Mac: ke3}, nil
+	if _, err := decodePoint(d.conf.Group, ke3[:length], errInvalidAuthCiphertext); err != nil {
+		return nil, err
+	}
+
+	return &message.KE3{AuthCiphertext: ke3[:length], Mac: ke3[length:]}, nil
 }
 
-// DecodeAkePrivateKey takes a serialized private key (a scalar) and attempts to return it's decoded form.
+// KE4 takes a serialized KE4 message and returns a deserialized KE4 structure.
+func (d *Deserializer) KE4(ke4 []byte) (*message.KE4, error) {
+	if err := d.Check(KE4Message, ke4); err != nil {
+		return nil, err
+	}
+
+	return &message.KE4{Mac: ke4}, nil
+}
+
+// ReauthRequest takes a serialized ReauthRequest message and returns a deserialized ReauthRequest structure.
+func (d *Deserializer) ReauthRequest(request []byte) (*message.ReauthRequest, error) {
+	if err := d.Check(ReauthRequestMessage, request); err != nil {
+		return nil, err
+	}
+
+	return &message.ReauthRequest{Nonce: request}, nil
+}
+
+// ReauthResponse takes a serialized ReauthResponse message and returns a deserialized ReauthResponse structure.
+func (d *Deserializer) ReauthResponse(response []byte) (*message.ReauthResponse, error) {
+	if err := d.Check(ReauthResponseMessage, response); err != nil {
+		return nil, err
+	}
+
+	return &message.ReauthResponse{Nonce: response[:d.conf.NonceLen], Mac: response[d.conf.NonceLen:]}, nil
+}
+
+// ReauthFinish takes a serialized ReauthFinish message and returns a deserialized ReauthFinish structure.
+func (d *Deserializer) ReauthFinish(finish []byte) (*message.ReauthFinish, error) {
+	if err := d.Check(ReauthFinishMessage, finish); err != nil {
+		return nil, err
+	}
+
+	return &message.ReauthFinish{Mac: finish}, nil
+}
+
+// DecodeAkePrivateKey takes a serialized private key (a scalar) and attempts to return it's decoded form, rejecting
+// the zero scalar.
 func (d *Deserializer) DecodeAkePrivateKey(encoded []byte) (*group.Scalar, error) {
-	return d.conf.Group.NewScalar().Decode(encoded)
+	if len(encoded) != encoding.ScalarLength[d.conf.Group] {
+		return nil, fmt.Errorf("%w: %v", errInvalidPrivateKey, errInvalidKeyLength)
+	}
+
+	s, err := d.conf.Group.NewScalar().Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPrivateKey, err)
+	}
+
+	if s.IsZero() {
+		return nil, fmt.Errorf("%w: %v", errInvalidPrivateKey, errZeroScalar)
+	}
+
+	return s, nil
 }
 
-// DecodeAkePublicKey takes a serialized public key (a point) and attempts to return it's decoded form.
+// DecodeAkePublicKey takes a serialized public key (a point) and attempts to return it's decoded form, rejecting the
+// identity element.
 func (d *Deserializer) DecodeAkePublicKey(encoded []byte) (*group.Point, error) {
-	return d.conf.Group.NewElement().Decode(encoded)
+	if len(encoded) != d.conf.AkePointLength {
+		return nil, fmt.Errorf("%w: %v", errInvalidPublicKey, errInvalidKeyLength)
+	}
+
+	return decodePoint(d.conf.Group, encoded, errInvalidPublicKey)
+}
+
+// PartialEvaluation takes a serialized PartialEvaluation message and returns a deserialized PartialEvaluation
+// structure.
+func (d *Deserializer) PartialEvaluation(partialEvaluation []byte) (*message.PartialEvaluation, error) {
+	if err := d.Check(PartialEvaluationMessage, partialEvaluation); err != nil {
+		return nil, err
+	}
+
+	evaluation, err := decodePoint(d.conf.OPRF.Group(), partialEvaluation[2:], errInvalidEvaluatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message.PartialEvaluation{
+		C:                d.conf.OPRF,
+		Index:            uint16(encoding.OS2IP(partialEvaluation[:2])),
+		EvaluatedMessage: evaluation,
+	}, nil
+}
+
+// Message is the set of the deserialized message types, for Deserialize.
+type Message interface {
+	*message.RegistrationRequest | *message.RegistrationResponse | *message.RegistrationRecord |
+		*message.KE1 | *message.KE2 | *message.KE3 | *message.KE4 |
+		*message.ReauthRequest | *message.ReauthResponse | *message.ReauthFinish |
+		*message.PartialEvaluation
+}
+
+// Deserialize takes a serialized message and returns its deserialized structure of the given type in the
+// configuration, e.g.
+//
+//	ke1, err := opaque.Deserialize[*message.KE1](conf, serializedKE1)
+func Deserialize[T Message](c *Configuration, encoded []byte) (T, error) {
+	d, err := c.Deserializer()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return DeserializeWith[T](d, encoded)
+}
+
+// DeserializeWith is like Deserialize, but uses the given Deserializer and its limits.
+func DeserializeWith[T Message](d *Deserializer, encoded []byte) (T, error) {
+	var (
+		m   T
+		out any
+		err error
+	)
+
+	switch any(m).(type) {
+	case *message.RegistrationRequest:
+		out, err = d.RegistrationRequest(encoded)
+	case *message.RegistrationResponse:
+		out, err = d.RegistrationResponse(encoded)
+	case *message.RegistrationRecord:
+		out, err = d.RegistrationRecord(encoded)
+	case *message.KE1:
+		out, err = d.KE1(encoded)
+	case *message.KE2:
+		out, err = d.KE2(encoded)
+	case *message.KE3:
+		out, err = d.KE3(encoded)
+	case *message.KE4:
+		out, err = d.KE4(encoded)
+	case *message.ReauthRequest:
+		out, err = d.ReauthRequest(encoded)
+	case *message.ReauthResponse:
+		out, err = d.ReauthResponse(encoded)
+	case *message.ReauthFinish:
+		out, err = d.ReauthFinish(encoded)
+	case *message.PartialEvaluation:
+		out, err = d.PartialEvaluation(encoded)
+	}
+
+	if err != nil {
+		return m, err
+	}
+
+	return out.(T), nil
 }
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import "fmt"
+
+// MessageField is a labeled segment of a serialized message, as returned by Dissect.
+type MessageField struct {
+	// Name is the name of the field, e.g. "server nonce", "application message", or "trailing data".
+	Name string
+
+	// Offset and Length locate the field in the message.
+	Offset int
+	Length int
+
+	// Value is the content of the field, sharing its memory with the message.
+	Value []byte
+
+	// Secret is true for the fields derived from the secrets of the parties, i.e. the masking key, the envelope and
+	// its encryption, and the MACs, which Dump redacts.
+	Secret bool
+}
+
+// secretFields are the names of the message fields derived from the secrets of the parties.
+var secretFields = map[string]bool{
+	"masking key":               true,
+	"envelope":                  true,
+	"masked response":           true,
+	"authentication ciphertext": true,
+	"server MAC":                true,
+	"client MAC":                true,
+	"MAC":                       true,
+}
+
+// String returns the offset, length, name, and hexadecimal value of the field.
+func (f MessageField) String() string {
+	return fmt.Sprintf("%5d %5d  %-28s %x", f.Offset, f.Length, f.Name, f.Value)
+}
+
+// MessageTypes returns the types of messages the serialized message has the length and framing of in the
+// configuration, in the order of the MessageType constants, e.g. to find which message a capture holds before
+// dissecting it. Messages of different types can have the same length, and a returned type is not guaranteed to
+// deserialize.
+func (d *Deserializer) MessageTypes(m []byte) []MessageType {
+	var types []MessageType
+
+	for t := RegistrationRequestMessage; t <= PartialEvaluationMessage; t++ {
+		if d.Check(t, m) == nil {
+			types = append(types, t)
+		}
+	}
+
+	return types
+}
+
+// Dissect splits the serialized message of the given type into its fields, e.g. to debug the messages exchanged with
+// another implementation. The fields cover the whole message: a field cut short by the end of the message has the
+// remaining length, and the bytes past the last field are returned as the application message of KE1 and KE2, or as
+// trailing data. The error, if any, is that of deserializing the message, e.g. a LengthError naming the first
+// truncated field, or an invalid group element, and comes with the fields nonetheless.
+func (d *Deserializer) Dissect(t MessageType, m []byte) ([]MessageField, error) {
+	if _, err := d.messageLength(t); err != nil {
+		return nil, err
+	}
+
+	var (
+		fields []MessageField
+		offset int
+	)
+
+	for _, f := range d.layout(t) {
+		if offset == len(m) {
+			break
+		}
+
+		length := f.length
+		if offset+length > len(m) {
+			length = len(m) - offset
+		}
+
+		value := m[offset : offset+length]
+		fields = append(fields, MessageField{
+			Name:   f.name,
+			Offset: offset,
+			Length: length,
+			Value:  value,
+			Secret: secretFields[f.name],
+		})
+		offset += length
+	}
+
+	if offset < len(m) {
+		name := "trailing data"
+		if t == KE1Message || t == KE2Message {
+			name = "application message"
+		}
+
+		fields = append(fields, MessageField{Name: name, Offset: offset, Length: len(m) - offset, Value: m[offset:]})
+	}
+
+	return fields, d.deserialize(t, m)
+}
+
+// deserialize deserializes the message of the given type, and returns the error, if any.
+func (d *Deserializer) deserialize(t MessageType, m []byte) error {
+	var err error
+
+	switch t {
+	case RegistrationRequestMessage:
+		_, err = d.RegistrationRequest(m)
+	case RegistrationResponseMessage:
+		_, err = d.RegistrationResponse(m)
+	case RegistrationRecordMessage:
+		_, err = d.RegistrationRecord(m)
+	case KE1Message:
+		_, err = d.KE1(m)
+	case KE2Message:
+		_, err = d.KE2(m)
+	case KE3Message:
+		_, err = d.KE3(m)
+	case KE4Message:
+		_, err = d.KE4(m)
+	case ReauthRequestMessage:
+		_, err = d.ReauthRequest(m)
+	case ReauthResponseMessage:
+		_, err = d.ReauthResponse(m)
+	case ReauthFinishMessage:
+		_, err = d.ReauthFinish(m)
+	case PartialEvaluationMessage:
+		_, err = d.PartialEvaluation(m)
+	default:
+		err = errUnknownMessageType
+	}
+
+	return err
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/message"
+)
+
+var errNilMessage = errors.New("nil message")
+
+// Dump writes the fields of the message in the configuration to w, one per line with its offset, length, and
+// hexadecimal value, for support bundles and bug reports. The values of the fields derived from secrets, i.e. the
+// masking key, the envelope and its encryption, and the MACs, are redacted to their length and fingerprint, so that
+// the output can be shared without enabling offline attacks on the password, while equal values can still be told
+// apart. The public keys, nonces, and OPRF elements are printed. A malformed message is dumped nonetheless, followed by
+// the error of deserializing it.
+func Dump(w io.Writer, msg message.Serializer, conf *Configuration) error {
+	t, err := messageType(msg)
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.Deserializer()
+	if err != nil {
+		return err
+	}
+
+	m := msg.Serialize()
+	fields, dissectErr := d.Dissect(t, m)
+
+	if _, err = fmt.Fprintf(w, "%v (%d bytes, configuration %s)\n", t, len(m),
+		configurationLabel(conf.Fingerprint())); err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		value := fmt.Sprintf("%x", f.Value)
+		if f.Secret {
+			value = internal.Redacted(f.Value)
+		}
+
+		if _, err = fmt.Fprintf(w, "  %5d %5d  %-28s %s\n", f.Offset, f.Length, f.Name, value); err != nil {
+			return err
+		}
+	}
+
+	if dissectErr != nil {
+		_, err = fmt.Fprintf(w, "  error: %v\n", dissectErr)
+	}
+
+	return err
+}
+
+// messageType returns the type of the message, or an error if it is nil or not a protocol message.
+func messageType(msg message.Serializer) (MessageType, error) {
+	var t MessageType
+
+	switch msg.(type) {
+	case *message.RegistrationRequest:
+		t = RegistrationRequestMessage
+	case *message.RegistrationResponse:
+		t = RegistrationResponseMessage
+	case *message.RegistrationRecord:
+		t = RegistrationRecordMessage
+	case *message.KE1:
+		t = KE1Message
+	case *message.KE2:
+		t = KE2Message
+	case *message.KE3:
+		t = KE3Message
+	case *message.KE4:
+		t = KE4Message
+	case *message.ReauthRequest:
+		t = ReauthRequestMessage
+	case *message.ReauthResponse:
+		t = ReauthResponseMessage
+	case *message.ReauthFinish:
+		t = ReauthFinishMessage
+	case *message.PartialEvaluation:
+		t = PartialEvaluationMessage
+	case nil:
+		return 0, errNilMessage
+	default:
+		return 0, fmt.Errorf("%w: %T", errUnknownMessageType, msg)
+	}
+
+	if reflect.ValueOf(msg).IsNil() {
+		return 0, errNilMessage
+	}
+
+	return t, nil
+}
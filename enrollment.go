@@ -0,0 +1,319 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+const (
+	enrollmentKeyLength      = 32
+	enrollmentDeviceIDLength = 32
+	enrollmentAccountKeyInfo = "account-key"
+	enrollmentTokenDST       = "OPAQUE-EnrollmentToken"
+	enrollmentBundleDST      = "OPAQUE-EnrollmentBundle"
+)
+
+var (
+	// ErrEnrollmentToken indicates that an enrollment token is invalid or expired.
+	ErrEnrollmentToken = errors.New("invalid or expired enrollment token")
+
+	// ErrEnrollmentBundle indicates that an enrollment bundle can't be opened with the given transfer key.
+	ErrEnrollmentBundle = errors.New("invalid enrollment bundle")
+
+	// ErrDeviceExists indicates that a record with the same credential identifier is already attached to the account.
+	ErrDeviceExists = errors.New("device is already enrolled")
+
+	// ErrDeviceNotFound indicates that no record with the credential identifier is attached to the account.
+	ErrDeviceNotFound = errors.New("device not found")
+
+	// ErrAccountIdentity indicates that a record's client identity is not the identity of the account it is added to.
+	ErrAccountIdentity = errors.New("record's client identity differs from the account's")
+
+	errEnrollmentKeyLength = errors.New("invalid enrollment key length")
+	errEnrollmentLength    = errors.New("enrollment field is too long")
+)
+
+// EnrollmentToken is issued by the server to an authenticated device, allowing the enrollment of a new device on the
+// same account. It is stateless: the server verifies it with the key it was issued with, and it can be presented again
+// until it expires. Account.EnrollDevice refuses it while the device it enrolled is attached, but not once that device
+// is removed, so servers that need strict single use must keep the credential identifiers of the tokens they accepted
+// until the tokens expire.
+type EnrollmentToken []byte
+
+// NewEnrollmentToken returns a token for the account, valid until expiry, authenticated under tokenKey. The token
+// carries a fresh credential identifier for the new device's record. tokenKey must be a 32-byte server secret.
+func NewEnrollmentToken(tokenKey, accountID []byte, expiry time.Time) (EnrollmentToken, error) {
+	if len(tokenKey) != enrollmentKeyLength {
+		return nil, errEnrollmentKeyLength
+	}
+
+	if len(accountID) > maxIdentityLength {
+		return nil, errEnrollmentLength
+	}
+
+	deviceID, err := internal.RandomBytes(enrollmentDeviceIDLength)
+	if err != nil {
+		return nil, err
+	}
+
+	exp := make([]byte, 8)
+	binary.BigEndian.PutUint64(exp, uint64(expiry.Unix()))
+	body := encoding.Concatenate(encoding.EncodeVector(accountID), exp, deviceID)
+
+	return encoding.Concat(body, enrollmentTokenMac(tokenKey, body)), nil
+}
+
+func enrollmentTokenMac(tokenKey, body []byte) []byte {
+	mac := hmac.New(sha256.New, tokenKey)
+	_, _ = mac.Write([]byte(enrollmentTokenDST))
+	_, _ = mac.Write(body)
+
+	return mac.Sum(nil)
+}
+
+// VerifyEnrollmentToken verifies the token under tokenKey and returns the account identifier it was issued for and
+// the credential identifier the new device must register with.
+func VerifyEnrollmentToken(
+	tokenKey []byte,
+	token EnrollmentToken,
+	now time.Time,
+) (accountID, deviceID []byte, err error) {
+	if len(tokenKey) != enrollmentKeyLength {
+		return nil, nil, errEnrollmentKeyLength
+	}
+
+	accountID, offset, err := encoding.DecodeVector(token)
+	if err != nil || len(token) != offset+8+enrollmentDeviceIDLength+sha256.Size {
+		return nil, nil, ErrEnrollmentToken
+	}
+
+	body := token[:offset+8+enrollmentDeviceIDLength]
+	if !internal.ConstantTimeEqual(enrollmentTokenMac(tokenKey, body), token[len(body):]) {
+		return nil, nil, ErrEnrollmentToken
+	}
+
+	expiry := int64(binary.BigEndian.Uint64(token[offset : offset+8]))
+	if now.Unix() > expiry {
+		return nil, nil, ErrEnrollmentToken
+	}
+
+	return accountID, token[offset+8 : len(body)], nil
+}
+
+// EnrollmentBundle is built by an enrolled device and transferred to a new device, so that the new device can register
+// its own record on the same account without knowing, or the server learning, the account's password.
+type EnrollmentBundle struct {
+	// Token is the server-issued enrollment token, to be presented with the new device's registration.
+	Token EnrollmentToken
+
+	// DevicePassword is a high-entropy secret that the new device uses as its OPAQUE password.
+	DevicePassword []byte
+
+	// AccountKey is a key derived from the enrolling device's export key, that lets all devices of an account share
+	// the same application key material (e.g. to decrypt a common vault).
+	AccountKey []byte
+}
+
+// AccountKey returns the account-wide key derived from the export key, as shared with new devices in an
+// EnrollmentBundle.
+func AccountKey(exportKey ExportKey) ([]byte, error) {
+	return exportKey.DeriveKey(enrollmentAccountKeyInfo, nil, enrollmentKeyLength)
+}
+
+// NewEnrollmentBundle builds a bundle for the new device with the token the server issued, and the account key derived
+// from the export key of the logged-in device. A new account key is not derived if accountKey is given, so that
+// devices enrolled from an enrolled device share the same account key.
+func NewEnrollmentBundle(token EnrollmentToken, exportKey ExportKey, accountKey []byte) (*EnrollmentBundle, error) {
+	if accountKey == nil {
+		var err error
+
+		accountKey, err = AccountKey(exportKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	devicePassword, err := internal.RandomBytes(enrollmentKeyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnrollmentBundle{
+		Token:          token,
+		DevicePassword: devicePassword,
+		AccountKey:     accountKey,
+	}, nil
+}
+
+// Seal encrypts the bundle for transfer to the new device, e.g. through the server or a QR code, and returns the
+// sealed bundle and the transfer key that must be conveyed to the new device over a separate channel.
+func (b *EnrollmentBundle) Seal() (sealed, transferKey []byte, err error) {
+	for _, field := range [][]byte{b.Token, b.DevicePassword, b.AccountKey} {
+		if len(field) > maxInfoLength {
+			return nil, nil, errEnrollmentLength
+		}
+	}
+
+	transferKey, err = internal.RandomBytes(enrollmentKeyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := enrollmentAEAD(transferKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext := encoding.Concatenate(
+		encoding.EncodeVector(b.Token),
+		encoding.EncodeVector(b.DevicePassword),
+		encoding.EncodeVector(b.AccountKey),
+	)
+	nonce, err := internal.RandomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, []byte(enrollmentBundleDST)), transferKey, nil
+}
+
+// OpenEnrollmentBundle decrypts a sealed bundle with the transfer key.
+func OpenEnrollmentBundle(sealed, transferKey []byte) (*EnrollmentBundle, error) {
+	aead, err := enrollmentAEAD(transferKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrEnrollmentBundle
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(enrollmentBundleDST))
+	if err != nil {
+		return nil, ErrEnrollmentBundle
+	}
+
+	var fields [3][]byte
+
+	for i := range fields {
+		field, offset, err := encoding.DecodeVector(plaintext)
+		if err != nil {
+			return nil, ErrEnrollmentBundle
+		}
+
+		fields[i], plaintext = field, plaintext[offset:]
+	}
+
+	if len(plaintext) != 0 {
+		return nil, ErrEnrollmentBundle
+	}
+
+	return &EnrollmentBundle{
+		Token:          fields[0],
+		DevicePassword: fields[1],
+		AccountKey:     fields[2],
+	}, nil
+}
+
+func enrollmentAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != enrollmentKeyLength {
+		return nil, errEnrollmentKeyLength
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Account groups the records of all the devices enrolled on one client identity. Each device has its own record, with
+// its own credential identifier, and logs in with it independently of the others. RecoveryRecords are registered with
+// recovery codes, and PasswordResetRequired is set once one of them has been used.
+type Account struct {
+	Identity              []byte
+	Devices               []*ClientRecord
+	RecoveryRecords       []*ClientRecord
+	PasswordResetRequired bool
+}
+
+// AddDevice attaches the record to the account. It returns ErrAccountIdentity if the record's client identity is not
+// the account's identity.
+func (a *Account) AddDevice(record *ClientRecord) error {
+	if err := a.checkIdentity(record); err != nil {
+		return err
+	}
+
+	if _, err := a.Device(record.CredentialIdentifier); err == nil {
+		return ErrDeviceExists
+	}
+
+	a.Devices = append(a.Devices, record)
+
+	return nil
+}
+
+// checkIdentity returns ErrAccountIdentity if the record wasn't registered with the account's identity.
+func (a *Account) checkIdentity(record *ClientRecord) error {
+	if !internal.ConstantTimeEqual(record.ClientIdentity, a.Identity) {
+		return ErrAccountIdentity
+	}
+
+	return nil
+}
+
+// EnrollDevice verifies the enrollment token under tokenKey, and attaches the new device's record to the account if the
+// token was issued for it. The record must have been registered with the credential identifier carried by the token.
+func (a *Account) EnrollDevice(tokenKey []byte, token EnrollmentToken, record *ClientRecord, now time.Time) error {
+	accountID, deviceID, err := VerifyEnrollmentToken(tokenKey, token, now)
+	if err != nil {
+		return err
+	}
+
+	if !internal.ConstantTimeEqual(accountID, a.Identity) ||
+		!internal.ConstantTimeEqual(deviceID, record.CredentialIdentifier) {
+		return ErrEnrollmentToken
+	}
+
+	return a.AddDevice(record)
+}
+
+// Device returns the record attached to the account with the credential identifier.
+func (a *Account) Device(credentialIdentifier []byte) (*ClientRecord, error) {
+	for _, d := range a.Devices {
+		if internal.ConstantTimeEqual(d.CredentialIdentifier, credentialIdentifier) {
+			return d, nil
+		}
+	}
+
+	return nil, ErrDeviceNotFound
+}
+
+// RemoveDevice detaches the record with the credential identifier from the account.
+func (a *Account) RemoveDevice(credentialIdentifier []byte) error {
+	for i, d := range a.Devices {
+		if internal.ConstantTimeEqual(d.CredentialIdentifier, credentialIdentifier) {
+			a.Devices = append(a.Devices[:i], a.Devices[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrDeviceNotFound
+}
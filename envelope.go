@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/keyrecovery"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// ErrInvalidRandomizedPassword indicates that a randomized password doesn't have the length of the KDF output.
+var ErrInvalidRandomizedPassword = errors.New("invalid randomized password length")
+
+// The envelope functions below are the envelope construction of the registration and the login, for applications
+// that run the OPRF or store the credentials themselves, e.g. with a hardware-backed OPRF or an alternative
+// registration flow. Client.RegistrationFinalize and Client.LoginFinish use the same construction, so envelopes built
+// either way are interchangeable.
+
+// RandomizedPassword derives the randomized password from the OPRF output, hardening it with the configuration's KSF,
+// as the client does after unblinding the server's evaluation. The result is secret, and must be zeroed after use.
+func (c *Configuration) RandomizedPassword(oprfOutput []byte) ([]byte, error) {
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, err
+	}
+
+	stretched := conf.KSF.Harden(oprfOutput, nil, conf.OPRFPointLength)
+	ikm := encoding.Concat(oprfOutput, stretched)
+	randomizedPwd := conf.KDF.Extract(nil, ikm)
+
+	internal.Zero(stretched)
+	internal.Zero(ikm)
+
+	return randomizedPwd, nil
+}
+
+// BuildEnvelope seals the client's credentials under the randomized password, and returns the serialized envelope,
+// the client's public key, the masking key, and the export key, i.e. everything the client sends in the
+// RegistrationRecord, and the export key it keeps. If clientSecretKey is nil, the client's key pair is derived from
+// the randomized password in the internal mode, or randomly generated in the external mode. appData is sealed in the
+// envelope if the configuration sets an application data length. Nil identities default to the public keys.
+func (c *Configuration) BuildEnvelope(
+	randomizedPassword, serverPublicKey, clientSecretKey, appData, clientIdentity, serverIdentity []byte,
+) (envelope, clientPublicKey, maskingKey, exportKey []byte, err error) {
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if err = checkEnvelopeInputs(conf, randomizedPassword, serverPublicKey, clientIdentity, serverIdentity); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	sk, err := decodeClientSecretKey(conf, clientSecretKey)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	env, pku, exportKey, err := keyrecovery.Store(conf, randomizedPassword, serverPublicKey, sk, appData,
+		&keyrecovery.Identities{Client: clientIdentity, Server: serverIdentity}, nil)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	maskingKey = conf.KDF.Expand(randomizedPassword, []byte(tag.MaskingKey), conf.KDF.Size())
+
+	return env.Serialize(), encoding.SerializePoint(pku, conf.Group), maskingKey, exportKey, nil
+}
+
+// RecoverEnvelope opens a serialized envelope with the randomized password, and returns the client's key pair, the
+// export key, and the application data sealed in the envelope, if any. In the internal mode, the client's secret key
+// given to BuildEnvelope, if any, must be given again, as it is not stored in the envelope. It fails if the password,
+// the server public key, or the identities are not those the envelope was built with. Nil identities default to the
+// public keys.
+func (c *Configuration) RecoverEnvelope(
+	randomizedPassword, serverPublicKey, clientSecretKey, clientIdentity, serverIdentity, envelope []byte,
+) (secretKey, clientPublicKey, exportKey, appData []byte, err error) {
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if err = checkEnvelopeInputs(conf, randomizedPassword, serverPublicKey, clientIdentity, serverIdentity); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if len(envelope) != conf.EnvelopeSize {
+		return nil, nil, nil, nil, ErrInvalidEnvelopeLength
+	}
+
+	knownSecretKey, err := decodeClientSecretKey(conf, clientSecretKey)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	sk, pk, exportKey, appData, err := keyrecovery.Recover(conf, randomizedPassword, serverPublicKey,
+		&keyrecovery.Identities{Client: clientIdentity, Server: serverIdentity}, knownSecretKey,
+		keyrecovery.Deserialize(conf, envelope))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return encoding.SerializeScalar(sk, conf.Group), encoding.SerializePoint(pk, conf.Group), exportKey, appData, nil
+}
+
+// EnvelopeLength returns the length of the serialized envelopes of the configuration.
+func (c *Configuration) EnvelopeLength() (int, error) {
+	conf, err := c.toInternal()
+	if err != nil {
+		return 0, err
+	}
+
+	return conf.EnvelopeSize, nil
+}
+
+func checkEnvelopeInputs(
+	conf *internal.Configuration,
+	randomizedPassword, serverPublicKey, clientIdentity, serverIdentity []byte,
+) error {
+	if len(randomizedPassword) != conf.KDF.Size() {
+		return ErrInvalidRandomizedPassword
+	}
+
+	if _, err := newDeserializer(conf).DecodeAkePublicKey(serverPublicKey); err != nil {
+		return fmt.Errorf("%v: %w", errInvalidServerPK, err)
+	}
+
+	return checkIdentities(clientIdentity, serverIdentity)
+}
+
+// decodeClientSecretKey decodes the client's secret key, if any.
+func decodeClientSecretKey(conf *internal.Configuration, clientSecretKey []byte) (*group.Scalar, error) {
+	if clientSecretKey == nil {
+		return nil, nil
+	}
+
+	sk, err := newDeserializer(conf).DecodeAkePrivateKey(clientSecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errInvalidClientSecretKey, err)
+	}
+
+	return sk, nil
+}
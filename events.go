@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"time"
+
+	"github.com/bytemare/opaque/message"
+)
+
+// The Client and Server report the phases of the protocol to the logger set with SetLogger, with Go 1.21 or later.
+// The events are built from public values only, i.e. the name of the phase, the type and length of its message, its
+// duration, and its error, so that no secret can reach the logs.
+
+// The names of the phases in the lifecycle events.
+const (
+	phaseRegistrationInit     = "registration init"
+	phaseRegistrationResponse = "registration response"
+	phaseRegistrationFinalize = "registration finalize"
+	phaseLoginInit            = "login init"
+	phaseLoginResponse        = "login response"
+	phaseLoginFinish          = "login finish"
+	phaseLoginVerify          = "login verify"
+)
+
+// eventLogger receives the phases of the protocol, with the message the phase produced, or received if it produces
+// none, which may be a nil pointer if it failed.
+type eventLogger func(phase string, msg message.Serializer, duration time.Duration, err error)
+
+// log reports the phase started at start, if the logger is set.
+func (l eventLogger) log(phase string, start time.Time, msg message.Serializer, err error) {
+	if l != nil {
+		l(phase, msg, time.Since(start), err)
+	}
+}
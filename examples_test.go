@@ -32,7 +32,11 @@ func isSameConf(a, b *opaque.Configuration) bool {
 		a.MAC != b.MAC ||
 		a.Hash != b.Hash ||
 		a.KSF != b.KSF ||
-		a.AKE != b.AKE {
+		a.AKE != b.AKE ||
+		a.Mode != b.Mode ||
+		a.AppDataLength != b.AppDataLength ||
+		a.KEM != b.KEM ||
+		a.Protocol != b.Protocol {
 		return false
 	}
 
@@ -54,6 +58,7 @@ func Example_configuration() {
 		Hash:    crypto.SHA512,
 		KSF:     ksf.Scrypt,
 		AKE:     opaque.RistrettoSha512,
+		Mode:    opaque.Internal,
 		Context: nil,
 	}
 
@@ -92,11 +97,16 @@ func Example_serverSetup() {
 	// This a straightforward way to use a secure and efficient configuration.
 	// They have to be run only once in the application's lifecycle, and the output values must be stored appropriately.
 	conf := opaque.DefaultConfiguration()
-	secretOprfSeed = conf.GenerateOPRFSeed()
-	serverPrivateKey, serverPublicKey = conf.KeyGen()
+	var err error
 
-	if serverPrivateKey == nil || serverPublicKey == nil || secretOprfSeed == nil {
-		log.Fatalf("Oh no! Something went wrong setting up the server secrets!")
+	secretOprfSeed, err = conf.GenerateOPRFSeed()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	serverPrivateKey, serverPublicKey, err = conf.KeyGen()
+	if err != nil {
+		log.Fatalln(err)
 	}
 
 	fmt.Println("OPAQUE server values initialized.")
@@ -106,8 +116,8 @@ func Example_serverSetup() {
 
 // Example_Deserialization demonstrates a couple of ways to deserialize OPAQUE protocol messages.
 // Message interpretation depends on the configuration context it's exchanged in. Hence, we need the corresponding
-// configuration. We can then directly deserialize messages from a Configuration or pass them to Client or Server
-// instances which can do it as well.
+// configuration. We can then directly deserialize messages from a Configuration, or pass the messages a Server or
+// Client consumes to that instance, which can do it as well.
 // You must know in advance what message you are expecting, and call the appropriate deserialization function.
 func Example_deserialization() {
 	// Let's say we have this RegistrationRequest message we received on the wire.
@@ -127,7 +137,7 @@ func Example_deserialization() {
 		log.Fatalln(err)
 	}
 
-	// Or if you already have a Server instance, you can use that also.
+	// Or if you already have a Server instance, you can use that also for the messages it consumes.
 	server, err := conf.Server()
 	if err != nil {
 		log.Fatalln(err)
@@ -139,28 +149,12 @@ func Example_deserialization() {
 		log.Fatalln(err)
 	}
 
-	// Alternatively, a Client instance can do that as well.
-	client, err := conf.Client()
-	if err != nil {
-		// The error message will tell us what's wrong.
-		log.Fatalln(err)
-	}
-
-	requestC, err := client.Deserialize.RegistrationRequest(registrationMessage)
-	if err != nil {
-		// The error message will tell us what's wrong.
-		log.Fatalln(err)
-	}
-
-	// All these yield the same message. The following is just a test to proof that point.
+	// Both yield the same message. The following is just a test to proof that point.
 	{
-		if !reflect.DeepEqual(requestD, requestS) ||
-			!reflect.DeepEqual(requestD, requestC) ||
-			!reflect.DeepEqual(requestS, requestC) {
-			log.Fatalf("Unexpected divergent RegistrationMessages:\n\t- %v\n\t- %v\n\t- %v",
+		if !reflect.DeepEqual(requestD, requestS) {
+			log.Fatalf("Unexpected divergent RegistrationMessages:\n\t- %v\n\t- %v",
 				hex.EncodeToString(requestD.Serialize()),
-				hex.EncodeToString(requestS.Serialize()),
-				hex.EncodeToString(requestC.Serialize()))
+				hex.EncodeToString(requestS.Serialize()))
 		}
 
 		fmt.Println("OPAQUE messages deserialization is easy!")
@@ -220,14 +214,21 @@ func Example_registration() {
 
 		// The server creates a database entry for the client and creates a credential identifier that must absolutely
 		// be unique among all clients.
-		credID = opaque.RandomBytes(64)
+		credID, err = opaque.RandomBytes(64)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
 		pks, err := server.Deserialize.DecodeAkePublicKey(serverPublicKey)
 		if err != nil {
 			log.Fatalln(err)
 		}
 
 		// The server uses its public key and secret OPRF seed created at the setup.
-		response := server.RegistrationResponse(request, pks, credID, secretOprfSeed)
+		response, err := server.RegistrationResponse(request, pks, credID, secretOprfSeed)
+		if err != nil {
+			log.Fatalln(err)
+		}
 
 		// The server responds with its serialized response.
 		message2 = response.Serialize()
@@ -242,7 +243,10 @@ func Example_registration() {
 
 		// The client produces its record and a client-only-known secret export_key, that the client can use for other purposes (e.g. encrypt
 		// information to store on the server, and that the server can't decrypt). We don't use in the example here.
-		record, _ := client.RegistrationFinalize(response, clientID, serverID)
+		record, _, err := client.RegistrationFinalize(response, clientID, serverID)
+		if err != nil {
+			log.Fatalln(err)
+		}
 		message3 = record.Serialize()
 	}
 
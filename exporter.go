@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/ake"
+)
+
+// maxExporterLabelLength leaves room for the label prefix in the one-byte encoded label.
+const maxExporterLabelLength = 255 - len("OPAQUE-")
+
+var (
+	// errExporterLabel happens when the exporter label is empty or too long.
+	errExporterLabel = errors.New("invalid exporter label length")
+
+	// errExporterLength happens when the requested exporter output length is out of range.
+	errExporterLength = errors.New("invalid exporter output length")
+)
+
+func exporter(conf *internal.Configuration, sessionSecret []byte, label string, context []byte, length int) ([]byte,
+	error,
+) {
+	if len(label) == 0 || len(label) > maxExporterLabelLength {
+		return nil, errExporterLabel
+	}
+
+	if length <= 0 || length > 255*conf.KDF.Size() {
+		return nil, errExporterLength
+	}
+
+	return ake.Exporter(conf, sessionSecret, label, context, length), nil
+}
+
+// Exporter returns length bytes of keying material bound to the handshake, for the given label and context, like a
+// TLS exporter. Both ends of the handshake export the same values, and LoginFinish must have succeeded.
+func (c *Client) Exporter(label string, context []byte, length int) ([]byte, error) {
+	sessionSecret := c.Ake.SessionKey()
+	if len(sessionSecret) == 0 {
+		return nil, errSessionMissing
+	}
+
+	return exporter(c.conf, sessionSecret, label, context, length)
+}
+
+// Exporter returns length bytes of keying material bound to the handshake, for the given label and context, like a
+// TLS exporter. Both ends of the handshake export the same values, and LoginFinish must have succeeded.
+func (s *Server) Exporter(label string, context []byte, length int) ([]byte, error) {
+	if !s.finished {
+		return nil, errSessionMissing
+	}
+
+	return exporter(s.conf, s.Ake.SessionKey(), label, context, length)
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto"
+	"errors"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// exportKeyKDF is the fixed KDF used to derive application keys from an export key, independently of the
+// configuration's KDF, so that derived keys only depend on the export key, the label, and the context.
+const exportKeyKDF = crypto.SHA512
+
+var (
+	// errExportKeyEmpty happens when deriving from an empty export key.
+	errExportKeyEmpty = errors.New("export key is empty")
+
+	// errExportKeyEmptyLabel happens when deriving a key without a purpose label.
+	errExportKeyEmptyLabel = errors.New("export key derivation label is empty")
+
+	// errExportKeyLength happens when the requested derived key length is out of range.
+	errExportKeyLength = errors.New("invalid export key derivation length")
+)
+
+// ExportKey is the secret key derived by the client during registration and login, that is never known to the server.
+// Applications should not use it directly, but derive a separate key for each purpose with DeriveKey.
+type ExportKey []byte
+
+// DeriveKey returns a key of the given length bound to the label and the optional context, derived from the export
+// key with HKDF-Expand over SHA-512. Different labels (e.g. "vault-encryption", "token-signing") yield independent
+// keys, and the same label and context always yield the same key for a given export key.
+func (e ExportKey) DeriveKey(label string, context []byte, length int) ([]byte, error) {
+	if len(e) == 0 {
+		return nil, errExportKeyEmpty
+	}
+
+	if label == "" {
+		return nil, errExportKeyEmptyLabel
+	}
+
+	kdf := internal.NewKDF(exportKeyKDF)
+	if length <= 0 || length > 255*kdf.Size() {
+		return nil, errExportKeyLength
+	}
+
+	info := encoding.Concat3(
+		encoding.I2OSP(length, 2),
+		encoding.EncodeVector(encoding.SuffixString([]byte(tag.ExportKeyDerive), label)),
+		encoding.EncodeVector(context),
+	)
+
+	return kdf.Expand(e, info, length), nil
+}
@@ -2,13 +2,16 @@ module github.com/bytemare/opaque
 
 go 1.18
 
-require github.com/bytemare/crypto v0.2.7
+require (
+	github.com/bytemare/crypto v0.2.7
+	golang.org/x/crypto v0.0.0-20220321153916-2c7772ba3064
+	golang.org/x/sys v0.5.0
+	golang.org/x/text v0.14.0
+)
 
 require (
 	filippo.io/edwards25519 v1.0.0-rc.1 // indirect
 	github.com/armfazh/h2c-go-ref v0.0.0-20220222212046-ff45165972af // indirect
 	github.com/armfazh/tozan-ecc v0.1.4 // indirect
 	github.com/gtank/ristretto255 v0.1.2 // indirect
-	golang.org/x/crypto v0.0.0-20220321153916-2c7772ba3064 // indirect
-	golang.org/x/sys v0.0.0-20220327210214-530d0810a4d0 // indirect
 )
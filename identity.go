@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/ake"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+// Identity hiding keeps the client's identity material away from passive observers. Applications usually send the
+// client's identifier in clear alongside KE1 so that the server can look up the client record. Instead, the client can
+// seal it with SealIdentity under a key derived from its ephemeral key in KE1 and the server's long-term public key,
+// which it must then know in advance, and the server opens it with OpenIdentity before LoginInit.
+//
+// Identity material that the server only needs once the client is authenticated, e.g. a device name, can be sealed
+// with SealIdentityKE3 under a key derived from the session secret, and sent alongside KE3. The server only opens it
+// with LoginFinishWithIdentity after successfully verifying KE3, and it is bound to the handshake transcript.
+//
+// Sealed identities are padded to a multiple of identityPadding bytes to hide their exact length, and each seal uses a
+// fresh random nonce, prepended to the ciphertext, so that sealing again under the same key is safe.
+
+const (
+	identityPadding = 32
+	identityNonce   = 12
+)
+
+var (
+	// ErrIdentityHiding indicates that a sealed client identity could not be opened.
+	ErrIdentityHiding = errors.New("invalid sealed client identity")
+
+	// errSessionMissing happens when using the session before a successful LoginFinish.
+	errSessionMissing = errors.New("no session key: LoginFinish must succeed first")
+)
+
+func identityAEAD(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		// The keys are always of valid length.
+		panic(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return aead
+}
+
+func sealIdentity(key, identity, additionalData []byte) ([]byte, error) {
+	padded := encoding.EncodeVector(identity)
+	if rem := len(padded) % identityPadding; rem != 0 {
+		padded = append(padded, make([]byte, identityPadding-rem)...)
+	}
+
+	nonce, err := internal.RandomBytes(identityNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return identityAEAD(key).Seal(nonce, nonce, padded, additionalData), nil
+}
+
+func openIdentity(key, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < identityNonce {
+		return nil, ErrIdentityHiding
+	}
+
+	padded, err := identityAEAD(key).Open(nil, sealed[:identityNonce], sealed[identityNonce:], additionalData)
+	if err != nil {
+		return nil, ErrIdentityHiding
+	}
+
+	identity, _, err := encoding.DecodeVector(padded)
+	if err != nil {
+		return nil, ErrIdentityHiding
+	}
+
+	return identity, nil
+}
+
+// SealIdentity encrypts the client's identity to be sent alongside the KE1 of the previous call to LoginInit, for the
+// server with the given long-term public key.
+func (c *Client) SealIdentity(identity, serverPublicKey []byte) ([]byte, error) {
+	if len(c.Ake.Ke1) == 0 {
+		return nil, errKe1Missing
+	}
+
+	if len(identity) > maxIdentityLength {
+		return nil, errIdentityLength
+	}
+
+	pks, err := c.Deserialize.DecodeAkePublicKey(serverPublicKey)
+	if err != nil {
+		return nil, errInvalidServerPK
+	}
+
+	return sealIdentity(c.Ake.IdentityKey(c.conf, pks), identity, c.Ake.Ke1)
+}
+
+// OpenIdentity decrypts the client identity sealed with SealIdentity and sent alongside KE1, given the server's
+// long-term private key.
+func (s *Server) OpenIdentity(ke1 *message.KE1, serverSecretKey, sealedIdentity []byte) ([]byte, error) {
+	if !completeKE1(ke1) {
+		return nil, errIncompleteMessage
+	}
+
+	sks, err := s.decodeServerSecretKey(serverSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.Ake.IdentityKey(s.conf, sks, ke1.EpkU)
+
+	return openIdentity(key, sealedIdentity, ke1.Serialize())
+}
+
+// SealIdentityKE3 encrypts the client's identity material to be sent alongside the KE3 of the previous successful call
+// to LoginFinish.
+func (c *Client) SealIdentityKE3(identity []byte) ([]byte, error) {
+	sessionKey := c.SessionKey()
+	if len(sessionKey) == 0 {
+		return nil, errSessionMissing
+	}
+
+	if len(identity) > maxIdentityLength {
+		return nil, errIdentityLength
+	}
+
+	return sealIdentity(ake.SessionIdentityKey(c.conf, sessionKey), identity, c.TranscriptHash())
+}
+
+// LoginFinishWithIdentity verifies KE3 as LoginFinish does, and only then decrypts the client's identity material
+// sealed with SealIdentityKE3.
+func (s *Server) LoginFinishWithIdentity(ke3 *message.KE3, sealedIdentity []byte) ([]byte, error) {
+	if err := s.LoginFinish(ke3); err != nil {
+		return nil, err
+	}
+
+	return openIdentity(ake.SessionIdentityKey(s.conf, s.SessionKey()), sealedIdentity, s.TranscriptHash())
+}
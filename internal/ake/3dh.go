@@ -10,6 +10,9 @@
 package ake
 
 import (
+	"errors"
+	"runtime"
+
 	"github.com/bytemare/crypto/group"
 
 	"github.com/bytemare/opaque/internal"
@@ -18,35 +21,56 @@ import (
 	"github.com/bytemare/opaque/message"
 )
 
-// KeyGen returns private and public keys in the group.
-func KeyGen(id group.Group) (privateKey, publicKey []byte) {
-	scalar := id.NewScalar().Random()
+// errLabelLength happens when a KDF label or its context is longer than 255 bytes.
+var errLabelLength = errors.New("label or context is too long")
+
+// KeyGen returns private and public keys in the group, or an error wrapping internal.ErrRandom if the random number
+// generator fails.
+func KeyGen(id group.Group) (privateKey, publicKey []byte, err error) {
+	scalar, err := internal.RandomScalar(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	point := id.Base().Mult(scalar)
 
-	return encoding.SerializeScalar(scalar, id), encoding.SerializePoint(point, id)
+	return encoding.SerializeScalar(scalar, id), encoding.SerializePoint(point, id), nil
 }
 
-// setValues - testing: integrated to support testing, to force values.
-// There's no effect if esk, epk, and nonce have already been set in a previous call.
-func setValues(g group.Group, scalar *group.Scalar, nonce []byte, nonceLen int) (s *group.Scalar, n []byte) {
-	if scalar != nil {
-		s = scalar
-	} else {
-		s = g.NewScalar().Random()
+// hedgedValues returns the ephemeral private key and the nonce if they haven't been set for testing, hedged with the
+// transcript so far and the party's secrets.
+func hedgedValues(
+	conf *internal.Configuration,
+	esk *group.Scalar,
+	nonce []byte,
+	inputs [][]byte,
+) (*group.Scalar, []byte) {
+	if esk == nil {
+		esk = conf.HedgedScalar(conf.Group, tag.HedgedEphemeralKey, inputs...)
 	}
 
 	if len(nonce) == 0 {
-		nonce = internal.RandomBytes(nonceLen)
+		nonce = conf.HedgedBytes(tag.HedgedNonce, conf.NonceLen, inputs...)
 	}
 
-	return s, nonce
+	return esk, nonce
 }
 
+// buildLabel returns I2OSP(length, 2) || EncodeVectorLen(LabelPrefix || label, 1) || EncodeVectorLen(context, 1),
+// written into a single buffer.
 func buildLabel(length int, label, context []byte) []byte {
-	return encoding.Concat3(
-		encoding.I2OSP(length, 2),
-		encoding.EncodeVectorLen(append([]byte(tag.LabelPrefix), label...), 1),
-		encoding.EncodeVectorLen(context, 1))
+	labelLength := len(tag.LabelPrefix) + len(label)
+	if labelLength > 255 || len(context) > 255 {
+		panic(errLabelLength)
+	}
+
+	out := make([]byte, 0, 4+labelLength+len(context))
+	out = append(out, byte(length>>8), byte(length), byte(labelLength))
+	out = append(out, tag.LabelPrefix...)
+	out = append(out, label...)
+	out = append(out, byte(len(context)))
+
+	return append(out, context...)
 }
 
 func expand(h *internal.KDF, secret, hkdfLabel []byte) []byte {
@@ -62,26 +86,135 @@ func deriveSecret(h *internal.KDF, secret, label, context []byte) []byte {
 	return expandLabel(h, secret, label, context)
 }
 
-func initTranscript(conf *internal.Configuration, clientIdentity, serverIdentity, ke1 []byte, ke2 *message.KE2) {
-	encodedClientID := encoding.EncodeVector(clientIdentity)
-	encodedServerID := encoding.EncodeVector(serverIdentity)
-	conf.Hash.Write(encoding.Concatenate([]byte(tag.VersionTag), encoding.EncodeVector(conf.Context),
-		encodedClientID, ke1,
-		encodedServerID, ke2.CredentialResponse.Serialize(), ke2.NonceS, encoding.SerializePoint(ke2.EpkS, conf.Group)))
+// ke1Pieces returns the fields of KE1 as they are serialized on the wire, to be written into the transcript without
+// serializing the message. The credential request is absent in the standalone AKE.
+func ke1Pieces(conf *internal.Configuration, ke1 *message.KE1) [][]byte {
+	var request, info []byte
+	if ke1.CredentialRequest != nil {
+		request = ke1.CredentialRequest.Serialize()
+	}
+
+	if len(ke1.ClientInfo) != 0 {
+		info = encoding.EncodeVector(ke1.ClientInfo)
+	}
+
+	return [][]byte{
+		request, ke1.NonceU, encoding.SerializePoint(ke1.EpkU, conf.Group),
+		ke1.KEMPublicKey, info,
+	}
+}
+
+// initTranscript feeds the preamble into the running transcript hash. KE1 is given in pieces, and KE2 is written field
+// by field, so that neither the preamble nor the messages are ever concatenated in memory.
+func initTranscript(
+	conf *internal.Configuration,
+	clientIdentity, serverIdentity []byte,
+	ke1 [][]byte,
+	ke2 *message.KE2,
+) {
+	var binding, commitment []byte
+	if len(conf.ChannelBinding) != 0 {
+		binding = encoding.Concat([]byte(tag.ChannelBinding), encoding.EncodeVector(conf.ChannelBinding))
+	}
+
+	if len(conf.Commitment) != 0 {
+		commitment = encoding.Concat([]byte(tag.ConfigurationCommitment), encoding.EncodeVector(conf.Commitment))
+	}
+
+	for _, piece := range [...][]byte{
+		[]byte(conf.VersionTag()), encoding.EncodeVector(conf.Context), commitment, binding,
+		encoding.EncodeVector(clientIdentity),
+	} {
+		conf.Hash.Write(piece)
+	}
+
+	for _, piece := range ke1 {
+		conf.Hash.Write(piece)
+	}
+
+	conf.Hash.Write(encoding.EncodeVector(serverIdentity))
+
+	// The credential response is absent in the standalone AKE.
+	if ke2.CredentialResponse != nil {
+		conf.Hash.Write(conf.OPRF.SerializePoint(ke2.EvaluatedMessage))
+		conf.Hash.Write(ke2.MaskingNonce)
+		conf.Hash.Write(ke2.MaskedResponse)
+	}
+
+	for _, piece := range [...][]byte{
+		ke2.NonceS, encoding.SerializePoint(ke2.EpkS, conf.Group),
+		ke2.KEMCiphertext, ke2.AuthCiphertext,
+	} {
+		conf.Hash.Write(piece)
+	}
 }
 
-func deriveKeys(h *internal.KDF, ikm, context []byte) (serverMacKey, clientMacKey, sessionSecret []byte) {
+func deriveKeys(
+	conf *internal.Configuration,
+	ikm, context []byte,
+) (serverMacKey, clientMacKey, sessionSecret, encKey []byte) {
+	h := conf.KDF
 	prk := h.Extract(nil, ikm)
 	handshakeSecret := deriveSecret(h, prk, []byte(tag.Handshake), context)
 	sessionSecret = deriveSecret(h, prk, []byte(tag.SessionKey), context)
 	serverMacKey = expandLabel(h, handshakeSecret, []byte(tag.MacServer), nil)
 	clientMacKey = expandLabel(h, handshakeSecret, []byte(tag.MacClient), nil)
+	encKey = expandLabel(h, handshakeSecret, []byte(tag.HandshakeEncryption), nil)
 
-	return serverMacKey, clientMacKey, sessionSecret
+	conf.Trace(internal.TraceHandshakeSecret, handshakeSecret)
+	conf.Trace(internal.TraceServerMacKey, serverMacKey)
+	conf.Trace(internal.TraceClientMacKey, clientMacKey)
+
+	internal.Zero(prk)
+	internal.Zero(handshakeSecret)
+
+	return serverMacKey, clientMacKey, sessionSecret, encKey
+}
+
+// xorInfo encrypts or decrypts the server's application message with a pad derived from the handshake encryption key.
+func xorInfo(h *internal.KDF, encKey, info []byte) []byte {
+	pad := h.Expand(encKey, []byte(tag.EncryptionPadInfo), len(info))
+	defer internal.Zero(pad)
+
+	out := make([]byte, len(info))
+
+	for i, b := range info {
+		out[i] = b ^ pad[i]
+	}
+
+	return out
+}
+
+// blindStaticKey returns p*r and sk/r for a random r, whose product is the same as that of p and sk, so that the
+// multiplication of the blinded point with the blinded key never handles the long-term private key directly.
+func blindStaticKey(
+	conf *internal.Configuration,
+	p *group.Point,
+	sk *group.Scalar,
+	inputs [][]byte,
+) (*group.Point, *group.Scalar) {
+	r := conf.HedgedScalar(conf.Group, tag.HedgedStaticKeyBlind, inputs...)
+	return p.Mult(r), r.Copy().Invert().Mult(sk)
+}
+
+// dhWorkers bounds the number of goroutines running parallel 3DH operations, across all sessions.
+var dhWorkers = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// dh sends the encoding of p*s on out, on a worker if one is free, or on the calling goroutine otherwise.
+func dh(g group.Group, p *group.Point, s *group.Scalar, out chan<- []byte) {
+	select {
+	case dhWorkers <- struct{}{}:
+		go func() {
+			out <- encoding.SerializePoint(p.Mult(s), g)
+			<-dhWorkers
+		}()
+	default:
+		out <- encoding.SerializePoint(p.Mult(s), g)
+	}
 }
 
 func k3dh(
-	g group.Group,
+	conf *internal.Configuration,
 	p1 *group.Point,
 	s1 *group.Scalar,
 	p2 *group.Point,
@@ -89,25 +222,65 @@ func k3dh(
 	p3 *group.Point,
 	s3 *group.Scalar,
 ) []byte {
-	e1 := encoding.SerializePoint(p1.Mult(s1), g)
-	e2 := encoding.SerializePoint(p2.Mult(s2), g)
+	g := conf.Group
+
+	if !conf.ParallelDH {
+		e1 := encoding.SerializePoint(p1.Mult(s1), g)
+		e2 := encoding.SerializePoint(p2.Mult(s2), g)
+		e3 := encoding.SerializePoint(p3.Mult(s3), g)
+
+		return encoding.Concat3(e1, e2, e3)
+	}
+
+	// The first two operations are offloaded, and the third runs meanwhile on the calling goroutine.
+	c1, c2 := make(chan []byte, 1), make(chan []byte, 1)
+	dh(g, p1, s1, c1)
+	dh(g, p2, s2, c2)
 	e3 := encoding.SerializePoint(p3.Mult(s3), g)
 
-	return encoding.Concat3(e1, e2, e3)
+	return encoding.Concat3(<-c1, <-c2, e3)
 }
 
+// core3DH runs the key schedule. If serverInfo is not nil, it is encrypted into ke2, otherwise the returned info is
+// the decryption of the server's application message in ke2, if any. The returned transcript is the hash of the
+// whole transcript, up to the server MAC. The ikm and the intermediate keys are zeroed once used, but for the client
+// MAC key, which the caller gives to clientMac.
 func core3DH(
 	conf *internal.Configuration,
-	ikm, clientIdentity, serverIdentity, ke1 []byte,
+	ikm, clientIdentity, serverIdentity []byte,
+	ke1 [][]byte,
 	ke2 *message.KE2,
-) (sessionSecret, macS, macC []byte) {
+	serverInfo []byte,
+) (sessionSecret, macS, clientMacKey, info, transcript []byte) {
 	initTranscript(conf, clientIdentity, serverIdentity, ke1, ke2)
 
-	serverMacKey, clientMacKey, sessionSecret := deriveKeys(conf.KDF, ikm, conf.Hash.Sum()) // preamble
-	serverMac := conf.MAC.MAC(serverMacKey, conf.Hash.Sum())                                // transcript2
+	serverMacKey, clientMacKey, sessionSecret, encKey := deriveKeys(conf, ikm, conf.Hash.Sum()) // preamble
+
+	switch {
+	case len(serverInfo) != 0:
+		ke2.EncryptedServerInfo = xorInfo(conf.KDF, encKey, serverInfo)
+		info = serverInfo
+	case len(ke2.EncryptedServerInfo) != 0:
+		info = xorInfo(conf.KDF, encKey, ke2.EncryptedServerInfo)
+	}
+
+	if len(ke2.EncryptedServerInfo) != 0 {
+		conf.Hash.Write(encoding.EncodeVector(ke2.EncryptedServerInfo))
+	}
+
+	serverMac := conf.MAC.MAC(serverMacKey, conf.Hash.Sum()) // transcript2
 	conf.Hash.Write(serverMac)
-	transcript3 := conf.Hash.Sum()
-	clientMac := conf.MAC.MAC(clientMacKey, transcript3)
+	transcript = conf.Hash.Sum()
+
+	for _, secret := range [][]byte{ikm, serverMacKey, encKey} {
+		internal.Zero(secret)
+	}
+
+	return sessionSecret, serverMac, clientMacKey, info, transcript
+}
 
-	return sessionSecret, serverMac, clientMac
+// clientMac returns the client MAC over the transcript hash, followed in the KEM-based AKE by the client's
+// encapsulation to the server's long-term key in KE3.
+func clientMac(conf *internal.Configuration, clientMacKey, transcript, authCiphertext []byte) []byte {
+	return conf.MAC.MAC(clientMacKey, encoding.Concat(transcript, authCiphertext))
 }
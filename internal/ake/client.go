@@ -14,7 +14,10 @@ import (
 	"github.com/bytemare/crypto/group"
 
 	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
 	"github.com/bytemare/opaque/message"
+	"github.com/bytemare/opaque/securemem"
 )
 
 var errAkeInvalidServerMac = errors.New(" AKE finalization: invalid server mac")
@@ -22,8 +25,14 @@ var errAkeInvalidServerMac = errors.New(" AKE finalization: invalid server mac")
 // Client exposes the client's AKE functions and holds its state.
 type Client struct {
 	esk           *group.Scalar
+	epk           *group.Point
 	Ke1           []byte
 	sessionSecret []byte
+	locked        *securemem.Buffer
+	clientMac     []byte
+	transcript    []byte
+	kemSeed       []byte
+	serverInfo    []byte
 	nonceU        []byte // testing: integrated to support testing, to force values.
 }
 
@@ -33,29 +42,40 @@ func NewClient() *Client {
 }
 
 // SetValues - testing: integrated to support testing, to force values.
-// There's no effect if esk, epk, and nonce have already been set in a previous call.
-func (c *Client) SetValues(g group.Group, esk *group.Scalar, nonce []byte, nonceLen int) *group.Point {
-	s, nonce := setValues(g, esk, nonce, nonceLen)
-	if c.esk == nil || (esk != nil && c.esk != s) {
-		c.esk = s
+// There's no effect if the nonce has already been set in a previous call. The values left nil are hedged.
+func (c *Client) SetValues(g group.Group, esk *group.Scalar, nonce []byte) *group.Point {
+	if esk != nil {
+		c.esk = esk
 	}
 
-	if c.nonceU == nil {
+	if c.nonceU == nil && len(nonce) != 0 {
 		c.nonceU = nonce
 	}
 
+	if c.esk == nil {
+		return nil
+	}
+
 	return g.Base().Mult(c.esk)
 }
 
-// Start initiates the 3DH protocol, and returns a KE1 message with clientInfo.
-func (c *Client) Start(cs group.Group) *message.KE1 {
-	epk := c.SetValues(cs, nil, nil, 32)
-
-	return &message.KE1{
-		G:      cs,
+// Start initiates the 3DH protocol, and returns a KE1 message with clientInfo. In the hybrid AKE, KE1 also holds a
+// fresh KEM public key. The ephemeral key and nonce are hedged with the transcript, i.e. the rest of KE1.
+func (c *Client) Start(conf *internal.Configuration, transcript ...[]byte) *message.KE1 {
+	c.esk, c.nonceU = hedgedValues(conf, c.esk, c.nonceU, transcript)
+	c.epk = conf.BaseMult(c.esk)
+	ke1 := &message.KE1{
+		G:      conf.Group,
 		NonceU: c.nonceU,
-		EpkU:   epk,
+		EpkU:   c.epk,
 	}
+
+	if conf.KEM != internal.NoKEM {
+		c.kemSeed = conf.HedgedBytes(tag.HedgedKEMSeed, kemSeedLength, transcript...)
+		ke1.KEMPublicKey = kemKeyGen(conf.KEM, c.kemSeed)
+	}
+
+	return ke1
 }
 
 // Finalize verifies and responds to KE3. If the handshake is successful, the session key is stored and this functions
@@ -68,19 +88,95 @@ func (c *Client) Finalize(
 	serverPublicKey *group.Point,
 	ke2 *message.KE2,
 ) (*message.KE3, error) {
-	ikm := k3dh(conf.Group, ke2.EpkS, c.esk, serverPublicKey, c.esk, ke2.EpkS, clientSecretKey)
-	sessionSecret, serverMac, clientMac := core3DH(conf, ikm, clientIdentity, serverIdentity, c.Ke1, ke2)
+	var ikm []byte
+
+	switch conf.Protocol {
+	case internal.KEMAKE:
+		authCiphertext, err := decodeAuthCiphertext(conf, ke2.AuthCiphertext)
+		if err != nil {
+			return nil, err
+		}
+
+		ikm = encoding.Concat(decapsulate(conf, c.esk, ke2.EpkS), decapsulate(conf, clientSecretKey, authCiphertext))
+	case internal.HMQV:
+		d, e := hmqvExponents(conf, clientIdentity, serverIdentity, c.epk, ke2.EpkS)
+		ikm = hmqv(conf, c.esk, clientSecretKey, d, ke2.EpkS, serverPublicKey, e)
+	default:
+		ikm = k3dh(conf, ke2.EpkS, c.esk, serverPublicKey, c.esk, ke2.EpkS, clientSecretKey)
+	}
+
+	if conf.KEM != internal.NoKEM {
+		sharedSecret, err := kemDecapsulate(conf.KEM, c.kemSeed, ke2.KEMCiphertext)
+		if err != nil {
+			return nil, err
+		}
+
+		ikm = encoding.Concat(ikm, sharedSecret)
+		internal.Zero(sharedSecret)
+	}
+
+	sessionSecret, serverMac, clientMacKey, info, transcript := core3DH(conf, ikm, clientIdentity, serverIdentity,
+		[][]byte{c.Ke1}, ke2, nil)
+	defer internal.Zero(clientMacKey)
 
 	if !conf.MAC.Equal(serverMac, ke2.Mac) {
-		return nil, errAkeInvalidServerMac
+		return nil, internal.Detail(errAkeInvalidServerMac, "server MAC of %d bytes, expected %d, transcript hash %x",
+			len(ke2.Mac), len(serverMac), transcript)
+	}
+
+	ke3 := &message.KE3{}
+
+	// In the KEM-based AKE, the client MAC also covers the encapsulation, so that it can't be replaced in transit.
+	if conf.Protocol == internal.KEMAKE {
+		sk := encoding.SerializeScalar(clientSecretKey, conf.Group)
+		r := conf.HedgedScalar(conf.Group, tag.HedgedEncapsulation, transcript, sk)
+		internal.Zero(sk)
+
+		sharedSecret, ciphertext := encapsulate(conf, r, serverPublicKey)
+		ke3.AuthCiphertext = encoding.SerializePoint(ciphertext, conf.Group)
+		sessionSecret = kemSessionSecret(conf, sessionSecret, sharedSecret, ke3.AuthCiphertext)
+	}
+
+	ke3.Mac = clientMac(conf, clientMacKey, transcript, ke3.AuthCiphertext)
+
+	c.sessionSecret, c.locked = conf.Lock(sessionSecret)
+	c.clientMac = ke3.Mac
+	c.serverInfo = info
+	c.transcript = transcript
+
+	return ke3, nil
+}
+
+// Wipe zeroes the client's secret state and drops its references. The scalars can't be zeroed in place, and are only
+// dropped.
+func (c *Client) Wipe() {
+	for _, secret := range [][]byte{c.sessionSecret, c.clientMac, c.kemSeed, c.serverInfo} {
+		internal.Zero(secret)
 	}
 
-	c.sessionSecret = sessionSecret
+	if c.locked != nil {
+		_ = c.locked.Destroy()
+	}
+
+	*c = Client{}
+}
 
-	return &message.KE3{Mac: clientMac}, nil
+// EphemeralPublicKey returns the client's ephemeral public key if a previous call to Start() was made.
+func (c *Client) EphemeralPublicKey() *group.Point {
+	return c.epk
 }
 
 // SessionKey returns the secret shared session key if a previous call to Finalize() was successful.
 func (c *Client) SessionKey() []byte {
 	return c.sessionSecret
 }
+
+// TranscriptHash returns the hash of the handshake transcript if a previous call to Finalize() was successful.
+func (c *Client) TranscriptHash() []byte {
+	return c.transcript
+}
+
+// ServerInfo returns the decrypted application message of the server if a previous call to Finalize() was successful.
+func (c *Client) ServerInfo() []byte {
+	return c.serverInfo
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package ake
+
+import (
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// confirmationMac authenticates the final transcript, which is committed to by the client MAC, under a key derived
+// from the final session secret, so that it also covers the KEM-based AKE's encapsulation in KE3.
+func confirmationMac(conf *internal.Configuration, sessionSecret, clientMac []byte) []byte {
+	key := conf.KDF.Expand(sessionSecret, []byte(tag.KeyConfirmation), conf.KDF.Size())
+	defer internal.Zero(key)
+
+	return conf.MAC.MAC(key, clientMac)
+}
+
+// ConfirmationMAC returns the server's key confirmation MAC, to be sent after a successful call to Finalize().
+func (s *Server) ConfirmationMAC(conf *internal.Configuration) []byte {
+	return confirmationMac(conf, s.sessionSecret, s.clientMac)
+}
+
+// VerifyConfirmation returns whether the server's key confirmation MAC matches the session of the previous successful
+// call to Finalize().
+func (c *Client) VerifyConfirmation(conf *internal.Configuration, mac []byte) bool {
+	if len(c.sessionSecret) == 0 {
+		return false
+	}
+
+	return conf.MAC.Equal(confirmationMac(conf, c.sessionSecret, c.clientMac), mac)
+}
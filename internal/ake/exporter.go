@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package ake
+
+import (
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// Exporter derives keying material for an external protocol from the session secret, like the TLS 1.3 exporter of
+// RFC 8446: a secret is derived for the label from the exporter secret, and expanded with the context.
+func Exporter(conf *internal.Configuration, sessionSecret []byte, label string, context []byte, length int) []byte {
+	exporterSecret := deriveSecret(conf.KDF, sessionSecret, []byte(tag.ExporterSecret), nil)
+	secret := deriveSecret(conf.KDF, exporterSecret, []byte(label), nil)
+
+	internal.Zero(exporterSecret)
+	defer internal.Zero(secret)
+
+	return conf.KDF.Expand(
+		secret,
+		buildLabel(length, []byte(tag.Exporter), conf.KDF.Extract(nil, context)),
+		length,
+	)
+}
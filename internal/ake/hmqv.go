@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package ake
+
+import (
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// HMQV replaces the three Diffie-Hellman values of 3DH with a single one, computed by each side with one
+// (multi-)scalar multiplication less:
+//
+//	client: (Y + e*B) * (x + d*a)
+//	server: (X + d*A) * (y + e*b)
+//
+// where a, A (resp. b, B) are the client's (resp. server's) long-term keys, x, X and y, Y their ephemeral keys, and
+// d and e are derived from the ephemeral keys and the peer's identity. The result is fed into the same key schedule
+// as 3DH.
+
+// hmqvExponents returns d and e, binding the client's ephemeral key to the server's identity and the server's
+// ephemeral key to the client's identity.
+func hmqvExponents(
+	conf *internal.Configuration,
+	clientIdentity, serverIdentity []byte,
+	epkU, epkS *group.Point,
+) (d, e *group.Scalar) {
+	d = conf.Group.HashToScalar(
+		encoding.Concat(encoding.SerializePoint(epkU, conf.Group), encoding.EncodeVector(serverIdentity)),
+		encoding.Concat([]byte(tag.HMQVClient), conf.Context),
+	)
+	e = conf.Group.HashToScalar(
+		encoding.Concat(encoding.SerializePoint(epkS, conf.Group), encoding.EncodeVector(clientIdentity)),
+		encoding.Concat([]byte(tag.HMQVServer), conf.Context),
+	)
+
+	return d, e
+}
+
+// hmqv computes the shared HMQV value, given the own ephemeral and long-term secrets and exponent, and the peer's
+// ephemeral and long-term public keys and exponent.
+func hmqv(
+	conf *internal.Configuration,
+	esk, sk, ownExponent *group.Scalar,
+	peerEpk, peerPk *group.Point,
+	peerExponent *group.Scalar,
+) []byte {
+	base := peerEpk.Add(peerPk.Mult(peerExponent))
+	exponent := esk.Add(ownExponent.Mult(sk))
+
+	return encoding.SerializePoint(base.Mult(exponent), conf.Group)
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package ake
+
+import (
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// identityKeyLength is the length of the keys sealing the client identity.
+const identityKeyLength = 32
+
+func identityKey(conf *internal.Configuration, dh, epkU, serverPublicKey *group.Point) []byte {
+	ikm := encoding.Concat3(
+		encoding.SerializePoint(dh, conf.Group),
+		encoding.SerializePoint(epkU, conf.Group),
+		encoding.SerializePoint(serverPublicKey, conf.Group),
+	)
+
+	return conf.KDF.Expand(conf.KDF.Extract(nil, ikm), []byte(tag.IdentityHidingKE1), identityKeyLength)
+}
+
+// IdentityKey returns the key sealing the client identity sent with KE1, derived from the client's ephemeral key of
+// the previous call to Start() and the server's long-term public key.
+func (c *Client) IdentityKey(conf *internal.Configuration, serverPublicKey *group.Point) []byte {
+	return identityKey(conf, serverPublicKey.Mult(c.esk), c.epk, serverPublicKey)
+}
+
+// IdentityKey returns the key sealing the client identity sent with KE1, derived from the server's long-term private
+// key and the client's ephemeral public key.
+func (s *Server) IdentityKey(conf *internal.Configuration, serverSecretKey *group.Scalar, epkU *group.Point) []byte {
+	return identityKey(conf, epkU.Mult(serverSecretKey), epkU, conf.BaseMult(serverSecretKey))
+}
+
+// SessionIdentityKey returns the key sealing the client identity sent with KE3, derived from the session secret.
+func SessionIdentityKey(conf *internal.Configuration, sessionSecret []byte) []byte {
+	return conf.KDF.Expand(sessionSecret, []byte(tag.IdentityHidingKE3), identityKeyLength)
+}
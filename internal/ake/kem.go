@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package ake
+
+import (
+	"errors"
+
+	"github.com/bytemare/opaque/internal"
+)
+
+const (
+	mlkem768PublicKeyLength  = 1184
+	mlkem768CiphertextLength = 1088
+)
+
+var (
+	errKEMUnavailable   = errors.New("KEM is not available in this build")
+	errKEMEncapsulation = errors.New("hybrid AKE: invalid KEM public key")
+	errKEMDecapsulation = errors.New("hybrid AKE: invalid KEM ciphertext")
+)
+
+// KEMLengths returns the length of the KEM public key in KE1 and of the KEM ciphertext in KE2, which are 0 for NoKEM.
+func KEMLengths(kem internal.KEM) (publicKeyLength, ciphertextLength int) {
+	switch kem {
+	case internal.MLKEM768:
+		return mlkem768PublicKeyLength, mlkem768CiphertextLength
+	default:
+		return 0, 0
+	}
+}
+
+// KEMAvailable returns whether the KEM is supported in this build.
+func KEMAvailable(kem internal.KEM) bool {
+	return kem == internal.NoKEM || mlkemAvailable(kem)
+}
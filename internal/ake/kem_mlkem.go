@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build go1.24
+
+package ake
+
+import (
+	"crypto/mlkem"
+	"fmt"
+
+	"github.com/bytemare/opaque/internal"
+)
+
+func mlkemAvailable(kem internal.KEM) bool {
+	return kem == internal.MLKEM768
+}
+
+// kemSeedLength is the length of the seed of a decapsulation key.
+const kemSeedLength = mlkem.SeedSize
+
+// kemKeyGen returns the encoding of the encapsulation key of the decapsulation key with the given seed. The
+// configuration only enables available KEMs and the seed has the right length, so it never returns nil.
+func kemKeyGen(kem internal.KEM, seed []byte) (publicKey []byte) {
+	if kem != internal.MLKEM768 {
+		return nil
+	}
+
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil
+	}
+
+	return dk.EncapsulationKey().Bytes()
+}
+
+// kemEncapsulate returns a shared secret and its encapsulation to the given public key.
+func kemEncapsulate(kem internal.KEM, publicKey []byte) (sharedSecret, ciphertext []byte, err error) {
+	if kem != internal.MLKEM768 {
+		return nil, nil, errKEMUnavailable
+	}
+
+	ek, err := mlkem.NewEncapsulationKey768(publicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errKEMEncapsulation, err)
+	}
+
+	sharedSecret, ciphertext = ek.Encapsulate()
+
+	return sharedSecret, ciphertext, nil
+}
+
+// kemDecapsulate returns the shared secret encapsulated in ciphertext to the decapsulation key of the given seed.
+func kemDecapsulate(kem internal.KEM, seed, ciphertext []byte) ([]byte, error) {
+	if kem != internal.MLKEM768 {
+		return nil, errKEMUnavailable
+	}
+
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errKEMDecapsulation, err)
+	}
+
+	sharedSecret, err := dk.Decapsulate(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errKEMDecapsulation, err)
+	}
+
+	return sharedSecret, nil
+}
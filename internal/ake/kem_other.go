@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build !go1.24
+
+package ake
+
+import (
+	"github.com/bytemare/opaque/internal"
+)
+
+// ML-KEM is provided by the standard library from Go 1.24 on.
+
+func mlkemAvailable(_ internal.KEM) bool {
+	return false
+}
+
+// kemSeedLength is the length of the seed of a decapsulation key.
+const kemSeedLength = 64
+
+func kemKeyGen(_ internal.KEM, _ []byte) (publicKey []byte) {
+	return nil
+}
+
+func kemEncapsulate(_ internal.KEM, _ []byte) (sharedSecret, ciphertext []byte, err error) {
+	return nil, nil, errKEMUnavailable
+}
+
+func kemDecapsulate(_ internal.KEM, _, _ []byte) ([]byte, error) {
+	return nil, errKEMUnavailable
+}
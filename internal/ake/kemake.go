@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package ake
+
+import (
+	"errors"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// In the KEM-based AKE, the parties never combine Diffie-Hellman values directly, but only encapsulate to and
+// decapsulate from each other's keys:
+//   - the server encapsulates to the client's ephemeral key (the ciphertext is KE2's ephemeral key) and to the client's
+//     long-term key, and both shared secrets key the MACs and the handshake secret,
+//   - the client encapsulates to the server's long-term key in KE3, and that shared secret is mixed into the session
+//     key, so that only the holder of the server's private key can compute it. The client MAC covers the ciphertext,
+//     which can't be replaced in transit.
+//
+// The long-term keys of OPAQUE are elements of the AKE group, so the KEM is instantiated as DHKEM over that group.
+
+var errInvalidAuthCiphertext = errors.New("KEM-based AKE: invalid authentication ciphertext")
+
+// kemSharedSecret derives the DHKEM shared secret from the Diffie-Hellman value, the ciphertext, and the recipient's
+// public key.
+func kemSharedSecret(conf *internal.Configuration, dh, ciphertext, publicKey *group.Point) []byte {
+	ikm := encoding.Concat3(
+		encoding.SerializePoint(dh, conf.Group),
+		encoding.SerializePoint(ciphertext, conf.Group),
+		encoding.SerializePoint(publicKey, conf.Group),
+	)
+
+	return conf.KDF.Expand(conf.KDF.Extract(nil, ikm), []byte(tag.DHKEM), conf.KDF.Size())
+}
+
+// encapsulate returns a shared secret and its encapsulation to the public key, using the ephemeral secret.
+func encapsulate(
+	conf *internal.Configuration,
+	ephemeral *group.Scalar,
+	publicKey *group.Point,
+) (sharedSecret []byte, ciphertext *group.Point) {
+	ciphertext = conf.BaseMult(ephemeral)
+	return kemSharedSecret(conf, publicKey.Mult(ephemeral), ciphertext, publicKey), ciphertext
+}
+
+// decapsulate returns the shared secret encapsulated in ciphertext to the private key.
+func decapsulate(conf *internal.Configuration, secretKey *group.Scalar, ciphertext *group.Point) []byte {
+	return kemSharedSecret(conf, ciphertext.Mult(secretKey), ciphertext, conf.BaseMult(secretKey))
+}
+
+// decodeAuthCiphertext decodes an encapsulation to a long-term key.
+func decodeAuthCiphertext(conf *internal.Configuration, ciphertext []byte) (*group.Point, error) {
+	if len(ciphertext) != conf.AkePointLength {
+		return nil, errInvalidAuthCiphertext
+	}
+
+	p, err := conf.Group.NewElement().Decode(ciphertext)
+	if err != nil {
+		return nil, errInvalidAuthCiphertext
+	}
+
+	return p, nil
+}
+
+// kemSessionSecret mixes the shared secret encapsulated to the server's long-term key into the session secret.
+func kemSessionSecret(conf *internal.Configuration, handshakeSessionSecret, sharedSecret, ciphertext []byte) []byte {
+	prk := conf.KDF.Extract(handshakeSessionSecret, sharedSecret)
+	defer internal.Zero(prk)
+
+	// The handshake session secret is superseded.
+	internal.Zero(handshakeSessionSecret)
+	internal.Zero(sharedSecret)
+
+	return deriveSecret(conf.KDF, prk, []byte(tag.SessionKey), ciphertext)
+}
@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package ake
+
+import (
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// ReauthMacs returns the server and client MACs of a re-authentication with the given nonces, under a key derived from
+// the re-authentication secret. As in the login flow, the client MAC also covers the server MAC.
+func ReauthMacs(conf *internal.Configuration, secret, clientNonce, serverNonce []byte) (serverMac, clientMac []byte) {
+	key := conf.KDF.Expand(secret, []byte(tag.ReauthMacKey), conf.KDF.Size())
+	defer internal.Zero(key)
+
+	transcript := encoding.Concat3([]byte(tag.ReauthTranscript), clientNonce, serverNonce)
+	serverMac = conf.MAC.MAC(key, transcript)
+	clientMac = conf.MAC.MAC(key, encoding.Concat(transcript, serverMac))
+
+	return serverMac, clientMac
+}
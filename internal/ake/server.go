@@ -14,7 +14,10 @@ import (
 	"github.com/bytemare/crypto/group"
 
 	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
 	"github.com/bytemare/opaque/message"
+	"github.com/bytemare/opaque/securemem"
 )
 
 var errStateNotEmpty = errors.New("existing state is not empty")
@@ -23,6 +26,14 @@ var errStateNotEmpty = errors.New("existing state is not empty")
 type Server struct {
 	clientMac     []byte
 	sessionSecret []byte
+	locked        *securemem.Buffer
+	transcript    []byte
+
+	// clientMacKey is kept in the KEM-based AKE, where the client MAC covers the encapsulation in KE3.
+	clientMacKey []byte
+
+	// serverSecretKey is kept for decapsulation in KE3 in the KEM-based AKE.
+	serverSecretKey *group.Scalar
 
 	// testing: integrated to support testing, to force values.
 	esk    *group.Scalar
@@ -35,21 +46,26 @@ func NewServer() *Server {
 }
 
 // SetValues - testing: integrated to support testing, to force values.
-// There's no effect if esk, epk, and nonce have already been set in a previous call.
-func (s *Server) SetValues(g group.Group, esk *group.Scalar, nonce []byte, nonceLen int) *group.Point {
-	es, nonce := setValues(g, esk, nonce, nonceLen)
-	if s.esk == nil || (esk != nil && s.esk != es) {
-		s.esk = es
+// There's no effect if the nonce has already been set in a previous call. The values left nil are hedged.
+func (s *Server) SetValues(g group.Group, esk *group.Scalar, nonce []byte) *group.Point {
+	if esk != nil {
+		s.esk = esk
 	}
 
-	if s.nonceS == nil {
+	if s.nonceS == nil && len(nonce) != 0 {
 		s.nonceS = nonce
 	}
 
+	if s.esk == nil {
+		return nil
+	}
+
 	return g.Base().Mult(s.esk)
 }
 
-// Response produces a 3DH server response message.
+// Response produces a 3DH server response message. In the hybrid AKE, the server also encapsulates a shared secret
+// to the client's KEM public key, and both secrets are fed into the key schedule. The optional serverInfo is
+// encrypted in KE2 under the handshake keys.
 func (s *Server) Response(
 	conf *internal.Configuration,
 	serverIdentity []byte,
@@ -58,8 +74,21 @@ func (s *Server) Response(
 	clientPublicKey *group.Point,
 	ke1 *message.KE1,
 	response *message.CredentialResponse,
-) *message.KE2 {
-	epk := s.SetValues(conf.Group, nil, nil, conf.NonceLen)
+	serverInfo []byte,
+) (*message.KE2, error) {
+	// The ephemeral values are hedged with KE1, the credential response, and the server's private key. The credential
+	// response is nil in the standalone AKE.
+	sk := encoding.SerializeScalar(serverSecretKey, conf.Group)
+	pieces := ke1Pieces(conf, ke1)
+
+	var serializedResponse []byte
+	if response != nil {
+		serializedResponse = response.Serialize()
+	}
+
+	hedge := append(pieces[:len(pieces):len(pieces)], serializedResponse, sk)
+	s.esk, s.nonceS = hedgedValues(conf, s.esk, s.nonceS, hedge)
+	epk := conf.BaseMult(s.esk)
 
 	ke2 := &message.KE2{
 		G:                  conf.Group,
@@ -68,18 +97,96 @@ func (s *Server) Response(
 		EpkS:               epk,
 	}
 
-	ikm := k3dh(conf.Group, ke1.EpkU, s.esk, ke1.EpkU, serverSecretKey, clientPublicKey, s.esk)
-	sessionSecret, serverMac, clientMac := core3DH(conf, ikm, clientIdentity, serverIdentity, ke1.Serialize(), ke2)
-	s.sessionSecret = sessionSecret
-	s.clientMac = clientMac
+	var ikm []byte
+
+	switch conf.Protocol {
+	case internal.KEMAKE:
+		// The server's ephemeral public key is the encapsulation to the client's ephemeral public key.
+		ephemeralSecret, _ := encapsulate(conf, s.esk, ke1.EpkU)
+		r := conf.HedgedScalar(conf.Group, tag.HedgedEncapsulation, hedge...)
+		authSecret, authCiphertext := encapsulate(conf, r, clientPublicKey)
+		ke2.AuthCiphertext = encoding.SerializePoint(authCiphertext, conf.Group)
+		s.serverSecretKey = serverSecretKey
+		ikm = encoding.Concat(ephemeralSecret, authSecret)
+
+		internal.Zero(ephemeralSecret)
+		internal.Zero(authSecret)
+	case internal.HMQV:
+		d, e := hmqvExponents(conf, clientIdentity, serverIdentity, ke1.EpkU, epk)
+		ikm = hmqv(conf, s.esk, serverSecretKey, e, ke1.EpkU, clientPublicKey, d)
+	default:
+		p, sks := ke1.EpkU, serverSecretKey
+		if conf.BlindStaticKey {
+			p, sks = blindStaticKey(conf, p, sks, hedge)
+		}
+
+		ikm = k3dh(conf, ke1.EpkU, s.esk, p, sks, clientPublicKey, s.esk)
+	}
+
+	internal.Zero(sk)
+
+	if conf.KEM != internal.NoKEM {
+		sharedSecret, ciphertext, err := kemEncapsulate(conf.KEM, ke1.KEMPublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		ke2.KEMCiphertext = ciphertext
+		ikm = encoding.Concat(ikm, sharedSecret)
+		internal.Zero(sharedSecret)
+	}
+
+	sessionSecret, serverMac, clientMacKey, _, transcript := core3DH(conf, ikm, clientIdentity, serverIdentity,
+		pieces, ke2, serverInfo)
+	s.sessionSecret, s.locked = conf.Lock(sessionSecret)
+	s.transcript = transcript
+
+	if conf.Protocol == internal.KEMAKE {
+		s.clientMacKey = clientMacKey
+	} else {
+		s.clientMac = clientMac(conf, clientMacKey, transcript, nil)
+		internal.Zero(clientMacKey)
+	}
 	ke2.Mac = serverMac
 
-	return ke2
+	return ke2, nil
 }
 
-// Finalize verifies the authentication tag contained in ke3.
+// Finalize verifies the authentication tag contained in ke3. In the KEM-based AKE, the tag also covers the client's
+// encapsulation to the server's long-term key, which is then decapsulated to update the session key accordingly.
 func (s *Server) Finalize(conf *internal.Configuration, ke3 *message.KE3) bool {
-	return conf.MAC.Equal(s.clientMac, ke3.Mac)
+	if conf.Protocol != internal.KEMAKE {
+		return conf.MAC.Equal(s.clientMac, ke3.Mac)
+	}
+
+	if s.serverSecretKey == nil || len(s.clientMacKey) == 0 {
+		return false
+	}
+
+	expected := clientMac(conf, s.clientMacKey, s.transcript, ke3.AuthCiphertext)
+	if !conf.MAC.Equal(expected, ke3.Mac) {
+		return false
+	}
+
+	authCiphertext, err := decodeAuthCiphertext(conf, ke3.AuthCiphertext)
+	if err != nil {
+		return false
+	}
+
+	s.clientMac = expected
+
+	sharedSecret := decapsulate(conf, s.serverSecretKey, authCiphertext)
+	sessionSecret := kemSessionSecret(conf, s.sessionSecret, sharedSecret, ke3.AuthCiphertext)
+	s.destroyLocked()
+	s.sessionSecret, s.locked = conf.Lock(sessionSecret)
+
+	return true
+}
+
+// SetServerSecretKey sets the server's long-term private key, needed to finalize the KEM-based AKE after restoring
+// the state with SetState.
+func (s *Server) SetServerSecretKey(serverSecretKey *group.Scalar) {
+	s.serverSecretKey = serverSecretKey
 }
 
 // SessionKey returns the secret shared session key if a previous call to Response() was successful.
@@ -87,13 +194,24 @@ func (s *Server) SessionKey() []byte {
 	return s.sessionSecret
 }
 
-// ExpectedMAC returns the expected client MAC if a previous call to Response() was successful.
+// TranscriptHash returns the hash of the handshake transcript if a previous call to Response() was successful.
+func (s *Server) TranscriptHash() []byte {
+	return s.transcript
+}
+
+// ExpectedMAC returns the expected client MAC if a previous call to Response() was successful. In the KEM-based AKE,
+// the MAC covers KE3, and is only known after a successful call to Finalize().
 func (s *Server) ExpectedMAC() []byte {
 	return s.clientMac
 }
 
-// SerializeState will return a []byte containing internal state of the Server.
+// SerializeState will return a []byte containing internal state of the Server. In the KEM-based AKE, the state holds
+// the client MAC key and the transcript hash instead of the client MAC.
 func (s *Server) SerializeState() []byte {
+	if len(s.clientMacKey) != 0 {
+		return encoding.Concatenate(s.clientMacKey, s.transcript, s.sessionSecret)
+	}
+
 	state := make([]byte, len(s.clientMac)+len(s.sessionSecret))
 
 	i := copy(state, s.clientMac)
@@ -113,3 +231,34 @@ func (s *Server) SetState(clientMac, sessionSecret []byte) error {
 
 	return nil
 }
+
+// SetKEMState will set the given client MAC key, transcript hash, and sessionSecret in the server's internal state, in
+// the KEM-based AKE.
+func (s *Server) SetKEMState(clientMacKey, transcript, sessionSecret []byte) error {
+	if len(s.clientMacKey) != 0 || len(s.sessionSecret) != 0 {
+		return errStateNotEmpty
+	}
+
+	s.clientMacKey = clientMacKey
+	s.transcript = transcript
+	s.sessionSecret = sessionSecret
+
+	return nil
+}
+
+// Wipe zeroes the server's secret state and drops its references, so that it can be used for a new session. The
+// scalars can't be zeroed in place, and are only dropped.
+func (s *Server) Wipe() {
+	internal.Zero(s.clientMac)
+	internal.Zero(s.clientMacKey)
+	internal.Zero(s.sessionSecret)
+	s.destroyLocked()
+	*s = Server{}
+}
+
+func (s *Server) destroyLocked() {
+	if s.locked != nil {
+		_ = s.locked.Destroy()
+		s.locked = nil
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package internal
+
+import (
+	"sync"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// windowBits is the width of the digits of a scalar in fixed-base multiplication.
+const windowBits = 4
+
+// BaseTable holds multiples of a group's base point for fixed-base scalar multiplication: the scalar is split into
+// 4-bit digits, and the product is the sum of one precomputed point per digit, instead of a full double-and-add over
+// all its bits. This mostly helps the NIST curves, whose generic backend is slow, at the cost of a table of
+// 15 points per digit computed once per group, e.g. about 2000 points for P-521.
+//
+// The lookups depend on the digits of the scalar, so, like the generic backend, this isn't protected against cache
+// timing attacks.
+type BaseTable struct {
+	// windows[i][d] is d * 16^i * G.
+	windows   [][1 << windowBits]*group.Point
+	g         group.Group
+	bigEndian bool
+}
+
+var (
+	baseTables   = map[group.Group]*BaseTable{}
+	baseTablesMu sync.Mutex
+)
+
+// GetBaseTable returns the base table of the group, computing it on first use.
+func GetBaseTable(g group.Group) *BaseTable {
+	baseTablesMu.Lock()
+	defer baseTablesMu.Unlock()
+
+	t, ok := baseTables[g]
+	if !ok {
+		t = newBaseTable(g)
+		baseTables[g] = t
+	}
+
+	return t
+}
+
+func newBaseTable(g group.Group) *BaseTable {
+	t := &BaseTable{
+		windows:   make([][1 << windowBits]*group.Point, 8/windowBits*encoding.ScalarLength[g]),
+		g:         g,
+		bigEndian: g == group.P256Sha256 || g == group.P384Sha384 || g == group.P521Sha512,
+	}
+
+	base := g.Base()
+	identity := base.Sub(base)
+
+	for i := range t.windows {
+		t.windows[i][0] = identity
+		for d := 1; d < 1<<windowBits; d++ {
+			t.windows[i][d] = t.windows[i][d-1].Add(base)
+		}
+
+		base = t.windows[i][1<<windowBits-1].Add(base)
+	}
+
+	return t
+}
+
+// Mult returns the product of the base point with the scalar.
+func (t *BaseTable) Mult(s *group.Scalar) *group.Point {
+	b := encoding.SerializeScalar(s, t.g)
+	p := t.windows[0][0]
+
+	for i := range b {
+		// Process the bytes from the least significant one.
+		v := b[i]
+		if t.bigEndian {
+			v = b[len(b)-1-i]
+		}
+
+		p = p.Add(t.windows[2*i][v&0x0f]).Add(t.windows[2*i+1][v>>windowBits])
+	}
+
+	return p
+}
+
+// BaseMult returns the product of the group's base point with the scalar, using the precomputed table if enabled.
+func (c *Configuration) BaseMult(s *group.Scalar) *group.Point {
+	if c.BaseTable != nil {
+		return c.BaseTable.Mult(s)
+	}
+
+	return c.Group.Base().Mult(s)
+}
@@ -11,12 +11,16 @@ package internal
 
 import (
 	cryptorand "crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/bytemare/crypto/group"
 
 	"github.com/bytemare/opaque/internal/oprf"
+	"github.com/bytemare/opaque/internal/tag"
+	"github.com/bytemare/opaque/securemem"
 )
 
 const (
@@ -30,28 +34,218 @@ const (
 // ErrConfigurationInvalidLength happens when deserializing a configuration of invalid length.
 var ErrConfigurationInvalidLength = errors.New("invalid encoded configuration length")
 
+// ErrRandom indicates a failure of the random number generator.
+var ErrRandom = errors.New("random number generator failure")
+
+// EnvelopeMode identifies how the client's key pair is handled in the envelope.
+type EnvelopeMode byte
+
+const (
+	// Internal is the envelope mode where the client's key pair is derived from the randomized password.
+	Internal EnvelopeMode = iota
+
+	// External is the envelope mode where the client's private key is encrypted in the envelope.
+	External
+)
+
+// AEAD identifies the AEAD sealing the envelope, if any.
+type AEAD byte
+
+const (
+	// NoAEAD seals the envelope with pads derived by the KDF, and authenticates it with the MAC.
+	NoAEAD AEAD = iota
+
+	// AES256GCM seals the envelope with AES-256-GCM.
+	AES256GCM
+
+	// ChaCha20Poly1305 seals the envelope with ChaCha20-Poly1305.
+	ChaCha20Poly1305
+)
+
+// KEM identifies the key encapsulation mechanism combined with 3DH in the hybrid AKE.
+type KEM byte
+
+const (
+	// NoKEM uses 3DH alone.
+	NoKEM KEM = iota
+
+	// MLKEM768 combines 3DH with ML-KEM-768.
+	MLKEM768
+)
+
+// Protocol identifies the AKE protocol.
+type Protocol byte
+
+const (
+	// TripleDH is the 3DH AKE.
+	TripleDH Protocol = iota
+
+	// KEMAKE is the AKE using key encapsulation against the long-term and ephemeral keys.
+	KEMAKE
+
+	// HMQV is the HMQV AKE.
+	HMQV
+)
+
+// Compatibility identifies the labels and parameters the protocol runs with, to interoperate with other
+// implementations.
+type Compatibility byte
+
+const (
+	// Draft runs the protocol as in the draft.
+	Draft Compatibility = iota
+
+	// OpaqueKE runs the protocol with the labels of RFC 9807 and RFC 9497, as the opaque-ke Rust crate does.
+	OpaqueKE
+
+	// LibOpaque runs the protocol with the labels of RFC 9807 and RFC 9497, as libopaque does.
+	LibOpaque
+)
+
 // Configuration is the internal representation of the instance runtime parameters.
 type Configuration struct {
-	KDF             *KDF
-	MAC             *Mac
-	Hash            *Hash
-	KSF             *KSF
-	NonceLen        int
-	EnvelopeSize    int
-	OPRFPointLength int
-	AkePointLength  int
-	Group           group.Group
-	OPRF            oprf.Ciphersuite
-	Context         []byte
-}
-
-// RandomBytes returns random bytes of length len (wrapper for crypto/rand).
-func RandomBytes(length int) []byte {
+	KDF                 *KDF
+	MAC                 *Mac
+	Hash                *Hash
+	KSF                 *KSF
+	NonceLen            int
+	EnvelopeSize        int
+	AppDataLength       int
+	OPRFPointLength     int
+	AkePointLength      int
+	KEMPublicKeyLength  int
+	KEMCiphertextLength int
+	Group               group.Group
+	OPRF                oprf.Ciphersuite
+	Mode                EnvelopeMode
+	AEAD                AEAD
+	KEM                 KEM
+	Protocol            Protocol
+	Compatibility       Compatibility
+	Context             []byte
+	ChannelBinding      []byte
+
+	// Commitment is the fingerprint of the serialized configuration bound into the AKE transcript, if set.
+	Commitment []byte
+
+	// BaseTable speeds up fixed-base scalar multiplications if set.
+	BaseTable *BaseTable
+
+	// ParallelDH runs the 3DH operations concurrently if set.
+	ParallelDH bool
+
+	// BlindStaticKey blinds the server's long-term private key in its 3DH operation if set.
+	BlindStaticKey bool
+
+	// LockedMemory holds the session and export keys in locked memory if set.
+	LockedMemory bool
+
+	// Standalone runs the AKE alone, without the OPRF and the credentials, with its own transcript prefix.
+	Standalone bool
+
+	// HedgeKey is the per-instance secret mixed into the hedged nonces and ephemeral scalars.
+	HedgeKey []byte
+
+	// Tracer, if set, is called with the intermediate values of the protocol as they are derived, to generate test
+	// vectors. The values may be zeroed after the call, and must be copied to be kept.
+	Tracer func(name string, value []byte)
+
+	// hedgeCounter keeps the hedged values unique if the random number generator fails.
+	hedgeCounter uint64
+}
+
+// The names of the intermediate values given to the Tracer, as in the test vectors.
+const (
+	TraceOPRFKey            = "oprf_key"
+	TraceRandomizedPassword = "randomized_pwd"
+	TraceAuthKey            = "auth_key"
+	TraceHandshakeSecret    = "handshake_secret"
+	TraceServerMacKey       = "server_mac_key"
+	TraceClientMacKey       = "client_mac_key"
+)
+
+// Trace gives the intermediate value to the Tracer, if any.
+func (c *Configuration) Trace(name string, value []byte) {
+	if c.Tracer != nil {
+		c.Tracer(name, value)
+	}
+}
+
+// VersionTag returns the protocol identifier prefixing the AKE transcript.
+func (c *Configuration) VersionTag() string {
+	if c.Standalone {
+		return tag.VersionTagStandalone
+	}
+
+	if c.Compatibility != Draft {
+		return tag.VersionTagV1
+	}
+
+	return tag.VersionTag
+}
+
+// DerivePrivateKey derives the client's private key from the seed in the AKE group.
+func (c *Configuration) DerivePrivateKey(seed []byte) (*group.Scalar, error) {
+	if c.Compatibility != Draft {
+		return oprf.Ciphersuite(c.Group).RFC9497().DeriveKey(seed, []byte(tag.DeriveDiffieHellmanKeyPair))
+	}
+
+	return oprf.Ciphersuite(c.Group).DeriveKey(seed, []byte(tag.DerivePrivateKey))
+}
+
+// ConstantTimeEqual returns whether a and b are equal, in time that only depends on their lengths.
+func ConstantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// Lock moves secret into locked memory if LockedMemory is set, zeroing secret, and returns the locked copy and its
+// buffer. It returns secret and a nil buffer if LockedMemory is not set or if the memory can't be locked, e.g. on an
+// unsupported platform or above the process' limit, so that the protocol doesn't fail on it.
+func (c *Configuration) Lock(secret []byte) ([]byte, *securemem.Buffer) {
+	if !c.LockedMemory || len(secret) == 0 {
+		return secret, nil
+	}
+
+	b, err := securemem.Copy(secret)
+	if err != nil {
+		return secret, nil
+	}
+
+	return b.Bytes(), b
+}
+
+// RandomBytes returns random bytes of length len (wrapper for crypto/rand), or an error wrapping ErrRandom if the
+// random number generator fails.
+func RandomBytes(length int) ([]byte, error) {
 	r := make([]byte, length)
-	if _, err := cryptorand.Read(r); err != nil {
-		// We can as well not panic and try again in a loop and a counter to stop.
-		panic(fmt.Errorf("unexpected error in generating random bytes : %w", err))
+	if _, err := io.ReadFull(cryptorand.Reader, r); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRandom, err)
 	}
 
-	return r
+	return r, nil
+}
+
+// RandomScalar returns a random non-zero scalar in g, or an error wrapping ErrRandom if the random number generator
+// fails.
+func RandomScalar(g group.Group) (*group.Scalar, error) {
+	for {
+		seed, err := RandomBytes(2 * SeedLength)
+		if err != nil {
+			return nil, err
+		}
+
+		s := g.HashToScalar(seed, []byte(tag.RandomScalar))
+		Zero(seed)
+
+		if !s.IsZero() {
+			return s, nil
+		}
+	}
+}
+
+// Zero overwrites the given byte slice with zeros.
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
 }
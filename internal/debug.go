@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build debug
+
+package internal
+
+import "fmt"
+
+// With the debug build tag, the errors of failed verifications carry internal detail, e.g. which MAC failed, the
+// received and expected lengths, and the transcript hash, to debug interoperability issues. This detail can help an
+// attacker, and this build must not be deployed.
+
+// Debug is true in the builds with the debug tag.
+const Debug = true
+
+// Detail returns err annotated with the detail formatted from format and args, which it wraps.
+func Detail(err error, format string, args ...any) error {
+	return fmt.Errorf("%w [debug: %s]", err, fmt.Sprintf(format, args...))
+}
@@ -11,93 +11,117 @@ package internal
 import (
 	"crypto"
 	"crypto/hmac"
+	"errors"
 
-	"github.com/bytemare/crypto/hash"
 	"github.com/bytemare/crypto/ksf"
 )
 
-// NewKDF returns a newly instantiated KDF.
-func NewKDF(id crypto.Hash) *KDF {
-	return &KDF{h: hash.FromCrypto(id).Get()}
+// errExpandLength happens when more than 255 blocks of output are requested from Expand.
+var errExpandLength = errors.New("requested KDF output length is too large")
+
+// HashAvailable returns whether the hash function can back the KDF, MAC, and Hash, i.e. whether it is one of the
+// SHA-2 or SHA-3 functions and linked into the binary. The opaque and tripledh packages check their configurations
+// against it rather than against a given crypto library, so that the backend is chosen in this package only.
+func HashAvailable(id crypto.Hash) bool {
+	switch id {
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512, crypto.SHA3_256, crypto.SHA3_384, crypto.SHA3_512:
+		return id.Available()
+	default:
+		return false
+	}
 }
 
-// KDF wraps a hash function and exposes KDF methods.
-type KDF struct {
-	h *hash.Hash
+// Expand exposes an Expand only KDF method. It implements HKDF-Expand of RFC 5869 with a single HMAC instance and
+// writes the blocks directly into the output, as it runs many times per login.
+func (k *KDF) Expand(key, info []byte, length int) []byte {
+	return k.ExpandInto(nil, key, info, length)
 }
 
-// Extract exposes an Extract only KDF method.
-func (k *KDF) Extract(salt, ikm []byte) []byte {
-	return k.h.HKDFExtract(ikm, salt)
-}
+// ExpandInto is like Expand, but appends the output to dst and returns the extended slice. It doesn't allocate the
+// output if dst has room for length bytes plus one hash block, which are used as scratch space for the last block.
+func (k *KDF) ExpandInto(dst, key, info []byte, length int) []byte {
+	if length == 0 {
+		length = k.Size()
+	}
 
-// Expand exposes an Expand only KDF method.
-func (k *KDF) Expand(key, info []byte, length int) []byte {
-	return k.h.HKDFExpand(key, info, length)
-}
+	mac := hmac.New(k.newHash, key)
+	base := len(dst)
+	out := dst
 
-// Size returns the output size of the Extract method.
-func (k *KDF) Size() int {
-	return k.h.OutputSize()
-}
+	if cap(out)-base < length+mac.Size() {
+		out = make([]byte, base, base+length+mac.Size())
+		copy(out, dst)
+	}
 
-// NewMac returns a newly instantiated Mac.
-func NewMac(id crypto.Hash) *Mac {
-	return &Mac{h: hash.FromCrypto(id).Get()}
-}
+	counter := [1]byte{}
 
-// Mac wraps a hash function and exposes Message Authentication Code methods.
-type Mac struct {
-	h *hash.Hash
-}
+	var prev []byte
 
-// Equal returns a constant-time comparison of the input.
-func (m *Mac) Equal(a, b []byte) bool {
-	return hmac.Equal(a, b)
-}
+	for len(out)-base < length {
+		counter[0]++
+		if counter[0] == 0 {
+			panic(errExpandLength)
+		}
 
-// MAC computes a MAC over the message using key.
-func (m *Mac) MAC(key, message []byte) []byte {
-	return m.h.Hmac(message, key)
-}
+		if prev != nil {
+			mac.Reset()
+		}
 
-// Size returns the MAC's output length.
-func (m *Mac) Size() int {
-	return m.h.OutputSize()
-}
+		_, _ = mac.Write(prev)
+		_, _ = mac.Write(info)
+		_, _ = mac.Write(counter[:])
 
-// NewHash returns a newly instantiated Hash.
-func NewHash(id crypto.Hash) *Hash {
-	return &Hash{h: hash.FromCrypto(id).Get()}
-}
+		start := len(out)
+		out = mac.Sum(out)
+		prev = out[start:]
+	}
 
-// Hash wraps a hash function and exposes only necessary hashing methods.
-type Hash struct {
-	h *hash.Hash
+	return out[: base+length : base+length]
 }
 
-// Size returns the output size of the hashing function.
-func (h *Hash) Size() int {
-	return h.h.OutputSize()
+// Equal returns a constant-time comparison of the input.
+func (m *Mac) Equal(a, b []byte) bool {
+	return ConstantTimeEqual(a, b)
 }
 
-// Sum returns the current hash of the running state.
-func (h *Hash) Sum() []byte {
-	return h.h.Sum(nil)
-}
+// NewKSF returns a newly instantiated KSF, with the given parameters replacing the defaults, if any.
+func NewKSF(id ksf.Identifier, parameters ...int) *KSF {
+	if id == 0 {
+		return &KSF{&IdentityKSF{}}
+	}
+
+	k := id.Get()
+	if len(parameters) != 0 {
+		k.Parameterize(parameters...)
+	}
 
-// Write adds input to the running state.
-func (h *Hash) Write(p []byte) {
-	_, _ = h.h.Write(p)
+	return &KSF{k}
 }
 
-// NewKSF returns a newly instantiated KSF.
-func NewKSF(id ksf.Identifier) *KSF {
+// zeroSaltLength is the length of the all-zero salt RFC 9807 implementations harden the OPRF output with.
+const zeroSaltLength = 16
+
+// NewZeroSaltKSF returns the KSF as opaque-ke and libopaque run it, with the given parameters, an all-zero salt, and
+// an output as long as the input. The identifier must be 0, for the identity, or one taking the parameters.
+func NewZeroSaltKSF(id ksf.Identifier, parameters ...int) *KSF {
 	if id == 0 {
 		return &KSF{&IdentityKSF{}}
 	}
 
-	return &KSF{id.Get()}
+	k := id.Get()
+	k.Parameterize(parameters...)
+
+	return &KSF{zeroSaltKSF{k}}
+}
+
+// zeroSaltKSF ignores the salt and length it's given.
+type zeroSaltKSF struct {
+	ksfInterface
+}
+
+// Harden stretches the password with an all-zero salt into an output of the same length.
+func (z zeroSaltKSF) Harden(password, _ []byte, _ int) []byte {
+	return z.ksfInterface.Harden(password, make([]byte, zeroSaltLength), len(password))
 }
 
 // KSF wraps a key stretching function and exposes its functions.
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build !stdhash
+
+package internal
+
+import (
+	"crypto"
+	stdhash "hash"
+
+	"github.com/bytemare/crypto/hash"
+)
+
+// NewKDF returns a newly instantiated KDF.
+func NewKDF(id crypto.Hash) *KDF {
+	return &KDF{h: hash.FromCrypto(id).Get(), newHash: id.New}
+}
+
+// KDF wraps a hash function and exposes KDF methods.
+type KDF struct {
+	h       *hash.Hash
+	newHash func() stdhash.Hash
+}
+
+// Extract exposes an Extract only KDF method.
+func (k *KDF) Extract(salt, ikm []byte) []byte {
+	return k.h.HKDFExtract(ikm, salt)
+}
+
+// Size returns the output size of the Extract method.
+func (k *KDF) Size() int {
+	return k.h.OutputSize()
+}
+
+// NewMac returns a newly instantiated Mac.
+func NewMac(id crypto.Hash) *Mac {
+	return &Mac{h: hash.FromCrypto(id).Get()}
+}
+
+// Mac wraps a hash function and exposes Message Authentication Code methods.
+type Mac struct {
+	h *hash.Hash
+}
+
+// MAC computes a MAC over the message using key.
+func (m *Mac) MAC(key, message []byte) []byte {
+	return m.h.Hmac(message, key)
+}
+
+// Size returns the MAC's output length.
+func (m *Mac) Size() int {
+	return m.h.OutputSize()
+}
+
+// NewHash returns a newly instantiated Hash.
+func NewHash(id crypto.Hash) *Hash {
+	return &Hash{h: hash.FromCrypto(id).Get()}
+}
+
+// Hash wraps a hash function and exposes only necessary hashing methods.
+type Hash struct {
+	h *hash.Hash
+}
+
+// Size returns the output size of the hashing function.
+func (h *Hash) Size() int {
+	return h.h.OutputSize()
+}
+
+// Sum returns the current hash of the running state.
+func (h *Hash) Sum() []byte {
+	return h.h.Sum(nil)
+}
+
+// Write adds input to the running state.
+func (h *Hash) Write(p []byte) {
+	_, _ = h.h.Write(p)
+}
+
+// Reset clears the running state.
+func (h *Hash) Reset() {
+	h.h.Reset()
+}
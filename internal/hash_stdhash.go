@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build stdhash
+
+package internal
+
+import (
+	"crypto"
+	"crypto/hmac"
+	stdhash "hash"
+
+	"golang.org/x/crypto/hkdf"
+
+	// Register the SHA-3 functions with crypto.Hash.
+	_ "golang.org/x/crypto/sha3"
+)
+
+// The stdhash build tag only swaps the KDF, MAC, and Hash backends: they are backed by the standard library and
+// x/crypto instead of github.com/bytemare/crypto/hash. It doesn't drop github.com/bytemare/crypto from the build: the
+// groups, the OPRF, and the KSFs still come from it, as crypto/ecdh has no hash-to-curve nor point arithmetic for the
+// OPRF, and its shared secrets are x-coordinates, while 3DH derives its keys from the full encoding of the
+// Diffie-Hellman points.
+
+// NewKDF returns a newly instantiated KDF.
+func NewKDF(id crypto.Hash) *KDF {
+	return &KDF{newHash: id.New, size: id.Size()}
+}
+
+// KDF wraps a hash function and exposes KDF methods.
+type KDF struct {
+	newHash func() stdhash.Hash
+	size    int
+}
+
+// Extract exposes an Extract only KDF method.
+func (k *KDF) Extract(salt, ikm []byte) []byte {
+	return hkdf.Extract(k.newHash, ikm, salt)
+}
+
+// Size returns the output size of the Extract method.
+func (k *KDF) Size() int {
+	return k.size
+}
+
+// NewMac returns a newly instantiated Mac.
+func NewMac(id crypto.Hash) *Mac {
+	return &Mac{newHash: id.New, size: id.Size()}
+}
+
+// Mac wraps a hash function and exposes Message Authentication Code methods.
+type Mac struct {
+	newHash func() stdhash.Hash
+	size    int
+}
+
+// MAC computes a MAC over the message using key.
+func (m *Mac) MAC(key, message []byte) []byte {
+	h := hmac.New(m.newHash, key)
+	_, _ = h.Write(message)
+
+	return h.Sum(nil)
+}
+
+// Size returns the MAC's output length.
+func (m *Mac) Size() int {
+	return m.size
+}
+
+// NewHash returns a newly instantiated Hash.
+func NewHash(id crypto.Hash) *Hash {
+	return &Hash{h: id.New()}
+}
+
+// Hash wraps a hash function and exposes only necessary hashing methods.
+type Hash struct {
+	h stdhash.Hash
+}
+
+// Size returns the output size of the hashing function.
+func (h *Hash) Size() int {
+	return h.h.Size()
+}
+
+// Sum returns the current hash of the running state.
+func (h *Hash) Sum() []byte {
+	return h.h.Sum(nil)
+}
+
+// Write adds input to the running state.
+func (h *Hash) Write(p []byte) {
+	_, _ = h.h.Write(p)
+}
+
+// Reset clears the running state.
+func (h *Hash) Reset() {
+	h.h.Reset()
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package internal
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// HedgeKeyLength is the length of the per-instance secret mixed into the hedged values.
+const HedgeKeyLength = 32
+
+// The nonces and ephemeral scalars are hedged: they are derived from fresh randomness, the per-instance HedgeKey, and
+// the values of the session at hand, like the messages received so far and the long-term secrets. A weak or repeating
+// random number generator then doesn't translate into repeated or predictable values, as long as the instance secret
+// or the session's inputs differ, and a sound generator keeps them uniformly random. If the generator fails, the
+// values are derived without fresh randomness but with a per-instance counter, which keeps them unique and
+// unpredictable to anyone not knowing the instance secret, so that the operations drawing them never fail.
+
+// hedge extracts a key from fresh randomness, the instance secret, and the length-prefixed inputs.
+func (c *Configuration) hedge(inputs [][]byte) []byte {
+	size := SeedLength + 8
+	for _, in := range inputs {
+		size += 4 + len(in)
+	}
+
+	seed, err := RandomBytes(SeedLength)
+	if err != nil {
+		seed = make([]byte, SeedLength)
+	}
+
+	ikm := append(make([]byte, 0, size), seed...)
+	Zero(seed)
+
+	var counter [8]byte
+
+	binary.BigEndian.PutUint64(counter[:], atomic.AddUint64(&c.hedgeCounter, 1))
+	ikm = append(ikm, counter[:]...)
+
+	var length [4]byte
+
+	for _, in := range inputs {
+		binary.BigEndian.PutUint32(length[:], uint32(len(in)))
+		ikm = append(ikm, length[:]...)
+		ikm = append(ikm, in...)
+	}
+
+	prk := c.KDF.Extract(c.HedgeKey, ikm)
+	Zero(ikm)
+
+	return prk
+}
+
+// HedgedBytes returns length hedged bytes for the label, mixing in the inputs.
+func (c *Configuration) HedgedBytes(label string, length int, inputs ...[]byte) []byte {
+	prk := c.hedge(inputs)
+	defer Zero(prk)
+
+	return c.KDF.Expand(prk, []byte(label), length)
+}
+
+// HedgedScalar returns a hedged scalar in g for the label, mixing in the inputs.
+func (c *Configuration) HedgedScalar(g group.Group, label string, inputs ...[]byte) *group.Scalar {
+	seed := c.HedgedBytes(label, 2*SeedLength, inputs...)
+	defer Zero(seed)
+
+	return g.HashToScalar(seed, []byte(tag.Hedged))
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package keyrecovery
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+const (
+	aeadKeyLength = 32
+	aeadOverhead  = 16
+)
+
+// AuthTagLength returns the length of the envelope's authentication tag, i.e. the MAC output length, or the overhead
+// of the AEAD sealing the envelope.
+func AuthTagLength(conf *internal.Configuration) int {
+	if conf.AEAD != internal.NoAEAD {
+		return aeadOverhead
+	}
+
+	return conf.MAC.Size()
+}
+
+// newAEAD returns the AEAD keyed for the envelope with the given nonce. Each key seals a single envelope, so the AEAD
+// nonce is fixed.
+func newAEAD(conf *internal.Configuration, randomizedPwd, nonce []byte) (cipher.AEAD, error) {
+	key := conf.KDF.Expand(randomizedPwd, encoding.SuffixString(nonce, tag.EnvelopeAEADKey), aeadKeyLength)
+	defer internal.Zero(key)
+
+	if conf.AEAD == internal.ChaCha20Poly1305 {
+		return chacha20poly1305.New(key)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// aeadCredentials returns the additional data of the sealed envelope, i.e. its nonce and the cleartext credentials.
+// In the external mode, the client's public key is not known before opening the envelope, and is instead bound by
+// the private key in the plaintext, and a nil client identity is encoded as empty.
+func aeadCredentials(
+	conf *internal.Configuration,
+	nonce, clientPublicKey, serverPublicKey []byte,
+	ids *Identities,
+) []byte {
+	if conf.Mode == internal.External {
+		clientPublicKey = nil
+	}
+
+	return encoding.Concat(nonce, cleartextCredentials(clientPublicKey, serverPublicKey, ids.Client, ids.Server))
+}
+
+// appDataSlot returns the padded application data slot, or nil if the configuration doesn't set one.
+func appDataSlot(conf *internal.Configuration, appData []byte) []byte {
+	if conf.AppDataLength == 0 {
+		return nil
+	}
+
+	slot := make([]byte, AppDataSlotLength(conf))
+	copy(slot, encoding.I2OSP(len(appData), 2))
+	copy(slot[2:], appData)
+
+	return slot
+}
+
+// parseAppDataSlot returns the application data in the decrypted slot.
+func parseAppDataSlot(conf *internal.Configuration, slot []byte) ([]byte, error) {
+	if conf.AppDataLength == 0 {
+		return nil, nil
+	}
+
+	length := encoding.OS2IP(slot[:2])
+	if length > conf.AppDataLength {
+		return nil, errAppDataTooLong
+	}
+
+	return slot[2 : 2+length], nil
+}
+
+// seal returns the envelope sealing the encoded client private key, if any, and the application data slot with the
+// AEAD. The ciphertext is split along the fields of the envelope, and the AEAD tag is its authentication tag.
+func seal(conf *internal.Configuration, randomizedPwd, nonce, secretKey, appData, ad []byte) (*Envelope, error) {
+	aead, err := newAEAD(conf, randomizedPwd, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	slot := appDataSlot(conf, appData)
+	plaintext := encoding.Concat(secretKey, slot)
+
+	defer func() {
+		internal.Zero(slot)
+		internal.Zero(plaintext)
+	}()
+
+	ciphertext := aead.Seal(nil, make([]byte, aead.NonceSize()), plaintext, ad)
+	innerEnd := len(secretKey)
+	slotEnd := len(plaintext)
+
+	return &Envelope{
+		Nonce:         nonce,
+		InnerEnvelope: ciphertext[:innerEnd:innerEnd],
+		AppData:       ciphertext[innerEnd:slotEnd:slotEnd],
+		AuthTag:       ciphertext[slotEnd:],
+	}, nil
+}
+
+// open authenticates and decrypts the envelope sealed with the AEAD, and returns the encoded client private key, empty
+// in the internal mode, and the application data slot.
+func open(
+	conf *internal.Configuration,
+	randomizedPwd []byte,
+	envelope *Envelope,
+	ad []byte,
+) (secretKey, slot []byte, err error) {
+	aead, err := newAEAD(conf, randomizedPwd, envelope.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ciphertext := encoding.Concatenate(envelope.InnerEnvelope, envelope.AppData, envelope.AuthTag)
+
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, ad)
+	if err != nil {
+		return nil, nil, internal.Detail(errEnvelopeInvalidMac, "AEAD open of the %d-byte sealed envelope: %v",
+			len(ciphertext), err)
+	}
+
+	innerEnd := len(envelope.InnerEnvelope)
+
+	return plaintext[:innerEnd], plaintext[innerEnd:], nil
+}
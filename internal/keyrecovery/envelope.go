@@ -11,6 +11,7 @@ package keyrecovery
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/bytemare/crypto/group"
 
@@ -19,32 +20,113 @@ import (
 	"github.com/bytemare/opaque/internal/tag"
 )
 
-var errEnvelopeInvalidMac = errors.New("recover envelope: invalid envelope authentication tag")
+var (
+	errEnvelopeInvalidMac = errors.New("recover envelope: invalid envelope authentication tag")
+	errAppDataTooLong     = errors.New("application data is longer than the configured length")
+)
 
-// Credentials structure is currently used for testing purposes.
-type Credentials struct {
-	ClientIdentity, ServerIdentity []byte
-	EnvelopeNonce, MaskingNonce    []byte // testing: integrated to support testing
+// Identities are the client and server identities bound to the envelope. A nil identity defaults to the public key of
+// its party.
+type Identities struct {
+	Client, Server []byte
 }
 
-// Envelope represents the OPAQUE envelope.
+// Envelope represents the OPAQUE envelope. InnerEnvelope holds the encrypted client private key in the external mode,
+// and is empty in the internal mode. AppData holds the encrypted application data slot if the configuration sets an
+// application data length, and is empty otherwise.
 type Envelope struct {
-	Nonce   []byte
-	AuthTag []byte
+	Nonce         []byte
+	InnerEnvelope []byte
+	AppData       []byte
+	AuthTag       []byte
 }
 
 // Serialize returns the byte serialization of the envelope.
 func (e *Envelope) Serialize() []byte {
-	return encoding.Concat(e.Nonce, e.AuthTag)
+	return encoding.Concatenate(e.Nonce, e.InnerEnvelope, e.AppData, e.AuthTag)
+}
+
+// String returns a description of the envelope with the lengths and fingerprints of its fields, but not their values.
+func (e *Envelope) String() string {
+	if e == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("keyrecovery.Envelope{Nonce: %s, InnerEnvelope: %s, AppData: %s, AuthTag: %s}",
+		internal.Redacted(e.Nonce), internal.Redacted(e.InnerEnvelope), internal.Redacted(e.AppData),
+		internal.Redacted(e.AuthTag))
+}
+
+// GoString returns the same description as String.
+func (e *Envelope) GoString() string {
+	return e.String()
+}
+
+// Format prints the description of String for any verb.
+func (e *Envelope) Format(f fmt.State, _ rune) {
+	internal.Format(f, e)
+}
+
+// AppDataSlotLength returns the length of the encrypted application data slot in the envelope, i.e. the 2-byte
+// encoding of the data length followed by the data padded to the configured length.
+func AppDataSlotLength(conf *internal.Configuration) int {
+	if conf.AppDataLength == 0 {
+		return 0
+	}
+
+	return 2 + conf.AppDataLength
+}
+
+// InnerEnvelopeLength returns the length of the inner envelope for the configuration's mode.
+func InnerEnvelopeLength(conf *internal.Configuration) int {
+	if conf.Mode == internal.External {
+		return encoding.ScalarLength[conf.Group]
+	}
+
+	return 0
+}
+
+// Deserialize returns the Envelope contained in the input. It assumes the input is of the configuration's
+// envelope size.
+func Deserialize(conf *internal.Configuration, envelope []byte) *Envelope {
+	offset := conf.NonceLen + InnerEnvelopeLength(conf)
+	appDataEnd := offset + AppDataSlotLength(conf)
+
+	return &Envelope{
+		Nonce:         envelope[:conf.NonceLen],
+		InnerEnvelope: envelope[conf.NonceLen:offset],
+		AppData:       envelope[offset:appDataEnd],
+		AuthTag:       envelope[appDataEnd:],
+	}
 }
 
 func exportKey(conf *internal.Configuration, randomizedPwd, nonce []byte) []byte {
 	return conf.KDF.Expand(randomizedPwd, encoding.SuffixString(nonce, tag.ExportKey), conf.KDF.Size())
 }
 
-func authTag(conf *internal.Configuration, randomizedPwd, nonce, ctc []byte) []byte {
-	authKey := conf.KDF.Expand(randomizedPwd, encoding.SuffixString(nonce, tag.AuthKey), conf.KDF.Size())
-	return conf.MAC.MAC(authKey, encoding.Concat(nonce, ctc))
+func authTag(conf *internal.Configuration, randomizedPwd []byte, envelope *Envelope, ctc []byte) []byte {
+	authKey := conf.KDF.Expand(randomizedPwd, encoding.SuffixString(envelope.Nonce, tag.AuthKey), conf.KDF.Size())
+	defer internal.Zero(authKey)
+
+	conf.Trace(internal.TraceAuthKey, authKey)
+
+	return conf.MAC.MAC(
+		authKey,
+		encoding.Concatenate(envelope.Nonce, envelope.InnerEnvelope, envelope.AppData, ctc),
+	)
+}
+
+// xorPad encrypts or decrypts the input with a pad derived from the randomized password, the nonce, and the label.
+func xorPad(conf *internal.Configuration, randomizedPwd, nonce []byte, label string, in []byte) []byte {
+	pad := conf.KDF.Expand(randomizedPwd, encoding.SuffixString(nonce, label), len(in))
+	defer internal.Zero(pad)
+
+	out := make([]byte, len(in))
+	for i, r := range pad {
+		out[i] = r ^ in[i]
+	}
+
+	return out
 }
 
 // cleartextCredentials assumes that clientPublicKey, serverPublicKey are non-nil valid group elements.
@@ -65,55 +147,213 @@ func cleartextCredentials(clientPublicKey, serverPublicKey, clientIdentity, serv
 }
 
 // Store returns the client's Envelope, the masking key for the registration, and the additional export key.
+// If clientSecretKey is nil, the client key pair is derived from the randomized password in the internal mode, or
+// randomly generated in the external mode. The given key is used otherwise, and encrypted in the external mode.
+// appData is sealed in the envelope if the configuration sets an application data length. If nonce is nil, the
+// envelope nonce is hedged random.
 func Store(
 	conf *internal.Configuration,
 	randomizedPwd, serverPublicKey []byte,
-	creds *Credentials,
-) (env *Envelope, pku *group.Point, export []byte) {
-	// testing: integrated to support testing with set nonce
-	nonce := creds.EnvelopeNonce
+	clientSecretKey *group.Scalar,
+	appData []byte,
+	ids *Identities,
+	nonce []byte,
+) (env *Envelope, pku *group.Point, export []byte, err error) {
+	if len(appData) > conf.AppDataLength {
+		return nil, nil, nil, errAppDataTooLong
+	}
+
 	if nonce == nil {
-		nonce = internal.RandomBytes(conf.NonceLen)
+		nonce = conf.HedgedBytes(tag.HedgedEnvelopeNonce, conf.NonceLen, randomizedPwd, serverPublicKey)
 	}
 
-	pku = getPubkey(conf, randomizedPwd, nonce)
-	ctc := cleartextCredentials(
-		encoding.SerializePoint(pku, conf.Group),
-		serverPublicKey,
-		creds.ClientIdentity,
-		creds.ServerIdentity,
-	)
-	auth := authTag(conf, randomizedPwd, nonce, ctc)
-	export = exportKey(conf, randomizedPwd, nonce)
+	var secret []byte
+
+	switch {
+	case conf.Mode == internal.External:
+		if clientSecretKey == nil {
+			clientSecretKey, err = internal.RandomScalar(conf.Group)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+
+		pku = conf.BaseMult(clientSecretKey)
+		secret = encoding.SerializeScalar(clientSecretKey, conf.Group)
+		defer internal.Zero(secret)
+	case clientSecretKey != nil:
+		pku = conf.BaseMult(clientSecretKey)
+	default:
+		pku, err = getPubkey(conf, randomizedPwd, nonce)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	pk := encoding.SerializePoint(pku, conf.Group)
+
+	if conf.AEAD != internal.NoAEAD {
+		ad := aeadCredentials(conf, nonce, pk, serverPublicKey, ids)
+		if env, err = seal(conf, randomizedPwd, nonce, secret, appData, ad); err != nil {
+			return nil, nil, nil, err
+		}
+
+		return env, pku, exportKey(conf, randomizedPwd, nonce), nil
+	}
+
+	var inner []byte
+	if secret != nil {
+		inner = xorPad(conf, randomizedPwd, nonce, tag.EncryptionPad, secret)
+	}
 
 	env = &Envelope{
-		Nonce:   nonce,
-		AuthTag: auth,
+		Nonce:         nonce,
+		InnerEnvelope: inner,
+		AppData:       sealAppData(conf, randomizedPwd, nonce, appData),
 	}
+	env.AuthTag = authTag(conf, randomizedPwd, env, cleartextCredentials(pk, serverPublicKey, ids.Client, ids.Server))
+	export = exportKey(conf, randomizedPwd, nonce)
 
-	return env, pku, export
+	return env, pku, export, nil
 }
 
-// Recover returns the client's private and public key, as well as the secret export key.
+// Recover returns the client's private and public key, the secret export key, and the application data sealed in the
+// envelope, if any. In the internal mode, if
+// knownSecretKey is not nil, it is used as the client's private key instead of deriving it from the randomized
+// password. In the external mode, the private key is always decrypted from the envelope.
 func Recover(
 	conf *internal.Configuration,
-	randomizedPwd, serverPublicKey, clientIdentity, serverIdentity []byte,
+	randomizedPwd, serverPublicKey []byte,
+	ids *Identities,
+	knownSecretKey *group.Scalar,
 	envelope *Envelope,
-) (clientSecretKey *group.Scalar, clientPublicKey *group.Point, export []byte, err error) {
-	clientSecretKey, clientPublicKey = recoverKeys(conf, randomizedPwd, envelope.Nonce)
+) (clientSecretKey *group.Scalar, clientPublicKey *group.Point, export, appData []byte, err error) {
+	if conf.AEAD != internal.NoAEAD {
+		return recoverSealed(conf, randomizedPwd, serverPublicKey, ids, knownSecretKey, envelope)
+	}
+
+	switch {
+	case conf.Mode == internal.External:
+		clientSecretKey, err = decryptSecretKey(conf, randomizedPwd, envelope)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		clientPublicKey = conf.BaseMult(clientSecretKey)
+	case knownSecretKey != nil:
+		clientSecretKey, clientPublicKey = knownSecretKey, conf.BaseMult(knownSecretKey)
+	default:
+		clientSecretKey, clientPublicKey, err = recoverKeys(conf, randomizedPwd, envelope.Nonce)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
 	ctc := cleartextCredentials(
 		encoding.SerializePoint(clientPublicKey, conf.Group),
 		serverPublicKey,
-		clientIdentity,
-		serverIdentity,
+		ids.Client,
+		ids.Server,
 	)
 
-	expectedTag := authTag(conf, randomizedPwd, envelope.Nonce, ctc)
+	expectedTag := authTag(conf, randomizedPwd, envelope, ctc)
 	if !conf.MAC.Equal(expectedTag, envelope.AuthTag) {
-		return nil, nil, nil, errEnvelopeInvalidMac
+		return nil, nil, nil, nil, internal.Detail(errEnvelopeInvalidMac,
+			"envelope authentication tag of %d bytes, expected %d", len(envelope.AuthTag), len(expectedTag))
+	}
+
+	appData, err = openAppData(conf, randomizedPwd, envelope)
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
 
 	export = exportKey(conf, randomizedPwd, envelope.Nonce)
 
-	return clientSecretKey, clientPublicKey, export, nil
+	return clientSecretKey, clientPublicKey, export, appData, nil
+}
+
+// recoverSealed is Recover for the envelopes sealed with an AEAD.
+func recoverSealed(
+	conf *internal.Configuration,
+	randomizedPwd, serverPublicKey []byte,
+	ids *Identities,
+	knownSecretKey *group.Scalar,
+	envelope *Envelope,
+) (clientSecretKey *group.Scalar, clientPublicKey *group.Point, export, appData []byte, err error) {
+	var pk []byte
+
+	switch {
+	case conf.Mode == internal.External:
+	case knownSecretKey != nil:
+		clientSecretKey, clientPublicKey = knownSecretKey, conf.BaseMult(knownSecretKey)
+	default:
+		clientSecretKey, clientPublicKey, err = recoverKeys(conf, randomizedPwd, envelope.Nonce)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	if clientPublicKey != nil {
+		pk = encoding.SerializePoint(clientPublicKey, conf.Group)
+	}
+
+	secret, slot, err := open(conf, randomizedPwd, envelope,
+		aeadCredentials(conf, envelope.Nonce, pk, serverPublicKey, ids))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	defer internal.Zero(secret)
+
+	if conf.Mode == internal.External {
+		clientSecretKey, err = conf.Group.NewScalar().Decode(secret)
+		if err != nil || clientSecretKey.IsZero() {
+			return nil, nil, nil, nil, internal.Detail(errEnvelopeInvalidMac,
+				"the sealed external private key is not a valid scalar")
+		}
+
+		clientPublicKey = conf.BaseMult(clientSecretKey)
+	}
+
+	if appData, err = parseAppDataSlot(conf, slot); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return clientSecretKey, clientPublicKey, exportKey(conf, randomizedPwd, envelope.Nonce), appData, nil
+}
+
+// decryptSecretKey returns the client's private key encrypted in the external mode envelope. A wrong password yields a
+// garbage scalar that is either rejected here or by the subsequent authentication tag verification, with the same
+// error.
+func decryptSecretKey(conf *internal.Configuration, randomizedPwd []byte, envelope *Envelope) (*group.Scalar, error) {
+	encoded := xorPad(conf, randomizedPwd, envelope.Nonce, tag.EncryptionPad, envelope.InnerEnvelope)
+	defer internal.Zero(encoded)
+
+	sk, err := conf.Group.NewScalar().Decode(encoded)
+	if err != nil || sk.IsZero() {
+		return nil, internal.Detail(errEnvelopeInvalidMac, "the decrypted external private key is not a valid scalar")
+	}
+
+	return sk, nil
+}
+
+// sealAppData returns the encrypted application data slot, or nil if the configuration doesn't set one.
+func sealAppData(conf *internal.Configuration, randomizedPwd, nonce, appData []byte) []byte {
+	if conf.AppDataLength == 0 {
+		return nil
+	}
+
+	slot := appDataSlot(conf, appData)
+	defer internal.Zero(slot)
+
+	return xorPad(conf, randomizedPwd, nonce, tag.AppDataPad, slot)
+}
+
+// openAppData returns the application data in the envelope. It must only be called after the envelope's
+// authentication tag has been verified.
+func openAppData(conf *internal.Configuration, randomizedPwd []byte, envelope *Envelope) ([]byte, error) {
+	if conf.AppDataLength == 0 {
+		return nil, nil
+	}
+
+	return parseAppDataSlot(conf, xorPad(conf, randomizedPwd, envelope.Nonce, tag.AppDataPad, envelope.AppData))
 }
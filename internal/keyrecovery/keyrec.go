@@ -13,25 +13,32 @@ import (
 
 	"github.com/bytemare/opaque/internal"
 	"github.com/bytemare/opaque/internal/encoding"
-	"github.com/bytemare/opaque/internal/oprf"
 	"github.com/bytemare/opaque/internal/tag"
 )
 
-func deriveAuthKeyPair(conf *internal.Configuration, randomizedPwd, nonce []byte) (*group.Scalar, *group.Point) {
+func deriveAuthKeyPair(
+	conf *internal.Configuration,
+	randomizedPwd, nonce []byte,
+) (*group.Scalar, *group.Point, error) {
 	seed := conf.KDF.Expand(randomizedPwd, encoding.SuffixString(nonce, tag.ExpandPrivateKey), internal.SeedLength)
-	sk := oprf.Ciphersuite(conf.Group).DeriveKey(seed, []byte(tag.DerivePrivateKey))
+	defer internal.Zero(seed)
+
+	sk, err := conf.DerivePrivateKey(seed)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return sk, conf.Group.Base().Mult(sk)
+	return sk, conf.BaseMult(sk), nil
 }
 
-func getPubkey(conf *internal.Configuration, randomizedPwd, nonce []byte) *group.Point {
-	_, pk := deriveAuthKeyPair(conf, randomizedPwd, nonce)
-	return pk
+func getPubkey(conf *internal.Configuration, randomizedPwd, nonce []byte) (*group.Point, error) {
+	_, pk, err := deriveAuthKeyPair(conf, randomizedPwd, nonce)
+	return pk, err
 }
 
 func recoverKeys(
 	conf *internal.Configuration,
 	randomizedPwd, nonce []byte,
-) (clientSecretKey *group.Scalar, clientPublicKey *group.Point) {
+) (clientSecretKey *group.Scalar, clientPublicKey *group.Point, err error) {
 	return deriveAuthKeyPair(conf, randomizedPwd, nonce)
 }
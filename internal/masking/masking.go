@@ -30,19 +30,36 @@ type Keys struct {
 	ExportKey, ServerPublicKeyBytes  []byte
 }
 
+// BufferSize returns the capacity of a buffer in which MaskInto doesn't allocate: the masked response and the scratch
+// space of the last block of the pad.
+func BufferSize(conf *internal.Configuration) int {
+	return encoding.PointLength[conf.Group] + conf.EnvelopeSize + conf.KDF.Size()
+}
+
 // Mask encrypts the serverPublicKey and the envelope under nonceIn and the maskingKey.
 func Mask(
 	conf *internal.Configuration,
 	nonceIn, maskingKey, serverPublicKey, envelope []byte,
+) (nonce, maskedResponse []byte) {
+	return MaskInto(conf, nil, nonceIn, maskingKey, serverPublicKey, envelope)
+}
+
+// MaskInto is like Mask, but writes the masked response into buf, without allocating it if buf has a capacity of at
+// least BufferSize. The masked response shares its memory with buf.
+func MaskInto(
+	conf *internal.Configuration,
+	buf, nonceIn, maskingKey, serverPublicKey, envelope []byte,
 ) (nonce, maskedResponse []byte) {
 	// testing: integrated to support testing, to force values.
 	nonce = nonceIn
 	if len(nonce) == 0 {
-		nonce = internal.RandomBytes(conf.NonceLen)
+		nonce = conf.HedgedBytes(tag.HedgedMaskingNonce, conf.NonceLen, maskingKey, envelope)
 	}
 
-	clear := encoding.Concat(serverPublicKey, envelope)
-	maskedResponse = xorResponse(conf, maskingKey, nonce, clear)
+	// The pad is xored in place with the public key and the envelope, instead of with their concatenation.
+	maskedResponse = responsePad(conf, buf[:0], maskingKey, nonce)
+	xor(maskedResponse, serverPublicKey)
+	xor(maskedResponse[len(serverPublicKey):], envelope)
 
 	return nonce, maskedResponse
 }
@@ -54,38 +71,34 @@ func Unmask(
 	randomizedPwd, nonce, maskedResponse []byte,
 ) (serverPublicKey *group.Point, serverPublicKeyBytes []byte, envelope *keyrecovery.Envelope, err error) {
 	maskingKey := conf.KDF.Expand(randomizedPwd, []byte(tag.MaskingKey), conf.Hash.Size())
-	clear := xorResponse(conf, maskingKey, nonce, maskedResponse)
+	clear := responsePad(conf, nil, maskingKey, nonce)
+	internal.Zero(maskingKey)
+	xor(clear, maskedResponse)
 	serverPublicKeyBytes = clear[:encoding.PointLength[conf.Group]]
-	env := clear[encoding.PointLength[conf.Group]:]
-	envelope = &keyrecovery.Envelope{
-		Nonce:   env[:conf.NonceLen],
-		AuthTag: env[conf.NonceLen:],
-	}
+	envelope = keyrecovery.Deserialize(conf, clear[encoding.PointLength[conf.Group]:])
 
 	serverPublicKey, err = conf.Group.NewElement().Decode(serverPublicKeyBytes)
 	if err != nil {
-		return nil, nil, nil, errInvalidPKS
+		return nil, nil, nil, internal.Detail(errInvalidPKS,
+			"the unmasked server public key doesn't decode, e.g. for a wrong password: %v", err)
 	}
 
 	return serverPublicKey, serverPublicKeyBytes, envelope, nil
 }
 
-// xorResponse is used to encrypt and decrypt the response in KE2.
-// It returns a new byte slice containing the byte-by-byte xor-ing of the in argument and a constructed pad,
-// which must be of the same length.
-func xorResponse(c *internal.Configuration, key, nonce, in []byte) []byte {
-	pad := c.KDF.Expand(
+// responsePad returns the pad encrypting and decrypting the response in KE2, appended to dst.
+func responsePad(c *internal.Configuration, dst, key, nonce []byte) []byte {
+	return c.KDF.ExpandInto(
+		dst,
 		key,
 		encoding.SuffixString(nonce, tag.CredentialResponsePad),
 		encoding.PointLength[c.Group]+c.EnvelopeSize,
 	)
+}
 
-	dst := make([]byte, len(pad))
-
-	// if the size is fixed, we could unroll the loop
-	for i, r := range pad {
-		dst[i] = r ^ in[i]
+// xor xors in into the beginning of dst, which must be at least as long.
+func xor(dst, in []byte) {
+	for i, b := range in {
+		dst[i] ^= b
 	}
-
-	return dst
 }
@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build !debug
+
+package internal
+
+// Debug is true in the builds with the debug tag.
+const Debug = false
+
+// Detail returns err as is: the errors of the default build are uniform, and don't tell which check failed.
+func Detail(err error, _ string, _ ...any) error {
+	return err
+}
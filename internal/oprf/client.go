@@ -22,8 +22,24 @@ var errInvalidInput = errors.New("invalid input - OPRF input deterministically m
 // Client implements the OPRF client and holds its state.
 type Client struct {
 	Ciphersuite
-	input []byte
-	blind *group.Scalar
+	input   []byte
+	info    []byte
+	blind   *group.Scalar
+	blinded *group.Point
+}
+
+// SetInfo sets the public info string to bind into the evaluation, switching the client to the POPRF mode.
+// A nil info uses the base OPRF mode.
+func (c *Client) SetInfo(info []byte) {
+	c.info = info
+}
+
+func (c *Client) mode() mode {
+	if c.info != nil {
+		return partial
+	}
+
+	return base
 }
 
 // SetBlind allows to set the blinding scalar to use.
@@ -31,20 +47,32 @@ func (c *Client) SetBlind(blind *group.Scalar) {
 	c.blind = blind
 }
 
+// HasBlind returns whether the blinding scalar is set.
+func (c *Client) HasBlind() bool {
+	return c.blind != nil
+}
+
 // Blind masks the input.
 func (c *Client) Blind(input []byte) *group.Point {
 	if c.blind == nil {
 		c.blind = c.Group().NewScalar().Random()
 	}
 
-	p := c.Group().HashToGroup(input, c.dst(tag.OPRFPointPrefix))
+	p := c.Group().HashToGroup(input, c.dst(tag.OPRFPointPrefix, c.mode()))
 	if p.IsIdentity() {
+		// Unreachable: finding such an input would break the hash-to-curve function.
 		panic(errInvalidInput)
 	}
 
 	c.input = input
+	c.blinded = p.Mult(c.blind)
+
+	return c.blinded.Copy()
+}
 
-	return p.Mult(c.blind)
+// Blinded returns the blinded element of the last call to Blind, or nil after Wipe.
+func (c *Client) Blinded() *group.Point {
+	return c.blinded
 }
 
 func (c *Client) hashTranscript(input, unblinded []byte) []byte {
@@ -52,9 +80,21 @@ func (c *Client) hashTranscript(input, unblinded []byte) []byte {
 	encElement := encoding.EncodeVector(unblinded)
 	encDST := []byte(tag.OPRFFinalize)
 
+	if c.info != nil {
+		return c.Ciphersuite.hash(encInput, encoding.EncodeVector(c.info), encElement, encDST)
+	}
+
 	return c.Ciphersuite.hash(encInput, encElement, encDST)
 }
 
+// Wipe drops the blind, the blinded element, and the input, which are not needed after Finalize. The blind can't be zeroed in place, and the
+// input is the caller's.
+func (c *Client) Wipe() {
+	c.blind = nil
+	c.blinded = nil
+	c.input = nil
+}
+
 // Finalize terminates the OPRF by unblinding the evaluation and hashing the transcript.
 func (c *Client) Finalize(evaluation *group.Point) []byte {
 	u := encoding.SerializePoint(evaluation.InvertMult(c.blind), c.Ciphersuite.Group())
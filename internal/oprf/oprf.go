@@ -12,6 +12,7 @@ package oprf
 
 import (
 	"crypto"
+	"errors"
 
 	"github.com/bytemare/crypto/group"
 
@@ -19,11 +20,19 @@ import (
 	"github.com/bytemare/opaque/internal/tag"
 )
 
-// mode distinguishes between the OPRF base mode and the Verifiable mode.
+// mode distinguishes between the OPRF base mode, the Verifiable mode, and the Partially-oblivious mode.
 type mode byte
 
-// base identifies the OPRF non-verifiable, base mode.
-const base mode = iota
+const (
+	// base identifies the OPRF non-verifiable, base mode.
+	base mode = iota
+
+	// verifiable identifies the VOPRF mode, which is not used in OPAQUE.
+	_
+
+	// partial identifies the POPRF mode, binding a public info string into the evaluation.
+	partial
+)
 
 // Ciphersuite identifies the OPRF compatible cipher suite to be used.
 type Ciphersuite group.Group
@@ -40,10 +49,24 @@ const (
 
 	// P521Sha512 is the OPRF cipher suite of the NIST P-512 group and SHA-512.
 	P521Sha512 = Ciphersuite(group.P521Sha512)
+
+	// rfc9497 flags a cipher suite using the context string of RFC 9497 instead of the draft's.
+	rfc9497 Ciphersuite = 0x80
 )
 
+// identifiers are the names of the cipher suites in the RFC 9497 context string.
+var identifiers = map[group.Group]string{
+	group.Ristretto255Sha512: "ristretto255-SHA512",
+	group.P256Sha256:         "P256-SHA256",
+	group.P384Sha384:         "P384-SHA384",
+	group.P521Sha512:         "P521-SHA512",
+}
+
 var suiteToHash = make(map[group.Group]crypto.Hash)
 
+// errDeriveKeyPair happens when DeriveKey doesn't find a non-zero scalar.
+var errDeriveKeyPair = errors.New("DeriveKeyPairError")
+
 func init() {
 	RistrettoSha512.register(crypto.SHA512)
 	P256Sha256.register(crypto.SHA256)
@@ -55,12 +78,28 @@ func (c Ciphersuite) register(h crypto.Hash) {
 	suiteToHash[c.Group()] = h
 }
 
-func (c Ciphersuite) dst(prefix string) []byte {
-	return encoding.Concat([]byte(prefix), c.contextString())
+func (c Ciphersuite) dst(prefix string, m mode) []byte {
+	return encoding.Concat([]byte(prefix), c.contextString(m))
+}
+
+func (c Ciphersuite) contextString(m mode) []byte {
+	if c&rfc9497 != 0 {
+		return encoding.Concatenate([]byte(tag.OPRFV1), encoding.I2OSP(int(m), 1), []byte("-"),
+			[]byte(identifiers[c.Group()]))
+	}
+
+	return encoding.Concat3([]byte(tag.OPRF), encoding.I2OSP(int(m), 1), encoding.I2OSP(int(c), 2))
+}
+
+// RFC9497 returns the cipher suite with the context string of RFC 9497, as used by RFC 9807, instead of the draft's.
+func (c Ciphersuite) RFC9497() Ciphersuite {
+	return c | rfc9497
 }
 
-func (c Ciphersuite) contextString() []byte {
-	return encoding.Concat3([]byte(tag.OPRF), encoding.I2OSP(int(base), 1), encoding.I2OSP(int(c), 2))
+// infoScalar maps the public POPRF info string to a scalar.
+func (c Ciphersuite) infoScalar(info []byte) *group.Scalar {
+	framedInfo := encoding.Concat([]byte(tag.OPRFInfo), encoding.EncodeVector(info))
+	return c.Group().HashToScalar(framedInfo, c.dst(tag.OPRFScalarPrefix, partial))
 }
 
 func (c Ciphersuite) hash(input ...[]byte) []byte {
@@ -82,7 +121,7 @@ func (c Ciphersuite) Available() bool {
 
 // Group returns the Group identifier for the cipher suite.
 func (c Ciphersuite) Group() group.Group {
-	return group.Group(c)
+	return group.Group(c &^ rfc9497)
 }
 
 // SerializePoint returns the byte encoding of the point padded accordingly.
@@ -90,9 +129,9 @@ func (c Ciphersuite) SerializePoint(p *group.Point) []byte {
 	return encoding.SerializePoint(p, c.Group())
 }
 
-// DeriveKey returns a scalar mapped from the input.
-func (c Ciphersuite) DeriveKey(seed, info []byte) *group.Scalar {
-	dst := encoding.Concat([]byte(tag.DeriveKeyPairInternal), c.contextString())
+// DeriveKey returns a scalar mapped from the input, or an error in the negligible case no non-zero scalar is found.
+func (c Ciphersuite) DeriveKey(seed, info []byte) (*group.Scalar, error) {
+	dst := encoding.Concat([]byte(tag.DeriveKeyPairInternal), c.contextString(base))
 	deriveInput := encoding.Concat(seed, encoding.EncodeVector(info))
 
 	var counter uint8
@@ -100,14 +139,14 @@ func (c Ciphersuite) DeriveKey(seed, info []byte) *group.Scalar {
 
 	for s == nil || s.IsZero() {
 		if counter > 255 {
-			panic("DeriveKeyPairError")
+			return nil, errDeriveKeyPair
 		}
 
 		s = c.Group().HashToScalar(encoding.Concat(deriveInput, []byte{counter}), dst)
 		counter++
 	}
 
-	return s
+	return s, nil
 }
 
 // Client returns an OPRF client.
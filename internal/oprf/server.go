@@ -9,10 +9,82 @@
 package oprf
 
 import (
+	"errors"
+
 	"github.com/bytemare/crypto/group"
 )
 
+var errInverseZero = errors.New("POPRF evaluation failed - the tweaked key is zero")
+
 // Evaluate evaluates the blinded input with the given key.
 func (c Ciphersuite) Evaluate(privateKey *group.Scalar, blindedElement *group.Point) *group.Point {
 	return blindedElement.Mult(privateKey)
 }
+
+// EvaluateWithInfo evaluates the blinded input with the given key tweaked by the public info, as in the POPRF mode.
+func (c Ciphersuite) EvaluateWithInfo(
+	privateKey *group.Scalar,
+	blindedElement *group.Point,
+	info []byte,
+) (*group.Point, error) {
+	t := privateKey.Add(c.infoScalar(info))
+	if t.IsZero() {
+		return nil, errInverseZero
+	}
+
+	return blindedElement.Mult(t.Invert()), nil
+}
+
+// EvaluateBatch evaluates each blinded input with the key at the same index, tweaked by the public info if it is not
+// nil, as in the POPRF mode. The tweaked keys are inverted together with Montgomery's trick, using a single inversion
+// for the whole batch.
+func (c Ciphersuite) EvaluateBatch(
+	privateKeys []*group.Scalar,
+	blindedElements []*group.Point,
+	info []byte,
+) ([]*group.Point, error) {
+	evaluations := make([]*group.Point, len(blindedElements))
+
+	if info == nil {
+		for i, element := range blindedElements {
+			evaluations[i] = c.Evaluate(privateKeys[i], element)
+		}
+
+		return evaluations, nil
+	}
+
+	if len(blindedElements) == 0 {
+		return evaluations, nil
+	}
+
+	tweak := c.infoScalar(info)
+	tweaked := make([]*group.Scalar, len(privateKeys))
+	products := make([]*group.Scalar, len(privateKeys))
+
+	for i, k := range privateKeys {
+		tweaked[i] = k.Add(tweak)
+		if tweaked[i].IsZero() {
+			return nil, errInverseZero
+		}
+
+		products[i] = tweaked[i]
+		if i > 0 {
+			products[i] = products[i-1].Mult(tweaked[i])
+		}
+	}
+
+	// inverse holds the inverse of the product of the first i+1 tweaked keys.
+	inverse := products[len(products)-1].Invert()
+
+	for i := len(tweaked) - 1; i >= 0; i-- {
+		t := inverse
+		if i > 0 {
+			t = inverse.Mult(products[i-1])
+			inverse = inverse.Mult(tweaked[i])
+		}
+
+		evaluations[i] = blindedElements[i].Mult(t)
+	}
+
+	return evaluations, nil
+}
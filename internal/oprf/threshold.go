@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package oprf
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+var (
+	errShareIndexZero      = errors.New("share index is zero")
+	errShareIndexDuplicate = errors.New("duplicate share index")
+)
+
+// scalarFromIndex returns the scalar encoding the small integer i.
+func (c Ciphersuite) scalarFromIndex(i uint16) *group.Scalar {
+	enc := make([]byte, encoding.ScalarLength[c.Group()])
+
+	if c.Group() == group.Ristretto255Sha512 {
+		binary.LittleEndian.PutUint16(enc, i)
+	} else {
+		binary.BigEndian.PutUint16(enc[len(enc)-2:], i)
+	}
+
+	s, err := c.Group().NewScalar().Decode(enc)
+	if err != nil {
+		// Small integers are always valid scalars.
+		panic(err)
+	}
+
+	return s
+}
+
+// SplitKey secret-shares the key using Shamir's scheme, given the coefficients of the polynomial of degree
+// threshold-1, with the key as the constant term at index 0 and random scalars otherwise, such that any threshold of
+// the total shares can reconstruct it. The i-th returned share is the evaluation of the polynomial at i+1.
+func (c Ciphersuite) SplitKey(coefficients []*group.Scalar, total uint16) []*group.Scalar {
+	threshold := len(coefficients)
+	shares := make([]*group.Scalar, total)
+
+	for i := uint16(1); i <= total; i++ {
+		x := c.scalarFromIndex(i)
+
+		// Horner's method.
+		share := coefficients[threshold-1].Copy()
+		for j := threshold - 2; j >= 0; j-- {
+			share = share.Mult(x).Add(coefficients[j])
+		}
+
+		shares[i-1] = share
+	}
+
+	return shares
+}
+
+// lagrangeCoefficient returns the Lagrange coefficient of the index at 0, given all participating indices.
+func (c Ciphersuite) lagrangeCoefficient(index uint16, indices []uint16) *group.Scalar {
+	xi := c.scalarFromIndex(index)
+	numerator := c.scalarFromIndex(1)
+	denominator := c.scalarFromIndex(1)
+
+	for _, j := range indices {
+		if j == index {
+			continue
+		}
+
+		xj := c.scalarFromIndex(j)
+		numerator = numerator.Mult(xj)
+		denominator = denominator.Mult(xj.Sub(xi))
+	}
+
+	return numerator.Mult(denominator.Invert())
+}
+
+// Combine interpolates the partial evaluations at the given distinct, non-zero indices into the evaluation under the
+// shared key.
+func (c Ciphersuite) Combine(indices []uint16, evaluations []*group.Point) (*group.Point, error) {
+	seen := make(map[uint16]bool, len(indices))
+
+	for _, i := range indices {
+		if i == 0 {
+			return nil, errShareIndexZero
+		}
+
+		if seen[i] {
+			return nil, errShareIndexDuplicate
+		}
+
+		seen[i] = true
+	}
+
+	var result *group.Point
+
+	for k, i := range indices {
+		term := evaluations[k].Mult(c.lagrangeCoefficient(i, indices))
+
+		if result == nil {
+			result = term
+		} else {
+			result = result.Add(term)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// fingerprintLength is the length of the fingerprints of the redacted values.
+const fingerprintLength = 8
+
+// Redacted returns a printable description of value with its length and a fingerprint, but not the value itself, so
+// that secrets can be told apart in logs without being leaked.
+func Redacted(value []byte) string {
+	if len(value) == 0 {
+		return "<empty>"
+	}
+
+	h := sha256.New()
+	_, _ = h.Write([]byte(tag.Fingerprint))
+	_, _ = h.Write(value)
+
+	return fmt.Sprintf("<%d bytes, fingerprint %s>", len(value), hex.EncodeToString(h.Sum(nil)[:fingerprintLength]))
+}
+
+// Format writes the description of s for any formatting verb, so that no verb prints the raw fields of the type
+// implementing fmt.Formatter with it.
+func Format(f fmt.State, s fmt.Stringer) {
+	_, _ = io.WriteString(f, s.String())
+}
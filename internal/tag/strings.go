@@ -16,6 +16,9 @@ const (
 	// OPRF is a string explicitly stating the version name.
 	OPRF = "VOPRF09-"
 
+	// OPRFV1 is the version name of the OPRF in RFC 9497.
+	OPRFV1 = "OPRFV1-"
+
 	// DeriveKeyPairInternal is the internal DeriveKeyPair tag as defined in VOPRF.
 	DeriveKeyPairInternal = "DeriveKeyPair"
 
@@ -25,6 +28,12 @@ const (
 	// OPRFFinalize is the DST suffix used in the client transcript.
 	OPRFFinalize = "Finalize"
 
+	// OPRFScalarPrefix is the DST prefix to use for HashToScalar operations.
+	OPRFScalarPrefix = "HashToScalar-"
+
+	// OPRFInfo is the prefix of the public info string framing in the POPRF mode.
+	OPRFInfo = "Info"
+
 	// Envelope tags.
 
 	// AuthKey is the envelope's MAC key's KDF dst.
@@ -33,12 +42,27 @@ const (
 	// ExportKey is the export key's KDF dst.
 	ExportKey = "ExportKey"
 
+	// EncryptionPad is the KDF dst for the pad encrypting the client's private key in the external envelope mode.
+	EncryptionPad = "Pad"
+
+	// AppDataPad is the KDF dst for the pad encrypting the application data in the envelope.
+	AppDataPad = "AppDataPad"
+
+	// EnvelopeAEADKey is the KDF dst of the key sealing the envelope with an AEAD.
+	EnvelopeAEADKey = "EnvelopeAEADKey"
+
 	// MaskingKey is the masking key's creation KDF dst.
 	MaskingKey = "MaskingKey"
 
+	// ExportKeyDerive is the DST prefix for application keys derived from the export key.
+	ExportKeyDerive = "OPAQUE-ExportKeyDerive-"
+
 	// DerivePrivateKey is the client's private key hash-to-scalar dst.
 	DerivePrivateKey = "OPAQUE-DeriveAuthKeyPair"
 
+	// DeriveDiffieHellmanKeyPair is the client's private key hash-to-scalar dst in RFC 9807.
+	DeriveDiffieHellmanKeyPair = "OPAQUE-DeriveDiffieHellmanKeyPair"
+
 	// ExpandPrivateKey is the client's private key seed KDF dst.
 	ExpandPrivateKey = "PrivateKey"
 
@@ -47,6 +71,33 @@ const (
 	// VersionTag indicates the protocol RFC identifier for the AKE transcript prefix.
 	VersionTag = "RFCXXXX"
 
+	// VersionTagV1 is the AKE transcript prefix of RFC 9807.
+	VersionTagV1 = "OPAQUEv1-"
+
+	// VersionTagStandalone is the AKE transcript prefix of the standalone 3DH AKE.
+	VersionTagStandalone = "3DH-RFCXXXX"
+
+	// ExporterSecret is the dst of the exporter secret derived from the session secret.
+	ExporterSecret = "ExporterSecret"
+
+	// Exporter is the dst of the keying material expanded by the exporter.
+	Exporter = "exporter"
+
+	// ReauthMacKey is the dst of the MAC key of the re-authentication flow.
+	ReauthMacKey = "ReauthMacKey"
+
+	// ReauthTranscript prefixes the transcript of the re-authentication flow.
+	ReauthTranscript = "OPAQUE-Reauth"
+
+	// SecondFactor prefixes the second-factor evidence bound into the configuration context.
+	SecondFactor = "OPAQUE-SecondFactor"
+
+	// ChannelBinding prefixes the transport channel binding in the AKE transcript.
+	ChannelBinding = "ChannelBinding"
+
+	// ConfigurationCommitment prefixes the fingerprint of the configuration in the AKE transcript.
+	ConfigurationCommitment = "ConfigurationCommitment"
+
 	// LabelPrefix is the 3DH secret KDF dst prefix.
 	LabelPrefix = "OPAQUE-"
 
@@ -62,13 +113,73 @@ const (
 	// MacClient is 3DH server's MAC key KDF dst.
 	MacClient = "ClientMAC"
 
+	// HandshakeEncryption is the KDF dst of the key encrypting the server's application message in KE2.
+	HandshakeEncryption = "HandshakeKey"
+
+	// KeyConfirmation is the KDF dst of the server's key confirmation MAC key.
+	KeyConfirmation = "KeyConfirmation"
+
+	// EncryptionPadInfo is the KDF dst of the pad encrypting the server's application message in KE2.
+	EncryptionPadInfo = "EncryptionPad"
+
+	// DHKEM is the shared secret KDF dst of the KEM used in the KEM-based AKE.
+	DHKEM = "OPAQUE-DHKEM"
+
+	// HMQVClient is the hash-to-scalar dst of the client's HMQV exponent.
+	HMQVClient = "OPAQUE-HMQV-ClientExponent"
+
+	// HMQVServer is the hash-to-scalar dst of the server's HMQV exponent.
+	HMQVServer = "OPAQUE-HMQV-ServerExponent"
+
+	// IdentityHidingKE1 is the KDF dst of the key sealing the client identity sent with KE1.
+	IdentityHidingKE1 = "OPAQUE-IdentityHiding-KE1"
+
+	// IdentityHidingKE3 is the KDF dst of the key sealing the client identity sent with KE3.
+	IdentityHidingKE3 = "OPAQUE-IdentityHiding-KE3"
+
+	// RandomScalar is the hash-to-scalar dst of the random scalars.
+	RandomScalar = "OPAQUE-RandomScalar"
+
+	// Fingerprint is the hash prefix of the fingerprints of the redacted values.
+	Fingerprint = "OPAQUE-Fingerprint"
+
+	// SelfTest is the KDF salt of the fixed inputs of the self-test.
+	SelfTest = "OPAQUE-SelfTest"
+
+	// Hedged is the hash-to-scalar dst of the hedged ephemeral scalars.
+	Hedged = "OPAQUE-Hedged"
+
+	// HedgedNonce is the KDF dst of the hedged AKE nonces.
+	HedgedNonce = "HedgedNonce"
+
+	// HedgedEphemeralKey is the KDF dst of the hedged AKE ephemeral private keys.
+	HedgedEphemeralKey = "HedgedEphemeralKey"
+
+	// HedgedKEMSeed is the KDF dst of the hedged seeds of the client's KEM decapsulation keys.
+	HedgedKEMSeed = "HedgedKEMSeed"
+
+	// HedgedStaticKeyBlind is the KDF dst of the hedged blinds of the server's long-term private key.
+	HedgedStaticKeyBlind = "HedgedStaticKeyBlind"
+
+	// HedgedEncapsulation is the KDF dst of the hedged encapsulation scalars of the KEM-based AKE.
+	HedgedEncapsulation = "HedgedEncapsulation"
+
 	// Client tags.
 
+	// HedgedBlind is the KDF dst of the client's hedged OPRF blind.
+	HedgedBlind = "HedgedBlind"
+
+	// HedgedEnvelopeNonce is the KDF dst of the client's hedged envelope nonce.
+	HedgedEnvelopeNonce = "HedgedEnvelopeNonce"
+
 	// CredentialResponsePad is the masking keys KDF dst to expand to the input.
 	CredentialResponsePad = "CredentialResponsePad"
 
 	// Server tags.
 
+	// HedgedMaskingNonce is the KDF dst of the server's hedged masking nonce.
+	HedgedMaskingNonce = "HedgedMaskingNonce"
+
 	// ExpandOPRF is the server's OPRF key seed KDF dst.
 	ExpandOPRF = "OprfKey"
 
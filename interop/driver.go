@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package interop
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/bytemare/opaque/vectors"
+)
+
+// Driver sends requests to a driver, e.g. an external implementation's process, and is safe for concurrent use.
+type Driver struct {
+	Name string
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+	decoder *json.Decoder
+	closer  io.Closer
+	cmd     *exec.Cmd
+	id      uint64
+}
+
+// NewDriver returns a Driver writing its requests to w and reading the responses from r. Close closes w.
+func NewDriver(name string, r io.Reader, w io.WriteCloser) *Driver {
+	return &Driver{
+		Name:    name,
+		encoder: json.NewEncoder(w),
+		decoder: json.NewDecoder(r),
+		closer:  w,
+	}
+}
+
+// Start starts the driver command, whose standard error is the process', and returns its Driver. Close waits for the
+// command to exit.
+func Start(name, command string, args ...string) (*Driver, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Stderr = os.Stderr
+
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting the %s driver: %w", name, err)
+	}
+
+	d := NewDriver(name, r, w)
+	d.cmd = cmd
+
+	return d, nil
+}
+
+// Local returns a Driver of this implementation, served in-process by Serve.
+func Local() *Driver {
+	requests, requestWriter := io.Pipe()
+	responses, responseWriter := io.Pipe()
+
+	go func() {
+		_ = responseWriter.CloseWithError(Serve(requests, responseWriter))
+	}()
+
+	return NewDriver(Implementation, responses, requestWriter)
+}
+
+// Close ends the driver's input, and waits for its command to exit, if any.
+func (d *Driver) Close() error {
+	err := d.closer.Close()
+
+	if d.cmd != nil {
+		if werr := d.cmd.Wait(); err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+func (d *Driver) call(req *Request) (*Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.id++
+	req.ID = d.id
+
+	if err := d.encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("%s: sending %s: %w", d.Name, req.Op, err)
+	}
+
+	var resp Response
+	if err := d.decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("%s: reading the response to %s: %w", d.Name, req.Op, err)
+	}
+
+	if resp.ID != req.ID {
+		return nil, fmt.Errorf("%s: %w: id %d, want %d", d.Name, errResponseID, resp.ID, req.ID)
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s: %w: %s: %s", d.Name, ErrDriver, req.Op, resp.Error)
+	}
+
+	return &resp, nil
+}
+
+// Configure sets the configuration of the driver's subsequent operations, and returns the name of its implementation.
+func (d *Driver) Configure(config *vectors.Config) (string, error) {
+	resp, err := d.call(&Request{Op: Configure, Config: config})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Implementation, nil
+}
+
+// Call runs the operation in the session with the arguments, and returns its results.
+func (d *Driver) Call(op Op, session string, args Values) (Values, error) {
+	resp, err := d.call(&Request{Op: op, Session: session, Args: args})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Results, nil
+}
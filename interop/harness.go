@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package interop
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/vectors"
+)
+
+// Parameters are the inputs of a Handshake. A nil Password or CredentialIdentifier defaults to a fixed value, and nil
+// identities are omitted, so that they default to the public keys.
+type Parameters struct {
+	Password             []byte
+	CredentialIdentifier []byte
+	ClientIdentity       []byte
+	ServerIdentity       []byte
+}
+
+// Transcript holds the messages and keys of a Handshake.
+type Transcript struct {
+	RegistrationRequest  []byte
+	RegistrationResponse []byte
+	RegistrationRecord   []byte
+	KE1                  []byte
+	KE2                  []byte
+	KE3                  []byte
+	ExportKey            []byte
+	SessionKey           []byte
+}
+
+// handshake holds the state of a Handshake.
+type handshake struct {
+	client, server *Driver
+	deserializer   *opaque.Deserializer
+	identities     Values
+	password       Values
+}
+
+// Handshake runs a registration and a login between the client and server drivers in the configuration, with a key
+// pair and an OPRF seed generated by this implementation and only given to the server. Each message must pass this
+// implementation's deserializer and serialize back to the same bytes, and the export and session keys of both ends
+// must match, or it returns an error wrapping ErrMismatch.
+func Handshake(config *vectors.Config, client, server *Driver, p *Parameters) (*Transcript, error) {
+	conf, err := config.Configuration()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &handshake{
+		client:     client,
+		server:     server,
+		identities: Values{},
+		password:   Values{"password": defaultTo(p.Password, "password")},
+	}
+	if h.deserializer, err = conf.Deserializer(); err != nil {
+		return nil, err
+	}
+
+	for _, d := range []*Driver{client, server} {
+		if _, err = d.Configure(config); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.ClientIdentity != nil {
+		h.identities["client_identity"] = p.ClientIdentity
+	}
+
+	if p.ServerIdentity != nil {
+		h.identities["server_identity"] = p.ServerIdentity
+	}
+
+	sk, pk, err := conf.KeyGen()
+	if err != nil {
+		return nil, err
+	}
+
+	oprfSeed, err := conf.GenerateOPRFSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := Values{
+		"server_private_key":    sk,
+		"server_public_key":     pk,
+		"oprf_seed":             oprfSeed,
+		"credential_identifier": defaultTo(p.CredentialIdentifier, "credential identifier"),
+	}
+
+	t := &Transcript{}
+	if err = h.registration(t, keys); err != nil {
+		return nil, fmt.Errorf("registration: %w", err)
+	}
+
+	if err = h.login(t, keys); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+
+	return t, nil
+}
+
+func defaultTo(value []byte, s string) []byte {
+	if value == nil {
+		return []byte(s)
+	}
+
+	return value
+}
+
+// with returns the union of the values.
+func with(values ...Values) Values {
+	union := Values{}
+
+	for _, v := range values {
+		for name, value := range v {
+			union[name] = value
+		}
+	}
+
+	return union
+}
+
+// call runs the operation on the driver, and checks the message it returns.
+func (h *handshake) call(d *Driver, op Op, session string, args Values, typ opaque.MessageType,
+	message string,
+) (Values, error) {
+	results, err := d.Call(op, session, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.check(d, typ, message, results[message]); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// check returns an error wrapping ErrMismatch if the message of the driver doesn't pass this implementation's
+// deserializer, or doesn't serialize back to the same bytes.
+func (h *handshake) check(d *Driver, typ opaque.MessageType, name string, encoded []byte) error {
+	if err := h.deserializer.Check(typ, encoded); err != nil {
+		return fmt.Errorf("%w: %s of %s: %v", ErrMismatch, name, d.Name, err)
+	}
+
+	var (
+		m   interface{ Serialize() []byte }
+		err error
+	)
+
+	switch typ {
+	case opaque.RegistrationRequestMessage:
+		m, err = h.deserializer.RegistrationRequest(encoded)
+	case opaque.RegistrationResponseMessage:
+		m, err = h.deserializer.RegistrationResponse(encoded)
+	case opaque.RegistrationRecordMessage:
+		m, err = h.deserializer.RegistrationRecord(encoded)
+	case opaque.KE1Message:
+		m, err = h.deserializer.KE1(encoded)
+	case opaque.KE2Message:
+		m, err = h.deserializer.KE2(encoded)
+	default:
+		m, err = h.deserializer.KE3(encoded)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s of %s: %v", ErrMismatch, name, d.Name, err)
+	}
+
+	if !bytes.Equal(m.Serialize(), encoded) {
+		return fmt.Errorf("%w: %s of %s doesn't serialize back to the same bytes", ErrMismatch, name, d.Name)
+	}
+
+	return nil
+}
+
+func (h *handshake) registration(t *Transcript, keys Values) error {
+	r1, err := h.call(h.client, ClientRegistrationStart, "registration", h.password,
+		opaque.RegistrationRequestMessage, "registration_request")
+	if err != nil {
+		return err
+	}
+
+	r2, err := h.call(h.server, ServerRegistrationResponse, "", with(keys, r1), opaque.RegistrationResponseMessage,
+		"registration_response")
+	if err != nil {
+		return err
+	}
+
+	r3, err := h.call(h.client, ClientRegistrationFinish, "registration", with(h.identities, r2),
+		opaque.RegistrationRecordMessage, "registration_record")
+	if err != nil {
+		return err
+	}
+
+	t.RegistrationRequest = r1["registration_request"]
+	t.RegistrationResponse = r2["registration_response"]
+	t.RegistrationRecord = r3["registration_record"]
+	t.ExportKey = r3["export_key"]
+
+	return nil
+}
+
+func (h *handshake) login(t *Transcript, keys Values) error {
+	ke1, err := h.call(h.client, ClientLoginStart, "login", h.password, opaque.KE1Message, "ke1")
+	if err != nil {
+		return err
+	}
+
+	ke2, err := h.call(h.server, ServerLoginStart, "login",
+		with(keys, h.identities, ke1, Values{"registration_record": t.RegistrationRecord}), opaque.KE2Message, "ke2")
+	if err != nil {
+		return err
+	}
+
+	ke3, err := h.call(h.client, ClientLoginFinish, "login", with(h.identities, ke2), opaque.KE3Message, "ke3")
+	if err != nil {
+		return err
+	}
+
+	finish, err := h.server.Call(ServerLoginFinish, "login", Values{"ke3": ke3["ke3"]})
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(ke3["export_key"], t.ExportKey) {
+		return fmt.Errorf("%w: the export keys of the registration and the login differ", ErrMismatch)
+	}
+
+	if len(ke3["session_key"]) == 0 || !bytes.Equal(ke3["session_key"], finish["session_key"]) {
+		return fmt.Errorf("%w: the session keys of %s and %s differ", ErrMismatch, h.client.Name, h.server.Name)
+	}
+
+	t.KE1, t.KE2, t.KE3, t.SessionKey = ke1["ke1"], ke2["ke2"], ke3["ke3"], ke3["session_key"]
+
+	return nil
+}
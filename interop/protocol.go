@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package interop runs OPAQUE handshakes between this implementation and others, e.g. opaque-ke in Rust or the
+// TypeScript libraries, to catch wire-format drift early.
+//
+// Each implementation is wrapped in a driver: a process reading requests from its stdin and writing responses to its
+// stdout, one JSON object per line. A request names an operation, the client or server session it applies to, and
+// its arguments, and the response carries the results or an error:
+//
+//	{"id":1,"op":"configure","config":{"Group":"ristretto255","Hash":"SHA512",...}}
+//	{"id":1,"implementation":"bytemare/opaque"}
+//	{"id":2,"op":"client_login_start","session":"a","args":{"password":"70617373776f7264"}}
+//	{"id":2,"results":{"ke1":"..."}}
+//
+// The configuration is given in the format of the draft's test vectors, and the arguments and results are hex
+// encoded. The operations and their arguments are the Op constants. The driver of this implementation is Serve, run
+// as a command by cmd/opaque-driver, and Handshake runs a registration and a login between a client driver and a
+// server driver, checking the messages against this implementation's deserializers.
+package interop
+
+import (
+	"errors"
+
+	"github.com/bytemare/opaque/vectors"
+)
+
+var (
+	// ErrDriver is returned when a driver answers a request with an error.
+	ErrDriver = errors.New("driver error")
+
+	// ErrMismatch is returned when the implementations disagree on a message or a key.
+	ErrMismatch = errors.New("interoperability mismatch")
+
+	errResponseID = errors.New("response doesn't match the request")
+	errNoConfig   = errors.New("driver is not configured")
+	errSession    = errors.New("unknown session")
+	errOp         = errors.New("unknown operation")
+	errArgument   = errors.New("missing argument")
+)
+
+// Op identifies an operation of the driver protocol.
+type Op string
+
+const (
+	// Configure sets the configuration of the subsequent operations, and discards the sessions.
+	// Results: none, with the name of the implementation in the response.
+	Configure Op = "configure"
+
+	// ClientRegistrationStart starts the registration of the client session.
+	// Arguments: password. Results: registration_request.
+	ClientRegistrationStart Op = "client_registration_start"
+
+	// ServerRegistrationResponse answers a registration request.
+	// Arguments: registration_request, server_public_key, credential_identifier, oprf_seed.
+	// Results: registration_response.
+	ServerRegistrationResponse Op = "server_registration_response"
+
+	// ClientRegistrationFinish finishes the registration of the client session.
+	// Arguments: registration_response, and optionally client_identity and server_identity.
+	// Results: registration_record, export_key.
+	ClientRegistrationFinish Op = "client_registration_finish"
+
+	// ClientLoginStart starts the login of the client session.
+	// Arguments: password. Results: ke1.
+	ClientLoginStart Op = "client_login_start"
+
+	// ServerLoginStart answers KE1 in the server session.
+	// Arguments: ke1, server_private_key, server_public_key, oprf_seed, credential_identifier, registration_record,
+	// and optionally client_identity and server_identity. Results: ke2.
+	ServerLoginStart Op = "server_login_start"
+
+	// ClientLoginFinish finishes the login of the client session.
+	// Arguments: ke2, and optionally client_identity and server_identity. Results: ke3, session_key, export_key.
+	ClientLoginFinish Op = "client_login_finish"
+
+	// ServerLoginFinish finishes the login of the server session.
+	// Arguments: ke3. Results: session_key.
+	ServerLoginFinish Op = "server_login_finish"
+)
+
+// Values are the named arguments or results of an operation.
+type Values map[string]vectors.HexBytes
+
+// optional returns the value, or nil if it's absent or empty, for the optional arguments like the identities.
+func (v Values) optional(name string) []byte {
+	if len(v[name]) == 0 {
+		return nil
+	}
+
+	return v[name]
+}
+
+// Request is a request to a driver.
+type Request struct {
+	Config  *vectors.Config `json:"config,omitempty"`
+	Args    Values          `json:"args,omitempty"`
+	Op      Op              `json:"op"`
+	Session string          `json:"session,omitempty"`
+	ID      uint64          `json:"id"`
+}
+
+// Response is the response of a driver to the request of the same ID.
+type Response struct {
+	Results        Values `json:"results,omitempty"`
+	Implementation string `json:"implementation,omitempty"`
+	Error          string `json:"error,omitempty"`
+	ID             uint64 `json:"id"`
+}
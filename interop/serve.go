@@ -0,0 +1,307 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package interop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bytemare/opaque"
+)
+
+// Implementation is the name of this implementation in the driver protocol.
+const Implementation = "bytemare/opaque"
+
+// Serve runs the driver of this implementation, answering the requests read from r on w until r ends. It returns an
+// error if a request can't be decoded or a response can't be written, and answers the failed operations with their
+// error.
+func Serve(r io.Reader, w io.Writer) error {
+	decoder, encoder := json.NewDecoder(r), json.NewEncoder(w)
+	d := &driver{}
+
+	for {
+		var req Request
+		if err := decoder.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("decoding a request: %w", err)
+		}
+
+		resp := &Response{ID: req.ID}
+
+		results, err := d.handle(&req)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+
+		if req.Op == Configure && err == nil {
+			resp.Implementation = Implementation
+		}
+
+		resp.Results = results
+
+		if err = encoder.Encode(resp); err != nil {
+			return fmt.Errorf("encoding a response: %w", err)
+		}
+	}
+}
+
+// driver holds the configuration and the sessions of Serve.
+type driver struct {
+	conf    *opaque.Configuration
+	clients map[string]*opaque.Client
+	servers map[string]*opaque.Server
+}
+
+// arguments gets the arguments of a request, and keeps the first missing one as error.
+type arguments struct {
+	values Values
+	err    error
+}
+
+func (a *arguments) get(name string) []byte {
+	v, ok := a.values[name]
+	if !ok && a.err == nil {
+		a.err = fmt.Errorf("%w: %s", errArgument, name)
+	}
+
+	return v
+}
+
+func (d *driver) handle(req *Request) (Values, error) {
+	if req.Op == Configure {
+		return nil, d.configure(req)
+	}
+
+	if d.conf == nil {
+		return nil, errNoConfig
+	}
+
+	args := &arguments{values: req.Args}
+
+	switch req.Op {
+	case ClientRegistrationStart, ClientLoginStart:
+		return d.clientStart(req.Op, req.Session, args)
+	case ServerRegistrationResponse:
+		return d.registrationResponse(args)
+	case ClientRegistrationFinish:
+		return d.registrationFinish(req.Session, args)
+	case ServerLoginStart:
+		return d.serverLoginStart(req.Session, args)
+	case ClientLoginFinish:
+		return d.clientLoginFinish(req.Session, args)
+	case ServerLoginFinish:
+		return d.serverLoginFinish(req.Session, args)
+	default:
+		return nil, fmt.Errorf("%w: %q", errOp, req.Op)
+	}
+}
+
+func (d *driver) configure(req *Request) error {
+	if req.Config == nil {
+		return fmt.Errorf("%w: config", errArgument)
+	}
+
+	conf, err := req.Config.Configuration()
+	if err != nil {
+		return err
+	}
+
+	d.conf = conf
+	d.clients = make(map[string]*opaque.Client)
+	d.servers = make(map[string]*opaque.Server)
+
+	return nil
+}
+
+func (d *driver) clientStart(op Op, session string, args *arguments) (Values, error) {
+	password := args.get("password")
+	if args.err != nil {
+		return nil, args.err
+	}
+
+	client, err := d.conf.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	d.clients[session] = client
+
+	if op == ClientLoginStart {
+		return Values{"ke1": client.LoginInit(password).Serialize()}, nil
+	}
+
+	return Values{"registration_request": client.RegistrationInit(password).Serialize()}, nil
+}
+
+func (d *driver) client(session string) (*opaque.Client, error) {
+	client, ok := d.clients[session]
+	if !ok {
+		return nil, fmt.Errorf("%w: client %q", errSession, session)
+	}
+
+	return client, nil
+}
+
+func (d *driver) registrationResponse(args *arguments) (Values, error) {
+	encoded, pk := args.get("registration_request"), args.get("server_public_key")
+	credentialIdentifier, oprfSeed := args.get("credential_identifier"), args.get("oprf_seed")
+
+	if args.err != nil {
+		return nil, args.err
+	}
+
+	server, err := d.conf.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := server.Deserialize.RegistrationRequest(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := server.RegistrationResponse(request, pks, credentialIdentifier, oprfSeed)
+	if err != nil {
+		return nil, err
+	}
+
+	return Values{"registration_response": response.Serialize()}, nil
+}
+
+func (d *driver) registrationFinish(session string, args *arguments) (Values, error) {
+	encoded := args.get("registration_response")
+	if args.err != nil {
+		return nil, args.err
+	}
+
+	client, err := d.client(session)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Deserialize.RegistrationResponse(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	record, exportKey, err := client.RegistrationFinalize(response, args.values.optional("client_identity"),
+		args.values.optional("server_identity"))
+	if err != nil {
+		return nil, err
+	}
+
+	delete(d.clients, session)
+
+	return Values{"registration_record": record.Serialize(), "export_key": []byte(exportKey)}, nil
+}
+
+func (d *driver) serverLoginStart(session string, args *arguments) (Values, error) {
+	encodedKE1, encodedRecord := args.get("ke1"), args.get("registration_record")
+	sk, pk, oprfSeed := args.get("server_private_key"), args.get("server_public_key"), args.get("oprf_seed")
+	credentialIdentifier := args.get("credential_identifier")
+
+	if args.err != nil {
+		return nil, args.err
+	}
+
+	server, err := d.conf.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	ke1, err := server.Deserialize.KE1(encodedKE1)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := server.Deserialize.RegistrationRecord(encodedRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	ke2, err := server.LoginInit(ke1, args.values.optional("server_identity"), sk, pk, oprfSeed,
+		&opaque.ClientRecord{
+			CredentialIdentifier: credentialIdentifier,
+			ClientIdentity:       args.values.optional("client_identity"),
+			RegistrationRecord:   record,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	d.servers[session] = server
+
+	return Values{"ke2": ke2.Serialize()}, nil
+}
+
+func (d *driver) clientLoginFinish(session string, args *arguments) (Values, error) {
+	encoded := args.get("ke2")
+	if args.err != nil {
+		return nil, args.err
+	}
+
+	client, err := d.client(session)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(d.clients, session)
+
+	ke2, err := client.Deserialize.KE2(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	ke3, exportKey, err := client.LoginFinish(args.values.optional("client_identity"),
+		args.values.optional("server_identity"), ke2)
+	if err != nil {
+		return nil, err
+	}
+
+	return Values{
+		"ke3":         ke3.Serialize(),
+		"session_key": client.SessionKey(),
+		"export_key":  []byte(exportKey),
+	}, nil
+}
+
+func (d *driver) serverLoginFinish(session string, args *arguments) (Values, error) {
+	encoded := args.get("ke3")
+	if args.err != nil {
+		return nil, args.err
+	}
+
+	server, ok := d.servers[session]
+	if !ok {
+		return nil, fmt.Errorf("%w: server %q", errSession, session)
+	}
+
+	delete(d.servers, session)
+
+	ke3, err := server.Deserialize.KE3(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		return nil, err
+	}
+
+	return Values{"session_key": server.SessionKey()}, nil
+}
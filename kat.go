@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// errKnownAnswer indicates that a known answer is invalid, or doesn't apply to the Client or Server it's set on.
+var errKnownAnswer = errors.New("invalid known answer")
+
+// KnownAnswers are the values that are otherwise random in the protocol, fixed to run byte-exact known-answer tests,
+// e.g. against test vectors. A nil field keeps its value random. They weaken the protocol, and must only be set in
+// tests.
+type KnownAnswers struct {
+	// Blind is the client's OPRF blind, for its registration and login.
+	Blind []byte
+
+	// EnvelopeNonce is the nonce of the envelope sealed by the client at registration.
+	EnvelopeNonce []byte
+
+	// MaskingNonce is the nonce masking the server's credential response.
+	MaskingNonce []byte
+
+	// EphemeralSecretKey is the AKE private key share of the client or the server.
+	EphemeralSecretKey []byte
+
+	// Nonce is the AKE nonce of the client or the server.
+	Nonce []byte
+}
+
+// knownAnswers are the decoded KnownAnswers.
+type knownAnswers struct {
+	blind         *group.Scalar
+	esk           *group.Scalar
+	envelopeNonce []byte
+	maskingNonce  []byte
+	nonce         []byte
+}
+
+func decodeKnownAnswer(g group.Group, field string, encoded []byte) (*group.Scalar, error) {
+	if encoded == nil {
+		return nil, nil
+	}
+
+	if len(encoded) != encoding.ScalarLength[g] {
+		return nil, fmt.Errorf("%w: %s length", errKnownAnswer, field)
+	}
+
+	s, err := g.NewScalar().Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errKnownAnswer, field, err)
+	}
+
+	if s.IsZero() {
+		return nil, fmt.Errorf("%w: %s is zero", errKnownAnswer, field)
+	}
+
+	return s, nil
+}
+
+func checkKnownNonce(conf *internal.Configuration, field string, nonce []byte) ([]byte, error) {
+	if nonce != nil && len(nonce) != conf.NonceLen {
+		return nil, fmt.Errorf("%w: %s length", errKnownAnswer, field)
+	}
+
+	return nonce, nil
+}
+
+func newKnownAnswers(conf *internal.Configuration, k *KnownAnswers) (*knownAnswers, error) {
+	var (
+		kat knownAnswers
+		err error
+	)
+
+	if kat.blind, err = decodeKnownAnswer(conf.OPRF.Group(), "blind", k.Blind); err != nil {
+		return nil, err
+	}
+
+	if kat.esk, err = decodeKnownAnswer(conf.Group, "ephemeral secret key", k.EphemeralSecretKey); err != nil {
+		return nil, err
+	}
+
+	for _, n := range []struct {
+		dst   *[]byte
+		field string
+		nonce []byte
+	}{
+		{&kat.envelopeNonce, "envelope nonce", k.EnvelopeNonce},
+		{&kat.maskingNonce, "masking nonce", k.MaskingNonce},
+		{&kat.nonce, "nonce", k.Nonce},
+	} {
+		if *n.dst, err = checkKnownNonce(conf, n.field, n.nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	return &kat, nil
+}
+
+// SetKnownAnswers fixes the client's blind, envelope nonce, and AKE ephemeral key share and nonce, for the subsequent
+// registrations and logins. A nil k, or Wipe, restores the random values. It returns an error if a value is invalid,
+// or if the masking nonce, which is the server's, is set.
+func (c *Client) SetKnownAnswers(k *KnownAnswers) error {
+	if k == nil {
+		c.kat = nil
+		return nil
+	}
+
+	if k.MaskingNonce != nil {
+		return fmt.Errorf("%w: the masking nonce is the server's", errKnownAnswer)
+	}
+
+	kat, err := newKnownAnswers(c.conf, k)
+	if err != nil {
+		return err
+	}
+
+	c.kat = kat
+
+	return nil
+}
+
+// SetKnownAnswers fixes the server's masking nonce, and AKE ephemeral key share and nonce, for the subsequent logins.
+// A nil k, or Wipe, restores the random values. It returns an error if a value is invalid, or if the blind or the
+// envelope nonce, which are the client's, are set.
+func (s *Server) SetKnownAnswers(k *KnownAnswers) error {
+	if k == nil {
+		s.kat = nil
+		return nil
+	}
+
+	if k.Blind != nil || k.EnvelopeNonce != nil {
+		return fmt.Errorf("%w: the blind and the envelope nonce are the client's", errKnownAnswer)
+	}
+
+	kat, err := newKnownAnswers(s.conf, k)
+	if err != nil {
+		return err
+	}
+
+	s.kat = kat
+
+	return nil
+}
+
+// setAkeValues gives the known AKE ephemeral key share and nonce, if any, to the AKE.
+func (k *knownAnswers) setAkeValues(g group.Group, set func(group.Group, *group.Scalar, []byte) *group.Point) {
+	if k == nil {
+		return
+	}
+
+	var esk *group.Scalar
+	if k.esk != nil {
+		esk = k.esk.Copy()
+	}
+
+	set(g, esk, k.nonce)
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// OPRFKeyCache is a bounded, least-recently-used cache of the per-credential OPRF keys derived from the server's OPRF
+// seed, saving the derivation on each login under heavy load. It is safe for concurrent use, and can be shared by the
+// servers of a Configuration using the same OPRF seed. Keys are held encoded, and are zeroed when they are evicted or
+// purged.
+type OPRFKeyCache struct {
+	entries  map[string]*list.Element
+	order    *list.List
+	seed     []byte
+	capacity int
+	mu       sync.Mutex
+}
+
+type oprfKeyEntry struct {
+	id  string
+	key []byte
+}
+
+// NewOPRFKeyCache returns an OPRFKeyCache holding at most capacity keys, and at least one.
+func NewOPRFKeyCache(capacity int) *OPRFKeyCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &OPRFKeyCache{
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+		capacity: capacity,
+	}
+}
+
+// Len returns the number of cached keys.
+func (c *OPRFKeyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Purge zeroes and drops all cached keys, e.g. after rotating the OPRF seed.
+func (c *OPRFKeyCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purge()
+}
+
+func (c *OPRFKeyCache) purge() {
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		internal.Zero(e.Value.(*oprfKeyEntry).key)
+	}
+
+	internal.Zero(c.seed)
+	c.seed = nil
+	c.entries = make(map[string]*list.Element, c.capacity)
+	c.order.Init()
+}
+
+// get returns the cached key of the credential identifier under the seed. The cache is purged if the seed changed.
+func (c *OPRFKeyCache) get(oprfSeed, credentialIdentifier []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seed != nil && !internal.ConstantTimeEqual(c.seed, oprfSeed) {
+		c.purge()
+	}
+
+	e, ok := c.entries[string(credentialIdentifier)]
+	if !ok {
+		return nil
+	}
+
+	c.order.MoveToFront(e)
+
+	return append([]byte(nil), e.Value.(*oprfKeyEntry).key...)
+}
+
+// add caches the key of the credential identifier, evicting the least recently used key if the cache is full.
+func (c *OPRFKeyCache) add(oprfSeed, credentialIdentifier, key []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seed == nil {
+		c.seed = append([]byte(nil), oprfSeed...)
+	} else if !internal.ConstantTimeEqual(c.seed, oprfSeed) {
+		return
+	}
+
+	if _, ok := c.entries[string(credentialIdentifier)]; ok {
+		return
+	}
+
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		entry := c.order.Remove(oldest).(*oprfKeyEntry)
+		internal.Zero(entry.key)
+		delete(c.entries, entry.id)
+	}
+
+	entry := &oprfKeyEntry{id: string(credentialIdentifier), key: key}
+	c.entries[entry.id] = c.order.PushFront(entry)
+}
+
+// SetOPRFKeyCache sets the cache of the OPRF keys derived from the OPRF seed. A nil cache disables it.
+func (s *Server) SetOPRFKeyCache(cache *OPRFKeyCache) {
+	s.oprfKeys = cache
+}
+
+// cachedOPRFKey returns the OPRF key of the credential identifier from the cache, or nil if it's not cached.
+func (s *Server) cachedOPRFKey(oprfSeed, credentialIdentifier []byte) *group.Scalar {
+	encoded := s.oprfKeys.get(oprfSeed, credentialIdentifier)
+	if encoded == nil {
+		return nil
+	}
+
+	defer internal.Zero(encoded)
+
+	ku, err := s.conf.OPRF.Group().NewScalar().Decode(encoded)
+	if err != nil {
+		return nil
+	}
+
+	return ku
+}
+
+func (s *Server) cacheOPRFKey(oprfSeed, credentialIdentifier []byte, ku *group.Scalar) {
+	s.oprfKeys.add(oprfSeed, credentialIdentifier, encoding.SerializeScalar(ku, s.conf.OPRF.Group()))
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+var (
+	// ErrKeyPairMismatch indicates that the server's public key doesn't correspond to its private key.
+	ErrKeyPairMismatch = errors.New("server public key doesn't match the private key")
+
+	// ErrInvalidSeedLength indicates that a key derivation seed doesn't have the required length.
+	ErrInvalidSeedLength = errors.New("invalid seed length")
+
+	// errKeyMaterialMissing happens when using the key material before SetKeyMaterial.
+	errKeyMaterialMissing = errors.New("no server key material: SetKeyMaterial must succeed first")
+)
+
+// SeedLength is the length of the seeds from which DeriveAKEKeyPair derives key pairs.
+const SeedLength = internal.SeedLength
+
+// GenerateAKEKeyPair returns a random key pair in the AKE group of the configuration, as KeyGen does.
+func GenerateAKEKeyPair(c *Configuration) (secretKey, publicKey []byte, err error) {
+	return c.KeyGen()
+}
+
+// DeriveAKEKeyPair deterministically derives a key pair in the AKE group of the configuration from a secret seed of
+// SeedLength bytes, e.g. to reproduce the server's long-term key from a seed held in a key management service. The
+// derivation is the protocol's DeriveDiffieHellmanKeyPair, with the labels of the compatibility mode. The same seed
+// and configuration always give the same key pair, so the seed must be kept as secret as the private key.
+func DeriveAKEKeyPair(c *Configuration, seed []byte) (secretKey, publicKey []byte, err error) {
+	if len(seed) != SeedLength {
+		return nil, nil, ErrInvalidSeedLength
+	}
+
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sk, err := conf.DerivePrivateKey(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encoding.SerializeScalar(sk, conf.Group), encoding.SerializePoint(conf.BaseMult(sk), conf.Group), nil
+}
+
+// keyMaterial holds the server's validated long-term keys.
+type keyMaterial struct {
+	identity  []byte
+	secretKey []byte
+	publicKey []byte
+	pks       *group.Point
+	oprfSeed  []byte
+}
+
+// SetKeyMaterial validates and sets the server's identity, long-term key pair, and OPRF seed, for
+// RegistrationResponseWithKeys and LoginInitWithKeys. It fails fast if a key is malformed or not in the
+// configuration's group, if the public key doesn't correspond to the private key, or if the seed doesn't have the
+// length of the configuration's Hash, instead of later producing KE2 messages that clients can't use. A nil identity
+// defaults to the public key. The inputs are copied, and the copies are zeroed by Wipe.
+func (s *Server) SetKeyMaterial(serverIdentity, serverSecretKey, serverPublicKey, oprfSeed []byte) error {
+	if len(serverIdentity) > maxIdentityLength {
+		return errIdentityLength
+	}
+
+	sks, err := s.Deserialize.DecodeAkePrivateKey(serverSecretKey)
+	if err != nil {
+		return fmt.Errorf("%v: %w", ErrInvalidServerSecretKey, err)
+	}
+
+	pks, err := s.Deserialize.DecodeAkePublicKey(serverPublicKey)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errInvalidServerPK, err)
+	}
+
+	expected := encoding.SerializePoint(s.conf.BaseMult(sks), s.conf.Group)
+	if subtle.ConstantTimeCompare(expected, serverPublicKey) != 1 {
+		return ErrKeyPairMismatch
+	}
+
+	if len(oprfSeed) != s.conf.Hash.Size() {
+		return ErrInvalidOPRFSeedLength
+	}
+
+	var identity []byte
+	if serverIdentity != nil {
+		identity = append(make([]byte, 0, len(serverIdentity)), serverIdentity...)
+	}
+
+	s.wipeKeyMaterial()
+	s.keys = &keyMaterial{
+		identity:  identity,
+		secretKey: append([]byte(nil), serverSecretKey...),
+		publicKey: append([]byte(nil), serverPublicKey...),
+		pks:       pks,
+		oprfSeed:  append([]byte(nil), oprfSeed...),
+	}
+
+	return nil
+}
+
+// wipeKeyMaterial zeroes and drops the key material.
+func (s *Server) wipeKeyMaterial() {
+	if s.keys == nil {
+		return
+	}
+
+	internal.Zero(s.keys.secretKey)
+	internal.Zero(s.keys.oprfSeed)
+	s.keys = nil
+}
+
+// RegistrationResponseWithKeys is like RegistrationResponse, with the key material set with SetKeyMaterial.
+func (s *Server) RegistrationResponseWithKeys(
+	req *message.RegistrationRequest,
+	credentialIdentifier []byte,
+) (*message.RegistrationResponse, error) {
+	if s.keys == nil {
+		return nil, errKeyMaterialMissing
+	}
+
+	return s.RegistrationResponse(req, s.keys.pks, credentialIdentifier, s.keys.oprfSeed)
+}
+
+// LoginInitWithKeys is like LoginInit, with the key material set with SetKeyMaterial.
+func (s *Server) LoginInitWithKeys(ke1 *message.KE1, record *ClientRecord) (*message.KE2, error) {
+	if s.keys == nil {
+		return nil, errKeyMaterialMissing
+	}
+
+	return s.LoginInit(ke1, s.keys.identity, s.keys.secretKey, s.keys.publicKey, s.keys.oprfSeed, record)
+}
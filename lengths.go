@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+// MessageLength returns the length of the serialized messages of the given type in the configuration, e.g. to
+// pre-allocate buffers, bound the reads from the network, or validate a framing. KE1 and KE2 are longer by the 2-byte
+// length prefix and the application message if they carry one.
+func (c *Configuration) MessageLength(t MessageType) (int, error) {
+	d, err := c.Deserializer()
+	if err != nil {
+		return 0, err
+	}
+
+	return d.messageLength(t)
+}
+
+// length returns the length of the messages of the type, or 0 if the configuration is invalid.
+func (c *Configuration) length(t MessageType) int {
+	length, err := c.MessageLength(t)
+	if err != nil {
+		return 0
+	}
+
+	return length
+}
+
+// RegistrationRequestLength returns the length of a RegistrationRequest, or 0 if the configuration is invalid.
+func (c *Configuration) RegistrationRequestLength() int {
+	return c.length(RegistrationRequestMessage)
+}
+
+// RegistrationResponseLength returns the length of a RegistrationResponse, or 0 if the configuration is invalid.
+func (c *Configuration) RegistrationResponseLength() int {
+	return c.length(RegistrationResponseMessage)
+}
+
+// RegistrationRecordLength returns the length of a RegistrationRecord, or 0 if the configuration is invalid.
+func (c *Configuration) RegistrationRecordLength() int {
+	return c.length(RegistrationRecordMessage)
+}
+
+// KE1Length returns the length of a KE1 without an application message, or 0 if the configuration is invalid.
+func (c *Configuration) KE1Length() int {
+	return c.length(KE1Message)
+}
+
+// KE2Length returns the length of a KE2 without an application message, or 0 if the configuration is invalid.
+func (c *Configuration) KE2Length() int {
+	return c.length(KE2Message)
+}
+
+// KE3Length returns the length of a KE3, or 0 if the configuration is invalid.
+func (c *Configuration) KE3Length() int {
+	return c.length(KE3Message)
+}
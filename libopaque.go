@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+// libopaque servers don't derive the OPRF keys of their clients from a seed, but draw a random one at each
+// registration, and store it with the server's private key and the client's registration record, as
+//
+//	oprf_key || server_private_key || registration_record
+//
+// i.e. the Opaque_UserRecord of libopaque. A server in the LibOpaque compatibility mode decodes these records with
+// Deserializer.LibOpaqueRecord and answers their clients with LoginInitWithOPRFKey, so that the clients of libopaque
+// log in without registering again. New clients can be registered the same way, with GenerateOPRFKey and
+// RegistrationResponseWithOPRFKey, or with an OPRF seed like in the other configurations.
+
+var (
+	// ErrInvalidOPRFKey indicates an OPRF key that can't be used.
+	ErrInvalidOPRFKey = errors.New("invalid OPRF key")
+
+	// errLibOpaqueRecordLength happens when a libopaque record is too short to hold its keys.
+	errLibOpaqueRecordLength = errors.New("invalid libopaque record length")
+)
+
+// LibOpaqueRecord is a client record as stored by a libopaque server.
+type LibOpaqueRecord struct {
+	OPRFKey         []byte
+	ServerSecretKey []byte
+	*message.RegistrationRecord
+}
+
+// Serialize returns the byte encoding of the record, as stored by libopaque.
+func (r *LibOpaqueRecord) Serialize() []byte {
+	return encoding.Concat3(r.OPRFKey, r.ServerSecretKey, r.RegistrationRecord.Serialize())
+}
+
+// LibOpaqueRecord decodes a client record stored by a libopaque server.
+func (d *Deserializer) LibOpaqueRecord(record []byte) (*LibOpaqueRecord, error) {
+	nok, nsk := encoding.ScalarLength[d.conf.OPRF.Group()], encoding.ScalarLength[d.conf.Group]
+	if len(record) < nok+nsk {
+		return nil, errLibOpaqueRecordLength
+	}
+
+	if _, err := decodeOPRFKey(d.conf.OPRF.Group(), record[:nok]); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.DecodeAkePrivateKey(record[nok : nok+nsk]); err != nil {
+		return nil, err
+	}
+
+	registration, err := d.RegistrationRecord(record[nok+nsk:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &LibOpaqueRecord{
+		OPRFKey:            record[:nok],
+		ServerSecretKey:    record[nok : nok+nsk],
+		RegistrationRecord: registration,
+	}, nil
+}
+
+func decodeOPRFKey(g group.Group, key []byte) (*group.Scalar, error) {
+	if len(key) != encoding.ScalarLength[g] {
+		return nil, ErrInvalidOPRFKey
+	}
+
+	ku, err := g.NewScalar().Decode(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOPRFKey, err)
+	}
+
+	if ku.IsZero() {
+		return nil, ErrInvalidOPRFKey
+	}
+
+	return ku, nil
+}
+
+// GenerateOPRFKey returns a new random OPRF key for a client, to be stored with its record.
+func (c *Configuration) GenerateOPRFKey() ([]byte, error) {
+	if err := c.verify(); err != nil {
+		return nil, err
+	}
+
+	g := group.Group(c.OPRF)
+
+	ku, err := internal.RandomScalar(g)
+	if err != nil {
+		return nil, err
+	}
+
+	return encoding.SerializeScalar(ku, g), nil
+}
+
+// RegistrationResponseWithOPRFKey is like RegistrationResponse, but evaluates the request under the client's OPRF
+// key, e.g. from GenerateOPRFKey, instead of deriving it from a seed.
+func (s *Server) RegistrationResponseWithOPRFKey(
+	req *message.RegistrationRequest,
+	serverPublicKey *group.Point,
+	oprfKey []byte,
+) (*message.RegistrationResponse, error) {
+	if req == nil || req.BlindedMessage == nil {
+		return nil, errIncompleteMessage
+	}
+
+	if serverPublicKey == nil {
+		return nil, errInvalidServerPK
+	}
+
+	ku, err := decodeOPRFKey(s.conf.OPRF.Group(), oprfKey)
+	if err != nil {
+		return nil, err
+	}
+
+	z, err := s.evaluate(ku, req.BlindedMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message.RegistrationResponse{
+		C:                s.conf.OPRF,
+		G:                s.conf.Group,
+		EvaluatedMessage: z,
+		Pks:              serverPublicKey,
+	}, nil
+}
+
+// LoginInitWithOPRFKey is like LoginInit, but evaluates KE1 under the client's OPRF key, e.g. the one of a
+// LibOpaqueRecord, instead of deriving it from a seed.
+func (s *Server) LoginInitWithOPRFKey(
+	ke1 *message.KE1,
+	serverIdentity, serverSecretKey, serverPublicKey, oprfKey []byte,
+	record *ClientRecord,
+) (*message.KE2, error) {
+	sks, err := s.decodeServerSecretKey(serverSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyInitInput(serverPublicKey, record); err != nil {
+		return nil, err
+	}
+
+	if !completeKE1(ke1) {
+		return nil, errIncompleteMessage
+	}
+
+	ku, err := decodeOPRFKey(s.conf.OPRF.Group(), oprfKey)
+	if err != nil {
+		return nil, err
+	}
+
+	z, err := s.evaluate(ku, ke1.BlindedMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loginInit(ke1, serverIdentity, sks, serverPublicKey, z, record, nil)
+}
@@ -0,0 +1,23 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package message
+
+import "github.com/bytemare/opaque/internal/encoding"
+
+// ConfigurationAdvertisement is the message sent by the server before the handshake, advertising its configuration to
+// the clients.
+type ConfigurationAdvertisement struct {
+	Configuration []byte `json:"configuration"`
+	Fingerprint   []byte `json:"fingerprint"`
+}
+
+// Serialize returns the byte encoding of ConfigurationAdvertisement.
+func (a *ConfigurationAdvertisement) Serialize() []byte {
+	return encoding.Concat(encoding.EncodeVector(a.Configuration), a.Fingerprint)
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package message
+
+import "errors"
+
+var errUnboundMessage = errors.New("message is not bound to a decoder")
+
+// Decoder decodes the serialized messages of a configuration, as does the Deserializer of the opaque package.
+type Decoder interface {
+	RegistrationRequest(registrationRequest []byte) (*RegistrationRequest, error)
+	RegistrationResponse(registrationResponse []byte) (*RegistrationResponse, error)
+	RegistrationRecord(record []byte) (*RegistrationRecord, error)
+	KE1(ke1 []byte) (*KE1, error)
+	KE2(ke2 []byte) (*KE2, error)
+	KE3(ke3 []byte) (*KE3, error)
+	KE4(ke4 []byte) (*KE4, error)
+	ReauthRequest(request []byte) (*ReauthRequest, error)
+	ReauthResponse(response []byte) (*ReauthResponse, error)
+	ReauthFinish(finish []byte) (*ReauthFinish, error)
+	PartialEvaluation(partialEvaluation []byte) (*PartialEvaluation, error)
+}
+
+// Binding binds a message to the Decoder of its configuration, which its UnmarshalBinary method needs since the
+// encoding of a message depends on the configuration. Bind the message before handing it to gob or any other
+// encoding.BinaryUnmarshaler consumer, e.g.:
+//
+//	var ke1 message.KE1
+//	ke1.Bind(deserializer) // e.g. returned by opaque.Configuration.Deserializer
+//	err := gob.NewDecoder(r).Decode(&ke1)
+type Binding struct {
+	decoder Decoder
+}
+
+// Bind sets the Decoder used by UnmarshalBinary.
+func (b *Binding) Bind(d Decoder) {
+	b.decoder = d
+}
+
+// decode copies the input, as required by encoding.BinaryUnmarshaler, and decodes it with the bound Decoder.
+func decode[T any](b Binding, decode func(Decoder, []byte) (*T, error), data []byte) (*T, error) {
+	if b.decoder == nil {
+		return nil, errUnboundMessage
+	}
+
+	return decode(b.decoder, append([]byte(nil), data...))
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of RegistrationRequest.
+func (r *RegistrationRequest) MarshalBinary() ([]byte, error) {
+	return r.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (r *RegistrationRequest) UnmarshalBinary(data []byte) error {
+	m, err := decode(r.Binding, Decoder.RegistrationRequest, data)
+	if err != nil {
+		return err
+	}
+
+	m.Binding = r.Binding
+	*r = *m
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of RegistrationResponse.
+func (r *RegistrationResponse) MarshalBinary() ([]byte, error) {
+	return r.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (r *RegistrationResponse) UnmarshalBinary(data []byte) error {
+	m, err := decode(r.Binding, Decoder.RegistrationResponse, data)
+	if err != nil {
+		return err
+	}
+
+	m.Binding = r.Binding
+	*r = *m
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of RegistrationRecord.
+func (r *RegistrationRecord) MarshalBinary() ([]byte, error) {
+	return r.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (r *RegistrationRecord) UnmarshalBinary(data []byte) error {
+	m, err := decode(r.Binding, Decoder.RegistrationRecord, data)
+	if err != nil {
+		return err
+	}
+
+	m.Binding = r.Binding
+	*r = *m
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of KE1.
+func (m *KE1) MarshalBinary() ([]byte, error) {
+	return m.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (m *KE1) UnmarshalBinary(data []byte) error {
+	ke1, err := decode(m.Binding, Decoder.KE1, data)
+	if err != nil {
+		return err
+	}
+
+	ke1.Binding = m.Binding
+	*m = *ke1
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of KE2.
+func (m *KE2) MarshalBinary() ([]byte, error) {
+	return m.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (m *KE2) UnmarshalBinary(data []byte) error {
+	ke2, err := decode(m.Binding, Decoder.KE2, data)
+	if err != nil {
+		return err
+	}
+
+	ke2.Binding = m.Binding
+	*m = *ke2
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of KE3.
+func (k *KE3) MarshalBinary() ([]byte, error) {
+	return k.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (k *KE3) UnmarshalBinary(data []byte) error {
+	ke3, err := decode(k.Binding, Decoder.KE3, data)
+	if err != nil {
+		return err
+	}
+
+	ke3.Binding = k.Binding
+	*k = *ke3
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of KE4.
+func (k *KE4) MarshalBinary() ([]byte, error) {
+	return k.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (k *KE4) UnmarshalBinary(data []byte) error {
+	ke4, err := decode(k.Binding, Decoder.KE4, data)
+	if err != nil {
+		return err
+	}
+
+	ke4.Binding = k.Binding
+	*k = *ke4
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of ReauthRequest.
+func (r *ReauthRequest) MarshalBinary() ([]byte, error) {
+	return r.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (r *ReauthRequest) UnmarshalBinary(data []byte) error {
+	m, err := decode(r.Binding, Decoder.ReauthRequest, data)
+	if err != nil {
+		return err
+	}
+
+	m.Binding = r.Binding
+	*r = *m
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of ReauthResponse.
+func (r *ReauthResponse) MarshalBinary() ([]byte, error) {
+	return r.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (r *ReauthResponse) UnmarshalBinary(data []byte) error {
+	m, err := decode(r.Binding, Decoder.ReauthResponse, data)
+	if err != nil {
+		return err
+	}
+
+	m.Binding = r.Binding
+	*r = *m
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of ReauthFinish.
+func (r *ReauthFinish) MarshalBinary() ([]byte, error) {
+	return r.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (r *ReauthFinish) UnmarshalBinary(data []byte) error {
+	m, err := decode(r.Binding, Decoder.ReauthFinish, data)
+	if err != nil {
+		return err
+	}
+
+	m.Binding = r.Binding
+	*r = *m
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns the byte encoding of PartialEvaluation.
+func (p *PartialEvaluation) MarshalBinary() ([]byte, error) {
+	return p.Serialize(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with the bound Decoder.
+func (p *PartialEvaluation) UnmarshalBinary(data []byte) error {
+	m, err := decode(p.Binding, Decoder.PartialEvaluation, data)
+	if err != nil {
+		return err
+	}
+
+	m.Binding = p.Binding
+	*p = *m
+
+	return nil
+}
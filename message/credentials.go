@@ -15,14 +15,41 @@ import (
 	"github.com/bytemare/opaque/internal/oprf"
 )
 
+// Serializer is implemented by all the messages, which serialize to their wire encoding.
+type Serializer interface {
+	Serialize() []byte
+}
+
+var (
+	_ Serializer = (*CredentialRequest)(nil)
+	_ Serializer = (*CredentialResponse)(nil)
+	_ Serializer = (*RegistrationRequest)(nil)
+	_ Serializer = (*RegistrationResponse)(nil)
+	_ Serializer = (*RegistrationRecord)(nil)
+	_ Serializer = (*KE1)(nil)
+	_ Serializer = (*KE2)(nil)
+	_ Serializer = (*KE3)(nil)
+	_ Serializer = (*KE4)(nil)
+	_ Serializer = (*ReauthRequest)(nil)
+	_ Serializer = (*ReauthResponse)(nil)
+	_ Serializer = (*ReauthFinish)(nil)
+	_ Serializer = (*PartialEvaluation)(nil)
+	_ Serializer = (*ConfigurationAdvertisement)(nil)
+)
+
 // CredentialRequest represents credential request message.
 type CredentialRequest struct {
 	C              oprf.Ciphersuite
 	BlindedMessage *group.Point `json:"blinded_message"`
 }
 
-// Serialize returns the byte encoding of CredentialRequest.
+// Serialize returns the byte encoding of CredentialRequest, which is empty if c is nil, e.g. in a KE1 without the
+// credential request.
 func (c *CredentialRequest) Serialize() []byte {
+	if c == nil {
+		return nil
+	}
+
 	return c.C.SerializePoint(c.BlindedMessage)
 }
 
@@ -34,7 +61,12 @@ type CredentialResponse struct {
 	MaskedResponse   []byte       `json:"masked_response"`
 }
 
-// Serialize returns the byte encoding of CredentialResponse.
+// Serialize returns the byte encoding of CredentialResponse, which is empty if c is nil, e.g. in a KE2 without the
+// credential response.
 func (c *CredentialResponse) Serialize() []byte {
+	if c == nil {
+		return nil
+	}
+
 	return encoding.Concat3(c.C.SerializePoint(c.EvaluatedMessage), c.MaskingNonce, c.MaskedResponse)
 }
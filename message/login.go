@@ -21,11 +21,35 @@ type KE1 struct {
 	*CredentialRequest
 	NonceU []byte       `json:"client_none"`
 	EpkU   *group.Point `json:"client_ephemeral_pk"`
+
+	// KEMPublicKey is the client's ephemeral KEM public key in the hybrid AKE, and empty otherwise.
+	KEMPublicKey []byte `json:"client_kem_pk,omitempty"`
+
+	// ClientInfo is an optional application message, sent in clear and authenticated by the AKE.
+	ClientInfo []byte `json:"client_info,omitempty"`
+
+	Binding
 }
 
 // Serialize returns the byte encoding of KE1.
 func (m *KE1) Serialize() []byte {
-	return encoding.Concat3(m.CredentialRequest.Serialize(), m.NonceU, encoding.SerializePoint(m.EpkU, m.G))
+	return encoding.Concatenate(
+		m.CredentialRequest.Serialize(),
+		m.NonceU,
+		encoding.SerializePoint(m.EpkU, m.G),
+		m.KEMPublicKey,
+		encodeInfo(m.ClientInfo),
+	)
+}
+
+// encodeInfo returns the encoding of an optional application message appended to KE1 or KE2, which is empty if there
+// is none.
+func encodeInfo(info []byte) []byte {
+	if len(info) == 0 {
+		return nil
+	}
+
+	return encoding.EncodeVector(info)
 }
 
 // KE2 is the second message of the login flow, created by the server and sent to the client.
@@ -34,23 +58,60 @@ type KE2 struct {
 	*CredentialResponse
 	NonceS []byte       `json:"server_nonce"`
 	EpkS   *group.Point `json:"server_ephemeral_pk"`
-	Mac    []byte       `json:"server_mac"`
+
+	// KEMCiphertext is the server's KEM encapsulation in the hybrid AKE, and empty otherwise.
+	KEMCiphertext []byte `json:"server_kem_ciphertext,omitempty"`
+
+	// AuthCiphertext is the server's encapsulation to the client's long-term key in the KEM-based AKE, and empty
+	// otherwise.
+	AuthCiphertext []byte `json:"server_auth_ciphertext,omitempty"`
+	Mac            []byte `json:"server_mac"`
+
+	// EncryptedServerInfo is an optional application message, encrypted under the handshake keys and authenticated
+	// by the server MAC.
+	EncryptedServerInfo []byte `json:"encrypted_server_info,omitempty"`
+
+	Binding
 }
 
 // Serialize returns the byte encoding of KE2.
 func (m *KE2) Serialize() []byte {
 	return encoding.Concat(
 		m.CredentialResponse.Serialize(),
-		encoding.Concat3(m.NonceS, encoding.SerializePoint(m.EpkS, m.G), m.Mac),
+		encoding.Concatenate(
+			m.NonceS,
+			encoding.SerializePoint(m.EpkS, m.G),
+			m.KEMCiphertext,
+			m.AuthCiphertext,
+			m.Mac,
+			encodeInfo(m.EncryptedServerInfo),
+		),
 	)
 }
 
 // KE3 is the third and last message of the login flow, created by the client and sent to the server.
 type KE3 struct {
-	Mac []byte `json:"client_mac"`
+	// AuthCiphertext is the client's encapsulation to the server's long-term key in the KEM-based AKE, and empty
+	// otherwise.
+	AuthCiphertext []byte `json:"client_auth_ciphertext,omitempty"`
+	Mac            []byte `json:"client_mac"`
+
+	Binding
 }
 
 // Serialize returns the byte encoding of KE3.
-func (k KE3) Serialize() []byte {
+func (k *KE3) Serialize() []byte {
+	return encoding.Concat(k.AuthCiphertext, k.Mac)
+}
+
+// KE4 is the optional key confirmation message, created by the server after verifying KE3 and sent to the client.
+type KE4 struct {
+	Mac []byte `json:"server_confirmation_mac"`
+
+	Binding
+}
+
+// Serialize returns the byte encoding of KE4.
+func (k *KE4) Serialize() []byte {
 	return k.Mac
 }
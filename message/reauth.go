@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package message
+
+import "github.com/bytemare/opaque/internal/encoding"
+
+// ReauthRequest is the first message of the re-authentication flow, created by the client and sent to the server.
+type ReauthRequest struct {
+	Nonce []byte `json:"client_nonce"`
+
+	Binding
+}
+
+// Serialize returns the byte encoding of ReauthRequest.
+func (r *ReauthRequest) Serialize() []byte {
+	return r.Nonce
+}
+
+// ReauthResponse is the second message of the re-authentication flow, created by the server and sent to the client.
+type ReauthResponse struct {
+	Nonce []byte `json:"server_nonce"`
+	Mac   []byte `json:"server_mac"`
+
+	Binding
+}
+
+// Serialize returns the byte encoding of ReauthResponse.
+func (r *ReauthResponse) Serialize() []byte {
+	return encoding.Concat(r.Nonce, r.Mac)
+}
+
+// ReauthFinish is the third and last message of the re-authentication flow, created by the client and sent to the
+// server.
+type ReauthFinish struct {
+	Mac []byte `json:"client_mac"`
+
+	Binding
+}
+
+// Serialize returns the byte encoding of ReauthFinish.
+func (r *ReauthFinish) Serialize() []byte {
+	return r.Mac
+}
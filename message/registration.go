@@ -9,8 +9,11 @@
 package message
 
 import (
+	"fmt"
+
 	"github.com/bytemare/crypto/group"
 
+	"github.com/bytemare/opaque/internal"
 	"github.com/bytemare/opaque/internal/encoding"
 	"github.com/bytemare/opaque/internal/oprf"
 )
@@ -19,6 +22,8 @@ import (
 type RegistrationRequest struct {
 	C              oprf.Ciphersuite
 	BlindedMessage *group.Point `json:"blinded_message"`
+
+	Binding
 }
 
 // Serialize returns the byte encoding of RegistrationRequest.
@@ -32,6 +37,8 @@ type RegistrationResponse struct {
 	G                group.Group
 	EvaluatedMessage *group.Point `json:"evaluated_message"`
 	Pks              *group.Point `json:"server_public_key"`
+
+	Binding
 }
 
 // Serialize returns the byte encoding of RegistrationResponse.
@@ -45,9 +52,42 @@ type RegistrationRecord struct {
 	PublicKey  *group.Point `json:"client_public_key"`
 	MaskingKey []byte       `json:"making_key"`
 	Envelope   []byte       `json:"envelope"`
+
+	Binding
+}
+
+// Wipe zeroes the masking key and the envelope of the record, e.g. once it has been stored.
+func (r *RegistrationRecord) Wipe() {
+	internal.Zero(r.MaskingKey)
+	internal.Zero(r.Envelope)
 }
 
 // Serialize returns the byte encoding of RegistrationRecord.
 func (r *RegistrationRecord) Serialize() []byte {
 	return encoding.Concat3(encoding.SerializePoint(r.PublicKey, r.G), r.MaskingKey, r.Envelope)
 }
+
+// String returns a description of the record with the lengths and fingerprints of its fields, but not their values.
+func (r *RegistrationRecord) String() string {
+	if r == nil {
+		return "<nil>"
+	}
+
+	var publicKey []byte
+	if r.PublicKey != nil {
+		publicKey = r.PublicKey.Bytes()
+	}
+
+	return fmt.Sprintf("message.RegistrationRecord{PublicKey: %s, MaskingKey: %s, Envelope: %s}",
+		internal.Redacted(publicKey), internal.Redacted(r.MaskingKey), internal.Redacted(r.Envelope))
+}
+
+// GoString returns the same description as String.
+func (r *RegistrationRecord) GoString() string {
+	return r.String()
+}
+
+// Format prints the description of String for any verb.
+func (r *RegistrationRecord) Format(f fmt.State, _ rune) {
+	internal.Format(f, r)
+}
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package message
+
+import (
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/oprf"
+)
+
+// PartialEvaluation is a server's OPRF evaluation under its share of the client's OPRF key in threshold OPAQUE,
+// sent to the client to be combined with the others.
+type PartialEvaluation struct {
+	C                oprf.Ciphersuite
+	Index            uint16       `json:"index"`
+	EvaluatedMessage *group.Point `json:"evaluated_message"`
+
+	Binding
+}
+
+// Serialize returns the byte encoding of PartialEvaluation.
+func (p *PartialEvaluation) Serialize() []byte {
+	return encoding.Concat(encoding.I2OSP(int(p.Index), 2), p.C.SerializePoint(p.EvaluatedMessage))
+}
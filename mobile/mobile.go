@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package mobile exposes OPAQUE as functions over byte slices and plain structs, for gomobile bind:
+//
+//	gomobile bind -target=ios,android github.com/bytemare/opaque/mobile
+//
+// Configurations are passed in their serialization, as returned by DefaultConfiguration or
+// opaque.Configuration.Serialize, and messages in their wire encoding. The results are copied out of the library's
+// state, so they remain valid after the next call.
+//
+// A Client runs one registration or login at a time: RegistrationInit and LoginInit start a new flow and discard the
+// state of the previous one. A Server runs one login at a time too, and a new Server is needed per concurrent login.
+package mobile
+
+import (
+	"errors"
+
+	"github.com/bytemare/opaque"
+)
+
+var (
+	errNoRegistration = errors.New("mobile: RegistrationInit must be called first")
+	errNoLogin        = errors.New("mobile: LoginInit must be called first")
+)
+
+// DefaultConfiguration returns the serialization of the default configuration.
+func DefaultConfiguration() []byte {
+	return opaque.DefaultConfiguration().Serialize()
+}
+
+// KeyPair is an AKE key pair.
+type KeyPair struct {
+	SecretKey []byte
+	PublicKey []byte
+}
+
+// GenerateKeyPair returns a random AKE key pair for the configuration, e.g. for the server's long-term keys.
+func GenerateKeyPair(configuration []byte) (*KeyPair, error) {
+	conf, err := opaque.DeserializeConfiguration(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	sk, pk, err := conf.KeyGen()
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{SecretKey: sk, PublicKey: pk}, nil
+}
+
+// GenerateOPRFSeed returns a random OPRF seed for the configuration.
+func GenerateOPRFSeed(configuration []byte) ([]byte, error) {
+	conf, err := opaque.DeserializeConfiguration(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf.GenerateOPRFSeed()
+}
+
+// RegistrationResult is the client's output of a registration.
+type RegistrationResult struct {
+	// Record is the RegistrationRecord message to send to the server.
+	Record []byte
+
+	// ExportKey is the export key, for the application to use, e.g. to encrypt data bound to the password.
+	ExportKey []byte
+}
+
+// LoginResult is the client's output of a login.
+type LoginResult struct {
+	// KE3 is the message to send to the server.
+	KE3 []byte
+
+	// SessionKey is the secret session key shared with the server.
+	SessionKey []byte
+
+	// ExportKey is the same export key as the registration's.
+	ExportKey []byte
+}
+
+// Client is an OPAQUE client.
+type Client struct {
+	client *opaque.Client
+	flow   int
+}
+
+const (
+	flowNone = iota
+	flowRegistration
+	flowLogin
+)
+
+// NewClient returns a Client for the serialized configuration.
+func NewClient(configuration []byte) (*Client, error) {
+	conf, err := opaque.DeserializeConfiguration(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := conf.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{client: client}, nil
+}
+
+// RegistrationInit starts a registration, and returns the RegistrationRequest message to send to the server.
+func (c *Client) RegistrationInit(password []byte) []byte {
+	c.client.Wipe()
+	c.flow = flowRegistration
+
+	return c.client.RegistrationInit(password).Serialize()
+}
+
+// RegistrationFinalize returns the RegistrationRecord message and the export key, given the server's
+// RegistrationResponse message. Empty identities default to the public keys.
+func (c *Client) RegistrationFinalize(response, clientIdentity, serverIdentity []byte) (*RegistrationResult, error) {
+	if c.flow != flowRegistration {
+		return nil, errNoRegistration
+	}
+
+	resp, err := c.client.Deserialize.RegistrationResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	record, exportKey, err := c.client.RegistrationFinalize(resp, identity(clientIdentity), identity(serverIdentity))
+	if err != nil {
+		return nil, err
+	}
+
+	c.flow = flowNone
+
+	return &RegistrationResult{Record: record.Serialize(), ExportKey: copyBytes(exportKey)}, nil
+}
+
+// LoginInit starts a login, and returns the KE1 message to send to the server.
+func (c *Client) LoginInit(password []byte) []byte {
+	c.client.Wipe()
+	c.flow = flowLogin
+
+	return c.client.LoginInit(password).Serialize()
+}
+
+// LoginFinish returns the KE3 message, the session key, and the export key, given the server's KE2 message. It fails if
+// the password or the server are not those of the registration. Empty identities default to the public keys.
+func (c *Client) LoginFinish(ke2, clientIdentity, serverIdentity []byte) (*LoginResult, error) {
+	if c.flow != flowLogin {
+		return nil, errNoLogin
+	}
+
+	m, err := c.client.Deserialize.KE2(ke2)
+	if err != nil {
+		return nil, err
+	}
+
+	ke3, exportKey, err := c.client.LoginFinish(identity(clientIdentity), identity(serverIdentity), m)
+	if err != nil {
+		return nil, err
+	}
+
+	c.flow = flowNone
+
+	return &LoginResult{
+		KE3:        ke3.Serialize(),
+		SessionKey: copyBytes(c.client.SessionKey()),
+		ExportKey:  copyBytes(exportKey),
+	}, nil
+}
+
+// Wipe zeroes the client's secret state. The results returned before remain valid.
+func (c *Client) Wipe() {
+	c.client.Wipe()
+	c.flow = flowNone
+}
+
+// Server is an OPAQUE server with its long-term keys.
+type Server struct {
+	conf      *opaque.Configuration
+	server    *opaque.Server
+	identity  []byte
+	secretKey []byte
+	publicKey []byte
+	oprfSeed  []byte
+}
+
+// NewServer returns a Server for the serialized configuration, with its identity, AKE key pair, and OPRF seed. An empty
+// identity defaults to the public key. It fails if the keys or the seed are not valid for the configuration.
+func NewServer(configuration, serverIdentity, serverSecretKey, serverPublicKey, oprfSeed []byte) (*Server, error) {
+	conf, err := opaque.DeserializeConfiguration(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		conf:      conf,
+		identity:  identity(copyBytes(serverIdentity)),
+		secretKey: copyBytes(serverSecretKey),
+		publicKey: copyBytes(serverPublicKey),
+		oprfSeed:  copyBytes(oprfSeed),
+	}
+
+	if err = s.reset(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// reset replaces the server's state with a fresh one, holding the key material.
+func (s *Server) reset() error {
+	server, err := s.conf.Server()
+	if err != nil {
+		return err
+	}
+
+	if err = server.SetKeyMaterial(s.identity, s.secretKey, s.publicKey, s.oprfSeed); err != nil {
+		return err
+	}
+
+	if s.server != nil {
+		s.server.Wipe()
+	}
+
+	s.server = server
+
+	return nil
+}
+
+// RegistrationResponse returns the RegistrationResponse message for the client's RegistrationRequest message, for the
+// credential identifier under which the record will be stored.
+func (s *Server) RegistrationResponse(request, credentialIdentifier []byte) ([]byte, error) {
+	req, err := s.server.Deserialize.RegistrationRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.server.RegistrationResponseWithKeys(req, credentialIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Serialize(), nil
+}
+
+// LoginInit starts a login, discarding the state of the previous one, and returns the KE2 message for the client's KE1
+// message, given the client's registration record, its credential identifier, and the client identity given at
+// registration, if any.
+func (s *Server) LoginInit(ke1, record, credentialIdentifier, clientIdentity []byte) ([]byte, error) {
+	if err := s.reset(); err != nil {
+		return nil, err
+	}
+
+	m, err := s.server.Deserialize.KE1(ke1)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.server.Deserialize.RegistrationRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	ke2, err := s.server.LoginInitWithKeys(m, &opaque.ClientRecord{
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       identity(clientIdentity),
+		RegistrationRecord:   r,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ke2.Serialize(), nil
+}
+
+// LoginFinish verifies the client's KE3 message, and returns the session key shared with the client.
+func (s *Server) LoginFinish(ke3 []byte) ([]byte, error) {
+	m, err := s.server.Deserialize.KE3(ke3)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.server.LoginFinish(m); err != nil {
+		return nil, err
+	}
+
+	return copyBytes(s.server.SessionKey()), nil
+}
+
+// Wipe zeroes the server's secret state, including its long-term keys. The Server must not be used afterwards.
+func (s *Server) Wipe() {
+	s.server.Wipe()
+
+	for _, k := range [][]byte{s.secretKey, s.oprfSeed} {
+		for i := range k {
+			k[i] = 0
+		}
+	}
+}
+
+// identity maps the empty identities of the bindings, where nil and empty byte arrays can't be told apart, to nil.
+func identity(id []byte) []byte {
+	if len(id) == 0 {
+		return nil
+	}
+
+	return id
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
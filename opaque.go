@@ -14,16 +14,18 @@ package opaque
 
 import (
 	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 
 	"github.com/bytemare/crypto/group"
-	"github.com/bytemare/crypto/hash"
 	"github.com/bytemare/crypto/ksf"
 
 	"github.com/bytemare/opaque/internal"
 	"github.com/bytemare/opaque/internal/ake"
 	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/keyrecovery"
 	"github.com/bytemare/opaque/internal/oprf"
 	"github.com/bytemare/opaque/message"
 )
@@ -53,13 +55,130 @@ const (
 	confLength = 6
 )
 
+// configurationTrailer lists the lengths of the optional fields encoded after the context, in order: the envelope mode,
+// the application data length, the KEM, the AKE protocol, the compatibility mode, and the envelope AEAD. Their default
+// values are all encoded as zeros.
+var configurationTrailer = []int{1, 2, 1, 1, 1, 1}
+
+const configurationTrailerLength = 1 + 2 + 1 + 1 + 1 + 1
+
+// EnvelopeMode identifies how the client's key pair is handled in the envelope.
+type EnvelopeMode byte
+
+const (
+	// Internal is the default envelope mode, where the client's key pair is derived from the password and not stored.
+	Internal = EnvelopeMode(internal.Internal)
+
+	// External is the envelope mode where the client's private key is generated (or given) at registration, and
+	// stored encrypted in the envelope. This allows the client's key pair to be independent of the password.
+	External = EnvelopeMode(internal.External)
+)
+
+// EnvelopeAEAD identifies the AEAD sealing the envelope, if any.
+type EnvelopeAEAD byte
+
+const (
+	// NoAEAD is the default, sealing the envelope as in the draft: the client's private key in the External mode and
+	// the application data are encrypted with pads derived by the KDF, and the envelope is authenticated with the MAC.
+	NoAEAD = EnvelopeAEAD(internal.NoAEAD)
+
+	// AES256GCM seals the client's private key in the External mode and the application data with AES-256-GCM, under
+	// a key derived from the randomized password and the envelope nonce. The 16-byte AEAD tag replaces the MAC, so the
+	// envelope is shorter with hash functions longer than 128 bits.
+	AES256GCM = EnvelopeAEAD(internal.AES256GCM)
+
+	// ChaCha20Poly1305 seals the envelope as AES256GCM does, with ChaCha20-Poly1305, e.g. for clients without AES
+	// hardware support.
+	ChaCha20Poly1305 = EnvelopeAEAD(internal.ChaCha20Poly1305)
+)
+
+// KEM identifies the post-quantum key encapsulation mechanism combined with 3DH in the hybrid AKE.
+type KEM byte
+
+const (
+	// NoKEM is the default, using 3DH alone.
+	NoKEM = KEM(internal.NoKEM)
+
+	// MLKEM768 adds an ML-KEM-768 encapsulation to KE1 and KE2, and feeds its shared secret into the key schedule
+	// together with the 3DH secrets, so that session keys remain confidential if the AKE group is later broken.
+	// It requires Go 1.24 or later.
+	MLKEM768 = KEM(internal.MLKEM768)
+)
+
+// Protocol identifies the AKE protocol.
+type Protocol byte
+
+const (
+	// TripleDH is the default 3DH AKE.
+	TripleDH = Protocol(internal.TripleDH)
+
+	// KEMAKE is the AKE where the parties authenticate by key encapsulation to each other's long-term keys instead of
+	// combining Diffie-Hellman values. The server encapsulates to the client's ephemeral and long-term keys in KE2, and
+	// the client encapsulates to the server's long-term key in KE3, so the session key is only final once the server
+	// processed KE3. The KEM is DHKEM over the AKE group.
+	KEMAKE = Protocol(internal.KEMAKE)
+
+	// HMQV is the HMQV AKE, using the same messages and key schedule as 3DH but computing a single Diffie-Hellman
+	// value, saving a scalar multiplication on each side.
+	HMQV = Protocol(internal.HMQV)
+)
+
+// Compatibility identifies the labels and parameters the protocol runs with, to interoperate with other
+// implementations without a coordinated migration of their users.
+type Compatibility byte
+
+const (
+	// Draft is the default, running the protocol as in the draft this library implements.
+	Draft = Compatibility(internal.Draft)
+
+	// OpaqueKE runs the protocol as the opaque-ke Rust crate (version 3) does, so that a server authenticates the
+	// existing clients of opaque-ke, and their registration records remain valid. The messages are encoded as in the
+	// draft, but the labels are those of RFC 9807 and RFC 9497: the OPRF context string is "OPRFV1-" with the
+	// cipher suite's name, the AKE transcript starts with "OPAQUEv1-", and the client's key pair is derived with
+	// "OPAQUE-DeriveDiffieHellmanKeyPair". The KSF must be the identity (0) or ksf.Argon2id, which runs with the
+	// default parameters of opaque-ke (t=2, m=19456 KiB, p=1), a 16-byte all-zero salt, and an output of the length
+	// of the OPRF output. The extensions of this library that opaque-ke doesn't have, i.e. the External mode, the
+	// application data, the KEMs, and the protocols other than TripleDH, are rejected.
+	OpaqueKE = Compatibility(internal.OpaqueKE)
+
+	// LibOpaque runs the protocol as libopaque does, e.g. for clients using its bindings on mobile or in the browser.
+	// It uses the labels of RFC 9807 and RFC 9497 like OpaqueKE, and the only suite of libopaque: RistrettoSha512 with
+	// SHA-512 for the KDF, MAC, and Hash. With ksf.Argon2id, the KSF is libsodium's crypto_pwhash with the interactive
+	// limits (t=2, m=65536 KiB, p=1) and a 16-byte all-zero salt. The KSF can also be the identity (0), for libopaque
+	// built for the test vectors. libopaque servers derive no OPRF key from a seed, but store a random one with each
+	// record: see LibOpaqueRecord to serve them.
+	LibOpaque = Compatibility(internal.LibOpaque)
+)
+
+// compatibilityKSF holds the Argon2id parameters (time, memory in KiB, threads) of the compatibility modes.
+var compatibilityKSF = map[Compatibility][]int{
+	OpaqueKE:  {2, 19456, 1},
+	LibOpaque: {2, 65536, 1},
+}
+
 var (
 	errInvalidOPRFid = errors.New("invalid OPRF group id")
 	errInvalidKDFid  = errors.New("invalid KDF id")
 	errInvalidMACid  = errors.New("invalid MAC id")
 	errInvalidHASHid = errors.New("invalid Hash id")
 	errInvalidKSFid  = errors.New("invalid KSF id")
+	errKSFParameters = errors.New("invalid KSF parameters")
 	errInvalidAKEid  = errors.New("invalid AKE group id")
+	errInvalidMode   = errors.New("invalid envelope mode")
+	errInvalidAEAD   = errors.New("invalid envelope AEAD")
+	errInvalidKEM    = errors.New("invalid KEM id")
+	errInvalidProto  = errors.New("invalid AKE protocol")
+	errContextLength = errors.New("context is too long")
+	errAppDataLength = errors.New("application data length exceeds the KDF output")
+	errInvalidCompat = errors.New("invalid compatibility mode")
+	errCompatibility = errors.New("configuration is not supported in the compatibility mode")
+
+	// ErrRandom indicates a failure of the system's random number generator (crypto/rand.Reader). It is returned by
+	// the operations drawing long-term secrets, like the constructors, KeyGen, GenerateOPRFSeed, and the external
+	// mode registration. The nonces and ephemeral keys of the protocol are hedged with a secret drawn when the Client
+	// or Server is created, and remain unique and unpredictable without fresh randomness, so that RegistrationInit,
+	// LoginInit, and the server's responses don't fail on it.
+	ErrRandom = internal.ErrRandom
 )
 
 // Configuration represents an OPAQUE configuration. Note that OprfGroup and AKEGroup are recommended to be the same,
@@ -81,11 +200,65 @@ type Configuration struct {
 	// defined in github.com/bytemare/crypto/ksf.
 	KSF ksf.Identifier `json:"ksf"`
 
+	// KSFParameters replaces the default parameters of the KSF, e.g. the time, memory in KiB, and threads of
+	// ksf.Argon2id, or the N, r, and p of ksf.Scrypt. Only the client runs the KSF, and it must use the same parameters
+	// at registration and login. They are not part of the serialization.
+	KSFParameters []int `json:"ksf_parameters,omitempty"`
+
 	// AKE identifies the group to use for the AKE.
 	AKE Group `json:"group"`
 
-	// Context is optional shared information to include in the AKE transcript.
+	// Mode identifies the envelope mode, and defaults to Internal.
+	Mode EnvelopeMode `json:"mode"`
+
+	// AppDataLength is the maximum length of the application data that can be sealed in the envelope. All envelopes
+	// have the same size for a given configuration, so a non-zero value increases the size of every record and KE2.
+	// It is bounded by the KDF output, and must not exceed 255*KDF.Size()-2 bytes (8158 bytes with SHA-256).
+	AppDataLength uint16 `json:"app_data_length"`
+
+	// AEAD identifies the AEAD sealing the envelope, and defaults to NoAEAD.
+	AEAD EnvelopeAEAD `json:"aead,omitempty"`
+
+	// KEM identifies the KEM of the hybrid AKE, and defaults to NoKEM.
+	KEM KEM `json:"kem"`
+
+	// Protocol identifies the AKE protocol, and defaults to TripleDH.
+	Protocol Protocol `json:"protocol"`
+
+	// Compatibility identifies the implementation to be wire-compatible with, and defaults to Draft.
+	Compatibility Compatibility `json:"compatibility"`
+
+	// Context is optional shared information to include in the AKE transcript, of at most 65535 bytes.
 	Context []byte
+
+	// CommitConfiguration binds the Fingerprint of the whole serialized Configuration into the AKE transcript, and not
+	// only the Context, so that a client and a server running different configurations, e.g. after an attacker
+	// tampered with an advertised one, fail the login on the MAC instead of silently running the weaker one. Both
+	// parties must set it. It changes the protocol, but is not part of the serialization, and is not supported in the
+	// compatibility modes.
+	CommitConfiguration bool `json:"commit_configuration,omitempty"`
+
+	// FixedBaseTables enables precomputed tables for the multiplications of the base point, e.g. to generate the
+	// ephemeral keys, which dominate the cost of a login on the NIST curves. The table of a group is computed on first
+	// use and shared by all configurations. It doesn't change the protocol, and is not part of the serialization.
+	FixedBaseTables bool `json:"-"`
+
+	// ParallelDH computes the three Diffie-Hellman operations of 3DH concurrently, on a bounded pool of workers, which
+	// reduces the latency of a login on the larger NIST curves. It doesn't change the protocol, and is not part of the
+	// serialization.
+	ParallelDH bool `json:"-"`
+
+	// BlindStaticKey hardens the server against side channels on shared hardware, e.g. cache timing or power analysis,
+	// by splitting its long-term private key into two random factors for its 3DH operation, so that no scalar
+	// multiplication handles the key itself, nor the same scalar twice. It costs a scalar multiplication and inversion
+	// per login. It doesn't change the protocol, and is not part of the serialization.
+	BlindStaticKey bool `json:"-"`
+
+	// LockedMemory holds the session and export keys in memory that is locked against swapping, excluded from core
+	// dumps, and surrounded by guard pages, on the platforms supporting it (see the securemem package). It silently
+	// falls back to the heap where such memory is not available. The keys are released when the Client or Server is
+	// wiped. It doesn't change the protocol, and is not part of the serialization.
+	LockedMemory bool `json:"-"`
 }
 
 // DefaultConfiguration returns a default configuration with strong parameters.
@@ -97,6 +270,7 @@ func DefaultConfiguration() *Configuration {
 		Hash:    crypto.SHA512,
 		KSF:     ksf.Scrypt,
 		AKE:     RistrettoSha512,
+		Mode:    Internal,
 		Context: nil,
 	}
 }
@@ -112,12 +286,12 @@ func (c *Configuration) Server() (*Server, error) {
 }
 
 // GenerateOPRFSeed returns a OPRF seed valid in the given configuration.
-func (c *Configuration) GenerateOPRFSeed() []byte {
+func (c *Configuration) GenerateOPRFSeed() ([]byte, error) {
 	return RandomBytes(c.Hash.Size())
 }
 
 // KeyGen returns a key pair in the AKE group.
-func (c *Configuration) KeyGen() (secretKey, publicKey []byte) {
+func (c *Configuration) KeyGen() (secretKey, publicKey []byte, err error) {
 	return ake.KeyGen(group.Group(c.AKE))
 }
 
@@ -127,15 +301,15 @@ func (c *Configuration) verify() error {
 		return errInvalidOPRFid
 	}
 
-	if !hash.Hashing(c.KDF).Available() {
+	if !internal.HashAvailable(c.KDF) {
 		return errInvalidKDFid
 	}
 
-	if !hash.Hashing(c.MAC).Available() {
+	if !internal.HashAvailable(c.MAC) {
 		return errInvalidMACid
 	}
 
-	if !hash.Hashing(c.Hash).Available() {
+	if !internal.HashAvailable(c.Hash) {
 		return errInvalidHASHid
 	}
 
@@ -143,10 +317,104 @@ func (c *Configuration) verify() error {
 		return errInvalidKSFid
 	}
 
+	if err := c.verifyKSFParameters(); err != nil {
+		return err
+	}
+
 	if !group.Group(c.AKE).Available() {
 		return errInvalidAKEid
 	}
 
+	if c.Mode != Internal && c.Mode != External {
+		return errInvalidMode
+	}
+
+	if c.AEAD > ChaCha20Poly1305 {
+		return errInvalidAEAD
+	}
+
+	// The application data slot is encrypted with the pad of a single KDF expansion.
+	if c.AppDataLength != 0 && 2+int(c.AppDataLength) > maxExpandBlocks*c.KDF.Size() {
+		return errAppDataLength
+	}
+
+	if !ake.KEMAvailable(internal.KEM(c.KEM)) {
+		return errInvalidKEM
+	}
+
+	if c.Protocol != TripleDH && c.Protocol != KEMAKE && c.Protocol != HMQV {
+		return errInvalidProto
+	}
+
+	if len(c.Context) > maxInfoLength {
+		return errContextLength
+	}
+
+	return c.verifyCompatibility()
+}
+
+// ksfParameterCount holds the number of parameters of each KSF.
+var ksfParameterCount = map[ksf.Identifier]int{
+	ksf.Argon2id:     3,
+	ksf.Scrypt:       3,
+	ksf.PBKDF2Sha512: 1,
+	ksf.Bcrypt:       1,
+}
+
+// verifyKSFParameters returns an error if the KSF parameters are set but don't match the KSF.
+func (c *Configuration) verifyKSFParameters() error {
+	if len(c.KSFParameters) == 0 {
+		return nil
+	}
+
+	if len(c.KSFParameters) != ksfParameterCount[c.KSF] {
+		return fmt.Errorf("%w: %d parameters for KSF %d", errKSFParameters, len(c.KSFParameters), c.KSF)
+	}
+
+	for _, p := range c.KSFParameters {
+		if p <= 0 {
+			return fmt.Errorf("%w: %d is not positive", errKSFParameters, p)
+		}
+	}
+
+	return nil
+}
+
+// verifyCompatibility returns an error if the configuration uses what the compatibility mode doesn't support.
+func (c *Configuration) verifyCompatibility() error {
+	switch c.Compatibility {
+	case Draft:
+		return nil
+	case OpaqueKE:
+	case LibOpaque:
+		if c.OPRF != RistrettoSha512 || c.AKE != RistrettoSha512 || c.KDF != crypto.SHA512 ||
+			c.MAC != crypto.SHA512 || c.Hash != crypto.SHA512 {
+			return fmt.Errorf("%w: only RistrettoSha512 with SHA-512 is", errCompatibility)
+		}
+	default:
+		return errInvalidCompat
+	}
+
+	if c.KSF != 0 && c.KSF != ksf.Argon2id {
+		return fmt.Errorf("%w: KSF %s", errCompatibility, c.KSF)
+	}
+
+	if len(c.KSFParameters) != 0 {
+		return fmt.Errorf("%w: KSF parameters", errCompatibility)
+	}
+
+	if c.AEAD != NoAEAD {
+		return fmt.Errorf("%w: envelope AEAD", errCompatibility)
+	}
+
+	if c.CommitConfiguration {
+		return fmt.Errorf("%w: configuration commitment", errCompatibility)
+	}
+
+	if c.Mode != Internal || c.AppDataLength != 0 || c.KEM != NoKEM || c.Protocol != TripleDH {
+		return fmt.Errorf("%w: only the Internal mode and TripleDH without application data are", errCompatibility)
+	}
+
 	return nil
 }
 
@@ -156,6 +424,11 @@ func (c *Configuration) toInternal() (*internal.Configuration, error) {
 		return nil, err
 	}
 
+	hedgeKey, err := internal.RandomBytes(internal.HedgeKeyLength)
+	if err != nil {
+		return nil, err
+	}
+
 	g := group.Group(c.AKE)
 	ip := &internal.Configuration{
 		OPRF:            oprf.Ciphersuite(c.OPRF),
@@ -163,13 +436,39 @@ func (c *Configuration) toInternal() (*internal.Configuration, error) {
 		KDF:             internal.NewKDF(c.KDF),
 		MAC:             internal.NewMac(c.MAC),
 		Hash:            internal.NewHash(c.Hash),
-		KSF:             internal.NewKSF(c.KSF),
+		KSF:             internal.NewKSF(c.KSF, c.KSFParameters...),
 		NonceLen:        internal.NonceLength,
 		Group:           g,
 		AkePointLength:  encoding.PointLength[g],
+		Mode:            internal.EnvelopeMode(c.Mode),
+		AEAD:            internal.AEAD(c.AEAD),
+		AppDataLength:   int(c.AppDataLength),
+		KEM:             internal.KEM(c.KEM),
+		Protocol:        internal.Protocol(c.Protocol),
 		Context:         c.Context,
+		ParallelDH:      c.ParallelDH,
+		BlindStaticKey:  c.BlindStaticKey,
+		LockedMemory:    c.LockedMemory,
+		HedgeKey:        hedgeKey,
+	}
+
+	if c.Compatibility != Draft {
+		ip.Compatibility = internal.Compatibility(c.Compatibility)
+		ip.OPRF = ip.OPRF.RFC9497()
+		ip.KSF = internal.NewZeroSaltKSF(c.KSF, compatibilityKSF[c.Compatibility]...)
+	}
+
+	if c.CommitConfiguration {
+		ip.Commitment = c.Fingerprint()
 	}
-	ip.EnvelopeSize = ip.NonceLen + ip.MAC.Size()
+
+	if c.FixedBaseTables {
+		ip.BaseTable = internal.GetBaseTable(g)
+	}
+
+	ip.KEMPublicKeyLength, ip.KEMCiphertextLength = ake.KEMLengths(ip.KEM)
+	ip.EnvelopeSize = ip.NonceLen + keyrecovery.InnerEnvelopeLength(ip) + keyrecovery.AppDataSlotLength(ip) +
+		keyrecovery.AuthTagLength(ip)
 
 	return ip, nil
 }
@@ -182,10 +481,18 @@ func (c *Configuration) Deserializer() (*Deserializer, error) {
 		return nil, err
 	}
 
-	return &Deserializer{conf: conf}, nil
+	return newDeserializer(conf), nil
+}
+
+// Fingerprint returns the SHA-256 hash of the serialized Configuration, identifying it in logs or tokens.
+func (c *Configuration) Fingerprint() []byte {
+	h := sha256.Sum256(c.Serialize())
+	return h[:]
 }
 
-// Serialize returns the byte encoding of the Configuration structure.
+// Serialize returns the byte encoding of the Configuration structure. The envelope mode, the application data length,
+// the KEM, the AKE protocol, the compatibility mode, and the envelope AEAD are appended after the context up to the
+// last one that isn't set to its default, so that the encodings of the draft configurations remain the same.
 func (c *Configuration) Serialize() []byte {
 	b := []byte{
 		byte(c.OPRF),
@@ -196,7 +503,30 @@ func (c *Configuration) Serialize() []byte {
 		byte(c.AKE),
 	}
 
-	return encoding.Concat(b, encoding.EncodeVector(c.Context))
+	trailer := encoding.Concatenate(
+		[]byte{byte(c.Mode)},
+		encoding.I2OSP(int(c.AppDataLength), 2),
+		[]byte{byte(c.KEM), byte(c.Protocol), byte(c.Compatibility), byte(c.AEAD)},
+	)
+
+	return encoding.Concatenate(b, encoding.EncodeVector(c.Context), trimTrailer(trailer))
+}
+
+// trimTrailer returns the configuration trailer up to its last field that isn't zero.
+func trimTrailer(trailer []byte) []byte {
+	end, offset := 0, 0
+
+	for _, length := range configurationTrailer {
+		for _, b := range trailer[offset : offset+length] {
+			if b != 0 {
+				end = offset + length
+			}
+		}
+
+		offset += length
+	}
+
+	return trailer[:end]
 }
 
 // GetFakeRecord creates a fake Client record to be used when no existing client record exists,
@@ -207,43 +537,70 @@ func (c *Configuration) GetFakeRecord(credentialIdentifier []byte) (*ClientRecor
 		return nil, err
 	}
 
-	scalar := i.Group.NewScalar().Random()
-	publicKey := i.Group.Base().Mult(scalar)
+	scalar, err := internal.RandomScalar(i.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	maskingKey, err := RandomBytes(i.KDF.Size())
+	if err != nil {
+		return nil, err
+	}
 
 	regRecord := &message.RegistrationRecord{
 		G:          i.Group,
-		PublicKey:  publicKey,
-		MaskingKey: RandomBytes(i.KDF.Size()),
-		Envelope:   make([]byte, internal.NonceLength+i.MAC.Size()),
+		PublicKey:  i.BaseMult(scalar),
+		MaskingKey: maskingKey,
+		Envelope:   make([]byte, i.EnvelopeSize),
 	}
 
 	return &ClientRecord{
 		CredentialIdentifier: credentialIdentifier,
 		ClientIdentity:       nil,
 		RegistrationRecord:   regRecord,
-		TestMaskNonce:        nil,
 	}, nil
 }
 
-// DeserializeConfiguration decodes the input and returns a Parameter structure.
+// DeserializeConfiguration decodes the input and returns a Parameter structure. The fields after the context that
+// the encoding doesn't have are set to their defaults, so that the configurations serialized by earlier versions of
+// this library, which didn't have them, are decoded too.
 func DeserializeConfiguration(encoded []byte) (*Configuration, error) {
 	if len(encoded) < confLength+2 { // corresponds to the configuration length + 2-byte encoding of empty context
 		return nil, internal.ErrConfigurationInvalidLength
 	}
 
-	ctx, _, err := encoding.DecodeVector(encoded[confLength:])
+	ctx, offset, err := encoding.DecodeVector(encoded[confLength:])
 	if err != nil {
 		return nil, fmt.Errorf("decoding the configuration context: %w", err)
 	}
 
+	// The trailer is canonical: it must end after a field that isn't set to its default.
+	trailer := encoded[confLength+offset:]
+	if len(trailer) > configurationTrailerLength {
+		return nil, internal.ErrConfigurationInvalidLength
+	}
+
+	fields := make([]byte, configurationTrailerLength)
+	copy(fields, trailer)
+
+	if len(trimTrailer(fields)) != len(trailer) {
+		return nil, internal.ErrConfigurationInvalidLength
+	}
+
 	c := &Configuration{
-		OPRF:    Group(encoded[0]),
-		KDF:     crypto.Hash(encoded[1]),
-		MAC:     crypto.Hash(encoded[2]),
-		Hash:    crypto.Hash(encoded[3]),
-		KSF:     ksf.Identifier(encoded[4]),
-		AKE:     Group(encoded[5]),
-		Context: ctx,
+		OPRF:          Group(encoded[0]),
+		KDF:           crypto.Hash(encoded[1]),
+		MAC:           crypto.Hash(encoded[2]),
+		Hash:          crypto.Hash(encoded[3]),
+		KSF:           ksf.Identifier(encoded[4]),
+		AKE:           Group(encoded[5]),
+		Mode:          EnvelopeMode(fields[0]),
+		AppDataLength: uint16(encoding.OS2IP(fields[1:3])),
+		KEM:           KEM(fields[3]),
+		Protocol:      Protocol(fields[4]),
+		Compatibility: Compatibility(fields[5]),
+		AEAD:          EnvelopeAEAD(fields[6]),
+		Context:       ctx,
 	}
 
 	if err := c.verify(); err != nil {
@@ -258,12 +615,66 @@ type ClientRecord struct {
 	CredentialIdentifier []byte
 	ClientIdentity       []byte
 	*message.RegistrationRecord
+}
+
+// ConstantTimeCompareRecords returns whether the two client records are equal, in time that only depends on the
+// lengths of their fields, e.g. to check a record against the stored one without leaking where they differ.
+func ConstantTimeCompareRecords(a, b *ClientRecord) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	eq := subtle.ConstantTimeCompare(a.CredentialIdentifier, b.CredentialIdentifier)
+	eq &= subtle.ConstantTimeCompare(a.ClientIdentity, b.ClientIdentity)
+
+	ra, rb := a.RegistrationRecord, b.RegistrationRecord
+	if ra == nil || rb == nil {
+		return eq == 1 && ra == rb
+	}
+
+	eq &= subtle.ConstantTimeEq(int32(ra.G), int32(rb.G))
+	eq &= subtle.ConstantTimeCompare(pointBytes(ra.PublicKey), pointBytes(rb.PublicKey))
+	eq &= subtle.ConstantTimeCompare(ra.MaskingKey, rb.MaskingKey)
+	eq &= subtle.ConstantTimeCompare(ra.Envelope, rb.Envelope)
+
+	return eq == 1
+}
+
+// ValidateRecord checks a stored record against the configuration, e.g. to detect records registered in another
+// configuration before they fail the logins: the lengths of its components and the encoding of the client public key
+// must be the ones of the configuration, and its credential identifier must not be empty. It returns an error wrapping
+// ErrInvalidRecord otherwise, as Server.VerifyRegistrationRecord does.
+func ValidateRecord(conf *Configuration, record *ClientRecord) error {
+	d, err := conf.Deserializer()
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, errIncompleteMessage)
+	}
+
+	if len(record.CredentialIdentifier) == 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, errEmptyCredentialIdentifier)
+	}
+
+	if len(record.CredentialIdentifier) > maxIdentityLength || len(record.ClientIdentity) > maxIdentityLength {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, errIdentityLength)
+	}
+
+	return verifyRegistrationRecord(d, record.RegistrationRecord)
+}
+
+func pointBytes(p *group.Point) []byte {
+	if p == nil {
+		return nil
+	}
 
-	// testing
-	TestMaskNonce []byte
+	return p.Bytes()
 }
 
-// RandomBytes returns random bytes of length len (wrapper for crypto/rand).
-func RandomBytes(length int) []byte {
+// RandomBytes returns random bytes of length len (wrapper for crypto/rand), or an error wrapping ErrRandom if the
+// random number generator fails.
+func RandomBytes(length int) ([]byte, error) {
 	return internal.RandomBytes(length)
 }
@@ -0,0 +1,451 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaqueeap implements EAP-OPAQUE, an EAP method (RFC 3748) carrying the OPAQUE login, to be used e.g. as the
+// inner authentication of 802.1X deployments.
+//
+// The authenticator asks for the peer's identity, which is the credential identifier, and starts the method. The
+// peer answers with KE1, the authenticator with KE2, and the peer with KE3, after which the authenticator sends an
+// EAP Success or Failure. Both ends then export the MSK and EMSK keying material derived from the session key.
+//
+// An EAP-OPAQUE packet's Type-Data starts with a flags byte and an operation byte. Messages that don't fit the MTU
+// are fragmented: all but the last fragment have the M (0x40) flag set, the first one has the L (0x80) flag set and
+// is followed by the total message length in 4 bytes, and each fragment is acknowledged by an empty packet with the
+// Ack operation.
+package opaqueeap
+
+import (
+	"crypto"
+	"errors"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal"
+)
+
+// DefaultMTU is the maximum EAP packet length, if none is set.
+const DefaultMTU = 1020
+
+// minMTU is the smallest usable MTU, to fit the headers and some data.
+const minMTU = methodHeaderLength + totalLengthLength + 1
+
+// KeyLength is the length of the MSK and EMSK.
+const KeyLength = 64
+
+const (
+	labelMSK  = "EAP-OPAQUE MSK"
+	labelEMSK = "EAP-OPAQUE EMSK"
+)
+
+var (
+	// ErrAuthentication indicates that the authentication failed.
+	ErrAuthentication = errors.New("opaqueeap: authentication failed")
+
+	// ErrUnexpectedPacket indicates a packet that is not valid in the current state of the method.
+	ErrUnexpectedPacket = errors.New("opaqueeap: unexpected packet")
+
+	errMTU = errors.New("opaqueeap: MTU too small")
+)
+
+type state byte
+
+const (
+	stateIdentity state = iota
+	stateStart
+	stateKE1
+	stateKE2
+	stateKE3
+	stateDone
+	stateFailed
+)
+
+func keys(sessionKey []byte) (msk, emsk []byte) {
+	kdf := internal.NewKDF(crypto.SHA512)
+
+	return kdf.Expand(sessionKey, []byte(labelMSK), KeyLength), kdf.Expand(sessionKey, []byte(labelEMSK), KeyLength)
+}
+
+func mtu(m int) (int, error) {
+	if m == 0 {
+		return DefaultMTU, nil
+	}
+
+	if m < minMTU {
+		return 0, errMTU
+	}
+
+	return m, nil
+}
+
+// Peer is the EAP peer side of EAP-OPAQUE, logging in with a password.
+type Peer struct {
+	client               *opaque.Client
+	credentialIdentifier []byte
+	password             []byte
+	clientIdentity       []byte
+	serverIdentity       []byte
+	msk, emsk            []byte
+	frag                 fragmenter
+	state                state
+}
+
+// NewPeer returns a peer logging in with the password to the record registered under the credential identifier.
+// clientIdentity and serverIdentity are the identities given at registration, nil if the public keys were used, and
+// an MTU of 0 uses DefaultMTU.
+func NewPeer(
+	conf *opaque.Configuration,
+	credentialIdentifier, password, clientIdentity, serverIdentity []byte,
+	maxPacketLength int,
+) (*Peer, error) {
+	client, err := conf.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := mtu(maxPacketLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Peer{
+		client:               client,
+		credentialIdentifier: credentialIdentifier,
+		password:             password,
+		clientIdentity:       clientIdentity,
+		serverIdentity:       serverIdentity,
+		frag:                 fragmenter{mtu: m},
+	}, nil
+}
+
+// Handle processes a packet from the authenticator, and returns the response packet to send back, if any. After
+// an EAP Success, Done returns true and the keys are available.
+func (p *Peer) Handle(in []byte) ([]byte, error) {
+	req, err := decodePacket(in)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := p.handle(req)
+	if err != nil {
+		p.state = stateFailed
+		return nil, err
+	}
+
+	if out == nil {
+		return nil, nil
+	}
+
+	return out.encode(), nil
+}
+
+func (p *Peer) handle(req *packet) (*packet, error) {
+	switch req.code {
+	case CodeSuccess:
+		if p.state != stateKE3 || p.frag.sending() {
+			return nil, ErrUnexpectedPacket
+		}
+
+		p.state = stateDone
+
+		return nil, nil
+	case CodeFailure:
+		return nil, ErrAuthentication
+	case CodeRequest:
+	default:
+		return nil, ErrUnexpectedPacket
+	}
+
+	switch {
+	case req.typ == TypeIdentity:
+		if p.state != stateIdentity {
+			return nil, ErrUnexpectedPacket
+		}
+
+		p.state = stateStart
+
+		return &packet{
+			code:       CodeResponse,
+			identifier: req.identifier,
+			typ:        TypeIdentity,
+			data:       p.credentialIdentifier,
+		}, nil
+	case req.typ != TypeOPAQUE:
+		return &packet{code: CodeResponse, identifier: req.identifier, typ: TypeNak, data: []byte{TypeOPAQUE}}, nil
+	case req.op == opAck:
+		if !p.frag.sending() {
+			return nil, ErrUnexpectedPacket
+		}
+
+		return p.frag.next(CodeResponse, req.identifier), nil
+	}
+
+	m, more, err := p.frag.receive(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if more {
+		return ack(CodeResponse, req.identifier), nil
+	}
+
+	if err := p.receive(req.op, m); err != nil {
+		return nil, err
+	}
+
+	return p.frag.next(CodeResponse, req.identifier), nil
+}
+
+// receive processes a complete message from the authenticator, and sets the answer to send.
+func (p *Peer) receive(op byte, m []byte) error {
+	switch {
+	case op == opStart && (p.state == stateStart || p.state == stateIdentity):
+		p.state = stateKE1
+		p.frag.send(opKE1, p.client.LoginInit(p.password).Serialize())
+	case op == opKE2 && p.state == stateKE1:
+		ke2, err := p.client.Deserialize.KE2(m)
+		if err != nil {
+			return ErrUnexpectedPacket
+		}
+
+		ke3, _, err := p.client.LoginFinish(p.clientIdentity, p.serverIdentity, ke2)
+		if err != nil {
+			return ErrAuthentication
+		}
+
+		p.state = stateKE3
+		p.msk, p.emsk = keys(p.client.SessionKey())
+		p.frag.send(opKE3, ke3.Serialize())
+	default:
+		return ErrUnexpectedPacket
+	}
+
+	return nil
+}
+
+// Done returns whether the authentication succeeded.
+func (p *Peer) Done() bool {
+	return p.state == stateDone
+}
+
+// MSK returns the Master Session Key exported after a successful authentication.
+func (p *Peer) MSK() []byte {
+	if !p.Done() {
+		return nil
+	}
+
+	return p.msk
+}
+
+// EMSK returns the Extended Master Session Key exported after a successful authentication.
+func (p *Peer) EMSK() []byte {
+	if !p.Done() {
+		return nil
+	}
+
+	return p.emsk
+}
+
+// AuthenticatorConfig holds the server's credentials for the authenticator side of EAP-OPAQUE.
+type AuthenticatorConfig struct {
+	Configuration *opaque.Configuration
+
+	// Lookup returns the record registered under the credential identifier, or nil if there is none, in which case
+	// the method runs with a fake record to not reveal it.
+	Lookup func(credentialIdentifier []byte) (*opaque.ClientRecord, error)
+
+	ServerIdentity  []byte
+	ServerSecretKey []byte
+	ServerPublicKey []byte
+	OPRFSeed        []byte
+
+	// MTU is the maximum EAP packet length, DefaultMTU if zero.
+	MTU int
+}
+
+// Authenticator is the EAP authenticator side of EAP-OPAQUE, run for a single peer.
+type Authenticator struct {
+	config               *AuthenticatorConfig
+	server               *opaque.Server
+	credentialIdentifier []byte
+	msk, emsk            []byte
+	frag                 fragmenter
+	state                state
+	identifier           byte
+}
+
+// NewAuthenticator returns an authenticator for a new peer.
+func NewAuthenticator(config *AuthenticatorConfig) (*Authenticator, error) {
+	server, err := config.Configuration.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := mtu(config.MTU)
+	if err != nil {
+		return nil, err
+	}
+
+	identifier, err := internal.RandomBytes(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		config:     config,
+		server:     server,
+		frag:       fragmenter{mtu: m},
+		identifier: identifier[0],
+	}, nil
+}
+
+// Start returns the first packet to send to the peer, an EAP Identity request.
+func (a *Authenticator) Start() []byte {
+	return (&packet{code: CodeRequest, identifier: a.identifier, typ: TypeIdentity}).encode()
+}
+
+// Handle processes a response packet from the peer, and returns the next packet to send to it. The authentication
+// is complete when that packet is an EAP Success or Failure, after which Done tells whether it succeeded.
+func (a *Authenticator) Handle(in []byte) ([]byte, error) {
+	resp, err := decodePacket(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// Responses that don't match the outstanding request are rejected, and can be discarded by the caller.
+	if resp.code != CodeResponse || resp.identifier != a.identifier {
+		return nil, ErrUnexpectedPacket
+	}
+
+	a.identifier++
+
+	out, err := a.handle(resp)
+	if err != nil {
+		a.state = stateFailed
+		return (&packet{code: CodeFailure, identifier: a.identifier}).encode(), err
+	}
+
+	return out.encode(), nil
+}
+
+func (a *Authenticator) handle(resp *packet) (*packet, error) {
+	switch {
+	case resp.typ == TypeIdentity && a.state == stateIdentity:
+		if len(resp.data) == 0 {
+			return nil, ErrUnexpectedPacket
+		}
+
+		a.credentialIdentifier = resp.data
+		a.state = stateStart
+		a.frag.send(opStart, nil)
+
+		return a.frag.next(CodeRequest, a.identifier), nil
+	case resp.typ != TypeOPAQUE:
+		return nil, ErrAuthentication
+	case resp.op == opAck:
+		if !a.frag.sending() {
+			return nil, ErrUnexpectedPacket
+		}
+
+		return a.frag.next(CodeRequest, a.identifier), nil
+	}
+
+	m, more, err := a.frag.receive(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if more {
+		return ack(CodeRequest, a.identifier), nil
+	}
+
+	switch {
+	case resp.op == opKE1 && a.state == stateStart:
+		if err := a.loginInit(m); err != nil {
+			return nil, err
+		}
+
+		return a.frag.next(CodeRequest, a.identifier), nil
+	case resp.op == opKE3 && a.state == stateKE2:
+		ke3, err := a.server.Deserialize.KE3(m)
+		if err != nil {
+			return nil, ErrUnexpectedPacket
+		}
+
+		if err := a.server.LoginFinish(ke3); err != nil {
+			return nil, ErrAuthentication
+		}
+
+		a.state = stateDone
+		a.msk, a.emsk = keys(a.server.SessionKey())
+
+		return &packet{code: CodeSuccess, identifier: a.identifier}, nil
+	default:
+		return nil, ErrUnexpectedPacket
+	}
+}
+
+func (a *Authenticator) loginInit(m []byte) error {
+	ke1, err := a.server.Deserialize.KE1(m)
+	if err != nil {
+		return ErrUnexpectedPacket
+	}
+
+	record, err := a.config.Lookup(a.credentialIdentifier)
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		if record, err = a.config.Configuration.GetFakeRecord(a.credentialIdentifier); err != nil {
+			return err
+		}
+	}
+
+	c := a.config
+
+	ke2, err := a.server.LoginInit(ke1, c.ServerIdentity, c.ServerSecretKey, c.ServerPublicKey, c.OPRFSeed, record)
+	if err != nil {
+		return err
+	}
+
+	a.state = stateKE2
+	a.frag.send(opKE2, ke2.Serialize())
+
+	return nil
+}
+
+// Done returns whether the peer was authenticated.
+func (a *Authenticator) Done() bool {
+	return a.state == stateDone
+}
+
+// CredentialIdentifier returns the identity the peer authenticated with, after a successful authentication.
+func (a *Authenticator) CredentialIdentifier() []byte {
+	if !a.Done() {
+		return nil
+	}
+
+	return a.credentialIdentifier
+}
+
+// MSK returns the Master Session Key exported after a successful authentication.
+func (a *Authenticator) MSK() []byte {
+	if !a.Done() {
+		return nil
+	}
+
+	return a.msk
+}
+
+// EMSK returns the Extended Master Session Key exported after a successful authentication.
+func (a *Authenticator) EMSK() []byte {
+	if !a.Done() {
+		return nil
+	}
+
+	return a.emsk
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaqueeap
+
+import (
+	"errors"
+
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// EAP codes, as defined in RFC 3748.
+const (
+	CodeRequest  byte = 1
+	CodeResponse byte = 2
+	CodeSuccess  byte = 3
+	CodeFailure  byte = 4
+)
+
+// EAP method types.
+const (
+	// TypeIdentity is the EAP Identity type, carrying the credential identifier.
+	TypeIdentity byte = 1
+
+	// TypeNak is the EAP legacy Nak type, with which a peer refuses a method.
+	TypeNak byte = 3
+
+	// TypeOPAQUE is the method type of EAP-OPAQUE. Until a type is assigned, it is the experimental type of RFC 3748.
+	TypeOPAQUE byte = 255
+)
+
+// Flags of an EAP-OPAQUE packet.
+const (
+	flagLength byte = 0x80
+	flagMore   byte = 0x40
+)
+
+// Operations of an EAP-OPAQUE packet.
+const (
+	opAck byte = iota
+	opStart
+	opKE1
+	opKE2
+	opKE3
+)
+
+const (
+	headerLength       = 4
+	methodHeaderLength = headerLength + 3
+	totalLengthLength  = 4
+
+	// maxMessageLength bounds the size of a reassembled message.
+	maxMessageLength = 1 << 16
+)
+
+var (
+	errPacketLength   = errors.New("opaqueeap: invalid packet length")
+	errPacketCode     = errors.New("opaqueeap: unexpected packet code")
+	errFragment       = errors.New("opaqueeap: invalid fragment")
+	errMessageTooLong = errors.New("opaqueeap: reassembled message too long")
+)
+
+// packet is an EAP packet. The flags, op, and total fields are only used by EAP-OPAQUE packets.
+type packet struct {
+	code       byte
+	identifier byte
+	typ        byte
+	flags      byte
+	op         byte
+	total      int
+	data       []byte
+}
+
+func (p *packet) encode() []byte {
+	var body []byte
+
+	switch {
+	case p.code == CodeSuccess || p.code == CodeFailure:
+	case p.typ == TypeOPAQUE:
+		body = []byte{p.typ, p.flags, p.op}
+		if p.flags&flagLength != 0 {
+			body = encoding.Concat(body, encoding.I2OSP(p.total, totalLengthLength))
+		}
+
+		body = encoding.Concat(body, p.data)
+	default:
+		body = encoding.Concat([]byte{p.typ}, p.data)
+	}
+
+	return encoding.Concatenate(
+		[]byte{p.code, p.identifier},
+		encoding.I2OSP(headerLength+len(body), 2),
+		body,
+	)
+}
+
+func decodePacket(in []byte) (*packet, error) {
+	if len(in) < headerLength || encoding.OS2IP(in[2:4]) != len(in) {
+		return nil, errPacketLength
+	}
+
+	p := &packet{code: in[0], identifier: in[1]}
+
+	switch p.code {
+	case CodeSuccess, CodeFailure:
+		if len(in) != headerLength {
+			return nil, errPacketLength
+		}
+
+		return p, nil
+	case CodeRequest, CodeResponse:
+	default:
+		return nil, errPacketCode
+	}
+
+	if len(in) == headerLength {
+		return nil, errPacketLength
+	}
+
+	p.typ = in[headerLength]
+	if p.typ != TypeOPAQUE {
+		p.data = in[headerLength+1:]
+		return p, nil
+	}
+
+	if len(in) < methodHeaderLength {
+		return nil, errPacketLength
+	}
+
+	p.flags, p.op = in[headerLength+1], in[headerLength+2]
+	offset := methodHeaderLength
+
+	if p.flags&flagLength != 0 {
+		if len(in) < offset+totalLengthLength {
+			return nil, errPacketLength
+		}
+
+		p.total = encoding.OS2IP(in[offset : offset+totalLengthLength])
+		offset += totalLengthLength
+	}
+
+	p.data = in[offset:]
+
+	return p, nil
+}
+
+// fragmenter sends and reassembles the EAP-OPAQUE messages in fragments fitting the MTU.
+type fragmenter struct {
+	out     []byte
+	in      []byte
+	mtu     int
+	outOp   byte
+	inOp    byte
+	total   int
+	inTotal int
+}
+
+// sending returns whether fragments of an outgoing message are still to be sent.
+func (f *fragmenter) sending() bool {
+	return len(f.out) != 0
+}
+
+func (f *fragmenter) send(op byte, message []byte) {
+	f.outOp = op
+	f.out = message
+	f.total = len(message)
+}
+
+// next returns the next fragment of the outgoing message. The first fragment of a fragmented message carries its
+// total length.
+func (f *fragmenter) next(code, identifier byte) *packet {
+	p := &packet{code: code, identifier: identifier, typ: TypeOPAQUE, op: f.outOp}
+	room := f.mtu - methodHeaderLength
+
+	if len(f.out) > room {
+		if len(f.out) == f.total {
+			p.flags |= flagLength
+			p.total = f.total
+			room -= totalLengthLength
+		}
+
+		p.flags |= flagMore
+	}
+
+	if room > len(f.out) {
+		room = len(f.out)
+	}
+
+	p.data, f.out = f.out[:room], f.out[room:]
+
+	return p
+}
+
+// receive adds a fragment to the incoming message, and returns the message once complete, or more as true if more
+// fragments are expected.
+func (f *fragmenter) receive(p *packet) (message []byte, more bool, err error) {
+	if len(f.in) != 0 && p.op != f.inOp {
+		return nil, false, errFragment
+	}
+
+	if p.flags&flagLength != 0 {
+		if len(f.in) != 0 {
+			return nil, false, errFragment
+		}
+
+		f.inTotal = p.total
+	}
+
+	f.inOp = p.op
+	f.in = encoding.Concat(f.in, p.data)
+
+	if len(f.in) > maxMessageLength || f.inTotal > maxMessageLength {
+		return nil, false, errMessageTooLong
+	}
+
+	if p.flags&flagMore != 0 {
+		return nil, true, nil
+	}
+
+	message, total := f.in, f.inTotal
+	f.in, f.inTotal = nil, 0
+
+	if total != 0 && len(message) != total {
+		return nil, false, errFragment
+	}
+
+	return message, false, nil
+}
+
+func ack(code, identifier byte) *packet {
+	return &packet{code: code, identifier: identifier, typ: TypeOPAQUE, op: opAck}
+}
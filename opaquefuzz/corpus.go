@@ -0,0 +1,311 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaquefuzz
+
+import (
+	"crypto"
+	"sync"
+
+	"github.com/bytemare/crypto/ksf"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/keyrecovery"
+)
+
+// Seed holds valid serialized messages, keys, and envelope recovery inputs for a configuration.
+type Seed struct {
+	Configuration []byte
+
+	RegistrationRequest  []byte
+	RegistrationResponse []byte
+	RegistrationRecord   []byte
+
+	KE1 []byte
+	KE2 []byte
+	KE3 []byte
+	KE4 []byte
+
+	ReauthRequest  []byte
+	ReauthResponse []byte
+	ReauthFinish   []byte
+
+	OPRFKeyShare      []byte
+	PartialEvaluation []byte
+
+	ServerSecretKey []byte
+	ServerPublicKey []byte
+
+	// Envelope is sealed under RandomizedPassword, ServerPublicKey, and the identities, and is recovered by them.
+	Envelope           []byte
+	RandomizedPassword []byte
+	ClientIdentity     []byte
+	ServerIdentity     []byte
+}
+
+// Configurations returns the configurations the default corpus is generated for: the default configuration, its
+// variants with the external envelope mode and application data, the KEM-based AKE, HMQV, and ML-KEM-768, and the
+// NIST groups.
+func Configurations() []*opaque.Configuration {
+	external := opaque.DefaultConfiguration()
+	external.Mode = opaque.External
+	external.AppDataLength = 32
+
+	kemAKE := opaque.DefaultConfiguration()
+	kemAKE.Protocol = opaque.KEMAKE
+
+	hmqv := opaque.DefaultConfiguration()
+	hmqv.Protocol = opaque.HMQV
+
+	hybrid := opaque.DefaultConfiguration()
+	hybrid.KEM = opaque.MLKEM768
+	hybrid.Context = []byte("opaquefuzz")
+
+	return []*opaque.Configuration{
+		opaque.DefaultConfiguration(),
+		external,
+		kemAKE,
+		hmqv,
+		hybrid,
+		nistConfiguration(opaque.P256Sha256, crypto.SHA256),
+		nistConfiguration(opaque.P384Sha512, crypto.SHA512),
+		nistConfiguration(opaque.P521Sha512, crypto.SHA512),
+	}
+}
+
+func nistConfiguration(g opaque.Group, h crypto.Hash) *opaque.Configuration {
+	return &opaque.Configuration{
+		OPRF: g,
+		KDF:  h,
+		MAC:  h,
+		Hash: h,
+		KSF:  ksf.Scrypt,
+		AKE:  g,
+	}
+}
+
+var (
+	defaultCorpus    []*Seed
+	defaultCorpusErr error
+	defaultCorpusSet sync.Once
+)
+
+// DefaultCorpus returns the seeds generated once for the Configurations available in the build, e.g. ML-KEM-768
+// requires Go 1.24 or later.
+func DefaultCorpus() ([]*Seed, error) {
+	defaultCorpusSet.Do(func() {
+		for _, c := range Configurations() {
+			if _, err := c.Client(); err != nil {
+				continue
+			}
+
+			seed, err := Corpus(c)
+			if err != nil {
+				defaultCorpus, defaultCorpusErr = nil, err
+				return
+			}
+
+			defaultCorpus = append(defaultCorpus, seed)
+		}
+	})
+
+	return defaultCorpus, defaultCorpusErr
+}
+
+// Corpus returns the messages of a registration, a login with application messages and key confirmation, a
+// re-authentication, and a threshold evaluation run in the configuration, together with an envelope sealed under a
+// random randomized password.
+func Corpus(c *opaque.Configuration) (*Seed, error) {
+	s := &Seed{
+		Configuration:  c.Serialize(),
+		ClientIdentity: []byte("client"),
+		ServerIdentity: []byte("server"),
+	}
+
+	if err := s.handshake(c); err != nil {
+		return nil, err
+	}
+
+	if err := s.threshold(c); err != nil {
+		return nil, err
+	}
+
+	if err := s.envelope(c); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Seed) handshake(c *opaque.Configuration) error {
+	password := []byte("password")
+	credentialIdentifier := []byte("credential identifier")
+
+	oprfSeed, err := c.GenerateOPRFSeed()
+	if err != nil {
+		return err
+	}
+
+	s.ServerSecretKey, s.ServerPublicKey, err = c.KeyGen()
+	if err != nil {
+		return err
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		return err
+	}
+
+	server, err := c.Server()
+	if err != nil {
+		return err
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(s.ServerPublicKey)
+	if err != nil {
+		return err
+	}
+
+	r1 := client.RegistrationInit(password)
+
+	r2, err := server.RegistrationResponse(r1, pks, credentialIdentifier, oprfSeed)
+	if err != nil {
+		return err
+	}
+
+	r3, _, err := client.RegistrationFinalize(r2, s.ClientIdentity, s.ServerIdentity)
+	if err != nil {
+		return err
+	}
+
+	s.RegistrationRequest, s.RegistrationResponse, s.RegistrationRecord = r1.Serialize(), r2.Serialize(),
+		r3.Serialize()
+	record := &opaque.ClientRecord{
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       s.ClientIdentity,
+		RegistrationRecord:   r3,
+	}
+
+	ke1, err := client.LoginInitWithInfo(password, []byte("client info"))
+	if err != nil {
+		return err
+	}
+
+	ke2, err := server.LoginInitWithInfo(ke1, s.ServerIdentity, s.ServerSecretKey, s.ServerPublicKey, oprfSeed,
+		record, []byte("server info"))
+	if err != nil {
+		return err
+	}
+
+	ke3, _, err := client.LoginFinish(s.ClientIdentity, s.ServerIdentity, ke2)
+	if err != nil {
+		return err
+	}
+
+	ke4, err := server.LoginFinishWithConfirmation(ke3)
+	if err != nil {
+		return err
+	}
+
+	s.KE1, s.KE2, s.KE3, s.KE4 = ke1.Serialize(), ke2.Serialize(), ke3.Serialize(), ke4.Serialize()
+
+	return s.reauth(c, client, server)
+}
+
+func (s *Seed) reauth(c *opaque.Configuration, client *opaque.Client, server *opaque.Server) error {
+	clientSecret, err := client.ReauthSecret()
+	if err != nil {
+		return err
+	}
+
+	serverSecret, err := server.ReauthSecret()
+	if err != nil {
+		return err
+	}
+
+	initiator, err := c.Reauthenticator(clientSecret)
+	if err != nil {
+		return err
+	}
+
+	responder, err := c.Reauthenticator(serverSecret)
+	if err != nil {
+		return err
+	}
+
+	request := initiator.ReauthInit()
+
+	response, err := responder.ReauthRespond(request)
+	if err != nil {
+		return err
+	}
+
+	finish, err := initiator.ReauthFinish(response)
+	if err != nil {
+		return err
+	}
+
+	s.ReauthRequest, s.ReauthResponse, s.ReauthFinish = request.Serialize(), response.Serialize(), finish.Serialize()
+
+	return nil
+}
+
+func (s *Seed) threshold(c *opaque.Configuration) error {
+	shares, err := c.GenerateOPRFKeyShares(2, 3)
+	if err != nil {
+		return err
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		return err
+	}
+
+	server, err := c.Server()
+	if err != nil {
+		return err
+	}
+
+	evaluation, err := server.PartialEvaluate(client.RegistrationInit([]byte("password")).BlindedMessage, shares[0])
+	if err != nil {
+		return err
+	}
+
+	s.OPRFKeyShare, s.PartialEvaluation = shares[0].Serialize(), evaluation.Serialize()
+
+	return nil
+}
+
+func (s *Seed) envelope(c *opaque.Configuration) error {
+	client, err := c.Client()
+	if err != nil {
+		return err
+	}
+
+	conf := client.GetConf()
+
+	s.RandomizedPassword, err = opaque.RandomBytes(conf.KDF.Size())
+	if err != nil {
+		return err
+	}
+
+	appData := []byte("application data")
+	if len(appData) > conf.AppDataLength {
+		appData = appData[:conf.AppDataLength]
+	}
+
+	env, _, _, err := keyrecovery.Store(conf, s.RandomizedPassword, s.ServerPublicKey, nil, appData,
+		&keyrecovery.Identities{Client: s.ClientIdentity, Server: s.ServerIdentity}, nil)
+	if err != nil {
+		return err
+	}
+
+	s.Envelope = env.Serialize()
+
+	return nil
+}
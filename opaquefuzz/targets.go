@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaquefuzz provides native Go fuzz targets for the message deserializers, the envelope recovery, and the
+// configuration decoder, seeded with a corpus generated from valid handshakes.
+//
+// The targets are exported so that they can be run against any build of the library, by wrapping them in a test file:
+//
+//	func FuzzKE1(f *testing.F) { opaquefuzz.KE1(f) }
+//
+// and running e.g. go test -fuzz FuzzKE1. The message targets take the serialized configuration as their first input,
+// so the fuzzer explores the configurations too. Besides the absence of panics, they check that accepted messages
+// pass Deserializer.Check and serialize back to the input.
+package opaquefuzz
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/keyrecovery"
+)
+
+type serializer interface {
+	Serialize() []byte
+}
+
+// addSeeds adds the field selected by input of every seed of the default corpus to the fuzzing corpus.
+func addSeeds(f *testing.F, input func(*Seed) []byte) {
+	f.Helper()
+
+	seeds, err := DefaultCorpus()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, s := range seeds {
+		f.Add(s.Configuration, input(s))
+	}
+}
+
+// client returns a client for the serialized configuration, or skips the input if it's invalid.
+func client(t *testing.T, configuration []byte) *opaque.Client {
+	t.Helper()
+
+	c, err := opaque.DeserializeConfiguration(configuration)
+	if err != nil {
+		t.Skip()
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		t.Skip()
+	}
+
+	return client
+}
+
+// deserializer returns a Deserializer for the serialized configuration, or skips the input if it's invalid.
+func deserializer(t *testing.T, configuration []byte) *opaque.Deserializer {
+	t.Helper()
+
+	c, err := opaque.DeserializeConfiguration(configuration)
+	if err != nil {
+		t.Skip()
+	}
+
+	d, err := c.Deserializer()
+	if err != nil {
+		t.Skip()
+	}
+
+	return d
+}
+
+// fuzzMessage fuzzes a message deserializer. If typ is not 0, the inputs accepted by decode must pass Check.
+func fuzzMessage(
+	f *testing.F,
+	typ opaque.MessageType,
+	input func(*Seed) []byte,
+	decode func(d *opaque.Deserializer, input []byte) (serializer, error),
+) {
+	f.Helper()
+	addSeeds(f, input)
+
+	f.Fuzz(func(t *testing.T, configuration, input []byte) {
+		d := deserializer(t, configuration)
+
+		m, err := decode(d, input)
+		if err != nil {
+			return
+		}
+
+		if typ != 0 {
+			if err = d.Check(typ, input); err != nil {
+				t.Fatalf("accepted message fails the structural check: %v", err)
+			}
+		}
+
+		if !bytes.Equal(m.Serialize(), input) {
+			t.Fatalf("accepted message serializes to %x, want %x", m.Serialize(), input)
+		}
+	})
+}
+
+// Configuration fuzzes DeserializeConfiguration, and the setup of clients and servers from the configurations it
+// accepts.
+func Configuration(f *testing.F) {
+	seeds, err := DefaultCorpus()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, s := range seeds {
+		f.Add(s.Configuration)
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		c, err := opaque.DeserializeConfiguration(input)
+		if err != nil {
+			return
+		}
+
+		if !bytes.Equal(c.Serialize(), input) {
+			t.Fatalf("accepted configuration serializes to %x, want %x", c.Serialize(), input)
+		}
+
+		_, _ = c.Client()
+		_, _ = c.Server()
+		_, _ = c.Deserializer()
+	})
+}
+
+// RegistrationRequest fuzzes Deserializer.RegistrationRequest.
+func RegistrationRequest(f *testing.F) {
+	fuzzMessage(f, opaque.RegistrationRequestMessage, func(s *Seed) []byte { return s.RegistrationRequest },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.RegistrationRequest(input) })
+}
+
+// RegistrationResponse fuzzes Deserializer.RegistrationResponse.
+func RegistrationResponse(f *testing.F) {
+	fuzzMessage(f, opaque.RegistrationResponseMessage, func(s *Seed) []byte { return s.RegistrationResponse },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.RegistrationResponse(input) })
+}
+
+// RegistrationRecord fuzzes Deserializer.RegistrationRecord.
+func RegistrationRecord(f *testing.F) {
+	fuzzMessage(f, opaque.RegistrationRecordMessage, func(s *Seed) []byte { return s.RegistrationRecord },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.RegistrationRecord(input) })
+}
+
+// KE1 fuzzes Deserializer.KE1.
+func KE1(f *testing.F) {
+	fuzzMessage(f, opaque.KE1Message, func(s *Seed) []byte { return s.KE1 },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.KE1(input) })
+}
+
+// KE2 fuzzes Deserializer.KE2.
+func KE2(f *testing.F) {
+	fuzzMessage(f, opaque.KE2Message, func(s *Seed) []byte { return s.KE2 },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.KE2(input) })
+}
+
+// KE3 fuzzes Deserializer.KE3.
+func KE3(f *testing.F) {
+	fuzzMessage(f, opaque.KE3Message, func(s *Seed) []byte { return s.KE3 },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.KE3(input) })
+}
+
+// KE4 fuzzes Deserializer.KE4.
+func KE4(f *testing.F) {
+	fuzzMessage(f, opaque.KE4Message, func(s *Seed) []byte { return s.KE4 },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.KE4(input) })
+}
+
+// ReauthRequest fuzzes Deserializer.ReauthRequest.
+func ReauthRequest(f *testing.F) {
+	fuzzMessage(f, opaque.ReauthRequestMessage, func(s *Seed) []byte { return s.ReauthRequest },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.ReauthRequest(input) })
+}
+
+// ReauthResponse fuzzes Deserializer.ReauthResponse.
+func ReauthResponse(f *testing.F) {
+	fuzzMessage(f, opaque.ReauthResponseMessage, func(s *Seed) []byte { return s.ReauthResponse },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.ReauthResponse(input) })
+}
+
+// ReauthFinish fuzzes Deserializer.ReauthFinish.
+func ReauthFinish(f *testing.F) {
+	fuzzMessage(f, opaque.ReauthFinishMessage, func(s *Seed) []byte { return s.ReauthFinish },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.ReauthFinish(input) })
+}
+
+// PartialEvaluation fuzzes Deserializer.PartialEvaluation.
+func PartialEvaluation(f *testing.F) {
+	fuzzMessage(f, opaque.PartialEvaluationMessage, func(s *Seed) []byte { return s.PartialEvaluation },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.PartialEvaluation(input) })
+}
+
+// OPRFKeyShare fuzzes Deserializer.OPRFKeyShare.
+func OPRFKeyShare(f *testing.F) {
+	fuzzMessage(f, 0, func(s *Seed) []byte { return s.OPRFKeyShare },
+		func(d *opaque.Deserializer, input []byte) (serializer, error) { return d.OPRFKeyShare(input) })
+}
+
+// AkePublicKey fuzzes Deserializer.DecodeAkePublicKey.
+func AkePublicKey(f *testing.F) {
+	addSeeds(f, func(s *Seed) []byte { return s.ServerPublicKey })
+
+	f.Fuzz(func(t *testing.T, configuration, input []byte) {
+		c := client(t, configuration)
+
+		p, err := c.Deserialize.DecodeAkePublicKey(input)
+		if err != nil {
+			return
+		}
+
+		if p.IsIdentity() || !bytes.Equal(encoding.SerializePoint(p, c.GetConf().Group), input) {
+			t.Fatalf("accepted public key %x isn't canonical", input)
+		}
+	})
+}
+
+// AkePrivateKey fuzzes Deserializer.DecodeAkePrivateKey.
+func AkePrivateKey(f *testing.F) {
+	addSeeds(f, func(s *Seed) []byte { return s.ServerSecretKey })
+
+	f.Fuzz(func(t *testing.T, configuration, input []byte) {
+		c := client(t, configuration)
+
+		s, err := deserializer(t, configuration).DecodeAkePrivateKey(input)
+		if err != nil {
+			return
+		}
+
+		if s.IsZero() || !bytes.Equal(encoding.SerializeScalar(s, c.GetConf().Group), input) {
+			t.Fatalf("accepted private key %x isn't canonical", input)
+		}
+	})
+}
+
+// EnvelopeRecovery fuzzes the recovery of the client's keys and export key from an envelope. The envelopes it
+// recovers must be those sealed by the same inputs.
+func EnvelopeRecovery(f *testing.F) {
+	seeds, err := DefaultCorpus()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, s := range seeds {
+		f.Add(s.Configuration, s.Envelope, s.RandomizedPassword, s.ServerPublicKey, s.ClientIdentity, s.ServerIdentity)
+	}
+
+	f.Fuzz(func(t *testing.T, configuration, envelope, randomizedPassword, serverPublicKey, idc, ids []byte) {
+		conf := client(t, configuration).GetConf()
+		if len(envelope) != conf.EnvelopeSize {
+			t.Skip()
+		}
+
+		env := keyrecovery.Deserialize(conf, envelope)
+
+		sk, _, export, appData, err := keyrecovery.Recover(conf, randomizedPassword, serverPublicKey,
+			&keyrecovery.Identities{Client: idc, Server: ids}, nil, env)
+		if err != nil {
+			return
+		}
+
+		sealed, _, sealedExport, err := keyrecovery.Store(conf, randomizedPassword, serverPublicKey, sk, appData,
+			&keyrecovery.Identities{Client: idc, Server: ids}, env.Nonce)
+		if err != nil {
+			t.Fatalf("can't seal the recovered envelope: %v", err)
+		}
+
+		if !bytes.Equal(sealed.Serialize(), envelope) || !bytes.Equal(sealedExport, export) {
+			t.Fatal("recovered envelope differs from the one sealed by the same inputs")
+		}
+	})
+}
@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaquehttp
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// flagSuffix is appended to the name of a record file to name the file marking it for re-registration.
+const flagSuffix = ".rehash"
+
+var errCorruptRecord = errors.New("corrupt record file")
+
+// FileCredentialStore is a CredentialStore keeping each record in a file of a directory, named after the SHA-256 hash
+// of its credential identifier, for small deployments and reference servers. It is also a CredentialWalker and a
+// RehashStore, marking the records flagged for re-registration with an empty file next to them.
+type FileCredentialStore struct {
+	deserializer *opaque.Deserializer
+	dir          string
+}
+
+// NewFileCredentialStore returns a FileCredentialStore of the records of the configuration in the directory, which is
+// created if it doesn't exist.
+func NewFileCredentialStore(dir string, conf *opaque.Configuration) (*FileCredentialStore, error) {
+	d, err := conf.Deserializer()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileCredentialStore{deserializer: d, dir: dir}, nil
+}
+
+func (f *FileCredentialStore) path(credentialIdentifier []byte) string {
+	h := sha256.Sum256(credentialIdentifier)
+	return filepath.Join(f.dir, hex.EncodeToString(h[:]))
+}
+
+// Get returns the record for the credential identifier, or ErrCredentialNotFound.
+func (f *FileCredentialStore) Get(credentialIdentifier []byte) (*opaque.ClientRecord, error) {
+	encoded, err := os.ReadFile(f.path(credentialIdentifier))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCredentialNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := f.decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(record.CredentialIdentifier, credentialIdentifier) != 1 {
+		return nil, errCorruptRecord
+	}
+
+	return record, nil
+}
+
+func (f *FileCredentialStore) decode(encoded []byte) (*opaque.ClientRecord, error) {
+	credentialIdentifier, offset, err := encoding.DecodeVector(encoded)
+	if err != nil {
+		return nil, errCorruptRecord
+	}
+
+	clientIdentity, length, err := encoding.DecodeVector(encoded[offset:])
+	if err != nil {
+		return nil, errCorruptRecord
+	}
+
+	record, err := f.deserializer.RegistrationRecord(encoded[offset+length:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+
+	if len(clientIdentity) == 0 {
+		clientIdentity = nil
+	}
+
+	return &opaque.ClientRecord{
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       clientIdentity,
+		RegistrationRecord:   record,
+	}, nil
+}
+
+// Put stores a new record, or returns ErrCredentialExists if one is already stored for its credential identifier.
+// The record is written to a temporary file first, so that a record file is always complete.
+func (f *FileCredentialStore) Put(record *opaque.ClientRecord) error {
+	tmp, err := f.writeTemp(record)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp)
+
+	// Linking fails if the record file exists, so that concurrent registrations can't overwrite each other.
+	if err = os.Link(tmp, f.path(record.CredentialIdentifier)); errors.Is(err, os.ErrExist) {
+		return ErrCredentialExists
+	}
+
+	return err
+}
+
+// writeTemp writes the record to a new temporary file in the directory, and returns its path.
+func (f *FileCredentialStore) writeTemp(record *opaque.ClientRecord) (string, error) {
+	tmp, err := os.CreateTemp(f.dir, ".record-*")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tmp.Write(encoding.Concat3(
+		encoding.EncodeVector(record.CredentialIdentifier),
+		encoding.EncodeVector(record.ClientIdentity),
+		record.RegistrationRecord.Serialize(),
+	))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// Walk calls fn with each record file of the directory, or with the error reading it, e.g. for a record of another
+// configuration, and stops at the first error fn returns.
+func (f *FileCredentialStore) Walk(fn func(record *opaque.ClientRecord, err error) error) error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), flagSuffix) {
+			continue
+		}
+
+		encoded, err := os.ReadFile(filepath.Join(f.dir, e.Name()))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		var record *opaque.ClientRecord
+		if err == nil {
+			record, err = f.decode(encoded)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("%s: %w", e.Name(), err)
+		}
+
+		if err = fn(record, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flag marks the record of the credential identifier for re-registration, or returns ErrCredentialNotFound.
+func (f *FileCredentialStore) Flag(credentialIdentifier []byte) error {
+	path := f.path(credentialIdentifier)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return ErrCredentialNotFound
+	}
+
+	return os.WriteFile(path+flagSuffix, nil, 0o600)
+}
+
+// Flagged returns whether the record of the credential identifier is marked for re-registration.
+func (f *FileCredentialStore) Flagged(credentialIdentifier []byte) (bool, error) {
+	_, err := os.Stat(f.path(credentialIdentifier) + flagSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Replace stores the new record of a flagged credential identifier in place of the old one and clears its flag, or
+// returns ErrCredentialNotFlagged. The record file is replaced by renaming a complete temporary file over it.
+func (f *FileCredentialStore) Replace(record *opaque.ClientRecord) error {
+	path := f.path(record.CredentialIdentifier)
+
+	tmp, err := f.writeTemp(record)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp)
+
+	// Removing the flag first ensures that a record is replaced only once, by the first of concurrent calls.
+	if err = os.Remove(path + flagSuffix); errors.Is(err, os.ErrNotExist) {
+		return ErrCredentialNotFlagged
+	} else if err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.WriteFile(path+flagSuffix, nil, 0o600)
+		return err
+	}
+
+	return nil
+}
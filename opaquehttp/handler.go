@@ -0,0 +1,506 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaquehttp provides net/http handlers running the OPAQUE registration and login flows.
+//
+// Each handler takes a POSTed JSON object whose byte fields are base64 encoded, and answers with a JSON object:
+//
+//	/register/init   {"credential_identifier", "message": RegistrationRequest} -> {"session", "message": RegistrationResponse}
+//	                 {"message": RegistrationRequest} -> {"session", "credential_identifier", "message": RegistrationResponse}
+//	/register/finish {"session", "credential_identifier", "client_identity", "message": RegistrationRecord} -> 204
+//	/login/init      {"credential_identifier", "message": KE1, "cookie"} -> {"session", "message": KE2}, or {"cookie"}
+//	/login/finish    {"session", "message": KE3} -> OnLogin, or 204
+//
+// The configuration handler answers GET requests with the server's configuration advertisement, and the parameters
+// the clients need besides it, for them to check against their opaque.ConfigurationPolicy before the handshake:
+//
+//	/configuration   -> {"configuration", "fingerprint", "server_public_key", "server_identity"}
+//
+// A registration session binds the record to the registration response the server issued: the record is only stored
+// under the credential identifier the response was issued for, once, and before the session expires. The server can't
+// verify that the client derived the record from the response, since only the client knows the password.
+//
+// With a RehashStore, the login finish handler answers the successful logins of the records flagged for re-registration,
+// e.g. by Migrate, with the RehashHeader, holding a session in which the client registers again: it sends it with its
+// credential identifier to the register init handler, and the new record replaces the flagged one on finish.
+//
+// With MintCredentialIdentifiers, the client doesn't choose its credential identifier: the register init handler mints
+// a random one, which the client sends back with its record and stores to log in.
+//
+// With a CookieKey, the login init handler answers the KE1 messages without a valid cookie for their source with only a
+// cookie, without processing them, and the client sends its KE1 again with the cookie: see opaque.NewCookie.
+//
+// The registration handlers store whatever record they are given under the requested credential identifier: they
+// must be mounted behind whatever authorization the application requires to create an account.
+package opaquehttp
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+// maxBodySize is the maximum size of a request body.
+const maxBodySize = 1 << 16
+
+// sessionIDLength is the number of random bytes in a session identifier.
+const sessionIDLength = 32
+
+// RehashHeader is the header of the login finish response holding the session in which the client must register again.
+const RehashHeader = "Opaque-Rehash"
+
+// replaceRecord is the state of a registration session whose record replaces a flagged one.
+const replaceRecord = 1
+
+// The kinds of sessions, prefixed to their state.
+const (
+	loginSession byte = 1 + iota
+	registrationSession
+	rehashSession
+)
+
+var errInvalidSession = errors.New("invalid session state")
+
+// Handler serves the OPAQUE flows for a server, given its long-term credentials and where to keep client records
+// and registration and login sessions.
+type Handler struct {
+	Configuration *opaque.Configuration
+	Credentials   CredentialStore
+	Sessions      SessionStore
+
+	// OnLogin is called after a successful login, e.g. to set a session cookie derived from the session key. If it
+	// is nil, the login finish handler answers with an empty 204 response.
+	OnLogin func(w http.ResponseWriter, r *http.Request, credentialIdentifier, sessionKey []byte)
+
+	ServerIdentity  []byte
+	ServerSecretKey []byte
+	ServerPublicKey []byte
+	OPRFSeed        []byte
+
+	// CookieKey, if set, is the 32-byte secret of the cookies required with KE1, valid for CookieTTL, or a minute if
+	// zero. RequireCookie selects the requests that need one, e.g. from sources not verified otherwise or while the
+	// server is under load, and defaults to all of them. The source a cookie is bound to is the request's remote IP
+	// address.
+	CookieKey     []byte
+	CookieTTL     time.Duration
+	RequireCookie func(r *http.Request) bool
+
+	// MintCredentialIdentifiers, if set, has the register init handler mint a random credential identifier for each
+	// registration, and reject the requests with one: see opaque.Server.MintRegistrationResponse.
+	MintCredentialIdentifiers bool
+
+	// Rehash, if set, keeps the records flagged for re-registration, e.g. the CredentialStore if it implements it.
+	Rehash RehashStore
+
+	// ConcealRegistered, if set, answers the registration of a credential identifier that is already registered like
+	// a new one, with 204 instead of 409 Conflict, and keeps the stored record, so that the registration handlers
+	// can't be used to enumerate the registered credential identifiers.
+	ConcealRegistered bool
+}
+
+type request struct {
+	CredentialIdentifier []byte `json:"credential_identifier,omitempty"`
+	ClientIdentity       []byte `json:"client_identity,omitempty"`
+	Session              string `json:"session,omitempty"`
+	Message              []byte `json:"message"`
+	Cookie               []byte `json:"cookie,omitempty"`
+}
+
+// advertisement is the response of the configuration handler.
+type advertisement struct {
+	message.ConfigurationAdvertisement
+
+	ServerPublicKey []byte `json:"server_public_key"`
+	ServerIdentity  []byte `json:"server_identity,omitempty"`
+}
+
+type response struct {
+	CredentialIdentifier []byte `json:"credential_identifier,omitempty"`
+	Session              string `json:"session,omitempty"`
+	Message              []byte `json:"message,omitempty"`
+	Cookie               []byte `json:"cookie,omitempty"`
+}
+
+// Mount registers the handlers on the mux, under the given path prefix, e.g. "/auth".
+func (h *Handler) Mount(mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	mux.HandleFunc(prefix+"/register/init", h.RegisterInit)
+	mux.HandleFunc(prefix+"/register/finish", h.RegisterFinish)
+	mux.HandleFunc(prefix+"/login/init", h.LoginInit)
+	mux.HandleFunc(prefix+"/login/finish", h.LoginFinish)
+	mux.HandleFunc(prefix+"/configuration", h.Advertise)
+}
+
+// Advertise answers a GET request with the advertisement of the server's configuration, its public key, and its
+// identity if set.
+func (h *Handler) Advertise(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		httpError(w, http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	writeJSON(w, &advertisement{
+		ConfigurationAdvertisement: *h.Configuration.Advertisement(),
+		ServerPublicKey:            h.ServerPublicKey,
+		ServerIdentity:             h.ServerIdentity,
+	})
+}
+
+// RegisterInit answers a RegistrationRequest with a RegistrationResponse, and the credential identifier it minted if
+// MintCredentialIdentifiers is set. The response doesn't depend on whether the credential identifier is registered:
+// see opaque.Server.RegistrationResponse. A request with the session of the RehashHeader re-registers its credential
+// identifier.
+func (h *Handler) RegisterInit(w http.ResponseWriter, r *http.Request) {
+	req, server, ok := h.start(w, r)
+	if !ok {
+		return
+	}
+
+	m, err := server.Deserialize.RegistrationRequest(req.Message)
+	if err != nil || (req.Session == "" && (len(req.CredentialIdentifier) == 0) != h.MintCredentialIdentifiers) {
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(h.ServerPublicKey)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		resp                 *message.RegistrationResponse
+		credentialIdentifier = req.CredentialIdentifier
+		state                []byte
+	)
+
+	switch {
+	case req.Session != "":
+		var flagged []byte
+		if flagged, _, err = h.takeSession(rehashSession, req.Session); err != nil ||
+			subtle.ConstantTimeCompare(flagged, req.CredentialIdentifier) != 1 {
+			httpError(w, http.StatusUnauthorized)
+			return
+		}
+
+		resp, err = server.RegistrationResponse(m, pks, credentialIdentifier, h.OPRFSeed)
+		state = []byte{replaceRecord}
+	case h.MintCredentialIdentifiers:
+		resp, credentialIdentifier, err = server.MintRegistrationResponse(m, pks, h.OPRFSeed)
+	default:
+		resp, err = server.RegistrationResponse(m, pks, credentialIdentifier, h.OPRFSeed)
+	}
+
+	if err != nil {
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	id, err := h.openSession(registrationSession, credentialIdentifier, state)
+
+	switch {
+	case errors.Is(err, ErrTooManySessions):
+		httpError(w, http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	res := &response{Session: id, Message: resp.Serialize()}
+	if h.MintCredentialIdentifiers && req.Session == "" {
+		res.CredentialIdentifier = credentialIdentifier
+	}
+
+	writeJSON(w, res)
+}
+
+// RegisterFinish verifies the client's RegistrationRecord, and stores it if the registration session was opened for
+// its credential identifier, in place of the flagged record for a re-registration.
+func (h *Handler) RegisterFinish(w http.ResponseWriter, r *http.Request) {
+	req, server, ok := h.start(w, r)
+	if !ok {
+		return
+	}
+
+	m, err := server.Deserialize.RegistrationRecord(req.Message)
+	if err == nil {
+		err = server.VerifyRegistrationRecord(m)
+	}
+
+	if err != nil || len(req.CredentialIdentifier) == 0 {
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	credentialIdentifier, state, err := h.takeSession(registrationSession, req.Session)
+	if err != nil || subtle.ConstantTimeCompare(credentialIdentifier, req.CredentialIdentifier) != 1 {
+		httpError(w, http.StatusUnauthorized)
+		return
+	}
+
+	record := &opaque.ClientRecord{
+		CredentialIdentifier: req.CredentialIdentifier,
+		ClientIdentity:       req.ClientIdentity,
+		RegistrationRecord:   m,
+	}
+
+	if len(state) == 1 && state[0] == replaceRecord {
+		err = h.Rehash.Replace(record)
+	} else {
+		err = h.Credentials.Put(record)
+	}
+
+	switch {
+	case errors.Is(err, ErrCredentialNotFlagged):
+		httpError(w, http.StatusConflict)
+	case errors.Is(err, ErrCredentialExists) && h.ConcealRegistered:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, ErrCredentialExists):
+		httpError(w, http.StatusConflict)
+	case err != nil:
+		httpError(w, http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// LoginInit answers a KE1 with a KE2, and opens a login session. Unknown credential identifiers are answered with a
+// fake record, so that they can't be told apart from registered ones. If the session store holds too many pending
+// sessions, it answers 503 Service Unavailable. If a cookie is required and the request has no valid one, it only
+// answers with a new cookie.
+func (h *Handler) LoginInit(w http.ResponseWriter, r *http.Request) {
+	req, server, ok := h.start(w, r)
+	if !ok || !h.checkCookie(w, r, req) {
+		return
+	}
+
+	ke1, err := server.Deserialize.KE1(req.Message)
+	if err != nil || len(req.CredentialIdentifier) == 0 {
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	record, err := h.Credentials.Get(req.CredentialIdentifier)
+
+	switch {
+	case errors.Is(err, ErrCredentialNotFound):
+		record, err = h.Configuration.GetFakeRecord(req.CredentialIdentifier)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError)
+			return
+		}
+	case err != nil:
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	ke2, err := server.LoginInit(ke1, h.ServerIdentity, h.ServerSecretKey, h.ServerPublicKey, h.OPRFSeed, record)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	id, err := h.openSession(loginSession, req.CredentialIdentifier, server.SerializeState())
+
+	switch {
+	case errors.Is(err, ErrTooManySessions):
+		httpError(w, http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, &response{Session: id, Message: ke2.Serialize()})
+}
+
+// LoginFinish verifies the client's KE3 in the login session, and closes it. If the record is flagged for
+// re-registration, the response has the RehashHeader.
+func (h *Handler) LoginFinish(w http.ResponseWriter, r *http.Request) {
+	req, server, ok := h.start(w, r)
+	if !ok {
+		return
+	}
+
+	ke3, err := server.Deserialize.KE3(req.Message)
+	if err != nil {
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	credentialIdentifier, state, err := h.takeSession(loginSession, req.Session)
+	if err != nil {
+		httpError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err = h.restore(server, state); err != nil {
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	if err := server.LoginFinish(ke3); err != nil {
+		httpError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if h.Rehash != nil {
+		if err = h.signalRehash(w, credentialIdentifier); err != nil {
+			httpError(w, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if h.OnLogin == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.OnLogin(w, r, credentialIdentifier, server.SessionKey())
+}
+
+// signalRehash sets the RehashHeader with a new re-registration session if the record is flagged for re-registration.
+func (h *Handler) signalRehash(w http.ResponseWriter, credentialIdentifier []byte) error {
+	flagged, err := h.Rehash.Flagged(credentialIdentifier)
+	if err != nil || !flagged {
+		return err
+	}
+
+	id, err := h.openSession(rehashSession, credentialIdentifier, nil)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set(RehashHeader, id)
+
+	return nil
+}
+
+// checkCookie returns true if the request doesn't need a cookie or has a valid one, and otherwise answers with a new
+// cookie and returns false.
+func (h *Handler) checkCookie(w http.ResponseWriter, r *http.Request, req *request) bool {
+	if h.CookieKey == nil || (h.RequireCookie != nil && !h.RequireCookie(r)) {
+		return true
+	}
+
+	source, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		source = r.RemoteAddr
+	}
+
+	now := time.Now()
+	if opaque.VerifyCookie(h.CookieKey, req.Cookie, []byte(source), now) == nil {
+		return true
+	}
+
+	ttl := h.CookieTTL
+	if ttl == 0 {
+		ttl = time.Minute
+	}
+
+	cookie, err := opaque.NewCookie(h.CookieKey, []byte(source), now.Add(ttl))
+	if err != nil {
+		httpError(w, http.StatusInternalServerError)
+		return false
+	}
+
+	writeJSON(w, &response{Cookie: cookie})
+
+	return false
+}
+
+// openSession stores a new session of the kind for the credential identifier, with the state, and returns its
+// identifier.
+func (h *Handler) openSession(kind byte, credentialIdentifier, state []byte) (string, error) {
+	sessionID, err := opaque.RandomBytes(sessionIDLength)
+	if err != nil {
+		return "", err
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(sessionID)
+
+	return id, h.Sessions.Put(id, encoding.Concat3([]byte{kind}, encoding.EncodeVector(credentialIdentifier), state))
+}
+
+// takeSession removes the session of the kind, and returns its credential identifier and state.
+func (h *Handler) takeSession(kind byte, id string) (credentialIdentifier, state []byte, err error) {
+	session, err := h.Sessions.Take(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(session) == 0 || session[0] != kind {
+		return nil, nil, errInvalidSession
+	}
+
+	credentialIdentifier, offset, err := encoding.DecodeVector(session[1:])
+	if err != nil {
+		return nil, nil, errInvalidSession
+	}
+
+	return credentialIdentifier, session[1+offset:], nil
+}
+
+// restore sets the AKE state of a login session in the server.
+func (h *Handler) restore(server *opaque.Server, state []byte) error {
+	if err := server.SetAKEState(state); err != nil {
+		return err
+	}
+
+	if h.Configuration.Protocol == opaque.KEMAKE {
+		return server.SetAKEServerKey(h.ServerSecretKey)
+	}
+
+	return nil
+}
+
+// start decodes the request and sets up a server for it, or writes the error response and returns false.
+func (h *Handler) start(w http.ResponseWriter, r *http.Request) (*request, *opaque.Server, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed)
+
+		return nil, nil, false
+	}
+
+	req := new(request)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(req); err != nil {
+		httpError(w, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	server, err := h.Configuration.Server()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return req, server, true
+}
+
+func httpError(w http.ResponseWriter, status int) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
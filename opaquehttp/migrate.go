@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaquehttp
+
+import "github.com/bytemare/opaque"
+
+// MigrationReport counts the records walked by Migrate.
+type MigrationReport struct {
+	// Records is the number of records walked.
+	Records int
+
+	// Flagged is the number of records flagged for re-registration, or that would be in a dry run.
+	Flagged int
+
+	// Invalid is the number of records that couldn't be read, or that aren't valid in the configuration, e.g. after
+	// a configuration drift. They are not flagged, since their clients can't log in to register again.
+	Invalid int
+}
+
+// Migrate walks the records of the store, and flags in rehash the ones valid in the configuration for which deprecated
+// returns true, or all of them if deprecated is nil. Records can't be converted offline, since that needs the client's
+// password: a Handler with the RehashStore then signals the clients of the flagged records to register again on their
+// next login. A nil rehash only reports what would be flagged.
+func Migrate(
+	conf *opaque.Configuration,
+	store CredentialWalker,
+	rehash RehashStore,
+	deprecated func(record *opaque.ClientRecord) bool,
+) (*MigrationReport, error) {
+	report := new(MigrationReport)
+
+	err := store.Walk(func(record *opaque.ClientRecord, err error) error {
+		report.Records++
+
+		if err != nil || opaque.ValidateRecord(conf, record) != nil {
+			report.Invalid++
+			return nil
+		}
+
+		if deprecated != nil && !deprecated(record) {
+			return nil
+		}
+
+		if rehash != nil {
+			if err = rehash.Flag(record.CredentialIdentifier); err != nil {
+				return err
+			}
+		}
+
+		report.Flagged++
+
+		return nil
+	})
+
+	return report, err
+}
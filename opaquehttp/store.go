@@ -0,0 +1,308 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaquehttp
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bytemare/opaque"
+)
+
+var (
+	// ErrCredentialNotFound indicates that no record is stored for a credential identifier.
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	// ErrCredentialExists indicates that a record is already stored for a credential identifier.
+	ErrCredentialExists = errors.New("credential already exists")
+
+	// ErrSessionNotFound indicates that a session is unknown or has expired.
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrTooManySessions indicates that a session store holds its maximum number of pending sessions.
+	ErrTooManySessions = errors.New("too many pending sessions")
+
+	// ErrCredentialNotFlagged indicates that a record replaced in a RehashStore isn't flagged for re-registration.
+	ErrCredentialNotFlagged = errors.New("credential is not flagged for re-registration")
+)
+
+// CredentialStore persists client records, indexed by their credential identifier.
+type CredentialStore interface {
+	// Get returns the record for the credential identifier, or ErrCredentialNotFound.
+	Get(credentialIdentifier []byte) (*opaque.ClientRecord, error)
+
+	// Put stores a new record, or returns ErrCredentialExists if one is already stored for its credential identifier.
+	Put(record *opaque.ClientRecord) error
+}
+
+// CredentialWalker is implemented by the CredentialStores whose records can be enumerated, e.g. by Migrate.
+type CredentialWalker interface {
+	// Walk calls fn with each stored record, or with the error reading it, and stops at the first error fn returns.
+	Walk(fn func(record *opaque.ClientRecord, err error) error) error
+}
+
+// RehashStore keeps the credential identifiers whose records must be registered again on the next login, e.g. as
+// flagged by Migrate, and replaces their records.
+type RehashStore interface {
+	// Flag marks the record of the credential identifier for re-registration.
+	Flag(credentialIdentifier []byte) error
+
+	// Flagged returns whether the record of the credential identifier is marked for re-registration.
+	Flagged(credentialIdentifier []byte) (bool, error)
+
+	// Replace stores the new record of a flagged credential identifier in place of the old one and clears its flag,
+	// or returns ErrCredentialNotFlagged.
+	Replace(record *opaque.ClientRecord) error
+}
+
+// SessionStore holds the server's state between the init and finish requests of a registration or login.
+type SessionStore interface {
+	// Put stores the state of a new session.
+	Put(id string, state []byte) error
+
+	// Take returns and removes the state of a session, or returns ErrSessionNotFound.
+	Take(id string) ([]byte, error)
+}
+
+// MemoryCredentialStore is an in-memory CredentialStore, CredentialWalker, and RehashStore, for tests and prototypes.
+type MemoryCredentialStore struct {
+	records map[string]*opaque.ClientRecord
+	flagged map[string]bool
+	mu      sync.RWMutex
+}
+
+// NewMemoryCredentialStore returns an empty in-memory CredentialStore.
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{
+		records: make(map[string]*opaque.ClientRecord),
+		flagged: make(map[string]bool),
+	}
+}
+
+// Get returns the record for the credential identifier, or ErrCredentialNotFound.
+func (m *MemoryCredentialStore) Get(credentialIdentifier []byte) (*opaque.ClientRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, ok := m.records[string(credentialIdentifier)]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+
+	return record, nil
+}
+
+// Put stores a new record, or returns ErrCredentialExists if one is already stored for its credential identifier.
+func (m *MemoryCredentialStore) Put(record *opaque.ClientRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.records[string(record.CredentialIdentifier)]; ok {
+		return ErrCredentialExists
+	}
+
+	m.records[string(record.CredentialIdentifier)] = record
+
+	return nil
+}
+
+// Walk calls fn with each record stored when it's called, and stops at the first error fn returns.
+func (m *MemoryCredentialStore) Walk(fn func(record *opaque.ClientRecord, err error) error) error {
+	m.mu.RLock()
+	records := make([]*opaque.ClientRecord, 0, len(m.records))
+
+	for _, record := range m.records {
+		records = append(records, record)
+	}
+	m.mu.RUnlock()
+
+	for _, record := range records {
+		if err := fn(record, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flag marks the record of the credential identifier for re-registration, or returns ErrCredentialNotFound.
+func (m *MemoryCredentialStore) Flag(credentialIdentifier []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.records[string(credentialIdentifier)]; !ok {
+		return ErrCredentialNotFound
+	}
+
+	m.flagged[string(credentialIdentifier)] = true
+
+	return nil
+}
+
+// Flagged returns whether the record of the credential identifier is marked for re-registration.
+func (m *MemoryCredentialStore) Flagged(credentialIdentifier []byte) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.flagged[string(credentialIdentifier)], nil
+}
+
+// Replace stores the new record of a flagged credential identifier in place of the old one and clears its flag, or
+// returns ErrCredentialNotFlagged.
+func (m *MemoryCredentialStore) Replace(record *opaque.ClientRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.flagged[string(record.CredentialIdentifier)] {
+		return ErrCredentialNotFlagged
+	}
+
+	m.records[string(record.CredentialIdentifier)] = record
+	delete(m.flagged, string(record.CredentialIdentifier))
+
+	return nil
+}
+
+// OverflowPolicy is what a MemorySessionStore does with a new session when it already holds its maximum number of
+// pending sessions.
+type OverflowPolicy byte
+
+const (
+	// RejectNew rejects the new session with ErrTooManySessions, keeping the pending ones.
+	RejectNew OverflowPolicy = iota
+
+	// EvictOldest evicts the oldest pending session to store the new one. Its login then fails on finish.
+	EvictOldest
+)
+
+// SessionStats are the counters of a MemorySessionStore, e.g. to export as metrics.
+type SessionStats struct {
+	// Pending is the number of sessions currently stored, including the expired ones not dropped yet.
+	Pending int
+
+	// Stored, Taken, Expired, Evicted, and Rejected count the sessions stored, taken by a login finish, dropped on
+	// expiry, evicted by EvictOldest, and rejected by RejectNew, since the store was created.
+	Stored   uint64
+	Taken    uint64
+	Expired  uint64
+	Evicted  uint64
+	Rejected uint64
+}
+
+type session struct {
+	id     string
+	expiry time.Time
+	state  []byte
+}
+
+// MemorySessionStore is an in-memory SessionStore whose sessions expire after a fixed duration. The number of pending
+// sessions can be capped with SetLimit, so that a flood of KE1 messages never finished can't exhaust the memory.
+type MemorySessionStore struct {
+	sessions map[string]*list.Element
+	order    *list.List // the sessions, from the oldest to the newest
+	stats    SessionStats
+	ttl      time.Duration
+	limit    int
+	policy   OverflowPolicy
+	mu       sync.Mutex
+}
+
+// NewMemorySessionStore returns an empty in-memory SessionStore whose sessions expire after ttl, with no limit on the
+// number of pending sessions.
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*list.Element), order: list.New(), ttl: ttl}
+}
+
+// SetLimit caps the number of pending sessions to limit, with the policy applied to the new sessions beyond it. A
+// limit of 0 or less removes the cap. Lowering the limit below the number of pending sessions only applies to the
+// next sessions.
+func (m *MemorySessionStore) SetLimit(limit int, policy OverflowPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.limit, m.policy = limit, policy
+}
+
+// Stats returns the counters of the store.
+func (m *MemorySessionStore) Stats() SessionStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	stats.Pending = m.order.Len()
+
+	return stats
+}
+
+// Put stores the state of a new session, and drops expired ones. If the store holds its maximum number of
+// sessions, it returns ErrTooManySessions or evicts the oldest one, depending on the policy.
+func (m *MemorySessionStore) Put(id string, state []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	// The sessions all live for the same duration, so the oldest expire first.
+	for e := m.order.Front(); e != nil && now.After(e.Value.(*session).expiry); e = m.order.Front() {
+		m.remove(e)
+		m.stats.Expired++
+	}
+
+	if e, ok := m.sessions[id]; ok {
+		m.remove(e)
+	}
+
+	if m.limit > 0 && m.order.Len() >= m.limit {
+		if m.policy != EvictOldest {
+			m.stats.Rejected++
+			return ErrTooManySessions
+		}
+
+		for m.order.Len() >= m.limit {
+			m.remove(m.order.Front())
+			m.stats.Evicted++
+		}
+	}
+
+	m.sessions[id] = m.order.PushBack(&session{id: id, expiry: now.Add(m.ttl), state: state})
+	m.stats.Stored++
+
+	return nil
+}
+
+// Take returns and removes the state of a session, or returns ErrSessionNotFound.
+func (m *MemorySessionStore) Take(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	s := m.remove(e)
+
+	if time.Now().After(s.expiry) {
+		m.stats.Expired++
+		return nil, ErrSessionNotFound
+	}
+
+	m.stats.Taken++
+
+	return s.state, nil
+}
+
+func (m *MemorySessionStore) remove(e *list.Element) *session {
+	s := m.order.Remove(e).(*session)
+	delete(m.sessions, s.id)
+
+	return s
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaquenoise bootstraps a Noise protocol channel from an OPAQUE session, so that applications using Noise for
+// transport get password-based mutual authentication.
+//
+// Both ends derive from the session key the same Curve25519 static key pairs for the client and the server, a
+// pre-shared key, and a prologue. The resulting Config mirrors the fields of the handshake configuration of Noise
+// libraries like github.com/flynn/noise, for the IKpsk2 and XXpsk3 patterns over 25519: only the two ends of the OPAQUE
+// session can complete the Noise handshake.
+package opaquenoise
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// Pattern is a Noise handshake pattern with a pre-shared key.
+type Pattern byte
+
+const (
+	// IK is the IKpsk2 pattern, in which the initiator knows the responder's static key in advance.
+	IK Pattern = iota + 1
+
+	// XX is the XXpsk3 pattern, in which both static keys are transmitted and must be checked with VerifyPeerStatic.
+	XX
+)
+
+const (
+	labelClientStatic = "OPAQUE-Noise-ClientStatic"
+	labelServerStatic = "OPAQUE-Noise-ServerStatic"
+	labelPSK          = "OPAQUE-Noise-PSK"
+	labelPrologue     = "OPAQUE-Noise-Prologue"
+
+	keyLength = 32
+)
+
+var (
+	errSessionKey = errors.New("opaquenoise: empty session key")
+	errPattern    = errors.New("opaquenoise: invalid pattern")
+
+	// ErrPeerStatic indicates that the Noise peer's static key is not the one derived from the OPAQUE session.
+	ErrPeerStatic = errors.New("opaquenoise: unexpected peer static key")
+)
+
+// KeyPair is a Curve25519 key pair.
+type KeyPair struct {
+	Private []byte
+	Public  []byte
+}
+
+// Keys holds the Noise key material derived from an OPAQUE session.
+type Keys struct {
+	ClientStatic KeyPair
+	ServerStatic KeyPair
+	PSK          []byte
+	Prologue     []byte
+}
+
+// Config is the configuration of one end of a Noise handshake.
+type Config struct {
+	// Pattern is the handshake pattern, and PresharedKeyPlacement the position of the psk token in it.
+	Pattern               Pattern
+	PresharedKeyPlacement int
+
+	// Initiator is true on the client, which initiates the handshake.
+	Initiator bool
+
+	StaticKeypair KeyPair
+
+	// PeerStatic is the peer's expected static public key.
+	PeerStatic []byte
+
+	PresharedKey []byte
+	Prologue     []byte
+}
+
+func keyPair(kdf *internal.KDF, sessionKey, info []byte, label string) (KeyPair, error) {
+	private := kdf.Expand(sessionKey, encoding.Concat([]byte(label), info), curve25519.ScalarSize)
+
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	return KeyPair{Private: private, Public: public}, nil
+}
+
+// Derive returns the Noise key material of an OPAQUE session key, bound to the given context, e.g. the channel's
+// purpose. Both ends derive the same keys.
+func Derive(sessionKey, context []byte) (*Keys, error) {
+	if len(sessionKey) == 0 {
+		return nil, errSessionKey
+	}
+
+	kdf := internal.NewKDF(crypto.SHA256)
+	info := encoding.EncodeVector(context)
+
+	client, err := keyPair(kdf, sessionKey, info, labelClientStatic)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := keyPair(kdf, sessionKey, info, labelServerStatic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keys{
+		ClientStatic: client,
+		ServerStatic: server,
+		PSK:          kdf.Expand(sessionKey, encoding.Concat([]byte(labelPSK), info), keyLength),
+		Prologue:     kdf.Expand(sessionKey, encoding.Concat([]byte(labelPrologue), info), keyLength),
+	}, nil
+}
+
+func placement(pattern Pattern) (int, error) {
+	switch pattern {
+	case IK:
+		return 2, nil
+	case XX:
+		return 3, nil
+	default:
+		return 0, errPattern
+	}
+}
+
+// Initiator returns the configuration of the client, which initiates the handshake.
+func (k *Keys) Initiator(pattern Pattern) (*Config, error) {
+	return k.config(pattern, true, k.ClientStatic, k.ServerStatic.Public)
+}
+
+// Responder returns the configuration of the server, which responds to the handshake.
+func (k *Keys) Responder(pattern Pattern) (*Config, error) {
+	return k.config(pattern, false, k.ServerStatic, k.ClientStatic.Public)
+}
+
+func (k *Keys) config(pattern Pattern, initiator bool, static KeyPair, peer []byte) (*Config, error) {
+	p, err := placement(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{
+		Pattern:               pattern,
+		PresharedKeyPlacement: p,
+		Initiator:             initiator,
+		StaticKeypair:         static,
+		PeerStatic:            peer,
+		PresharedKey:          k.PSK,
+		Prologue:              k.Prologue,
+	}, nil
+}
+
+// VerifyPeerStatic returns an error if the static key the peer used in the handshake is not the expected one. It must
+// be called after an XX handshake, in which the static keys are transmitted instead of known in advance.
+func (c *Config) VerifyPeerStatic(remote []byte) error {
+	if subtle.ConstantTimeCompare(remote, c.PeerStatic) != 1 {
+		return ErrPeerStatic
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaqueprop provides generators and properties for property-based testing with testing/quick, so that the
+// wrappers of the library can check it in their own builds, and feed their integration with valid configurations,
+// records, and message sequences.
+//
+// The generators are the types implementing quick.Generator, e.g. Configuration and Registration, and can be used as
+// arguments of the functions given to quick.Check. The properties are functions returning an error if they don't
+// hold, and Check runs them all:
+//
+//	func TestOPAQUE(t *testing.T) { opaqueprop.Check(t, &quick.Config{MaxCount: 10}) }
+//
+// The generated configurations use the identity KSF, so that the properties run quickly, and cover the groups, the
+// envelope modes, the application data, the AKE protocols, the KEMs available in the build, the compatibility modes,
+// and the contexts. The handshakes in the NIST groups are much slower than in Ristretto255, so a small MaxCount keeps
+// the checks short.
+package opaqueprop
+
+import (
+	"crypto"
+	"fmt"
+	"math/rand"
+	"reflect"
+
+	"github.com/bytemare/opaque"
+)
+
+// suites are the groups of the generated configurations, with their hash functions.
+var suites = []struct {
+	group opaque.Group
+	hash  crypto.Hash
+}{
+	{opaque.RistrettoSha512, crypto.SHA512},
+	{opaque.P256Sha256, crypto.SHA256},
+	{opaque.P384Sha512, crypto.SHA512},
+	{opaque.P521Sha512, crypto.SHA512},
+}
+
+// Configuration generates valid configurations.
+type Configuration struct {
+	*opaque.Configuration
+}
+
+// Generate returns a random valid Configuration. The size bounds the length of the context and the application data.
+func (Configuration) Generate(r *rand.Rand, size int) reflect.Value {
+	for {
+		s := suites[r.Intn(len(suites))]
+		c := &opaque.Configuration{
+			OPRF:          s.group,
+			KDF:           s.hash,
+			MAC:           s.hash,
+			Hash:          s.hash,
+			AKE:           s.group,
+			Mode:          opaque.EnvelopeMode(r.Intn(2)),
+			AEAD:          opaque.EnvelopeAEAD(r.Intn(3)),
+			KEM:           opaque.KEM(r.Intn(2)),
+			Protocol:      opaque.Protocol(r.Intn(3)),
+			Compatibility: opaque.Compatibility(r.Intn(3)),
+			Context:       randomBytes(r, size),
+		}
+
+		if r.Intn(2) == 0 {
+			c.AppDataLength = uint16(r.Intn(size + 1))
+		}
+
+		// Drop the combinations that the compatibility modes or the build don't support.
+		if _, err := c.Deserializer(); err == nil {
+			return reflect.ValueOf(Configuration{c})
+		}
+	}
+}
+
+// Credentials generates the inputs of a registration.
+type Credentials struct {
+	Password             []byte
+	CredentialIdentifier []byte
+
+	// The identities are nil or non-empty, and default to the public keys if nil.
+	ClientIdentity []byte
+	ServerIdentity []byte
+}
+
+// Generate returns random Credentials, with a non-empty password and credential identifier of at most size + 1 bytes.
+func (Credentials) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(Credentials{
+		Password:             randomBytes(r, size+1),
+		CredentialIdentifier: randomBytes(r, size+1),
+		ClientIdentity:       randomIdentity(r, size),
+		ServerIdentity:       randomIdentity(r, size),
+	})
+}
+
+// randomBytes returns between 1 and size bytes, or nil if size is 0.
+func randomBytes(r *rand.Rand, size int) []byte {
+	if size == 0 {
+		return nil
+	}
+
+	b := make([]byte, 1+r.Intn(size))
+	_, _ = r.Read(b)
+
+	return b
+}
+
+func randomIdentity(r *rand.Rand, size int) []byte {
+	if r.Intn(2) == 0 {
+		return nil
+	}
+
+	return randomBytes(r, size+1)
+}
+
+// Message is a serialized protocol message.
+type Message struct {
+	Type    opaque.MessageType
+	Encoded []byte
+}
+
+// Registration is a registration run with the credentials in the configuration. The server's keys and the OPRF seed
+// are random.
+type Registration struct {
+	Configuration *opaque.Configuration
+	Credentials   Credentials
+
+	ServerSecretKey []byte
+	ServerPublicKey []byte
+	OPRFSeed        []byte
+
+	Record    *opaque.ClientRecord
+	ExportKey []byte
+
+	// Messages are the RegistrationRequest, RegistrationResponse, and RegistrationRecord messages.
+	Messages []Message
+}
+
+// Generate returns a Registration in a random configuration. It panics if the registration fails, which is a bug.
+func (Registration) Generate(r *rand.Rand, size int) reflect.Value {
+	c := Configuration{}.Generate(r, size).Interface().(Configuration)
+	cr := Credentials{}.Generate(r, size).Interface().(Credentials)
+
+	reg, err := Register(c.Configuration, &cr)
+	if err != nil {
+		panic(fmt.Sprintf("opaqueprop: registration in a valid configuration: %v", err))
+	}
+
+	return reflect.ValueOf(*reg)
+}
+
+// Register runs a registration with the credentials in the configuration, with a new server key pair and OPRF seed.
+func Register(c *opaque.Configuration, cr *Credentials) (*Registration, error) {
+	reg := &Registration{Configuration: c, Credentials: *cr}
+
+	var err error
+	if reg.ServerSecretKey, reg.ServerPublicKey, err = c.KeyGen(); err != nil {
+		return nil, err
+	}
+
+	if reg.OPRFSeed, err = c.GenerateOPRFSeed(); err != nil {
+		return nil, err
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := c.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(reg.ServerPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	request := client.RegistrationInit(cr.Password)
+
+	response, err := server.RegistrationResponse(request, pks, cr.CredentialIdentifier, reg.OPRFSeed)
+	if err != nil {
+		return nil, err
+	}
+
+	record, exportKey, err := client.RegistrationFinalize(response, cr.ClientIdentity, cr.ServerIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	reg.Record = &opaque.ClientRecord{
+		CredentialIdentifier: cr.CredentialIdentifier,
+		ClientIdentity:       cr.ClientIdentity,
+		RegistrationRecord:   record,
+	}
+	reg.ExportKey = exportKey
+	reg.Messages = []Message{
+		{opaque.RegistrationRequestMessage, request.Serialize()},
+		{opaque.RegistrationResponseMessage, response.Serialize()},
+		{opaque.RegistrationRecordMessage, record.Serialize()},
+	}
+
+	return reg, nil
+}
+
+// Handshake is a registration followed by a successful login.
+type Handshake struct {
+	Registration
+
+	ClientSessionKey []byte
+	ServerSessionKey []byte
+	LoginExportKey   []byte
+
+	// Sequence holds the messages of the registration, followed by KE1, KE2, and KE3.
+	Sequence []Message
+}
+
+// Generate returns a Handshake in a random configuration. It panics if the handshake fails, which is a bug.
+func (Handshake) Generate(r *rand.Rand, size int) reflect.Value {
+	reg := Registration{}.Generate(r, size).Interface().(Registration)
+
+	h, err := reg.Login(reg.Credentials.Password)
+	if err != nil {
+		panic(fmt.Sprintf("opaqueprop: login with the registered password: %v", err))
+	}
+
+	return reflect.ValueOf(*h)
+}
+
+// Login runs a login with the password to the registration, and returns an error if the client or the server fails.
+func (reg *Registration) Login(password []byte) (*Handshake, error) {
+	c := reg.Configuration
+
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := c.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	cr := reg.Credentials
+	ke1 := client.LoginInit(password)
+
+	ke2, err := server.LoginInit(ke1, cr.ServerIdentity, reg.ServerSecretKey, reg.ServerPublicKey, reg.OPRFSeed,
+		reg.Record)
+	if err != nil {
+		return nil, err
+	}
+
+	ke3, exportKey, err := client.LoginFinish(cr.ClientIdentity, cr.ServerIdentity, ke2)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		return nil, err
+	}
+
+	return &Handshake{
+		Registration:     *reg,
+		ClientSessionKey: client.SessionKey(),
+		ServerSessionKey: server.SessionKey(),
+		LoginExportKey:   exportKey,
+		Sequence: append(append([]Message{}, reg.Messages...),
+			Message{opaque.KE1Message, ke1.Serialize()},
+			Message{opaque.KE2Message, ke2.Serialize()},
+			Message{opaque.KE3Message, ke3.Serialize()},
+		),
+	}, nil
+}
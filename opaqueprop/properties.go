@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaqueprop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/quick"
+
+	"github.com/bytemare/opaque"
+)
+
+// ErrProperty is wrapped by the errors of the properties that don't hold.
+var ErrProperty = errors.New("property doesn't hold")
+
+// ConfigurationRoundTrip checks that the configuration decodes from its serialization to one with the same
+// serialization.
+func ConfigurationRoundTrip(c Configuration) error {
+	encoded := c.Serialize()
+
+	decoded, err := opaque.DeserializeConfiguration(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: decoding the configuration %x: %v", ErrProperty, encoded, err)
+	}
+
+	if !bytes.Equal(decoded.Serialize(), encoded) {
+		return fmt.Errorf("%w: the configuration %x decodes to %x", ErrProperty, encoded, decoded.Serialize())
+	}
+
+	return nil
+}
+
+// MessageRoundTrip checks that each message of the handshake passes Deserializer.Check, and deserializes and
+// serializes back to the same bytes.
+func MessageRoundTrip(h Handshake) error {
+	d, err := h.Configuration.Deserializer()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range h.Sequence {
+		if err = d.Check(m.Type, m.Encoded); err != nil {
+			return fmt.Errorf("%w: checking message %d: %v", ErrProperty, m.Type, err)
+		}
+
+		var s interface{ Serialize() []byte }
+
+		switch m.Type {
+		case opaque.RegistrationRequestMessage:
+			s, err = d.RegistrationRequest(m.Encoded)
+		case opaque.RegistrationResponseMessage:
+			s, err = d.RegistrationResponse(m.Encoded)
+		case opaque.RegistrationRecordMessage:
+			s, err = d.RegistrationRecord(m.Encoded)
+		case opaque.KE1Message:
+			s, err = d.KE1(m.Encoded)
+		case opaque.KE2Message:
+			s, err = d.KE2(m.Encoded)
+		default:
+			s, err = d.KE3(m.Encoded)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: deserializing message %d: %v", ErrProperty, m.Type, err)
+		}
+
+		if !bytes.Equal(s.Serialize(), m.Encoded) {
+			return fmt.Errorf("%w: message %d doesn't serialize back to the same bytes", ErrProperty, m.Type)
+		}
+	}
+
+	return nil
+}
+
+// RegisterThenLogin checks that a login with the registered password succeeds, with the same session key on both
+// ends, and the export key of the registration.
+func RegisterThenLogin(reg Registration) error {
+	h, err := reg.Login(reg.Credentials.Password)
+	if err != nil {
+		return fmt.Errorf("%w: login with the registered password: %v", ErrProperty, err)
+	}
+
+	if len(h.ClientSessionKey) == 0 || !bytes.Equal(h.ClientSessionKey, h.ServerSessionKey) {
+		return fmt.Errorf("%w: the session keys of the client and the server differ", ErrProperty)
+	}
+
+	if !bytes.Equal(h.LoginExportKey, reg.ExportKey) {
+		return fmt.Errorf("%w: the export keys of the registration and the login differ", ErrProperty)
+	}
+
+	return nil
+}
+
+// WrongPasswordFails checks that a login with a password other than the registered one fails.
+func WrongPasswordFails(reg Registration, password []byte) error {
+	if bytes.Equal(password, reg.Credentials.Password) {
+		return nil
+	}
+
+	if _, err := reg.Login(password); err == nil {
+		return fmt.Errorf("%w: login with a wrong password succeeded", ErrProperty)
+	}
+
+	return nil
+}
+
+// Check checks all the properties with quick.Check and the config, which may be nil for the defaults, and reports
+// the first input on which each property doesn't hold.
+func Check(t *testing.T, config *quick.Config) {
+	t.Helper()
+
+	var failure error
+
+	holds := func(err error) bool {
+		if err != nil && failure == nil {
+			failure = err
+		}
+
+		return err == nil
+	}
+
+	for _, p := range []struct {
+		name string
+		f    interface{}
+	}{
+		{"ConfigurationRoundTrip", func(c Configuration) bool { return holds(ConfigurationRoundTrip(c)) }},
+		{"MessageRoundTrip", func(h Handshake) bool { return holds(MessageRoundTrip(h)) }},
+		{"RegisterThenLogin", func(reg Registration) bool { return holds(RegisterThenLogin(reg)) }},
+		{"WrongPasswordFails", func(reg Registration, password []byte) bool {
+			return holds(WrongPasswordFails(reg, password))
+		}},
+	} {
+		failure = nil
+
+		if err := quick.Check(p.f, config); err != nil {
+			t.Errorf("%s: %v (%v)", p.name, failure, err)
+		}
+	}
+}
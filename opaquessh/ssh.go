@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaquessh replaces SSH password authentication with an OPAQUE login tunneled through the
+// keyboard-interactive method of golang.org/x/crypto/ssh, so that the password is never exposed to the server.
+//
+// The SSH user name is the credential identifier. The exchange takes two keyboard-interactive rounds, where the
+// protocol messages are base64 encoded:
+//
+//	server: no instruction, question "OPAQUE-KE1"  ->  client: KE1
+//	server: instruction KE2, question "OPAQUE-KE3" ->  client: KE3
+package opaquessh
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/bytemare/opaque"
+)
+
+const (
+	// Method is the name of the challenge, to tell the OPAQUE exchange apart from other keyboard-interactive prompts.
+	Method = "OPAQUE"
+
+	questionKE1 = "OPAQUE-KE1"
+	questionKE3 = "OPAQUE-KE3"
+
+	// ExtensionCredentialIdentifier is the key of the credential identifier in the Permissions of an authenticated
+	// connection.
+	ExtensionCredentialIdentifier = "opaque-credential-identifier"
+)
+
+var (
+	// ErrAuthentication indicates that the OPAQUE login failed.
+	ErrAuthentication = errors.New("opaquessh: authentication failed")
+
+	// ErrProtocol indicates a malformed or unexpected keyboard-interactive challenge or answer.
+	ErrProtocol = errors.New("opaquessh: protocol error")
+)
+
+var encoder = base64.StdEncoding
+
+// AuthMethod returns an SSH client authentication method logging in with the password. clientIdentity and
+// serverIdentity are the identities given at registration, nil if the public keys were used.
+func AuthMethod(conf *opaque.Configuration, password, clientIdentity, serverIdentity []byte) ssh.AuthMethod {
+	var client *opaque.Client
+
+	return ssh.KeyboardInteractive(func(name, instruction string, questions []string, _ []bool) ([]string, error) {
+		if name != Method || len(questions) != 1 {
+			return nil, ErrProtocol
+		}
+
+		switch questions[0] {
+		case questionKE1:
+			c, err := conf.Client()
+			if err != nil {
+				return nil, err
+			}
+
+			client = c
+
+			return []string{encoder.EncodeToString(client.LoginInit(password).Serialize())}, nil
+		case questionKE3:
+			if client == nil {
+				return nil, ErrProtocol
+			}
+
+			return login(client, instruction, clientIdentity, serverIdentity)
+		default:
+			return nil, ErrProtocol
+		}
+	})
+}
+
+func login(client *opaque.Client, instruction string, clientIdentity, serverIdentity []byte) ([]string, error) {
+	m, err := encoder.DecodeString(instruction)
+	if err != nil {
+		return nil, ErrProtocol
+	}
+
+	ke2, err := client.Deserialize.KE2(m)
+	if err != nil {
+		return nil, ErrProtocol
+	}
+
+	ke3, _, err := client.LoginFinish(clientIdentity, serverIdentity, ke2)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	return []string{encoder.EncodeToString(ke3.Serialize())}, nil
+}
+
+// Server authenticates SSH clients with OPAQUE.
+type Server struct {
+	Configuration *opaque.Configuration
+
+	// Lookup returns the record registered for the SSH user, or nil if there is none, in which case the exchange
+	// runs with a fake record to not reveal it.
+	Lookup func(user string) (*opaque.ClientRecord, error)
+
+	ServerIdentity  []byte
+	ServerSecretKey []byte
+	ServerPublicKey []byte
+	OPRFSeed        []byte
+}
+
+// KeyboardInteractiveCallback runs the OPAQUE exchange, and is to be set as the KeyboardInteractiveCallback of the
+// ssh.ServerConfig.
+func (s *Server) KeyboardInteractiveCallback(
+	conn ssh.ConnMetadata,
+	challenge ssh.KeyboardInteractiveChallenge,
+) (*ssh.Permissions, error) {
+	server, err := s.Configuration.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ask(challenge, "", questionKE1)
+	if err != nil {
+		return nil, err
+	}
+
+	ke1, err := server.Deserialize.KE1(m)
+	if err != nil {
+		return nil, ErrProtocol
+	}
+
+	record, err := s.record(conn.User())
+	if err != nil {
+		return nil, err
+	}
+
+	ke2, err := server.LoginInit(ke1, s.ServerIdentity, s.ServerSecretKey, s.ServerPublicKey, s.OPRFSeed, record)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, err = ask(challenge, encoder.EncodeToString(ke2.Serialize()), questionKE3); err != nil {
+		return nil, err
+	}
+
+	ke3, err := server.Deserialize.KE3(m)
+	if err != nil {
+		return nil, ErrProtocol
+	}
+
+	if err := server.LoginFinish(ke3); err != nil {
+		return nil, ErrAuthentication
+	}
+
+	return &ssh.Permissions{
+		Extensions: map[string]string{ExtensionCredentialIdentifier: conn.User()},
+	}, nil
+}
+
+func (s *Server) record(user string) (*opaque.ClientRecord, error) {
+	record, err := s.Lookup(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if record == nil {
+		return s.Configuration.GetFakeRecord([]byte(user))
+	}
+
+	return record, nil
+}
+
+// ask sends a single question in a keyboard-interactive challenge, and returns the decoded answer.
+func ask(challenge ssh.KeyboardInteractiveChallenge, instruction, question string) ([]byte, error) {
+	answers, err := challenge(Method, instruction, []string{question}, []bool{false})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(answers) != 1 {
+		return nil, ErrProtocol
+	}
+
+	m, err := encoder.DecodeString(answers[0])
+	if err != nil {
+		return nil, ErrProtocol
+	}
+
+	return m, nil
+}
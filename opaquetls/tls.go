@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaquetls roots TLS 1.3 connections in an OPAQUE session key, so that post-login application traffic is
+// mutually authenticated by the password.
+//
+// The session key is turned into a TLS 1.3 external PSK with ExternalPSK, for TLS stacks that accept them. Since
+// crypto/tls doesn't, ClientConfig and ServerConfig instead derive an Ed25519 key pair for each side from the session
+// key, present them in self-signed certificates, and only accept the peer's expected key: only the two ends of the
+// OPAQUE session can complete the handshake.
+package opaquetls
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+const (
+	labelPSK         = "OPAQUE-TLS13-PSK"
+	labelPSKIdentity = "OPAQUE-TLS13-PSK-Identity"
+	labelClientKey   = "OPAQUE-TLS13-Client"
+	labelServerKey   = "OPAQUE-TLS13-Server"
+
+	pskIdentityLength = 16
+
+	labelChannelBinding  = "EXPORTER-OPAQUE-channel-binding"
+	channelBindingLength = 32
+
+	// certificateValidity is how long before and after its creation a derived certificate is valid.
+	certificateValidity = 24 * time.Hour
+)
+
+var (
+	errSessionKey = errors.New("opaquetls: empty session key")
+
+	// ErrPeerKey indicates that the TLS peer didn't prove knowledge of the OPAQUE session key.
+	ErrPeerKey = errors.New("opaquetls: peer certificate does not match the session key")
+)
+
+// PSK is a TLS 1.3 external pre-shared key, with the SHA-256 based cipher suites.
+type PSK struct {
+	Identity []byte
+	Key      []byte
+}
+
+func expand(sessionKey []byte, label string, length int) []byte {
+	return internal.NewKDF(crypto.SHA256).Expand(sessionKey, []byte(label), length)
+}
+
+// ExternalPSK derives a TLS 1.3 external PSK from an OPAQUE session key, bound to the given context, e.g. the
+// connection's purpose. Both ends derive the same identity and key.
+func ExternalPSK(sessionKey, context []byte) (*PSK, error) {
+	if len(sessionKey) == 0 {
+		return nil, errSessionKey
+	}
+
+	ctx := encoding.EncodeVector(context)
+
+	return &PSK{
+		Identity: expand(sessionKey, labelPSKIdentity+string(ctx), pskIdentityLength),
+		Key:      expand(sessionKey, labelPSK+string(ctx), crypto.SHA256.Size()),
+	}, nil
+}
+
+func deriveKey(sessionKey []byte, label string) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(expand(sessionKey, label, ed25519.SeedSize))
+}
+
+func certificate(key ed25519.PrivateKey) (tls.Certificate, error) {
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    now.Add(-certificateValidity),
+		NotAfter:     now.Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// verifyPeer returns a certificate verification function accepting only the expected public key.
+func verifyPeer(expected ed25519.PublicKey) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) != 1 {
+			return ErrPeerKey
+		}
+
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return ErrPeerKey
+		}
+
+		pk, ok := cert.PublicKey.(ed25519.PublicKey)
+		if !ok || subtle.ConstantTimeCompare(pk, expected) != 1 {
+			return ErrPeerKey
+		}
+
+		return nil
+	}
+}
+
+func config(base *tls.Config, sessionKey []byte, own, peer string) (*tls.Config, error) {
+	if len(sessionKey) == 0 {
+		return nil, errSessionKey
+	}
+
+	cert, err := certificate(deriveKey(sessionKey, own))
+	if err != nil {
+		return nil, err
+	}
+
+	var c *tls.Config
+	if base != nil {
+		c = base.Clone()
+	} else {
+		c = new(tls.Config)
+	}
+
+	c.MinVersion = tls.VersionTLS13
+	c.Certificates = []tls.Certificate{cert}
+	c.GetCertificate = nil
+	c.GetClientCertificate = nil
+	c.ClientAuth = tls.RequireAnyClientCert
+	c.InsecureSkipVerify = true // #nosec G402 -- the peer certificate is verified against the session key instead.
+	c.VerifyPeerCertificate = verifyPeer(deriveKey(sessionKey, peer).Public().(ed25519.PublicKey))
+
+	return c, nil
+}
+
+// ChannelBinding returns the TLS exporter value of the connection to be set as the OPAQUE channel binding with
+// SetChannelBinding on both ends, as defined in RFC 5705 and RFC 8446. The connection must use TLS 1.3, or a TLS 1.2
+// connection with the extended master secret extension.
+func ChannelBinding(state *tls.ConnectionState) ([]byte, error) {
+	return state.ExportKeyingMaterial(labelChannelBinding, nil, channelBindingLength)
+}
+
+// ClientConfig returns a copy of base, which may be nil, for the client end of a TLS 1.3 connection authenticated by
+// the OPAQUE session key.
+func ClientConfig(base *tls.Config, sessionKey []byte) (*tls.Config, error) {
+	return config(base, sessionKey, labelClientKey, labelServerKey)
+}
+
+// ServerConfig returns a copy of base, which may be nil, for the server end of a TLS 1.3 connection authenticated by
+// the OPAQUE session key.
+func ServerConfig(base *tls.Config, sessionKey []byte) (*tls.Config, error) {
+	return config(base, sessionKey, labelServerKey, labelClientKey)
+}
@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaquetoken mints signed tokens after a successful OPAQUE login, binding the client identity, an identifier
+// of the session, and the fingerprint of the configuration it ran with.
+//
+// Tokens are signed with Ed25519, either as JSON Web Tokens (RFC 7519, with the EdDSA algorithm of RFC 8037) or as
+// v4.public PASETO tokens.
+package opaquetoken
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// Format is the encoding of a token.
+type Format byte
+
+const (
+	// JWT is a JSON Web Token signed with EdDSA.
+	JWT Format = iota
+
+	// PASETO is a v4.public PASETO token.
+	PASETO
+)
+
+const (
+	// DefaultTTL is the validity of a token, if none is set.
+	DefaultTTL = time.Hour
+
+	labelSessionID  = "OPAQUE-Token-SessionID"
+	sessionIDLength = 16
+
+	jwtHeader    = `{"alg":"EdDSA","typ":"JWT"}`
+	pasetoHeader = "v4.public."
+)
+
+var (
+	// ErrInvalidToken indicates a malformed token, or one with an invalid signature.
+	ErrInvalidToken = errors.New("opaquetoken: invalid token")
+
+	// ErrExpired indicates that the token has expired, or is not valid yet.
+	ErrExpired = errors.New("opaquetoken: token expired")
+
+	// ErrClaims indicates that the token's claims don't match the verifier's expectations.
+	ErrClaims = errors.New("opaquetoken: unexpected claims")
+
+	errSessionKey = errors.New("opaquetoken: empty session key")
+	errPublicKey  = errors.New("opaquetoken: invalid public key length")
+)
+
+var b64 = base64.RawURLEncoding
+
+// Claims are the claims of a token.
+type Claims struct {
+	Issuer   string `json:"iss,omitempty"`
+	Audience string `json:"aud,omitempty"`
+
+	// Subject is the base64url encoded client identity.
+	Subject string `json:"sub"`
+
+	// SessionID identifies the OPAQUE session the token was issued for, without revealing its session key.
+	SessionID string `json:"sid"`
+
+	// ConfigurationFingerprint identifies the OPAQUE configuration of the login.
+	ConfigurationFingerprint string `json:"cfg"`
+
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// ClientIdentity returns the decoded client identity of the subject.
+func (c *Claims) ClientIdentity() ([]byte, error) {
+	return b64.DecodeString(c.Subject)
+}
+
+// SessionID returns the identifier of the session with the given session key.
+func SessionID(sessionKey []byte) string {
+	return b64.EncodeToString(internal.NewKDF(crypto.SHA256).Expand(sessionKey, []byte(labelSessionID),
+		sessionIDLength))
+}
+
+// ConfigurationFingerprint returns the fingerprint of the configuration.
+func ConfigurationFingerprint(conf *opaque.Configuration) string {
+	return b64.EncodeToString(conf.Fingerprint())
+}
+
+// Issuer mints tokens.
+type Issuer struct {
+	Configuration *opaque.Configuration
+	Key           ed25519.PrivateKey
+	Issuer        string
+	Audience      string
+
+	// TTL is the validity of the tokens, DefaultTTL if zero.
+	TTL time.Duration
+
+	Format Format
+}
+
+// Issue returns a token for the client identity, to be called after a successful LoginFinish with the session key of
+// the login.
+func (i *Issuer) Issue(clientIdentity, sessionKey []byte) (string, error) {
+	if len(sessionKey) == 0 {
+		return "", errSessionKey
+	}
+
+	ttl := i.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	now := time.Now()
+
+	payload, err := json.Marshal(&Claims{
+		Issuer:                   i.Issuer,
+		Audience:                 i.Audience,
+		Subject:                  b64.EncodeToString(clientIdentity),
+		SessionID:                SessionID(sessionKey),
+		ConfigurationFingerprint: ConfigurationFingerprint(i.Configuration),
+		IssuedAt:                 now.Unix(),
+		ExpiresAt:                now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if i.Format == PASETO {
+		sig := ed25519.Sign(i.Key, pae([]byte(pasetoHeader), payload, nil, nil))
+		return pasetoHeader + b64.EncodeToString(encoding.Concat(payload, sig)), nil
+	}
+
+	signed := b64.EncodeToString([]byte(jwtHeader)) + "." + b64.EncodeToString(payload)
+
+	return signed + "." + b64.EncodeToString(ed25519.Sign(i.Key, []byte(signed))), nil
+}
+
+// Verifier verifies tokens. It should be built with NewVerifier, which checks its key.
+type Verifier struct {
+	Configuration *opaque.Configuration
+	Key           ed25519.PublicKey
+	Issuer        string
+	Audience      string
+	Format        Format
+}
+
+// NewVerifier returns a Verifier of the tokens issued with the configuration, issuer, audience, and format, and signed
+// with the private key of the public key. It returns an error if the public key is not of ed25519.PublicKeySize bytes.
+func NewVerifier(
+	conf *opaque.Configuration,
+	key ed25519.PublicKey,
+	issuer, audience string,
+	format Format,
+) (*Verifier, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errPublicKey
+	}
+
+	return &Verifier{
+		Configuration: conf,
+		Key:           key,
+		Issuer:        issuer,
+		Audience:      audience,
+		Format:        format,
+	}, nil
+}
+
+// Verify returns the claims of a token if its signature is valid, it hasn't expired, and it was issued with the
+// verifier's issuer, audience, and configuration.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	// ed25519.Verify panics on a key of another length.
+	if len(v.Key) != ed25519.PublicKeySize {
+		return nil, errPublicKey
+	}
+
+	var (
+		payload []byte
+		err     error
+	)
+
+	if v.Format == PASETO {
+		payload, err = v.verifyPASETO(token)
+	} else {
+		payload, err = v.verifyJWT(token)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(Claims)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now().Unix()
+	if now >= claims.ExpiresAt || now < claims.IssuedAt {
+		return nil, ErrExpired
+	}
+
+	if claims.Issuer != v.Issuer || claims.Audience != v.Audience ||
+		claims.ConfigurationFingerprint != ConfigurationFingerprint(v.Configuration) {
+		return nil, ErrClaims
+	}
+
+	return claims, nil
+}
+
+// VerifySession is like Verify, but also checks that the token was issued for the session with the given key.
+func (v *Verifier) VerifySession(token string, sessionKey []byte) (*Claims, error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.SessionID != SessionID(sessionKey) {
+		return nil, ErrClaims
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) verifyJWT(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	header, err := b64.DecodeString(parts[0])
+	if err != nil || !internal.ConstantTimeEqual(header, []byte(jwtHeader)) {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(v.Key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
+func (v *Verifier) verifyPASETO(token string) ([]byte, error) {
+	if !strings.HasPrefix(token, pasetoHeader) {
+		return nil, ErrInvalidToken
+	}
+
+	body := token[len(pasetoHeader):]
+	if strings.Contains(body, ".") {
+		// Footers are not used.
+		return nil, ErrInvalidToken
+	}
+
+	m, err := b64.DecodeString(body)
+	if err != nil || len(m) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+
+	payload, sig := m[:len(m)-ed25519.SignatureSize], m[len(m)-ed25519.SignatureSize:]
+	if !ed25519.Verify(v.Key, pae([]byte(pasetoHeader), payload, nil, nil), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
+// le64 is the little-endian 64-bit length encoding of PASETO, with the most significant bit cleared.
+func le64(n int) []byte {
+	out := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		out[i] = byte(uint64(n) >> (8 * i))
+	}
+
+	out[7] &= 0x7f
+
+	return out
+}
+
+// pae is the pre-authentication encoding of PASETO.
+func pae(pieces ...[]byte) []byte {
+	out := le64(len(pieces))
+	for _, p := range pieces {
+		out = encoding.Concatenate(out, le64(len(p)), p)
+	}
+
+	return out
+}
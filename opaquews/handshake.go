@@ -0,0 +1,311 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package opaquews runs the OPAQUE login over an existing WebSocket connection, for real-time applications that
+// authenticate in-band.
+//
+// It doesn't depend on a WebSocket implementation: any connection with the Conn methods can be used, like a
+// *websocket.Conn of github.com/gorilla/websocket. Each protocol message is sent in its own binary WebSocket message,
+// starting with a one-byte frame type:
+//
+//	client -> server  hello:  credential identifier (2-byte length prefixed) || KE1
+//	server -> client  ke2:    KE2
+//	client -> server  ke3:    KE3
+//	server -> client  result: empty on success
+//	either way        alert:  the peer aborted the handshake
+package opaquews
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// BinaryMessage is the WebSocket message type for binary data frames, as defined in RFC 6455.
+const BinaryMessage = 2
+
+// DefaultTimeout is the time allowed to read or write each message, if none is set.
+const DefaultTimeout = 10 * time.Second
+
+const (
+	frameHello byte = iota + 1
+	frameKE2
+	frameKE3
+	frameResult
+	frameAlert
+)
+
+var (
+	// ErrAuthentication indicates that the peer could not be authenticated, or rejected the authentication.
+	ErrAuthentication = errors.New("opaquews: authentication failed")
+
+	// ErrProtocol indicates a malformed or unexpected message from the peer.
+	ErrProtocol = errors.New("opaquews: protocol error")
+
+	// ErrTimeout indicates that the peer didn't answer in time.
+	ErrTimeout = errors.New("opaquews: handshake timed out")
+)
+
+// Conn is the subset of a WebSocket connection's methods used for the handshake.
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// Result holds the outcome of a successful handshake.
+type Result struct {
+	// SessionKey is the secret session key shared by both peers.
+	SessionKey []byte
+
+	// ExportKey is the client's export key. It is only set on the client side.
+	ExportKey opaque.ExportKey
+
+	// CredentialIdentifier identifies the client's record on the server.
+	CredentialIdentifier []byte
+
+	// ClientIdentity and ServerIdentity are the identities authenticated by the AKE. On the client, a nil
+	// ClientIdentity means the client's public key was used.
+	ClientIdentity []byte
+	ServerIdentity []byte
+}
+
+type framer struct {
+	conn    Conn
+	timeout time.Duration
+}
+
+func newFramer(conn Conn, timeout time.Duration) *framer {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &framer{conn: conn, timeout: timeout}
+}
+
+func (f *framer) write(frameType byte, payload []byte) error {
+	if err := f.conn.SetWriteDeadline(time.Now().Add(f.timeout)); err != nil {
+		return err
+	}
+
+	return mapError(f.conn.WriteMessage(BinaryMessage, encoding.Concat([]byte{frameType}, payload)))
+}
+
+// read returns the payload of the next message, which must be of the expected frame type.
+func (f *framer) read(expected byte) ([]byte, error) {
+	if err := f.conn.SetReadDeadline(time.Now().Add(f.timeout)); err != nil {
+		return nil, err
+	}
+
+	messageType, m, err := f.conn.ReadMessage()
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	switch {
+	case messageType != BinaryMessage || len(m) == 0:
+		return nil, ErrProtocol
+	case m[0] == frameAlert:
+		return nil, ErrAuthentication
+	case m[0] != expected:
+		return nil, ErrProtocol
+	}
+
+	return m[1:], nil
+}
+
+// abort notifies the peer the handshake failed, and returns the error.
+func (f *framer) abort(err error) error {
+	if !errors.Is(err, ErrTimeout) {
+		_ = f.write(frameAlert, nil)
+	}
+
+	return err
+}
+
+func mapError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+
+	return err
+}
+
+// Client runs the client side of the handshake.
+type Client struct {
+	Configuration *opaque.Configuration
+
+	// ClientIdentity and ServerIdentity are the identities given at registration, nil if the public keys were used.
+	ClientIdentity []byte
+	ServerIdentity []byte
+
+	// Timeout is the time allowed to read or write each message, DefaultTimeout if zero.
+	Timeout time.Duration
+}
+
+// Handshake logs in with the password to the record registered under the credential identifier on the server at the
+// other end of conn.
+func (c *Client) Handshake(conn Conn, credentialIdentifier, password []byte) (*Result, error) {
+	client, err := c.Configuration.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFramer(conn, c.Timeout)
+	ke1 := client.LoginInit(password)
+
+	hello := encoding.Concat(encoding.EncodeVector(credentialIdentifier), ke1.Serialize())
+	if err := f.write(frameHello, hello); err != nil {
+		return nil, err
+	}
+
+	m, err := f.read(frameKE2)
+	if err != nil {
+		return nil, f.abort(err)
+	}
+
+	ke2, err := client.Deserialize.KE2(m)
+	if err != nil {
+		return nil, f.abort(ErrProtocol)
+	}
+
+	ke3, exportKey, err := client.LoginFinish(c.ClientIdentity, c.ServerIdentity, ke2)
+	if err != nil {
+		return nil, f.abort(ErrAuthentication)
+	}
+
+	if err := f.write(frameKE3, ke3.Serialize()); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.read(frameResult); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		SessionKey:           client.SessionKey(),
+		ExportKey:            exportKey,
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       c.ClientIdentity,
+		ServerIdentity:       c.ServerIdentity,
+	}, nil
+}
+
+// Server runs the server side of the handshake.
+type Server struct {
+	Configuration *opaque.Configuration
+
+	// Lookup returns the record registered under the credential identifier, or nil if there is none, in which case
+	// the handshake runs with a fake record to not reveal it.
+	Lookup func(credentialIdentifier []byte) (*opaque.ClientRecord, error)
+
+	ServerIdentity  []byte
+	ServerSecretKey []byte
+	ServerPublicKey []byte
+	OPRFSeed        []byte
+
+	// Timeout is the time allowed to read or write each message, DefaultTimeout if zero.
+	Timeout time.Duration
+}
+
+// Handshake authenticates the client at the other end of conn.
+func (s *Server) Handshake(conn Conn) (*Result, error) {
+	server, err := s.Configuration.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFramer(conn, s.Timeout)
+
+	m, err := f.read(frameHello)
+	if err != nil {
+		return nil, f.abort(err)
+	}
+
+	credentialIdentifier, offset, err := encoding.DecodeVector(m)
+	if err != nil || len(credentialIdentifier) == 0 {
+		return nil, f.abort(ErrProtocol)
+	}
+
+	ke1, err := server.Deserialize.KE1(m[offset:])
+	if err != nil {
+		return nil, f.abort(ErrProtocol)
+	}
+
+	record, err := s.record(credentialIdentifier)
+	if err != nil {
+		return nil, f.abort(err)
+	}
+
+	ke2, err := server.LoginInit(ke1, s.ServerIdentity, s.ServerSecretKey, s.ServerPublicKey, s.OPRFSeed, record)
+	if err != nil {
+		return nil, f.abort(err)
+	}
+
+	if err := f.write(frameKE2, ke2.Serialize()); err != nil {
+		return nil, err
+	}
+
+	if m, err = f.read(frameKE3); err != nil {
+		return nil, f.abort(err)
+	}
+
+	ke3, err := server.Deserialize.KE3(m)
+	if err != nil {
+		return nil, f.abort(ErrProtocol)
+	}
+
+	if err := server.LoginFinish(ke3); err != nil {
+		return nil, f.abort(ErrAuthentication)
+	}
+
+	if err := f.write(frameResult, nil); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		SessionKey:           server.SessionKey(),
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       clientIdentity(record),
+		ServerIdentity:       s.serverIdentity(),
+	}, nil
+}
+
+func (s *Server) record(credentialIdentifier []byte) (*opaque.ClientRecord, error) {
+	record, err := s.Lookup(credentialIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if record == nil {
+		return s.Configuration.GetFakeRecord(credentialIdentifier)
+	}
+
+	return record, nil
+}
+
+func (s *Server) serverIdentity() []byte {
+	if s.ServerIdentity == nil {
+		return s.ServerPublicKey
+	}
+
+	return s.ServerIdentity
+}
+
+func clientIdentity(record *opaque.ClientRecord) []byte {
+	if record.ClientIdentity == nil {
+		return encoding.SerializePoint(record.PublicKey, record.G)
+	}
+
+	return record.ClientIdentity
+}
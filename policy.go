@@ -0,0 +1,363 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/message"
+)
+
+// The client is the only party that ever sees the password, so it's the only one that can enforce a password policy:
+// the server only receives a blinded element and a record it can't relate to the password. A client sets its policy
+// with SetPasswordPolicy, and RegistrationStart and AuthenticationStart check the password against it before the OPRF
+// starts. RegistrationInit and LoginInit check it too, and then fail the finalization of the registration or login.
+
+// ErrPasswordPolicy indicates a password rejected by the client's PasswordPolicy.
+var ErrPasswordPolicy = errors.New("password rejected by policy")
+
+// PasswordPolicy checks passwords on the client.
+type PasswordPolicy interface {
+	// Check returns an error if the password is not acceptable.
+	Check(password []byte) error
+}
+
+// SetPasswordPolicy sets the policy against which RegistrationStart, RegistrationInit, AuthenticationStart, LoginInit,
+// and LoginInitWithInfo check passwords. A client tightening its policy should only set it for registrations until its
+// users changed their passwords, since they couldn't log in with the ones that no longer comply. A nil policy disables
+// it.
+func (c *Client) SetPasswordPolicy(policy PasswordPolicy) {
+	c.policy = policy
+}
+
+// checkPassword returns an error wrapping ErrPasswordPolicy if the policy rejects the password.
+func (c *Client) checkPassword(password []byte) error {
+	if c.policy == nil {
+		return nil
+	}
+
+	if err := c.policy.Check(password); err != nil {
+		if errors.Is(err, ErrPasswordPolicy) {
+			return err
+		}
+
+		return fmt.Errorf("%w: %v", ErrPasswordPolicy, err)
+	}
+
+	return nil
+}
+
+// AuthenticationStart is like LoginInit, but first checks the password against the PasswordPolicy, if any, and returns
+// an error wrapping ErrPasswordPolicy if it's rejected, without starting the OPRF. A password the policy rejects can't
+// be a registered one, so this spares a round trip, and a failed login, on a mistyped password.
+func (c *Client) AuthenticationStart(password []byte) (*message.KE1, error) {
+	if err := c.checkPassword(password); err != nil {
+		return nil, err
+	}
+
+	c.passwordErr = nil
+
+	return c.loginInit(password, nil), nil
+}
+
+const (
+	// defaultMinPasswordLength is the minimum length of NIST SP 800-63B.
+	defaultMinPasswordLength = 8
+
+	// defaultMaxPasswordLength bounds the cost of the estimation, well above the 64 characters NIST SP 800-63B
+	// requires to be accepted.
+	defaultMaxPasswordLength = 1024
+
+	// defaultMinPasswordEntropy is about 10^9 guesses, between the "safely unguessable" scores of zxcvbn.
+	defaultMinPasswordEntropy = 30
+)
+
+// StandardPasswordPolicy is a PasswordPolicy on the length of passwords, the estimate of EstimatePasswordEntropy, and
+// a denylist. The lengths are in Unicode characters.
+type StandardPasswordPolicy struct {
+	// MinLength is the minimum length of a password.
+	MinLength int
+
+	// MaxLength is the maximum length of a password, or unbounded if zero.
+	MaxLength int
+
+	// MinEntropy is the minimum estimate of EstimatePasswordEntropy, in bits.
+	MinEntropy float64
+
+	// Denylist holds the passwords rejected regardless of their estimate, compared case-insensitively, besides the
+	// most common passwords, which are always rejected.
+	Denylist [][]byte
+}
+
+// DefaultPasswordPolicy returns a StandardPasswordPolicy requiring at least 8 characters, at most 1024, and an estimate
+// of 30 bits.
+func DefaultPasswordPolicy() *StandardPasswordPolicy {
+	return &StandardPasswordPolicy{
+		MinLength:  defaultMinPasswordLength,
+		MaxLength:  defaultMaxPasswordLength,
+		MinEntropy: defaultMinPasswordEntropy,
+	}
+}
+
+var (
+	errPasswordShort  = errors.New("password is too short")
+	errPasswordLong   = errors.New("password is too long")
+	errPasswordWeak   = errors.New("password is too guessable")
+	errPasswordDenied = errors.New("password is denylisted")
+)
+
+// Check returns an error wrapping ErrPasswordPolicy if the password is not acceptable.
+func (p *StandardPasswordPolicy) Check(password []byte) error {
+	length := utf8.RuneCount(password)
+
+	if length < p.MinLength {
+		return fmt.Errorf("%w: %v", ErrPasswordPolicy, errPasswordShort)
+	}
+
+	if p.MaxLength != 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: %v", ErrPasswordPolicy, errPasswordLong)
+	}
+
+	lower := bytes.ToLower(password)
+	if _, ok := commonPasswordRank[string(lower)]; ok {
+		return fmt.Errorf("%w: %v", ErrPasswordPolicy, errPasswordDenied)
+	}
+
+	for _, denied := range p.Denylist {
+		if internal.ConstantTimeEqual(bytes.ToLower(denied), lower) {
+			return fmt.Errorf("%w: %v", ErrPasswordPolicy, errPasswordDenied)
+		}
+	}
+
+	if EstimatePasswordEntropy(password) < p.MinEntropy {
+		return fmt.Errorf("%w: %v", ErrPasswordPolicy, errPasswordWeak)
+	}
+
+	return nil
+}
+
+// EstimatePasswordEntropy returns an estimate of the number of guesses, in bits, an attacker needs to find the
+// password, in the manner of zxcvbn: the password is split into the sequence of patterns that is the cheapest to guess,
+// among common passwords and words (also with capitals and common substitutions), repeated characters, sequences like
+// "abcd" or "9876", keyboard rows like "qwerty", and otherwise single characters of their class.
+func EstimatePasswordEntropy(password []byte) float64 {
+	runes := []rune(string(password))
+
+	// best[i] is the estimate of the first i characters.
+	best := make([]float64, len(runes)+1)
+	for i := 1; i <= len(runes); i++ {
+		best[i] = math.Inf(1)
+	}
+
+	for end := 1; end <= len(runes); end++ {
+		first := end - maxPatternLength
+		if first < 0 {
+			first = 0
+		}
+
+		for start := first; start < end; start++ {
+			guesses := patternGuesses(runes[start:end])
+			if guesses == 0 {
+				continue
+			}
+
+			if bits := best[start] + math.Log2(guesses); bits < best[end] {
+				best[end] = bits
+			}
+		}
+	}
+
+	return best[len(runes)]
+}
+
+// maxPatternLength bounds the length of the patterns, and thus the cost of the estimate.
+const maxPatternLength = 32
+
+// patternGuesses returns the number of guesses of the cheapest pattern matching the whole segment, or 0 if none does.
+func patternGuesses(segment []rune) float64 {
+	if len(segment) == 1 {
+		return characterCardinality(segment[0])
+	}
+
+	var guesses float64
+
+	for _, match := range [...]func([]rune) float64{
+		dictionaryGuesses, repeatGuesses, sequenceGuesses, keyboardGuesses,
+	} {
+		if g := match(segment); g != 0 && (guesses == 0 || g < guesses) {
+			guesses = g
+		}
+	}
+
+	return guesses
+}
+
+// characterCardinality returns the number of characters of the class of r, for brute force guesses.
+func characterCardinality(r rune) float64 {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r < utf8.RuneSelf:
+		return 33
+	default:
+		return 100
+	}
+}
+
+// leetSubstitutions maps the common substitutions to the letters they replace.
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's',
+	'7': 't', '+': 't', '2': 'z',
+}
+
+// dictionaryGuesses returns the rank of the segment in the common passwords and words, as is or with the common
+// substitutions undone, doubled if it has capitals and if it has substitutions, or 0 if it's not one of them.
+func dictionaryGuesses(segment []rune) float64 {
+	word := make([]rune, len(segment))
+	unsubstituted := make([]rune, len(segment))
+	capitals := false
+
+	for i, r := range segment {
+		if unicode.IsUpper(r) {
+			capitals = true
+		}
+
+		word[i] = unicode.ToLower(r)
+		unsubstituted[i] = word[i]
+
+		if l, ok := leetSubstitutions[r]; ok {
+			unsubstituted[i] = l
+		}
+	}
+
+	guesses := float64(0)
+
+	if rank, ok := commonPasswordRank[string(word)]; ok {
+		guesses = float64(rank + 1)
+	} else if rank, ok = commonPasswordRank[string(unsubstituted)]; ok {
+		guesses = float64(2 * (rank + 1))
+	} else {
+		return 0
+	}
+
+	if capitals {
+		guesses *= 2
+	}
+
+	return guesses
+}
+
+// minPatternLength is the minimum length of the repeats, sequences, and keyboard rows.
+const minPatternLength = 3
+
+// repeatGuesses returns the guesses of a repeated character, or 0 if the segment isn't one.
+func repeatGuesses(segment []rune) float64 {
+	if len(segment) < minPatternLength {
+		return 0
+	}
+
+	for _, r := range segment[1:] {
+		if r != segment[0] {
+			return 0
+		}
+	}
+
+	return characterCardinality(segment[0]) * float64(len(segment))
+}
+
+// sequenceGuesses returns the guesses of a sequence of consecutive characters, or 0 if the segment isn't one.
+func sequenceGuesses(segment []rune) float64 {
+	if len(segment) < minPatternLength {
+		return 0
+	}
+
+	delta := segment[1] - segment[0]
+	if delta != 1 && delta != -1 {
+		return 0
+	}
+
+	for i := 2; i < len(segment); i++ {
+		if segment[i]-segment[i-1] != delta {
+			return 0
+		}
+	}
+
+	guesses := characterCardinality(segment[0]) * float64(len(segment))
+	if delta < 0 {
+		guesses *= 2
+	}
+
+	return guesses
+}
+
+// keyboardRows are the rows of a QWERTY keyboard.
+var keyboardRows = [...]string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// keyboardGuesses returns the guesses of a run of adjacent keys of a keyboard row, in either direction, or 0 if the
+// segment isn't one.
+func keyboardGuesses(segment []rune) float64 {
+	if len(segment) < minPatternLength+1 {
+		return 0
+	}
+
+	word := make([]rune, len(segment))
+	reversed := make([]rune, len(segment))
+
+	for i, r := range segment {
+		word[i] = unicode.ToLower(r)
+		reversed[len(segment)-1-i] = word[i]
+	}
+
+	for _, row := range keyboardRows {
+		switch {
+		case strings.Contains(row, string(word)):
+			return float64(len(keyboardRows)*len(row)) * float64(len(segment))
+		case strings.Contains(row, string(reversed)):
+			return float64(2*len(keyboardRows)*len(row)) * float64(len(segment))
+		}
+	}
+
+	return 0
+}
+
+// commonPasswords are among the most common passwords and words found in breaches, by decreasing frequency.
+var commonPasswords = [...]string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234", "111111", "1234567", "dragon",
+	"123123", "baseball", "abc123", "football", "monkey", "letmein", "696969", "shadow", "master", "666666",
+	"qwertyuiop", "123321", "mustang", "1234567890", "michael", "654321", "superman", "1qaz2wsx", "7777777",
+	"121212", "000000", "qazwsx", "123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh",
+	"hunter", "buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou", "2000", "charlie",
+	"robert", "thomas", "hockey", "ranger", "daniel", "starwars", "klaster", "112233", "george", "computer",
+	"michelle", "jessica", "pepper", "1111", "zxcvbn", "555555", "11111111", "131313", "freedom", "777777", "pass",
+	"maggie", "159753", "aaaaaa", "ginger", "princess", "joshua", "cheese", "amanda", "summer", "love", "ashley",
+	"nicole", "chelsea", "biteme", "matthew", "access", "yankees", "987654321", "dallas", "austin", "thunder",
+	"taylor", "matrix", "admin", "welcome", "login", "passw0rd", "hello", "secret", "whatever", "winter", "spring",
+	"autumn", "flower", "banana", "orange", "purple", "silver", "golden", "cookie", "chocolate", "angel", "family",
+	"friends", "forever", "lovely", "money", "internet", "samsung", "google", "apple", "qwerty123", "password1",
+	"iloveu", "baby", "hottie", "sweet", "blink182", "pokemon", "naruto", "liverpool", "arsenal", "barcelona",
+}
+
+// commonPasswordRank maps the common passwords to their rank.
+var commonPasswordRank = func() map[string]int {
+	ranks := make(map[string]int, len(commonPasswords))
+	for i, p := range commonPasswords {
+		ranks[p] = i
+	}
+
+	return ranks
+}()
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ServerPool recycles the per-handshake Server state of a Configuration, for servers handling many logins per second.
+// A Server is taken with GetServerSession for a single login, and given back with PutServerSession, which wipes it.
+//
+// The configuration and key material of the pool can be rotated without downtime with Swap: the Servers taken
+// afterwards use the new ones, while the Servers already taken keep the ones they were taken with, so that the logins
+// in flight complete.
+type ServerPool struct {
+	current atomic.Value // *serverGeneration
+}
+
+// ServerKeys is the key material of a server, as given to SetKeyMaterial.
+type ServerKeys struct {
+	// Identity is the server's identity, defaulting to its public key if nil.
+	Identity []byte
+
+	// SecretKey and PublicKey are the server's long-term AKE key pair.
+	SecretKey []byte
+	PublicKey []byte
+
+	// OPRFSeed is the seed from which the per-client OPRF keys are derived.
+	OPRFSeed []byte
+}
+
+// serverGeneration is a configuration and key material of a ServerPool, with the Servers using them.
+type serverGeneration struct {
+	pool sync.Pool
+	conf Configuration
+	keys *keyMaterial
+}
+
+// newServerGeneration validates the configuration and the key material, if any.
+func newServerGeneration(c *Configuration, keys *ServerKeys) (*serverGeneration, error) {
+	g := &serverGeneration{conf: *c}
+
+	s, err := NewServer(&g.conf)
+	if err != nil {
+		return nil, err
+	}
+
+	s.generation = g
+
+	if keys != nil {
+		if err = s.SetKeyMaterial(keys.Identity, keys.SecretKey, keys.PublicKey, keys.OPRFSeed); err != nil {
+			return nil, err
+		}
+
+		g.keys = s.keys
+	}
+
+	g.pool.New = func() interface{} {
+		// The configuration has been checked above.
+		s, _ := NewServer(&g.conf)
+		s.generation = g
+
+		return s
+	}
+	g.pool.Put(s)
+
+	return g, nil
+}
+
+// ServerPool returns a ServerPool for the Configuration, which is copied, so that later changes have no effect.
+func (c *Configuration) ServerPool() (*ServerPool, error) {
+	g, err := newServerGeneration(c, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ServerPool{}
+	p.current.Store(g)
+
+	return p, nil
+}
+
+// Swap atomically replaces the configuration and the key material of the Servers taken from the pool afterwards, e.g.
+// to rotate the server's keys. The Servers already taken keep the previous ones for the logins in flight, and are
+// dropped when put back. A nil conf keeps the current configuration, and nil keys take the Servers without key
+// material. The configuration is copied, and the key material is validated as with SetKeyMaterial and copied, before
+// anything is replaced: on error, the pool is unchanged. The previous key material is not zeroed, since Servers may
+// concurrently be taken with it, but each Server zeroes its copy when put back.
+func (p *ServerPool) Swap(keys *ServerKeys, conf *Configuration) error {
+	if conf == nil {
+		conf = &p.generation().conf
+	}
+
+	g, err := newServerGeneration(conf, keys)
+	if err != nil {
+		return err
+	}
+
+	p.current.Store(g)
+
+	return nil
+}
+
+func (p *ServerPool) generation() *serverGeneration {
+	return p.current.Load().(*serverGeneration)
+}
+
+// GetServerSession returns a Server ready for a new login, with the configuration and the key material of the pool,
+// if any, for RegistrationResponseWithKeys and LoginInitWithKeys.
+func (p *ServerPool) GetServerSession() *Server {
+	g := p.generation()
+	s := g.pool.Get().(*Server)
+
+	if g.keys != nil {
+		s.keys = &keyMaterial{
+			identity:  g.keys.identity,
+			secretKey: append([]byte(nil), g.keys.secretKey...),
+			publicKey: g.keys.publicKey,
+			pks:       g.keys.pks,
+			oprfSeed:  append([]byte(nil), g.keys.oprfSeed...),
+		}
+	}
+
+	return s
+}
+
+// PutServerSession wipes the Server's state and puts it back in the pool. The session key and any other secret values
+// obtained from the Server are zeroed too, and must be copied beforehand if they are still needed. The Server must not
+// be used afterwards. A Server taken before a Swap, or not taken from this pool, is wiped and dropped.
+func (p *ServerPool) PutServerSession(s *Server) {
+	s.Wipe()
+
+	if g := p.generation(); s.generation == g {
+		g.pool.Put(s)
+	}
+}
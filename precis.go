@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/width"
+)
+
+// errNormalization happens when the input can't be prepared with the OpaqueString profile.
+var errNormalization = errors.New("invalid input for the OpaqueString profile")
+
+// NormalizePassword maps full-width and half-width characters to their canonical width, and then prepares and enforces
+// the password with the PRECIS OpaqueString profile (RFC 8265): mapping of non-ASCII spaces to ASCII space, and NFC
+// normalization. The width folding departs from RFC 8265, whose OpaqueString profile leaves full-width and half-width
+// characters as they are, so the output differs from that of other OpaqueString implementations for passwords that
+// contain them. Using it on both registration and login avoids authentication failures when the same password is typed
+// on platforms that encode Unicode differently. Normalization is opt-in: applications must apply it consistently, as
+// it changes the input to the OPRF.
+func NormalizePassword(password []byte) ([]byte, error) {
+	return normalize(password)
+}
+
+// NormalizeIdentity applies the same preparation as NormalizePassword to the client or server identity, so that
+// identities bound into the envelope and the AKE transcript are the same across platforms.
+func NormalizeIdentity(identity []byte) ([]byte, error) {
+	if identity == nil {
+		return nil, nil
+	}
+
+	return normalize(identity)
+}
+
+// normalize folds the width of the input, which RFC 8265 doesn't, before applying the OpaqueString profile.
+func normalize(input []byte) ([]byte, error) {
+	out, err := precis.OpaqueString.Bytes(width.Fold.Bytes(input))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNormalization, err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/ake"
+	"github.com/bytemare/opaque/internal/tag"
+	"github.com/bytemare/opaque/message"
+)
+
+// After a successful login, both ends can keep a re-authentication secret, from ReauthSecret() or derived by the
+// application from the export key on the client and a value registered with the server. Later, the client can prove
+// it still holds that secret with a lightweight exchange of fresh nonces and MACs, without running the OPRF and the
+// key stretching function again:
+//
+//	client: ReauthInit() -> ReauthRequest
+//	server: ReauthRespond(ReauthRequest) -> ReauthResponse
+//	client: ReauthFinish(ReauthResponse) -> ReauthFinish
+//	server: ReauthVerify(ReauthFinish)
+//
+// This is meant for "confirm it is still you" checkpoints within a session, and doesn't replace a login: the secret
+// only proves possession of the prior session, not of the password.
+
+// reauthLabel is the exporter label of the re-authentication secret.
+const reauthLabel = "Reauthentication"
+
+var (
+	// ErrReauthInvalidMac indicates that the peer's MAC in the re-authentication flow is not valid for the secret.
+	ErrReauthInvalidMac = errors.New("failed to re-authenticate: invalid mac")
+
+	errReauthSecret = errors.New("empty re-authentication secret")
+	errReauthState  = errors.New("re-authentication message out of order")
+)
+
+// ReauthSecret returns the secret for later re-authentications, available after a successful LoginFinish.
+func (c *Client) ReauthSecret() ([]byte, error) {
+	return c.Exporter(reauthLabel, nil, c.conf.KDF.Size())
+}
+
+// ReauthSecret returns the secret for later re-authentications, available after a successful LoginFinish.
+func (s *Server) ReauthSecret() ([]byte, error) {
+	return s.Exporter(reauthLabel, nil, s.conf.KDF.Size())
+}
+
+// Reauthenticator runs one end of a single re-authentication exchange.
+type Reauthenticator struct {
+	// Deserialize provides deserialization functions for the re-authentication messages.
+	Deserialize *Deserializer
+
+	conf      *internal.Configuration
+	secret    []byte
+	clientMac []byte
+	nonce     []byte
+	server    bool
+}
+
+// Reauthenticator returns a Reauthenticator for one re-authentication exchange with the given secret.
+func (c *Configuration) Reauthenticator(secret []byte) (*Reauthenticator, error) {
+	if len(secret) == 0 {
+		return nil, errReauthSecret
+	}
+
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reauthenticator{
+		Deserialize: newDeserializer(conf),
+		conf:        conf,
+		secret:      secret,
+	}, nil
+}
+
+// ReauthInit returns the client's fresh challenge, starting the exchange.
+func (r *Reauthenticator) ReauthInit() *message.ReauthRequest {
+	r.nonce = r.conf.HedgedBytes(tag.HedgedNonce, r.conf.NonceLen, r.secret)
+	return &message.ReauthRequest{Nonce: r.nonce}
+}
+
+// ReauthRespond returns the server's answer to the client's challenge, with its own fresh challenge.
+func (r *Reauthenticator) ReauthRespond(request *message.ReauthRequest) (*message.ReauthResponse, error) {
+	if request == nil {
+		return nil, errIncompleteMessage
+	}
+
+	if r.nonce != nil || len(request.Nonce) != r.conf.NonceLen {
+		return nil, errReauthState
+	}
+
+	r.nonce = r.conf.HedgedBytes(tag.HedgedNonce, r.conf.NonceLen, r.secret, request.Nonce)
+	r.server = true
+
+	var serverMac []byte
+	serverMac, r.clientMac = ake.ReauthMacs(r.conf, r.secret, request.Nonce, r.nonce)
+
+	return &message.ReauthResponse{Nonce: r.nonce, Mac: serverMac}, nil
+}
+
+// ReauthFinish verifies the server's answer, and returns the client's proof if it is valid.
+func (r *Reauthenticator) ReauthFinish(response *message.ReauthResponse) (*message.ReauthFinish, error) {
+	if response == nil {
+		return nil, errIncompleteMessage
+	}
+
+	if r.nonce == nil || r.server || r.clientMac != nil || len(response.Nonce) != r.conf.NonceLen {
+		return nil, errReauthState
+	}
+
+	serverMac, clientMac := ake.ReauthMacs(r.conf, r.secret, r.nonce, response.Nonce)
+	if !r.conf.MAC.Equal(serverMac, response.Mac) {
+		return nil, internal.Detail(ErrReauthInvalidMac, "server MAC of %d bytes, expected %d", len(response.Mac),
+			len(serverMac))
+	}
+
+	r.clientMac = clientMac
+
+	return &message.ReauthFinish{Mac: clientMac}, nil
+}
+
+// ReauthVerify returns nil if the client's proof is valid, completing the exchange on the server.
+func (r *Reauthenticator) ReauthVerify(finish *message.ReauthFinish) error {
+	if finish == nil {
+		return errIncompleteMessage
+	}
+
+	if !r.server {
+		return errReauthState
+	}
+
+	if !r.conf.MAC.Equal(r.clientMac, finish.Mac) {
+		return internal.Detail(ErrReauthInvalidMac, "client MAC of %d bytes, expected %d", len(finish.Mac),
+			len(r.clientMac))
+	}
+
+	return nil
+}
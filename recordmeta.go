@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// A stored ClientRecord can carry operational metadata, e.g. when it was registered and under which configuration and
+// OPRF seed, so that operators can tell which records a rotation or a migration affects. The metadata and the record
+// are authenticated together under a server key, so that a tampered metadata section, or one moved to another record,
+// is detected when the record is read back.
+
+const (
+	// RecordSchemaVersion is the version of the layout written by SerializeWithMetadata.
+	RecordSchemaVersion = 1
+
+	recordMetadataKeyLength = 32
+	recordMetadataDST       = "OPAQUE-RecordMetadata"
+
+	// recordMetadataHeaderLength is the length of the schema version, creation time, and seed version.
+	recordMetadataHeaderLength = 2 + 8 + 4
+)
+
+var (
+	// ErrRecordMetadata indicates that a record's metadata is malformed, or doesn't authenticate under the given key.
+	ErrRecordMetadata = errors.New("invalid or tampered record metadata")
+
+	// ErrRecordSchemaVersion indicates that a record was serialized with an unknown schema version.
+	ErrRecordSchemaVersion = errors.New("unknown record schema version")
+
+	errRecordMetadataKeyLength = errors.New("invalid record metadata key length")
+)
+
+// RecordMetadata is the operational data stored alongside a ClientRecord.
+type RecordMetadata struct {
+	// Created is when the record was registered, with a precision of a second.
+	Created time.Time
+
+	// Configuration is the fingerprint of the Configuration the record was registered in, as returned by
+	// Configuration.Fingerprint.
+	Configuration []byte
+
+	// SeedVersion identifies the OPRF seed the record was registered with, for deployments rotating it.
+	SeedVersion uint32
+
+	// SchemaVersion is the version of the layout the record was serialized with. It's set on deserialization, and
+	// RecordSchemaVersion is always written.
+	SchemaVersion uint16
+}
+
+// SerializeWithMetadata returns the encoding of the record and its metadata, authenticated with HMAC-SHA256 under
+// metadataKey, to be stored and read back with Deserializer.ClientRecordWithMetadata. metadataKey must be a 32-byte
+// server secret.
+func (r *ClientRecord) SerializeWithMetadata(metadataKey []byte, metadata *RecordMetadata) ([]byte, error) {
+	if len(metadataKey) != recordMetadataKeyLength {
+		return nil, errRecordMetadataKeyLength
+	}
+
+	if r.RegistrationRecord == nil || r.PublicKey == nil || metadata == nil {
+		return nil, errIncompleteMessage
+	}
+
+	if len(r.CredentialIdentifier) > maxIdentityLength || len(r.ClientIdentity) > maxIdentityLength ||
+		len(metadata.Configuration) > maxIdentityLength {
+		return nil, errIdentityLength
+	}
+
+	header := make([]byte, recordMetadataHeaderLength)
+	binary.BigEndian.PutUint16(header, RecordSchemaVersion)
+	binary.BigEndian.PutUint64(header[2:], uint64(metadata.Created.Unix()))
+	binary.BigEndian.PutUint32(header[10:], metadata.SeedVersion)
+
+	body := encoding.Concatenate(
+		header,
+		encoding.EncodeVector(metadata.Configuration),
+		encoding.EncodeVector(r.CredentialIdentifier),
+		encoding.EncodeVector(r.ClientIdentity),
+		r.RegistrationRecord.Serialize(),
+	)
+
+	return encoding.Concat(body, recordMetadataMac(metadataKey, body)), nil
+}
+
+func recordMetadataMac(metadataKey, body []byte) []byte {
+	mac := hmac.New(sha256.New, metadataKey)
+	_, _ = mac.Write([]byte(recordMetadataDST))
+	_, _ = mac.Write(body)
+
+	return mac.Sum(nil)
+}
+
+// ClientRecordWithMetadata verifies the encoding of SerializeWithMetadata under metadataKey, and returns the record and
+// its metadata. It returns an error wrapping ErrRecordMetadata if the encoding doesn't authenticate, and
+// ErrRecordSchemaVersion if it was written in an unknown layout. The caller should check that the configuration
+// fingerprint of the metadata is the one of the Deserializer's configuration.
+func (d *Deserializer) ClientRecordWithMetadata(metadataKey, encoded []byte) (*ClientRecord, *RecordMetadata, error) {
+	if len(metadataKey) != recordMetadataKeyLength {
+		return nil, nil, errRecordMetadataKeyLength
+	}
+
+	if len(encoded) < recordMetadataHeaderLength+sha256.Size {
+		return nil, nil, ErrRecordMetadata
+	}
+
+	body := encoded[:len(encoded)-sha256.Size]
+	if !internal.ConstantTimeEqual(recordMetadataMac(metadataKey, body), encoded[len(body):]) {
+		return nil, nil, ErrRecordMetadata
+	}
+
+	metadata := &RecordMetadata{
+		Created:       time.Unix(int64(binary.BigEndian.Uint64(body[2:])), 0),
+		SeedVersion:   binary.BigEndian.Uint32(body[10:]),
+		SchemaVersion: binary.BigEndian.Uint16(body),
+	}
+
+	if metadata.SchemaVersion != RecordSchemaVersion {
+		return nil, nil, ErrRecordSchemaVersion
+	}
+
+	var fields [3][]byte
+
+	rest := body[recordMetadataHeaderLength:]
+	for i := range fields {
+		field, offset, err := encoding.DecodeVector(rest)
+		if err != nil {
+			return nil, nil, ErrRecordMetadata
+		}
+
+		fields[i], rest = field, rest[offset:]
+	}
+
+	record, err := d.RegistrationRecord(rest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrRecordMetadata, err)
+	}
+
+	metadata.Configuration = fields[0]
+
+	clientIdentity := fields[2]
+	if len(clientIdentity) == 0 {
+		clientIdentity = nil
+	}
+
+	return &ClientRecord{
+		CredentialIdentifier: fields[1],
+		ClientIdentity:       clientIdentity,
+		RegistrationRecord:   record,
+	}, metadata, nil
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+
+	"github.com/bytemare/opaque/internal"
+)
+
+const (
+	recoveryCodeLength    = 20
+	recoveryCodeGroupSize = 4
+	maxRecoveryCodes      = 64
+)
+
+var (
+	// ErrRecoveryCode indicates that a recovery code is malformed.
+	ErrRecoveryCode = errors.New("invalid recovery code")
+
+	// ErrPasswordResetRequired indicates that the account was recovered, and that a new password must be registered
+	// before regular logins are accepted again.
+	ErrPasswordResetRequired = errors.New("password reset required")
+
+	// ErrNoPasswordReset indicates that a password reset was completed on an account that is not being recovered.
+	ErrNoPasswordReset = errors.New("no password reset pending")
+
+	errRecoveryCodeCount = errors.New("invalid number of recovery codes")
+
+	recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// RecoveryCode is a high-entropy, human-transcribable secret, that is registered as the password of an additional
+// OPAQUE record on the account. It is displayed to the user in groups, e.g. "ABCD-EFGH-...".
+type RecoveryCode string
+
+// GenerateRecoveryCodes returns n fresh recovery codes.
+func GenerateRecoveryCodes(n int) ([]RecoveryCode, error) {
+	if n <= 0 || n > maxRecoveryCodes {
+		return nil, errRecoveryCodeCount
+	}
+
+	codes := make([]RecoveryCode, n)
+	for i := range codes {
+		code, err := internal.RandomBytes(recoveryCodeLength)
+		if err != nil {
+			return nil, err
+		}
+
+		codes[i] = formatRecoveryCode(recoveryEncoding.EncodeToString(code))
+	}
+
+	return codes, nil
+}
+
+func formatRecoveryCode(code string) RecoveryCode {
+	var b strings.Builder
+
+	for i, r := range code {
+		if i > 0 && i%recoveryCodeGroupSize == 0 {
+			b.WriteByte('-')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return RecoveryCode(b.String())
+}
+
+// ParseRecoveryCode returns the recovery code as typed by the user, ignoring case, spaces, and dashes.
+func ParseRecoveryCode(input string) (RecoveryCode, error) {
+	clean := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToUpper(input))
+
+	decoded, err := recoveryEncoding.DecodeString(clean)
+	if err != nil || len(decoded) != recoveryCodeLength {
+		return "", ErrRecoveryCode
+	}
+
+	return formatRecoveryCode(clean), nil
+}
+
+// Password returns the canonical encoding of the recovery code, to be used as the OPAQUE password for the recovery
+// record at registration and at recovery login.
+func (r RecoveryCode) Password() []byte {
+	return []byte(strings.ReplaceAll(string(r), "-", ""))
+}
+
+// AddRecoveryRecord attaches a record registered with a recovery code to the account. It returns ErrAccountIdentity if
+// the record's client identity is not the account's identity.
+func (a *Account) AddRecoveryRecord(record *ClientRecord) error {
+	if err := a.checkIdentity(record); err != nil {
+		return err
+	}
+
+	if _, err := a.RecoveryRecord(record.CredentialIdentifier); err == nil {
+		return ErrDeviceExists
+	}
+
+	a.RecoveryRecords = append(a.RecoveryRecords, record)
+
+	return nil
+}
+
+// RecoveryRecord returns the recovery record with the credential identifier, to run a recovery login.
+func (a *Account) RecoveryRecord(credentialIdentifier []byte) (*ClientRecord, error) {
+	for _, r := range a.RecoveryRecords {
+		if internal.ConstantTimeEqual(r.CredentialIdentifier, credentialIdentifier) {
+			return r, nil
+		}
+	}
+
+	return nil, ErrDeviceNotFound
+}
+
+// AcceptRecovery must be called after a successful login with a recovery record. The recovery record is consumed, as
+// every code can only be used once, and the account requires a password reset before accepting regular logins.
+func (a *Account) AcceptRecovery(credentialIdentifier []byte) error {
+	for i, r := range a.RecoveryRecords {
+		if internal.ConstantTimeEqual(r.CredentialIdentifier, credentialIdentifier) {
+			a.RecoveryRecords = append(a.RecoveryRecords[:i], a.RecoveryRecords[i+1:]...)
+			a.PasswordResetRequired = true
+
+			return nil
+		}
+	}
+
+	return ErrDeviceNotFound
+}
+
+// CheckLogin returns ErrPasswordResetRequired if the account is being recovered, in which case regular logins must be
+// refused until the password is reset.
+func (a *Account) CheckLogin() error {
+	if a.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+
+	return nil
+}
+
+// CompletePasswordReset replaces all device records of a recovered account with the record registered with the new
+// password, and accepts regular logins again.
+func (a *Account) CompletePasswordReset(record *ClientRecord) error {
+	if !a.PasswordResetRequired {
+		return ErrNoPasswordReset
+	}
+
+	a.Devices = nil
+	a.PasswordResetRequired = false
+
+	return a.AddDevice(record)
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"fmt"
+
+	"github.com/bytemare/opaque/internal"
+)
+
+// The types holding secrets implement fmt.Stringer, fmt.GoStringer, and fmt.Formatter to print the lengths and
+// fingerprints of their secrets but never their values, for any formatting verb, so that logging them by accident
+// doesn't leak masking keys, session keys, or export keys. The fingerprints tell values apart without revealing them.
+
+// configurationLabel returns a short public identifier of the configuration from its fingerprint.
+func configurationLabel(fingerprint []byte) string {
+	if len(fingerprint) < 8 {
+		return "<empty>"
+	}
+
+	return fmt.Sprintf("%x", fingerprint[:8])
+}
+
+// String returns a description of the client that doesn't print its secrets.
+func (c *Client) String() string {
+	if c == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("opaque.Client{Configuration: %s, SessionKey: %s}",
+		configurationLabel(c.fingerprint), internal.Redacted(c.SessionKey()))
+}
+
+// GoString returns the same description as String.
+func (c *Client) GoString() string {
+	return c.String()
+}
+
+// Format prints the description of String for any verb.
+func (c *Client) Format(f fmt.State, _ rune) {
+	internal.Format(f, c)
+}
+
+// String returns a description of the server that doesn't print its secrets.
+func (s *Server) String() string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("opaque.Server{Configuration: %s, SessionKey: %s, Finished: %t}",
+		configurationLabel(s.fingerprint), internal.Redacted(s.SessionKey()), s.finished)
+}
+
+// GoString returns the same description as String.
+func (s *Server) GoString() string {
+	return s.String()
+}
+
+// Format prints the description of String for any verb.
+func (s *Server) Format(f fmt.State, _ rune) {
+	internal.Format(f, s)
+}
+
+// String returns a description of the record that doesn't print its masking key and envelope, nor the client's
+// identifiers.
+func (r *ClientRecord) String() string {
+	if r == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("opaque.ClientRecord{CredentialIdentifier: %s, ClientIdentity: %s, RegistrationRecord: %s}",
+		internal.Redacted(r.CredentialIdentifier), internal.Redacted(r.ClientIdentity), r.RegistrationRecord.String())
+}
+
+// GoString returns the same description as String.
+func (r *ClientRecord) GoString() string {
+	return r.String()
+}
+
+// Format prints the description of String for any verb.
+func (r *ClientRecord) Format(f fmt.State, _ rune) {
+	internal.Format(f, r)
+}
+
+// String returns the length and fingerprint of the export key, but not its value.
+func (k ExportKey) String() string {
+	return internal.Redacted(k)
+}
+
+// GoString returns the same description as String.
+func (k ExportKey) GoString() string {
+	return k.String()
+}
+
+// Format prints the description of String for any verb.
+func (k ExportKey) Format(f fmt.State, _ rune) {
+	internal.Format(f, k)
+}
+
+// String returns a description of the key share that doesn't print the share.
+func (s *OPRFKeyShare) String() string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("opaque.OPRFKeyShare{Index: %d, Share: %s}", s.Index, internal.Redacted(s.Share))
+}
+
+// GoString returns the same description as String.
+func (s *OPRFKeyShare) GoString() string {
+	return s.String()
+}
+
+// Format prints the description of String for any verb.
+func (s *OPRFKeyShare) Format(f fmt.State, _ rune) {
+	internal.Format(f, s)
+}
+
+// String returns a description of the handshake result that doesn't print its keys. The session identifier and the
+// configuration fingerprint are public, and printed in hexadecimal.
+func (r *HandshakeResult) String() string {
+	if r == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf(
+		"opaque.HandshakeResult{SessionKey: %s, ExportKey: %s, SessionID: %x, ClientIdentity: %s, "+
+			"ServerIdentity: %s, ConfigurationFingerprint: %x}",
+		internal.Redacted(r.SessionKey), internal.Redacted(r.ExportKey), r.SessionID,
+		internal.Redacted(r.ClientIdentity), internal.Redacted(r.ServerIdentity), r.ConfigurationFingerprint,
+	)
+}
+
+// GoString returns the same description as String.
+func (r *HandshakeResult) GoString() string {
+	return r.String()
+}
+
+// Format prints the description of String for any verb.
+func (r *HandshakeResult) Format(f fmt.State, _ rune) {
+	internal.Format(f, r)
+}
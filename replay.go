@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+// A replayed KE3 can't complete a login, since its MAC covers the server's fresh nonce and ephemeral key, but a
+// replayed KE1 still makes the server answer and spend work on it. Deployments whose transport doesn't guarantee
+// freshness can set a ReplayCache on their servers, which then reject a KE1 reusing a client nonce or ephemeral key
+// seen within the cache's window.
+
+// ErrReplay indicates a KE1 whose client nonce or ephemeral public key was already seen.
+var ErrReplay = errors.New("replayed KE1: client nonce or ephemeral key already seen")
+
+// Prefixes separating the nonces from the ephemeral keys in a ReplayCache.
+const (
+	replayNonce byte = iota + 1
+	replayEphemeralKey
+)
+
+// ReplayCache records the values seen by servers, e.g. in memory or in a shared store for multiple instances.
+type ReplayCache interface {
+	// Add records the value, and returns false if it was already recorded and has not expired.
+	Add(value []byte) (bool, error)
+}
+
+// MemoryReplayCache is an in-memory ReplayCache, safe for concurrent use, that forgets values after a window.
+type MemoryReplayCache struct {
+	seen      map[string]time.Time
+	nextSweep time.Time
+	window    time.Duration
+	mu        sync.Mutex
+}
+
+// NewMemoryReplayCache returns a MemoryReplayCache remembering values for the given window.
+func NewMemoryReplayCache(window time.Duration) *MemoryReplayCache {
+	return &MemoryReplayCache{
+		seen:   make(map[string]time.Time),
+		window: window,
+	}
+}
+
+// Add records the value, and returns false if it was already recorded within the window.
+func (m *MemoryReplayCache) Add(value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	if now.After(m.nextSweep) {
+		for k, expiry := range m.seen {
+			if now.After(expiry) {
+				delete(m.seen, k)
+			}
+		}
+
+		m.nextSweep = now.Add(m.window)
+	}
+
+	if expiry, ok := m.seen[string(value)]; ok && !now.After(expiry) {
+		return false, nil
+	}
+
+	m.seen[string(value)] = now.Add(m.window)
+
+	return true, nil
+}
+
+// SetReplayCache sets the cache with which LoginInit rejects replayed KE1 messages. A nil cache disables it.
+func (s *Server) SetReplayCache(cache ReplayCache) {
+	s.replay = cache
+}
+
+func (s *Server) checkReplay(ke1 *message.KE1) error {
+	if s.replay == nil {
+		return nil
+	}
+
+	for _, value := range [][]byte{
+		encoding.Concat([]byte{replayNonce}, ke1.NonceU),
+		encoding.Concat([]byte{replayEphemeralKey}, encoding.SerializePoint(ke1.EpkU, s.conf.Group)),
+	} {
+		fresh, err := s.replay.Add(value)
+		if err != nil {
+			return err
+		}
+
+		if !fresh {
+			return ErrReplay
+		}
+	}
+
+	return nil
+}
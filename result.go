@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"github.com/bytemare/opaque/message"
+)
+
+// sessionIDLabel is the exporter label of the session identifier.
+const sessionIDLabel = "SessionIdentifier"
+
+// HandshakeResult gathers the outputs of a successful login.
+type HandshakeResult struct {
+	// SessionKey is the secret shared key of the session.
+	SessionKey []byte
+
+	// ExportKey is the client's export key, and is empty on the server.
+	ExportKey ExportKey
+
+	// SessionID is a public identifier of the session, bound to its transcript, that both ends compute without
+	// revealing the session key.
+	SessionID []byte
+
+	// ClientIdentity and ServerIdentity are the identities the AKE authenticated, which are the public keys if none
+	// were given.
+	ClientIdentity []byte
+	ServerIdentity []byte
+
+	// ConfigurationFingerprint is the Fingerprint of the Configuration of the login.
+	ConfigurationFingerprint []byte
+}
+
+// LoginFinishWithResult is like LoginFinish, but returns the outputs of the login in a HandshakeResult.
+func (c *Client) LoginFinishWithResult(
+	clientIdentity, serverIdentity []byte,
+	ke2 *message.KE2,
+) (*message.KE3, *HandshakeResult, error) {
+	ke3, exportKey, err := c.LoginFinish(clientIdentity, serverIdentity, ke2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionID, err := c.Exporter(sessionIDLabel, nil, c.conf.KDF.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ke3, &HandshakeResult{
+		SessionKey:               c.SessionKey(),
+		ExportKey:                exportKey,
+		SessionID:                sessionID,
+		ClientIdentity:           c.identities[0],
+		ServerIdentity:           c.identities[1],
+		ConfigurationFingerprint: c.fingerprint,
+	}, nil
+}
+
+// LoginFinishWithResult is like LoginFinish, but returns the outputs of the login in a HandshakeResult.
+func (s *Server) LoginFinishWithResult(ke3 *message.KE3) (*HandshakeResult, error) {
+	if err := s.LoginFinish(ke3); err != nil {
+		return nil, err
+	}
+
+	sessionID, err := s.Exporter(sessionIDLabel, nil, s.conf.KDF.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	return &HandshakeResult{
+		SessionKey:               s.SessionKey(),
+		SessionID:                sessionID,
+		ClientIdentity:           s.identities[0],
+		ServerIdentity:           s.identities[1],
+		ConfigurationFingerprint: s.fingerprint,
+	}, nil
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sort"
+
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// A second authentication factor can be bound into the login, so that a wrong factor makes the AKE itself fail
+// instead of being checked separately afterwards: both ends derive the configuration they run the login with from
+// WithSecondFactor and the evidence they each hold, e.g. a WebAuthn assertion hash that the server has verified.
+// If they differ, the client fails on the server MAC in KE2, and the server on the client MAC in KE3.
+//
+// The evidence must be high-entropy: the server sends KE2 before the client proves anything, so a client knowing the
+// password can try every candidate evidence offline against the server MAC. Low-entropy factors, e.g. TOTP codes,
+// must instead be checked by the server after LoginFinish.
+
+// SecondFactorType identifies the kind of a second-factor evidence.
+type SecondFactorType byte
+
+// WebAuthn is the hash of a WebAuthn assertion, e.g. its clientDataHash.
+const WebAuthn SecondFactorType = 1
+
+// minSecondFactorLength is the minimum length of a second-factor evidence.
+const minSecondFactorLength = sha256.Size
+
+// errSecondFactorLength happens when a second-factor evidence is too short to be high-entropy.
+var errSecondFactorLength = errors.New("second-factor evidence is shorter than 32 bytes")
+
+// SecondFactor is a piece of additional authentication evidence.
+type SecondFactor struct {
+	Evidence []byte
+	Type     SecondFactorType
+}
+
+// WebAuthnFactor returns the second-factor evidence of a WebAuthn assertion hash.
+func WebAuthnFactor(assertionHash []byte) SecondFactor {
+	return SecondFactor{Type: WebAuthn, Evidence: assertionHash}
+}
+
+// digest returns the fixed-length commitment to the evidence that goes into the context.
+func (f SecondFactor) digest() []byte {
+	h := sha256.Sum256(encoding.Concat([]byte{byte(f.Type)}, f.Evidence))
+	return encoding.Concat([]byte{byte(f.Type)}, h[:])
+}
+
+// WithSecondFactor returns a copy of the Configuration whose Context also binds the given second-factor evidence.
+// The encoding is canonical: the order of the factors doesn't matter. Without factors, the copy is identical. It
+// returns an error if an evidence is shorter than 32 bytes.
+func (c *Configuration) WithSecondFactor(factors ...SecondFactor) (*Configuration, error) {
+	conf := *c
+	if len(factors) == 0 {
+		return &conf, nil
+	}
+
+	digests := make([][]byte, len(factors))
+	for i, f := range factors {
+		if len(f.Evidence) < minSecondFactorLength {
+			return nil, errSecondFactorLength
+		}
+
+		digests[i] = f.digest()
+	}
+
+	sort.Slice(digests, func(i, j int) bool {
+		return string(digests[i]) < string(digests[j])
+	})
+
+	conf.Context = encoding.Concatenate(encoding.EncodeVector(c.Context), []byte(tag.SecondFactor),
+		encoding.Concatenate(digests...))
+
+	return &conf, nil
+}
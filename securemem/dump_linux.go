@@ -0,0 +1,15 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package securemem
+
+import "golang.org/x/sys/unix"
+
+func excludeFromDumps(region []byte) error {
+	return unix.Madvise(region, unix.MADV_DONTDUMP)
+}
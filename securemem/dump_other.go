@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package securemem
+
+// excludeFromDumps is a no-op on platforms without a per-mapping core dump exclusion, where locked memory is only
+// kept out of swap.
+func excludeFromDumps(_ []byte) error {
+	return nil
+}
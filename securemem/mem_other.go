@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package securemem
+
+func alloc(_ int) (mem, data []byte, err error) {
+	return nil, nil, ErrUnsupported
+}
+
+func free(_ []byte) error {
+	return ErrUnsupported
+}
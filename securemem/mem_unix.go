@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package securemem
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// alloc maps the data pages between two guard pages, and locks them. The data is placed at the end of the data pages.
+func alloc(size int) (mem, data []byte, err error) {
+	page := os.Getpagesize()
+	dataLength := (size + page - 1) / page * page
+
+	mem, err = unix.Mmap(-1, 0, dataLength+2*page, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	region := mem[page : page+dataLength]
+
+	for _, guard := range [][]byte{mem[:page], mem[page+dataLength:]} {
+		if err = unix.Mprotect(guard, unix.PROT_NONE); err != nil {
+			_ = unix.Munmap(mem)
+			return nil, nil, err
+		}
+	}
+
+	if err = unix.Mlock(region); err != nil {
+		_ = unix.Munmap(mem)
+		return nil, nil, err
+	}
+
+	if err = excludeFromDumps(region); err != nil {
+		_ = unix.Munlock(region)
+		_ = unix.Munmap(mem)
+
+		return nil, nil, err
+	}
+
+	return mem, region[dataLength-size:], nil
+}
+
+func free(mem []byte) error {
+	page := os.Getpagesize()
+	if err := unix.Munlock(mem[page : len(mem)-page]); err != nil {
+		return err
+	}
+
+	return unix.Munmap(mem)
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package securemem provides buffers for secrets, like passwords and keys, in memory that is locked against swapping,
+// excluded from core dumps, and surrounded by guard pages, on the platforms supporting it.
+//
+// A Buffer is mapped outside the Go heap, so its content is never copied by the garbage collector. The data ends
+// right before the trailing guard page, so that an overflow faults instead of reading or writing past it. Buffers
+// must be destroyed when they're no longer needed, which zeroes and unmaps them. The amount of locked memory is
+// bounded by the process' limits, e.g. RLIMIT_MEMLOCK, and each Buffer takes at least three pages.
+package securemem
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrUnsupported indicates a platform without locked memory.
+	ErrUnsupported = errors.New("securemem: locked memory is not supported on this platform")
+
+	errSize = errors.New("securemem: invalid buffer size")
+)
+
+// Buffer holds a secret in locked memory.
+type Buffer struct {
+	mem  []byte
+	data []byte
+	mu   sync.Mutex
+}
+
+// New returns a zeroed Buffer of the given size, which must be positive.
+func New(size int) (*Buffer, error) {
+	if size <= 0 {
+		return nil, errSize
+	}
+
+	mem, data, err := alloc(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Buffer{mem: mem, data: data}, nil
+}
+
+// Copy returns a Buffer holding a copy of secret, and zeroes secret.
+func Copy(secret []byte) (*Buffer, error) {
+	b, err := New(len(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(b.data, secret)
+
+	for i := range secret {
+		secret[i] = 0
+	}
+
+	return b, nil
+}
+
+// Bytes returns the content of the Buffer, which must not be used after Destroy.
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// Destroy zeroes the Buffer and releases its memory. It is safe to call it more than once.
+func (b *Buffer) Destroy() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mem == nil {
+		return nil
+	}
+
+	for i := range b.data {
+		b.data[i] = 0
+	}
+
+	err := free(b.mem)
+	b.mem, b.data = nil, nil
+
+	return err
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/tag"
+)
+
+// ErrSelfTest indicates that the self-test of a configuration failed.
+var ErrSelfTest = errors.New("self-test failed")
+
+// selfTestAnswers are the SHA-256 digests of the self-test transcripts of DefaultConfiguration, and of the
+// configurations of the P-256, P-384, and P-521 suites with the same parameters, with their fingerprints.
+var selfTestAnswers = []struct{ fingerprint, digest string }{
+	{
+		"2c7c81f3eb24d41df04dbb5e407a9b9a2c67af4a5de104f30da8398854e3d18e",
+		"1f28c4af09d053899ffdf11937f8a50e84437a154e5f1000a350d390ca467367",
+	},
+	{
+		"e060a51df182f097daac91d225b9e0819f1ae281d980fb740237b3340c2794d3",
+		"88930cf1f0921f421fe7bb9dfb8a842dfab7bfc66c4ef084fb65ee397c7056f3",
+	},
+	{
+		"d2627d2073c4d074f779ac580ab8134d0d396bb6dd32a22256c1b907955b03bc",
+		"7a0e28a26dc9d2860fada4c2f57accff179b27c1902730ba0a65f1c5543bde7a",
+	},
+	{
+		"fd370bad41e4caa62cd029abe3c6fac0f73e71ee8c7590a3c4485113d8101e45",
+		"f494d13104e39df1226aa51934d6202cc3d8e715af0b9867a7be10cfbb914b18",
+	},
+}
+
+// selfTestInputs derives the fixed inputs of the self-test.
+type selfTestInputs struct {
+	prk []byte
+}
+
+func (s selfTestInputs) bytes(label string, length int) []byte {
+	return internal.NewKDF(crypto.SHA512).Expand(s.prk, []byte(label), length)
+}
+
+func (s selfTestInputs) scalar(g group.Group, label string) []byte {
+	return encoding.SerializeScalar(g.HashToScalar(s.bytes(label, 64), []byte(tag.SelfTest)), g)
+}
+
+// SelfTest runs a registration and a login with fixed inputs in the configuration, e.g. at process start to verify
+// the integrity of the implementation before serving. The client and server must agree on the export and session
+// keys, and for the configurations with known answers, i.e. DefaultConfiguration and the P-256, P-384, and P-521
+// suites with the same hash functions, KSF, and mode, the messages and keys must match the known answer byte for
+// byte. Configurations with KEMs or the KEMAKE protocol have random values that can't be fixed, and are only tested
+// for agreement. The returned error wraps ErrSelfTest.
+func SelfTest(conf *Configuration) error {
+	if conf == nil {
+		conf = DefaultConfiguration()
+	}
+
+	digest, err := selfTest(conf)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSelfTest, err)
+	}
+
+	fingerprint := hex.EncodeToString(conf.Fingerprint())
+
+	for _, answer := range selfTestAnswers {
+		if answer.fingerprint == fingerprint && answer.digest != hex.EncodeToString(digest) {
+			return fmt.Errorf("%w: the transcript doesn't match the known answer", ErrSelfTest)
+		}
+	}
+
+	return nil
+}
+
+// selfTest runs the self-test in the configuration, and returns the digest of its transcript.
+func selfTest(conf *Configuration) ([]byte, error) {
+	client, err := NewClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := NewServer(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	in := selfTestInputs{prk: internal.NewKDF(crypto.SHA512).Extract([]byte(tag.SelfTest), conf.Fingerprint())}
+	ic := client.conf
+	password, credentialIdentifier := in.bytes("password", 8), in.bytes("credential identifier", 8)
+
+	if err = client.SetKnownAnswers(&KnownAnswers{
+		Blind:              in.scalar(ic.OPRF.Group(), "blind"),
+		EnvelopeNonce:      in.bytes("envelope nonce", ic.NonceLen),
+		EphemeralSecretKey: in.scalar(ic.Group, "client ephemeral secret key"),
+		Nonce:              in.bytes("client nonce", ic.NonceLen),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = server.SetKnownAnswers(&KnownAnswers{
+		MaskingNonce:       in.bytes("masking nonce", ic.NonceLen),
+		EphemeralSecretKey: in.scalar(ic.Group, "server ephemeral secret key"),
+		Nonce:              in.bytes("server nonce", ic.NonceLen),
+	}); err != nil {
+		return nil, err
+	}
+
+	serverSecretKey, serverPublicKey, err := DeriveAKEKeyPair(conf, in.bytes("server key seed", SeedLength))
+	if err != nil {
+		return nil, err
+	}
+
+	oprfSeed := in.bytes("OPRF seed", ic.Hash.Size())
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(serverPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	request := client.RegistrationInit(password)
+
+	response, err := server.RegistrationResponse(request, pks, credentialIdentifier, oprfSeed)
+	if err != nil {
+		return nil, err
+	}
+
+	record, registrationExportKey, err := client.RegistrationFinalize(response, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ke1 := client.LoginInit(password)
+
+	ke2, err := server.LoginInit(ke1, nil, serverSecretKey, serverPublicKey, oprfSeed, &ClientRecord{
+		CredentialIdentifier: credentialIdentifier,
+		RegistrationRecord:   record,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ke3, exportKey, err := client.LoginFinish(nil, nil, ke2)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		return nil, err
+	}
+
+	sessionKey := client.SessionKey()
+
+	if !internal.ConstantTimeEqual(exportKey, registrationExportKey) {
+		return nil, errors.New("the export keys of the registration and the login differ")
+	}
+
+	if !internal.ConstantTimeEqual(sessionKey, server.SessionKey()) {
+		return nil, errors.New("the session keys of the client and the server differ")
+	}
+
+	h := sha256.New()
+
+	for _, v := range [][]byte{
+		request.Serialize(), response.Serialize(), record.Serialize(),
+		ke1.Serialize(), ke2.Serialize(), ke3.Serialize(),
+		exportKey, sessionKey,
+	} {
+		_, _ = h.Write(v)
+	}
+
+	return h.Sum(nil), nil
+}
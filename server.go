@@ -11,6 +11,7 @@ package opaque
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bytemare/crypto/group"
 
@@ -43,13 +44,40 @@ var (
 
 	// ErrZeroSKS indicates that the server's private key is a zero scalar.
 	ErrZeroSKS = errors.New("server private key is zero")
+
+	// ErrInvalidRecord indicates that a RegistrationRecord doesn't hold valid values for the configuration.
+	ErrInvalidRecord = errors.New("invalid registration record")
+
+	// errIncompleteMessage happens when a protocol message given as a structure misses a field.
+	errIncompleteMessage = errors.New("incomplete protocol message")
+
+	errInvalidMaskingKeyLength   = errors.New("invalid masking key length")
+	errRecordRoundTrip           = errors.New("record doesn't deserialize back to itself")
+	errEmptyCredentialIdentifier = errors.New("empty credential identifier")
 )
 
 // Server represents an OPAQUE Server, exposing its functions and holding its state.
 type Server struct {
-	Deserialize *Deserializer
+	// Deserialize parses the messages the Server consumes, e.g. the RegistrationRequest, KE1, and KE3.
+	Deserialize *ServerDeserializer
+
 	conf        *internal.Configuration
 	Ake         *ake.Server
+	oprfInfo    []byte
+	replay      ReplayCache
+	oprfKeys    *OPRFKeyCache
+	fingerprint []byte
+	identities  [2][]byte
+	finished    bool
+	kat         *knownAnswers
+	keys        *keyMaterial
+	logger      eventLogger
+
+	// generation is the ServerPool generation the Server was taken from, if any.
+	generation *serverGeneration
+
+	// responseBuffer holds the masked response of KE2, if set with SetResponseBuffer.
+	responseBuffer []byte
 }
 
 // NewServer returns a Server instantiation given the application Configuration.
@@ -64,9 +92,10 @@ func NewServer(c *Configuration) (*Server, error) {
 	}
 
 	return &Server{
-		Deserialize: &Deserializer{conf: conf},
+		Deserialize: &ServerDeserializer{d: newDeserializer(conf)},
 		conf:        conf,
 		Ake:         ake.NewServer(),
+		fingerprint: c.Fingerprint(),
 	}, nil
 }
 
@@ -75,44 +104,194 @@ func (s *Server) GetConf() *internal.Configuration {
 	return s.conf
 }
 
-func (s *Server) oprfResponse(element *group.Point, oprfSeed, credentialIdentifier []byte) *group.Point {
+// SetOPRFInfo sets a public info string, e.g. an account epoch or policy version, that is bound into the OPRF
+// evaluation using the partially-oblivious (POPRF) mode. The client must set the same info to recover its
+// credentials, and changing it has the same effect as rotating the client's OPRF key. A nil info uses the base OPRF.
+func (s *Server) SetOPRFInfo(info []byte) {
+	s.oprfInfo = info
+}
+
+// SetChannelBinding binds the login to the underlying transport channel, by mixing binding, e.g. a TLS exporter value,
+// into the AKE transcript. It must match the binding set by the client on its end of the channel, and be set before
+// LoginInit. A nil binding disables it. It returns an error if the binding is longer than 65535 bytes.
+func (s *Server) SetChannelBinding(binding []byte) error {
+	if len(binding) > maxChannelBindingLength {
+		return errChannelBindingLength
+	}
+
+	s.conf.ChannelBinding = binding
+
+	return nil
+}
+
+// ResponseBufferSize returns the capacity of a response buffer in which the masked response of KE2 is written without
+// allocation.
+func (s *Server) ResponseBufferSize() int {
+	return masking.BufferSize(s.conf)
+}
+
+// SetResponseBuffer sets a buffer in which the masked response of the next KE2 messages is written, instead of a
+// newly allocated one, for servers where building KE2 is the hot spot. It should have a capacity of at least
+// ResponseBufferSize. The masked response of a KE2 shares its memory with the buffer, so a KE2 must be serialized or
+// sent before the next LoginInit with the same buffer. A nil buffer restores the allocation for each KE2.
+func (s *Server) SetResponseBuffer(buf []byte) {
+	s.responseBuffer = buf
+}
+
+// oprfKey derives the OPRF key of the credential identifier from the seed, or gets it from the OPRF key cache.
+func (s *Server) oprfKey(oprfSeed, credentialIdentifier []byte) (*group.Scalar, error) {
+	if s.oprfKeys != nil {
+		if ku := s.cachedOPRFKey(oprfSeed, credentialIdentifier); ku != nil {
+			return ku, nil
+		}
+	}
+
 	seed := s.conf.KDF.Expand(
 		oprfSeed,
 		encoding.SuffixString(credentialIdentifier, tag.ExpandOPRF),
 		internal.SeedLength,
 	)
-	ku := s.conf.OPRF.DeriveKey(seed, []byte(tag.DeriveKeyPair))
+	defer internal.Zero(seed)
+
+	ku, err := s.conf.OPRF.DeriveKey(seed, []byte(tag.DeriveKeyPair))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.conf.Tracer != nil {
+		s.conf.Trace(internal.TraceOPRFKey, encoding.SerializeScalar(ku, s.conf.OPRF.Group()))
+	}
+
+	if s.oprfKeys != nil {
+		s.cacheOPRFKey(oprfSeed, credentialIdentifier, ku)
+	}
+
+	return ku, nil
+}
+
+func (s *Server) oprfResponse(element *group.Point, oprfSeed, credentialIdentifier []byte) (*group.Point, error) {
+	ku, err := s.oprfKey(oprfSeed, credentialIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.evaluate(ku, element)
+}
 
-	return s.conf.OPRF.Evaluate(ku, element)
+// evaluate evaluates the element under the OPRF key, with the info set with SetOPRFInfo, if any.
+func (s *Server) evaluate(ku *group.Scalar, element *group.Point) (*group.Point, error) {
+	if s.oprfInfo != nil {
+		return s.conf.OPRF.EvaluateWithInfo(ku, element, s.oprfInfo)
+	}
+
+	return s.conf.OPRF.Evaluate(ku, element), nil
 }
 
 // RegistrationResponse returns a RegistrationResponse message to the input RegistrationRequest message and given
 // identifiers.
+//
+// The response is derived from the OPRF seed and the credential identifier only, so it can be returned as is for a
+// credential identifier that is already registered, without revealing it: its OPRF evaluation is the one of a new
+// registration, and of the KE2 of a login, whether there's a record or not. A fake response derived from another
+// secret would be told apart by comparing its evaluation to a KE2's. Only the outcome of storing the record must then
+// be concealed.
 func (s *Server) RegistrationResponse(
 	req *message.RegistrationRequest,
 	serverPublicKey *group.Point,
 	credentialIdentifier, oprfSeed []byte,
-) *message.RegistrationResponse {
-	z := s.oprfResponse(req.BlindedMessage, oprfSeed, credentialIdentifier)
+) (response *message.RegistrationResponse, err error) {
+	defer func(start time.Time) {
+		s.logger.log(phaseRegistrationResponse, start, response, err)
+	}(time.Now())
+
+	if req == nil || req.BlindedMessage == nil {
+		return nil, errIncompleteMessage
+	}
+
+	if serverPublicKey == nil {
+		return nil, errInvalidServerPK
+	}
+
+	if len(oprfSeed) != s.conf.Hash.Size() {
+		return nil, ErrInvalidOPRFSeedLength
+	}
+
+	z, err := s.oprfResponse(req.BlindedMessage, oprfSeed, credentialIdentifier)
+	if err != nil {
+		return nil, err
+	}
 
 	return &message.RegistrationResponse{
 		C:                s.conf.OPRF,
 		G:                s.conf.Group,
 		EvaluatedMessage: z,
 		Pks:              serverPublicKey,
+	}, nil
+}
+
+// VerifyRegistrationRecord checks a RegistrationRecord received from a client before it is stored: the client public
+// key must decode to a valid element of the group other than the identity, the masking key and the envelope must have
+// the lengths of the configuration, and the record must deserialize back to itself. Otherwise, it returns an error
+// wrapping ErrInvalidRecord, so that garbage can't end up in the credential store.
+func (s *Server) VerifyRegistrationRecord(record *message.RegistrationRecord) error {
+	return verifyRegistrationRecord(s.Deserialize.d, record)
+}
+
+func verifyRegistrationRecord(d *Deserializer, record *message.RegistrationRecord) error {
+	if record == nil || record.PublicKey == nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, errIncompleteMessage)
+	}
+
+	if record.G != d.conf.Group {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, errInvalidClientPK)
+	}
+
+	// Some groups can't encode the identity element.
+	if record.PublicKey.IsIdentity() {
+		return fmt.Errorf("%w: %v: %v", ErrInvalidRecord, errInvalidClientPK, errIdentityElement)
+	}
+
+	if _, err := decodePoint(d.conf.Group, encoding.SerializePoint(record.PublicKey, d.conf.Group),
+		errInvalidClientPK); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+
+	if len(record.MaskingKey) != d.conf.Hash.Size() {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, errInvalidMaskingKeyLength)
+	}
+
+	if len(record.Envelope) != d.conf.EnvelopeSize {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, ErrInvalidEnvelopeLength)
+	}
+
+	encoded := record.Serialize()
+
+	decoded, err := d.RegistrationRecord(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, err)
 	}
+
+	if !internal.ConstantTimeEqual(decoded.Serialize(), encoded) {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, errRecordRoundTrip)
+	}
+
+	return nil
 }
 
 func (s *Server) credentialResponse(
-	req *message.CredentialRequest,
+	z *group.Point,
 	serverPublicKey []byte,
 	record *message.RegistrationRecord,
-	credentialIdentifier, oprfSeed, maskingNonce []byte,
+	maskingNonce []byte,
 ) *message.CredentialResponse {
-	z := s.oprfResponse(req.BlindedMessage, oprfSeed, credentialIdentifier)
+	// The masking nonce is hedged with the masking key and the evaluation of the client's request.
+	if len(maskingNonce) == 0 {
+		maskingNonce = s.conf.HedgedBytes(tag.HedgedMaskingNonce, s.conf.NonceLen, record.MaskingKey, z.Bytes())
+	}
 
-	maskingNonce, maskedResponse := masking.Mask(
+	maskingNonce, maskedResponse := masking.MaskInto(
 		s.conf,
+		s.responseBuffer,
 		maskingNonce,
 		record.MaskingKey,
 		serverPublicKey,
@@ -127,10 +306,7 @@ func (s *Server) credentialResponse(
 	}
 }
 
-func (s *Server) verifyInitInput(
-	serverSecretKey, serverPublicKey, oprfSeed []byte,
-	record *ClientRecord,
-) (*group.Scalar, error) {
+func (s *Server) decodeServerSecretKey(serverSecretKey []byte) (*group.Scalar, error) {
 	sks, err := s.conf.Group.NewScalar().Decode(serverSecretKey)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", ErrInvalidServerSecretKey, err)
@@ -140,27 +316,31 @@ func (s *Server) verifyInitInput(
 		return nil, ErrZeroSKS
 	}
 
-	if len(oprfSeed) != s.conf.Hash.Size() {
-		return nil, ErrInvalidOPRFSeedLength
-	}
+	return sks, nil
+}
 
+func (s *Server) verifyInitInput(serverPublicKey []byte, record *ClientRecord) error {
 	if len(serverPublicKey) != s.conf.AkePointLength {
-		return nil, ErrInvalidPksLength
+		return ErrInvalidPksLength
 	}
 
-	_, err = s.conf.Group.NewElement().Decode(serverPublicKey)
+	_, err := s.conf.Group.NewElement().Decode(serverPublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("invalid server public key: %w", err)
+		return fmt.Errorf("invalid server public key: %w", err)
+	}
+
+	if record == nil || record.RegistrationRecord == nil || record.PublicKey == nil {
+		return errIncompleteMessage
 	}
 
 	if len(record.Envelope) != s.conf.EnvelopeSize {
-		return nil, ErrInvalidEnvelopeLength
+		return ErrInvalidEnvelopeLength
 	}
 
 	// We've checked that the server's public key and the client's envelope are of correct length,
 	// thus ensuring that the subsequent xor-ing input is the same length as the encryption pad.
 
-	return sks, nil
+	return nil
 }
 
 // LoginInit responds to a KE1 message with a KE2 message given server credentials and client record.
@@ -169,13 +349,79 @@ func (s *Server) LoginInit(
 	serverIdentity, serverSecretKey, serverPublicKey, oprfSeed []byte,
 	record *ClientRecord,
 ) (*message.KE2, error) {
-	sks, err := s.verifyInitInput(serverSecretKey, serverPublicKey, oprfSeed, record)
+	return s.LoginInitWithInfo(ke1, serverIdentity, serverSecretKey, serverPublicKey, oprfSeed, record, nil)
+}
+
+// LoginInitWithInfo is like LoginInit, but piggybacks the serverInfo application message on KE2, encrypted under the
+// handshake keys. Only the client that knows the password can decrypt it, but serverInfo is sent before the client is
+// authenticated: it must not be anything the server wouldn't disclose to the registered client.
+func (s *Server) LoginInitWithInfo(
+	ke1 *message.KE1,
+	serverIdentity, serverSecretKey, serverPublicKey, oprfSeed []byte,
+	record *ClientRecord,
+	serverInfo []byte,
+) (ke2 *message.KE2, err error) {
+	defer func(start time.Time) {
+		s.logger.log(phaseLoginResponse, start, ke2, err)
+	}(time.Now())
+
+	if len(serverInfo) > maxServerInfoLength(s.conf) {
+		return nil, ErrInfoLength
+	}
+
+	sks, err := s.decodeServerSecretKey(serverSecretKey)
 	if err != nil {
 		return nil, err
 	}
 
-	response := s.credentialResponse(ke1.CredentialRequest, serverPublicKey,
-		record.RegistrationRecord, record.CredentialIdentifier, oprfSeed, record.TestMaskNonce)
+	if len(oprfSeed) != s.conf.Hash.Size() {
+		return nil, ErrInvalidOPRFSeedLength
+	}
+
+	if err := s.verifyInitInput(serverPublicKey, record); err != nil {
+		return nil, err
+	}
+
+	if !completeKE1(ke1) {
+		return nil, errIncompleteMessage
+	}
+
+	z, err := s.oprfResponse(ke1.BlindedMessage, oprfSeed, record.CredentialIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loginInit(ke1, serverIdentity, sks, serverPublicKey, z, record, serverInfo)
+}
+
+// completeKE1 returns whether none of the fields of the KE1 message are missing.
+func completeKE1(ke1 *message.KE1) bool {
+	return ke1 != nil && ke1.CredentialRequest != nil && ke1.BlindedMessage != nil && ke1.EpkU != nil
+}
+
+func (s *Server) loginInit(
+	ke1 *message.KE1,
+	serverIdentity []byte,
+	sks *group.Scalar,
+	serverPublicKey []byte,
+	z *group.Point,
+	record *ClientRecord,
+	serverInfo []byte,
+) (*message.KE2, error) {
+	if err := checkIdentities(record.ClientIdentity, serverIdentity); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkReplay(ke1); err != nil {
+		return nil, err
+	}
+
+	var maskingNonce []byte
+	if s.kat != nil {
+		maskingNonce = s.kat.maskingNonce
+	}
+
+	response := s.credentialResponse(z, serverPublicKey, record.RegistrationRecord, maskingNonce)
 
 	clientIdentity := record.ClientIdentity
 
@@ -187,32 +433,62 @@ func (s *Server) LoginInit(
 		serverIdentity = serverPublicKey
 	}
 
-	ke2 := s.Ake.Response(s.conf, serverIdentity, sks, clientIdentity, record.PublicKey, ke1, response)
+	s.identities = [2][]byte{clientIdentity, serverIdentity}
+	s.kat.setAkeValues(s.conf.Group, s.Ake.SetValues)
 
-	return ke2, nil
+	return s.Ake.Response(s.conf, serverIdentity, sks, clientIdentity, record.PublicKey, ke1, response, serverInfo)
 }
 
 // LoginFinish returns an error if the KE3 received from the client holds an invalid mac, and nil if correct.
-func (s *Server) LoginFinish(ke3 *message.KE3) error {
+func (s *Server) LoginFinish(ke3 *message.KE3) (err error) {
+	defer func(start time.Time) {
+		s.logger.log(phaseLoginVerify, start, ke3, err)
+	}(time.Now())
+
+	if ke3 == nil {
+		return errIncompleteMessage
+	}
+
 	if !s.Ake.Finalize(s.conf, ke3) {
-		return ErrAkeInvalidClientMac
+		return internal.Detail(ErrAkeInvalidClientMac,
+			"client MAC of %d bytes, expected %d, authentication ciphertext of %d bytes, transcript hash %x",
+			len(ke3.Mac), s.conf.MAC.Size(), len(ke3.AuthCiphertext), s.Ake.TranscriptHash())
 	}
 
+	s.finished = true
+
 	return nil
 }
 
-// SessionKey returns the session key if the previous call to LoginInit() was successful.
+// SessionKey returns the session key if the previous call to LoginInit() was successful. With the KEMAKE protocol,
+// the session key is only final after a successful call to LoginFinish().
 func (s *Server) SessionKey() []byte {
 	return s.Ake.SessionKey()
 }
 
-// ExpectedMAC returns the expected client MAC if the previous call to LoginInit() was successful.
+// TranscriptHash returns the hash of the handshake transcript if the previous call to LoginInit() was successful. It
+// is the same as the client's, once the client has finished the login.
+func (s *Server) TranscriptHash() []byte {
+	return s.Ake.TranscriptHash()
+}
+
+// ExpectedMAC returns the expected client MAC if the previous call to LoginInit() was successful. With the KEMAKE
+// protocol, the client MAC covers KE3, and is only returned after a successful LoginFinish.
 func (s *Server) ExpectedMAC() []byte {
 	return s.Ake.ExpectedMAC()
 }
 
 // SetAKEState sets the internal state of the AKE server from the given bytes.
 func (s *Server) SetAKEState(state []byte) error {
+	if s.conf.Protocol == internal.KEMAKE {
+		macKey, transcript := s.conf.KDF.Size(), s.conf.Hash.Size()
+		if len(state) != macKey+transcript+s.conf.KDF.Size() {
+			return ErrInvalidState
+		}
+
+		return s.Ake.SetKEMState(state[:macKey], state[macKey:macKey+transcript], state[macKey+transcript:])
+	}
+
 	if len(state) != s.conf.MAC.Size()+s.conf.KDF.Size() {
 		return ErrInvalidState
 	}
@@ -220,6 +496,19 @@ func (s *Server) SetAKEState(state []byte) error {
 	return s.Ake.SetState(state[:s.conf.MAC.Size()], state[s.conf.MAC.Size():])
 }
 
+// SetAKEServerKey sets the server's private key, which LoginFinish needs in the KEM-based AKE when the state was
+// restored with SetAKEState instead of continuing from LoginInit on the same Server.
+func (s *Server) SetAKEServerKey(serverSecretKey []byte) error {
+	sks, err := s.decodeServerSecretKey(serverSecretKey)
+	if err != nil {
+		return err
+	}
+
+	s.Ake.SetServerSecretKey(sks)
+
+	return nil
+}
+
 // SerializeState returns the internal state of the AKE server serialized to bytes.
 func (s *Server) SerializeState() []byte {
 	return s.Ake.SerializeState()
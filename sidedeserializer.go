@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/message"
+)
+
+// ServerDeserializer parses the messages a Server consumes, and the keys and records it loads. The other messages, e.g.
+// KE2, are parsed with the Configuration's Deserializer.
+type ServerDeserializer struct {
+	d *Deserializer
+}
+
+// ClientDeserializer parses the messages a Client consumes, and the server public key. The other messages, e.g. KE1,
+// are parsed with the Configuration's Deserializer.
+type ClientDeserializer struct {
+	d *Deserializer
+}
+
+// SetMaxInfoLength sets the maximum length of the application messages accepted in KE1, as in
+// Deserializer.SetMaxInfoLength.
+func (s *ServerDeserializer) SetMaxInfoLength(length int) {
+	s.d.SetMaxInfoLength(length)
+}
+
+// MaxMessageLength returns the length of the longest message accepted, as in Deserializer.MaxMessageLength.
+func (s *ServerDeserializer) MaxMessageLength() int {
+	return s.d.MaxMessageLength()
+}
+
+// Check verifies the length and framing of a serialized message, as in Deserializer.Check.
+func (s *ServerDeserializer) Check(t MessageType, m []byte) error {
+	return s.d.Check(t, m)
+}
+
+// RegistrationRequest takes a serialized RegistrationRequest message and returns a deserialized
+// RegistrationRequest structure.
+func (s *ServerDeserializer) RegistrationRequest(registrationRequest []byte) (*message.RegistrationRequest, error) {
+	return s.d.RegistrationRequest(registrationRequest)
+}
+
+// RegistrationRecord takes a serialized RegistrationRecord message and returns a deserialized
+// RegistrationRecord structure.
+func (s *ServerDeserializer) RegistrationRecord(record []byte) (*message.RegistrationRecord, error) {
+	return s.d.RegistrationRecord(record)
+}
+
+// KE1 takes a serialized KE1 message and returns a deserialized KE1 structure.
+func (s *ServerDeserializer) KE1(ke1 []byte) (*message.KE1, error) {
+	return s.d.KE1(ke1)
+}
+
+// KE3 takes a serialized KE3 message and returns a deserialized KE3 structure.
+func (s *ServerDeserializer) KE3(ke3 []byte) (*message.KE3, error) {
+	return s.d.KE3(ke3)
+}
+
+// PartialEvaluation takes a serialized PartialEvaluation message and returns a deserialized PartialEvaluation
+// structure.
+func (s *ServerDeserializer) PartialEvaluation(partialEvaluation []byte) (*message.PartialEvaluation, error) {
+	return s.d.PartialEvaluation(partialEvaluation)
+}
+
+// OPRFKeyShare decodes a share produced by OPRFKeyShare.Serialize.
+func (s *ServerDeserializer) OPRFKeyShare(share []byte) (*OPRFKeyShare, error) {
+	return s.d.OPRFKeyShare(share)
+}
+
+// ClientRecordWithMetadata verifies and decodes a record serialized with its metadata, as in
+// Deserializer.ClientRecordWithMetadata.
+func (s *ServerDeserializer) ClientRecordWithMetadata(
+	metadataKey, encoded []byte,
+) (*ClientRecord, *RecordMetadata, error) {
+	return s.d.ClientRecordWithMetadata(metadataKey, encoded)
+}
+
+// LibOpaqueRecord decodes a client record stored by a libopaque server.
+func (s *ServerDeserializer) LibOpaqueRecord(record []byte) (*LibOpaqueRecord, error) {
+	return s.d.LibOpaqueRecord(record)
+}
+
+// DecodeAkePrivateKey takes a serialized private key (a scalar) and attempts to return it's decoded form, rejecting
+// the zero scalar.
+func (s *ServerDeserializer) DecodeAkePrivateKey(encoded []byte) (*group.Scalar, error) {
+	return s.d.DecodeAkePrivateKey(encoded)
+}
+
+// DecodeAkePublicKey takes a serialized public key (a point) and attempts to return it's decoded form, rejecting the
+// identity element.
+func (s *ServerDeserializer) DecodeAkePublicKey(encoded []byte) (*group.Point, error) {
+	return s.d.DecodeAkePublicKey(encoded)
+}
+
+// SetMaxInfoLength sets the maximum length of the application messages accepted in KE2, as in
+// Deserializer.SetMaxInfoLength.
+func (c *ClientDeserializer) SetMaxInfoLength(length int) {
+	c.d.SetMaxInfoLength(length)
+}
+
+// MaxMessageLength returns the length of the longest message accepted, as in Deserializer.MaxMessageLength.
+func (c *ClientDeserializer) MaxMessageLength() int {
+	return c.d.MaxMessageLength()
+}
+
+// Check verifies the length and framing of a serialized message, as in Deserializer.Check.
+func (c *ClientDeserializer) Check(t MessageType, m []byte) error {
+	return c.d.Check(t, m)
+}
+
+// RegistrationResponse takes a serialized RegistrationResponse message and returns a deserialized
+// RegistrationResponse structure.
+func (c *ClientDeserializer) RegistrationResponse(
+	registrationResponse []byte,
+) (*message.RegistrationResponse, error) {
+	return c.d.RegistrationResponse(registrationResponse)
+}
+
+// KE2 takes a serialized KE2 message and returns a deserialized KE2 structure.
+func (c *ClientDeserializer) KE2(ke2 []byte) (*message.KE2, error) {
+	return c.d.KE2(ke2)
+}
+
+// KE4 takes a serialized KE4 message and returns a deserialized KE4 structure.
+func (c *ClientDeserializer) KE4(ke4 []byte) (*message.KE4, error) {
+	return c.d.KE4(ke4)
+}
+
+// DecodeAkePublicKey takes a serialized public key (a point) and attempts to return it's decoded form, rejecting the
+// identity element.
+func (c *ClientDeserializer) DecodeAkePublicKey(encoded []byte) (*group.Point, error) {
+	return c.d.DecodeAkePublicKey(encoded)
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build go1.21
+
+package opaque
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/message"
+)
+
+// SetLogger sets the logger to which the client logs its lifecycle at the Debug level: its configuration, and then
+// each phase with the type and length of its message, its duration, and its error, if any. No secret or
+// secret-derived value is logged. A nil logger disables the logs.
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.logger = newEventLogger(logger, "client", c.conf, c.fingerprint)
+}
+
+// SetLogger sets the logger to which the server logs its lifecycle at the Debug level: its configuration, and then
+// each phase with the type and length of its message, its duration, and its error, if any. No secret or
+// secret-derived value is logged. A nil logger disables the logs.
+func (s *Server) SetLogger(logger *slog.Logger) {
+	s.logger = newEventLogger(logger, "server", s.conf, s.fingerprint)
+}
+
+// newEventLogger logs the configuration of the party to the logger, and returns the eventLogger logging the phases to
+// it, or nil if the logger is nil.
+func newEventLogger(
+	logger *slog.Logger,
+	party string,
+	conf *internal.Configuration,
+	fingerprint []byte,
+) eventLogger {
+	if logger == nil {
+		return nil
+	}
+
+	logger = logger.With(slog.String("party", party))
+	logger.LogAttrs(context.Background(), slog.LevelDebug, "opaque: configuration",
+		slog.String("fingerprint", configurationLabel(fingerprint)),
+		slog.Int("oprf", int(conf.OPRF)),
+		slog.Int("group", int(conf.Group)),
+		slog.Int("mode", int(conf.Mode)),
+		slog.Int("aead", int(conf.AEAD)),
+		slog.Int("kem", int(conf.KEM)),
+		slog.Int("protocol", int(conf.Protocol)),
+		slog.Int("compatibility", int(conf.Compatibility)),
+	)
+
+	return func(phase string, msg message.Serializer, duration time.Duration, err error) {
+		ctx := context.Background()
+		if !logger.Enabled(ctx, slog.LevelDebug) {
+			return
+		}
+
+		attrs := []slog.Attr{slog.Duration("duration", duration)}
+
+		if t, typeErr := messageType(msg); typeErr == nil {
+			attrs = append(attrs, slog.String("message", t.String()), slog.Int("size", len(msg.Serialize())))
+		}
+
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+
+		logger.LogAttrs(ctx, slog.LevelDebug, "opaque: "+phase, attrs...)
+	}
+}
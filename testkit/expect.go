@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package testkit
+
+import (
+	"crypto/subtle"
+	"errors"
+	"testing"
+)
+
+// ExpectSuccess fails the test if the run failed, or if a login didn't end with the same session key on both ends.
+func ExpectSuccess(t testing.TB, r *Result) {
+	t.Helper()
+
+	if r.Failed() {
+		t.Fatalf("unexpected failure at %s: %v", r.Step, r.Err)
+	}
+
+	if r.ClientSessionKey != nil && subtle.ConstantTimeCompare(r.ClientSessionKey, r.ServerSessionKey) != 1 {
+		t.Fatal("the session keys of the client and the server differ")
+	}
+}
+
+// ExpectFailure fails the test unless the run failed at the step, with an error matching target if it's not nil.
+// It also fails the test if the server accepted a login, which a failed run must never let it do.
+func ExpectFailure(t testing.TB, r *Result, step Step, target error) {
+	t.Helper()
+
+	if !r.Failed() {
+		t.Fatalf("expected a failure at %s, but the run succeeded", step)
+	}
+
+	if r.Step != step {
+		t.Fatalf("expected a failure at %s, got one at %s: %v", step, r.Step, r.Err)
+	}
+
+	if target != nil && !errors.Is(r.Err, target) {
+		t.Fatalf("expected %q at %s, got %q", target, step, r.Err)
+	}
+
+	if r.ServerSessionKey != nil {
+		t.Fatal("the server accepted the login")
+	}
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package testkit
+
+import (
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaqueprop"
+)
+
+// Fault alters a message sent on the network, and returns the messages put in transit in its place: none drops it,
+// and more deliver the others after it. Faults may keep state across messages and runs.
+type Fault func(m opaqueprop.Message) []opaqueprop.Message
+
+// Drop drops the messages of the type.
+func Drop(t opaque.MessageType) Fault {
+	return func(m opaqueprop.Message) []opaqueprop.Message {
+		if m.Type == t {
+			return nil
+		}
+
+		return []opaqueprop.Message{m}
+	}
+}
+
+// Tamper replaces the messages of the type with the output of f, which is given a copy of the serialized message.
+func Tamper(t opaque.MessageType, f func(encoded []byte) []byte) Fault {
+	return func(m opaqueprop.Message) []opaqueprop.Message {
+		if m.Type == t {
+			m.Encoded = f(append([]byte(nil), m.Encoded...))
+		}
+
+		return []opaqueprop.Message{m}
+	}
+}
+
+// FlipBit flips a bit of the messages of the type, counted from the first bit of the first byte, and modulo the
+// length of the message in bits, so that -1 is the last bit.
+func FlipBit(t opaque.MessageType, bit int) Fault {
+	return Tamper(t, func(encoded []byte) []byte {
+		if n := 8 * len(encoded); n != 0 {
+			i := (bit%n + n) % n
+			encoded[i/8] ^= 0x80 >> (i % 8)
+		}
+
+		return encoded
+	})
+}
+
+// Duplicate delivers the messages of the type twice, so that the copy is received in place of the next message.
+func Duplicate(t opaque.MessageType) Fault {
+	return func(m opaqueprop.Message) []opaqueprop.Message {
+		if m.Type == t {
+			return []opaqueprop.Message{m, m}
+		}
+
+		return []opaqueprop.Message{m}
+	}
+}
+
+// Delay holds the messages of the type, and delivers each after the next message sent in the same direction, which
+// is received in its place. In a single run, the receiver of a delayed message gets none.
+func Delay(t opaque.MessageType) Fault {
+	var held []opaqueprop.Message
+
+	return func(m opaqueprop.Message) []opaqueprop.Message {
+		if m.Type == t {
+			held = append(held, m)
+			return nil
+		}
+
+		if len(held) != 0 && toClientType(m.Type) == toClientType(t) {
+			out := append([]opaqueprop.Message{m}, held...)
+			held = nil
+
+			return out
+		}
+
+		return []opaqueprop.Message{m}
+	}
+}
+
+// Replay records the first message of the type, and delivers it in place of the following ones, as an attacker
+// replaying a message of an earlier run.
+func Replay(t opaque.MessageType) Fault {
+	var recorded *opaqueprop.Message
+
+	return func(m opaqueprop.Message) []opaqueprop.Message {
+		if m.Type != t {
+			return []opaqueprop.Message{m}
+		}
+
+		if recorded == nil {
+			recorded = &m
+		}
+
+		return []opaqueprop.Message{*recorded}
+	}
+}
@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package testkit provides an in-memory network connecting an OPAQUE Client and Server, with fault injection, so that
+// integrators can write negative tests of what happens when the messages are tampered with, dropped, duplicated,
+// delayed, or replayed:
+//
+//	n, err := testkit.NewNetwork(conf, &opaqueprop.Credentials{Password: password, CredentialIdentifier: id})
+//	testkit.ExpectSuccess(t, n.Register())
+//	n.Inject(testkit.FlipBit(opaque.KE2Message, 0))
+//	testkit.ExpectFailure(t, n.Login(password), testkit.ClientLoginFinish, nil)
+//
+// The messages are serialized in transit and deserialized by their receiver, as over a real network. The network
+// keeps a queue of the messages in transit towards each party, across runs, as a single connection would: a message
+// that is delayed or duplicated is received in place of a later one.
+package testkit
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaqueprop"
+)
+
+var (
+	// ErrDropped is returned when a party expects a message and none is in transit towards it.
+	ErrDropped = errors.New("no message received")
+
+	// ErrServerPublicKey is returned when the registration response holds another public key than the server's.
+	ErrServerPublicKey = errors.New("the registration response holds another server public key")
+
+	// ErrNoRecord is returned when logging in before a successful registration.
+	ErrNoRecord = errors.New("no registration record")
+)
+
+// Step identifies the processing of a received message, where a run can fail.
+type Step byte
+
+const (
+	// ServerRegistrationResponse is the server receiving the RegistrationRequest.
+	ServerRegistrationResponse Step = 1 + iota
+
+	// ClientRegistrationFinalize is the client receiving the RegistrationResponse.
+	ClientRegistrationFinalize
+
+	// ServerRegistrationRecord is the server receiving the RegistrationRecord.
+	ServerRegistrationRecord
+
+	// ServerLoginInit is the server receiving KE1.
+	ServerLoginInit
+
+	// ClientLoginFinish is the client receiving KE2.
+	ClientLoginFinish
+
+	// ServerLoginFinish is the server receiving KE3.
+	ServerLoginFinish
+)
+
+var stepNames = []string{
+	"no step", "ServerRegistrationResponse", "ClientRegistrationFinalize", "ServerRegistrationRecord",
+	"ServerLoginInit", "ClientLoginFinish", "ServerLoginFinish",
+}
+
+// String returns the name of the step.
+func (s Step) String() string {
+	if int(s) < len(stepNames) {
+		return stepNames[s]
+	}
+
+	return fmt.Sprintf("Step(%d)", byte(s))
+}
+
+// Result is the outcome of a registration or a login.
+type Result struct {
+	// Step is the step that failed, and Err its error, or 0 and nil if the run succeeded.
+	Step Step
+	Err  error
+
+	// ExportKey is the client's export key, if the client finished.
+	ExportKey []byte
+
+	// The session keys are set if the respective party accepted the login.
+	ClientSessionKey []byte
+	ServerSessionKey []byte
+}
+
+// Failed returns whether the run failed.
+func (r *Result) Failed() bool {
+	return r.Err != nil
+}
+
+func (r *Result) fail(step Step, err error) *Result {
+	r.Step, r.Err = step, err
+	return r
+}
+
+// Network connects a client and a server with the credentials in the configuration. Each registration and login
+// uses a new Client and Server.
+type Network struct {
+	Configuration *opaque.Configuration
+	Credentials   opaqueprop.Credentials
+
+	ServerSecretKey []byte
+	ServerPublicKey []byte
+	OPRFSeed        []byte
+
+	// Record is the record stored by the server after the last successful registration.
+	Record *opaque.ClientRecord
+
+	// Transcript holds the messages received, in order.
+	Transcript []opaqueprop.Message
+
+	faults []Fault
+
+	// toClient and toServer are the messages in transit.
+	toClient []opaqueprop.Message
+	toServer []opaqueprop.Message
+}
+
+// NewNetwork returns a Network for the credentials in the configuration, with a new server key pair and OPRF seed.
+func NewNetwork(c *opaque.Configuration, cr *opaqueprop.Credentials) (*Network, error) {
+	n := &Network{Configuration: c, Credentials: *cr}
+
+	var err error
+	if n.ServerSecretKey, n.ServerPublicKey, err = c.KeyGen(); err != nil {
+		return nil, err
+	}
+
+	if n.OPRFSeed, err = c.GenerateOPRFSeed(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Inject adds the faults, which apply in order to the messages sent from then on.
+func (n *Network) Inject(faults ...Fault) {
+	n.faults = append(n.faults, faults...)
+}
+
+// Reset removes the faults and the messages in transit, but keeps the record.
+func (n *Network) Reset() {
+	n.faults = nil
+	n.toClient = nil
+	n.toServer = nil
+}
+
+// toClientType returns whether messages of the type are sent to the client.
+func toClientType(t opaque.MessageType) bool {
+	return t == opaque.RegistrationResponseMessage || t == opaque.KE2Message
+}
+
+func (n *Network) send(t opaque.MessageType, encoded []byte) {
+	messages := []opaqueprop.Message{{Type: t, Encoded: encoded}}
+
+	for _, f := range n.faults {
+		var out []opaqueprop.Message
+		for _, m := range messages {
+			out = append(out, f(m)...)
+		}
+
+		messages = out
+	}
+
+	for _, m := range messages {
+		if toClientType(m.Type) {
+			n.toClient = append(n.toClient, m)
+		} else {
+			n.toServer = append(n.toServer, m)
+		}
+	}
+}
+
+// receive returns the next message in transit to the client or to the server. The receiver doesn't know the type of
+// what it receives, and deserializes it as the message it expects.
+func (n *Network) receive(client bool) ([]byte, error) {
+	queue := &n.toServer
+	if client {
+		queue = &n.toClient
+	}
+
+	if len(*queue) == 0 {
+		return nil, ErrDropped
+	}
+
+	m := (*queue)[0]
+	*queue = (*queue)[1:]
+	n.Transcript = append(n.Transcript, m)
+
+	return m.Encoded, nil
+}
+
+func (n *Network) parties() (*opaque.Client, *opaque.Server, error) {
+	client, err := n.Configuration.Client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server, err := n.Configuration.Server()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, server, nil
+}
+
+// Register runs a registration, and stores the record if it succeeds. The client rejects a response with another
+// public key than the server's, as a client knowing the server's key would.
+func (n *Network) Register() *Result {
+	r := new(Result)
+	cr := n.Credentials
+
+	client, server, err := n.parties()
+	if err != nil {
+		return r.fail(ServerRegistrationResponse, err)
+	}
+
+	n.send(opaque.RegistrationRequestMessage, client.RegistrationInit(cr.Password).Serialize())
+
+	encoded, err := n.receive(false)
+	if err != nil {
+		return r.fail(ServerRegistrationResponse, err)
+	}
+
+	request, err := server.Deserialize.RegistrationRequest(encoded)
+	if err != nil {
+		return r.fail(ServerRegistrationResponse, err)
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(n.ServerPublicKey)
+	if err != nil {
+		return r.fail(ServerRegistrationResponse, err)
+	}
+
+	response, err := server.RegistrationResponse(request, pks, cr.CredentialIdentifier, n.OPRFSeed)
+	if err != nil {
+		return r.fail(ServerRegistrationResponse, err)
+	}
+
+	n.send(opaque.RegistrationResponseMessage, response.Serialize())
+
+	if encoded, err = n.receive(true); err != nil {
+		return r.fail(ClientRegistrationFinalize, err)
+	}
+
+	if response, err = client.Deserialize.RegistrationResponse(encoded); err != nil {
+		return r.fail(ClientRegistrationFinalize, err)
+	}
+
+	if subtle.ConstantTimeCompare(response.Pks.Bytes(), pks.Bytes()) != 1 {
+		return r.fail(ClientRegistrationFinalize, ErrServerPublicKey)
+	}
+
+	record, exportKey, err := client.RegistrationFinalize(response, cr.ClientIdentity, cr.ServerIdentity)
+	if err != nil {
+		return r.fail(ClientRegistrationFinalize, err)
+	}
+
+	r.ExportKey = exportKey
+	n.send(opaque.RegistrationRecordMessage, record.Serialize())
+
+	if encoded, err = n.receive(false); err != nil {
+		return r.fail(ServerRegistrationRecord, err)
+	}
+
+	if record, err = server.Deserialize.RegistrationRecord(encoded); err != nil {
+		return r.fail(ServerRegistrationRecord, err)
+	}
+
+	n.Record = &opaque.ClientRecord{
+		CredentialIdentifier: cr.CredentialIdentifier,
+		ClientIdentity:       cr.ClientIdentity,
+		RegistrationRecord:   record,
+	}
+
+	return r
+}
+
+// Login runs a login with the password, against the stored record.
+func (n *Network) Login(password []byte) *Result {
+	r := new(Result)
+	cr := n.Credentials
+
+	client, server, err := n.parties()
+	if err != nil {
+		return r.fail(ServerLoginInit, err)
+	}
+
+	n.send(opaque.KE1Message, client.LoginInit(password).Serialize())
+
+	encoded, err := n.receive(false)
+	if err != nil {
+		return r.fail(ServerLoginInit, err)
+	}
+
+	if n.Record == nil {
+		return r.fail(ServerLoginInit, ErrNoRecord)
+	}
+
+	ke1, err := server.Deserialize.KE1(encoded)
+	if err != nil {
+		return r.fail(ServerLoginInit, err)
+	}
+
+	ke2, err := server.LoginInit(ke1, cr.ServerIdentity, n.ServerSecretKey, n.ServerPublicKey, n.OPRFSeed, n.Record)
+	if err != nil {
+		return r.fail(ServerLoginInit, err)
+	}
+
+	n.send(opaque.KE2Message, ke2.Serialize())
+
+	if encoded, err = n.receive(true); err != nil {
+		return r.fail(ClientLoginFinish, err)
+	}
+
+	if ke2, err = client.Deserialize.KE2(encoded); err != nil {
+		return r.fail(ClientLoginFinish, err)
+	}
+
+	ke3, exportKey, err := client.LoginFinish(cr.ClientIdentity, cr.ServerIdentity, ke2)
+	if err != nil {
+		return r.fail(ClientLoginFinish, err)
+	}
+
+	r.ExportKey = exportKey
+	r.ClientSessionKey = client.SessionKey()
+	n.send(opaque.KE3Message, ke3.Serialize())
+
+	if encoded, err = n.receive(false); err != nil {
+		return r.fail(ServerLoginFinish, err)
+	}
+
+	if ke3, err = server.Deserialize.KE3(encoded); err != nil {
+		return r.fail(ServerLoginFinish, err)
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		return r.fail(ServerLoginFinish, err)
+	}
+
+	r.ServerSessionKey = server.SessionKey()
+
+	return r
+}
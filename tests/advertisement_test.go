@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/crypto/ksf"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestConfigurationAdvertisement(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.Context = []byte("context")
+	advertisement := conf.Advertisement()
+
+	decoded, err := opaque.DeserializeConfigurationAdvertisement(advertisement.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted, err := new(opaque.ConfigurationPolicy).Accept(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(accepted.Serialize(), conf.Serialize()) {
+		t.Fatal("the accepted configuration is not the advertised one")
+	}
+
+	for _, bad := range [][]byte{nil, advertisement.Serialize()[1:], append(advertisement.Serialize(), 0)} {
+		if _, err = opaque.DeserializeConfigurationAdvertisement(bad); !errors.Is(err, opaque.ErrConfigurationAdvertisement) {
+			t.Fatalf("expected an advertisement error, got %v", err)
+		}
+	}
+
+	// A configuration that doesn't match its fingerprint.
+	tampered := conf.Advertisement()
+	tampered.Configuration[1] = byte(crypto.SHA256)
+
+	if _, err = new(opaque.ConfigurationPolicy).Accept(tampered); !errors.Is(err, opaque.ErrConfigurationAdvertisement) {
+		t.Fatalf("expected an advertisement error on a tampered configuration, got %v", err)
+	}
+
+	if _, err = new(opaque.ConfigurationPolicy).Accept(nil); !errors.Is(err, opaque.ErrConfigurationAdvertisement) {
+		t.Fatalf("expected an advertisement error on a nil advertisement, got %v", err)
+	}
+}
+
+func TestConfigurationPolicy(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.Context = []byte("context")
+	advertisement := conf.Advertisement()
+
+	accept := []*opaque.ConfigurationPolicy{
+		{Fingerprints: [][]byte{make([]byte, 32), conf.Fingerprint()}},
+		{
+			Groups:    []opaque.Group{opaque.P256Sha256, opaque.RistrettoSha512},
+			Hashes:    []crypto.Hash{crypto.SHA512},
+			KSFs:      []ksf.Identifier{ksf.Scrypt, ksf.Argon2id},
+			Protocols: []opaque.Protocol{opaque.TripleDH},
+			Context:   []byte("context"),
+		},
+	}
+
+	for i, policy := range accept {
+		if _, err := policy.Accept(advertisement); err != nil {
+			t.Fatalf("policy %d: %v", i, err)
+		}
+	}
+
+	reject := []*opaque.ConfigurationPolicy{
+		{Fingerprints: [][]byte{make([]byte, 32)}},
+		{Groups: []opaque.Group{opaque.P256Sha256}},
+		{Hashes: []crypto.Hash{crypto.SHA256}},
+		{KSFs: []ksf.Identifier{ksf.Argon2id}},
+		{Protocols: []opaque.Protocol{opaque.HMQV}},
+		{Context: []byte("other")},
+		{Context: []byte{}},
+		{RequireKEM: true},
+	}
+
+	for i, policy := range reject {
+		if _, err := policy.Accept(advertisement); !errors.Is(err, opaque.ErrConfigurationPolicy) {
+			t.Fatalf("policy %d: expected a policy error, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/vectors"
+)
+
+var envelopeAEADs = []opaque.EnvelopeAEAD{opaque.AES256GCM, opaque.ChaCha20Poly1305}
+
+func TestEnvelopeAEAD(t *testing.T) {
+	password := []byte("password")
+	appData := []byte("kdf=argon2id;flags=2fa")
+
+	for _, c := range confs {
+		for _, aead := range envelopeAEADs {
+			for _, mode := range []opaque.EnvelopeMode{opaque.Internal, opaque.External} {
+				for _, appDataLength := range []uint16{0, 32} {
+					conf := *c.Conf
+					conf.AEAD = aead
+					conf.Mode = mode
+					conf.AppDataLength = appDataLength
+
+					var data []byte
+					if appDataLength != 0 {
+						data = appData
+					}
+
+					client, _ := conf.Client()
+					server, _ := conf.Server()
+					sks, pks, _ := conf.KeyGen()
+					oprfSeed, _ := conf.GenerateOPRFSeed()
+
+					pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+					r2, _ := server.RegistrationResponse(client.RegistrationInit(password), pk, nil, oprfSeed)
+
+					record, exportKey, err := client.RegistrationFinalizeWithOptions(r2, []byte("client"), nil,
+						&opaque.RegistrationOptions{AppData: data})
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					// The 16-byte AEAD tag replaces the MAC.
+					expected := 32 + 16
+					if mode == opaque.External {
+						expected += len(sks)
+					}
+
+					if appDataLength != 0 {
+						expected += 2 + int(appDataLength)
+					}
+
+					if length, _ := conf.EnvelopeLength(); len(record.Envelope) != expected || length != expected {
+						t.Fatalf("unexpected envelope length %d, expected %d", len(record.Envelope), expected)
+					}
+
+					clientRecord := &opaque.ClientRecord{ClientIdentity: []byte("client"), RegistrationRecord: record}
+
+					client, _ = conf.Client()
+					ke2, err := server.LoginInit(client.LoginInit(password), nil, sks, pks, oprfSeed, clientRecord)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					ke3, loginExportKey, err := client.LoginFinish([]byte("client"), nil, ke2)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if err = server.LoginFinish(ke3); err != nil {
+						t.Fatal(err)
+					}
+
+					if !bytes.Equal(exportKey, loginExportKey) || !bytes.Equal(client.AppData(), data) {
+						t.Fatal("unexpected export key or application data")
+					}
+
+					// A wrong password or identity doesn't open the envelope.
+					for _, login := range []struct{ password, identity []byte }{
+						{[]byte("wrong"), []byte("client")},
+						{password, []byte("other")},
+					} {
+						client, _ = conf.Client()
+						ke2, _ = server.LoginInit(client.LoginInit(login.password), nil, sks, pks, oprfSeed,
+							clientRecord)
+
+						if _, _, err = client.LoginFinish(login.identity, nil, ke2); err == nil {
+							t.Fatal("expected error")
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEnvelopeAEAD_Configuration(t *testing.T) {
+	for _, aead := range envelopeAEADs {
+		conf := opaque.DefaultConfiguration()
+		conf.AEAD = aead
+
+		decoded, err := opaque.DeserializeConfiguration(conf.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.AEAD != aead || decoded.Compatibility != opaque.Draft {
+			t.Fatalf("unexpected AEAD %d after serialization", decoded.AEAD)
+		}
+
+		// The compatibility modes don't support the AEAD envelopes.
+		conf.Compatibility = opaque.OpaqueKE
+		if _, err = conf.Deserializer(); err == nil {
+			t.Fatal("expected error in the compatibility mode")
+		}
+	}
+
+	// The encodings without AEAD remain the same.
+	if encoded := opaque.DefaultConfiguration().Serialize(); len(encoded) != 6+2 {
+		t.Fatalf("unexpected encoding length %d", len(encoded))
+	}
+
+	conf := opaque.DefaultConfiguration()
+	conf.AEAD = opaque.ChaCha20Poly1305 + 1
+
+	if _, err := conf.Deserializer(); err == nil {
+		t.Fatal("expected error on invalid AEAD")
+	}
+
+	encoded := opaque.DefaultConfiguration().Serialize()
+	if _, err := opaque.DeserializeConfiguration(append(encoded, byte(opaque.Draft), byte(opaque.NoAEAD))); err == nil {
+		t.Fatal("expected error on explicit encoding of the defaults")
+	}
+}
+
+func TestEnvelopeAEAD_Vectors(t *testing.T) {
+	v, err := vectors.LoadFile("aeadVectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tv := range v {
+		if tv.Config.AEAD == "" {
+			t.Fatalf("%s: vector without AEAD", tv.Name())
+		}
+
+		if err := tv.Run(); err != nil {
+			t.Fatalf("%s: %v", tv.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestAsyncClient(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		credID := []byte("client")
+		password := []byte("password")
+		serverID := []byte("server")
+		oprfSeed, _ := conf.GenerateOPRFSeed()
+		serverSecretKey, serverPublicKey, _ := conf.KeyGen()
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+		pks, _ := server.Deserialize.DecodeAkePublicKey(serverPublicKey)
+
+		resp, _ := server.RegistrationResponse(client.RegistrationInit(password), pks, credID, oprfSeed)
+		registration := <-client.RegistrationFinalizeAsync(resp, credID, serverID)
+
+		record := &opaque.ClientRecord{
+			CredentialIdentifier: credID,
+			ClientIdentity:       credID,
+			RegistrationRecord:   registration.Record,
+		}
+
+		client, _ = conf.Client()
+		server, _ = conf.Server()
+
+		ke2, err := server.LoginInit(client.LoginInit(password), serverID, serverSecretKey, serverPublicKey, oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		login := <-client.LoginFinishAsync(credID, serverID, ke2)
+		if login.Err != nil {
+			t.Fatal(login.Err)
+		}
+
+		if !bytes.Equal(login.ExportKey, registration.ExportKey) {
+			t.Fatal("expected the same export key at registration and login")
+		}
+
+		if err := server.LoginFinish(login.KE3); err != nil {
+			t.Fatal(err)
+		}
+
+		// A failed login reports its error.
+		client, _ = conf.Client()
+		server, _ = conf.Server()
+
+		ke2, err = server.LoginInit(client.LoginInit([]byte("wrong")), serverID, serverSecretKey, serverPublicKey,
+			oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if login := <-client.LoginFinishAsync(credID, serverID, ke2); login.Err == nil || login.KE3 != nil {
+			t.Fatal("expected error on wrong password")
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+)
+
+func TestBaseTable(t *testing.T) {
+	for _, c := range confs {
+		g := group.Group(c.Conf.AKE)
+		table := internal.GetBaseTable(g)
+
+		if internal.GetBaseTable(g) != table {
+			t.Fatal("expected the table to be computed once")
+		}
+
+		one := g.NewScalar().Random()
+		one = one.Mult(one.Copy().Invert())
+		minusOne := g.NewScalar().Random()
+		minusOne = minusOne.Sub(minusOne).Sub(one)
+
+		for _, s := range []*group.Scalar{one, minusOne, g.NewScalar().Random(), g.NewScalar().Random()} {
+			if !bytes.Equal(table.Mult(s).Bytes(), g.Base().Mult(s).Bytes()) {
+				t.Fatalf("%s: unexpected fixed-base multiplication", g)
+			}
+		}
+	}
+}
+
+func TestFixedBaseTables(t *testing.T) {
+	for _, c := range confs {
+		// A server with the tables interoperates with a client without.
+		conf := *c.Conf
+		conf.FixedBaseTables = true
+
+		test, record := registeredTestParams(t, c.Conf)
+
+		client, _ := c.Conf.Client()
+		server, _ := conf.Server()
+
+		ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+			test.serverPublicKey, test.oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(conf.Serialize(), c.Conf.Serialize()) {
+			t.Fatal("the tables must not change the serialization")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/crypto/group"
+)
+
+func TestEvaluateBatch(t *testing.T) {
+	ids := [][]byte{[]byte("alice"), []byte("bob"), []byte("alice"), []byte("carol")}
+
+	for _, c := range confs {
+		conf := c.Conf
+		oprfSeed, _ := conf.GenerateOPRFSeed()
+		_, pks, _ := conf.KeyGen()
+
+		for _, info := range [][]byte{nil, []byte("info")} {
+			elements := make([]*group.Point, len(ids))
+			expected := make([]*group.Point, len(ids))
+
+			for i, id := range ids {
+				client, _ := conf.Client()
+				server, _ := conf.Server()
+
+				if info != nil {
+					client.SetOPRFInfo(info)
+					server.SetOPRFInfo(info)
+				}
+
+				req := client.RegistrationInit([]byte("password"))
+				elements[i] = req.BlindedMessage
+				pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+				resp, _ := server.RegistrationResponse(req, pk, id, oprfSeed)
+				expected[i] = resp.EvaluatedMessage
+			}
+
+			server, _ := conf.Server()
+			if info != nil {
+				server.SetOPRFInfo(info)
+			}
+
+			evaluated, err := server.EvaluateBatch(oprfSeed, ids, elements)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range ids {
+				if !bytes.Equal(evaluated[i].Bytes(), expected[i].Bytes()) {
+					t.Fatalf("batch evaluation %d differs from the single evaluation", i)
+				}
+			}
+
+			if _, err := server.EvaluateBatch(oprfSeed, ids[1:], elements); err == nil {
+				t.Fatal("expected error on length mismatch")
+			}
+
+			if _, err := server.EvaluateBatch(oprfSeed[1:], ids, elements); err == nil {
+				t.Fatal("expected error on invalid seed length")
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+	"github.com/bytemare/opaque/opaqueprop"
+)
+
+type boundMessage interface {
+	message.Serializer
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	Bind(d message.Decoder)
+}
+
+func newBoundMessage(t opaque.MessageType) boundMessage {
+	switch t {
+	case opaque.RegistrationRequestMessage:
+		return &message.RegistrationRequest{}
+	case opaque.RegistrationResponseMessage:
+		return &message.RegistrationResponse{}
+	case opaque.RegistrationRecordMessage:
+		return &message.RegistrationRecord{}
+	case opaque.KE1Message:
+		return &message.KE1{}
+	case opaque.KE2Message:
+		return &message.KE2{}
+	case opaque.KE3Message:
+		return &message.KE3{}
+	default:
+		return nil
+	}
+}
+
+func TestBinaryMarshaling(t *testing.T) {
+	for _, c := range confs {
+		reg, err := opaqueprop.Register(c.Conf, &opaqueprop.Credentials{
+			Password:             []byte("password"),
+			CredentialIdentifier: []byte("client"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := reg.Login(reg.Credentials.Password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := c.Conf.Deserializer()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range h.Sequence {
+			unbound := newBoundMessage(m.Type)
+			if err = unbound.UnmarshalBinary(m.Encoded); err == nil {
+				t.Fatalf("%v: expected error on unbound message", m.Type)
+			}
+
+			source := newBoundMessage(m.Type)
+			source.Bind(d)
+
+			if err = source.UnmarshalBinary(m.Encoded); err != nil {
+				t.Fatalf("%v: %v", m.Type, err)
+			}
+
+			var b bytes.Buffer
+			if err = gob.NewEncoder(&b).Encode(source); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded := newBoundMessage(m.Type)
+			decoded.Bind(d)
+
+			if err = gob.NewDecoder(&b).Decode(decoded); err != nil {
+				t.Fatalf("%v: %v", m.Type, err)
+			}
+
+			encoded, err := decoded.MarshalBinary()
+			if err != nil || !bytes.Equal(encoded, m.Encoded) {
+				t.Fatalf("%v: unexpected round trip encoding, %v", m.Type, err)
+			}
+
+			if !bytes.Equal(decoded.Serialize(), encoded) {
+				t.Fatalf("%v: Serialize and MarshalBinary differ", m.Type)
+			}
+
+			// The binding survives decoding, so the message can be reused.
+			if err = decoded.UnmarshalBinary(m.Encoded[:len(m.Encoded)-1]); err == nil {
+				t.Fatalf("%v: expected error on truncated message", m.Type)
+			}
+		}
+	}
+}
+
+func TestSerializeWithoutCredentials(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	client, _ := conf.Client()
+	ke1 := client.LoginInit([]byte("password"))
+	full := ke1.Serialize()
+	request := ke1.CredentialRequest.Serialize()
+
+	ke1.CredentialRequest = nil
+	if !bytes.Equal(ke1.Serialize(), full[len(request):]) {
+		t.Fatal("unexpected encoding of KE1 without the credential request")
+	}
+
+	var response *message.CredentialResponse
+	if response.Serialize() != nil {
+		t.Fatal("expected empty encoding of a nil credential response")
+	}
+}
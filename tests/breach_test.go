@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+// pwnedRange returns a Range function answering with the passwords and their counts, and padding entries.
+func pwnedRange(t *testing.T, passwords map[string]int) func(prefix string) ([]byte, error) {
+	return func(prefix string) ([]byte, error) {
+		if len(prefix) != 5 {
+			t.Fatalf("unexpected prefix %q", prefix)
+		}
+
+		response := "0000000000000000000000000000000000A:0\r\n"
+
+		for password, count := range passwords {
+			digest := sha1.Sum([]byte(password))
+			hash := strings.ToUpper(hex.EncodeToString(digest[:]))
+
+			if hash[:5] == prefix {
+				response += fmt.Sprintf("%s:%d\r\n", strings.ToLower(hash[5:]), count)
+			}
+		}
+
+		return []byte(response), nil
+	}
+}
+
+func TestBreachChecker(t *testing.T) {
+	checker := &opaque.PwnedPasswords{
+		Range: pwnedRange(t, map[string]int{"password": 1000, "hunter2": 1, "padding": 0}),
+	}
+
+	for password, breached := range map[string]bool{"password": true, "hunter2": true, "padding": false, "fresh": false} {
+		client, _ := opaque.DefaultConfiguration().Client()
+		client.SetBreachChecker(checker)
+
+		request, err := client.RegistrationStart([]byte(password))
+
+		switch {
+		case breached && (!errors.Is(err, opaque.ErrBreachedPassword) || request != nil):
+			t.Fatalf("expected a breached password error for %q, got %v", password, err)
+		case !breached && (err != nil || request == nil):
+			t.Fatalf("unexpected error for %q: %v", password, err)
+		}
+	}
+
+	// The threshold.
+	checker.Threshold = 10
+
+	if breached, err := checker.Breached([]byte("hunter2")); err != nil || breached {
+		t.Fatalf("unexpected breach below the threshold: %v", err)
+	}
+
+	if breached, err := checker.Breached([]byte("password")); err != nil || !breached {
+		t.Fatalf("expected a breach above the threshold: %v", err)
+	}
+
+	// The errors of the checker.
+	client, _ := opaque.DefaultConfiguration().Client()
+	errRange := errors.New("unavailable")
+	client.SetBreachChecker(&opaque.PwnedPasswords{Range: func(string) ([]byte, error) { return nil, errRange }})
+
+	if _, err := client.RegistrationStart([]byte("password")); !errors.Is(err, errRange) {
+		t.Fatalf("expected the range error, got %v", err)
+	}
+
+	for _, bad := range []string{"no colon\r\n", "ABCDEF:many\r\n"} {
+		checker := &opaque.PwnedPasswords{Range: func(string) ([]byte, error) { return []byte(bad), nil }}
+		if _, err := checker.Breached([]byte("password")); err == nil {
+			t.Fatalf("expected an error on the response %q", bad)
+		}
+	}
+
+	// Without a checker.
+	client.SetBreachChecker(nil)
+
+	if _, err := client.RegistrationStart([]byte("password")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/bytemare/crypto/ksf"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaqueprop"
+)
+
+func TestConfigurationBuilder(t *testing.T) {
+	conf, warnings, err := opaque.NewConfigurationBuilder().Build()
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("unexpected result for the default configuration: %v, %v", warnings, err)
+	}
+
+	if !bytes.Equal(conf.Serialize(), opaque.DefaultConfiguration().Serialize()) {
+		t.Fatal("expected the default configuration")
+	}
+
+	b := opaque.NewConfigurationBuilder().
+		WithOPRF(opaque.P256Sha256).
+		WithAKE(opaque.P256Sha256).
+		WithHashes(crypto.SHA256).
+		WithKSF(ksf.Scrypt, 1024, 8, 1).
+		WithContext([]byte("context"))
+
+	conf, warnings, err = b.Build()
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("unexpected result: %v, %v", warnings, err)
+	}
+
+	if conf.OPRF != opaque.P256Sha256 || conf.Hash != crypto.SHA256 || len(conf.KSFParameters) != 3 {
+		t.Fatal("unexpected configuration")
+	}
+
+	// The built configurations are independent of the builder.
+	other, _, _ := b.WithContext(nil).Build()
+	if other.Context != nil || !bytes.Equal(conf.Context, []byte("context")) {
+		t.Fatal("the configurations share their context")
+	}
+
+	// The KSF parameters are used by the client, and must match at login.
+	reg, err := opaqueprop.Register(conf, &opaqueprop.Credentials{
+		Password:             []byte("password"),
+		CredentialIdentifier: []byte("client"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = reg.Login(reg.Credentials.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	_, warnings, err = opaque.NewConfigurationBuilder().
+		WithAKE(opaque.P521Sha512).
+		WithKDF(crypto.SHA256).
+		WithKSF(0).
+		Build()
+	if err != nil || len(warnings) != 4 {
+		t.Fatalf("expected 4 warnings, got %v, %v", warnings, err)
+	}
+
+	for name, b := range map[string]*opaque.ConfigurationBuilder{
+		"invalid OPRF":                 opaque.NewConfigurationBuilder().WithOPRF(0),
+		"invalid KSF parameters count": opaque.NewConfigurationBuilder().WithKSFParams(1),
+		"negative KSF parameters":      opaque.NewConfigurationBuilder().WithKSFParams(-1, 8, 1),
+		"identity KSF parameters":      opaque.NewConfigurationBuilder().WithKSF(0, 1),
+		"compatibility KSF parameters": opaque.NewConfigurationBuilder().
+			WithCompatibility(opaque.OpaqueKE).
+			WithKSF(ksf.Argon2id, 1, 1024, 1),
+	} {
+		if _, _, err = b.Build(); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
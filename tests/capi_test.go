@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCAPI builds the shared library of the C interface, and runs a C program registering and logging in with it.
+func TestCAPI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the C interface build in short mode")
+	}
+
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "libopaquego.so")
+
+	build := exec.Command("go", "build", "-buildmode=c-shared", "-o", lib, "../capi")
+	build.Env = append(os.Environ(), "CGO_ENABLED=1")
+
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building the shared library: %v\n%s", err, out)
+	}
+
+	bin := filepath.Join(dir, "capi")
+
+	compile := exec.Command(cc, "-o", bin, "testdata/capi/main.c", "-I", dir, "-L", dir, "-lopaquego",
+		"-Wl,-rpath,"+dir)
+	if out, err := compile.CombinedOutput(); err != nil {
+		t.Fatalf("compiling the C program: %v\n%s", err, out)
+	}
+
+	out, err := exec.Command(bin).CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "ok" {
+		t.Fatalf("running the C program: %v\n%s", err, out)
+	}
+}
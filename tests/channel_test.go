@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/bytemare/opaque/channel"
+)
+
+type transport struct {
+	io.Reader
+	io.Writer
+}
+
+func TestChannel(t *testing.T) {
+	sessionKey := randomBytes(64)
+	c2s, s2c := new(bytes.Buffer), new(bytes.Buffer)
+
+	client, err := channel.New(transport{Reader: s2c, Writer: c2s}, sessionKey, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, _ := channel.New(transport{Reader: c2s, Writer: s2c}, sessionKey, false)
+	client.RekeyInterval = 2
+
+	// Messages span several records and rekeys.
+	message := randomBytes(5*channel.MaxRecordSize + 10)
+	if _, err := client.Write(message); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make([]byte, len(message))
+	if _, err := io.ReadFull(server, received); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(message, received) {
+		t.Fatal("messages differ")
+	}
+
+	// The other direction uses other keys.
+	if _, err := server.Write([]byte("reply")); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(s2c.Bytes(), []byte("reply")) {
+		t.Fatal("data is not encrypted")
+	}
+
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(client, reply); err != nil || string(reply) != "reply" {
+		t.Fatalf("unexpected reply %q: %v", reply, err)
+	}
+
+	// Replayed and tampered records are rejected.
+	_, _ = server.Write([]byte("hello"))
+	replay := append([]byte(nil), s2c.Bytes()...)
+
+	if _, err := client.Read(reply); err != nil {
+		t.Fatal(err)
+	}
+
+	s2c.Write(replay)
+
+	if _, err := client.Read(reply); !errors.Is(err, channel.ErrAuthentication) {
+		t.Fatalf("expected %q on replay, got %q", channel.ErrAuthentication, err)
+	}
+
+	client, _ = channel.New(transport{Reader: s2c, Writer: c2s}, sessionKey, true)
+	server, _ = channel.New(transport{Reader: c2s, Writer: s2c}, sessionKey, false)
+	_, _ = server.Write([]byte("hello"))
+	record := s2c.Bytes()
+	record[len(record)-1] ^= 1
+
+	if _, err := client.Read(reply); !errors.Is(err, channel.ErrAuthentication) {
+		t.Fatalf("expected %q, got %q", channel.ErrAuthentication, err)
+	}
+
+	// Channels with different session keys can't talk.
+	other, _ := channel.New(transport{Reader: c2s, Writer: s2c}, randomBytes(64), false)
+	_, _ = client.Write([]byte("hello"))
+
+	if _, err := other.Read(reply); !errors.Is(err, channel.ErrAuthentication) {
+		t.Fatalf("expected %q, got %q", channel.ErrAuthentication, err)
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestChannelBinding(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	login := func(clientBinding, serverBinding []byte) error {
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		if err := client.SetChannelBinding(clientBinding); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := server.SetChannelBinding(serverBinding); err != nil {
+			t.Fatal(err)
+		}
+
+		ke1 := client.LoginInit(test.password)
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			return err
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			return err
+		}
+
+		if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+			t.Fatal("session keys differ")
+		}
+
+		return nil
+	}
+
+	if err := login([]byte("channel"), []byte("channel")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A login forwarded through another channel fails on both ends.
+	if err := login([]byte("channel"), []byte("other channel")); err == nil {
+		t.Fatal("expected error with different channel bindings")
+	}
+
+	if err := login(nil, []byte("channel")); err == nil {
+		t.Fatal("expected error with a missing client channel binding")
+	}
+
+	// A binding that doesn't fit the transcript encoding is rejected before the login starts.
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	long := make([]byte, 1<<16)
+
+	if err := client.SetChannelBinding(long); err == nil || err.Error() != "channel binding is too long" {
+		t.Fatalf("expected error on too long client channel binding, got %v", err)
+	}
+
+	if err := server.SetChannelBinding(long); err == nil || err.Error() != "channel binding is too long" {
+		t.Fatalf("expected error on too long server channel binding, got %v", err)
+	}
+
+	if err := client.SetChannelBinding(long[:1<<16-1]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -9,11 +9,11 @@
 package opaque_test
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
 	"github.com/bytemare/opaque"
-	"github.com/bytemare/opaque/internal"
 	"github.com/bytemare/opaque/internal/encoding"
 	"github.com/bytemare/opaque/internal/tag"
 )
@@ -26,23 +26,23 @@ func TestClientRegistrationFinalize_InvalidPks(t *testing.T) {
 	/*
 		Invalid data sent to the client
 	*/
-	credID := internal.RandomBytes(32)
+	credID := randomBytes(32)
 
 	for _, conf := range confs {
 		client, _ := conf.Conf.Client()
 		server, _ := conf.Conf.Server()
-		_, pks := conf.Conf.KeyGen()
-		oprfSeed := internal.RandomBytes(conf.Conf.Hash.Size())
+		_, pks, _ := conf.Conf.KeyGen()
+		oprfSeed := randomBytes(conf.Conf.Hash.Size())
 		r1 := client.RegistrationInit([]byte("yo"))
 
 		pk, err := server.GetConf().Group.NewElement().Decode(pks)
 		if err != nil {
 			panic(err)
 		}
-		r2 := server.RegistrationResponse(r1, pk, credID, oprfSeed)
+		r2, _ := server.RegistrationResponse(r1, pk, credID, oprfSeed)
 
 		// message length
-		badr2 := internal.RandomBytes(15)
+		badr2 := randomBytes(15)
 		expected := "invalid message length"
 		if _, err := client.Deserialize.RegistrationResponse(badr2); err == nil ||
 			!strings.HasPrefix(err.Error(), expected) {
@@ -76,13 +76,13 @@ func TestClientFinish_BadEvaluation(t *testing.T) {
 		_ = client.LoginInit([]byte("yo"))
 		r2 := encoding.Concat(
 			getBadElement(t, conf),
-			internal.RandomBytes(
+			randomBytes(
 				client.GetConf().NonceLen+client.GetConf().AkePointLength+client.GetConf().EnvelopeSize,
 			),
 		)
 		badKe2 := encoding.Concat(
 			r2,
-			internal.RandomBytes(client.GetConf().NonceLen+client.GetConf().AkePointLength+client.GetConf().MAC.Size()),
+			randomBytes(client.GetConf().NonceLen+client.GetConf().AkePointLength+client.GetConf().MAC.Size()),
 		)
 
 		expected := "invalid OPRF evaluation"
@@ -96,13 +96,13 @@ func TestClientFinish_BadMaskedResponse(t *testing.T) {
 	/*
 		The masked response is of invalid length.
 	*/
-	credID := internal.RandomBytes(32)
+	credID := randomBytes(32)
 
 	for _, conf := range confs {
 		client, _ := conf.Conf.Client()
 		server, _ := conf.Conf.Server()
-		sks, pks := conf.Conf.KeyGen()
-		oprfSeed := internal.RandomBytes(conf.Conf.Hash.Size())
+		sks, pks, _ := conf.Conf.KeyGen()
+		oprfSeed := randomBytes(conf.Conf.Hash.Size())
 		rec := buildRecord(credID, oprfSeed, []byte("yo"), pks, client, server)
 
 		ke1 := client.LoginInit([]byte("yo"))
@@ -112,13 +112,13 @@ func TestClientFinish_BadMaskedResponse(t *testing.T) {
 		expected := "invalid masked response length"
 
 		// too short
-		ke2.MaskedResponse = internal.RandomBytes(goodLength - 1)
+		ke2.MaskedResponse = randomBytes(goodLength - 1)
 		if _, _, err := client.LoginFinish(nil, nil, ke2); err == nil || !strings.HasPrefix(err.Error(), expected) {
 			t.Fatalf("expected error for short response - got %v", err)
 		}
 
 		// too long
-		ke2.MaskedResponse = internal.RandomBytes(goodLength + 1)
+		ke2.MaskedResponse = randomBytes(goodLength + 1)
 		if _, _, err := client.LoginFinish(nil, nil, ke2); err == nil || !strings.HasPrefix(err.Error(), expected) {
 			t.Fatalf("expected error for long response - got %v", err)
 		}
@@ -129,13 +129,13 @@ func TestClientFinish_InvalidEnvelopeTag(t *testing.T) {
 	/*
 		Invalid envelope tag
 	*/
-	credID := internal.RandomBytes(32)
+	credID := randomBytes(32)
 
 	for _, conf := range confs {
 		client, _ := conf.Conf.Client()
 		server, _ := conf.Conf.Server()
-		sks, pks := conf.Conf.KeyGen()
-		oprfSeed := internal.RandomBytes(conf.Conf.Hash.Size())
+		sks, pks, _ := conf.Conf.KeyGen()
+		oprfSeed := randomBytes(conf.Conf.Hash.Size())
 		rec := buildRecord(credID, oprfSeed, []byte("yo"), pks, client, server)
 
 		ke1 := client.LoginInit([]byte("yo"))
@@ -147,7 +147,7 @@ func TestClientFinish_InvalidEnvelopeTag(t *testing.T) {
 		}
 
 		// tamper the envelope
-		env.AuthTag = internal.RandomBytes(client.GetConf().MAC.Size())
+		env.AuthTag = randomBytes(client.GetConf().MAC.Size())
 		clear := encoding.Concat(pks, env.Serialize())
 		ke2.MaskedResponse = xorResponse(server.GetConf(), rec.MaskingKey, ke2.MaskingNonce, clear)
 
@@ -175,13 +175,13 @@ func TestClientFinish_InvalidKE2KeyEncoding(t *testing.T) {
 	/*
 		Tamper KE2 values
 	*/
-	credID := internal.RandomBytes(32)
+	credID := randomBytes(32)
 
 	for _, conf := range confs {
 		client, _ := conf.Conf.Client()
 		server, _ := conf.Conf.Server()
-		sks, pks := conf.Conf.KeyGen()
-		oprfSeed := internal.RandomBytes(conf.Conf.Hash.Size())
+		sks, pks, _ := conf.Conf.KeyGen()
+		oprfSeed := randomBytes(conf.Conf.Hash.Size())
 		rec := buildRecord(credID, oprfSeed, []byte("yo"), pks, client, server)
 
 		ke1 := client.LoginInit([]byte("yo"))
@@ -244,19 +244,19 @@ func TestClientFinish_InvalidKE2Mac(t *testing.T) {
 	/*
 		Invalid server ke2 mac
 	*/
-	credID := internal.RandomBytes(32)
+	credID := randomBytes(32)
 
 	for _, conf := range confs {
 		client, _ := conf.Conf.Client()
 		server, _ := conf.Conf.Server()
-		sks, pks := conf.Conf.KeyGen()
-		oprfSeed := internal.RandomBytes(conf.Conf.Hash.Size())
+		sks, pks, _ := conf.Conf.KeyGen()
+		oprfSeed := randomBytes(conf.Conf.Hash.Size())
 		rec := buildRecord(credID, oprfSeed, []byte("yo"), pks, client, server)
 
 		ke1 := client.LoginInit([]byte("yo"))
 		ke2, _ := server.LoginInit(ke1, nil, sks, pks, oprfSeed, rec)
 
-		ke2.Mac = internal.RandomBytes(client.GetConf().MAC.Size())
+		ke2.Mac = randomBytes(client.GetConf().MAC.Size())
 		expected := " AKE finalization: invalid server mac"
 		if _, _, err := client.LoginFinish(nil, nil, ke2); err == nil || !strings.HasPrefix(err.Error(), expected) {
 			t.Fatalf("expected error for invalid epks encoding - got %q", err)
@@ -276,3 +276,47 @@ func TestClientFinish_MissingKe1(t *testing.T) {
 		)
 	}
 }
+
+func TestClientHandshakeState(t *testing.T) {
+	for _, c := range confs {
+		client, _ := c.Conf.Client()
+		conf := client.GetConf()
+
+		if client.KE1() != nil || client.EphemeralPublicKey() != nil || client.BlindedElement() != nil {
+			t.Fatal("expected no handshake state before LoginInit")
+		}
+
+		r1 := client.RegistrationInit([]byte("password"))
+		if !bytes.Equal(client.BlindedElement(), r1.Serialize()) {
+			t.Fatal("unexpected blinded element after RegistrationInit")
+		}
+
+		client, _ = c.Conf.Client()
+		ke1 := client.LoginInit([]byte("password"))
+		encoded := ke1.Serialize()
+
+		if !bytes.Equal(client.KE1(), encoded) {
+			t.Fatal("unexpected KE1")
+		}
+
+		if !bytes.Equal(client.EphemeralPublicKey(), encoding.SerializePoint(ke1.EpkU, conf.Group)) {
+			t.Fatal("unexpected ephemeral public key")
+		}
+
+		if !bytes.Equal(client.BlindedElement(), ke1.CredentialRequest.Serialize()) {
+			t.Fatal("unexpected blinded element")
+		}
+
+		// The accessors return copies.
+		client.KE1()[0] ^= 0xff
+		if !bytes.Equal(client.KE1(), encoded) {
+			t.Fatal("KE1 is not read-only")
+		}
+
+		client.Wipe()
+
+		if client.KE1() != nil || client.EphemeralPublicKey() != nil || client.BlindedElement() != nil {
+			t.Fatal("expected no handshake state after Wipe")
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+func TestRegistrationFinalizeWithClientKey(t *testing.T) {
+	password := []byte("password")
+	credID := randomBytes(32)
+
+	for _, conf := range confs {
+		client, _ := conf.Conf.Client()
+		server, _ := conf.Conf.Server()
+		sks, pks, _ := conf.Conf.KeyGen()
+		skc, pkc, _ := conf.Conf.KeyGen()
+		oprfSeed, _ := conf.Conf.GenerateOPRFSeed()
+
+		pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+		r2, _ := server.RegistrationResponse(client.RegistrationInit(password), pk, credID, oprfSeed)
+
+		record, exportKey, err := client.RegistrationFinalizeWithClientKey(r2, nil, nil, skc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(encoding.SerializePoint(record.PublicKey, server.GetConf().Group), pkc) {
+			t.Fatal("record does not hold the client's public key")
+		}
+
+		clientRecord := &opaque.ClientRecord{
+			CredentialIdentifier: credID,
+			RegistrationRecord:   record,
+		}
+
+		// Login with the client key.
+		client, _ = conf.Conf.Client()
+		ke2, err := server.LoginInit(client.LoginInit(password), nil, sks, pks, oprfSeed, clientRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, loginExportKey, err := client.LoginFinishWithClientKey(nil, nil, skc, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(exportKey, loginExportKey) {
+			t.Fatal("export keys differ")
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		// Login without the client key must fail.
+		client, _ = conf.Conf.Client()
+		server, _ = conf.Conf.Server()
+		ke2, _ = server.LoginInit(client.LoginInit(password), nil, sks, pks, oprfSeed, clientRecord)
+
+		if _, _, err := client.LoginFinish(nil, nil, ke2); err == nil {
+			t.Fatal("expected error when logging in without the registered client key")
+		}
+	}
+}
+
+func TestRegistrationFinalizeWithClientKey_InvalidKey(t *testing.T) {
+	for _, conf := range confs {
+		client, _ := conf.Conf.Client()
+		server, _ := conf.Conf.Server()
+		_, pks, _ := conf.Conf.KeyGen()
+		oprfSeed, _ := conf.Conf.GenerateOPRFSeed()
+
+		pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+		r2, _ := server.RegistrationResponse(client.RegistrationInit([]byte("yo")), pk, nil, oprfSeed)
+
+		if _, _, err := client.RegistrationFinalizeWithClientKey(r2, nil, nil, nil); err == nil ||
+			err.Error() != "invalid client secret key" {
+			t.Fatalf("expected error on empty client key, got %v", err)
+		}
+
+		zero := make([]byte, encoding.ScalarLength[server.GetConf().Group])
+		if _, _, err := client.RegistrationFinalizeWithClientKey(r2, nil, nil, zero); err == nil ||
+			err.Error() != "client private key is zero" {
+			t.Fatalf("expected error on zero client key, got %v", err)
+		}
+
+		if _, _, err := client.RegistrationFinalizeWithClientKey(r2, nil, nil, getBadScalar(t, conf)); err == nil {
+			t.Fatal("expected error on invalid client key")
+		}
+	}
+}
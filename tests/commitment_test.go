@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestConfigurationCommitment(t *testing.T) {
+	password := []byte("password")
+	credID := []byte("client")
+
+	// run registers the client and logs it in, the client running in a configuration downgraded to the identity KSF,
+	// which the server doesn't run.
+	run := func(serverCommits, clientCommits bool) error {
+		serverConf := opaque.DefaultConfiguration()
+		serverConf.CommitConfiguration = serverCommits
+
+		clientConf := opaque.DefaultConfiguration()
+		clientConf.KSF = 0
+		clientConf.CommitConfiguration = clientCommits
+
+		sks, pks, _ := serverConf.KeyGen()
+		oprfSeed, _ := serverConf.GenerateOPRFSeed()
+		client, _ := clientConf.Client()
+		server, _ := serverConf.Server()
+		record := buildRecord(credID, oprfSeed, password, pks, client, server)
+
+		client, _ = clientConf.Client()
+		server, _ = serverConf.Server()
+
+		ke2, err := server.LoginInit(client.LoginInit(password), nil, sks, pks, oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(nil, nil, ke2)
+		if err != nil {
+			return err
+		}
+
+		if err = server.LoginFinish(ke3); err != nil {
+			return err
+		}
+
+		if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+			t.Fatal("session keys differ")
+		}
+
+		return nil
+	}
+
+	// Without the commitment, the downgrade goes unnoticed.
+	if err := run(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, commits := range [][2]bool{{true, true}, {true, false}, {false, true}} {
+		if err := run(commits[0], commits[1]); err == nil {
+			t.Fatalf("expected the login to fail with commitments %v", commits)
+		}
+	}
+
+	// Matching configurations with the commitment log in.
+	conf, _, err := opaque.NewConfigurationBuilder().WithConfigurationCommitment().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := newTestParams(t, conf)
+	test.password = password
+	record, _ := testRegistration(t, test)
+	testAuthentication(t, test, record)
+
+	conf.Compatibility, conf.KSF = opaque.OpaqueKE, 0
+	if _, err = conf.Client(); err == nil {
+		t.Fatal("expected an error with a commitment in a compatibility mode")
+	}
+}
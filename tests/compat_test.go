@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/crypto/ksf"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/internal/oprf"
+)
+
+func opaqueKEConfiguration(g opaque.Group, h crypto.Hash, k ksf.Identifier) *opaque.Configuration {
+	return &opaque.Configuration{
+		OPRF:          g,
+		KDF:           h,
+		MAC:           h,
+		Hash:          h,
+		KSF:           k,
+		AKE:           g,
+		Compatibility: opaque.OpaqueKE,
+	}
+}
+
+// compatRegister registers the password with a client and a server of the configuration.
+func compatRegister(t *testing.T, conf *opaque.Configuration, pk, oprfSeed []byte) *opaque.ClientRecord {
+	t.Helper()
+
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	pks, _ := server.Deserialize.DecodeAkePublicKey(pk)
+
+	response, err := server.RegistrationResponse(client.RegistrationInit([]byte("password")), pks,
+		[]byte("credential"), oprfSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, _, err := client.RegistrationFinalize(response, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &opaque.ClientRecord{CredentialIdentifier: []byte("credential"), RegistrationRecord: record}
+}
+
+// compatLogin runs a login of a client of the client configuration to a server of the server configuration.
+func compatLogin(t *testing.T, clientConf, serverConf *opaque.Configuration, sk, pk, oprfSeed []byte,
+	record *opaque.ClientRecord,
+) error {
+	t.Helper()
+
+	client, _ := clientConf.Client()
+	server, _ := serverConf.Server()
+
+	ke2, err := server.LoginInit(client.LoginInit([]byte("password")), nil, sk, pk, oprfSeed, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, _, err := client.LoginFinish(nil, nil, ke2)
+	if err != nil {
+		return err
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+		t.Fatal("the session keys differ")
+	}
+
+	return nil
+}
+
+func TestOpaqueKE(t *testing.T) {
+	for _, conf := range []*opaque.Configuration{
+		opaqueKEConfiguration(opaque.RistrettoSha512, crypto.SHA512, 0),
+		opaqueKEConfiguration(opaque.RistrettoSha512, crypto.SHA512, ksf.Argon2id),
+		opaqueKEConfiguration(opaque.P256Sha256, crypto.SHA256, 0),
+	} {
+		sk, pk, _ := conf.KeyGen()
+		oprfSeed, _ := conf.GenerateOPRFSeed()
+		record := compatRegister(t, conf, pk, oprfSeed)
+
+		if err := compatLogin(t, conf, conf, sk, pk, oprfSeed, record); err != nil {
+			t.Fatalf("%v: %v", conf.KSF, err)
+		}
+
+		// A client of the draft can't log in with the record of an opaque-ke client.
+		draft := *conf
+		draft.Compatibility = opaque.Draft
+
+		if err := compatLogin(t, &draft, conf, sk, pk, oprfSeed, record); err == nil {
+			t.Fatal("expected an error logging in with a client of the draft")
+		}
+	}
+}
+
+// TestOpaqueKE_OPRF checks the DeriveKeyPair test vectors of RFC 9497, which pin its context string.
+func TestOpaqueKE_OPRF(t *testing.T) {
+	seed := bytes.Repeat([]byte{0xa3}, 32)
+
+	for suite, expected := range map[oprf.Ciphersuite]string{
+		oprf.RistrettoSha512: "5ebcea5ee37023ccb9fc2d2019f9d7737be85591ae8652ffa9ef0f4d37063b0e",
+		oprf.P256Sha256:      "159749d750713afe245d2d39ccfaae8381c53ce92d098a9375ee70739c7ac0bf",
+	} {
+		sk, err := suite.RFC9497().DeriveKey(seed, []byte("test key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hex.EncodeToString(encoding.SerializeScalar(sk, suite.Group())) != expected {
+			t.Fatalf("%v: unexpected key %x", suite.Group(), encoding.SerializeScalar(sk, suite.Group()))
+		}
+
+		if suite.RFC9497().Group() != suite.Group() {
+			t.Fatal("the RFC 9497 cipher suite must have the same group")
+		}
+	}
+}
+
+func TestOpaqueKE_Configuration(t *testing.T) {
+	conf := opaqueKEConfiguration(opaque.RistrettoSha512, crypto.SHA512, ksf.Argon2id)
+
+	// The compatibility mode is encoded after the default values of the fields before it.
+	encoded := conf.Serialize()
+	if len(encoded) != len(opaque.DefaultConfiguration().Serialize())+1+2+1+1+1 {
+		t.Fatalf("unexpected encoding length %d", len(encoded))
+	}
+
+	decoded, err := opaque.DeserializeConfiguration(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isSameConf(conf, decoded) {
+		t.Fatalf("unexpected inequality:\n\t%v\n\t%v", conf, decoded)
+	}
+
+	// A Draft compatibility byte is not canonical, and an unknown one is invalid.
+	encoded[len(encoded)-1] = byte(opaque.Draft)
+	if _, err = opaque.DeserializeConfiguration(encoded); !errors.Is(err, internal.ErrConfigurationInvalidLength) {
+		t.Fatalf("expected %q, got %v", internal.ErrConfigurationInvalidLength, err)
+	}
+
+	encoded[len(encoded)-1] = 9
+	if _, err = opaque.DeserializeConfiguration(encoded); err == nil || err.Error() != "invalid compatibility mode" {
+		t.Fatalf("expected an invalid compatibility mode, got %v", err)
+	}
+}
+
+func TestOpaqueKE_Unsupported(t *testing.T) {
+	for name, set := range map[string]func(c *opaque.Configuration){
+		"KSF":      func(c *opaque.Configuration) { c.KSF = ksf.Scrypt },
+		"mode":     func(c *opaque.Configuration) { c.Mode = opaque.External },
+		"app data": func(c *opaque.Configuration) { c.AppDataLength = 32 },
+		"protocol": func(c *opaque.Configuration) { c.Protocol = opaque.HMQV },
+	} {
+		conf := opaqueKEConfiguration(opaque.RistrettoSha512, crypto.SHA512, ksf.Argon2id)
+		set(conf)
+
+		if _, err := conf.Client(); err == nil || !strings.HasPrefix(err.Error(),
+			"configuration is not supported in the compatibility mode") {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestLibOpaque(t *testing.T) {
+	for _, k := range []ksf.Identifier{0, ksf.Argon2id} {
+		conf := opaqueKEConfiguration(opaque.RistrettoSha512, crypto.SHA512, k)
+		conf.Compatibility = opaque.LibOpaque
+
+		sk, pk, _ := conf.KeyGen()
+		oprfKey, err := conf.GenerateOPRFKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Registration, with the OPRF key stored in the record.
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+		pks, _ := server.Deserialize.DecodeAkePublicKey(pk)
+
+		response, err := server.RegistrationResponseWithOPRFKey(client.RegistrationInit([]byte("password")), pks,
+			oprfKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		registration, exportKey, err := client.RegistrationFinalize(response, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored := (&opaque.LibOpaqueRecord{
+			OPRFKey:            oprfKey,
+			ServerSecretKey:    sk,
+			RegistrationRecord: registration,
+		}).Serialize()
+
+		// Login, with the record decoded as a libopaque server stores it.
+		client, _ = conf.Client()
+		server, _ = conf.Server()
+
+		record, err := server.Deserialize.LibOpaqueRecord(stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke2, err := server.LoginInitWithOPRFKey(client.LoginInit([]byte("password")), nil, record.ServerSecretKey, pk,
+			record.OPRFKey, &opaque.ClientRecord{RegistrationRecord: record.RegistrationRecord})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, loginExportKey, err := client.LoginFinish(nil, nil, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(exportKey, loginExportKey) || !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+			t.Fatal("the keys differ")
+		}
+	}
+}
+
+func TestLibOpaque_Errors(t *testing.T) {
+	conf := opaqueKEConfiguration(opaque.P256Sha256, crypto.SHA256, 0)
+	conf.Compatibility = opaque.LibOpaque
+
+	if _, err := conf.Server(); err == nil || !strings.HasPrefix(err.Error(),
+		"configuration is not supported in the compatibility mode") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	conf = opaqueKEConfiguration(opaque.RistrettoSha512, crypto.SHA512, 0)
+	conf.Compatibility = opaque.LibOpaque
+	server, _ := conf.Server()
+	sk, pk, _ := conf.KeyGen()
+	oprfKey, _ := conf.GenerateOPRFKey()
+	pks, _ := server.Deserialize.DecodeAkePublicKey(pk)
+	client, _ := conf.Client()
+	request := client.RegistrationInit([]byte("password"))
+
+	if _, err := server.RegistrationResponseWithOPRFKey(request, pks, make([]byte, 32)); !errors.Is(err,
+		opaque.ErrInvalidOPRFKey) {
+		t.Fatalf("expected %q for a zero key, got %v", opaque.ErrInvalidOPRFKey, err)
+	}
+
+	if _, err := server.RegistrationResponseWithOPRFKey(request, pks, oprfKey[1:]); !errors.Is(err,
+		opaque.ErrInvalidOPRFKey) {
+		t.Fatalf("expected %q for a short key, got %v", opaque.ErrInvalidOPRFKey, err)
+	}
+
+	if _, err := server.Deserialize.LibOpaqueRecord(encoding.Concat(oprfKey, sk[1:])); err == nil {
+		t.Fatal("expected an error for a short record")
+	}
+
+	if _, err := server.Deserialize.LibOpaqueRecord(encoding.Concat(make([]byte, 64), sk)); !errors.Is(err,
+		opaque.ErrInvalidOPRFKey) {
+		t.Fatalf("expected %q for a zero key in the record, got %v", opaque.ErrInvalidOPRFKey, err)
+	}
+}
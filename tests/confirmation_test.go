@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+func TestKeyConfirmation(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		// The client can't verify a confirmation before finishing the login.
+		if err := client.VerifyConfirmation(&message.KE4{}); !errors.Is(err, opaque.ErrAkeInvalidServerConfirmation) {
+			t.Fatalf("expected %q, got %q", opaque.ErrAkeInvalidServerConfirmation, err)
+		}
+
+		ke1 := client.LoginInit(test.password)
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke4, err := server.LoginFinishWithConfirmation(ke3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke4, err = client.Deserialize.KE4(ke4.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := client.VerifyConfirmation(ke4); err != nil {
+			t.Fatal(err)
+		}
+
+		ke4.Mac[0] ^= 0xff
+
+		if err := client.VerifyConfirmation(ke4); !errors.Is(err, opaque.ErrAkeInvalidServerConfirmation) {
+			t.Fatalf("expected %q, got %q", opaque.ErrAkeInvalidServerConfirmation, err)
+		}
+
+		// No confirmation is produced for an invalid KE3.
+		ke3.Mac[0] ^= 0xff
+
+		server, _ = conf.Server()
+		if _, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := server.LoginFinishWithConfirmation(ke3); !errors.Is(err, opaque.ErrAkeInvalidClientMac) {
+			t.Fatalf("expected %q, got %q", opaque.ErrAkeInvalidClientMac, err)
+		}
+	}
+}
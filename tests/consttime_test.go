@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+func TestConstantTimeCompareRecords(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	clone := func() *opaque.ClientRecord {
+		r := *record.RegistrationRecord
+
+		return &opaque.ClientRecord{
+			CredentialIdentifier: append([]byte(nil), record.CredentialIdentifier...),
+			ClientIdentity:       append([]byte(nil), record.ClientIdentity...),
+			RegistrationRecord: &message.RegistrationRecord{
+				G:          r.G,
+				PublicKey:  r.PublicKey.Copy(),
+				MaskingKey: append([]byte(nil), r.MaskingKey...),
+				Envelope:   append([]byte(nil), r.Envelope...),
+			},
+		}
+	}
+
+	if !opaque.ConstantTimeCompareRecords(record, clone()) || !opaque.ConstantTimeCompareRecords(nil, nil) {
+		t.Fatal("expected equal records")
+	}
+
+	other, _ := testRegistration(t, test)
+
+	if opaque.ConstantTimeCompareRecords(record, nil) || opaque.ConstantTimeCompareRecords(nil, record) {
+		t.Fatal("expected a nil record to be unequal")
+	}
+
+	for name, tamper := range map[string]func(r *opaque.ClientRecord){
+		"credential identifier": func(r *opaque.ClientRecord) { r.CredentialIdentifier[0] ^= 1 },
+		"client identity":       func(r *opaque.ClientRecord) { r.ClientIdentity = nil },
+		"public key":            func(r *opaque.ClientRecord) { r.PublicKey = other.PublicKey },
+		"masking key":           func(r *opaque.ClientRecord) { r.MaskingKey[0] ^= 1 },
+		"envelope":              func(r *opaque.ClientRecord) { r.Envelope[0] ^= 1 },
+		"registration record":   func(r *opaque.ClientRecord) { r.RegistrationRecord = nil },
+	} {
+		r := clone()
+		tamper(r)
+
+		if opaque.ConstantTimeCompareRecords(record, r) || opaque.ConstantTimeCompareRecords(r, record) {
+			t.Fatalf("expected records differing in the %s to be unequal", name)
+		}
+	}
+}
+
+// variableTimeCalls are comparisons whose time depends on where their inputs differ.
+var variableTimeCalls = map[string][]string{
+	"bytes":   {"Equal", "Compare"},
+	"reflect": {"DeepEqual"},
+}
+
+// TestConstantTimeAudit fails if a variable-time comparison appears in the library's sources.
+func TestConstantTimeAudit(t *testing.T) {
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			switch d.Name() {
+			case "tests", "benchmarks", "opaquefuzz", "vectors", "interop", "opaqueprop", ".git":
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if pkg, name, ok := selector(n.Fun); ok && contains(variableTimeCalls[pkg], name) {
+					t.Errorf("%s: variable-time comparison %s.%s", fset.Position(n.Pos()), pkg, name)
+				}
+			case *ast.BinaryExpr:
+				if (n.Op == token.EQL || n.Op == token.NEQ) && (isStringConversion(n.X) || isStringConversion(n.Y)) {
+					t.Errorf("%s: variable-time comparison of converted strings", fset.Position(n.Pos()))
+				}
+			}
+
+			return true
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConstantTimeVerification fails if envelope recovery or KE3 verification stop comparing tags with MAC.Equal.
+func TestConstantTimeVerification(t *testing.T) {
+	for file, funcs := range map[string][]string{
+		"../internal/keyrecovery/envelope.go": {"Recover"},
+		"../internal/ake/client.go":           {"Finalize"},
+		"../internal/ake/server.go":           {"Finalize"},
+		"../reauth.go":                        {"ReauthFinish", "ReauthVerify"},
+	} {
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checked := 0
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || !contains(funcs, fn.Name.Name) {
+				continue
+			}
+
+			checked++
+			found := false
+
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "Equal" {
+					if _, name, ok := selector(sel.X); ok && name == "MAC" {
+						found = true
+					}
+				}
+
+				return !found
+			})
+
+			if !found {
+				t.Errorf("%s: %s doesn't compare with MAC.Equal", file, fn.Name.Name)
+			}
+		}
+
+		if checked != len(funcs) {
+			t.Errorf("%s: expected the functions %v", file, funcs)
+		}
+	}
+}
+
+func selector(e ast.Expr) (x, name string, ok bool) {
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok {
+		return "", "", false
+	}
+
+	switch v := sel.X.(type) {
+	case *ast.Ident:
+		return v.Name, sel.Sel.Name, true
+	case *ast.SelectorExpr:
+		return v.Sel.Name, sel.Sel.Name, true
+	default:
+		return "", "", false
+	}
+}
+
+func isStringConversion(e ast.Expr) bool {
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+
+	ident, ok := call.Fun.(*ast.Ident)
+
+	return ok && ident.Name == "string"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
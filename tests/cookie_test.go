@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaquehttp"
+)
+
+func TestCookie(t *testing.T) {
+	key := randomBytes(32)
+	source := []byte("192.0.2.1")
+	now := time.Now()
+
+	cookie, err := opaque.NewCookie(key, source, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = opaque.VerifyCookie(key, cookie, source, now); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append(opaque.Cookie(nil), cookie...)
+	tampered[0] ^= 1
+
+	for name, test := range map[string]struct {
+		key, source []byte
+		cookie      opaque.Cookie
+		now         time.Time
+	}{
+		"other key":    {randomBytes(32), source, cookie, now},
+		"other source": {key, []byte("192.0.2.2"), cookie, now},
+		"expired":      {key, source, cookie, now.Add(2 * time.Minute)},
+		"tampered":     {key, source, tampered, now},
+		"truncated":    {key, source, cookie[:len(cookie)-1], now},
+		"empty":        {key, source, nil, now},
+	} {
+		if err = opaque.VerifyCookie(test.key, test.cookie, test.source, test.now); !errors.Is(err, opaque.ErrCookie) {
+			t.Fatalf("%s: expected %q, got %v", name, opaque.ErrCookie, err)
+		}
+	}
+
+	if _, err = opaque.NewCookie(key[:16], source, now); err == nil {
+		t.Fatal("expected an error on a short key")
+	}
+
+	if err = opaque.VerifyCookie(key[:16], cookie, source, now); err == nil {
+		t.Fatal("expected an error on a short key")
+	}
+}
+
+func TestHTTPHandler_Cookie(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.KSF = 0
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+	require := true
+
+	h := &opaquehttp.Handler{
+		Configuration:   conf,
+		Credentials:     opaquehttp.NewMemoryCredentialStore(),
+		Sessions:        opaquehttp.NewMemorySessionStore(time.Minute),
+		ServerSecretKey: sks,
+		ServerPublicKey: pks,
+		OPRFSeed:        oprfSeed,
+		CookieKey:       randomBytes(32),
+		RequireCookie: func(*http.Request) bool {
+			return require
+		},
+	}
+
+	mux := http.NewServeMux()
+	h.Mount(mux, "")
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client, _ := conf.Client()
+	request := &httpMessage{
+		CredentialIdentifier: []byte("alice"),
+		Message:              client.LoginInit([]byte("password")).Serialize(),
+	}
+
+	// Without a cookie, the KE1 is answered with one.
+	resp, status := postJSON(t, srv.URL+"/login/init", request)
+	if status != http.StatusOK || len(resp.Cookie) == 0 || len(resp.Message) != 0 || resp.Session != "" {
+		t.Fatalf("expected a cookie, got status %d and %+v", status, resp)
+	}
+
+	// With the cookie, it is answered with KE2.
+	request.Cookie = resp.Cookie
+
+	resp, status = postJSON(t, srv.URL+"/login/init", request)
+	if status != http.StatusOK || resp.Session == "" {
+		t.Fatalf("expected a KE2, got status %d and %+v", status, resp)
+	}
+
+	if _, err := client.Deserialize.KE2(resp.Message); err != nil {
+		t.Fatal(err)
+	}
+
+	// An invalid cookie is answered with a new one.
+	request.Cookie[len(request.Cookie)-1] ^= 1
+
+	if resp, _ = postJSON(t, srv.URL+"/login/init", request); resp.Session != "" || len(resp.Cookie) == 0 {
+		t.Fatalf("expected a new cookie, got %+v", resp)
+	}
+
+	// The requests that don't require a cookie are processed without.
+	require = false
+	request.Cookie = nil
+
+	if resp, _ = postJSON(t, srv.URL+"/login/init", request); resp.Session == "" {
+		t.Fatalf("expected a KE2, got %+v", resp)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestMintRegistrationResponse(t *testing.T) {
+	for _, c := range confs {
+		_, pk, _ := c.Conf.KeyGen()
+		seed := randomBytes(c.Conf.Hash.Size())
+		client, _ := c.Conf.Client()
+		server, _ := c.Conf.Server()
+
+		pks, err := server.Deserialize.DecodeAkePublicKey(pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := client.RegistrationInit([]byte("password"))
+
+		resp, credID, err := server.MintRegistrationResponse(req, pks, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(credID) != opaque.CredentialIdentifierLength {
+			t.Fatalf("unexpected credential identifier length %d", len(credID))
+		}
+
+		// The OPRF key is derived from the minted credential identifier.
+		expected, _ := server.RegistrationResponse(req, pks, credID, seed)
+		if !bytes.Equal(resp.Serialize(), expected.Serialize()) {
+			t.Fatal("expected the response to be bound to the minted credential identifier")
+		}
+
+		_, other, _ := server.MintRegistrationResponse(req, pks, seed)
+		if bytes.Equal(credID, other) {
+			t.Fatal("expected different credential identifiers")
+		}
+	}
+}
+
+func TestAnonymizeCredentialIdentifier(t *testing.T) {
+	key := randomBytes(32)
+
+	alice, err := opaque.AnonymizeCredentialIdentifier(key, []byte("alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(alice) != opaque.CredentialIdentifierLength || bytes.Contains(alice, []byte("alice")) {
+		t.Fatalf("unexpected credential identifier %x", alice)
+	}
+
+	// The mapping is deterministic under a key, and differs across identities and keys.
+	if again, _ := opaque.AnonymizeCredentialIdentifier(key, []byte("alice@example.com")); !bytes.Equal(alice, again) {
+		t.Fatal("expected the same credential identifier")
+	}
+
+	if bob, _ := opaque.AnonymizeCredentialIdentifier(key, []byte("bob@example.com")); bytes.Equal(alice, bob) {
+		t.Fatal("expected different credential identifiers across identities")
+	}
+
+	other, _ := opaque.AnonymizeCredentialIdentifier(randomBytes(32), []byte("alice@example.com"))
+	if bytes.Equal(alice, other) {
+		t.Fatal("expected different credential identifiers across keys")
+	}
+
+	if _, err := opaque.AnonymizeCredentialIdentifier(randomBytes(16), []byte("alice")); err == nil {
+		t.Fatal("expected an error on a short key")
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/testkit"
+)
+
+// TestDebugDetail runs in both builds: the errors carry detail with the debug build tag only, and still match their
+// sentinels.
+func TestDebugDetail(t *testing.T) {
+	n := newTestNetwork(t)
+	testkit.ExpectSuccess(t, n.Register())
+
+	for _, test := range []struct {
+		name     string
+		fault    testkit.Fault
+		password []byte
+		detail   string
+	}{
+		// A wrong password fails on the unmasked server public key, or else on the envelope.
+		{"wrong password", nil, []byte("wrong"), ""},
+		{"flip KE2 mac", testkit.FlipBit(opaque.KE2Message, -1), n.Credentials.Password, "transcript hash"},
+		{"flip KE3", testkit.FlipBit(opaque.KE3Message, 0), n.Credentials.Password, "transcript hash"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n.Reset()
+
+			if test.fault != nil {
+				n.Inject(test.fault)
+			}
+
+			r := n.Login(test.password)
+			if !r.Failed() {
+				t.Fatal("expected the login to fail")
+			}
+
+			detailed := strings.Contains(r.Err.Error(), "[debug:") && strings.Contains(r.Err.Error(), test.detail)
+			if detailed != internal.Debug {
+				t.Fatalf("unexpected error detail in the build with debug %t: %q", internal.Debug, r.Err)
+			}
+		})
+	}
+
+	n.Reset()
+	n.Inject(testkit.FlipBit(opaque.KE3Message, 0))
+
+	if r := n.Login(n.Credentials.Password); !errors.Is(r.Err, opaque.ErrAkeInvalidClientMac) {
+		t.Fatalf("expected %q, got %q", opaque.ErrAkeInvalidClientMac, r.Err)
+	}
+}
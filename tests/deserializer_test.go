@@ -9,7 +9,9 @@
 package opaque_test
 
 import (
+	"bytes"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/bytemare/crypto/group"
@@ -17,6 +19,8 @@ import (
 	"github.com/bytemare/opaque"
 	"github.com/bytemare/opaque/internal"
 	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+	"github.com/bytemare/opaque/opaqueprop"
 )
 
 var errInvalidMessageLength = errors.New("invalid message length for the configuration")
@@ -55,14 +59,14 @@ func TestDeserializeRegistrationRequest(t *testing.T) {
 	server, _ := c.Server()
 	conf := server.GetConf()
 	length := conf.OPRFPointLength + 1
-	if _, err := server.Deserialize.RegistrationRequest(internal.RandomBytes(length)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	if _, err := server.Deserialize.RegistrationRequest(randomBytes(length)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeRegistrationRequest. want %q, got %q", errInvalidMessageLength, err)
 	}
 
-	client, _ := c.Client()
-	if _, err := client.Deserialize.RegistrationRequest(internal.RandomBytes(length)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	d, _ := c.Deserializer()
+	if _, err := d.RegistrationRequest(randomBytes(length)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeRegistrationRequest. want %q, got %q", errInvalidMessageLength, err)
 	}
 }
@@ -70,17 +74,17 @@ func TestDeserializeRegistrationRequest(t *testing.T) {
 func TestDeserializeRegistrationResponse(t *testing.T) {
 	c := opaque.DefaultConfiguration()
 
-	server, _ := c.Server()
-	conf := server.GetConf()
+	client, _ := c.Client()
+	conf := client.GetConf()
 	length := conf.OPRFPointLength + conf.AkePointLength + 1
-	if _, err := server.Deserialize.RegistrationResponse(internal.RandomBytes(length)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	if _, err := client.Deserialize.RegistrationResponse(randomBytes(length)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeRegistrationRequest. want %q, got %q", errInvalidMessageLength, err)
 	}
 
-	client, _ := c.Client()
-	if _, err := client.Deserialize.RegistrationResponse(internal.RandomBytes(length)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	d, _ := c.Deserializer()
+	if _, err := d.RegistrationResponse(randomBytes(length)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeRegistrationRequest. want %q, got %q", errInvalidMessageLength, err)
 	}
 }
@@ -90,22 +94,22 @@ func TestDeserializeRegistrationRecord(t *testing.T) {
 		server, _ := e.Conf.Server()
 		conf := server.GetConf()
 		length := conf.AkePointLength + conf.Hash.Size() + conf.EnvelopeSize + 1
-		if _, err := server.Deserialize.RegistrationRecord(internal.RandomBytes(length)); err == nil ||
-			err.Error() != errInvalidMessageLength.Error() {
+		if _, err := server.Deserialize.RegistrationRecord(randomBytes(length)); err == nil ||
+			!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 			t.Fatalf("Expected error for DeserializeRegistrationRequest. want %q, got %q", errInvalidMessageLength, err)
 		}
 
 		badPKu := getBadElement(t, e)
-		rec := encoding.Concat(badPKu, internal.RandomBytes(conf.Hash.Size()+conf.EnvelopeSize))
+		rec := encoding.Concat(badPKu, randomBytes(conf.Hash.Size()+conf.EnvelopeSize))
 
 		expect := "invalid client public key"
-		if _, err := server.Deserialize.RegistrationRecord(rec); err == nil || err.Error() != expect {
+		if _, err := server.Deserialize.RegistrationRecord(rec); err == nil || !strings.HasPrefix(err.Error(), expect) {
 			t.Fatalf("Expected error for DeserializeRegistrationRequest. want %q, got %q", expect, err)
 		}
 
-		client, _ := e.Conf.Client()
-		if _, err := client.Deserialize.RegistrationRecord(internal.RandomBytes(length)); err == nil ||
-			err.Error() != errInvalidMessageLength.Error() {
+		d, _ := e.Conf.Deserializer()
+		if _, err := d.RegistrationRecord(randomBytes(length)); err == nil ||
+			!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 			t.Fatalf("Expected error for DeserializeRegistrationRequest. want %q, got %q", errInvalidMessageLength, err)
 		}
 	}
@@ -117,14 +121,14 @@ func TestDeserializeKE1(t *testing.T) {
 	ke1Length := encoding.PointLength[g] + internal.NonceLength + encoding.PointLength[g]
 
 	server, _ := c.Server()
-	if _, err := server.Deserialize.KE1(internal.RandomBytes(ke1Length + 1)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	if _, err := server.Deserialize.KE1(randomBytes(ke1Length + 1)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeKE1. want %q, got %q", errInvalidMessageLength, err)
 	}
 
-	client, _ := c.Client()
-	if _, err := client.Deserialize.KE1(internal.RandomBytes(ke1Length + 1)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	d, _ := c.Deserializer()
+	if _, err := d.KE1(randomBytes(ke1Length + 1)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeKE1. want %q, got %q", errInvalidMessageLength, err)
 	}
 }
@@ -135,16 +139,14 @@ func TestDeserializeKE2(t *testing.T) {
 	client, _ := c.Client()
 	conf := client.GetConf()
 	ke2Length := conf.OPRFPointLength + 2*conf.NonceLen + 2*conf.AkePointLength + conf.EnvelopeSize + conf.MAC.Size()
-	if _, err := client.Deserialize.KE2(internal.RandomBytes(ke2Length + 1)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	if _, err := client.Deserialize.KE2(randomBytes(ke2Length + 1)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeKE1. want %q, got %q", errInvalidMessageLength, err)
 	}
 
-	server, _ := c.Server()
-	conf = server.GetConf()
-	ke2Length = conf.OPRFPointLength + 2*conf.NonceLen + 2*conf.AkePointLength + conf.EnvelopeSize + conf.MAC.Size()
-	if _, err := server.Deserialize.KE2(internal.RandomBytes(ke2Length + 1)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	d, _ := c.Deserializer()
+	if _, err := d.KE2(randomBytes(ke2Length + 1)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeKE1. want %q, got %q", errInvalidMessageLength, err)
 	}
 }
@@ -154,14 +156,176 @@ func TestDeserializeKE3(t *testing.T) {
 	ke3Length := c.MAC.Size()
 
 	server, _ := c.Server()
-	if _, err := server.Deserialize.KE3(internal.RandomBytes(ke3Length + 1)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	if _, err := server.Deserialize.KE3(randomBytes(ke3Length + 1)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeKE1. want %q, got %q", errInvalidMessageLength, err)
 	}
 
-	client, _ := c.Client()
-	if _, err := client.Deserialize.KE3(internal.RandomBytes(ke3Length + 1)); err == nil ||
-		err.Error() != errInvalidMessageLength.Error() {
+	d, _ := c.Deserializer()
+	if _, err := d.KE3(randomBytes(ke3Length + 1)); err == nil ||
+		!strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
 		t.Fatalf("Expected error for DeserializeKE1. want %q, got %q", errInvalidMessageLength, err)
 	}
 }
+
+func TestDeserializeLengthError(t *testing.T) {
+	c := opaque.DefaultConfiguration()
+	server, _ := c.Server()
+	conf := server.GetConf()
+
+	tests := []struct {
+		name     string
+		field    string
+		message  opaque.MessageType
+		input    []byte
+		expected int
+		received int
+	}{
+		{
+			name:     "truncated nonce",
+			message:  opaque.KE1Message,
+			input:    randomBytes(conf.OPRFPointLength + 12),
+			field:    "client nonce",
+			expected: conf.NonceLen,
+			received: 12,
+		},
+		{
+			name:     "truncated mac",
+			message:  opaque.KE3Message,
+			input:    randomBytes(conf.MAC.Size() - 1),
+			field:    "client MAC",
+			expected: conf.MAC.Size(),
+			received: conf.MAC.Size() - 1,
+		},
+		{
+			name:     "trailing data",
+			message:  opaque.RegistrationRequestMessage,
+			input:    randomBytes(conf.OPRFPointLength + 3),
+			field:    "trailing data",
+			expected: 0,
+			received: 3,
+		},
+		{
+			name:     "application message",
+			message:  opaque.KE1Message,
+			input:    encoding.Concat(randomBytes(c.KE1Length()), []byte{0, 5, 1}),
+			field:    "application message",
+			expected: 7,
+			received: 3,
+		},
+	}
+
+	for _, test := range tests {
+		err := server.Deserialize.Check(test.message, test.input)
+
+		var lengthErr *opaque.LengthError
+		if !errors.As(err, &lengthErr) {
+			t.Fatalf("%s: expected a length error, got %v", test.name, err)
+		}
+
+		if lengthErr.Message != test.message || lengthErr.Field != test.field ||
+			lengthErr.Expected != test.expected || lengthErr.Received != test.received {
+			t.Fatalf("%s: unexpected length error %+v", test.name, lengthErr)
+		}
+
+		if !strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) ||
+			!strings.Contains(err.Error(), test.field) {
+			t.Fatalf("%s: unexpected error message %q", test.name, err)
+		}
+	}
+}
+
+func TestDeserializeIdentityElement(t *testing.T) {
+	for _, c := range confs {
+		server, _ := c.Conf.Server()
+		conf := server.GetConf()
+		client, _ := c.Conf.Client()
+		ke1 := client.LoginInit([]byte("password")).Serialize()
+
+		// All-zero encodings are the identity element, or invalid, in all groups.
+		identity := make([]byte, conf.AkePointLength)
+		badKE1 := encoding.Concatenate(ke1[:conf.OPRFPointLength+conf.NonceLen], identity,
+			ke1[conf.OPRFPointLength+conf.NonceLen+conf.AkePointLength:])
+
+		expected := "invalid ephemeral client public key"
+		if _, err := server.Deserialize.KE1(badKE1); err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Fatalf("expected error on identity epku - got %v", err)
+		}
+
+		badKE1 = encoding.Concat(make([]byte, conf.OPRFPointLength), ke1[conf.OPRFPointLength:])
+
+		expected = "blinded data is an invalid point"
+		if _, err := server.Deserialize.KE1(badKE1); err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Fatalf("expected error on identity blinded element - got %v", err)
+		}
+
+		if _, err := server.Deserialize.DecodeAkePublicKey(identity); err == nil {
+			t.Fatal("expected error on identity public key")
+		}
+
+		zero := make([]byte, encoding.ScalarLength[conf.Group])
+		if _, err := server.Deserialize.DecodeAkePrivateKey(zero); err == nil {
+			t.Fatal("expected error on zero private key")
+		}
+	}
+}
+
+func TestDeserializeGeneric(t *testing.T) {
+	type serializer interface{ Serialize() []byte }
+
+	for _, c := range confs {
+		reg, err := opaqueprop.Register(c.Conf, &opaqueprop.Credentials{
+			Password:             []byte("password"),
+			CredentialIdentifier: []byte("client"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := reg.Login(reg.Credentials.Password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		routes := map[opaque.MessageType]func([]byte) (serializer, error){
+			opaque.RegistrationRequestMessage: func(b []byte) (serializer, error) {
+				return opaque.Deserialize[*message.RegistrationRequest](c.Conf, b)
+			},
+			opaque.RegistrationResponseMessage: func(b []byte) (serializer, error) {
+				return opaque.Deserialize[*message.RegistrationResponse](c.Conf, b)
+			},
+			opaque.RegistrationRecordMessage: func(b []byte) (serializer, error) {
+				return opaque.Deserialize[*message.RegistrationRecord](c.Conf, b)
+			},
+			opaque.KE1Message: func(b []byte) (serializer, error) {
+				return opaque.Deserialize[*message.KE1](c.Conf, b)
+			},
+			opaque.KE2Message: func(b []byte) (serializer, error) {
+				return opaque.Deserialize[*message.KE2](c.Conf, b)
+			},
+			opaque.KE3Message: func(b []byte) (serializer, error) {
+				return opaque.Deserialize[*message.KE3](c.Conf, b)
+			},
+		}
+
+		for _, m := range h.Sequence {
+			decoded, err := routes[m.Type](m.Encoded)
+			if err != nil {
+				t.Fatalf("%v: %v", m.Type, err)
+			}
+
+			if !bytes.Equal(decoded.Serialize(), m.Encoded) {
+				t.Fatalf("%v: unexpected encoding", m.Type)
+			}
+
+			if _, err = routes[m.Type](m.Encoded[1:]); err == nil {
+				t.Fatalf("%v: expected error on truncated message", m.Type)
+			}
+		}
+	}
+
+	invalid := &opaque.Configuration{}
+	if ke1, err := opaque.Deserialize[*message.KE1](invalid, nil); err == nil || ke1 != nil {
+		t.Fatal("expected error on invalid configuration")
+	}
+}
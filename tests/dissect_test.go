@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+// capturedMessages returns the serialized messages of a registration and login in the configuration.
+func capturedMessages(t *testing.T, conf *opaque.Configuration) map[opaque.MessageType][]byte {
+	p := newMobileParties(t, conf.Serialize(), nil)
+	password, credID := []byte("password"), []byte("credential")
+
+	request := p.client.RegistrationInit(password)
+
+	response, err := p.server.RegistrationResponse(request, credID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registration, err := p.client.RegistrationFinalize(response, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke1 := p.client.LoginInit(password)
+
+	ke2, err := p.server.LoginInit(ke1, registration.Record, credID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login, err := p.client.LoginFinish(ke2, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[opaque.MessageType][]byte{
+		opaque.RegistrationRequestMessage:  request,
+		opaque.RegistrationResponseMessage: response,
+		opaque.RegistrationRecordMessage:   registration.Record,
+		opaque.KE1Message:                  ke1,
+		opaque.KE2Message:                  ke2,
+		opaque.KE3Message:                  login.KE3,
+	}
+}
+
+// checkCoverage verifies that the fields cover the message in order.
+func checkCoverage(t *testing.T, fields []opaque.MessageField, m []byte) {
+	offset := 0
+
+	for _, f := range fields {
+		if f.Offset != offset || f.Length != len(f.Value) || !bytes.Equal(f.Value, m[offset:offset+f.Length]) {
+			t.Fatalf("field %q at %d of length %d doesn't follow the previous field", f.Name, f.Offset, f.Length)
+		}
+
+		offset += f.Length
+	}
+
+	if offset != len(m) {
+		t.Fatalf("the fields cover %d of the %d bytes", offset, len(m))
+	}
+}
+
+func TestDissect(t *testing.T) {
+	for _, c := range confs {
+		conf := *c.Conf
+		conf.KSF = 0
+
+		d, err := conf.Deserializer()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for typ, m := range capturedMessages(t, &conf) {
+			fields, err := d.Dissect(typ, m)
+			if err != nil {
+				t.Fatalf("%v: %v", typ, err)
+			}
+
+			checkCoverage(t, fields, m)
+
+			found := false
+
+			for _, guessed := range d.MessageTypes(m) {
+				found = found || guessed == typ
+			}
+
+			if !found {
+				t.Fatalf("%v is not among the types guessed from its length", typ)
+			}
+		}
+	}
+}
+
+func TestDissect_Malformed(t *testing.T) {
+	conf := *opaque.DefaultConfiguration()
+	conf.KSF = 0
+
+	d, _ := conf.Deserializer()
+	messages := capturedMessages(t, &conf)
+	ke2 := messages[opaque.KE2Message]
+
+	// A truncated message is dissected up to its end, and the error names the truncated field.
+	truncated := ke2[:len(ke2)-10]
+
+	fields, err := d.Dissect(opaque.KE2Message, truncated)
+
+	var lengthErr *opaque.LengthError
+	if !errors.As(err, &lengthErr) || lengthErr.Field != "server MAC" {
+		t.Fatalf("expected a length error on the server MAC, got %v", err)
+	}
+
+	checkCoverage(t, fields, truncated)
+
+	if last := fields[len(fields)-1]; last.Name != "server MAC" || last.Length != lengthErr.Received {
+		t.Fatalf("unexpected last field %v", last)
+	}
+
+	// Bytes past the last field are trailing data, or the application message of KE1 and KE2.
+	ke3 := append(append([]byte(nil), messages[opaque.KE3Message]...), 1, 2)
+
+	fields, err = d.Dissect(opaque.KE3Message, ke3)
+	if err == nil || fields[len(fields)-1].Name != "trailing data" {
+		t.Fatalf("expected trailing data and an error, got %v", err)
+	}
+
+	checkCoverage(t, fields, ke3)
+
+	ke1 := append(append([]byte(nil), messages[opaque.KE1Message]...), 0, 2, 'h', 'i')
+
+	fields, err = d.Dissect(opaque.KE1Message, ke1)
+	if err != nil || fields[len(fields)-1].Name != "application message" {
+		t.Fatalf("expected an application message, got %v", err)
+	}
+
+	// An invalid element is reported, with the fields.
+	request := bytes.Repeat([]byte{0xff}, len(messages[opaque.RegistrationRequestMessage]))
+
+	fields, err = d.Dissect(opaque.RegistrationRequestMessage, request)
+	if err == nil || len(fields) != 1 {
+		t.Fatal("expected an error on an invalid element")
+	}
+
+	if _, err = d.Dissect(opaque.MessageType(0), ke1); err == nil {
+		t.Fatal("expected an error on an unknown message type")
+	}
+
+	if types := d.MessageTypes([]byte{1, 2, 3}); len(types) != 0 {
+		t.Fatalf("unexpected types %v", types)
+	}
+}
+
+func TestMessageField_String(t *testing.T) {
+	f := opaque.MessageField{Name: "nonce", Offset: 2, Length: 2, Value: []byte{0xab, 0xcd}}
+
+	if s := f.String(); !bytes.Contains([]byte(s), []byte("nonce")) || !bytes.HasSuffix([]byte(s), []byte("abcd")) {
+		t.Fatalf("unexpected field description %q", s)
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+func TestDump(t *testing.T) {
+	conf := *opaque.DefaultConfiguration()
+	conf.KSF = 0
+
+	d, _ := conf.Deserializer()
+	messages := capturedMessages(t, &conf)
+
+	record, err := d.RegistrationRecord(messages[opaque.RegistrationRecordMessage])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke2, err := d.KE2(messages[opaque.KE2Message])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, err := d.KE3(messages[opaque.KE3Message])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for typ, msg := range map[opaque.MessageType]message.Serializer{
+		opaque.RegistrationRecordMessage: record,
+		opaque.KE2Message:                ke2,
+		opaque.KE3Message:                ke3,
+	} {
+		var out bytes.Buffer
+		if err = opaque.Dump(&out, msg, &conf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := msg.Serialize()
+		fields, _ := d.Dissect(typ, m)
+
+		for _, f := range fields {
+			printed := strings.Contains(out.String(), hex.EncodeToString(f.Value))
+			if f.Secret == printed {
+				t.Fatalf("field %q is secret %t but printed %t:\n%s", f.Name, f.Secret, printed, out.String())
+			}
+		}
+	}
+}
+
+func TestDump_Errors(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+
+	var out bytes.Buffer
+
+	if err := opaque.Dump(&out, nil, conf); err == nil {
+		t.Fatal("expected an error on a nil message")
+	}
+
+	if err := opaque.Dump(&out, (*message.KE1)(nil), conf); err == nil {
+		t.Fatal("expected an error on a nil message")
+	}
+
+	if err := opaque.Dump(&out, &message.CredentialRequest{}, conf); err == nil {
+		t.Fatal("expected an error on a message that is not sent on its own")
+	}
+
+	// A malformed message is dumped, followed by its error.
+	ke4 := &message.KE4{Mac: []byte{1, 2, 3}}
+
+	if err := opaque.Dump(&out, ke4, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "error:") || !strings.Contains(out.String(), "<3 bytes") {
+		t.Fatalf("unexpected dump of a malformed message:\n%s", out.String())
+	}
+}
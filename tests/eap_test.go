@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaqueeap"
+)
+
+func TestEAP(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	run := func(password []byte, mtu int) (*opaqueeap.Peer, *opaqueeap.Authenticator, error) {
+		peer, err := opaqueeap.NewPeer(conf, record.CredentialIdentifier, password, test.username, test.serverID, mtu)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		auth, err := opaqueeap.NewAuthenticator(&opaqueeap.AuthenticatorConfig{
+			Configuration: conf,
+			Lookup: func(credentialIdentifier []byte) (*opaque.ClientRecord, error) {
+				return record, nil
+			},
+			ServerIdentity:  test.serverID,
+			ServerSecretKey: test.serverSecretKey,
+			ServerPublicKey: test.serverPublicKey,
+			OPRFSeed:        test.oprfSeed,
+			MTU:             mtu,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := auth.Start()
+
+		for i := 0; i < 100; i++ {
+			if len(req) > mtu && mtu != 0 {
+				t.Fatalf("packet of %d bytes exceeds the MTU", len(req))
+			}
+
+			resp, err := peer.Handle(req)
+			if err != nil || resp == nil {
+				return peer, auth, err
+			}
+
+			if req, err = auth.Handle(resp); err != nil {
+				if _, perr := peer.Handle(req); !errors.Is(perr, opaqueeap.ErrAuthentication) {
+					t.Fatalf("expected the peer to receive a failure, got %v", perr)
+				}
+
+				return peer, auth, err
+			}
+		}
+
+		t.Fatal("EAP exchange did not terminate")
+
+		return nil, nil, nil
+	}
+
+	for _, mtu := range []int{0, 40} {
+		peer, auth, err := run(test.password, mtu)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !peer.Done() || !auth.Done() {
+			t.Fatal("authentication did not complete")
+		}
+
+		if len(peer.MSK()) != opaqueeap.KeyLength || !bytes.Equal(peer.MSK(), auth.MSK()) ||
+			!bytes.Equal(peer.EMSK(), auth.EMSK()) || bytes.Equal(peer.MSK(), peer.EMSK()) {
+			t.Fatal("unexpected exported keys")
+		}
+
+		if !bytes.Equal(auth.CredentialIdentifier(), record.CredentialIdentifier) {
+			t.Fatal("unexpected peer identity")
+		}
+	}
+
+	peer, auth, err := run([]byte("wrong"), 0)
+	if !errors.Is(err, opaqueeap.ErrAuthentication) || peer.Done() || auth.Done() || auth.MSK() != nil {
+		t.Fatalf("expected authentication failure, got %v", err)
+	}
+
+	if _, err := opaqueeap.NewPeer(conf, nil, nil, nil, nil, 8); err == nil {
+		t.Fatal("expected error on a too small MTU")
+	}
+}
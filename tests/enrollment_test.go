@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestDeviceEnrollment(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	tokenKey := randomBytes(32)
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+	account := &opaque.Account{Identity: []byte("alice")}
+
+	// The first device is registered and logs in, and gets its export key.
+	test := &testParams{
+		Configuration:   conf,
+		username:        account.Identity,
+		userID:          account.Identity,
+		password:        []byte("password"),
+		oprfSeed:        oprfSeed,
+		serverSecretKey: sks,
+		serverPublicKey: pks,
+	}
+	record, _ := testRegistration(t, test)
+	exportKey := testAuthentication(t, test, record)
+
+	if err := account.AddDevice(record); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server issues an enrollment token to the logged-in device, which builds and seals the bundle.
+	token, err := opaque.NewEnrollmentToken(tokenKey, account.Identity, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := opaque.NewEnrollmentBundle(token, exportKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, transferKey, err := bundle.Seal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = opaque.OpenEnrollmentBundle(sealed, randomBytes(32))
+	if !errors.Is(err, opaque.ErrEnrollmentBundle) {
+		t.Fatalf("expected error on wrong transfer key, got %v", err)
+	}
+
+	// The new device opens the bundle, and registers with the device password and the token's credential identifier.
+	opened, err := opaque.OpenEnrollmentBundle(sealed, transferKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountKey, _ := opaque.AccountKey(exportKey)
+	if !bytes.Equal(opened.AccountKey, accountKey) {
+		t.Fatal("account keys differ")
+	}
+
+	_, deviceID, err := opaque.VerifyEnrollmentToken(tokenKey, opened.Token, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+	r2, _ := server.RegistrationResponse(client.RegistrationInit(opened.DevicePassword), pk, deviceID, oprfSeed)
+	r3, _, _ := client.RegistrationFinalize(r2, account.Identity, nil)
+	newDevice := &opaque.ClientRecord{
+		CredentialIdentifier: deviceID,
+		ClientIdentity:       account.Identity,
+		RegistrationRecord:   r3,
+	}
+
+	if err := account.EnrollDevice(tokenKey, opened.Token, newDevice, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	err = account.EnrollDevice(tokenKey, opened.Token, newDevice, time.Now())
+	if !errors.Is(err, opaque.ErrDeviceExists) {
+		t.Fatalf("expected error on double enrollment, got %v", err)
+	}
+
+	// The new device logs in with its own record.
+	test.password = opened.DevicePassword
+	d, err := account.Device(deviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testAuthentication(t, test, d)
+
+	if err := account.RemoveDevice(deviceID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := account.Device(deviceID); !errors.Is(err, opaque.ErrDeviceNotFound) {
+		t.Fatalf("expected error on removed device, got %v", err)
+	}
+
+	// A record registered with another identity is refused, and left as is.
+	other := &opaque.ClientRecord{
+		CredentialIdentifier: deviceID,
+		ClientIdentity:       []byte("bob"),
+		RegistrationRecord:   r3,
+	}
+
+	if err := account.AddDevice(other); !errors.Is(err, opaque.ErrAccountIdentity) {
+		t.Fatalf("expected error on another client identity, got %v", err)
+	}
+
+	if !bytes.Equal(other.ClientIdentity, []byte("bob")) {
+		t.Fatal("expected the record to be unchanged")
+	}
+}
+
+func TestEnrollmentToken_Invalid(t *testing.T) {
+	tokenKey := randomBytes(32)
+
+	token, err := opaque.NewEnrollmentToken(tokenKey, []byte("alice"), time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = opaque.VerifyEnrollmentToken(tokenKey, token, time.Now())
+	if !errors.Is(err, opaque.ErrEnrollmentToken) {
+		t.Fatalf("expected error on expired token, got %v", err)
+	}
+
+	token, _ = opaque.NewEnrollmentToken(tokenKey, []byte("alice"), time.Now().Add(time.Minute))
+	token[len(token)-1] ^= 0xff
+
+	_, _, err = opaque.VerifyEnrollmentToken(tokenKey, token, time.Now())
+	if !errors.Is(err, opaque.ErrEnrollmentToken) {
+		t.Fatalf("expected error on tampered token, got %v", err)
+	}
+
+	token, _ = opaque.NewEnrollmentToken(tokenKey, []byte("alice"), time.Now().Add(time.Minute))
+	account := &opaque.Account{Identity: []byte("bob")}
+	record := &opaque.ClientRecord{CredentialIdentifier: token[7 : 7+32]}
+
+	if err := account.EnrollDevice(tokenKey, token, record, time.Now()); !errors.Is(err, opaque.ErrEnrollmentToken) {
+		t.Fatalf("expected error on token for another account, got %v", err)
+	}
+
+	if _, err := opaque.NewEnrollmentToken(nil, nil, time.Now()); err == nil {
+		t.Fatal("expected error on invalid token key")
+	}
+}
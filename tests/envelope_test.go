@@ -0,0 +1,349 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/vectors"
+)
+
+func externalConfiguration(c *opaque.Configuration) *opaque.Configuration {
+	conf := *c
+	conf.Mode = opaque.External
+
+	return &conf
+}
+
+func TestExternalMode(t *testing.T) {
+	for _, c := range confs {
+		conf := externalConfiguration(c.Conf)
+		test := newTestParams(t, conf)
+
+		record, exportKeyReg := testRegistration(t, test)
+
+		server, _ := conf.Server()
+		scalarLength := encoding.ScalarLength[server.GetConf().Group]
+		if len(record.Envelope) != internal.NonceLength+scalarLength+server.GetConf().MAC.Size() {
+			t.Fatalf("unexpected external envelope length %d", len(record.Envelope))
+		}
+
+		exportKeyLogin := testAuthentication(t, test, record)
+		if !bytes.Equal(exportKeyReg, exportKeyLogin) {
+			t.Fatal("export keys differ")
+		}
+
+		// A wrong password must not recover the key.
+		client, _ := conf.Client()
+		ke2, err := server.LoginInit(client.LoginInit([]byte("wrong")), test.serverID, test.serverSecretKey,
+			test.serverPublicKey, test.oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := client.LoginFinish(test.username, test.serverID, ke2); err == nil {
+			t.Fatal("expected error on wrong password")
+		}
+	}
+}
+
+func TestExternalMode_ClientKey(t *testing.T) {
+	password := []byte("password")
+
+	for _, c := range confs {
+		conf := externalConfiguration(c.Conf)
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+		sks, pks, _ := conf.KeyGen()
+		skc, pkc, _ := conf.KeyGen()
+		oprfSeed, _ := conf.GenerateOPRFSeed()
+
+		pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+		r2, _ := server.RegistrationResponse(client.RegistrationInit(password), pk, nil, oprfSeed)
+
+		record, _, err := client.RegistrationFinalizeWithClientKey(r2, nil, nil, skc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(encoding.SerializePoint(record.PublicKey, server.GetConf().Group), pkc) {
+			t.Fatal("record does not hold the client's public key")
+		}
+
+		// In the external mode, the key is recovered from the envelope, without supplying it again.
+		client, _ = conf.Client()
+		ke2, err := server.LoginInit(client.LoginInit(password), nil, sks, pks, oprfSeed,
+			&opaque.ClientRecord{RegistrationRecord: record})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(nil, nil, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExternalMode_Configuration(t *testing.T) {
+	conf := externalConfiguration(opaque.DefaultConfiguration())
+
+	decoded, err := opaque.DeserializeConfiguration(conf.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Mode != opaque.External {
+		t.Fatalf("expected external mode, got %v", decoded.Mode)
+	}
+
+	record, err := conf.GetFakeRecord(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := conf.Deserializer()
+	if _, err := d.RegistrationRecord(record.Serialize()); err != nil {
+		t.Fatalf("fake record in external mode is not valid: %v", err)
+	}
+}
+
+func TestAppData(t *testing.T) {
+	password := []byte("password")
+	appData := []byte("kdf=argon2id;flags=2fa")
+
+	for _, c := range confs {
+		for _, mode := range []opaque.EnvelopeMode{opaque.Internal, opaque.External} {
+			conf := *c.Conf
+			conf.Mode = mode
+			conf.AppDataLength = 32
+
+			client, _ := conf.Client()
+			server, _ := conf.Server()
+			sks, pks, _ := conf.KeyGen()
+			oprfSeed, _ := conf.GenerateOPRFSeed()
+
+			pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+			r2, _ := server.RegistrationResponse(client.RegistrationInit(password), pk, nil, oprfSeed)
+
+			record, _, err := client.RegistrationFinalizeWithOptions(r2, nil, nil,
+				&opaque.RegistrationOptions{AppData: appData})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(record.Envelope) != server.GetConf().EnvelopeSize {
+				t.Fatalf("unexpected envelope length %d", len(record.Envelope))
+			}
+
+			d, _ := conf.Deserializer()
+			record, err = d.RegistrationRecord(record.Serialize())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client, _ = conf.Client()
+			ke1, _ := d.KE1(client.LoginInit(password).Serialize())
+			ke2, err := server.LoginInit(ke1, nil, sks, pks, oprfSeed, &opaque.ClientRecord{RegistrationRecord: record})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ke2, err = d.KE2(ke2.Serialize())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := client.LoginFinish(nil, nil, ke2); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(client.AppData(), appData) {
+				t.Fatalf("unexpected application data %q", client.AppData())
+			}
+		}
+	}
+}
+
+func TestAppData_TooLong(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.AppDataLength = 4
+
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	_, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+
+	pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+	r2, _ := server.RegistrationResponse(client.RegistrationInit([]byte("yo")), pk, nil, oprfSeed)
+
+	if _, _, err := client.RegistrationFinalizeWithOptions(r2, nil, nil,
+		&opaque.RegistrationOptions{AppData: []byte("too long")}); err == nil ||
+		err.Error() != "application data is longer than the configured length" {
+		t.Fatalf("expected error on too long application data, got %v", err)
+	}
+
+	decoded, err := opaque.DeserializeConfiguration(conf.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.AppDataLength != 4 {
+		t.Fatalf("unexpected application data length %d", decoded.AppDataLength)
+	}
+}
+
+func TestAppData_LengthExceedsKDF(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.KDF = crypto.SHA256
+	conf.AppDataLength = uint16(255*crypto.SHA256.Size() - 2)
+
+	if _, err := conf.Client(); err != nil {
+		t.Fatalf("unexpected error on the maximum application data length: %v", err)
+	}
+
+	conf.AppDataLength++
+
+	if _, err := conf.Client(); err == nil || err.Error() != "application data length exceeds the KDF output" {
+		t.Fatalf("expected error on application data length exceeding the KDF output, got %v", err)
+	}
+
+	if _, err := opaque.DeserializeConfiguration(conf.Serialize()); err == nil {
+		t.Fatal("expected error decoding a configuration with an application data length exceeding the KDF output")
+	}
+}
+
+func TestBuildRecoverEnvelope(t *testing.T) {
+	for _, c := range confs {
+		for _, conf := range []*opaque.Configuration{c.Conf, externalConfiguration(c.Conf)} {
+			conf := *conf
+			conf.AppDataLength = 16
+			_, serverPublicKey, _ := conf.KeyGen()
+			clientSecretKey, clientPublicKey, _ := conf.KeyGen()
+
+			randomizedPwd, err := conf.RandomizedPassword([]byte("oprf output"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, sk := range [][]byte{nil, clientSecretKey} {
+				env, pk, maskingKey, exportKey, err := conf.BuildEnvelope(randomizedPwd, serverPublicKey, sk,
+					[]byte("data"), []byte("client"), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if length, _ := conf.EnvelopeLength(); len(env) != length || len(maskingKey) != conf.KDF.Size() {
+					t.Fatal("unexpected lengths")
+				}
+
+				if sk != nil && !bytes.Equal(pk, clientPublicKey) {
+					t.Fatal("the given client key is not used")
+				}
+
+				recoveredSK, recoveredPK, recoveredExportKey, appData, err := conf.RecoverEnvelope(randomizedPwd,
+					serverPublicKey, sk, []byte("client"), nil, env)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(recoveredPK, pk) || !bytes.Equal(recoveredExportKey, exportKey) ||
+					!bytes.Equal(appData, []byte("data")) {
+					t.Fatal("recovered values do not match")
+				}
+
+				if sk != nil && !bytes.Equal(recoveredSK, sk) {
+					t.Fatal("recovered secret key does not match")
+				}
+
+				// The identities are bound to the envelope.
+				if _, _, _, _, err := conf.RecoverEnvelope(randomizedPwd, serverPublicKey, sk, nil, nil,
+					env); err == nil {
+					t.Fatal("expected error on different client identity")
+				}
+
+				other, _ := conf.RandomizedPassword([]byte("other output"))
+				if _, _, _, _, err := conf.RecoverEnvelope(other, serverPublicKey, sk, []byte("client"), nil,
+					env); err == nil {
+					t.Fatal("expected error on wrong randomized password")
+				}
+			}
+		}
+	}
+}
+
+func TestBuildRecoverEnvelope_Errors(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	_, serverPublicKey, _ := conf.KeyGen()
+	randomizedPwd, _ := conf.RandomizedPassword([]byte("oprf output"))
+
+	if _, _, _, _, err := conf.BuildEnvelope(randomizedPwd[1:], serverPublicKey, nil, nil, nil,
+		nil); !errors.Is(err, opaque.ErrInvalidRandomizedPassword) {
+		t.Fatalf("expected %q, got %v", opaque.ErrInvalidRandomizedPassword, err)
+	}
+
+	if _, _, _, _, err := conf.BuildEnvelope(randomizedPwd, make([]byte, len(serverPublicKey)), nil, nil, nil,
+		nil); err == nil {
+		t.Fatal("expected error on invalid server public key")
+	}
+
+	if _, _, _, _, err := conf.BuildEnvelope(randomizedPwd, serverPublicKey, []byte("short"), nil, nil,
+		nil); err == nil {
+		t.Fatal("expected error on invalid client secret key")
+	}
+
+	if _, _, _, _, err := conf.BuildEnvelope(randomizedPwd, serverPublicKey, nil, []byte("data"), nil,
+		nil); err == nil {
+		t.Fatal("expected error on application data without a slot")
+	}
+
+	if _, _, _, _, err := conf.RecoverEnvelope(randomizedPwd, serverPublicKey, nil, nil, nil,
+		[]byte("short")); !errors.Is(err, opaque.ErrInvalidEnvelopeLength) {
+		t.Fatalf("expected %q, got %v", opaque.ErrInvalidEnvelopeLength, err)
+	}
+}
+
+func TestRecoverEnvelope_Vectors(t *testing.T) {
+	v, err := vectors.Draft()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tv := range v {
+		if fake, _ := tv.IsFake(); fake {
+			continue
+		}
+
+		conf, err := tv.Configuration()
+		if err != nil {
+			continue
+		}
+
+		in := tv.Inputs
+		_, pk, exportKey, _, err := conf.RecoverEnvelope(tv.Intermediates.RandomPWD, in.ServerPublicKey, nil,
+			in.ClientIdentity, in.ServerIdentity, tv.Intermediates.Envelope)
+		if err != nil {
+			t.Fatalf("%s: %v", tv.Name(), err)
+		}
+
+		if !bytes.Equal(pk, tv.Intermediates.ClientPublicKey) || !bytes.Equal(exportKey, tv.Outputs.ExportKey) {
+			t.Fatalf("%s: recovered values do not match", tv.Name())
+		}
+	}
+}
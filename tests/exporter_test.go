@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExporter(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		// Nothing is exported before the login succeeds.
+		if _, err := client.Exporter("label", nil, 32); err == nil {
+			t.Fatal("expected error on client exporter before login")
+		}
+
+		ke1 := client.LoginInit(test.password)
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := server.Exporter("label", nil, 32); err == nil {
+			t.Fatal("expected error on server exporter before LoginFinish")
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		clientOut, err := client.Exporter("label", []byte("context"), 48)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serverOut, err := server.Exporter("label", []byte("context"), 48)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(clientOut) != 48 || !bytes.Equal(clientOut, serverOut) {
+			t.Fatal("client and server exported different values")
+		}
+
+		if bytes.HasPrefix(clientOut, client.SessionKey()) {
+			t.Fatal("exported value is the session key")
+		}
+
+		otherLabel, _ := client.Exporter("other", []byte("context"), 48)
+		otherContext, _ := client.Exporter("label", []byte("other"), 48)
+		noContext, _ := client.Exporter("label", nil, 48)
+		shorter, _ := client.Exporter("label", []byte("context"), 32)
+
+		for _, other := range [][]byte{otherLabel, otherContext, noContext, shorter} {
+			if bytes.Equal(clientOut, other) {
+				t.Fatal("expected different exported values")
+			}
+		}
+
+		if bytes.Equal(clientOut[:32], shorter) {
+			t.Fatal("the output length must be bound to the exported value")
+		}
+
+		for _, length := range []int{0, -1, 255*len(client.SessionKey()) + 1} {
+			if _, err := client.Exporter("label", nil, length); err == nil {
+				t.Fatalf("expected error on length %d", length)
+			}
+		}
+
+		if _, err := client.Exporter("", nil, 32); err == nil {
+			t.Fatal("expected error on empty label")
+		}
+
+		if _, err := client.Exporter(string(make([]byte, 256)), nil, 32); err == nil {
+			t.Fatal("expected error on long label")
+		}
+	}
+}
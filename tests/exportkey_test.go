@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestExportKey_DeriveKey(t *testing.T) {
+	exportKey := opaque.ExportKey(randomBytes(64))
+
+	vault, err := exportKey.DeriveKey("vault-encryption", nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vault) != 32 {
+		t.Fatalf("unexpected derived key length %d", len(vault))
+	}
+
+	again, _ := exportKey.DeriveKey("vault-encryption", nil, 32)
+	if !bytes.Equal(vault, again) {
+		t.Fatal("derivation is not deterministic")
+	}
+
+	signing, _ := exportKey.DeriveKey("token-signing", nil, 32)
+	if bytes.Equal(vault, signing) {
+		t.Fatal("different labels yield the same key")
+	}
+
+	withContext, _ := exportKey.DeriveKey("vault-encryption", []byte("device-1"), 32)
+	if bytes.Equal(vault, withContext) {
+		t.Fatal("different contexts yield the same key")
+	}
+}
+
+func TestExportKey_DeriveKeyErrors(t *testing.T) {
+	exportKey := opaque.ExportKey(randomBytes(64))
+
+	if _, err := opaque.ExportKey(nil).DeriveKey("label", nil, 32); err == nil ||
+		err.Error() != "export key is empty" {
+		t.Fatalf("expected error on empty export key, got %v", err)
+	}
+
+	if _, err := exportKey.DeriveKey("", nil, 32); err == nil ||
+		err.Error() != "export key derivation label is empty" {
+		t.Fatalf("expected error on empty label, got %v", err)
+	}
+
+	for _, length := range []int{0, -1, 255*64 + 1} {
+		if _, err := exportKey.DeriveKey("label", nil, length); err == nil ||
+			err.Error() != "invalid export key derivation length" {
+			t.Fatalf("expected error on length %d, got %v", length, err)
+		}
+	}
+}
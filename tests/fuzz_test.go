@@ -10,11 +10,9 @@ package opaque_test
 
 import (
 	"crypto"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"testing"
 
@@ -24,6 +22,7 @@ import (
 	"github.com/bytemare/opaque"
 	"github.com/bytemare/opaque/internal"
 	"github.com/bytemare/opaque/internal/oprf"
+	"github.com/bytemare/opaque/vectors"
 )
 
 const (
@@ -105,21 +104,6 @@ func fuzzServerConfiguration(t *testing.T, c *opaque.Configuration) *opaque.Serv
 	return server
 }
 
-func fuzzLoadVectors(path string) ([]*vector, error) {
-	contents, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("no vectors to read: %v", err)
-	}
-
-	var v []*vector
-	err = json.Unmarshal(contents, &v)
-	if err != nil {
-		return nil, fmt.Errorf("no vectors to read: %v", err)
-	}
-
-	return v, nil
-}
-
 func FuzzConfiguration(f *testing.F) {
 	// seed corpus
 	loadVectorSeedCorpus(f, "")
@@ -141,13 +125,13 @@ func FuzzConfiguration(f *testing.F) {
 
 func loadVectorSeedCorpus(f *testing.F, stage string) {
 	// seed corpus
-	vectors, err := fuzzLoadVectors("vectors.json")
+	draft, err := vectors.Draft()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	for _, v := range vectors {
-		var input ByteToHex
+	for _, v := range draft {
+		var input []byte
 		switch stage {
 		case "":
 			input = nil
@@ -167,14 +151,19 @@ func loadVectorSeedCorpus(f *testing.F, stage string) {
 			panic(nil)
 		}
 
-		f.Add([]byte(input),
-			[]byte(v.Config.Context),
-			uint(kdfToHash(v.Config.KDF)),
-			uint(macToHash(v.Config.MAC)),
-			uint(hashToHash(v.Config.Hash)),
-			v.Config.OPRF[1],
-			byte(ksfToKSF(v.Config.KSF)),
-			byte(groupToGroup(v.Config.Group)),
+		c, err := v.Configuration()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		f.Add(input,
+			[]byte(c.Context),
+			uint(c.KDF),
+			uint(c.MAC),
+			uint(c.Hash),
+			byte(c.OPRF),
+			byte(c.KSF),
+			byte(c.AKE),
 		)
 	}
 
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+// zeroReader is a broken random number generator, always returning zeroes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
+
+func sameKE1(a, b *message.KE1) bool {
+	return bytes.Equal(a.NonceU, b.NonceU) || bytes.Equal(a.EpkU.Bytes(), b.EpkU.Bytes()) ||
+		bytes.Equal(a.BlindedMessage.Bytes(), b.BlindedMessage.Bytes())
+}
+
+func TestHedgedRandomness(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	client1, _ := conf.Client()
+	client2, _ := conf.Client()
+
+	reader := rand.Reader
+	rand.Reader = zeroReader{}
+
+	defer func() {
+		rand.Reader = reader
+	}()
+
+	// Instances created before the failure differ by their instance secret.
+	ke1a := client1.LoginInit(test.password)
+	ke1b := client2.LoginInit(test.password)
+
+	if sameKE1(ke1a, ke1b) {
+		t.Fatal("expected different values for different instances")
+	}
+
+	// Instances sharing their secret differ by the values of the session.
+	client3, _ := conf.Client()
+	client4, _ := conf.Client()
+
+	if sameKE1(client3.LoginInit(test.password), client4.LoginInit([]byte("other"))) {
+		t.Fatal("expected different values for different passwords")
+	}
+
+	server1, _ := conf.Server()
+	server2, _ := conf.Server()
+
+	ke2a, err := server1.LoginInit(ke1a, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+		record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke2b, err := server2.LoginInit(ke1b, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+		record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(ke2a.NonceS, ke2b.NonceS) || bytes.Equal(ke2a.EpkS.Bytes(), ke2b.EpkS.Bytes()) ||
+		bytes.Equal(ke2a.MaskingNonce, ke2b.MaskingNonce) {
+		t.Fatal("expected different values for different KE1")
+	}
+
+	// The protocol still succeeds.
+	ke3, _, err := client1.LoginFinish(test.username, test.serverID, ke2a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server1.LoginFinish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(client1.SessionKey(), server1.SessionKey()) {
+		t.Fatal("expected equal session keys")
+	}
+}
@@ -30,6 +30,43 @@ import (
 
 // helper functions
 
+// newTestParams returns the parameters of a "client" registering the "password" with a "server", with a fresh OPRF
+// seed and server key pair for the configuration.
+func newTestParams(t *testing.T, conf *opaque.Configuration) *testParams {
+	t.Helper()
+
+	oprfSeed, err := conf.GenerateOPRFSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sks, pks, err := conf.KeyGen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testParams{
+		Configuration:   conf,
+		username:        []byte("client"),
+		userID:          []byte("client"),
+		serverID:        []byte("server"),
+		password:        []byte("password"),
+		oprfSeed:        oprfSeed,
+		serverSecretKey: sks,
+		serverPublicKey: pks,
+	}
+}
+
+// registeredTestParams returns the parameters of newTestParams, and the record of their registration.
+func registeredTestParams(t *testing.T, conf *opaque.Configuration) (*testParams, *opaque.ClientRecord) {
+	t.Helper()
+
+	test := newTestParams(t, conf)
+	record, _ := testRegistration(t, test)
+
+	return test, record
+}
+
 type configuration struct {
 	Conf  *opaque.Configuration
 	Curve elliptic.Curve
@@ -87,6 +124,16 @@ var confs = []configuration{
 	//},
 }
 
+// randomBytes returns random bytes of the given length, and panics if the random number generator fails.
+func randomBytes(length int) []byte {
+	r, err := internal.RandomBytes(length)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
 func getBadRistrettoScalar() []byte {
 	a := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
 	decoded, _ := hex.DecodeString(a)
@@ -129,7 +176,7 @@ func badScalar(t *testing.T, g group.Group, curve elliptic.Curve) []byte {
 
 func getBadNistElement(t *testing.T, id group.Group) []byte {
 	size := encoding.PointLength[id]
-	element := internal.RandomBytes(size)
+	element := randomBytes(size)
 	// detag compression
 	element[0] = 4
 
@@ -175,14 +222,13 @@ func buildRecord(
 	if err != nil {
 		panic(err)
 	}
-	r2 := server.RegistrationResponse(r1, pk, credID, oprfSeed)
-	r3, _ := client.RegistrationFinalize(r2, nil, nil)
+	r2, _ := server.RegistrationResponse(r1, pk, credID, oprfSeed)
+	r3, _, _ := client.RegistrationFinalize(r2, nil, nil)
 
 	return &opaque.ClientRecord{
 		CredentialIdentifier: credID,
 		ClientIdentity:       nil,
 		RegistrationRecord:   r3,
-		TestMaskNonce:        nil,
 	}
 }
 
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+// hmqvFixture is a regression fixture for the HMQV AKE, with the Ristretto255-SHA512 suite and the identity KSF. Its
+// outputs were produced by this implementation, not computed independently, so it only detects changes to the HMQV
+// key schedule and is not a test vector for interoperability.
+type hmqvFixture struct {
+	Inputs struct {
+		BlindLogin            ByteToHex `json:"blind_login"`
+		BlindRegistration     ByteToHex `json:"blind_registration"`
+		ClientIdentity        ByteToHex `json:"client_identity"`
+		ClientNonce           ByteToHex `json:"client_nonce"`
+		ClientPrivateKeyshare ByteToHex `json:"client_private_keyshare"`
+		CredentialIdentifier  ByteToHex `json:"credential_identifier"`
+		EnvelopeNonce         ByteToHex `json:"envelope_nonce"`
+		MaskingNonce          ByteToHex `json:"masking_nonce"`
+		OprfSeed              ByteToHex `json:"oprf_seed"`
+		Password              ByteToHex `json:"password"`
+		ServerIdentity        ByteToHex `json:"server_identity"`
+		ServerNonce           ByteToHex `json:"server_nonce"`
+		ServerPrivateKey      ByteToHex `json:"server_private_key"`
+		ServerPrivateKeyshare ByteToHex `json:"server_private_keyshare"`
+		ServerPublicKey       ByteToHex `json:"server_public_key"`
+	} `json:"inputs"`
+	Outputs struct {
+		KE1        ByteToHex `json:"KE1"`
+		KE2        ByteToHex `json:"KE2"`
+		KE3        ByteToHex `json:"KE3"`
+		ExportKey  ByteToHex `json:"export_key"`
+		SessionKey ByteToHex `json:"session_key"`
+	} `json:"outputs"`
+}
+
+func (v *hmqvFixture) test(t *testing.T) {
+	conf := &opaque.Configuration{
+		OPRF:     opaque.RistrettoSha512,
+		KDF:      crypto.SHA512,
+		MAC:      crypto.SHA512,
+		Hash:     crypto.SHA512,
+		AKE:      opaque.RistrettoSha512,
+		Protocol: opaque.HMQV,
+	}
+	in := v.Inputs
+
+	// Registration
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+
+	if err := client.SetKnownAnswers(&opaque.KnownAnswers{
+		Blind:         in.BlindRegistration,
+		EnvelopeNonce: in.EnvelopeNonce,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pk, _ := server.Deserialize.DecodeAkePublicKey(in.ServerPublicKey)
+	req := client.RegistrationInit(in.Password)
+	resp, _ := server.RegistrationResponse(req, pk, in.CredentialIdentifier, in.OprfSeed)
+	upload, _, _ := client.RegistrationFinalize(resp, in.ClientIdentity, in.ServerIdentity)
+	record := &opaque.ClientRecord{
+		CredentialIdentifier: in.CredentialIdentifier,
+		ClientIdentity:       in.ClientIdentity,
+		RegistrationRecord:   upload,
+	}
+
+	// Login
+	client, _ = conf.Client()
+
+	if err := client.SetKnownAnswers(&opaque.KnownAnswers{
+		Blind:              in.BlindLogin,
+		EphemeralSecretKey: in.ClientPrivateKeyshare,
+		Nonce:              in.ClientNonce,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ke1 := client.LoginInit(in.Password)
+
+	if !bytes.Equal(v.Outputs.KE1, ke1.Serialize()) {
+		t.Fatal("KE1 do not match")
+	}
+
+	server, _ = conf.Server()
+
+	if err := server.SetKnownAnswers(&opaque.KnownAnswers{
+		MaskingNonce:       in.MaskingNonce,
+		EphemeralSecretKey: in.ServerPrivateKeyshare,
+		Nonce:              in.ServerNonce,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ke2, err := server.LoginInit(ke1, in.ServerIdentity, in.ServerPrivateKey, in.ServerPublicKey, in.OprfSeed, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(v.Outputs.KE2, ke2.Serialize()) {
+		t.Fatal("KE2 do not match")
+	}
+
+	ke3, exportKey, err := client.LoginFinish(in.ClientIdentity, in.ServerIdentity, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(v.Outputs.KE3, ke3.Serialize()) {
+		t.Fatal("KE3 do not match")
+	}
+
+	if !bytes.Equal(v.Outputs.ExportKey, exportKey) {
+		t.Fatal("Client export keys do not match")
+	}
+
+	if !bytes.Equal(v.Outputs.SessionKey, client.SessionKey()) {
+		t.Fatal("Client session keys do not match")
+	}
+
+	if err := server.LoginFinish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(v.Outputs.SessionKey, server.SessionKey()) {
+		t.Fatal("Server session keys do not match")
+	}
+}
+
+func TestHMQVRegression(t *testing.T) {
+	contents, err := os.ReadFile("hmqvRegression.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fixtures []*hmqvFixture
+	if err := json.Unmarshal(contents, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range fixtures {
+		t.Run(fmt.Sprintf("Fixture %d", i), v.test)
+	}
+}
+
+func TestHMQV(t *testing.T) {
+	for _, c := range confs {
+		conf := *c.Conf
+		conf.Protocol = opaque.HMQV
+		test, record := registeredTestParams(t, &conf)
+		testAuthentication(t, test, record)
+
+		// The identities are bound into the exponents.
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+		ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+			test.serverPublicKey, test.oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := client.LoginFinish(test.username, []byte("other server"), ke2); err == nil {
+			t.Fatal("expected error with a different server identity")
+		}
+	}
+}
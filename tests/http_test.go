@@ -0,0 +1,629 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+	"github.com/bytemare/opaque/opaquehttp"
+)
+
+type httpMessage struct {
+	CredentialIdentifier []byte `json:"credential_identifier,omitempty"`
+	ClientIdentity       []byte `json:"client_identity,omitempty"`
+	Session              string `json:"session,omitempty"`
+	Message              []byte `json:"message"`
+	Cookie               []byte `json:"cookie,omitempty"`
+}
+
+func postJSON(t *testing.T, url string, in *httpMessage) (*httpMessage, int) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	out := new(httpMessage)
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return out, resp.StatusCode
+}
+
+func TestHTTPHandler(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+
+	var loggedIn []byte
+
+	h := &opaquehttp.Handler{
+		Configuration:   conf,
+		Credentials:     opaquehttp.NewMemoryCredentialStore(),
+		Sessions:        opaquehttp.NewMemorySessionStore(time.Minute),
+		ServerIdentity:  []byte("server"),
+		ServerSecretKey: sks,
+		ServerPublicKey: pks,
+		OPRFSeed:        oprfSeed,
+		OnLogin: func(w http.ResponseWriter, _ *http.Request, credentialIdentifier, _ []byte) {
+			loggedIn = credentialIdentifier
+			w.WriteHeader(http.StatusNoContent)
+		},
+	}
+
+	mux := http.NewServeMux()
+	h.Mount(mux, "/auth/")
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Configuration advertisement.
+	{
+		resp, err := http.Get(srv.URL + "/auth/configuration")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var params struct {
+			message.ConfigurationAdvertisement
+			ServerPublicKey []byte `json:"server_public_key"`
+			ServerIdentity  []byte `json:"server_identity"`
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&params)
+		resp.Body.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		policy := &opaque.ConfigurationPolicy{Fingerprints: [][]byte{conf.Fingerprint()}}
+		if _, err = policy.Accept(&params.ConfigurationAdvertisement); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(params.ServerPublicKey, pks) || string(params.ServerIdentity) != "server" {
+			t.Fatal("unexpected server parameters")
+		}
+
+		if _, status := postJSON(t, srv.URL+"/auth/configuration", &httpMessage{}); status != http.StatusMethodNotAllowed {
+			t.Fatalf("expected method not allowed, got %d", status)
+		}
+	}
+
+	credID := []byte("alice")
+	password := []byte("password")
+
+	// Registration.
+	register := func(credID, password []byte) (*httpMessage, *httpMessage) {
+		client, _ := conf.Client()
+		resp, status := postJSON(t, srv.URL+"/auth/register/init", &httpMessage{
+			CredentialIdentifier: credID,
+			Message:              client.RegistrationInit(password).Serialize(),
+		})
+
+		if status != http.StatusOK || resp.Session == "" {
+			t.Fatalf("unexpected status %d", status)
+		}
+
+		r2, err := client.Deserialize.RegistrationResponse(resp.Message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r3, _, _ := client.RegistrationFinalize(r2, credID, []byte("server"))
+
+		return resp, &httpMessage{
+			Session:              resp.Session,
+			CredentialIdentifier: credID,
+			ClientIdentity:       credID,
+			Message:              r3.Serialize(),
+		}
+	}
+
+	// The record must be sent in the registration session opened for its credential identifier.
+	_, finish := register(credID, password)
+	finish.CredentialIdentifier = []byte("bob")
+
+	if _, status := postJSON(t, srv.URL+"/auth/register/finish", finish); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized on another credential identifier, got %d", status)
+	}
+
+	_, finish = register(credID, password)
+	finish.Session = ""
+
+	if _, status := postJSON(t, srv.URL+"/auth/register/finish", finish); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized without a session, got %d", status)
+	}
+
+	// A registration session can't finish a login.
+	resp, finish := register(credID, password)
+	if _, status := postJSON(t, srv.URL+"/auth/login/finish", &httpMessage{
+		Session: resp.Session,
+		Message: make([]byte, 64),
+	}); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized on a registration session, got %d", status)
+	}
+
+	_, finish = register(credID, password)
+	if _, status := postJSON(t, srv.URL+"/auth/register/finish", finish); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	// Sessions are single use.
+	if _, status := postJSON(t, srv.URL+"/auth/register/finish", finish); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized on a reused session, got %d", status)
+	}
+
+	_, finish = register(credID, password)
+
+	_, status := postJSON(t, srv.URL+"/auth/register/finish", finish)
+	if status != http.StatusConflict {
+		t.Fatalf("expected conflict on second registration, got %d", status)
+	}
+
+	// Registering again a registered credential identifier looks like a new registration if it is concealed, and the
+	// stored record is kept.
+	h.ConcealRegistered = true
+
+	_, finish = register(credID, []byte("other"))
+	if _, status = postJSON(t, srv.URL+"/auth/register/finish", finish); status != http.StatusNoContent {
+		t.Fatalf("expected a concealed second registration, got %d", status)
+	}
+
+	// Login.
+	login := func(password []byte) int {
+		client, _ := conf.Client()
+		resp, status := postJSON(t, srv.URL+"/auth/login/init", &httpMessage{
+			CredentialIdentifier: credID,
+			Message:              client.LoginInit(password).Serialize(),
+		})
+
+		if status != http.StatusOK {
+			t.Fatalf("unexpected status %d", status)
+		}
+
+		ke2, err := client.Deserialize.KE2(resp.Message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(credID, []byte("server"), ke2)
+		if err != nil {
+			return http.StatusUnauthorized
+		}
+
+		_, status = postJSON(t, srv.URL+"/auth/login/finish", &httpMessage{
+			Session: resp.Session,
+			Message: ke3.Serialize(),
+		})
+
+		return status
+	}
+
+	if status := login(password); status != http.StatusNoContent || !bytes.Equal(loggedIn, credID) {
+		t.Fatalf("login failed with status %d", status)
+	}
+
+	if status := login([]byte("wrong")); status != http.StatusUnauthorized {
+		t.Fatalf("expected login failure, got %d", status)
+	}
+
+	// Unknown sessions are rejected.
+	if _, status = postJSON(t, srv.URL+"/auth/login/finish", &httpMessage{
+		Session: "unknown",
+		Message: make([]byte, 64),
+	}); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized, got %d", status)
+	}
+
+	// Unknown credentials are answered like registered ones.
+	client, _ := conf.Client()
+	if _, status = postJSON(t, srv.URL+"/auth/login/init", &httpMessage{
+		CredentialIdentifier: []byte("bob"),
+		Message:              client.LoginInit(password).Serialize(),
+	}); status != http.StatusOK {
+		t.Fatalf("unexpected status %d for unknown credential", status)
+	}
+
+	// Beyond the limit of pending sessions, logins are rejected with 503.
+	sessions := opaquehttp.NewMemorySessionStore(time.Minute)
+	sessions.SetLimit(1, opaquehttp.RejectNew)
+	h.Sessions = sessions
+
+	for _, expected := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		client, _ = conf.Client()
+		if _, status = postJSON(t, srv.URL+"/auth/login/init", &httpMessage{
+			CredentialIdentifier: credID,
+			Message:              client.LoginInit(password).Serialize(),
+		}); status != expected {
+			t.Fatalf("expected status %d, got %d", expected, status)
+		}
+	}
+}
+
+func TestMemorySessionStore_Limit(t *testing.T) {
+	put := func(s *opaquehttp.MemorySessionStore, ids ...string) error {
+		for _, id := range ids {
+			if err := s.Put(id, []byte(id)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// RejectNew keeps the pending sessions.
+	s := opaquehttp.NewMemorySessionStore(time.Minute)
+	s.SetLimit(2, opaquehttp.RejectNew)
+
+	if err := put(s, "a", "b", "c"); !errors.Is(err, opaquehttp.ErrTooManySessions) {
+		t.Fatalf("expected %q, got %v", opaquehttp.ErrTooManySessions, err)
+	}
+
+	if _, err := s.Take("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := put(s, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := opaquehttp.SessionStats{Pending: 2, Stored: 3, Taken: 1, Rejected: 1}
+	if stats := s.Stats(); stats != expected {
+		t.Fatalf("expected %+v, got %+v", expected, stats)
+	}
+
+	// EvictOldest evicts the oldest pending session.
+	s = opaquehttp.NewMemorySessionStore(time.Minute)
+	s.SetLimit(2, opaquehttp.EvictOldest)
+
+	if err := put(s, "a", "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Take("a"); !errors.Is(err, opaquehttp.ErrSessionNotFound) {
+		t.Fatalf("expected the oldest session to be evicted, got %v", err)
+	}
+
+	if state, err := s.Take("b"); err != nil || string(state) != "b" {
+		t.Fatalf("unexpected state %q, %v", state, err)
+	}
+
+	expected = opaquehttp.SessionStats{Pending: 1, Stored: 3, Taken: 1, Evicted: 1}
+	if stats := s.Stats(); stats != expected {
+		t.Fatalf("expected %+v, got %+v", expected, stats)
+	}
+
+	// Expired sessions don't count against the limit.
+	s = opaquehttp.NewMemorySessionStore(time.Millisecond)
+	s.SetLimit(1, opaquehttp.RejectNew)
+
+	if err := put(s, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if err := put(s, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := s.Stats(); stats.Expired != 1 || stats.Pending != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestFileCredentialStore(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	dir := t.TempDir()
+
+	store, err := opaquehttp.NewFileCredentialStore(dir, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test, record := registeredTestParams(t, conf)
+
+	if _, err = store.Get(record.CredentialIdentifier); !errors.Is(err, opaquehttp.ErrCredentialNotFound) {
+		t.Fatalf("expected %q, got %v", opaquehttp.ErrCredentialNotFound, err)
+	}
+
+	if err = store.Put(record); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Put(record); !errors.Is(err, opaquehttp.ErrCredentialExists) {
+		t.Fatalf("expected %q, got %v", opaquehttp.ErrCredentialExists, err)
+	}
+
+	// The records are read back by another store on the same directory.
+	store, _ = opaquehttp.NewFileCredentialStore(dir, conf)
+
+	stored, err := store.Get(record.CredentialIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(stored.Serialize(), record.Serialize()) || !bytes.Equal(stored.ClientIdentity,
+		record.ClientIdentity) {
+		t.Fatal("the stored record differs")
+	}
+
+	testAuthentication(t, test, stored)
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected a single record file, got %d", len(entries))
+	}
+
+	// Flagged records are replaced once, and the walk skips the flag files.
+	if err = store.Replace(record); !errors.Is(err, opaquehttp.ErrCredentialNotFlagged) {
+		t.Fatalf("expected %q, got %v", opaquehttp.ErrCredentialNotFlagged, err)
+	}
+
+	report, err := opaquehttp.Migrate(conf, store, store, nil)
+	if err != nil || *report != (opaquehttp.MigrationReport{Records: 1, Flagged: 1}) {
+		t.Fatalf("unexpected report %+v, %v", report, err)
+	}
+
+	if flagged, err := store.Flagged(record.CredentialIdentifier); err != nil || !flagged {
+		t.Fatalf("expected the record to be flagged, got %v", err)
+	}
+
+	other, _ := testRegistration(t, test)
+	other.CredentialIdentifier = record.CredentialIdentifier
+
+	if err = store.Replace(other); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Replace(other); !errors.Is(err, opaquehttp.ErrCredentialNotFlagged) {
+		t.Fatalf("expected %q, got %v", opaquehttp.ErrCredentialNotFlagged, err)
+	}
+
+	if stored, err = store.Get(record.CredentialIdentifier); err != nil || !bytes.Equal(stored.Serialize(),
+		other.Serialize()) {
+		t.Fatalf("expected the replaced record, got %v", err)
+	}
+
+	// Records of another configuration are invalid, and not flagged.
+	if err = os.WriteFile(filepath.Join(dir, "other"), []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = opaquehttp.Migrate(conf, store, store, nil)
+	if err != nil || *report != (opaquehttp.MigrationReport{Records: 2, Flagged: 1, Invalid: 1}) {
+		t.Fatalf("unexpected report %+v, %v", report, err)
+	}
+}
+
+func TestHTTPHandler_Rehash(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+	store := opaquehttp.NewMemoryCredentialStore()
+
+	h := &opaquehttp.Handler{
+		Configuration:   conf,
+		Credentials:     store,
+		Sessions:        opaquehttp.NewMemorySessionStore(time.Minute),
+		Rehash:          store,
+		ServerSecretKey: sks,
+		ServerPublicKey: pks,
+		OPRFSeed:        oprfSeed,
+	}
+
+	mux := http.NewServeMux()
+	h.Mount(mux, "/auth")
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	credID := []byte("alice")
+
+	register := func(session string, password []byte) int {
+		client, _ := conf.Client()
+		resp, status := postJSON(t, srv.URL+"/auth/register/init", &httpMessage{
+			CredentialIdentifier: credID,
+			Session:              session,
+			Message:              client.RegistrationInit(password).Serialize(),
+		})
+
+		if status != http.StatusOK {
+			return status
+		}
+
+		r2, err := client.Deserialize.RegistrationResponse(resp.Message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r3, _, _ := client.RegistrationFinalize(r2, nil, nil)
+		_, status = postJSON(t, srv.URL+"/auth/register/finish", &httpMessage{
+			Session:              resp.Session,
+			CredentialIdentifier: credID,
+			Message:              r3.Serialize(),
+		})
+
+		return status
+	}
+
+	// login returns the status of the login finish and its rehash session.
+	login := func(password []byte) (int, string) {
+		client, _ := conf.Client()
+		resp, status := postJSON(t, srv.URL+"/auth/login/init", &httpMessage{
+			CredentialIdentifier: credID,
+			Message:              client.LoginInit(password).Serialize(),
+		})
+
+		if status != http.StatusOK {
+			t.Fatalf("unexpected status %d", status)
+		}
+
+		ke2, err := client.Deserialize.KE2(resp.Message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(nil, nil, ke2)
+		if err != nil {
+			return http.StatusUnauthorized, ""
+		}
+
+		body, _ := json.Marshal(&httpMessage{Session: resp.Session, Message: ke3.Serialize()})
+
+		res, err := http.Post(srv.URL+"/auth/login/finish", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		return res.StatusCode, res.Header.Get(opaquehttp.RehashHeader)
+	}
+
+	if status := register("", []byte("old")); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	if status, rehash := login([]byte("old")); status != http.StatusNoContent || rehash != "" {
+		t.Fatalf("unexpected login with status %d and rehash %q", status, rehash)
+	}
+
+	if _, err := opaquehttp.Migrate(conf, store, store, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A flagged record is signaled on login, but only a successful one.
+	if status, rehash := login([]byte("wrong")); status != http.StatusUnauthorized || rehash != "" {
+		t.Fatalf("unexpected login with status %d and rehash %q", status, rehash)
+	}
+
+	status, rehash := login([]byte("old"))
+	if status != http.StatusNoContent || rehash == "" {
+		t.Fatalf("expected a rehash signal, got status %d", status)
+	}
+
+	// The rehash session is single use, and replaces the record.
+	if status = register(rehash, []byte("new")); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	if status = register(rehash, []byte("new")); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized on a reused rehash session, got %d", status)
+	}
+
+	if status, rehash = login([]byte("old")); status != http.StatusUnauthorized {
+		t.Fatalf("expected the old password to fail, got %d", status)
+	}
+
+	if status, rehash = login([]byte("new")); status != http.StatusNoContent || rehash != "" {
+		t.Fatalf("unexpected login with status %d and rehash %q", status, rehash)
+	}
+}
+
+func TestHTTPHandler_MintCredentialIdentifiers(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+
+	h := &opaquehttp.Handler{
+		Configuration:             conf,
+		Credentials:               opaquehttp.NewMemoryCredentialStore(),
+		Sessions:                  opaquehttp.NewMemorySessionStore(time.Minute),
+		ServerSecretKey:           sks,
+		ServerPublicKey:           pks,
+		OPRFSeed:                  oprfSeed,
+		MintCredentialIdentifiers: true,
+	}
+
+	mux := http.NewServeMux()
+	h.Mount(mux, "/auth")
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	password := []byte("password")
+	client, _ := conf.Client()
+	request := client.RegistrationInit(password).Serialize()
+
+	// The client can't choose its credential identifier.
+	if _, status := postJSON(t, srv.URL+"/auth/register/init", &httpMessage{
+		CredentialIdentifier: []byte("alice"),
+		Message:              request,
+	}); status != http.StatusBadRequest {
+		t.Fatalf("expected bad request with a credential identifier, got %d", status)
+	}
+
+	resp, status := postJSON(t, srv.URL+"/auth/register/init", &httpMessage{Message: request})
+	if status != http.StatusOK || len(resp.CredentialIdentifier) != opaque.CredentialIdentifierLength {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	credID := resp.CredentialIdentifier
+
+	r2, err := client.Deserialize.RegistrationResponse(resp.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r3, _, _ := client.RegistrationFinalize(r2, nil, nil)
+	if _, status = postJSON(t, srv.URL+"/auth/register/finish", &httpMessage{
+		Session:              resp.Session,
+		CredentialIdentifier: credID,
+		Message:              r3.Serialize(),
+	}); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	// The client logs in with the minted credential identifier.
+	client, _ = conf.Client()
+	resp, status = postJSON(t, srv.URL+"/auth/login/init", &httpMessage{
+		CredentialIdentifier: credID,
+		Message:              client.LoginInit(password).Serialize(),
+	})
+
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	ke2, err := client.Deserialize.KE2(resp.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, _, err := client.LoginFinish(nil, nil, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, status = postJSON(t, srv.URL+"/auth/login/finish", &httpMessage{
+		Session: resp.Session,
+		Message: ke3.Serialize(),
+	}); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", status)
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func hybridConfiguration(conf *opaque.Configuration) *opaque.Configuration {
+	c := *conf
+	c.KEM = opaque.MLKEM768
+
+	return &c
+}
+
+func TestHybridAKE(t *testing.T) {
+	for _, c := range confs {
+		conf := hybridConfiguration(c.Conf)
+		test, record := registeredTestParams(t, conf)
+		testAuthentication(t, test, record)
+
+		// A tampered KEM ciphertext yields different keys on both ends.
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+		ke1 := client.LoginInit(test.password)
+
+		if len(ke1.KEMPublicKey) == 0 {
+			t.Fatal("KE1 is missing the KEM public key")
+		}
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke2.KEMCiphertext[0] ^= 0xff
+
+		if _, _, err := client.LoginFinish(test.username, test.serverID, ke2); err == nil {
+			t.Fatal("expected error with a tampered KEM ciphertext")
+		}
+
+		// A client without the KEM can't talk to a hybrid server.
+		plain, _ := c.Conf.Client()
+		if _, err := server.Deserialize.KE1(plain.LoginInit(test.password).Serialize()); err == nil {
+			t.Fatal("expected error on KE1 without KEM public key")
+		}
+	}
+}
+
+func TestHybridAKE_Configuration(t *testing.T) {
+	conf := hybridConfiguration(opaque.DefaultConfiguration())
+
+	decoded, err := opaque.DeserializeConfiguration(conf.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isSameConf(conf, decoded) {
+		t.Fatal("KEM was not preserved in the configuration encoding")
+	}
+
+	conf.KEM = 3
+	if _, err := conf.Client(); err == nil || err.Error() != "invalid KEM id" {
+		t.Fatalf("expected error on invalid KEM, got %v", err)
+	}
+}
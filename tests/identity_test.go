@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestIdentityHiding(t *testing.T) {
+	username := []byte("alice@example.com")
+	deviceName := []byte("alice's laptop")
+
+	for _, conf := range confs {
+		test := newTestParams(t, conf.Conf)
+		test.username = username
+		record, _ := testRegistration(t, test)
+
+		client, _ := conf.Conf.Client()
+		server, _ := conf.Conf.Server()
+		ke1 := client.LoginInit(test.password)
+
+		sealed, err := client.SealIdentity(username, test.serverPublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A 12-byte nonce, the identity padded to 32 bytes, and a 16-byte tag.
+		if bytes.Contains(sealed, username) || len(sealed)%32 != 28 {
+			t.Fatal("unexpected sealed identity")
+		}
+
+		// Sealing again uses a fresh nonce.
+		again, err := client.SealIdentity(username, test.serverPublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Equal(again[:12], sealed[:12]) || bytes.Equal(again, sealed) {
+			t.Fatal("unexpected nonce reuse")
+		}
+
+		m1, _ := server.Deserialize.KE1(ke1.Serialize())
+
+		for _, s := range [][]byte{sealed, again} {
+			identity, err := server.OpenIdentity(m1, test.serverSecretKey, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(identity, username) {
+				t.Fatal("identities differ")
+			}
+		}
+
+		// Only the intended server can open the identity.
+		otherSecretKey, _, _ := conf.Conf.KeyGen()
+		if _, err := server.OpenIdentity(m1, otherSecretKey, sealed); !errors.Is(err, opaque.ErrIdentityHiding) {
+			t.Fatalf("expected error with another server key, got %v", err)
+		}
+
+		// KE3 identity material.
+		ke2, err := server.LoginInit(m1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.SealIdentityKE3(deviceName); err == nil {
+			t.Fatal("expected error before LoginFinish")
+		}
+
+		ke3, _, err := client.LoginFinish(username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sealed, err = client.SealIdentityKE3(deviceName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		identity, err := server.LoginFinishWithIdentity(ke3, sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(identity, deviceName) {
+			t.Fatal("KE3 identities differ")
+		}
+
+		if _, err := server.LoginFinishWithIdentity(ke3, sealed[:4]); !errors.Is(err, opaque.ErrIdentityHiding) {
+			t.Fatalf("expected error on truncated identity, got %v", err)
+		}
+
+		sealed[0] ^= 0xff
+		if _, err := server.LoginFinishWithIdentity(ke3, sealed); !errors.Is(err, opaque.ErrIdentityHiding) {
+			t.Fatalf("expected error on tampered identity, got %v", err)
+		}
+	}
+}
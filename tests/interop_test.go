@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque/interop"
+	"github.com/bytemare/opaque/vectors"
+)
+
+// interopConfigs are the suites of the interoperability tests, named as in the draft.
+var interopConfigs = []*vectors.Config{
+	{Group: "ristretto255", Hash: "SHA512", KDF: "HKDF-SHA512", MAC: "HMAC-SHA512", KSF: "Identity", Name: "3DH"},
+	{Group: "ristretto255", Hash: "SHA512", KDF: "HKDF-SHA512", MAC: "HMAC-SHA512", KSF: "Identity", Name: "HMQV"},
+	{
+		Group: "P256_XMD:SHA-256_SSWU_RO_", Hash: "SHA256", KDF: "HKDF-SHA256", MAC: "HMAC-SHA256", KSF: "Identity",
+		Name: "3DH", Context: []byte("OPAQUE-POC"),
+	},
+}
+
+var interopParameters = []*interop.Parameters{
+	{},
+	{ClientIdentity: []byte("client"), ServerIdentity: []byte("server")},
+}
+
+// interopHandshakes runs the handshakes of the interoperability tests between the drivers.
+func interopHandshakes(t *testing.T, client, server *interop.Driver) {
+	t.Helper()
+
+	for _, c := range interopConfigs {
+		if _, err := c.Configuration(); err != nil {
+			continue
+		}
+
+		for _, p := range interopParameters {
+			if _, err := interop.Handshake(c, client, server, p); err != nil {
+				t.Fatalf("%s client, %s server, %s %s: %v", client.Name, server.Name, c.Name, c.Group, err)
+			}
+		}
+	}
+}
+
+func TestInteropLocal(t *testing.T) {
+	client, server := interop.Local(), interop.Local()
+	defer client.Close()
+	defer server.Close()
+
+	interopHandshakes(t, client, server)
+
+	// A single driver can be both ends.
+	interopHandshakes(t, client, client)
+}
+
+// TestInteropExternal runs handshakes in both directions with the drivers of other implementations, given in the
+// OPAQUE_INTEROP_DRIVERS environment variable as semicolon-separated name=command pairs, e.g.
+// "opaque-ke=./opaque-ke-driver;ts=node driver.js".
+func TestInteropExternal(t *testing.T) {
+	drivers := os.Getenv("OPAQUE_INTEROP_DRIVERS")
+	if drivers == "" {
+		t.Skip("OPAQUE_INTEROP_DRIVERS is not set")
+	}
+
+	local := interop.Local()
+	defer local.Close()
+
+	for _, spec := range strings.Split(drivers, ";") {
+		name, command, ok := strings.Cut(spec, "=")
+		if !ok {
+			t.Fatalf("invalid driver %q, expected name=command", spec)
+		}
+
+		args := strings.Fields(command)
+
+		external, err := interop.Start(name, args[0], args[1:]...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		interopHandshakes(t, external, local)
+		interopHandshakes(t, local, external)
+
+		if err = external.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInteropDriverErrors(t *testing.T) {
+	d := interop.Local()
+	defer d.Close()
+
+	if _, err := d.Call(interop.ClientLoginStart, "a", interop.Values{"password": []byte("password")}); !errors.Is(
+		err, interop.ErrDriver) {
+		t.Fatalf("expected %q before configuring, got %v", interop.ErrDriver, err)
+	}
+
+	name, err := d.Configure(interopConfigs[0])
+	if err != nil || name != interop.Implementation {
+		t.Fatalf("unexpected configuration: %q, %v", name, err)
+	}
+
+	if _, err = d.Configure(&vectors.Config{Group: "decaf448"}); !errors.Is(err, interop.ErrDriver) {
+		t.Fatalf("expected %q for an unsupported configuration, got %v", interop.ErrDriver, err)
+	}
+
+	for _, r := range []struct {
+		op   interop.Op
+		args interop.Values
+	}{
+		{"unknown", nil},
+		{interop.ClientLoginStart, nil},
+		{interop.ClientLoginFinish, interop.Values{"ke2": nil}},
+		{interop.ServerLoginFinish, interop.Values{"ke3": nil}},
+		{interop.ClientRegistrationFinish, interop.Values{"registration_response": nil}},
+		{interop.ServerRegistrationResponse, interop.Values{"registration_request": []byte{1}}},
+	} {
+		if _, err = d.Call(r.op, "unknown", r.args); !errors.Is(err, interop.ErrDriver) {
+			t.Fatalf("%s: expected %q, got %v", r.op, interop.ErrDriver, err)
+		}
+	}
+}
+
+// TestInteropDrift checks that the harness catches a driver whose messages this implementation can't read back.
+func TestInteropDrift(t *testing.T) {
+	requests, requestWriter := io.Pipe()
+	responses, responseWriter := io.Pipe()
+
+	// The drifting driver prefixes the registration requests of this implementation with a byte.
+	go func() {
+		_ = responseWriter.CloseWithError(interop.Serve(requests, &driftWriter{w: responseWriter}))
+	}()
+
+	drifting := interop.NewDriver("drifting", responses, requestWriter)
+	defer drifting.Close()
+
+	local := interop.Local()
+	defer local.Close()
+
+	if _, err := interop.Handshake(interopConfigs[0], drifting, local, &interop.Parameters{}); !errors.Is(err,
+		interop.ErrMismatch) {
+		t.Fatalf("expected %q, got %v", interop.ErrMismatch, err)
+	}
+}
+
+// driftWriter corrupts the registration requests written by a driver.
+type driftWriter struct {
+	w io.Writer
+}
+
+func (d *driftWriter) Write(p []byte) (int, error) {
+	const field = `"registration_request":"`
+
+	out := p
+	if i := bytes.Index(p, []byte(field)); i >= 0 {
+		i += len(field)
+		out = append(append(append([]byte{}, p[:i]...), "00"...), p[i:]...)
+	}
+
+	if _, err := d.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
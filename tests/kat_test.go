@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+type katInputs struct {
+	conf                           *opaque.Configuration
+	oprfSeed, serverSK, serverPK   []byte
+	registration, login, server    *opaque.KnownAnswers
+	credentialIdentifier, password []byte
+}
+
+func newKATInputs(t *testing.T) *katInputs {
+	t.Helper()
+
+	conf := opaque.DefaultConfiguration()
+	scalar := func() []byte {
+		sk, _, err := conf.KeyGen()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return sk
+	}
+
+	in := &katInputs{
+		conf:                 conf,
+		oprfSeed:             randomBytes(conf.Hash.Size()),
+		credentialIdentifier: []byte("credential"),
+		password:             []byte("password"),
+		registration:         &opaque.KnownAnswers{Blind: scalar(), EnvelopeNonce: randomBytes(32)},
+		login: &opaque.KnownAnswers{
+			Blind:              scalar(),
+			EphemeralSecretKey: scalar(),
+			Nonce:              randomBytes(32),
+		},
+		server: &opaque.KnownAnswers{
+			MaskingNonce:       randomBytes(32),
+			EphemeralSecretKey: scalar(),
+			Nonce:              randomBytes(32),
+		},
+	}
+	in.serverSK, in.serverPK, _ = conf.KeyGen()
+
+	return in
+}
+
+// run runs a registration and a login on new instances with the known answers, and returns the messages and the
+// session key.
+func (in *katInputs) run(t *testing.T) [][]byte {
+	t.Helper()
+
+	client, _ := in.conf.Client()
+	server, _ := in.conf.Server()
+
+	if err := client.SetKnownAnswers(in.registration); err != nil {
+		t.Fatal(err)
+	}
+
+	pks, _ := server.Deserialize.DecodeAkePublicKey(in.serverPK)
+	r1 := client.RegistrationInit(in.password)
+
+	r2, err := server.RegistrationResponse(r1, pks, in.credentialIdentifier, in.oprfSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r3, _, err := client.RegistrationFinalize(r2, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ = in.conf.Client()
+	if err = client.SetKnownAnswers(in.login); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = server.SetKnownAnswers(in.server); err != nil {
+		t.Fatal(err)
+	}
+
+	ke1 := client.LoginInit(in.password)
+
+	ke2, err := server.LoginInit(ke1, nil, in.serverSK, in.serverPK, in.oprfSeed, &opaque.ClientRecord{
+		CredentialIdentifier: in.credentialIdentifier,
+		RegistrationRecord:   r3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, _, err := client.LoginFinish(nil, nil, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return [][]byte{
+		r1.Serialize(), r2.Serialize(), r3.Serialize(),
+		ke1.Serialize(), ke2.Serialize(), ke3.Serialize(), client.SessionKey(),
+	}
+}
+
+func TestKnownAnswers(t *testing.T) {
+	in := newKATInputs(t)
+	a, b := in.run(t), in.run(t)
+
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			t.Fatalf("message %d differs with the same known answers", i)
+		}
+	}
+
+	ke1 := a[3]
+	if !bytes.Equal(ke1[len(ke1)-64:len(ke1)-32], in.login.Nonce) {
+		t.Fatal("KE1 doesn't have the known nonce")
+	}
+
+	// Setting nil restores the random values.
+	client, _ := in.conf.Client()
+	if err := client.SetKnownAnswers(in.login); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.SetKnownAnswers(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(client.LoginInit(in.password).Serialize(), ke1) {
+		t.Fatal("unexpected known KE1 after resetting the known answers")
+	}
+
+	// So does Wipe.
+	client, _ = in.conf.Client()
+	if err := client.SetKnownAnswers(in.login); err != nil {
+		t.Fatal(err)
+	}
+
+	client.Wipe()
+
+	if bytes.Equal(client.LoginInit(in.password).Serialize(), ke1) {
+		t.Fatal("unexpected known KE1 after Wipe")
+	}
+}
+
+func TestKnownAnswersErrors(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	scalar, _, _ := conf.KeyGen()
+
+	for name, k := range map[string]*opaque.KnownAnswers{
+		"short blind":            {Blind: scalar[1:]},
+		"zero blind":             {Blind: make([]byte, 32)},
+		"invalid blind":          {Blind: getBadRistrettoScalar()},
+		"zero ephemeral key":     {EphemeralSecretKey: make([]byte, 32)},
+		"short envelope nonce":   {EnvelopeNonce: randomBytes(31)},
+		"long nonce":             {Nonce: randomBytes(33)},
+		"server's masking nonce": {MaskingNonce: randomBytes(32)},
+	} {
+		if err := client.SetKnownAnswers(k); err == nil {
+			t.Fatalf("client: expected error for %s", name)
+		}
+	}
+
+	for name, k := range map[string]*opaque.KnownAnswers{
+		"client's blind":          {Blind: scalar},
+		"client's envelope nonce": {EnvelopeNonce: randomBytes(32)},
+		"short masking nonce":     {MaskingNonce: randomBytes(16)},
+		"invalid ephemeral key":   {EphemeralSecretKey: getBadRistrettoScalar()},
+	} {
+		if err := server.SetKnownAnswers(k); err == nil {
+			t.Fatalf("server: expected error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func kemakeConfiguration(conf *opaque.Configuration) *opaque.Configuration {
+	c := *conf
+	c.Protocol = opaque.KEMAKE
+
+	return &c
+}
+
+func TestKEMAKE(t *testing.T) {
+	for _, c := range confs {
+		for _, conf := range []*opaque.Configuration{kemakeConfiguration(c.Conf), hybridConfiguration(
+			kemakeConfiguration(c.Conf))} {
+			test, record := registeredTestParams(t, conf)
+			testAuthentication(t, test, record)
+		}
+	}
+}
+
+func TestKEMAKE_ServerKey(t *testing.T) {
+	conf := kemakeConfiguration(opaque.DefaultConfiguration())
+	password := []byte("password")
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+	test := &testParams{
+		Configuration:   conf,
+		password:        password,
+		serverSecretKey: sks,
+		serverPublicKey: pks,
+		oprfSeed:        oprfSeed,
+	}
+	record, _ := testRegistration(t, test)
+
+	login := func(finish func(server *opaque.Server, ke3 []byte) error) ([]byte, *opaque.Server) {
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		ke2, err := server.LoginInit(client.LoginInit(password), nil, sks, pks, oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(nil, nil, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := finish(server, ke3.Serialize()); err != nil {
+			return nil, nil
+		}
+
+		return client.SessionKey(), server
+	}
+
+	// Restoring the server state requires setting the server key.
+	restore := func(withKey bool) func(server *opaque.Server, ke3 []byte) error {
+		return func(server *opaque.Server, ke3 []byte) error {
+			restored, _ := conf.Server()
+			if err := restored.SetAKEState(server.SerializeState()); err != nil {
+				t.Fatal(err)
+			}
+
+			if withKey {
+				if err := restored.SetAKEServerKey(sks); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			m3, err := restored.Deserialize.KE3(ke3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := restored.LoginFinish(m3); err != nil {
+				return err
+			}
+
+			*server = *restored
+
+			return nil
+		}
+	}
+
+	clientKey, server := login(restore(true))
+	if server == nil || !bytes.Equal(clientKey, server.SessionKey()) {
+		t.Fatal("session keys differ after restoring the server state")
+	}
+
+	if _, server = login(restore(false)); server != nil {
+		t.Fatal("expected error when finalizing without the server key")
+	}
+}
+
+func TestKEMAKE_TamperedAuthCiphertext(t *testing.T) {
+	for _, c := range confs {
+		conf := kemakeConfiguration(c.Conf)
+		test := newTestParams(t, conf)
+		test.username, test.serverID = nil, nil
+		record, _ := testRegistration(t, test)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		ke2, err := server.LoginInit(client.LoginInit(test.password), nil, test.serverSecretKey, test.serverPublicKey,
+			test.oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(nil, nil, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Another valid encapsulation, replacing the client's in transit, fails the client MAC.
+		_, other, _ := conf.KeyGen()
+		ke3.AuthCiphertext = other
+
+		if err := server.LoginFinish(ke3); !errors.Is(err, opaque.ErrAkeInvalidClientMac) {
+			t.Fatalf("expected invalid client MAC on a tampered authentication ciphertext, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestOPRFKeyCache(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		oprfSeed, _ := conf.GenerateOPRFSeed()
+		_, pks, _ := conf.KeyGen()
+		cache := opaque.NewOPRFKeyCache(2)
+
+		evaluate := func(seed, credID []byte, cached bool) {
+			client, _ := conf.Client()
+			server, _ := conf.Server()
+
+			if cached {
+				server.SetOPRFKeyCache(cache)
+			}
+
+			req := client.RegistrationInit([]byte("password"))
+
+			// The same request is evaluated by a server without the cache, as a reference.
+			reference, _ := conf.Server()
+			pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+			expected, _ := reference.RegistrationResponse(req, pk, credID, seed)
+			evaluated, _ := server.RegistrationResponse(req, pk, credID, seed)
+
+			if !bytes.Equal(evaluated.EvaluatedMessage.Bytes(), expected.EvaluatedMessage.Bytes()) {
+				t.Fatalf("unexpected evaluation for %q", credID)
+			}
+		}
+
+		for i := 0; i < 3; i++ {
+			for _, id := range []string{"alice", "bob", "carol", "bob"} {
+				evaluate(oprfSeed, []byte(fmt.Sprintf("%s%d", id, i%2)), true)
+			}
+		}
+
+		if cache.Len() != 2 {
+			t.Fatalf("expected 2 cached keys, got %d", cache.Len())
+		}
+
+		// A new seed purges the cache, and doesn't use the keys of the previous one.
+		newSeed, _ := conf.GenerateOPRFSeed()
+		evaluate(newSeed, []byte("bob0"), true)
+
+		if cache.Len() != 1 {
+			t.Fatalf("expected 1 cached key after changing the seed, got %d", cache.Len())
+		}
+
+		cache.Purge()
+
+		if cache.Len() != 0 {
+			t.Fatal("expected an empty cache after purging")
+		}
+	}
+}
+
+func TestOPRFKeyCache_MixedGroups(t *testing.T) {
+	for _, groups := range [][2]opaque.Group{
+		{opaque.RistrettoSha512, opaque.P384Sha512},
+		{opaque.P384Sha512, opaque.RistrettoSha512},
+	} {
+		conf := opaque.DefaultConfiguration()
+		conf.OPRF, conf.AKE = groups[0], groups[1]
+		conf.KSF = 0
+		cache := opaque.NewOPRFKeyCache(2)
+		test, record := registeredTestParams(t, conf)
+
+		// The first login caches the OPRF key, and the second one uses it.
+		for i := 0; i < 2; i++ {
+			client, _ := conf.Client()
+			server, _ := conf.Server()
+			server.SetOPRFKeyCache(cache)
+
+			ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+				test.serverPublicKey, test.oprfSeed, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.LoginFinish(ke3); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if cache.Len() != 1 {
+			t.Fatalf("expected 1 cached key, got %d", cache.Len())
+		}
+	}
+}
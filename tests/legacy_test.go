@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+// baselineRecord is a registration by the first version of this library, in the default configuration with a context.
+// The configurations and records of that version are read as is by this one, so a deployment upgrading the dependency
+// doesn't need to convert them.
+type baselineRecord struct {
+	Configuration        ByteToHex `json:"configuration"`
+	Password             ByteToHex `json:"password"`
+	CredentialIdentifier ByteToHex `json:"credential_identifier"`
+	ClientIdentity       ByteToHex `json:"client_identity"`
+	ServerIdentity       ByteToHex `json:"server_identity"`
+	ServerPrivateKey     ByteToHex `json:"server_private_key"`
+	ServerPublicKey      ByteToHex `json:"server_public_key"`
+	OprfSeed             ByteToHex `json:"oprf_seed"`
+	Record               ByteToHex `json:"record"`
+	ExportKey            ByteToHex `json:"export_key"`
+}
+
+func TestBaselineRecord(t *testing.T) {
+	contents, err := os.ReadFile("baselineRecord.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v baselineRecord
+	if err = json.Unmarshal(contents, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := opaque.DeserializeConfiguration(v.Configuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, _ := conf.Server()
+
+	registration, err := server.Deserialize.RegistrationRecord(v.Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := &opaque.ClientRecord{
+		CredentialIdentifier: v.CredentialIdentifier,
+		ClientIdentity:       v.ClientIdentity,
+		RegistrationRecord:   registration,
+	}
+
+	if err = opaque.ValidateRecord(conf, record); err != nil {
+		t.Fatal(err)
+	}
+
+	test := &testParams{
+		Configuration:   conf,
+		username:        v.ClientIdentity,
+		userID:          v.ClientIdentity,
+		serverID:        v.ServerIdentity,
+		password:        v.Password,
+		serverSecretKey: v.ServerPrivateKey,
+		serverPublicKey: v.ServerPublicKey,
+		oprfSeed:        v.OprfSeed,
+	}
+
+	if exportKey := testAuthentication(t, test, record); !bytes.Equal(exportKey, v.ExportKey) {
+		t.Fatal("unexpected export key")
+	}
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque/mobile"
+)
+
+type mobileParties struct {
+	conf   []byte
+	client *mobile.Client
+	server *mobile.Server
+}
+
+func newMobileParties(t *testing.T, conf []byte, serverID []byte) *mobileParties {
+	keys, err := mobile.GenerateKeyPair(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, err := mobile.GenerateOPRFSeed(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := mobile.NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := mobile.NewServer(conf, serverID, keys.SecretKey, keys.PublicKey, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &mobileParties{conf: conf, client: client, server: server}
+}
+
+func (p *mobileParties) register(t *testing.T, password, credID, clientID, serverID []byte) *mobile.RegistrationResult {
+	request := p.client.RegistrationInit(password)
+
+	response, err := p.server.RegistrationResponse(request, credID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := p.client.RegistrationFinalize(response, clientID, serverID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestMobile(t *testing.T) {
+	password := []byte("password")
+	credID := []byte("credential")
+
+	for _, c := range confs {
+		conf := c.Conf.Serialize()
+
+		for _, ids := range [][2][]byte{{nil, nil}, {[]byte("client"), []byte("server")}} {
+			p := newMobileParties(t, conf, ids[1])
+			registration := p.register(t, password, credID, ids[0], ids[1])
+
+			// Two logins in a row, with the same Client and Server.
+			for i := 0; i < 2; i++ {
+				ke1 := p.client.LoginInit(password)
+
+				ke2, err := p.server.LoginInit(ke1, registration.Record, credID, ids[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				login, err := p.client.LoginFinish(ke2, ids[0], ids[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				serverKey, err := p.server.LoginFinish(login.KE3)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(login.SessionKey, serverKey) {
+					t.Fatal("expected equal session keys")
+				}
+
+				if !bytes.Equal(login.ExportKey, registration.ExportKey) {
+					t.Fatal("expected the export key of the registration")
+				}
+			}
+
+			// The results remain valid after wiping.
+			key := append([]byte(nil), registration.ExportKey...)
+			p.client.Wipe()
+
+			if !bytes.Equal(key, registration.ExportKey) {
+				t.Fatal("expected the export key to outlive Wipe")
+			}
+
+			p.server.Wipe()
+		}
+	}
+}
+
+func TestMobile_DefaultConfiguration(t *testing.T) {
+	if !bytes.Equal(mobile.DefaultConfiguration(), confs[0].Conf.Serialize()) {
+		t.Fatal("expected the serialization of the default configuration")
+	}
+}
+
+func TestMobile_Errors(t *testing.T) {
+	conf := mobile.DefaultConfiguration()
+	password := []byte("password")
+	credID := []byte("credential")
+
+	// Invalid configuration.
+	bad := []byte{0xff, 0xff, 0xff, 0xff, 0xff}
+
+	if _, err := mobile.NewClient(bad); err == nil {
+		t.Fatal("expected error on invalid configuration")
+	}
+
+	if _, err := mobile.GenerateKeyPair(bad); err == nil {
+		t.Fatal("expected error on invalid configuration")
+	}
+
+	if _, err := mobile.GenerateOPRFSeed(bad); err == nil {
+		t.Fatal("expected error on invalid configuration")
+	}
+
+	// Invalid key material.
+	keys, err := mobile.GenerateKeyPair(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, _ := mobile.GenerateOPRFSeed(conf)
+
+	if _, err = mobile.NewServer(bad, nil, keys.SecretKey, keys.PublicKey, seed); err == nil {
+		t.Fatal("expected error on invalid configuration")
+	}
+
+	if _, err = mobile.NewServer(conf, nil, keys.SecretKey, keys.PublicKey, seed[1:]); err == nil {
+		t.Fatal("expected error on invalid seed")
+	}
+
+	other, _ := mobile.GenerateKeyPair(conf)
+	if _, err = mobile.NewServer(conf, nil, keys.SecretKey, other.PublicKey, seed); err == nil {
+		t.Fatal("expected error on mismatching key pair")
+	}
+
+	p := newMobileParties(t, conf, nil)
+
+	// Out of order calls.
+	if _, err = p.client.RegistrationFinalize(nil, nil, nil); err == nil {
+		t.Fatal("expected error without RegistrationInit")
+	}
+
+	if _, err = p.client.LoginFinish(nil, nil, nil); err == nil {
+		t.Fatal("expected error without LoginInit")
+	}
+
+	// Malformed messages.
+	if _, err = p.server.RegistrationResponse([]byte{1}, credID); err == nil {
+		t.Fatal("expected error on malformed RegistrationRequest")
+	}
+
+	p.client.RegistrationInit(password)
+
+	if _, err = p.client.RegistrationFinalize([]byte{1}, nil, nil); err == nil {
+		t.Fatal("expected error on malformed RegistrationResponse")
+	}
+
+	registration := p.register(t, password, credID, nil, nil)
+
+	if _, err = p.server.LoginInit([]byte{1}, registration.Record, credID, nil); err == nil {
+		t.Fatal("expected error on malformed KE1")
+	}
+
+	ke1 := p.client.LoginInit(password)
+
+	if _, err = p.server.LoginInit(ke1, []byte{1}, credID, nil); err == nil {
+		t.Fatal("expected error on malformed record")
+	}
+
+	if _, err = p.client.LoginFinish([]byte{1}, nil, nil); err == nil {
+		t.Fatal("expected error on malformed KE2")
+	}
+
+	if _, err = p.server.LoginFinish([]byte{1}); err == nil {
+		t.Fatal("expected error on malformed KE3")
+	}
+
+	// Wrong password.
+	ke1 = p.client.LoginInit([]byte("wrong"))
+
+	ke2, err := p.server.LoginInit(ke1, registration.Record, credID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.client.LoginFinish(ke2, nil, nil); err == nil {
+		t.Fatal("expected error on wrong password")
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/bytemare/opaque/opaquenoise"
+)
+
+func TestNoiseBootstrap(t *testing.T) {
+	sessionKey := randomBytes(64)
+
+	clientKeys, err := opaquenoise.Derive(sessionKey, []byte("transport"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverKeys, _ := opaquenoise.Derive(sessionKey, []byte("transport"))
+	otherKeys, _ := opaquenoise.Derive(sessionKey, []byte("other"))
+
+	if bytes.Equal(clientKeys.PSK, otherKeys.PSK) || bytes.Equal(clientKeys.ServerStatic.Public,
+		otherKeys.ServerStatic.Public) {
+		t.Fatal("keys are not bound to the context")
+	}
+
+	for _, pattern := range []opaquenoise.Pattern{opaquenoise.IK, opaquenoise.XX} {
+		initiator, err := clientKeys.Initiator(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		responder, err := serverKeys.Responder(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !initiator.Initiator || responder.Initiator ||
+			!bytes.Equal(initiator.PresharedKey, responder.PresharedKey) ||
+			!bytes.Equal(initiator.Prologue, responder.Prologue) {
+			t.Fatal("unexpected configurations")
+		}
+
+		if err := initiator.VerifyPeerStatic(responder.StaticKeypair.Public); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := responder.VerifyPeerStatic(initiator.StaticKeypair.Public); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := responder.VerifyPeerStatic(otherKeys.ClientStatic.Public); err == nil {
+			t.Fatal("expected error on an unexpected peer static key")
+		}
+
+		// The static keys agree on a shared secret.
+		ss1, _ := curve25519.X25519(initiator.StaticKeypair.Private, initiator.PeerStatic)
+		ss2, _ := curve25519.X25519(responder.StaticKeypair.Private, responder.PeerStatic)
+
+		if !bytes.Equal(ss1, ss2) {
+			t.Fatal("static keys don't agree")
+		}
+	}
+
+	if _, err := clientKeys.Initiator(0); err == nil {
+		t.Fatal("expected error on invalid pattern")
+	}
+
+	if _, err := opaquenoise.Derive(nil, nil); err == nil {
+		t.Fatal("expected error on empty session key")
+	}
+}
@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+// errReader is a failing random number generator.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy source unavailable")
+}
+
+func TestRandomFailure(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+
+	external := opaque.DefaultConfiguration()
+	external.Mode = opaque.External
+	externalClient, _ := external.Client()
+
+	reader := rand.Reader
+	rand.Reader = errReader{}
+
+	defer func() {
+		rand.Reader = reader
+	}()
+
+	if _, err := conf.Client(); !errors.Is(err, opaque.ErrRandom) {
+		t.Fatalf("expected ErrRandom on Client, got %v", err)
+	}
+
+	if _, err := conf.Server(); !errors.Is(err, opaque.ErrRandom) {
+		t.Fatalf("expected ErrRandom on Server, got %v", err)
+	}
+
+	if _, _, err := conf.KeyGen(); !errors.Is(err, opaque.ErrRandom) {
+		t.Fatalf("expected ErrRandom on KeyGen, got %v", err)
+	}
+
+	if _, err := conf.GenerateOPRFSeed(); !errors.Is(err, opaque.ErrRandom) {
+		t.Fatalf("expected ErrRandom on GenerateOPRFSeed, got %v", err)
+	}
+
+	if _, err := opaque.RandomBytes(32); !errors.Is(err, opaque.ErrRandom) {
+		t.Fatalf("expected ErrRandom on RandomBytes, got %v", err)
+	}
+
+	if _, err := conf.GetFakeRecord([]byte("client")); !errors.Is(err, opaque.ErrRandom) {
+		t.Fatalf("expected ErrRandom on GetFakeRecord, got %v", err)
+	}
+
+	// Instances created beforehand still run the protocol on their hedged randomness.
+	pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+
+	credID := []byte("client")
+
+	resp, err := server.RegistrationResponse(client.RegistrationInit([]byte("password")), pk, credID, oprfSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := &opaque.ClientRecord{CredentialIdentifier: credID}
+
+	record.RegistrationRecord, _, err = client.RegistrationFinalize(resp, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The external mode draws the client's long-term key.
+	resp, _ = server.RegistrationResponse(externalClient.RegistrationInit([]byte("password")), pk, nil, oprfSeed)
+	if _, _, err = externalClient.RegistrationFinalize(resp, nil, nil); !errors.Is(err, opaque.ErrRandom) {
+		t.Fatalf("expected ErrRandom on external mode registration, got %v", err)
+	}
+
+	ke1 := client.LoginInit([]byte("password"))
+
+	ke2, err := server.LoginInit(ke1, nil, sks, pks, oprfSeed, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = client.LoginFinish(nil, nil, ke2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncompleteMessages(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		sks, pks, _ := conf.KeyGen()
+		oprfSeed, _ := conf.GenerateOPRFSeed()
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+		pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+
+		for _, req := range []*message.RegistrationRequest{nil, {}} {
+			if _, err := server.RegistrationResponse(req, pk, nil, oprfSeed); err == nil {
+				t.Fatal("expected error on incomplete registration request")
+			}
+		}
+
+		resp, _ := server.RegistrationResponse(client.RegistrationInit([]byte("password")), pk, nil, oprfSeed)
+		if _, err := server.RegistrationResponse(client.RegistrationInit([]byte("password")), nil, nil,
+			oprfSeed); err == nil {
+			t.Fatal("expected error on missing server public key")
+		}
+
+		for _, r := range []*message.RegistrationResponse{nil, {}, {EvaluatedMessage: resp.EvaluatedMessage}} {
+			if _, _, err := client.RegistrationFinalize(r, nil, nil); err == nil {
+				t.Fatal("expected error on incomplete registration response")
+			}
+		}
+
+		record := &opaque.ClientRecord{CredentialIdentifier: []byte("client")}
+		record.RegistrationRecord, _, _ = client.RegistrationFinalize(resp, nil, nil)
+		ke1 := client.LoginInit([]byte("password"))
+
+		for _, m := range []*message.KE1{nil, {}, {CredentialRequest: ke1.CredentialRequest}} {
+			if _, err := server.LoginInit(m, nil, sks, pks, oprfSeed, record); err == nil {
+				t.Fatal("expected error on incomplete KE1")
+			}
+
+			if _, err := server.OpenIdentity(m, sks, nil); err == nil {
+				t.Fatal("expected error on incomplete KE1")
+			}
+		}
+
+		ke2, _ := server.LoginInit(ke1, nil, sks, pks, oprfSeed, record)
+
+		for _, m := range []*message.KE2{nil, {}, {CredentialResponse: ke2.CredentialResponse}} {
+			if _, _, err := client.LoginFinish(nil, nil, m); err == nil {
+				t.Fatal("expected error on incomplete KE2")
+			}
+		}
+
+		if _, err := server.LoginInit(ke1, nil, sks, pks, oprfSeed, &opaque.ClientRecord{}); err == nil {
+			t.Fatal("expected error on incomplete client record")
+		}
+
+		if err := server.LoginFinish(nil); err == nil {
+			t.Fatal("expected error on missing KE3")
+		}
+	}
+}
+
+func TestLengthLimits(t *testing.T) {
+	long := make([]byte, 1<<16)
+
+	conf := opaque.DefaultConfiguration()
+	conf.Context = long
+
+	if _, err := conf.Client(); err == nil {
+		t.Fatal("expected error on long context")
+	}
+
+	conf = opaque.DefaultConfiguration()
+	sks, pks, _ := conf.KeyGen()
+	oprfSeed, _ := conf.GenerateOPRFSeed()
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+	resp, _ := server.RegistrationResponse(client.RegistrationInit([]byte("password")), pk, nil, oprfSeed)
+
+	if _, _, err := client.RegistrationFinalize(resp, long, nil); err == nil {
+		t.Fatal("expected error on long client identity")
+	}
+
+	record := &opaque.ClientRecord{CredentialIdentifier: []byte("client")}
+	record.RegistrationRecord, _, _ = client.RegistrationFinalize(resp, nil, nil)
+	ke1 := client.LoginInit([]byte("password"))
+
+	if _, err := client.SealIdentity(long, pks); err == nil {
+		t.Fatal("expected error on long sealed identity")
+	}
+
+	if _, err := server.LoginInit(ke1, long, sks, pks, oprfSeed, record); err == nil {
+		t.Fatal("expected error on long server identity")
+	}
+
+	ke2, _ := server.LoginInit(ke1, nil, sks, pks, oprfSeed, record)
+	if _, _, err := client.LoginFinish(nil, long, ke2); err == nil {
+		t.Fatal("expected error on long server identity")
+	}
+}
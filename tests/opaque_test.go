@@ -11,6 +11,8 @@ package opaque_test
 import (
 	"bytes"
 	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"reflect"
 	"strings"
@@ -33,25 +35,9 @@ type testParams struct {
 }
 
 func TestFull(t *testing.T) {
-	ids := []byte("server")
-	username := []byte("client")
-	password := []byte("password")
-
 	conf := opaque.DefaultConfiguration()
 	conf.Context = []byte("OPAQUETest")
-
-	test := &testParams{
-		Configuration: conf,
-		username:      username,
-		userID:        username,
-		serverID:      ids,
-		password:      password,
-		oprfSeed:      conf.GenerateOPRFSeed(),
-	}
-
-	serverSecretKey, pks := conf.KeyGen()
-	test.serverSecretKey = serverSecretKey
-	test.serverPublicKey = pks
+	test := newTestParams(t, conf)
 
 	/*
 		Registration
@@ -89,13 +75,16 @@ func testRegistration(t *testing.T, p *testParams) (*opaque.ClientRecord, []byte
 			t.Fatalf(dbgErr, err)
 		}
 
-		credID = internal.RandomBytes(32)
+		credID = randomBytes(32)
 		pks, err := server.Deserialize.DecodeAkePublicKey(p.serverPublicKey)
 		if err != nil {
 			t.Fatalf(dbgErr, err)
 		}
 
-		respReg := server.RegistrationResponse(m1, pks, credID, p.oprfSeed)
+		respReg, err := server.RegistrationResponse(m1, pks, credID, p.oprfSeed)
+		if err != nil {
+			t.Fatalf(dbgErr, err)
+		}
 
 		m2s = respReg.Serialize()
 	}
@@ -109,7 +98,10 @@ func testRegistration(t *testing.T, p *testParams) (*opaque.ClientRecord, []byte
 			t.Fatalf(dbgErr, err)
 		}
 
-		upload, key := client.RegistrationFinalize(m2, p.username, p.serverID)
+		upload, key, err := client.RegistrationFinalize(m2, p.username, p.serverID)
+		if err != nil {
+			t.Fatalf(dbgErr, err)
+		}
 		exportKeyReg = key
 
 		m3s = upload.Serialize()
@@ -194,6 +186,12 @@ func testAuthentication(t *testing.T, p *testParams, record *opaque.ClientRecord
 			t.Fatalf(dbgErr, err)
 		}
 
+		if p.Protocol == opaque.KEMAKE {
+			if err := server.SetAKEServerKey(p.serverSecretKey); err != nil {
+				t.Fatalf(dbgErr, err)
+			}
+		}
+
 		if err := server.LoginFinish(m6); err != nil {
 			t.Fatalf(dbgErr, err)
 		}
@@ -227,6 +225,21 @@ func isSameConf(a, b *opaque.Configuration) bool {
 	if a.AKE != b.AKE {
 		return false
 	}
+	if a.Mode != b.Mode {
+		return false
+	}
+	if a.AppDataLength != b.AppDataLength {
+		return false
+	}
+	if a.KEM != b.KEM {
+		return false
+	}
+	if a.Protocol != b.Protocol {
+		return false
+	}
+	if a.Compatibility != b.Compatibility {
+		return false
+	}
 
 	return bytes.Equal(a.Context, b.Context)
 }
@@ -276,8 +289,44 @@ func TestNilConfiguration(t *testing.T) {
 	}
 }
 
+func TestDeserializeConfiguration_Baseline(t *testing.T) {
+	p256 := opaque.DefaultConfiguration()
+	p256.OPRF, p256.AKE = opaque.P256Sha256, opaque.P256Sha256
+	p256.KDF, p256.MAC, p256.Hash = crypto.SHA256, crypto.SHA256, crypto.SHA256
+
+	withContext := opaque.DefaultConfiguration()
+	withContext.Context = []byte("legacy deployment")
+
+	// Serialized by the first version of this library, before the fields encoded after the context were added.
+	for encoded, want := range map[string]*opaque.Configuration{
+		"0107070702010000": opaque.DefaultConfiguration(),
+		"0305050502030000": p256,
+		"01070707020100116c6567616379206465706c6f796d656e74": withContext,
+	} {
+		b, _ := hex.DecodeString(encoded)
+
+		conf, err := opaque.DeserializeConfiguration(b)
+		if err != nil {
+			t.Fatalf("%s: %v", encoded, err)
+		}
+
+		if !isSameConf(conf, want) {
+			t.Fatalf("%s: unexpected configuration %v", encoded, conf)
+		}
+
+		// The encoding, and thus the fingerprint, remain the same.
+		if !bytes.Equal(want.Serialize(), b) {
+			t.Fatalf("%s: unexpected encoding %x", encoded, want.Serialize())
+		}
+
+		if fingerprint := sha256.Sum256(b); !bytes.Equal(want.Fingerprint(), fingerprint[:]) {
+			t.Fatalf("%s: unexpected fingerprint", encoded)
+		}
+	}
+}
+
 func TestDeserializeConfiguration_Short(t *testing.T) {
-	r9 := internal.RandomBytes(7)
+	r9 := randomBytes(7)
 
 	if _, err := opaque.DeserializeConfiguration(r9); !errors.Is(err, internal.ErrConfigurationInvalidLength) {
 		t.Errorf("DeserializeConfiguration did not return the appropriate error for vector r9. want %q, got %q",
@@ -353,9 +402,22 @@ func TestBadConfiguration(t *testing.T) {
 			},
 			error: "invalid AKE group id",
 		},
+		{
+			name: "Bad Mode",
+			makeBad: func() []byte {
+				// The envelope mode is the first field after the empty context.
+				return append(opaque.DefaultConfiguration().Serialize(), 9)
+			},
+			error: "invalid envelope mode",
+		},
 	}
 
 	convertToBadConf := func(encoded []byte) *opaque.Configuration {
+		mode := opaque.Internal
+		if len(encoded) > 8 {
+			mode = opaque.EnvelopeMode(encoded[8])
+		}
+
 		return &opaque.Configuration{
 			OPRF:    opaque.Group(encoded[0]),
 			KDF:     crypto.Hash(encoded[1]),
@@ -363,6 +425,7 @@ func TestBadConfiguration(t *testing.T) {
 			Hash:    crypto.Hash(encoded[3]),
 			KSF:     ksf.Identifier(encoded[4]),
 			AKE:     opaque.Group(encoded[5]),
+			Mode:    mode,
 			Context: encoded[5:],
 		}
 	}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"testing"
+
+	"github.com/bytemare/opaque/opaquefuzz"
+)
+
+func FuzzConfigurationDecoder(f *testing.F) { opaquefuzz.Configuration(f) }
+
+func FuzzRegistrationRequest(f *testing.F) { opaquefuzz.RegistrationRequest(f) }
+
+func FuzzRegistrationResponse(f *testing.F) { opaquefuzz.RegistrationResponse(f) }
+
+func FuzzRegistrationRecord(f *testing.F) { opaquefuzz.RegistrationRecord(f) }
+
+func FuzzKE1(f *testing.F) { opaquefuzz.KE1(f) }
+
+func FuzzKE2(f *testing.F) { opaquefuzz.KE2(f) }
+
+func FuzzKE3(f *testing.F) { opaquefuzz.KE3(f) }
+
+func FuzzKE4(f *testing.F) { opaquefuzz.KE4(f) }
+
+func FuzzReauthRequest(f *testing.F) { opaquefuzz.ReauthRequest(f) }
+
+func FuzzReauthResponse(f *testing.F) { opaquefuzz.ReauthResponse(f) }
+
+func FuzzReauthFinish(f *testing.F) { opaquefuzz.ReauthFinish(f) }
+
+func FuzzPartialEvaluation(f *testing.F) { opaquefuzz.PartialEvaluation(f) }
+
+func FuzzOPRFKeyShare(f *testing.F) { opaquefuzz.OPRFKeyShare(f) }
+
+func FuzzAkePublicKey(f *testing.F) { opaquefuzz.AkePublicKey(f) }
+
+func FuzzAkePrivateKey(f *testing.F) { opaquefuzz.AkePrivateKey(f) }
+
+func FuzzEnvelopeRecovery(f *testing.F) { opaquefuzz.EnvelopeRecovery(f) }
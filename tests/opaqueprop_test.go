@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaqueprop"
+)
+
+func TestProperties(t *testing.T) {
+	opaqueprop.Check(t, &quick.Config{MaxCount: 3})
+}
+
+func TestPropertiesFail(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	reg := opaqueprop.Registration{}.Generate(r, 8).Interface().(opaqueprop.Registration)
+
+	// Breaking the record breaks the login.
+	record := *reg.Record.RegistrationRecord
+	record.MaskingKey = make([]byte, len(record.MaskingKey))
+	reg.Record = &opaque.ClientRecord{
+		CredentialIdentifier: reg.Record.CredentialIdentifier,
+		ClientIdentity:       reg.Record.ClientIdentity,
+		RegistrationRecord:   &record,
+	}
+
+	if err := opaqueprop.RegisterThenLogin(reg); !errors.Is(err, opaqueprop.ErrProperty) {
+		t.Fatalf("expected %q, got %v", opaqueprop.ErrProperty, err)
+	}
+
+	h := opaqueprop.Handshake{}.Generate(r, 8).Interface().(opaqueprop.Handshake)
+	h.Sequence = append(h.Sequence, opaqueprop.Message{Type: opaque.KE1Message, Encoded: []byte{1}})
+
+	if err := opaqueprop.MessageRoundTrip(h); !errors.Is(err, opaqueprop.ErrProperty) {
+		t.Fatalf("expected %q, got %v", opaqueprop.ErrProperty, err)
+	}
+}
@@ -196,7 +196,10 @@ func (v oprfVector) test(t *testing.T) {
 		t.Fatalf("decoding errored with %q\nfor key info %v\n", err, v.KeyInfo)
 	}
 
-	sks := v.SuiteID.DeriveKey(decSeed, decKeyInfo)
+	sks, err := v.SuiteID.DeriveKey(decSeed, decKeyInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !sks.Sub(privKey).IsZero() {
 		t.Fatalf(" DeriveKeyPair did not yield the expected key %v\n", hex.EncodeToString(sks.Bytes()))
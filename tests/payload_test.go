@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+func TestApplicationPayloads(t *testing.T) {
+	clientInfo := []byte("client hello")
+	serverInfo := []byte("server hello")
+
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		ke1, err := client.LoginInitWithInfo(test.password, clientInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke1, err = server.Deserialize.KE1(ke1.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(ke1.ClientInfo, clientInfo) {
+			t.Fatal("client info was not transmitted")
+		}
+
+		ke2, err := server.LoginInitWithInfo(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey,
+			test.oprfSeed, record, serverInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Equal(ke2.EncryptedServerInfo, serverInfo) {
+			t.Fatal("server info is not encrypted")
+		}
+
+		ke2, err = client.Deserialize.KE2(ke2.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(client.ServerInfo(), serverInfo) {
+			t.Fatal("server info was not decrypted")
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+			t.Fatal("session keys differ")
+		}
+	}
+}
+
+func TestApplicationPayloads_Tampering(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	// A modified client info is detected by the server.
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	ke1, _ := client.LoginInitWithInfo(test.password, []byte("client hello"))
+	ke1.ClientInfo[0] ^= 0xff
+
+	ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := client.LoginFinish(test.username, test.serverID, ke2); err == nil {
+		t.Fatal("expected error with a tampered client info")
+	}
+
+	// A modified server info is detected by the client.
+	client, _ = conf.Client()
+	server, _ = conf.Server()
+	ke1 = client.LoginInit(test.password)
+
+	ke2, err = server.LoginInitWithInfo(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey,
+		test.oprfSeed, record, []byte("server hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke2.EncryptedServerInfo[0] ^= 0xff
+
+	if _, _, err := client.LoginFinish(test.username, test.serverID, ke2); err == nil {
+		t.Fatal("expected error with a tampered server info")
+	}
+
+	// A trailing byte after the application message is rejected.
+	if _, err := client.Deserialize.KE2(append(ke2.Serialize(), 0)); err == nil {
+		t.Fatal("expected error on trailing data")
+	}
+
+	// Application messages are limited in size.
+	if _, err := client.LoginInitWithInfo(test.password, make([]byte, 1<<16)); !errors.Is(err, opaque.ErrInfoLength) {
+		t.Fatalf("expected %q, got %q", opaque.ErrInfoLength, err)
+	}
+}
+
+func TestApplicationPayloads_ServerInfoLength(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	// The server info is encrypted with a single KDF expansion, of at most 255 blocks.
+	maxLength := 255 * conf.KDF.Size()
+
+	login := func(serverInfo []byte) (*opaque.Client, *message.KE2, error) {
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+		ke2, err := server.LoginInitWithInfo(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+			test.serverPublicKey, test.oprfSeed, record, serverInfo)
+
+		return client, ke2, err
+	}
+
+	client, ke2, err := login(make([]byte, maxLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ke2, err = client.Deserialize.KE2(ke2.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = client.LoginFinish(test.username, test.serverID, ke2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = login(make([]byte, 20000)); !errors.Is(err, opaque.ErrInfoLength) {
+		t.Fatalf("expected %q on oversized server info, got %q", opaque.ErrInfoLength, err)
+	}
+
+	// An oversized KE2, e.g. from a malicious server, is rejected by the client instead of crashing it.
+	client, ke2, err = login(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke2.EncryptedServerInfo = make([]byte, 20000)
+
+	if _, err = client.Deserialize.KE2(ke2.Serialize()); err == nil {
+		t.Fatal("expected error on an oversized KE2")
+	}
+
+	if _, _, err = client.LoginFinish(test.username, test.serverID, ke2); !errors.Is(err, opaque.ErrInfoLength) {
+		t.Fatalf("expected %q on an oversized KE2, got %q", opaque.ErrInfoLength, err)
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestStandardPasswordPolicy(t *testing.T) {
+	policy := opaque.DefaultPasswordPolicy()
+	policy.Denylist = [][]byte{[]byte("CompanyName2024!")}
+
+	for _, password := range []string{
+		"correcthorsebatterystaple", "Tr0ub4dor&3", "n8Fq3wR7tY", "ñéüøkX9#",
+	} {
+		if err := policy.Check([]byte(password)); err != nil {
+			t.Fatalf("unexpected error for %q: %v", password, err)
+		}
+	}
+
+	for _, password := range []string{
+		"", "kX9#vL", strings.Repeat("kX9#vL2q", 200), // lengths
+		"password", "QWERTY123", "companyname2024!", // denylists
+		"P@ssw0rd", "aaaaaaaaaaaa", "abcdefghij", "zxcvbnm1234", "summerwinter", "9876543210", // estimates
+	} {
+		if err := policy.Check([]byte(password)); !errors.Is(err, opaque.ErrPasswordPolicy) {
+			t.Fatalf("expected a policy error for %q, got %v", password, err)
+		}
+	}
+
+	// The estimate grows with the unpredictable characters.
+	if opaque.EstimatePasswordEntropy([]byte("password")) >= opaque.EstimatePasswordEntropy([]byte("password7Kq")) {
+		t.Fatal("unexpected estimates")
+	}
+
+	if e := opaque.EstimatePasswordEntropy(nil); e != 0 {
+		t.Fatalf("unexpected estimate %v of the empty password", e)
+	}
+}
+
+type policyFunc func(password []byte) error
+
+func (f policyFunc) Check(password []byte) error {
+	return f(password)
+}
+
+type breachFunc func(password []byte) (bool, error)
+
+func (f breachFunc) Breached(password []byte) (bool, error) {
+	return f(password)
+}
+
+func TestClient_PasswordPolicy(t *testing.T) {
+	client, _ := opaque.DefaultConfiguration().Client()
+	client.SetPasswordPolicy(opaque.DefaultPasswordPolicy())
+
+	// The breach checker is only queried for the passwords the policy accepts.
+	checked := 0
+	client.SetBreachChecker(breachFunc(func([]byte) (bool, error) {
+		checked++
+		return false, nil
+	}))
+
+	if _, err := client.RegistrationStart([]byte("password")); !errors.Is(err, opaque.ErrPasswordPolicy) {
+		t.Fatalf("expected a policy error on registration, got %v", err)
+	}
+
+	if _, err := client.AuthenticationStart([]byte("password")); !errors.Is(err, opaque.ErrPasswordPolicy) {
+		t.Fatalf("expected a policy error on login, got %v", err)
+	}
+
+	if checked != 0 {
+		t.Fatal("the breach checker was queried for a rejected password")
+	}
+
+	if _, err := client.RegistrationStart([]byte("Tr0ub4dor&3")); err != nil || checked != 1 {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+
+	client, _ = opaque.DefaultConfiguration().Client()
+	if ke1, err := client.AuthenticationStart([]byte("Tr0ub4dor&3")); err != nil || ke1 == nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+
+	// The errors of other policies are wrapped.
+	errCustom := errors.New("no digits")
+	client.SetPasswordPolicy(policyFunc(func(password []byte) error {
+		if strings.ContainsAny(string(password), "0123456789") {
+			return errCustom
+		}
+
+		return nil
+	}))
+
+	if _, err := client.AuthenticationStart([]byte("Tr0ub4dor&3")); !errors.Is(err, opaque.ErrPasswordPolicy) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+
+	client.SetPasswordPolicy(nil)
+
+	if _, err := client.AuthenticationStart([]byte("1")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_RegistrationInitChecks(t *testing.T) {
+	test := newTestParams(t, opaque.DefaultConfiguration())
+	server, _ := test.Server()
+	pk, _ := server.Deserialize.DecodeAkePublicKey(test.serverPublicKey)
+
+	register := func(client *opaque.Client, password string) error {
+		request := client.RegistrationInit([]byte(password))
+
+		response, err := server.RegistrationResponse(request, pk, []byte("id"), test.oprfSeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = client.RegistrationFinalize(response, nil, nil)
+
+		return err
+	}
+
+	client, _ := test.Client()
+	client.SetPasswordPolicy(opaque.DefaultPasswordPolicy())
+	client.SetBreachChecker(breachFunc(func(password []byte) (bool, error) {
+		return string(password) == "Tr0ub4dor&3", nil
+	}))
+
+	// RegistrationInit checks the password too, and the finalization fails on a rejected one.
+	if err := register(client, "password"); !errors.Is(err, opaque.ErrPasswordPolicy) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+
+	if err := register(client, "Tr0ub4dor&3"); !errors.Is(err, opaque.ErrBreachedPassword) {
+		t.Fatalf("expected a breached password error, got %v", err)
+	}
+
+	if err := register(client, "correcthorsebatterystaple"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_LoginInitChecks(t *testing.T) {
+	client, _ := opaque.DefaultConfiguration().Client()
+	client.SetPasswordPolicy(opaque.DefaultPasswordPolicy())
+
+	// LoginInit checks the password too, and LoginFinish fails on a rejected one before processing KE2.
+	if ke1 := client.LoginInit([]byte("password")); ke1 == nil {
+		t.Fatal("expected a KE1")
+	}
+
+	if _, _, err := client.LoginFinish(nil, nil, nil); !errors.Is(err, opaque.ErrPasswordPolicy) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+
+	if _, err := client.LoginInitWithInfo([]byte("password"), []byte("hello")); !errors.Is(err, opaque.ErrPasswordPolicy) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+
+	// An accepted password clears the error.
+	client.LoginInit([]byte("Tr0ub4dor&3"))
+
+	if _, _, err := client.LoginFinish(nil, nil, nil); errors.Is(err, opaque.ErrPasswordPolicy) {
+		t.Fatalf("unexpected policy error: %v", err)
+	}
+}
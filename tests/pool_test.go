@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+func TestServerPool(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		pool, err := conf.ServerPool()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		login := func(server *opaque.Server) (*message.KE2, []byte) {
+			client, _ := conf.Client()
+			ke1 := client.LoginInit(test.password)
+
+			ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey,
+				test.oprfSeed, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.LoginFinish(ke3); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+				t.Fatal("expected equal session keys")
+			}
+
+			return ke2, server.SessionKey()
+		}
+
+		// A recycled Server runs a new login with fresh values.
+		server := pool.GetServerSession()
+		ke2, sessionKey := login(server)
+		nonce := append([]byte(nil), ke2.NonceS...)
+		pool.PutServerSession(server)
+
+		if !bytes.Equal(sessionKey, make([]byte, len(sessionKey))) {
+			t.Fatal("expected the session key to be wiped")
+		}
+
+		if len(server.SessionKey()) != 0 || len(server.ExpectedMAC()) != 0 {
+			t.Fatal("expected empty state after wiping")
+		}
+
+		ke2, _ = login(server)
+		if bytes.Equal(nonce, ke2.NonceS) {
+			t.Fatal("expected a fresh server nonce after wiping")
+		}
+
+		pool.PutServerSession(server)
+
+		for i := 0; i < 3; i++ {
+			server = pool.GetServerSession()
+			login(server)
+			pool.PutServerSession(server)
+		}
+	}
+
+	if _, err := (&opaque.Configuration{}).ServerPool(); err == nil {
+		t.Fatal("expected error on invalid configuration")
+	}
+}
+
+func TestServerPool_Swap(t *testing.T) {
+	password, credID := []byte("password"), []byte("credential")
+
+	newKeys := func(conf *opaque.Configuration) *opaque.ServerKeys {
+		sk, pk, _ := conf.KeyGen()
+		seed, _ := conf.GenerateOPRFSeed()
+
+		return &opaque.ServerKeys{SecretKey: sk, PublicKey: pk, OPRFSeed: seed}
+	}
+
+	register := func(conf *opaque.Configuration, server *opaque.Server) *opaque.ClientRecord {
+		client, _ := conf.Client()
+
+		response, err := server.RegistrationResponseWithKeys(client.RegistrationInit(password), credID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record, _, err := client.RegistrationFinalize(response, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return &opaque.ClientRecord{CredentialIdentifier: credID, RegistrationRecord: record}
+	}
+
+	oldConf := opaque.DefaultConfiguration()
+	oldConf.KSF = 0
+	oldKeys := newKeys(oldConf)
+
+	pool, err := oldConf.ServerPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Swap(oldKeys, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Server taken before a swap of the keys only is not put back.
+	stale := pool.GetServerSession()
+
+	if err = pool.Swap(oldKeys, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.PutServerSession(stale)
+
+	server := pool.GetServerSession()
+	if server == stale {
+		t.Fatal("expected a Server taken before the swap to be dropped")
+	}
+
+	pool.PutServerSession(server)
+
+	server = pool.GetServerSession()
+	oldRecord := register(oldConf, server)
+	pool.PutServerSession(server)
+
+	// A login is in flight when the configuration and keys are swapped.
+	inFlight := pool.GetServerSession()
+	client, _ := oldConf.Client()
+
+	ke2, err := inFlight.LoginInitWithKeys(client.LoginInit(password), oldRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newConf := *confs[1].Conf
+	newConf.KSF = 0
+
+	// Invalid key material leaves the pool unchanged.
+	invalid := newKeys(&newConf)
+	invalid.PublicKey = oldKeys.PublicKey
+
+	if err = pool.Swap(invalid, &newConf); err == nil {
+		t.Fatal("expected an error on mismatching keys")
+	}
+
+	if server = pool.GetServerSession(); server.GetConf().Group != inFlight.GetConf().Group {
+		t.Fatal("expected the pool to be unchanged after a failed swap")
+	}
+
+	pool.PutServerSession(server)
+
+	if err = pool.Swap(newKeys(&newConf), &newConf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new logins use the new configuration and keys.
+	server = pool.GetServerSession()
+	if server.GetConf().Group == inFlight.GetConf().Group {
+		t.Fatal("expected the new configuration after the swap")
+	}
+
+	newRecord := register(&newConf, server)
+	pool.PutServerSession(server)
+
+	server = pool.GetServerSession()
+	newClient, _ := newConf.Client()
+
+	newKE2, err := server.LoginInitWithKeys(newClient.LoginInit(password), newRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, _, err := newClient.LoginFinish(nil, nil, newKE2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.PutServerSession(server)
+
+	// The login in flight completes with the previous ones.
+	ke3, _, err = client.LoginFinish(nil, nil, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = inFlight.LoginFinish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.PutServerSession(inFlight)
+}
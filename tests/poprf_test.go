@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestPOPRF(t *testing.T) {
+	password := []byte("password")
+	credID := randomBytes(32)
+	epoch1 := []byte("epoch-1")
+	epoch2 := []byte("epoch-2")
+
+	for _, conf := range confs {
+		sks, pks, _ := conf.Conf.KeyGen()
+		oprfSeed, _ := conf.Conf.GenerateOPRFSeed()
+
+		register := func(info []byte) *opaque.ClientRecord {
+			client, _ := conf.Conf.Client()
+			server, _ := conf.Conf.Server()
+			client.SetOPRFInfo(info)
+			server.SetOPRFInfo(info)
+
+			pk, _ := server.Deserialize.DecodeAkePublicKey(pks)
+			r2, _ := server.RegistrationResponse(client.RegistrationInit(password), pk, credID, oprfSeed)
+			r3, _, _ := client.RegistrationFinalize(r2, nil, nil)
+
+			return &opaque.ClientRecord{CredentialIdentifier: credID, RegistrationRecord: r3}
+		}
+
+		login := func(record *opaque.ClientRecord, clientInfo, serverInfo []byte) error {
+			client, _ := conf.Conf.Client()
+			server, _ := conf.Conf.Server()
+			client.SetOPRFInfo(clientInfo)
+			server.SetOPRFInfo(serverInfo)
+
+			ke2, err := server.LoginInit(client.LoginInit(password), nil, sks, pks, oprfSeed, record)
+			if err != nil {
+				return err
+			}
+
+			ke3, _, err := client.LoginFinish(nil, nil, ke2)
+			if err != nil {
+				return err
+			}
+
+			return server.LoginFinish(ke3)
+		}
+
+		record := register(epoch1)
+		if err := login(record, epoch1, epoch1); err != nil {
+			t.Fatalf("unexpected error with matching info: %v", err)
+		}
+
+		if err := login(record, epoch2, epoch2); err == nil {
+			t.Fatal("expected error after changing the info")
+		}
+
+		if err := login(record, epoch1, epoch2); err == nil {
+			t.Fatal("expected error with mismatching info")
+		}
+
+		if err := login(record, nil, nil); err == nil {
+			t.Fatal("expected error when falling back to the base mode")
+		}
+
+		// The same credential identifier and seed give distinct randomized passwords in the base mode.
+		base := register(nil)
+		if bytes.Equal(base.MaskingKey, record.MaskingKey) {
+			t.Fatal("POPRF and OPRF outputs are equal")
+		}
+
+		if err := login(base, nil, nil); err != nil {
+			t.Fatalf("unexpected error in base mode: %v", err)
+		}
+	}
+}
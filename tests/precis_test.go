@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestNormalizePassword(t *testing.T) {
+	// "é" as a single code point, and as "e" followed by a combining acute accent.
+	composed := []byte("caf\u00e9 p\u00e4ss")
+	decomposed := []byte("cafe\u0301\u3000pa\u0308ss") // also uses an ideographic space
+
+	a, err := opaque.NormalizePassword(composed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := opaque.NormalizePassword(decomposed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(a, b) {
+		t.Fatalf("normalized passwords differ: %q / %q", a, b)
+	}
+
+	// Full-width characters are mapped to their half-width counterparts.
+	w, err := opaque.NormalizePassword([]byte("\uff50\uff41\uff53\uff53"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(w, []byte("pass")) {
+		t.Fatalf("width mapping failed: %q", w)
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	if _, err := opaque.NormalizePassword(nil); err == nil {
+		t.Fatal("expected error on empty password")
+	}
+
+	if _, err := opaque.NormalizePassword([]byte("pass\u0007word")); err == nil {
+		t.Fatal("expected error on control character")
+	}
+
+	if id, err := opaque.NormalizeIdentity(nil); err != nil || id != nil {
+		t.Fatalf("expected nil identity to pass through, got %v / %v", id, err)
+	}
+}
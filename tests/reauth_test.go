@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestReauthentication(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		ke1 := client.LoginInit(test.password)
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		clientSecret, err := client.ReauthSecret()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serverSecret, err := server.ReauthSecret()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(clientSecret, serverSecret) {
+			t.Fatal("expected equal re-authentication secrets")
+		}
+
+		// A valid exchange, through serialization.
+		cr, _ := conf.Reauthenticator(clientSecret)
+		sr, _ := conf.Reauthenticator(serverSecret)
+
+		request, err := sr.Deserialize.ReauthRequest(cr.ReauthInit().Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := sr.ReauthRespond(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response, err = cr.Deserialize.ReauthResponse(response.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+
+		finish, err := cr.ReauthFinish(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if finish, err = sr.Deserialize.ReauthFinish(finish.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sr.ReauthVerify(finish); err != nil {
+			t.Fatal(err)
+		}
+
+		// Messages out of order are rejected.
+		if _, err := sr.ReauthRespond(request); err == nil {
+			t.Fatal("expected error on second response")
+		}
+
+		if err := cr.ReauthVerify(finish); err == nil {
+			t.Fatal("expected error on client verification")
+		}
+
+		// A client holding another secret is rejected, and detects the server doesn't hold its secret.
+		otherSecret, _ := conf.GenerateOPRFSeed()
+		other, _ := conf.Reauthenticator(otherSecret)
+		sr, _ = conf.Reauthenticator(serverSecret)
+
+		response, err = sr.ReauthRespond(other.ReauthInit())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := other.ReauthFinish(response); !errors.Is(err, opaque.ErrReauthInvalidMac) {
+			t.Fatalf("expected %q, got %q", opaque.ErrReauthInvalidMac, err)
+		}
+
+		// A replayed proof doesn't match the fresh server nonce.
+		sr, _ = conf.Reauthenticator(serverSecret)
+		if _, err := sr.ReauthRespond(request); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sr.ReauthVerify(finish); !errors.Is(err, opaque.ErrReauthInvalidMac) {
+			t.Fatalf("expected %q, got %q", opaque.ErrReauthInvalidMac, err)
+		}
+
+		if _, err := conf.Reauthenticator(nil); err == nil {
+			t.Fatal("expected error on empty secret")
+		}
+
+		// Nil messages are rejected.
+		sr, _ = conf.Reauthenticator(serverSecret)
+		if _, err := sr.ReauthRespond(nil); err == nil {
+			t.Fatal("expected error on nil request")
+		}
+
+		if _, err := cr.ReauthFinish(nil); err == nil {
+			t.Fatal("expected error on nil response")
+		}
+
+		if err := sr.ReauthVerify(nil); err == nil {
+			t.Fatal("expected error on nil finish")
+		}
+
+		if _, err := sr.Deserialize.ReauthRequest(request.Nonce[1:]); err == nil {
+			t.Fatal("expected error on short request")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestClientRecordWithMetadata(t *testing.T) {
+	for _, c := range confs {
+		server, _ := c.Conf.Server()
+		client, _ := c.Conf.Client()
+		_, pk, _ := c.Conf.KeyGen()
+		record := buildRecord(randomBytes(32), randomBytes(c.Conf.Hash.Size()), []byte("yo"), pk, client, server)
+		record.ClientIdentity = []byte("client")
+
+		key := randomBytes(32)
+		metadata := &opaque.RecordMetadata{
+			Created:       time.Unix(1700000000, 0),
+			Configuration: c.Conf.Fingerprint(),
+			SeedVersion:   3,
+		}
+
+		encoded, err := record.SerializeWithMetadata(key, metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, m, err := server.Deserialize.ClientRecordWithMetadata(key, encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !opaque.ConstantTimeCompareRecords(record, decoded) {
+			t.Fatal("expected the same record")
+		}
+
+		if !m.Created.Equal(metadata.Created) || !bytes.Equal(m.Configuration, metadata.Configuration) ||
+			m.SeedVersion != metadata.SeedVersion || m.SchemaVersion != opaque.RecordSchemaVersion {
+			t.Fatalf("unexpected metadata %+v", m)
+		}
+
+		// Any change to the metadata or the record is detected.
+		for i := range encoded {
+			tampered := append([]byte(nil), encoded...)
+			tampered[i] ^= 1
+
+			_, _, err = server.Deserialize.ClientRecordWithMetadata(key, tampered)
+			if !errors.Is(err, opaque.ErrRecordMetadata) {
+				t.Fatalf("expected an error on a change at byte %d, got %v", i, err)
+			}
+		}
+
+		_, _, err = server.Deserialize.ClientRecordWithMetadata(randomBytes(32), encoded)
+		if !errors.Is(err, opaque.ErrRecordMetadata) {
+			t.Fatalf("expected an error under another key, got %v", err)
+		}
+
+		_, _, err = server.Deserialize.ClientRecordWithMetadata(key, encoded[:10])
+		if !errors.Is(err, opaque.ErrRecordMetadata) {
+			t.Fatalf("expected an error on a truncated encoding, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	account := &opaque.Account{Identity: []byte("alice")}
+	test := newTestParams(t, conf)
+	test.username = account.Identity
+
+	record, _ := testRegistration(t, test)
+	if err := account.AddDevice(record); err != nil {
+		t.Fatal(err)
+	}
+
+	codes, err := opaque.GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, code := range codes {
+		test.password = code.Password()
+		r, _ := testRegistration(t, test)
+
+		if err := account.AddRecoveryRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A recovery record registered with another identity is refused.
+	test.username = []byte("bob")
+	r, _ := testRegistration(t, test)
+	test.username = account.Identity
+
+	if err := account.AddRecoveryRecord(r); !errors.Is(err, opaque.ErrAccountIdentity) {
+		t.Fatalf("expected error on another client identity, got %v", err)
+	}
+
+	// The user types in the second code, loosely.
+	typed := strings.ToLower(strings.ReplaceAll(string(codes[1]), "-", " "))
+
+	code, err := opaque.ParseRecoveryCode(typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != codes[1] {
+		t.Fatalf("parsed code %q differs from %q", code, codes[1])
+	}
+
+	recoveryRecord := account.RecoveryRecords[1]
+	test.password = code.Password()
+	testAuthentication(t, test, recoveryRecord)
+
+	if err := account.AcceptRecovery(recoveryRecord.CredentialIdentifier); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(account.RecoveryRecords) != 2 {
+		t.Fatal("recovery record was not consumed")
+	}
+
+	if err := account.CheckLogin(); !errors.Is(err, opaque.ErrPasswordResetRequired) {
+		t.Fatalf("expected password reset to be required, got %v", err)
+	}
+
+	test.password = []byte("new password")
+	newRecord, _ := testRegistration(t, test)
+
+	if err := account.CompletePasswordReset(newRecord); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := account.CheckLogin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(account.Devices) != 1 || account.Devices[0] != newRecord {
+		t.Fatal("device records were not replaced")
+	}
+
+	if err := account.CompletePasswordReset(newRecord); !errors.Is(err, opaque.ErrNoPasswordReset) {
+		t.Fatalf("expected error on unexpected reset, got %v", err)
+	}
+
+	if err := account.AcceptRecovery(randomBytes(32)); !errors.Is(err, opaque.ErrDeviceNotFound) {
+		t.Fatalf("expected error on unknown recovery record, got %v", err)
+	}
+}
+
+func TestRecoveryCodes_Invalid(t *testing.T) {
+	for _, n := range []int{0, -1, 65} {
+		if _, err := opaque.GenerateRecoveryCodes(n); err == nil {
+			t.Fatalf("expected error for %d codes", n)
+		}
+	}
+
+	for _, input := range []string{"", "ABCD", "0000-0000-0000-0000-0000-0000-0000-0000"} {
+		if _, err := opaque.ParseRecoveryCode(input); !errors.Is(err, opaque.ErrRecoveryCode) {
+			t.Fatalf("expected error on %q, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/keyrecovery"
+)
+
+var redactVerbs = []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d", "%T"}
+
+// checkRedacted verifies that no formatting verb prints the secrets of value.
+func checkRedacted(t *testing.T, name string, value interface{}, secrets ...[]byte) {
+	t.Helper()
+
+	for _, verb := range redactVerbs {
+		out := fmt.Sprintf(verb, value)
+
+		for _, secret := range secrets {
+			for _, encoded := range []string{
+				string(secret),
+				hex.EncodeToString(secret),
+				strings.ToUpper(hex.EncodeToString(secret)),
+				fmt.Sprint([]byte(secret)),
+				fmt.Sprintf("%q", secret),
+			} {
+				if strings.Contains(out, encoded) {
+					t.Fatalf("%s: %s prints a secret: %s", name, verb, out)
+				}
+			}
+		}
+
+		if verb != "%T" && !strings.Contains(out, "bytes, fingerprint") {
+			t.Fatalf("%s: %s doesn't describe the secrets: %s", name, verb, out)
+		}
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	ke1 := client.LoginInit(test.password)
+
+	ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, result, err := client.LoginFinishWithResult(test.username, test.serverID, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionKey := client.SessionKey()
+
+	checkRedacted(t, "client", client, sessionKey)
+	checkRedacted(t, "server", server, sessionKey)
+	checkRedacted(t, "record", record, record.MaskingKey, record.Envelope, record.CredentialIdentifier)
+	checkRedacted(t, "registration record", record.RegistrationRecord, record.MaskingKey, record.Envelope)
+	checkRedacted(t, "export key", result.ExportKey, result.ExportKey)
+	checkRedacted(t, "result", result, result.SessionKey, result.ExportKey)
+
+	env := keyrecovery.Deserialize(client.GetConf(), record.Envelope)
+	checkRedacted(t, "envelope", env, env.Nonce, env.AuthTag)
+
+	shares, err := conf.GenerateOPRFKeyShares(2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRedacted(t, "key share", shares[0], shares[0].Share)
+
+	// Fingerprints tell values apart.
+	if fmt.Sprint(opaque.ExportKey("a")) == fmt.Sprint(opaque.ExportKey("b")) {
+		t.Fatal("expected different fingerprints")
+	}
+
+	if fmt.Sprint(opaque.ExportKey(nil)) != "<empty>" || fmt.Sprint((*opaque.ClientRecord)(nil)) != "<nil>" {
+		t.Fatal("unexpected description of empty values")
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestReplayCache(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+		cache := opaque.NewMemoryReplayCache(time.Minute)
+
+		loginInit := func(ke1 []byte) error {
+			server, _ := conf.Server()
+			server.SetReplayCache(cache)
+
+			m, err := server.Deserialize.KE1(ke1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = server.LoginInit(m, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+				record)
+
+			return err
+		}
+
+		client, _ := conf.Client()
+		ke1 := client.LoginInit(test.password).Serialize()
+
+		if err := loginInit(ke1); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := loginInit(ke1); !errors.Is(err, opaque.ErrReplay) {
+			t.Fatalf("expected %q, got %q", opaque.ErrReplay, err)
+		}
+
+		client, _ = conf.Client()
+		if err := loginInit(client.LoginInit(test.password).Serialize()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryReplayCache(t *testing.T) {
+	cache := opaque.NewMemoryReplayCache(10 * time.Millisecond)
+
+	if fresh, _ := cache.Add([]byte("value")); !fresh {
+		t.Fatal("expected a new value to be fresh")
+	}
+
+	if fresh, _ := cache.Add([]byte("value")); fresh {
+		t.Fatal("expected a recorded value not to be fresh")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if fresh, _ := cache.Add([]byte("value")); !fresh {
+		t.Fatal("expected an expired value to be fresh")
+	}
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestResponseBuffer(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		server, _ := conf.Server()
+		buf := make([]byte, server.ResponseBufferSize())
+
+		for i := 0; i < 2; i++ {
+			client, _ := conf.Client()
+			server, _ := conf.Server()
+			server.SetResponseBuffer(buf)
+
+			ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+				test.serverPublicKey, test.oprfSeed, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if &ke2.MaskedResponse[0] != &buf[0] {
+				t.Fatal("expected the masked response to be written into the buffer")
+			}
+
+			ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.LoginFinish(ke3); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+				t.Fatal("expected equal session keys")
+			}
+		}
+
+		// A buffer that is too small is not used.
+		client, _ := conf.Client()
+		server.SetResponseBuffer(make([]byte, 1))
+
+		ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+			test.serverPublicKey, test.oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := client.LoginFinish(test.username, test.serverID, ke2); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHandshakeResult(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test := newTestParams(t, conf)
+		record, exportKey := testRegistration(t, test)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		ke1 := client.LoginInit(test.password)
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, clientResult, err := client.LoginFinishWithResult(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serverResult, err := server.LoginFinishWithResult(ke3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(clientResult.SessionKey, client.SessionKey()) ||
+			!bytes.Equal(serverResult.SessionKey, clientResult.SessionKey) {
+			t.Fatal("unexpected session key")
+		}
+
+		if !bytes.Equal(clientResult.ExportKey, exportKey) || serverResult.ExportKey != nil {
+			t.Fatal("unexpected export key")
+		}
+
+		if len(clientResult.SessionID) == 0 || !bytes.Equal(clientResult.SessionID, serverResult.SessionID) ||
+			bytes.Equal(clientResult.SessionID, clientResult.SessionKey) {
+			t.Fatal("unexpected session identifier")
+		}
+
+		for _, r := range []struct{ client, server []byte }{
+			{clientResult.ClientIdentity, serverResult.ClientIdentity},
+			{clientResult.ServerIdentity, serverResult.ServerIdentity},
+		} {
+			if !bytes.Equal(r.client, r.server) {
+				t.Fatal("client and server have different identities")
+			}
+		}
+
+		if !bytes.Equal(clientResult.ClientIdentity, test.username) ||
+			!bytes.Equal(clientResult.ServerIdentity, test.serverID) {
+			t.Fatal("unexpected identities")
+		}
+
+		if !bytes.Equal(clientResult.ConfigurationFingerprint, conf.Fingerprint()) ||
+			!bytes.Equal(serverResult.ConfigurationFingerprint, conf.Fingerprint()) {
+			t.Fatal("unexpected configuration fingerprint")
+		}
+
+		// A failed login has no result.
+		server, _ = conf.Server()
+		if _, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey,
+			test.oprfSeed, record); err != nil {
+			t.Fatal(err)
+		}
+
+		ke3.Mac[0] ^= 0xff
+
+		if result, err := server.LoginFinishWithResult(ke3); err == nil || result != nil {
+			t.Fatal("expected error on invalid KE3")
+		}
+	}
+}
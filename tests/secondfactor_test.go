@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestSecondFactor(t *testing.T) {
+	assertion, device, other := randomBytes(32), randomBytes(32), randomBytes(32)
+
+	for _, c := range confs {
+		test, record := registeredTestParams(t, c.Conf)
+
+		login := func(clientConf, serverConf *opaque.Configuration) (clientErr, serverErr error) {
+			client, _ := clientConf.Client()
+			server, _ := serverConf.Server()
+
+			ke1 := client.LoginInit(test.password)
+
+			ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey,
+				test.oprfSeed, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+			if err != nil {
+				return err, nil
+			}
+
+			return nil, server.LoginFinish(ke3)
+		}
+
+		withFactors := func(factors ...opaque.SecondFactor) *opaque.Configuration {
+			conf, err := c.Conf.WithSecondFactor(factors...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return conf
+		}
+
+		clientConf := withFactors(opaque.WebAuthnFactor(assertion), opaque.WebAuthnFactor(device))
+		serverConf := withFactors(opaque.WebAuthnFactor(device), opaque.WebAuthnFactor(assertion))
+
+		if !bytes.Equal(clientConf.Context, serverConf.Context) {
+			t.Fatal("the factor encoding must not depend on their order")
+		}
+
+		if clientErr, serverErr := login(clientConf, serverConf); clientErr != nil || serverErr != nil {
+			t.Fatalf("unexpected errors: %v, %v", clientErr, serverErr)
+		}
+
+		// A wrong assertion makes the AKE fail.
+		clientConf = withFactors(opaque.WebAuthnFactor(other), opaque.WebAuthnFactor(device))
+
+		clientErr, _ := login(clientConf, serverConf)
+		if clientErr == nil {
+			t.Fatal("expected error on wrong second factor")
+		}
+
+		// A missing factor too.
+		clientErr, _ = login(withFactors(opaque.WebAuthnFactor(assertion)), serverConf)
+		if clientErr == nil {
+			t.Fatal("expected error on missing second factor")
+		}
+
+		if !bytes.Equal(withFactors().Context, c.Conf.Context) {
+			t.Fatal("expected unchanged context without factors")
+		}
+
+		// Low-entropy evidence, e.g. a TOTP code, is refused.
+		if _, err := c.Conf.WithSecondFactor(opaque.WebAuthnFactor([]byte("123456"))); err == nil {
+			t.Fatal("expected error on short second-factor evidence")
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque/securemem"
+)
+
+func newLockedBuffer(t *testing.T, secret []byte) *securemem.Buffer {
+	t.Helper()
+
+	buf, err := securemem.Copy(secret)
+	if errors.Is(err, securemem.ErrUnsupported) {
+		t.Skip(err)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf
+}
+
+func TestSecureMem(t *testing.T) {
+	secret := []byte("password")
+	buf := newLockedBuffer(t, secret)
+
+	if !bytes.Equal(buf.Bytes(), []byte("password")) {
+		t.Fatal("unexpected buffer content")
+	}
+
+	if !isZero(secret) {
+		t.Fatal("expected the source to be zeroed")
+	}
+
+	if err := buf.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Bytes() != nil {
+		t.Fatal("expected no content after Destroy")
+	}
+
+	if err := buf.Destroy(); err != nil {
+		t.Fatalf("expected Destroy to be idempotent, got %v", err)
+	}
+
+	for _, size := range []int{0, -1} {
+		if _, err := securemem.New(size); err == nil {
+			t.Fatalf("expected an error for size %d", size)
+		}
+	}
+
+	buf, err := securemem.New(5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(buf.Bytes()) != 5000 || !isZero(buf.Bytes()) {
+		t.Fatal("expected a zeroed buffer of the requested size")
+	}
+
+	_ = buf.Destroy()
+}
+
+func TestLockedMemory(t *testing.T) {
+	_ = newLockedBuffer(t, []byte{1}).Destroy()
+
+	for _, c := range confs {
+		locked := *c.Conf
+		locked.LockedMemory = true
+
+		test := newTestParams(t, &locked)
+		record, registrationExportKey := testRegistration(t, test)
+
+		if !bytes.Equal(locked.Serialize(), c.Conf.Serialize()) {
+			t.Fatal("expected LockedMemory to not be serialized")
+		}
+
+		// Locked and heap ends interoperate.
+		client, _ := locked.Client()
+		server, _ := c.Conf.Server()
+
+		ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+			test.serverPublicKey, test.oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, exportKey, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+			t.Fatal("expected equal session keys")
+		}
+
+		if !bytes.Equal(exportKey, registrationExportKey) {
+			t.Fatal("expected equal export keys")
+		}
+
+		client.Wipe()
+
+		if len(client.SessionKey()) != 0 {
+			t.Fatal("expected the session key to be released")
+		}
+	}
+}
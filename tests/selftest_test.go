@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestSelfTest(t *testing.T) {
+	for _, c := range confs {
+		if err := opaque.SelfTest(c.Conf); err != nil {
+			t.Fatalf("%v: %v", c.Conf.OPRF, err)
+		}
+	}
+
+	if err := opaque.SelfTest(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The configurations without known answers are tested for agreement.
+	for _, protocol := range []opaque.Protocol{opaque.TripleDH, opaque.KEMAKE, opaque.HMQV} {
+		conf := opaque.DefaultConfiguration()
+		conf.KSF = 0
+		conf.Context = []byte("self-test")
+		conf.Protocol = protocol
+
+		if err := opaque.SelfTest(conf); err != nil {
+			t.Fatalf("protocol %d: %v", protocol, err)
+		}
+	}
+}
+
+func TestSelfTest_InvalidConfiguration(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.OPRF = 0
+
+	if err := opaque.SelfTest(conf); !errors.Is(err, opaque.ErrSelfTest) {
+		t.Fatalf("expected %q, got %v", opaque.ErrSelfTest, err)
+	}
+}
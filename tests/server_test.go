@@ -9,12 +9,12 @@
 package opaque_test
 
 import (
+	"bytes"
 	"errors"
 	"strings"
 	"testing"
 
 	"github.com/bytemare/opaque"
-	"github.com/bytemare/opaque/internal"
 	"github.com/bytemare/opaque/internal/encoding"
 )
 
@@ -54,8 +54,8 @@ func TestServerInit_InvalidPublicKey(t *testing.T) {
 	*/
 	for _, conf := range confs {
 		server, _ := conf.Conf.Server()
-		sk, _ := conf.Conf.KeyGen()
-		oprfSeed := internal.RandomBytes(conf.Conf.Hash.Size())
+		sk, _, _ := conf.Conf.KeyGen()
+		oprfSeed := randomBytes(conf.Conf.Hash.Size())
 
 		expected := "input server public key's length is invalid"
 		if _, err := server.LoginInit(nil, nil, sk, nil, oprfSeed, nil); err == nil ||
@@ -77,19 +77,19 @@ func TestServerInit_InvalidOPRFSeedLength(t *testing.T) {
 	*/
 	for _, conf := range confs {
 		server, _ := conf.Conf.Server()
-		sk, pk := conf.Conf.KeyGen()
+		sk, pk, _ := conf.Conf.KeyGen()
 		expected := opaque.ErrInvalidOPRFSeedLength
 
 		if _, err := server.LoginInit(nil, nil, sk, pk, nil, nil); err == nil || !errors.Is(err, expected) {
 			t.Fatalf("expected error on nil seed - got %s", err)
 		}
 
-		seed := internal.RandomBytes(conf.Conf.Hash.Size() - 1)
+		seed := randomBytes(conf.Conf.Hash.Size() - 1)
 		if _, err := server.LoginInit(nil, nil, sk, pk, seed, nil); err == nil || !errors.Is(err, expected) {
 			t.Fatalf("expected error on bad seed - got %s", err)
 		}
 
-		seed = internal.RandomBytes(conf.Conf.Hash.Size() + 1)
+		seed = randomBytes(conf.Conf.Hash.Size() + 1)
 		if _, err := server.LoginInit(nil, nil, sk, pk, seed, nil); err == nil || !errors.Is(err, expected) {
 			t.Fatalf("expected error on bad seed - got %s", err)
 		}
@@ -102,7 +102,7 @@ func TestServerInit_NilSecretKey(t *testing.T) {
 	*/
 	for _, conf := range confs {
 		server, _ := conf.Conf.Server()
-		_, pk := conf.Conf.KeyGen()
+		_, pk, _ := conf.Conf.KeyGen()
 		expected := "invalid server secret key: "
 
 		if _, err := server.LoginInit(nil, nil, nil, pk, nil, nil); err == nil ||
@@ -134,11 +134,11 @@ func TestServerInit_InvalidEnvelope(t *testing.T) {
 	*/
 	for _, conf := range confs {
 		server, _ := conf.Conf.Server()
-		sk, pk := conf.Conf.KeyGen()
-		oprfSeed := internal.RandomBytes(conf.Conf.Hash.Size())
+		sk, pk, _ := conf.Conf.KeyGen()
+		oprfSeed := randomBytes(conf.Conf.Hash.Size())
 		client, _ := conf.Conf.Client()
-		rec := buildRecord(internal.RandomBytes(32), oprfSeed, []byte("yo"), pk, client, server)
-		rec.Envelope = internal.RandomBytes(15)
+		rec := buildRecord(randomBytes(32), oprfSeed, []byte("yo"), pk, client, server)
+		rec.Envelope = randomBytes(15)
 
 		expected := "record has invalid envelope length"
 		if _, err := server.LoginInit(nil, nil, sk, pk, oprfSeed, rec); err == nil ||
@@ -156,8 +156,8 @@ func TestServerInit_InvalidData(t *testing.T) {
 		server, _ := conf.Conf.Server()
 		ke1 := encoding.Concatenate(
 			getBadElement(t, conf),
-			internal.RandomBytes(server.GetConf().NonceLen),
-			internal.RandomBytes(server.GetConf().AkePointLength),
+			randomBytes(server.GetConf().NonceLen),
+			randomBytes(server.GetConf().AkePointLength),
 		)
 		expected := "blinded data is an invalid point"
 		if _, err := server.Deserialize.KE1(ke1); err == nil || !strings.HasPrefix(err.Error(), expected) {
@@ -186,16 +186,47 @@ func TestServerInit_InvalidEPKU(t *testing.T) {
 	}
 }
 
+func TestServer_VerifyRegistrationRecord(t *testing.T) {
+	for _, conf := range confs {
+		server, _ := conf.Conf.Server()
+		client, _ := conf.Conf.Client()
+		_, pk, _ := conf.Conf.KeyGen()
+		seed := randomBytes(conf.Conf.Hash.Size())
+
+		if err := server.VerifyRegistrationRecord(
+			buildRecord(randomBytes(32), seed, []byte("yo"), pk, client, server).RegistrationRecord,
+		); err != nil {
+			t.Fatalf("unexpected error on a valid record: %v", err)
+		}
+
+		for name, tamper := range map[string]func(r *opaque.ClientRecord){
+			"nil public key":        func(r *opaque.ClientRecord) { r.PublicKey = nil },
+			"identity public key":   func(r *opaque.ClientRecord) { r.PublicKey = r.PublicKey.Sub(r.PublicKey) },
+			"short masking key":     func(r *opaque.ClientRecord) { r.MaskingKey = r.MaskingKey[1:] },
+			"long envelope":         func(r *opaque.ClientRecord) { r.Envelope = append(r.Envelope, 0) },
+			"missing envelope":      func(r *opaque.ClientRecord) { r.Envelope = nil },
+			"nil registration data": func(r *opaque.ClientRecord) { r.RegistrationRecord = nil },
+		} {
+			rec := buildRecord(randomBytes(32), seed, []byte("yo"), pk, client, server)
+			tamper(rec)
+
+			if err := server.VerifyRegistrationRecord(rec.RegistrationRecord); !errors.Is(err, opaque.ErrInvalidRecord) {
+				t.Fatalf("expected an invalid record error on %s, got %v", name, err)
+			}
+		}
+	}
+}
+
 func TestServerFinish_InvalidKE3Mac(t *testing.T) {
 	/*
 		ke3 mac is invalid
 	*/
 	conf := opaque.DefaultConfiguration()
-	credId := internal.RandomBytes(32)
-	oprfSeed := internal.RandomBytes(conf.Hash.Size())
+	credId := randomBytes(32)
+	oprfSeed := randomBytes(conf.Hash.Size())
 	client, _ := conf.Client()
 	server, _ := conf.Server()
-	sk, pk := conf.KeyGen()
+	sk, pk, _ := conf.KeyGen()
 	rec := buildRecord(credId, oprfSeed, []byte("yo"), pk, client, server)
 	ke1 := client.LoginInit([]byte("yo"))
 	ke2, err := server.LoginInit(ke1, nil, sk, pk, oprfSeed, rec)
@@ -221,7 +252,7 @@ func TestServerSetAKEState_InvalidInput(t *testing.T) {
 		Test an invalid state
 	*/
 
-	buf := internal.RandomBytes(conf.MAC.Size() + conf.KDF.Size() + 1)
+	buf := randomBytes(conf.MAC.Size() + conf.KDF.Size() + 1)
 
 	server, _ := conf.Server()
 	if err := server.SetAKEState(buf); err == nil || err.Error() != errInvalidStateLength.Error() {
@@ -232,11 +263,11 @@ func TestServerSetAKEState_InvalidInput(t *testing.T) {
 		A state already exists.
 	*/
 
-	credId := internal.RandomBytes(32)
-	seed := internal.RandomBytes(conf.Hash.Size())
+	credId := randomBytes(32)
+	seed := randomBytes(conf.Hash.Size())
 	client, _ := conf.Client()
 	server, _ = conf.Server()
-	sk, pk := conf.KeyGen()
+	sk, pk, _ := conf.KeyGen()
 	rec := buildRecord(credId, seed, []byte("yo"), pk, client, server)
 	ke1 := client.LoginInit([]byte("yo"))
 	_, _ = server.LoginInit(ke1, nil, sk, pk, seed, rec)
@@ -245,3 +276,146 @@ func TestServerSetAKEState_InvalidInput(t *testing.T) {
 		t.Fatalf("Expected error for SetAKEState. want %q, got %q", errStateExists, err)
 	}
 }
+
+func TestServerKeyMaterial(t *testing.T) {
+	for _, c := range confs {
+		server, _ := c.Conf.Server()
+		sks, pks, _ := c.Conf.KeyGen()
+		otherSKS, _, _ := c.Conf.KeyGen()
+		seed, _ := c.Conf.GenerateOPRFSeed()
+
+		if _, err := server.LoginInitWithKeys(nil, nil); err == nil {
+			t.Fatal("expected error without key material")
+		}
+
+		for name, in := range map[string][4][]byte{
+			"identity too long":  {make([]byte, 1<<16), sks, pks, seed},
+			"invalid secret key": {nil, make([]byte, len(sks)), pks, seed},
+			"invalid public key": {nil, sks, getBadElement(t, c), seed},
+			"mismatching keys":   {nil, otherSKS, pks, seed},
+			"short seed":         {nil, sks, pks, seed[1:]},
+		} {
+			if err := server.SetKeyMaterial(in[0], in[1], in[2], in[3]); err == nil {
+				t.Fatalf("%s: expected error", name)
+			}
+		}
+
+		ids := []byte("server")
+		if err := server.SetKeyMaterial(ids, sks, pks, seed); err != nil {
+			t.Fatal(err)
+		}
+
+		client, _ := c.Conf.Client()
+		credID := []byte("client")
+
+		r2, err := server.RegistrationResponseWithKeys(client.RegistrationInit([]byte("password")), credID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r3, _, err := client.RegistrationFinalize(r2, nil, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client, _ = c.Conf.Client()
+		ke2, err := server.LoginInitWithKeys(client.LoginInit([]byte("password")),
+			&opaque.ClientRecord{CredentialIdentifier: credID, RegistrationRecord: r3})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(nil, ids, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		server.Wipe()
+
+		if _, err = server.RegistrationResponseWithKeys(client.RegistrationInit([]byte("password")), credID); err == nil {
+			t.Fatal("expected error after Wipe")
+		}
+	}
+}
+
+func TestDeriveAKEKeyPair(t *testing.T) {
+	for _, c := range confs {
+		seed := randomBytes(opaque.SeedLength)
+
+		sks, pks, err := opaque.DeriveAKEKeyPair(c.Conf, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sks2, pks2, _ := opaque.DeriveAKEKeyPair(c.Conf, seed)
+		if !bytes.Equal(sks, sks2) || !bytes.Equal(pks, pks2) {
+			t.Fatal("expected the same key pair from the same seed")
+		}
+
+		other, _, _ := opaque.DeriveAKEKeyPair(c.Conf, randomBytes(opaque.SeedLength))
+		if bytes.Equal(sks, other) {
+			t.Fatal("expected different key pairs from different seeds")
+		}
+
+		server, _ := c.Conf.Server()
+		oprfSeed, _ := c.Conf.GenerateOPRFSeed()
+
+		if err = server.SetKeyMaterial(nil, sks, pks, oprfSeed); err != nil {
+			t.Fatalf("invalid derived key pair: %v", err)
+		}
+
+		sks, pks, err = opaque.GenerateAKEKeyPair(c.Conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = server.SetKeyMaterial(nil, sks, pks, oprfSeed); err != nil {
+			t.Fatalf("invalid generated key pair: %v", err)
+		}
+
+		if _, _, err = opaque.DeriveAKEKeyPair(c.Conf, seed[1:]); !errors.Is(err, opaque.ErrInvalidSeedLength) {
+			t.Fatalf("expected error on short seed, got %v", err)
+		}
+	}
+
+	if _, _, err := opaque.DeriveAKEKeyPair(&opaque.Configuration{}, randomBytes(opaque.SeedLength)); err == nil {
+		t.Fatal("expected error on invalid configuration")
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	for _, conf := range confs {
+		server, _ := conf.Conf.Server()
+		client, _ := conf.Conf.Client()
+		_, pk, _ := conf.Conf.KeyGen()
+		record := buildRecord(randomBytes(32), randomBytes(conf.Conf.Hash.Size()), []byte("yo"), pk, client, server)
+
+		if err := opaque.ValidateRecord(conf.Conf, record); err != nil {
+			t.Fatalf("unexpected error on a valid record: %v", err)
+		}
+
+		// The record fails in the other configurations.
+		for _, other := range confs {
+			if other.Conf.AKE == conf.Conf.AKE && other.Conf.Hash == conf.Conf.Hash {
+				continue
+			}
+
+			if err := opaque.ValidateRecord(other.Conf, record); !errors.Is(err, opaque.ErrInvalidRecord) {
+				t.Fatalf("expected an invalid record error in another configuration, got %v", err)
+			}
+		}
+
+		record.CredentialIdentifier = nil
+		if err := opaque.ValidateRecord(conf.Conf, record); !errors.Is(err, opaque.ErrInvalidRecord) {
+			t.Fatalf("expected an invalid record error without a credential identifier, got %v", err)
+		}
+
+		if err := opaque.ValidateRecord(conf.Conf, nil); !errors.Is(err, opaque.ErrInvalidRecord) {
+			t.Fatalf("expected an invalid record error on a nil record, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/opaqueprop"
+)
+
+var errMessageTooLong = errors.New("message is longer than the accepted maximum")
+
+func TestDeserializerCheck(t *testing.T) {
+	for _, c := range confs {
+		test, record := registeredTestParams(t, c.Conf)
+
+		client, _ := c.Conf.Client()
+		server, _ := c.Conf.Server()
+		d, _ := c.Conf.Deserializer()
+
+		ke1, err := client.LoginInitWithInfo(test.password, []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey,
+			test.oprfSeed, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		messages := map[opaque.MessageType][]byte{
+			opaque.RegistrationRecordMessage: record.RegistrationRecord.Serialize(),
+			opaque.KE1Message:                ke1.Serialize(),
+			opaque.KE2Message:                ke2.Serialize(),
+			opaque.KE3Message:                ke3.Serialize(),
+		}
+
+		for typ, m := range messages {
+			if err := d.Check(typ, m); err != nil {
+				t.Fatalf("unexpected error on valid message %d: %v", typ, err)
+			}
+
+			if err := d.Check(typ, m[:len(m)-1]); err == nil {
+				t.Fatalf("expected error on truncated message %d", typ)
+			}
+
+			if len(m) > d.MaxMessageLength() {
+				t.Fatalf("message %d is longer than the maximum %d", typ, d.MaxMessageLength())
+			}
+		}
+
+		if err := d.Check(0, nil); err == nil {
+			t.Fatal("expected error on unknown message type")
+		}
+
+		// A KE1 with a garbage length prefix doesn't pass the structural check.
+		garbage := encoding.Concat(ke1.Serialize()[:len(ke1.Serialize())-7], []byte{0, 9, 1, 2, 3, 4, 5, 6, 7})
+		if err := d.Check(opaque.KE1Message, garbage); err == nil || !strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
+			t.Fatalf("expected error %q, got %v", errInvalidMessageLength, err)
+		}
+	}
+}
+
+func TestDeserializerSizeLimits(t *testing.T) {
+	client, _ := opaque.DefaultConfiguration().Client()
+	server, _ := opaque.DefaultConfiguration().Server()
+
+	ke1, err := client.LoginInitWithInfo([]byte("password"), []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := ke1.Serialize()
+
+	// Oversized messages are rejected before any decoding.
+	oversized := encoding.Concat(m, make([]byte, server.Deserialize.MaxMessageLength()))
+	if _, err := server.Deserialize.KE1(oversized); err == nil || err.Error() != errMessageTooLong.Error() {
+		t.Fatalf("expected error %q, got %v", errMessageTooLong, err)
+	}
+
+	if _, err := client.Deserialize.KE2(oversized); err == nil || err.Error() != errMessageTooLong.Error() {
+		t.Fatalf("expected error %q, got %v", errMessageTooLong, err)
+	}
+
+	if _, err := server.Deserialize.KE3(oversized); err == nil || !strings.HasPrefix(err.Error(), errInvalidMessageLength.Error()) {
+		t.Fatalf("expected error %q, got %v", errInvalidMessageLength, err)
+	}
+
+	// Lowering the maximum application message length.
+	server.Deserialize.SetMaxInfoLength(5)
+
+	if _, err := server.Deserialize.KE1(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server.Deserialize.SetMaxInfoLength(4)
+
+	if _, err := server.Deserialize.KE1(m); err == nil || err.Error() != errMessageTooLong.Error() {
+		t.Fatalf("expected error %q, got %v", errMessageTooLong, err)
+	}
+
+	server.Deserialize.SetMaxInfoLength(0)
+
+	if _, err := server.Deserialize.KE1(m); err == nil || err.Error() != errMessageTooLong.Error() {
+		t.Fatalf("expected error %q, got %v", errMessageTooLong, err)
+	}
+
+	if _, err := server.Deserialize.KE1(client.LoginInit([]byte("password")).Serialize()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The maximum is clamped.
+	server.Deserialize.SetMaxInfoLength(1 << 20)
+	d, _ := opaque.DefaultConfiguration().Deserializer()
+
+	if server.Deserialize.MaxMessageLength() != d.MaxMessageLength() {
+		t.Fatal("expected the maximum application message length to be clamped")
+	}
+
+	// Keys of the wrong length are rejected.
+	if _, err := d.DecodeAkePublicKey(make([]byte, 1024)); err == nil {
+		t.Fatal("expected error on long public key")
+	}
+
+	if _, err := d.DecodeAkePrivateKey(make([]byte, 1024)); err == nil {
+		t.Fatal("expected error on long private key")
+	}
+}
+
+func TestConfigurationMessageLength(t *testing.T) {
+	for _, c := range confs {
+		reg, err := opaqueprop.Register(c.Conf, &opaqueprop.Credentials{
+			Password:             []byte("password"),
+			CredentialIdentifier: []byte("client"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := reg.Login(reg.Credentials.Password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lengths := map[opaque.MessageType]int{
+			opaque.RegistrationRequestMessage:  c.Conf.RegistrationRequestLength(),
+			opaque.RegistrationResponseMessage: c.Conf.RegistrationResponseLength(),
+			opaque.RegistrationRecordMessage:   c.Conf.RegistrationRecordLength(),
+			opaque.KE1Message:                  c.Conf.KE1Length(),
+			opaque.KE2Message:                  c.Conf.KE2Length(),
+			opaque.KE3Message:                  c.Conf.KE3Length(),
+		}
+
+		for _, m := range h.Sequence {
+			if lengths[m.Type] != len(m.Encoded) {
+				t.Fatalf("message %d: expected length %d, got %d", m.Type, len(m.Encoded), lengths[m.Type])
+			}
+
+			if length, err := c.Conf.MessageLength(m.Type); err != nil || length != len(m.Encoded) {
+				t.Fatalf("message %d: expected length %d, got %d, %v", m.Type, len(m.Encoded), length, err)
+			}
+		}
+
+		if _, err = c.Conf.MessageLength(0); err == nil {
+			t.Fatal("expected error on unknown message type")
+		}
+	}
+
+	invalid := &opaque.Configuration{OPRF: 0}
+	if _, err := invalid.MessageLength(opaque.KE1Message); err == nil || invalid.KE1Length() != 0 {
+		t.Fatal("expected an error and a length of 0 for an invalid configuration")
+	}
+}
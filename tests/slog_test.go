@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build go1.21
+
+package opaque_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestSetLogger(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.KSF = 0
+	password, credID := []byte("password"), []byte("credential")
+	sk, pk, _ := conf.KeyGen()
+	seed, _ := conf.GenerateOPRFSeed()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	client.SetLogger(logger)
+	server.SetLogger(logger)
+
+	pks, _ := server.Deserialize.DecodeAkePublicKey(pk)
+
+	response, err := server.RegistrationResponse(client.RegistrationInit(password), pks, credID, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registration, exportKey, err := client.RegistrationFinalize(response, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := &opaque.ClientRecord{CredentialIdentifier: credID, RegistrationRecord: registration}
+
+	ke2, err := server.LoginInit(client.LoginInit(password), nil, sk, pk, seed, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, _, err := client.LoginFinish(nil, nil, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	out := logs.String()
+
+	for _, event := range []string{
+		"opaque: configuration", "opaque: registration init", "opaque: registration response",
+		"opaque: registration finalize", "opaque: login init", "opaque: login response", "opaque: login finish",
+		"opaque: login verify", "message=KE2", "size=", "duration=",
+	} {
+		if !strings.Contains(out, event) {
+			t.Fatalf("missing %q in the logs:\n%s", event, out)
+		}
+	}
+
+	for name, secret := range map[string][]byte{
+		"password":    password,
+		"session key": client.SessionKey(),
+		"export key":  exportKey,
+		"masking key": registration.MaskingKey,
+		"envelope":    registration.Envelope,
+		"client MAC":  ke3.Mac,
+		"secret key":  sk,
+		"OPRF seed":   seed,
+	} {
+		if strings.Contains(out, string(secret)) || strings.Contains(out, hex.EncodeToString(secret)) {
+			t.Fatalf("the %s is in the logs:\n%s", name, out)
+		}
+	}
+
+	// Failures are logged with their error.
+	logs.Reset()
+
+	ke3.Mac[0] ^= 1
+
+	if err = server.LoginFinish(ke3); err == nil {
+		t.Fatal("expected an error on an invalid KE3")
+	}
+
+	if !strings.Contains(logs.String(), "error=") {
+		t.Fatalf("missing the error in the logs:\n%s", logs.String())
+	}
+}
+
+func TestSetLogger_Level(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	client, _ := opaque.NewClient(nil)
+	client.SetLogger(logger)
+	client.LoginInit([]byte("password"))
+
+	if logs.Len() != 0 {
+		t.Fatalf("unexpected logs above the Debug level:\n%s", logs.String())
+	}
+
+	// A nil logger disables the logs.
+	client.SetLogger(nil)
+	client.LoginInit([]byte("password"))
+}
+
+func TestServerPool_Logger(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	conf.KSF = 0
+	_, pk, _ := conf.KeyGen()
+	seed, _ := conf.GenerateOPRFSeed()
+
+	pool, err := conf.ServerPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// The logger of the previous login doesn't follow a recycled Server.
+	server := pool.GetServerSession()
+	server.SetLogger(logger)
+	pool.PutServerSession(server)
+
+	server = pool.GetServerSession()
+	client, _ := conf.Client()
+	client.SetLogger(logger)
+	client.Wipe()
+	logs.Reset()
+
+	pks, _ := server.Deserialize.DecodeAkePublicKey(pk)
+	if _, err = server.RegistrationResponse(client.RegistrationInit([]byte("password")), pks, []byte("id"),
+		seed); err != nil {
+		t.Fatal(err)
+	}
+
+	if logs.Len() != 0 {
+		t.Fatalf("unexpected logs after wiping:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaquessh"
+)
+
+func TestSSHAuthentication(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	server := &opaquessh.Server{
+		Configuration: conf,
+		Lookup: func(user string) (*opaque.ClientRecord, error) {
+			if user == string(record.CredentialIdentifier) {
+				return record, nil
+			}
+
+			return nil, nil
+		},
+		ServerIdentity:  test.serverID,
+		ServerSecretKey: test.serverSecretKey,
+		ServerPublicKey: test.serverPublicKey,
+		OPRFSeed:        test.oprfSeed,
+	}
+
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConf := &ssh.ServerConfig{KeyboardInteractiveCallback: server.KeyboardInteractiveCallback}
+	serverConf.AddHostKey(signer)
+
+	connect := func(user string, password []byte) (*ssh.Permissions, error) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		done := make(chan *ssh.Permissions)
+
+		go func() {
+			s, err := ln.Accept()
+			if err != nil {
+				done <- nil
+				return
+			}
+			defer s.Close()
+
+			conn, _, _, err := ssh.NewServerConn(s, serverConf)
+			if err != nil {
+				done <- nil
+				return
+			}
+
+			done <- conn.Permissions
+		}()
+
+		clientConf := &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{opaquessh.AuthMethod(conf, password, test.username, test.serverID)},
+			HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+		}
+
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		_, _, _, err = ssh.NewClientConn(c, ln.Addr().String(), clientConf)
+		if err != nil {
+			_ = c.Close()
+		}
+
+		return <-done, err
+	}
+
+	permissions, err := connect(string(record.CredentialIdentifier), test.password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if permissions == nil ||
+		permissions.Extensions[opaquessh.ExtensionCredentialIdentifier] != string(record.CredentialIdentifier) {
+		t.Fatal("unexpected permissions")
+	}
+
+	if _, err := connect(string(record.CredentialIdentifier), []byte("wrong")); err == nil {
+		t.Fatal("expected authentication failure with a wrong password")
+	}
+
+	if _, err := connect("unknown", test.password); err == nil {
+		t.Fatal("expected authentication failure for an unknown user")
+	}
+}
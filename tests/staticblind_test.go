@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+)
+
+func TestBlindStaticKey(t *testing.T) {
+	for _, c := range confs {
+		blinded := *c.Conf
+		blinded.BlindStaticKey = true
+
+		parallel := blinded
+		parallel.ParallelDH = true
+
+		test, record := registeredTestParams(t, c.Conf)
+
+		// A blinding server interoperates with any client, over several logins with different blinds.
+		for _, conf := range []*opaque.Configuration{&blinded, &parallel, &blinded} {
+			client, _ := c.Conf.Client()
+			server, _ := conf.Server()
+
+			ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+				test.serverPublicKey, test.oprfSeed, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.LoginFinish(ke3); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+				t.Fatal("expected equal session keys")
+			}
+		}
+
+		if !bytes.Equal(blinded.Serialize(), c.Conf.Serialize()) {
+			t.Fatal("the blinding must not change the serialization")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Registration and login through the C interface, linked against the shared library built from ./capi.
+
+#include <stdio.h>
+#include <string.h>
+
+#include "libopaquego.h"
+
+#define CHECK(call)                                                    \
+	do {                                                               \
+		int ret = (call);                                              \
+		if (ret != OPAQUE_OK) {                                        \
+			fprintf(stderr, "%s:%d: %s: %d\n", __FILE__, __LINE__, #call, ret); \
+			return 1;                                                  \
+		}                                                              \
+	} while (0)
+
+#define EXPECT(cond)                                                   \
+	do {                                                               \
+		if (!(cond)) {                                                 \
+			fprintf(stderr, "%s:%d: %s\n", __FILE__, __LINE__, #cond); \
+			return 1;                                                  \
+		}                                                              \
+	} while (0)
+
+int main(void) {
+	uint8_t password[] = "password", wrong[] = "wrong", credID[] = "credential";
+	uint8_t *conf, *sk, *pk, *seed, *req, *resp, *record, *exportKey, *ke1, *ke2, *ke3, *clientKey, *loginExportKey,
+		*serverKey;
+	size_t confLen, skLen, pkLen, seedLen, reqLen, respLen, recordLen, exportKeyLen, ke1Len, ke2Len, ke3Len,
+		clientKeyLen, loginExportKeyLen, serverKeyLen;
+	opaque_client client;
+	opaque_server server;
+
+	CHECK(opaque_default_configuration(&conf, &confLen));
+	CHECK(opaque_generate_key_pair(conf, confLen, &sk, &skLen, &pk, &pkLen));
+	CHECK(opaque_generate_oprf_seed(conf, confLen, &seed, &seedLen));
+	CHECK(opaque_client_new(conf, confLen, &client));
+	CHECK(opaque_server_new(conf, confLen, NULL, 0, sk, skLen, pk, pkLen, seed, seedLen, &server));
+
+	// Registration.
+	CHECK(opaque_client_registration_init(client, password, sizeof(password), &req, &reqLen));
+	CHECK(opaque_server_registration_response(server, req, reqLen, credID, sizeof(credID), &resp, &respLen));
+	CHECK(opaque_client_registration_finalize(client, resp, respLen, NULL, 0, NULL, 0, &record, &recordLen,
+		&exportKey, &exportKeyLen));
+
+	// Login.
+	CHECK(opaque_client_login_init(client, password, sizeof(password), &ke1, &ke1Len));
+	CHECK(opaque_server_login_init(server, ke1, ke1Len, record, recordLen, credID, sizeof(credID), NULL, 0, &ke2,
+		&ke2Len));
+	CHECK(opaque_client_login_finish(client, ke2, ke2Len, NULL, 0, NULL, 0, &ke3, &ke3Len, &clientKey, &clientKeyLen,
+		&loginExportKey, &loginExportKeyLen));
+	CHECK(opaque_server_login_finish(server, ke3, ke3Len, &serverKey, &serverKeyLen));
+
+	EXPECT(clientKeyLen == serverKeyLen && memcmp(clientKey, serverKey, clientKeyLen) == 0);
+	EXPECT(exportKeyLen == loginExportKeyLen && memcmp(exportKey, loginExportKey, exportKeyLen) == 0);
+
+	opaque_free(ke1);
+	opaque_free(ke2);
+
+	// Failures.
+	CHECK(opaque_client_login_init(client, wrong, sizeof(wrong), &ke1, &ke1Len));
+	CHECK(opaque_server_login_init(server, ke1, ke1Len, record, recordLen, credID, sizeof(credID), NULL, 0, &ke2,
+		&ke2Len));
+	EXPECT(opaque_client_login_finish(client, ke2, ke2Len, NULL, 0, NULL, 0, &ke3, &ke3Len, &clientKey,
+		&clientKeyLen, &loginExportKey, &loginExportKeyLen) == OPAQUE_ERROR_PROTOCOL);
+	EXPECT(opaque_server_login_finish(server, (uint8_t *) "", 0, &serverKey, &serverKeyLen) == OPAQUE_ERROR_PROTOCOL);
+	EXPECT(opaque_client_new((uint8_t *) "", 0, &client) == OPAQUE_ERROR_INVALID_ARGUMENT);
+	EXPECT(opaque_client_login_init(0, password, sizeof(password), &ke1, &ke1Len) == OPAQUE_ERROR_INVALID_HANDLE);
+	EXPECT(opaque_client_login_init(client, password, sizeof(password), NULL, &ke1Len) ==
+		OPAQUE_ERROR_INVALID_ARGUMENT);
+	EXPECT(opaque_server_login_finish(client, ke3, ke3Len, &serverKey, &serverKeyLen) ==
+		OPAQUE_ERROR_INVALID_HANDLE);
+
+	CHECK(opaque_client_free(client));
+	CHECK(opaque_server_free(server));
+	opaque_free(NULL);
+
+	printf("ok\n");
+
+	return 0;
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Registration and login through the JavaScript interface, with node:
+//
+//	node main.js path/to/wasm_exec.js path/to/opaque.wasm
+
+"use strict";
+
+const fs = require("fs");
+
+require(process.argv[2]);
+
+function check(value) {
+	if (value instanceof Error) {
+		throw value;
+	}
+
+	return value;
+}
+
+function expect(cond, message) {
+	if (!cond) {
+		throw new Error(message);
+	}
+}
+
+function equal(a, b) {
+	return a.length === b.length && a.every((v, i) => v === b[i]);
+}
+
+async function main() {
+	const go = new Go();
+	const { instance } = await WebAssembly.instantiate(fs.readFileSync(process.argv[3]), go.importObject);
+	go.run(instance);
+
+	const enc = new TextEncoder();
+	const password = enc.encode("password");
+	const credID = enc.encode("credential");
+
+	const conf = opaque.defaultConfiguration();
+	const keys = check(opaque.generateKeyPair(conf));
+	const seed = check(opaque.generateOPRFSeed(conf));
+	const client = check(opaque.newClient(conf));
+	const server = check(opaque.newServer(conf, null, keys.secretKey, keys.publicKey, seed));
+
+	const request = client.registrationInit(password);
+	const response = check(server.registrationResponse(request, credID));
+	const registration = check(client.registrationFinalize(response));
+
+	const ke1 = client.loginInit(password);
+	const ke2 = check(server.loginInit(ke1, registration.record, credID));
+	const login = check(client.loginFinish(ke2));
+	const sessionKey = check(server.loginFinish(login.ke3));
+
+	expect(sessionKey instanceof Uint8Array, "expected an Uint8Array");
+	expect(equal(sessionKey, login.sessionKey), "expected equal session keys");
+	expect(equal(registration.exportKey, login.exportKey), "expected equal export keys");
+
+	// Failures are returned as Errors.
+	expect(opaque.newClient("configuration") instanceof Error, "expected an Error on a string argument");
+	expect(opaque.newClient(new Uint8Array(1)) instanceof Error, "expected an Error on an invalid configuration");
+	expect(server.loginFinish(new Uint8Array(1)) instanceof Error, "expected an Error on an invalid KE3");
+
+	const wrong = check(server.loginInit(client.loginInit(enc.encode("wrong")), registration.record, credID));
+	expect(client.loginFinish(wrong) instanceof Error, "expected an Error on a wrong password");
+
+	client.wipe();
+	server.wipe();
+
+	console.log("ok");
+	process.exit(0);
+}
+
+main().catch((err) => {
+	console.error(err);
+	process.exit(1);
+});
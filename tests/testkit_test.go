@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaqueprop"
+	"github.com/bytemare/opaque/testkit"
+)
+
+func newTestNetwork(t *testing.T) *testkit.Network {
+	n, err := testkit.NewNetwork(opaque.DefaultConfiguration(), &opaqueprop.Credentials{
+		Password:             []byte("password"),
+		CredentialIdentifier: []byte("alice"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestNetwork(t *testing.T) {
+	n := newTestNetwork(t)
+
+	testkit.ExpectFailure(t, n.Login(n.Credentials.Password), testkit.ServerLoginInit, testkit.ErrNoRecord)
+
+	reg := n.Register()
+	testkit.ExpectSuccess(t, reg)
+
+	login := n.Login(n.Credentials.Password)
+	testkit.ExpectSuccess(t, login)
+
+	if !bytes.Equal(login.ExportKey, reg.ExportKey) {
+		t.Fatal("the export keys of the registration and the login differ")
+	}
+
+	// The KE1 of the first login, the registration, and the login.
+	if len(n.Transcript) != 7 {
+		t.Fatalf("expected 7 messages in the transcript, got %d", len(n.Transcript))
+	}
+
+	testkit.ExpectFailure(t, n.Login([]byte("wrong")), testkit.ClientLoginFinish, nil)
+}
+
+func TestNetwork_Faults(t *testing.T) {
+	n := newTestNetwork(t)
+	testkit.ExpectSuccess(t, n.Register())
+
+	for _, test := range []struct {
+		name  string
+		fault testkit.Fault
+		step  testkit.Step
+		err   error
+	}{
+		{"drop KE1", testkit.Drop(opaque.KE1Message), testkit.ServerLoginInit, testkit.ErrDropped},
+		{"drop KE2", testkit.Drop(opaque.KE2Message), testkit.ClientLoginFinish, testkit.ErrDropped},
+		{"drop KE3", testkit.Drop(opaque.KE3Message), testkit.ServerLoginFinish, testkit.ErrDropped},
+		{"truncate KE1", testkit.Tamper(opaque.KE1Message, func(e []byte) []byte {
+			return e[:len(e)-1]
+		}), testkit.ServerLoginInit, nil},
+		{"flip KE1 nonce", testkit.FlipBit(opaque.KE1Message, 8*32), testkit.ClientLoginFinish, nil},
+		{"flip KE2 nonce", testkit.FlipBit(opaque.KE2Message, 8*32), testkit.ClientLoginFinish, nil},
+		{"flip KE2 mac", testkit.FlipBit(opaque.KE2Message, -1), testkit.ClientLoginFinish, nil},
+		{"flip KE3", testkit.FlipBit(opaque.KE3Message, 0), testkit.ServerLoginFinish, opaque.ErrAkeInvalidClientMac},
+		{"truncate KE2", testkit.Tamper(opaque.KE2Message, func(e []byte) []byte {
+			return e[:len(e)-1]
+		}), testkit.ClientLoginFinish, nil},
+		{"duplicate KE1", testkit.Duplicate(opaque.KE1Message), testkit.ServerLoginFinish, nil},
+		{"delay KE3", testkit.Delay(opaque.KE3Message), testkit.ServerLoginFinish, testkit.ErrDropped},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n.Reset()
+			n.Inject(test.fault)
+			testkit.ExpectFailure(t, n.Login(n.Credentials.Password), test.step, test.err)
+		})
+	}
+}
+
+func TestNetwork_Replay(t *testing.T) {
+	n := newTestNetwork(t)
+	testkit.ExpectSuccess(t, n.Register())
+
+	for _, test := range []struct {
+		name string
+		typ  opaque.MessageType
+		step testkit.Step
+	}{
+		{"KE2", opaque.KE2Message, testkit.ClientLoginFinish},
+		{"KE3", opaque.KE3Message, testkit.ServerLoginFinish},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n.Reset()
+			n.Inject(testkit.Replay(test.typ))
+			testkit.ExpectSuccess(t, n.Login(n.Credentials.Password))
+			testkit.ExpectFailure(t, n.Login(n.Credentials.Password), test.step, nil)
+		})
+	}
+}
+
+func TestNetwork_Delay(t *testing.T) {
+	n := newTestNetwork(t)
+	testkit.ExpectSuccess(t, n.Register())
+
+	// The delayed KE3 of the first login arrives after the second login's KE1, in place of its KE3.
+	n.Inject(testkit.Delay(opaque.KE3Message))
+	testkit.ExpectFailure(t, n.Login(n.Credentials.Password), testkit.ServerLoginFinish, testkit.ErrDropped)
+	testkit.ExpectFailure(t, n.Login(n.Credentials.Password), testkit.ServerLoginFinish, opaque.ErrAkeInvalidClientMac)
+}
+
+func TestNetwork_Registration(t *testing.T) {
+	n := newTestNetwork(t)
+
+	// The Ristretto255 registration response is the evaluated element followed by the server public key, of 32 bytes
+	// each. The client rejects a response with another server public key.
+	n.Inject(testkit.FlipBit(opaque.RegistrationResponseMessage, 8*32+8))
+	testkit.ExpectFailure(t, n.Register(), testkit.ClientRegistrationFinalize, nil)
+
+	n.Reset()
+	n.Inject(testkit.Drop(opaque.RegistrationRecordMessage))
+	testkit.ExpectFailure(t, n.Register(), testkit.ServerRegistrationRecord, testkit.ErrDropped)
+
+	if n.Record != nil {
+		t.Fatal("a record was stored")
+	}
+
+	// A tampered evaluation goes unnoticed at registration, but the password doesn't work.
+	n.Reset()
+	n.Inject(testkit.Tamper(opaque.RegistrationResponseMessage, func(e []byte) []byte {
+		return append(e[32:64:64], e[32:]...)
+	}))
+	testkit.ExpectSuccess(t, n.Register())
+	n.Reset()
+	testkit.ExpectFailure(t, n.Login(n.Credentials.Password), testkit.ClientLoginFinish, nil)
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/message"
+)
+
+func TestThreshold(t *testing.T) {
+	password := []byte("password")
+	credID := randomBytes(32)
+
+	for _, conf := range confs {
+		shares, err := conf.Conf.GenerateOPRFKeyShares(2, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sks, pks, _ := conf.Conf.KeyGen()
+		primary, _ := conf.Conf.Server()
+
+		// The shares survive serialization.
+		for i, s := range shares {
+			decoded, err := primary.Deserialize.OPRFKeyShare(s.Serialize())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			shares[i] = decoded
+		}
+
+		partial := func(blinded *message.PartialEvaluation) *message.PartialEvaluation {
+			p, err := primary.Deserialize.PartialEvaluation(blinded.Serialize())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return p
+		}
+
+		// Registration with servers 1 and 3.
+		client, _ := conf.Conf.Client()
+		req := client.RegistrationInit(password)
+
+		var evaluations []*message.PartialEvaluation
+		for _, share := range []*opaque.OPRFKeyShare{shares[0], shares[2]} {
+			server, _ := conf.Conf.Server()
+
+			e, err := server.PartialEvaluate(req.BlindedMessage, share)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evaluations = append(evaluations, partial(e))
+		}
+
+		pk, _ := primary.Deserialize.DecodeAkePublicKey(pks)
+
+		resp, err := client.ThresholdRegistrationResponse(pk, evaluations)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r3, exportKey, _ := client.RegistrationFinalize(resp, nil, nil)
+		record := &opaque.ClientRecord{CredentialIdentifier: credID, RegistrationRecord: r3}
+
+		// Login with server 1 as primary, and server 2.
+		client, _ = conf.Conf.Client()
+		ke1 := client.LoginInit(password)
+
+		ke2, primaryEvaluation, err := primary.LoginInitWithKeyShare(ke1, nil, sks, pks, shares[0], record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server2, _ := conf.Conf.Server()
+
+		e2, err := server2.PartialEvaluate(ke1.BlindedMessage, shares[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evaluations = []*message.PartialEvaluation{partial(primaryEvaluation), partial(e2)}
+
+		ke3, loginExportKey, err := client.LoginFinishWithEvaluations(nil, nil, ke2, evaluations)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(exportKey, loginExportKey) {
+			t.Fatal("export keys differ")
+		}
+
+		if err := primary.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		// A single server's evaluation is not enough.
+		client, _ = conf.Conf.Client()
+		primary, _ = conf.Conf.Server()
+		ke1 = client.LoginInit(password)
+		ke2, primaryEvaluation, _ = primary.LoginInitWithKeyShare(ke1, nil, sks, pks, shares[0], record)
+
+		evaluations = []*message.PartialEvaluation{primaryEvaluation}
+		if _, _, err := client.LoginFinishWithEvaluations(nil, nil, ke2, evaluations); err == nil {
+			t.Fatal("expected error below the threshold")
+		}
+	}
+}
+
+func TestThreshold_Errors(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+
+	for _, p := range [][2]int{{0, 3}, {4, 3}, {1, 65536}} {
+		if _, err := conf.GenerateOPRFKeyShares(p[0], p[1]); !errors.Is(err, opaque.ErrInvalidThreshold) {
+			t.Fatalf("expected error for threshold %d of %d, got %v", p[0], p[1], err)
+		}
+	}
+
+	shares, _ := conf.GenerateOPRFKeyShares(2, 2)
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	req := client.RegistrationInit([]byte("password"))
+
+	if _, err := server.PartialEvaluate(req.BlindedMessage, &opaque.OPRFKeyShare{}); !errors.Is(
+		err, opaque.ErrInvalidKeyShare) {
+		t.Fatalf("expected error on invalid share, got %v", err)
+	}
+
+	e, _ := server.PartialEvaluate(req.BlindedMessage, shares[0])
+
+	if _, err := client.CombineEvaluations(nil); !errors.Is(err, opaque.ErrNoEvaluations) {
+		t.Fatalf("expected error on empty evaluations, got %v", err)
+	}
+
+	if _, err := client.CombineEvaluations([]*message.PartialEvaluation{e, e}); !errors.Is(
+		err, opaque.ErrInvalidKeyShare) {
+		t.Fatalf("expected error on duplicate evaluations, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build timing
+
+package opaque_test
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/keyrecovery"
+)
+
+// The timing tests are dudect-style leakage detection tests (https://eprint.iacr.org/2016/1123.pdf): they time an
+// operation on inputs of two classes, randomly interleaved, and report a leak if Welch's t-test distinguishes the two
+// distributions of durations. They run on the default configuration, are slow, and must not run under the race
+// detector, hence the build tag:
+//
+//	go test -tags timing -run TestTiming ./tests
+
+const (
+	timingSamples = 20000
+
+	// timingThreshold is the t-value above which the classes are distinguishable. dudect reports a probable leak above
+	// 4.5 and a definite one above 10, which avoids false positives on noisy CI runners.
+	timingThreshold = 10
+)
+
+// timingPercentiles are the percentiles at which the measurements are cropped, to remove the long tail of the
+// interruptions, with the uncropped measurements first.
+var timingPercentiles = []float64{1, 0.9, 0.75, 0.5}
+
+// welch accumulates the online mean and variance of both classes of measurements.
+type welch struct {
+	n, mean, m2 [2]float64
+}
+
+func (w *welch) push(class int, x float64) {
+	w.n[class]++
+	delta := x - w.mean[class]
+	w.mean[class] += delta / w.n[class]
+	w.m2[class] += delta * (x - w.mean[class])
+}
+
+// t returns Welch's t-value of the two classes.
+func (w *welch) t() float64 {
+	if w.n[0] < 2 || w.n[1] < 2 {
+		return 0
+	}
+
+	v0 := w.m2[0] / (w.n[0] - 1)
+	v1 := w.m2[1] / (w.n[1] - 1)
+	den := math.Sqrt(v0/w.n[0] + v1/w.n[1])
+
+	if den == 0 {
+		return 0
+	}
+
+	return (w.mean[0] - w.mean[1]) / den
+}
+
+// dudect times run on randomly chosen classes, and returns the largest absolute t-value over the cropped measurements.
+func dudect(samples int, run func(class int)) float64 {
+	classes := randomBytes(samples)
+	durations := make([]float64, samples)
+
+	// Warm up the caches and the branch predictor.
+	for i := 0; i < samples/10; i++ {
+		run(i & 1)
+	}
+
+	for i := range durations {
+		class := int(classes[i] & 1)
+		start := time.Now()
+		run(class)
+		durations[i] = float64(time.Since(start))
+	}
+
+	sorted := append([]float64(nil), durations...)
+	sort.Float64s(sorted)
+
+	var max float64
+
+	for _, p := range timingPercentiles {
+		cutoff := sorted[int(p*float64(samples-1))]
+
+		var w welch
+
+		for i, d := range durations {
+			if d <= cutoff {
+				w.push(int(classes[i]&1), d)
+			}
+		}
+
+		max = math.Max(max, math.Abs(w.t()))
+	}
+
+	return max
+}
+
+func checkTiming(t *testing.T, name string, run func(class int)) {
+	t.Helper()
+
+	tv := dudect(timingSamples, run)
+	if tv > timingThreshold {
+		t.Errorf("%s: timing leak detected, t = %.2f", name, tv)
+		return
+	}
+
+	t.Logf("%s: t = %.2f", name, tv)
+}
+
+// TestTimingEnvelopeRecovery compares recovering an envelope with the right and with a wrong password. With the right
+// password, the last byte of the authentication tag is flipped so that both classes fail its verification, and only
+// a comparison that doesn't run in constant time distinguishes them.
+func TestTimingEnvelopeRecovery(t *testing.T) {
+	client, _ := opaque.DefaultConfiguration().Client()
+	conf := client.GetConf()
+	_, pks, _ := opaque.DefaultConfiguration().KeyGen()
+
+	right := randomBytes(conf.KDF.Size())
+	wrong := randomBytes(conf.KDF.Size())
+	ids := &keyrecovery.Identities{Client: []byte("client"), Server: []byte("server")}
+
+	env, _, _, err := keyrecovery.Store(conf, right, pks, nil, nil, ids, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.AuthTag[len(env.AuthTag)-1] ^= 0xff
+	passwords := [2][]byte{right, wrong}
+
+	checkTiming(t, "envelope recovery", func(class int) {
+		_, _, _, _, err := keyrecovery.Recover(conf, passwords[class], pks, ids, nil, env)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+// TestTimingUserEnumeration compares the server's responses to a login for an existing client and for a non-existing
+// client with a fake record.
+func TestTimingUserEnumeration(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, existing := registeredTestParams(t, conf)
+
+	fake, err := conf.GetFakeRecord(existing.CredentialIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.ClientIdentity = existing.ClientIdentity
+	records := [2]*opaque.ClientRecord{existing, fake}
+
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	ke1 := client.LoginInit(test.password)
+
+	checkTiming(t, "login response", func(class int) {
+		if _, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey,
+			test.oprfSeed, records[class]); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
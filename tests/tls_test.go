@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/bytemare/opaque/opaquetls"
+)
+
+func tlsHandshake(t *testing.T, clientKey, serverKey []byte) (clientErr, serverErr error) {
+	clientConf, err := opaquetls.ClientConfig(nil, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConf, err := opaquetls.ServerConfig(nil, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan error)
+
+	go func() {
+		s, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+
+		conn := tls.Server(s, serverConf)
+		err = conn.Handshake()
+		if err == nil {
+			_, err = conn.Write([]byte("hello"))
+		}
+		_ = conn.Close()
+		done <- err
+	}()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := tls.Client(c, clientConf)
+	clientErr = conn.Handshake()
+
+	if clientErr == nil {
+		state := conn.ConnectionState()
+		if binding, err := opaquetls.ChannelBinding(&state); err != nil || len(binding) == 0 {
+			t.Fatalf("unexpected channel binding error: %v", err)
+		}
+
+		buf := make([]byte, 5)
+		if _, clientErr = conn.Read(buf); clientErr == nil && !bytes.Equal(buf, []byte("hello")) {
+			t.Fatal("unexpected application data")
+		}
+	}
+
+	_ = c.Close()
+
+	return clientErr, <-done
+}
+
+func TestTLSBridge(t *testing.T) {
+	sessionKey := randomBytes(64)
+
+	if cerr, serr := tlsHandshake(t, sessionKey, sessionKey); cerr != nil || serr != nil {
+		t.Fatalf("unexpected errors: %v, %v", cerr, serr)
+	}
+
+	if cerr, serr := tlsHandshake(t, sessionKey, randomBytes(64)); cerr == nil && serr == nil {
+		t.Fatal("expected handshake failure with different session keys")
+	}
+
+	// Both ends derive the same external PSK, bound to the context.
+	p1, _ := opaquetls.ExternalPSK(sessionKey, []byte("app"))
+	p2, _ := opaquetls.ExternalPSK(sessionKey, []byte("app"))
+	p3, _ := opaquetls.ExternalPSK(sessionKey, []byte("other"))
+
+	if !bytes.Equal(p1.Key, p2.Key) || !bytes.Equal(p1.Identity, p2.Identity) || bytes.Equal(p1.Key, p3.Key) {
+		t.Fatal("unexpected external PSK derivation")
+	}
+
+	if _, err := opaquetls.ClientConfig(nil, nil); err == nil {
+		t.Fatal("expected error on empty session key")
+	}
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaquetoken"
+)
+
+func TestTokens(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	pk, sk, _ := ed25519.GenerateKey(rand.Reader)
+	sessionKey := randomBytes(64)
+	identity := []byte("client")
+
+	for _, format := range []opaquetoken.Format{opaquetoken.JWT, opaquetoken.PASETO} {
+		issuer := &opaquetoken.Issuer{
+			Configuration: conf,
+			Key:           sk,
+			Issuer:        "server",
+			Audience:      "app",
+			Format:        format,
+		}
+
+		verifier, err := opaquetoken.NewVerifier(conf, pk, "server", "app", format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = opaquetoken.NewVerifier(conf, pk[1:], "server", "app", format); err == nil {
+			t.Fatal("expected error on a short public key")
+		}
+
+		token, err := issuer.Issue(identity, sessionKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := verifier.VerifySession(token, sessionKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id, err := claims.ClientIdentity(); err != nil || !bytes.Equal(id, identity) {
+			t.Fatal("unexpected client identity")
+		}
+
+		if _, err := verifier.VerifySession(token, randomBytes(64)); !errors.Is(err, opaquetoken.ErrClaims) {
+			t.Fatalf("expected %q, got %q", opaquetoken.ErrClaims, err)
+		}
+
+		// A token from another configuration or audience is rejected.
+		other := *verifier
+		other.Configuration = hybridConfiguration(conf)
+
+		if _, err := other.Verify(token); !errors.Is(err, opaquetoken.ErrClaims) {
+			t.Fatalf("expected %q, got %q", opaquetoken.ErrClaims, err)
+		}
+
+		other = *verifier
+		other.Audience = "other"
+
+		if _, err := other.Verify(token); !errors.Is(err, opaquetoken.ErrClaims) {
+			t.Fatalf("expected %q, got %q", opaquetoken.ErrClaims, err)
+		}
+
+		// A Verifier built without NewVerifier and with an invalid key fails without panicking.
+		other = *verifier
+		other.Key = pk[1:]
+
+		if _, err := other.Verify(token); err == nil {
+			t.Fatal("expected error on a short public key")
+		}
+
+		// Tampered tokens are rejected.
+		tampered := []byte(token)
+		tampered[len(tampered)-5] ^= 1
+
+		if _, err := verifier.Verify(string(tampered)); !errors.Is(err, opaquetoken.ErrInvalidToken) {
+			t.Fatalf("expected %q, got %q", opaquetoken.ErrInvalidToken, err)
+		}
+
+		// Expired tokens are rejected.
+		issuer.TTL = -1
+
+		token, _ = issuer.Issue(identity, sessionKey)
+		if _, err := verifier.Verify(token); !errors.Is(err, opaquetoken.ErrExpired) {
+			t.Fatalf("expected %q, got %q", opaquetoken.ErrExpired, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTranscriptHash(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		ke1, err := client.LoginInitWithInfo(test.password, []byte("client info"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if client.TranscriptHash() != nil || server.TranscriptHash() != nil {
+			t.Fatal("expected no transcript hash before the handshake")
+		}
+
+		ke2, err := server.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := server.LoginFinish(ke3); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(client.TranscriptHash()) != conf.Hash.Size() ||
+			!bytes.Equal(client.TranscriptHash(), server.TranscriptHash()) {
+			t.Fatal("client and server have different transcript hashes")
+		}
+
+		// Another response to the same KE1 has a different transcript.
+		other, _ := conf.Server()
+		if _, err := other.LoginInit(ke1, test.serverID, test.serverSecretKey, test.serverPublicKey, test.oprfSeed,
+			record); err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Equal(other.TranscriptHash(), server.TranscriptHash()) {
+			t.Fatal("expected different transcript hashes for different sessions")
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/tripledh"
+)
+
+// tripleDHVector is a test vector for the standalone 3DH AKE, with Ristretto255 and SHA-512.
+type tripleDHVector struct {
+	Inputs struct {
+		ClientIdentity        ByteToHex `json:"client_identity"`
+		ClientNonce           ByteToHex `json:"client_nonce"`
+		ClientPrivateKey      ByteToHex `json:"client_private_key"`
+		ClientPrivateKeyshare ByteToHex `json:"client_private_keyshare"`
+		ClientPublicKey       ByteToHex `json:"client_public_key"`
+		Context               ByteToHex `json:"context"`
+		ServerIdentity        ByteToHex `json:"server_identity"`
+		ServerNonce           ByteToHex `json:"server_nonce"`
+		ServerPrivateKey      ByteToHex `json:"server_private_key"`
+		ServerPrivateKeyshare ByteToHex `json:"server_private_keyshare"`
+		ServerPublicKey       ByteToHex `json:"server_public_key"`
+	} `json:"inputs"`
+	Outputs struct {
+		KE1        ByteToHex `json:"KE1"`
+		KE2        ByteToHex `json:"KE2"`
+		KE3        ByteToHex `json:"KE3"`
+		SessionKey ByteToHex `json:"session_key"`
+	} `json:"outputs"`
+}
+
+func (v *tripleDHVector) test(t *testing.T) {
+	conf := tripledh.DefaultConfiguration()
+	conf.Context = v.Inputs.Context
+	in := v.Inputs
+
+	// Empty identities default to the public keys.
+	if len(in.ClientIdentity) == 0 {
+		in.ClientIdentity, in.ServerIdentity = nil, nil
+	}
+
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+
+	if err := client.SetKnownAnswers(in.ClientPrivateKeyshare, in.ClientNonce); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.SetKnownAnswers(in.ServerPrivateKeyshare, in.ServerNonce); err != nil {
+		t.Fatal(err)
+	}
+
+	ke1 := client.Start()
+	if !bytes.Equal(v.Outputs.KE1, ke1.Serialize()) {
+		t.Fatal("KE1 do not match")
+	}
+
+	ke2, err := server.Respond(in.ServerIdentity, in.ServerPrivateKey, in.ClientIdentity, in.ClientPublicKey, ke1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(v.Outputs.KE2, ke2.Serialize()) {
+		t.Fatal("KE2 do not match")
+	}
+
+	ke3, err := client.Finish(in.ClientIdentity, in.ClientPrivateKey, in.ServerIdentity, in.ServerPublicKey, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(v.Outputs.KE3, ke3.Serialize()) {
+		t.Fatal("KE3 do not match")
+	}
+
+	if err := server.Finish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(v.Outputs.SessionKey, client.SessionKey()) {
+		t.Fatal("Client session keys do not match")
+	}
+
+	if !bytes.Equal(v.Outputs.SessionKey, server.SessionKey()) {
+		t.Fatal("Server session keys do not match")
+	}
+}
+
+func TestTripleDHVectors(t *testing.T) {
+	contents, err := os.ReadFile("tripledhVectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []*tripleDHVector
+	if err := json.Unmarshal(contents, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range vectors {
+		t.Run(fmt.Sprintf("Vector %d", i), v.test)
+	}
+}
+
+func tripleDHHandshake(
+	t *testing.T,
+	conf *tripledh.Configuration,
+	clientIdentity, serverIdentity []byte,
+) (*tripledh.Client, *tripledh.Server) {
+	clientSecretKey, clientPublicKey, _ := conf.KeyGen()
+	serverSecretKey, serverPublicKey, _ := conf.KeyGen()
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+
+	ke2, err := server.Respond(serverIdentity, serverSecretKey, clientIdentity, clientPublicKey, client.Start())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, err := client.Finish(clientIdentity, clientSecretKey, serverIdentity, serverPublicKey, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Finish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	return client, server
+}
+
+func TestTripleDH(t *testing.T) {
+	for _, c := range confs {
+		conf := &tripledh.Configuration{
+			Group:   c.Conf.AKE,
+			KDF:     c.Conf.KDF,
+			MAC:     c.Conf.MAC,
+			Hash:    c.Conf.Hash,
+			Context: []byte("context"),
+		}
+
+		for _, ids := range [][2][]byte{{nil, nil}, {[]byte("client"), []byte("server")}} {
+			client, server := tripleDHHandshake(t, conf, ids[0], ids[1])
+
+			if len(client.SessionKey()) == 0 || !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+				t.Fatal("session keys do not match")
+			}
+
+			if !bytes.Equal(client.TranscriptHash(), server.TranscriptHash()) {
+				t.Fatal("transcript hashes do not match")
+			}
+		}
+	}
+}
+
+func TestTripleDHSerialization(t *testing.T) {
+	conf := tripledh.DefaultConfiguration()
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+	csk, cpk, _ := conf.KeyGen()
+	ssk, spk, _ := conf.KeyGen()
+
+	ke1, err := conf.DeserializeKE1(client.Start().Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ke2, err := server.Respond(nil, ssk, nil, cpk, ke1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ke2, err = conf.DeserializeKE2(ke2.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+
+	ke3, err := client.Finish(nil, csk, nil, spk, ke2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ke3, err = conf.DeserializeKE3(ke3.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Finish(ke3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conf.DeserializeKE1(ke1.Serialize()[1:]); err == nil {
+		t.Fatal("expected error on short KE1")
+	}
+
+	if _, err := conf.DeserializeKE2(append(ke2.Serialize(), 0)); err == nil {
+		t.Fatal("expected error on long KE2")
+	}
+
+	if _, err := conf.DeserializeKE3(nil); err == nil {
+		t.Fatal("expected error on empty KE3")
+	}
+}
+
+func TestTripleDHFailures(t *testing.T) {
+	conf := tripledh.DefaultConfiguration()
+	csk, cpk, _ := conf.KeyGen()
+	ssk, spk, _ := conf.KeyGen()
+	_, otherPK, _ := conf.KeyGen()
+
+	// The client expects another server key.
+	client, _ := conf.Client()
+	server, _ := conf.Server()
+
+	ke2, err := server.Respond(nil, ssk, nil, cpk, client.Start())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Finish(nil, csk, nil, otherPK, ke2); !errors.Is(err, tripledh.ErrAuthentication) {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+
+	// The identities and the context are bound into the transcript.
+	for _, name := range []string{"identity", "context"} {
+		other := tripledh.DefaultConfiguration()
+		clientID := []byte(nil)
+
+		if name == "context" {
+			other.Context = []byte("other")
+		} else {
+			clientID = []byte("other")
+		}
+
+		client, _ = other.Client()
+		server, _ = conf.Server()
+
+		ke2, err = server.Respond(nil, ssk, nil, cpk, client.Start())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Finish(clientID, csk, nil, spk, ke2); !errors.Is(err, tripledh.ErrAuthentication) {
+			t.Fatalf("%s: expected authentication error, got %v", name, err)
+		}
+	}
+
+	// A tampered KE3 is rejected, and the server has no session key.
+	client, _ = conf.Client()
+	server, _ = conf.Server()
+
+	ke2, _ = server.Respond(nil, ssk, nil, cpk, client.Start())
+	ke3, _ := client.Finish(nil, csk, nil, spk, ke2)
+	ke3.MAC[0] ^= 0xff
+
+	if err := server.Finish(ke3); !errors.Is(err, tripledh.ErrAuthentication) {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+
+	if server.SessionKey() != nil {
+		t.Fatal("expected no session key")
+	}
+
+	// Invalid keys and messages.
+	client, _ = conf.Client()
+	server, _ = conf.Server()
+	ke1 := client.Start()
+
+	if _, err := server.Respond(nil, make([]byte, len(ssk)), nil, cpk, ke1); err == nil {
+		t.Fatal("expected error on zero secret key")
+	}
+
+	if _, err := server.Respond(nil, ssk, nil, make([]byte, len(cpk)), ke1); err == nil {
+		t.Fatal("expected error on identity public key")
+	}
+
+	bad := &tripledh.KE1{Nonce: ke1.Nonce, EphemeralPublicKey: make([]byte, len(cpk))}
+	if _, err := server.Respond(nil, ssk, nil, cpk, bad); err == nil {
+		t.Fatal("expected error on invalid ephemeral public key")
+	}
+
+	if _, err := client.Finish(nil, csk, nil, spk, &tripledh.KE2{Nonce: ke1.Nonce[1:]}); err == nil {
+		t.Fatal("expected error on short nonce")
+	}
+
+	fresh, _ := conf.Client()
+	if _, err := fresh.Finish(nil, csk, nil, spk, ke2); err == nil {
+		t.Fatal("expected error before Start")
+	}
+
+	if err := fresh.SetKnownAnswers(nil, []byte("short")); err == nil {
+		t.Fatal("expected error on short known nonce")
+	}
+
+	if _, err := (&tripledh.Configuration{Group: opaque.Group(0), Hash: crypto.SHA256}).Client(); err == nil {
+		t.Fatal("expected error on invalid group")
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWASM builds the js/wasm module, and runs a script registering and logging in with it in node.
+func TestWASM(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the js/wasm build in short mode")
+	}
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("no node")
+	}
+
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wasm_exec.js moved from misc/wasm to lib/wasm in Go 1.24.
+	var wasmExec string
+
+	for _, dir := range []string{"lib", "misc"} {
+		p := filepath.Join(strings.TrimSpace(string(goroot)), dir, "wasm", "wasm_exec.js")
+		if fileExists(p) {
+			wasmExec = p
+			break
+		}
+	}
+
+	if wasmExec == "" {
+		t.Skip("no wasm_exec.js in GOROOT")
+	}
+
+	module := filepath.Join(t.TempDir(), "opaque.wasm")
+
+	build := exec.Command("go", "build", "-trimpath", "-ldflags=-s -w", "-o", module, "../wasm")
+	build.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building the wasm module: %v\n%s", err, out)
+	}
+
+	out, err := exec.Command(node, "testdata/wasm/main.js", wasmExec, module).CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "ok" {
+		t.Fatalf("running the script: %v\n%s", err, out)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/opaquews"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// pipeConn is one end of an in-memory message pipe, mimicking a WebSocket connection.
+type pipeConn struct {
+	in       chan []byte
+	out      chan []byte
+	deadline time.Time
+}
+
+func newPipe() (*pipeConn, *pipeConn) {
+	a, b := make(chan []byte, 4), make(chan []byte, 4)
+	return &pipeConn{in: a, out: b}, &pipeConn{in: b, out: a}
+}
+
+func (p *pipeConn) ReadMessage() (int, []byte, error) {
+	select {
+	case m := <-p.in:
+		return opaquews.BinaryMessage, m, nil
+	case <-time.After(time.Until(p.deadline)):
+		return 0, nil, timeoutError{}
+	}
+}
+
+func (p *pipeConn) WriteMessage(_ int, data []byte) error {
+	p.out <- data
+	return nil
+}
+
+func (p *pipeConn) SetReadDeadline(t time.Time) error {
+	p.deadline = t
+	return nil
+}
+
+func (p *pipeConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	test, record := registeredTestParams(t, conf)
+
+	server := &opaquews.Server{
+		Configuration: conf,
+		Lookup: func(credentialIdentifier []byte) (*opaque.ClientRecord, error) {
+			if bytes.Equal(credentialIdentifier, record.CredentialIdentifier) {
+				return record, nil
+			}
+
+			return nil, nil
+		},
+		ServerIdentity:  test.serverID,
+		ServerSecretKey: test.serverSecretKey,
+		ServerPublicKey: test.serverPublicKey,
+		OPRFSeed:        test.oprfSeed,
+	}
+	client := &opaquews.Client{
+		Configuration:  conf,
+		ClientIdentity: test.username,
+		ServerIdentity: test.serverID,
+	}
+
+	run := func(credentialIdentifier, password []byte) (*opaquews.Result, *opaquews.Result, error, error) {
+		c, s := newPipe()
+		done := make(chan struct{})
+
+		var (
+			serverResult *opaquews.Result
+			serverErr    error
+		)
+
+		go func() {
+			serverResult, serverErr = server.Handshake(s)
+			close(done)
+		}()
+
+		clientResult, clientErr := client.Handshake(c, credentialIdentifier, password)
+		<-done
+
+		return clientResult, serverResult, clientErr, serverErr
+	}
+
+	cr, sr, cerr, serr := run(record.CredentialIdentifier, test.password)
+	if cerr != nil || serr != nil {
+		t.Fatalf("unexpected errors: %v, %v", cerr, serr)
+	}
+
+	if !bytes.Equal(cr.SessionKey, sr.SessionKey) {
+		t.Fatal("session keys differ")
+	}
+
+	if !bytes.Equal(sr.ClientIdentity, test.username) || !bytes.Equal(cr.ServerIdentity, sr.ServerIdentity) {
+		t.Fatal("unexpected identities")
+	}
+
+	// Wrong passwords and unknown credentials fail alike on both ends.
+	for _, credID := range [][]byte{record.CredentialIdentifier, []byte("unknown")} {
+		_, _, cerr, serr = run(credID, []byte("wrong"))
+		if !errors.Is(cerr, opaquews.ErrAuthentication) || !errors.Is(serr, opaquews.ErrAuthentication) {
+			t.Fatalf("expected authentication errors, got %v, %v", cerr, serr)
+		}
+	}
+
+	// A silent peer times out.
+	c, _ := newPipe()
+	client.Timeout = 10 * time.Millisecond
+
+	if _, err := client.Handshake(c, record.CredentialIdentifier, test.password); !errors.Is(err, opaquews.ErrTimeout) {
+		t.Fatalf("expected %q, got %q", opaquews.ErrTimeout, err)
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func isZero(b []byte) bool {
+	return bytes.Equal(b, make([]byte, len(b)))
+}
+
+func TestWipe(t *testing.T) {
+	for _, c := range confs {
+		conf := c.Conf
+		test, record := registeredTestParams(t, conf)
+
+		client, _ := conf.Client()
+		server, _ := conf.Server()
+
+		login := func() {
+			ke2, err := server.LoginInit(client.LoginInit(test.password), test.serverID, test.serverSecretKey,
+				test.serverPublicKey, test.oprfSeed, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ke3, _, err := client.LoginFinish(test.username, test.serverID, ke2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.LoginFinish(ke3); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		login()
+
+		clientKey, serverKey := client.SessionKey(), server.SessionKey()
+		client.Wipe()
+		server.Wipe()
+
+		if !isZero(clientKey) || !isZero(serverKey) {
+			t.Fatal("expected the session keys to be zeroed")
+		}
+
+		if len(client.SessionKey()) != 0 || len(server.SessionKey()) != 0 || len(server.ExpectedMAC()) != 0 {
+			t.Fatal("expected empty state after wiping")
+		}
+
+		// Wiped clients and servers can run a new login.
+		login()
+
+		if !bytes.Equal(client.SessionKey(), server.SessionKey()) {
+			t.Fatal("expected equal session keys after wiping")
+		}
+
+		// The masking key and envelope of a record can be wiped once stored.
+		wiped := *record.RegistrationRecord
+		wiped.MaskingKey = append([]byte(nil), record.MaskingKey...)
+		wiped.Envelope = append([]byte(nil), record.Envelope...)
+		wiped.Wipe()
+
+		if !isZero(wiped.MaskingKey) || !isZero(wiped.Envelope) {
+			t.Fatal("expected the record to be zeroed")
+		}
+
+		// A wiped Reauthenticator holds no secret.
+		secret, _ := client.ReauthSecret()
+
+		r, err := conf.Reauthenticator(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.Wipe()
+
+		if !isZero(secret) {
+			t.Fatal("expected the re-authentication secret to be zeroed")
+		}
+	}
+}
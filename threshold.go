@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+// Threshold OPAQUE secret-shares a client's OPRF key across several servers, so that the OPRF output can only be
+// computed with the cooperation of a threshold of them. One of them, the primary, stores the client record and runs
+// the AKE, but compromising it alone does not allow offline dictionary attacks on the password.
+//
+// At registration, a dealer generates the shares with GenerateOPRFKeyShares and hands one to each server. Each
+// server answers the client's RegistrationRequest with PartialEvaluate, and the client combines a threshold of them
+// with ThresholdRegistrationResponse. At login, the primary answers KE1 with LoginInitWithKeyShare, the others with
+// PartialEvaluate, and the client finishes with LoginFinishWithEvaluations.
+
+var (
+	// ErrInvalidThreshold indicates the threshold or total number of shares is out of range.
+	ErrInvalidThreshold = errors.New("invalid threshold: must satisfy 1 <= threshold <= total <= 65535")
+
+	// ErrInvalidKeyShare indicates an OPRF key share that can't be used.
+	ErrInvalidKeyShare = errors.New("invalid OPRF key share")
+
+	// ErrNoEvaluations indicates that no partial evaluations were given to combine.
+	ErrNoEvaluations = errors.New("no partial evaluations to combine")
+)
+
+// OPRFKeyShare is a server's share of a client's OPRF key in threshold OPAQUE. The share must be kept secret, and
+// is stored by the server alongside the client's credential identifier.
+type OPRFKeyShare struct {
+	Index uint16
+	Share []byte
+}
+
+// Serialize returns the byte encoding of the share, as the index in two bytes followed by the share's scalar.
+func (s *OPRFKeyShare) Serialize() []byte {
+	return encoding.Concat(encoding.I2OSP(int(s.Index), 2), s.Share)
+}
+
+// OPRFKeyShare decodes a share produced by OPRFKeyShare.Serialize.
+func (d *Deserializer) OPRFKeyShare(share []byte) (*OPRFKeyShare, error) {
+	if len(share) != 2+encoding.ScalarLength[d.conf.OPRF.Group()] {
+		return nil, ErrInvalidKeyShare
+	}
+
+	s := &OPRFKeyShare{
+		Index: uint16(encoding.OS2IP(share[:2])),
+		Share: share[2:],
+	}
+
+	if _, err := decodeKeyShare(d.conf.OPRF.Group(), s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// GenerateOPRFKeyShares generates a new random OPRF key for a client and splits it into total shares, any threshold
+// of which are needed to evaluate the OPRF. The key itself is not returned and should not be kept.
+func (c *Configuration) GenerateOPRFKeyShares(threshold, total int) ([]*OPRFKeyShare, error) {
+	if threshold < 1 || threshold > total || total > 65535 {
+		return nil, ErrInvalidThreshold
+	}
+
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, err
+	}
+
+	// The constant term of the polynomial is the key, and the other coefficients are random.
+	g := conf.OPRF.Group()
+	coefficients := make([]*group.Scalar, threshold)
+
+	for i := range coefficients {
+		if coefficients[i], err = internal.RandomScalar(g); err != nil {
+			return nil, err
+		}
+	}
+
+	scalars := conf.OPRF.SplitKey(coefficients, uint16(total))
+	shares := make([]*OPRFKeyShare, total)
+
+	for i, s := range scalars {
+		shares[i] = &OPRFKeyShare{
+			Index: uint16(i + 1),
+			Share: encoding.SerializeScalar(s, g),
+		}
+	}
+
+	return shares, nil
+}
+
+func decodeKeyShare(g group.Group, share *OPRFKeyShare) (*group.Scalar, error) {
+	if share == nil || share.Index == 0 {
+		return nil, ErrInvalidKeyShare
+	}
+
+	s, err := g.NewScalar().Decode(share.Share)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyShare, err)
+	}
+
+	if s.IsZero() {
+		return nil, ErrInvalidKeyShare
+	}
+
+	return s, nil
+}
+
+// PartialEvaluate evaluates the client's blinded message, from a RegistrationRequest or KE1, under the server's share
+// of the client's OPRF key.
+func (s *Server) PartialEvaluate(blindedMessage *group.Point, share *OPRFKeyShare) (*message.PartialEvaluation, error) {
+	if blindedMessage == nil {
+		return nil, errIncompleteMessage
+	}
+
+	ks, err := decodeKeyShare(s.conf.OPRF.Group(), share)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message.PartialEvaluation{
+		C:                s.conf.OPRF,
+		Index:            share.Index,
+		EvaluatedMessage: s.conf.OPRF.Evaluate(ks, blindedMessage),
+	}, nil
+}
+
+// LoginInitWithKeyShare responds to a KE1 message with a KE2 message as the primary server in threshold OPAQUE,
+// given the server credentials, its share of the client's OPRF key, and the client record. The KE2 holds the
+// server's partial evaluation, which is also returned to be combined by the client with those of the other servers.
+func (s *Server) LoginInitWithKeyShare(
+	ke1 *message.KE1,
+	serverIdentity, serverSecretKey, serverPublicKey []byte,
+	share *OPRFKeyShare,
+	record *ClientRecord,
+) (*message.KE2, *message.PartialEvaluation, error) {
+	sks, err := s.decodeServerSecretKey(serverSecretKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.verifyInitInput(serverPublicKey, record); err != nil {
+		return nil, nil, err
+	}
+
+	if !completeKE1(ke1) {
+		return nil, nil, errIncompleteMessage
+	}
+
+	evaluation, err := s.PartialEvaluate(ke1.BlindedMessage, share)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ke2, err := s.loginInit(ke1, serverIdentity, sks, serverPublicKey, evaluation.EvaluatedMessage, record, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ke2, evaluation, nil
+}
+
+// CombineEvaluations interpolates a threshold of partial evaluations from distinct servers into the OPRF evaluation
+// under the client's full OPRF key.
+func (c *Client) CombineEvaluations(evaluations []*message.PartialEvaluation) (*group.Point, error) {
+	if len(evaluations) == 0 {
+		return nil, ErrNoEvaluations
+	}
+
+	indices := make([]uint16, len(evaluations))
+	points := make([]*group.Point, len(evaluations))
+
+	for i, e := range evaluations {
+		if e == nil || e.EvaluatedMessage == nil {
+			return nil, errInvalidEvaluatedData
+		}
+
+		indices[i] = e.Index
+		points[i] = e.EvaluatedMessage
+	}
+
+	evaluation, err := c.conf.OPRF.Combine(indices, points)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyShare, err)
+	}
+
+	return evaluation, nil
+}
+
+// ThresholdRegistrationResponse combines the servers' partial evaluations of the RegistrationRequest into a
+// RegistrationResponse, given the primary server's public key, to be finalized as usual.
+func (c *Client) ThresholdRegistrationResponse(
+	serverPublicKey *group.Point,
+	evaluations []*message.PartialEvaluation,
+) (*message.RegistrationResponse, error) {
+	evaluation, err := c.CombineEvaluations(evaluations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message.RegistrationResponse{
+		C:                c.conf.OPRF,
+		G:                c.conf.Group,
+		EvaluatedMessage: evaluation,
+		Pks:              serverPublicKey,
+	}, nil
+}
+
+// LoginFinishWithEvaluations returns a KE3 message given the primary server's KE2 response message, the identities,
+// and a threshold of partial evaluations from distinct servers, which may include the primary's.
+func (c *Client) LoginFinishWithEvaluations(
+	clientIdentity, serverIdentity []byte,
+	ke2 *message.KE2,
+	evaluations []*message.PartialEvaluation,
+) (ke3 *message.KE3, exportKey ExportKey, err error) {
+	evaluation, err := c.CombineEvaluations(evaluations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.loginFinish(clientIdentity, serverIdentity, nil, evaluation, ke2)
+}
@@ -0,0 +1,533 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package tripledh exposes the 3DH AKE of OPAQUE on its own, for protocols in which both parties already hold
+// long-term key pairs and no password is involved.
+//
+// The key schedule, the MACs, and the transcript rules are those of the OPAQUE login, run by the same code, with the
+// OPRF and the credential pieces left out of the messages and the transcript. The transcript is prefixed with its own
+// version tag, so that a standalone 3DH transcript can never be mistaken for an OPAQUE one.
+//
+// The client sends KE1, the server answers with KE2, and the client finishes with KE3:
+//
+//	client, _ := conf.Client()
+//	server, _ := conf.Server()
+//	ke1 := client.Start()
+//	ke2, err := server.Respond(serverID, serverSecretKey, clientID, clientPublicKey, ke1)
+//	ke3, err := client.Finish(clientID, clientSecretKey, serverID, serverPublicKey, ke2)
+//	err = server.Finish(ke3)
+package tripledh
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/ake"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+// NonceLength is the length of the client and server nonces.
+const NonceLength = internal.NonceLength
+
+var (
+	errInvalidGroup    = errors.New("tripledh: invalid group")
+	errInvalidKDF      = errors.New("tripledh: invalid KDF")
+	errInvalidMAC      = errors.New("tripledh: invalid MAC")
+	errInvalidHash     = errors.New("tripledh: invalid Hash")
+	errInvalidLength   = errors.New("tripledh: invalid message length")
+	errInvalidPoint    = errors.New("tripledh: invalid ephemeral public key")
+	errInvalidKey      = errors.New("tripledh: invalid key")
+	errInvalidKnown    = errors.New("tripledh: invalid known answer")
+	errNotStarted      = errors.New("tripledh: Start must be called before Finish")
+	errAlreadyFinished = errors.New("tripledh: the handshake has already been finished")
+
+	// ErrAuthentication indicates that the peer's MAC is invalid, i.e. that the peer doesn't hold the expected key or
+	// that the messages have been tampered with.
+	ErrAuthentication = errors.New("tripledh: authentication failed")
+)
+
+// Configuration represents the 3DH parameters.
+type Configuration struct {
+	// Context is optional shared information bound into the transcript.
+	Context []byte `json:"context,omitempty"`
+
+	// Group is the prime-order group of the key pairs.
+	Group opaque.Group `json:"group"`
+
+	// KDF, MAC, and Hash are the hash functions of the key schedule, the MACs, and the transcript.
+	KDF  crypto.Hash `json:"kdf"`
+	MAC  crypto.Hash `json:"mac"`
+	Hash crypto.Hash `json:"hash"`
+}
+
+// DefaultConfiguration returns a 3DH configuration with Ristretto255 and SHA-512, as in the default OPAQUE
+// configuration.
+func DefaultConfiguration() *Configuration {
+	return &Configuration{
+		Group: opaque.RistrettoSha512,
+		KDF:   crypto.SHA512,
+		MAC:   crypto.SHA512,
+		Hash:  crypto.SHA512,
+	}
+}
+
+func (c *Configuration) verify() error {
+	if !group.Group(c.Group).Available() {
+		return errInvalidGroup
+	}
+
+	if !internal.HashAvailable(c.KDF) {
+		return errInvalidKDF
+	}
+
+	if !internal.HashAvailable(c.MAC) {
+		return errInvalidMAC
+	}
+
+	if !internal.HashAvailable(c.Hash) {
+		return errInvalidHash
+	}
+
+	return nil
+}
+
+func (c *Configuration) toInternal() (*internal.Configuration, error) {
+	if err := c.verify(); err != nil {
+		return nil, err
+	}
+
+	hedgeKey, err := internal.RandomBytes(internal.HedgeKeyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	g := group.Group(c.Group)
+
+	return &internal.Configuration{
+		KDF:            internal.NewKDF(c.KDF),
+		MAC:            internal.NewMac(c.MAC),
+		Hash:           internal.NewHash(c.Hash),
+		NonceLen:       internal.NonceLength,
+		Group:          g,
+		AkePointLength: encoding.PointLength[g],
+		KEM:            internal.NoKEM,
+		Protocol:       internal.TripleDH,
+		Context:        c.Context,
+		HedgeKey:       hedgeKey,
+		Standalone:     true,
+	}, nil
+}
+
+// KeyGen returns a random key pair in the group of the configuration.
+func (c *Configuration) KeyGen() (secretKey, publicKey []byte, err error) {
+	if err = c.verify(); err != nil {
+		return nil, nil, err
+	}
+
+	g := group.Group(c.Group)
+	sk := g.NewScalar().Random()
+
+	return encoding.SerializeScalar(sk, g), encoding.SerializePoint(g.Base().Mult(sk), g), nil
+}
+
+// Client returns a new 3DH client for the configuration.
+func (c *Configuration) Client() (*Client, error) {
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{conf: conf, ake: ake.NewClient()}, nil
+}
+
+// Server returns a new 3DH server for the configuration.
+func (c *Configuration) Server() (*Server, error) {
+	conf, err := c.toInternal()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{conf: conf, ake: ake.NewServer()}, nil
+}
+
+// KE1 is the client's first message.
+type KE1 struct {
+	Nonce              []byte `json:"client_nonce"`
+	EphemeralPublicKey []byte `json:"client_ephemeral_pk"`
+}
+
+// Serialize returns the byte encoding of KE1.
+func (m *KE1) Serialize() []byte {
+	return encoding.Concat(m.Nonce, m.EphemeralPublicKey)
+}
+
+// KE2 is the server's response.
+type KE2 struct {
+	Nonce              []byte `json:"server_nonce"`
+	EphemeralPublicKey []byte `json:"server_ephemeral_pk"`
+	MAC                []byte `json:"server_mac"`
+}
+
+// Serialize returns the byte encoding of KE2.
+func (m *KE2) Serialize() []byte {
+	return encoding.Concat3(m.Nonce, m.EphemeralPublicKey, m.MAC)
+}
+
+// KE3 is the client's final message.
+type KE3 struct {
+	MAC []byte `json:"client_mac"`
+}
+
+// Serialize returns the byte encoding of KE3.
+func (m *KE3) Serialize() []byte {
+	return append([]byte(nil), m.MAC...)
+}
+
+// DeserializeKE1 returns the KE1 message encoded in input.
+func (c *Configuration) DeserializeKE1(input []byte) (*KE1, error) {
+	pointLength := encoding.PointLength[group.Group(c.Group)]
+	if len(input) != NonceLength+pointLength {
+		return nil, errInvalidLength
+	}
+
+	return &KE1{
+		Nonce:              append([]byte(nil), input[:NonceLength]...),
+		EphemeralPublicKey: append([]byte(nil), input[NonceLength:]...),
+	}, nil
+}
+
+// DeserializeKE2 returns the KE2 message encoded in input.
+func (c *Configuration) DeserializeKE2(input []byte) (*KE2, error) {
+	if err := c.verify(); err != nil {
+		return nil, err
+	}
+
+	pointLength := encoding.PointLength[group.Group(c.Group)]
+	if len(input) != NonceLength+pointLength+c.MAC.Size() {
+		return nil, errInvalidLength
+	}
+
+	return &KE2{
+		Nonce:              append([]byte(nil), input[:NonceLength]...),
+		EphemeralPublicKey: append([]byte(nil), input[NonceLength:NonceLength+pointLength]...),
+		MAC:                append([]byte(nil), input[NonceLength+pointLength:]...),
+	}, nil
+}
+
+// DeserializeKE3 returns the KE3 message encoded in input.
+func (c *Configuration) DeserializeKE3(input []byte) (*KE3, error) {
+	if err := c.verify(); err != nil {
+		return nil, err
+	}
+
+	if len(input) != c.MAC.Size() {
+		return nil, errInvalidLength
+	}
+
+	return &KE3{MAC: append([]byte(nil), input...)}, nil
+}
+
+// decodeSecretKey decodes a non-zero scalar.
+func decodeSecretKey(g group.Group, encoded []byte) (*group.Scalar, error) {
+	if len(encoded) != encoding.ScalarLength[g] {
+		return nil, fmt.Errorf("%w: secret key length", errInvalidKey)
+	}
+
+	s, err := g.NewScalar().Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidKey, err)
+	}
+
+	if s.IsZero() {
+		return nil, fmt.Errorf("%w: zero secret key", errInvalidKey)
+	}
+
+	return s, nil
+}
+
+// decodePoint decodes a canonically encoded element other than the identity.
+func decodePoint(g group.Group, encoded []byte, fieldErr error) (*group.Point, error) {
+	if len(encoded) != encoding.PointLength[g] {
+		return nil, fmt.Errorf("%w: length", fieldErr)
+	}
+
+	p, err := g.NewElement().Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", fieldErr, err)
+	}
+
+	if p.IsIdentity() {
+		return nil, fmt.Errorf("%w: identity element", fieldErr)
+	}
+
+	// Some groups accept several encodings of the same element, which would make the messages malleable.
+	if subtle.ConstantTimeCompare(encoding.SerializePoint(p, g), encoded) != 1 {
+		return nil, fmt.Errorf("%w: non-canonical encoding", fieldErr)
+	}
+
+	return p, nil
+}
+
+// checkNonce verifies the length of a nonce received in a message.
+func checkNonce(nonce []byte) error {
+	if len(nonce) != NonceLength {
+		return errInvalidLength
+	}
+
+	return nil
+}
+
+// setKnownAnswers decodes and forces the ephemeral secret key and nonce, for test vectors.
+func setKnownAnswers(
+	g group.Group,
+	ephemeralSecretKey, nonce []byte,
+	set func(group.Group, *group.Scalar, []byte) *group.Point,
+) error {
+	var (
+		esk *group.Scalar
+		err error
+	)
+
+	if ephemeralSecretKey != nil {
+		if esk, err = decodeSecretKey(g, ephemeralSecretKey); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidKnown, err)
+		}
+	}
+
+	if nonce != nil && len(nonce) != NonceLength {
+		return fmt.Errorf("%w: nonce length", errInvalidKnown)
+	}
+
+	set(g, esk, nonce)
+
+	return nil
+}
+
+// Client holds the state of the client's side of a 3DH handshake.
+type Client struct {
+	conf *internal.Configuration
+	ake  *ake.Client
+	ke1  *message.KE1
+	done bool
+}
+
+// SetKnownAnswers forces the ephemeral secret key and the nonce of the next handshake, instead of hedged random
+// values. Either can be nil. This is meant only for test vectors: reusing these values breaks the security of the AKE.
+func (c *Client) SetKnownAnswers(ephemeralSecretKey, nonce []byte) error {
+	return setKnownAnswers(c.conf.Group, ephemeralSecretKey, nonce, c.ake.SetValues)
+}
+
+// Start initiates the handshake and returns KE1.
+func (c *Client) Start() *KE1 {
+	c.ke1 = c.ake.Start(c.conf)
+	ke1 := &KE1{
+		Nonce:              append([]byte(nil), c.ke1.NonceU...),
+		EphemeralPublicKey: encoding.SerializePoint(c.ke1.EpkU, c.conf.Group),
+	}
+	c.ake.Ke1 = ke1.Serialize()
+
+	return ke1
+}
+
+// Finish verifies the server's KE2 and returns KE3. The identities default to the public keys if nil. The session key
+// is available after success.
+func (c *Client) Finish(
+	clientIdentity, clientSecretKey, serverIdentity, serverPublicKey []byte,
+	ke2 *KE2,
+) (*KE3, error) {
+	if c.ke1 == nil {
+		return nil, errNotStarted
+	}
+
+	if c.done {
+		return nil, errAlreadyFinished
+	}
+
+	g := c.conf.Group
+
+	sk, err := decodeSecretKey(g, clientSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := decodePoint(g, serverPublicKey, errInvalidKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkNonce(ke2.Nonce); err != nil {
+		return nil, err
+	}
+
+	epk, err := decodePoint(g, ke2.EphemeralPublicKey, errInvalidPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if clientIdentity == nil {
+		clientIdentity = encoding.SerializePoint(c.conf.BaseMult(sk), g)
+	}
+
+	if serverIdentity == nil {
+		serverIdentity = serverPublicKey
+	}
+
+	ke3, err := c.ake.Finalize(c.conf, clientIdentity, sk, serverIdentity, pk, &message.KE2{
+		G:      g,
+		NonceS: ke2.Nonce,
+		EpkS:   epk,
+		Mac:    ke2.MAC,
+	})
+	c.conf.Hash.Reset()
+
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	c.done = true
+
+	return &KE3{MAC: ke3.Mac}, nil
+}
+
+// SessionKey returns the session key if Finish was successful.
+func (c *Client) SessionKey() []byte {
+	return c.ake.SessionKey()
+}
+
+// TranscriptHash returns the hash of the handshake transcript if Finish was successful.
+func (c *Client) TranscriptHash() []byte {
+	return c.ake.TranscriptHash()
+}
+
+// Wipe zeroes the client's secret state. The client can't be used afterwards.
+func (c *Client) Wipe() {
+	c.ake.Wipe()
+	c.conf.Hash.Reset()
+	internal.Zero(c.conf.HedgeKey)
+	c.ke1 = nil
+}
+
+// Server holds the state of the server's side of a 3DH handshake.
+type Server struct {
+	conf      *internal.Configuration
+	ake       *ake.Server
+	responded bool
+	done      bool
+}
+
+// SetKnownAnswers forces the ephemeral secret key and the nonce of the next handshake, instead of hedged random
+// values. Either can be nil. This is meant only for test vectors: reusing these values breaks the security of the AKE.
+func (s *Server) SetKnownAnswers(ephemeralSecretKey, nonce []byte) error {
+	return setKnownAnswers(s.conf.Group, ephemeralSecretKey, nonce, s.ake.SetValues)
+}
+
+// Respond answers the client's KE1 with KE2. The identities default to the public keys if nil.
+func (s *Server) Respond(
+	serverIdentity, serverSecretKey, clientIdentity, clientPublicKey []byte,
+	ke1 *KE1,
+) (*KE2, error) {
+	if s.responded {
+		return nil, errAlreadyFinished
+	}
+
+	g := s.conf.Group
+
+	sk, err := decodeSecretKey(g, serverSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := decodePoint(g, clientPublicKey, errInvalidKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkNonce(ke1.Nonce); err != nil {
+		return nil, err
+	}
+
+	epk, err := decodePoint(g, ke1.EphemeralPublicKey, errInvalidPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if serverIdentity == nil {
+		serverIdentity = encoding.SerializePoint(s.conf.BaseMult(sk), g)
+	}
+
+	if clientIdentity == nil {
+		clientIdentity = clientPublicKey
+	}
+
+	ke2, err := s.ake.Response(s.conf, serverIdentity, sk, clientIdentity, pk, &message.KE1{
+		G:      g,
+		NonceU: ke1.Nonce,
+		EpkU:   epk,
+	}, nil, nil)
+	s.conf.Hash.Reset()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.responded = true
+
+	return &KE2{
+		Nonce:              append([]byte(nil), ke2.NonceS...),
+		EphemeralPublicKey: encoding.SerializePoint(ke2.EpkS, g),
+		MAC:                ke2.Mac,
+	}, nil
+}
+
+// Finish verifies the client's KE3. The session key is available after success.
+func (s *Server) Finish(ke3 *KE3) error {
+	if !s.responded {
+		return errNotStarted
+	}
+
+	if s.done {
+		return errAlreadyFinished
+	}
+
+	if !s.ake.Finalize(s.conf, &message.KE3{Mac: ke3.MAC}) {
+		return ErrAuthentication
+	}
+
+	s.done = true
+
+	return nil
+}
+
+// SessionKey returns the session key if Finish was successful.
+func (s *Server) SessionKey() []byte {
+	if !s.done {
+		return nil
+	}
+
+	return s.ake.SessionKey()
+}
+
+// TranscriptHash returns the hash of the handshake transcript if Respond was successful.
+func (s *Server) TranscriptHash() []byte {
+	return s.ake.TranscriptHash()
+}
+
+// Wipe zeroes the server's secret state. The server can't be used afterwards.
+func (s *Server) Wipe() {
+	s.ake.Wipe()
+	s.conf.Hash.Reset()
+	internal.Zero(s.conf.HedgeKey)
+	s.responded, s.done = false, false
+}
@@ -0,0 +1,376 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package vectors
+
+import (
+	"crypto"
+	"fmt"
+	"strconv"
+
+	"github.com/bytemare/crypto/group"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal"
+	"github.com/bytemare/opaque/internal/encoding"
+	"github.com/bytemare/opaque/message"
+)
+
+const generatorLabel = "OPAQUE-TestVectorGenerator"
+
+// Parameters are the inputs of a generated vector. The Seed determines all the values that are random in the protocol,
+// i.e. the OPRF seed, the server's key pair, the blinds, the nonces, and the key shares, so that the same parameters
+// always generate the same vector. A nil Password or CredentialIdentifier defaults to the draft's, and nil identities
+// default to the public keys.
+type Parameters struct {
+	Seed                 []byte
+	Password             []byte
+	CredentialIdentifier []byte
+	ClientIdentity       []byte
+	ServerIdentity       []byte
+
+	// Fake generates the login of an unregistered client, with a fake record.
+	Fake bool
+}
+
+// Generate returns the vector of a registration and login in the configuration, or only of the login's response if
+// p.Fake is set, with all the intermediate values the draft's vectors have. It returns an error wrapping ErrUnsupported
+// if the draft's format can't express the configuration, i.e. for different OPRF and AKE groups, a KEM, the KEM-based
+// AKE, the external envelope mode, or application data.
+func Generate(c *opaque.Configuration, p *Parameters) (*Vector, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	v := &Vector{}
+	if err = v.Config.set(c, client.GetConf(), p.Fake); err != nil {
+		return nil, err
+	}
+
+	g := newGenerator(client.GetConf(), p.Seed)
+	v.Inputs = Inputs{
+		ClientIdentity:        p.ClientIdentity,
+		CredentialIdentifier:  defaultTo(p.CredentialIdentifier, "1234"),
+		OprfSeed:              g.bytes("oprf_seed", c.Hash.Size()),
+		Password:              defaultTo(p.Password, "CorrectHorseBatteryStaple"),
+		ServerIdentity:        p.ServerIdentity,
+		ServerPrivateKey:      g.scalar("server_private_key"),
+		ClientPrivateKeyshare: g.scalar("client_private_keyshare"),
+		ServerPrivateKeyshare: g.scalar("server_private_keyshare"),
+		BlindLogin:            g.blind("blind_login"),
+		ClientNonce:           g.bytes("client_nonce", g.conf.NonceLen),
+		ServerNonce:           g.bytes("server_nonce", g.conf.NonceLen),
+		MaskingNonce:          g.bytes("masking_nonce", g.conf.NonceLen),
+	}
+	v.Inputs.ServerPublicKey = g.public(v.Inputs.ServerPrivateKey)
+	v.Inputs.ClientKeyshare = g.public(v.Inputs.ClientPrivateKeyshare)
+	v.Inputs.ServerKeyshare = g.public(v.Inputs.ServerPrivateKeyshare)
+
+	if p.Fake {
+		err = v.generateFake(c, g)
+	} else {
+		v.Inputs.BlindRegistration = g.blind("blind_registration")
+		v.Inputs.EnvelopeNonce = g.bytes("envelope_nonce", g.conf.NonceLen)
+		err = v.generate(c)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func defaultTo(value []byte, s string) []byte {
+	if value == nil {
+		return []byte(s)
+	}
+
+	return value
+}
+
+// set sets the suite of the configuration, in the names of the draft.
+func (c *Config) set(conf *opaque.Configuration, i *internal.Configuration, fake bool) error {
+	switch {
+	case conf.OPRF != conf.AKE:
+		return fmt.Errorf("%w: different OPRF and AKE groups", ErrUnsupported)
+	case conf.KEM != opaque.NoKEM:
+		return fmt.Errorf("%w: KEM %d", ErrUnsupported, conf.KEM)
+	case conf.Mode == opaque.External:
+		return fmt.Errorf("%w: external envelope mode", ErrUnsupported)
+	case conf.AppDataLength != 0:
+		return fmt.Errorf("%w: application data", ErrUnsupported)
+	case conf.Compatibility != opaque.Draft:
+		return fmt.Errorf("%w: compatibility mode %d", ErrUnsupported, conf.Compatibility)
+	}
+
+	*c = Config{
+		Context: conf.Context,
+		Fake:    "False",
+		OPRF:    []byte{0, byte(conf.OPRF)},
+		Nh:      strconv.Itoa(i.Hash.Size()),
+		Nm:      strconv.Itoa(i.MAC.Size()),
+		Nok:     strconv.Itoa(encoding.ScalarLength[i.OPRF.Group()]),
+		Npk:     strconv.Itoa(i.AkePointLength),
+		Nsk:     strconv.Itoa(encoding.ScalarLength[i.Group]),
+		Nx:      strconv.Itoa(i.KDF.Size()),
+	}
+
+	if fake {
+		c.Fake = "True"
+	}
+
+	for name, g := range groups {
+		if g == conf.OPRF {
+			c.Group = name
+		}
+	}
+
+	for name, p := range protocols {
+		if p == conf.Protocol {
+			c.Name = name
+		}
+	}
+
+	for name, a := range aeads {
+		if a == conf.AEAD {
+			c.AEAD = name
+		}
+	}
+
+	for name, k := range keyStretching {
+		if k == conf.KSF {
+			c.KSF = name
+		}
+	}
+
+	for name, h := range hashes {
+		if h == conf.Hash {
+			c.Hash = name
+		}
+
+		if h == conf.KDF {
+			c.KDF = "HKDF-" + name
+		}
+
+		if h == conf.MAC {
+			c.MAC = "HMAC-" + name
+		}
+	}
+
+	if c.Group == "" || c.Name == "" || c.KSF == "" || c.Hash == "" || c.KDF == "" || c.MAC == "" {
+		return fmt.Errorf("%w: a component of the configuration has no name in the draft", ErrUnsupported)
+	}
+
+	return nil
+}
+
+// generator derives the random values of a vector from its seed.
+type generator struct {
+	conf *internal.Configuration
+	prk  []byte
+}
+
+func newGenerator(conf *internal.Configuration, seed []byte) *generator {
+	return &generator{
+		conf: conf,
+		prk:  internal.NewKDF(crypto.SHA512).Extract([]byte(generatorLabel), seed),
+	}
+}
+
+func (g *generator) bytes(label string, length int) []byte {
+	return internal.NewKDF(crypto.SHA512).Expand(g.prk, []byte(label), length)
+}
+
+func (g *generator) hashToScalar(gr group.Group, label string) []byte {
+	s := gr.HashToScalar(g.bytes(label, 64), []byte(generatorLabel+"-"+label))
+	return encoding.SerializeScalar(s, gr)
+}
+
+// scalar returns a scalar of the AKE group.
+func (g *generator) scalar(label string) []byte {
+	return g.hashToScalar(g.conf.Group, label)
+}
+
+// blind returns a scalar of the OPRF group.
+func (g *generator) blind(label string) []byte {
+	return g.hashToScalar(g.conf.OPRF.Group(), label)
+}
+
+// public returns the public key of the encoded scalar of the AKE group.
+func (g *generator) public(scalar []byte) []byte {
+	s, err := g.conf.Group.NewScalar().Decode(scalar)
+	if err != nil {
+		panic(err) // the scalar has just been encoded
+	}
+
+	return encoding.SerializePoint(g.conf.Group.Base().Mult(s), g.conf.Group)
+}
+
+// tracer returns a Tracer that records copies of the intermediate values.
+func tracer(values map[string][]byte) func(name string, value []byte) {
+	return func(name string, value []byte) {
+		values[name] = append([]byte(nil), value...)
+	}
+}
+
+func (v *Vector) generate(c *opaque.Configuration) error {
+	client, err := v.registrationClient(c)
+	if err != nil {
+		return err
+	}
+
+	server, err := c.Server()
+	if err != nil {
+		return err
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(v.Inputs.ServerPublicKey)
+	if err != nil {
+		return err
+	}
+
+	request := client.RegistrationInit(v.Inputs.Password)
+
+	response, err := server.RegistrationResponse(request, pks, v.Inputs.CredentialIdentifier, v.Inputs.OprfSeed)
+	if err != nil {
+		return err
+	}
+
+	record, _, err := client.RegistrationFinalize(response, v.Inputs.ClientIdentity, v.Inputs.ServerIdentity)
+	if err != nil {
+		return err
+	}
+
+	v.Outputs.RegistrationRequest = request.Serialize()
+	v.Outputs.RegistrationResponse = response.Serialize()
+	v.Outputs.RegistrationRecord = record.Serialize()
+	v.Intermediates.ClientPublicKey = encoding.SerializePoint(record.PublicKey, client.GetConf().Group)
+	v.Intermediates.MaskingKey = record.MaskingKey
+	v.Intermediates.Envelope = record.Envelope
+
+	return v.generateLogin(c, record)
+}
+
+func (v *Vector) generateLogin(c *opaque.Configuration, r *message.RegistrationRecord) error {
+	client, err := c.Client()
+	if err != nil {
+		return err
+	}
+
+	server, err := c.Server()
+	if err != nil {
+		return err
+	}
+
+	clientValues, serverValues := make(map[string][]byte), make(map[string][]byte)
+	client.GetConf().Tracer = tracer(clientValues)
+	server.GetConf().Tracer = tracer(serverValues)
+
+	ke1, err := v.ke1(client)
+	if err != nil {
+		return err
+	}
+
+	ke2, err := v.ke2(server, ke1, &opaque.ClientRecord{
+		CredentialIdentifier: v.Inputs.CredentialIdentifier,
+		ClientIdentity:       v.Inputs.ClientIdentity,
+		RegistrationRecord:   r,
+	})
+	if err != nil {
+		return err
+	}
+
+	ke3, exportKey, err := client.LoginFinish(v.Inputs.ClientIdentity, v.Inputs.ServerIdentity, ke2)
+	if err != nil {
+		return err
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		return err
+	}
+
+	v.Outputs.KE1, v.Outputs.KE2, v.Outputs.KE3 = ke1.Serialize(), ke2.Serialize(), ke3.Serialize()
+	v.Outputs.ExportKey = HexBytes(exportKey)
+	v.Outputs.SessionKey = client.SessionKey()
+	v.Intermediates.OprfKey = serverValues[internal.TraceOPRFKey]
+	v.Intermediates.RandomPWD = clientValues[internal.TraceRandomizedPassword]
+	v.Intermediates.AuthKey = clientValues[internal.TraceAuthKey]
+	v.Intermediates.HandshakeSecret = clientValues[internal.TraceHandshakeSecret]
+	v.Intermediates.ServerMacKey = clientValues[internal.TraceServerMacKey]
+	v.Intermediates.ClientMacKey = clientValues[internal.TraceClientMacKey]
+
+	return nil
+}
+
+// generateFake generates the response to the KE1 of an unregistered client, for a fake record.
+func (v *Vector) generateFake(c *opaque.Configuration, g *generator) error {
+	client, err := c.Client()
+	if err != nil {
+		return err
+	}
+
+	server, err := c.Server()
+	if err != nil {
+		return err
+	}
+
+	ke1, err := v.ke1(client)
+	if err != nil {
+		return err
+	}
+
+	v.Inputs.KE1 = ke1.Serialize()
+	v.Inputs.ClientPrivateKey = g.scalar("client_private_key")
+	v.Inputs.ClientPublicKey = g.public(v.Inputs.ClientPrivateKey)
+	v.Inputs.MaskingKey = g.bytes("masking_key", g.conf.KDF.Size())
+	v.Inputs.ClientPrivateKeyshare, v.Inputs.ClientKeyshare, v.Inputs.ClientNonce = nil, nil, nil
+	v.Inputs.BlindLogin, v.Inputs.Password = nil, nil
+
+	record, err := v.record(server, c, true)
+	if err != nil {
+		return err
+	}
+
+	ke2, err := v.ke2(server, ke1, record)
+	if err != nil {
+		return err
+	}
+
+	v.Outputs.KE2 = ke2.Serialize()
+
+	return nil
+}
+
+// ke1 returns the client's KE1 with the vector's blind, nonce, and key share.
+func (v *Vector) ke1(client *opaque.Client) (*message.KE1, error) {
+	if err := client.SetKnownAnswers(&opaque.KnownAnswers{
+		Blind:              v.Inputs.BlindLogin,
+		EphemeralSecretKey: v.Inputs.ClientPrivateKeyshare,
+		Nonce:              v.Inputs.ClientNonce,
+	}); err != nil {
+		return nil, err
+	}
+
+	return client.LoginInit(v.Inputs.Password), nil
+}
+
+// ke2 returns the server's KE2 with the vector's masking nonce, nonce, and key share.
+func (v *Vector) ke2(server *opaque.Server, ke1 *message.KE1, record *opaque.ClientRecord) (*message.KE2, error) {
+	if err := server.SetKnownAnswers(&opaque.KnownAnswers{
+		MaskingNonce:       v.Inputs.MaskingNonce,
+		EphemeralSecretKey: v.Inputs.ServerPrivateKeyshare,
+		Nonce:              v.Inputs.ServerNonce,
+	}); err != nil {
+		return nil, err
+	}
+
+	return server.LoginInit(ke1, v.Inputs.ServerIdentity, v.Inputs.ServerPrivateKey, v.Inputs.ServerPublicKey,
+		v.Inputs.OprfSeed, record)
+}
@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package vectors
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bytemare/opaque"
+	"github.com/bytemare/opaque/internal/encoding"
+)
+
+// Run runs the registration, unless the vector has fake credentials, and the login of the vector, with its blinds,
+// nonces, and key shares, and returns an error wrapping ErrMismatch at the first value that differs from the vector's,
+// or ErrUnsupported if the library doesn't support the vector's suite.
+func (v *Vector) Run() error {
+	conf, err := v.Configuration()
+	if err != nil {
+		return err
+	}
+
+	fake, err := v.IsFake()
+	if err != nil {
+		return err
+	}
+
+	if !fake {
+		if err = v.registration(conf); err != nil {
+			return fmt.Errorf("registration: %w", err)
+		}
+	}
+
+	if err = v.login(conf, fake); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	return nil
+}
+
+func compare(name string, want, got []byte) error {
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("%w: %s\nwant: %x\ngot : %x", ErrMismatch, name, want, got)
+	}
+
+	return nil
+}
+
+// value is a named value computed from a vector, and the vector's value.
+type value struct {
+	name      string
+	want, got []byte
+}
+
+func compareAll(values ...value) error {
+	for _, v := range values {
+		if err := compare(v.name, v.want, v.got); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registrationClient returns a client with the vector's blind and envelope nonce.
+func (v *Vector) registrationClient(conf *opaque.Configuration) (*opaque.Client, error) {
+	client, err := conf.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = client.SetKnownAnswers(&opaque.KnownAnswers{
+		Blind:         v.Inputs.BlindRegistration,
+		EnvelopeNonce: v.Inputs.EnvelopeNonce,
+	}); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (v *Vector) registration(conf *opaque.Configuration) error {
+	client, err := v.registrationClient(conf)
+	if err != nil {
+		return err
+	}
+
+	request := client.RegistrationInit(v.Inputs.Password)
+	if err = compare("registration request", v.Outputs.RegistrationRequest, request.Serialize()); err != nil {
+		return err
+	}
+
+	server, err := conf.Server()
+	if err != nil {
+		return err
+	}
+
+	pks, err := server.Deserialize.DecodeAkePublicKey(v.Inputs.ServerPublicKey)
+	if err != nil {
+		return err
+	}
+
+	response, err := server.RegistrationResponse(request, pks, v.Inputs.CredentialIdentifier, v.Inputs.OprfSeed)
+	if err != nil {
+		return err
+	}
+
+	if err = compare("registration response", v.Outputs.RegistrationResponse, response.Serialize()); err != nil {
+		return err
+	}
+
+	record, exportKey, err := client.RegistrationFinalize(response, v.Inputs.ClientIdentity, v.Inputs.ServerIdentity)
+	if err != nil {
+		return err
+	}
+
+	return compareAll(
+		value{"export key", v.Outputs.ExportKey, exportKey},
+		value{"envelope", v.Intermediates.Envelope, record.Envelope},
+		value{"registration record", v.Outputs.RegistrationRecord, record.Serialize()},
+	)
+}
+
+func (v *Vector) login(conf *opaque.Configuration, fake bool) error {
+	server, err := conf.Server()
+	if err != nil {
+		return err
+	}
+
+	record, err := v.record(server, conf, fake)
+	if err != nil {
+		return err
+	}
+
+	if fake {
+		return v.loginResponse(server, record, v.Inputs.KE1, true)
+	}
+
+	client, err := conf.Client()
+	if err != nil {
+		return err
+	}
+
+	ke1, err := v.ke1(client)
+	if err != nil {
+		return err
+	}
+
+	if err = compare("KE1", v.Outputs.KE1, ke1.Serialize()); err != nil {
+		return err
+	}
+
+	if err = v.loginResponse(server, record, v.Outputs.KE1, false); err != nil {
+		return err
+	}
+
+	ke2, err := client.Deserialize.KE2(v.Outputs.KE2)
+	if err != nil {
+		return err
+	}
+
+	ke3, exportKey, err := client.LoginFinish(v.Inputs.ClientIdentity, v.Inputs.ServerIdentity, ke2)
+	if err != nil {
+		return err
+	}
+
+	if err = compareAll(
+		value{"client export key", v.Outputs.ExportKey, exportKey},
+		value{"client session key", v.Outputs.SessionKey, client.SessionKey()},
+		value{"KE3", v.Outputs.KE3, ke3.Serialize()},
+	); err != nil {
+		return err
+	}
+
+	if err = server.LoginFinish(ke3); err != nil {
+		return err
+	}
+
+	return compare("server session key", v.Outputs.SessionKey, server.SessionKey())
+}
+
+// record returns the registration record of the vector, or the fake record built from its inputs.
+func (v *Vector) record(server *opaque.Server, conf *opaque.Configuration, fake bool) (*opaque.ClientRecord, error) {
+	encoded := v.Outputs.RegistrationRecord
+	if fake {
+		envelope := make([]byte, server.GetConf().EnvelopeSize)
+		encoded = encoding.Concat3(v.Inputs.ClientPublicKey, v.Inputs.MaskingKey, envelope)
+	}
+
+	r, err := server.Deserialize.RegistrationRecord(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &opaque.ClientRecord{
+		CredentialIdentifier: v.Inputs.CredentialIdentifier,
+		ClientIdentity:       v.Inputs.ClientIdentity,
+		RegistrationRecord:   r,
+	}, nil
+}
+
+// loginResponse answers the serialized KE1 with the server's nonce and key share, and compares the response, and the
+// server's expected client MAC and session key for a registered client, to the vector's.
+func (v *Vector) loginResponse(server *opaque.Server, record *opaque.ClientRecord, ke1 []byte, fake bool) error {
+	m, err := server.Deserialize.KE1(ke1)
+	if err != nil {
+		return err
+	}
+
+	ke2, err := v.ke2(server, m, record)
+	if err != nil {
+		return err
+	}
+
+	if err = compare("KE2", v.Outputs.KE2, ke2.Serialize()); err != nil || fake {
+		return err
+	}
+
+	ke3, err := server.Deserialize.KE3(v.Outputs.KE3)
+	if err != nil {
+		return err
+	}
+
+	return compareAll(
+		value{"expected client MAC", ke3.Mac, server.ExpectedMAC()},
+		value{"server session key", v.Outputs.SessionKey, server.SessionKey()},
+	)
+}
@@ -0,0 +1,291 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+// Package vectors loads the draft-irtf-cfrg-opaque JSON test vectors and runs the protocol against them, to verify
+// the conformance of the library for every suite it supports.
+//
+//	vectors, err := vectors.Draft()
+//	...
+//	for _, v := range vectors {
+//		if err := v.Run(); err != nil && !errors.Is(err, vectors.ErrUnsupported) {
+//			...
+//		}
+//	}
+package vectors
+
+import (
+	"bytes"
+	"crypto"
+	_ "embed" // for the draft's vectors
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bytemare/crypto/ksf"
+
+	"github.com/bytemare/opaque"
+)
+
+var (
+	// ErrUnsupported is returned when running a vector of a suite that the library doesn't support.
+	ErrUnsupported = errors.New("unsupported suite")
+
+	// ErrMismatch is returned when a value computed from a vector differs from the vector's.
+	ErrMismatch = errors.New("mismatch with the test vector")
+
+	errFake = errors.New("invalid Fake parameter")
+)
+
+//go:embed vectors.json
+var draft []byte
+
+// HexBytes is a byte slice encoded in JSON as a hexadecimal string.
+type HexBytes []byte
+
+// MarshalJSON encodes the bytes as a hexadecimal string.
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+// UnmarshalJSON decodes the bytes from a hexadecimal string.
+func (h *HexBytes) UnmarshalJSON(b []byte) error {
+	dst, err := hex.DecodeString(strings.Trim(string(b), "\""))
+	if err != nil {
+		return err
+	}
+
+	*h = dst
+
+	return nil
+}
+
+// Config is the suite of a vector. Nh, Nm, Nok, Npk, Nsk, and Nx are the lengths of the hash, the MAC, the OPRF
+// scalars, the AKE public and private keys, and the KDF, in decimal.
+type Config struct {
+	Context HexBytes `json:"Context"`
+	Fake    string   `json:"Fake"`
+	Group   string   `json:"Group"`
+	Hash    string   `json:"Hash"`
+	KDF     string   `json:"KDF"`
+	MAC     string   `json:"MAC"`
+	KSF     string   `json:"KSF"`
+	Name    string   `json:"Name"`
+	OPRF    HexBytes `json:"OPRF"`
+	Nh      string   `json:"Nh,omitempty"`
+	Nm      string   `json:"Nm,omitempty"`
+	Nok     string   `json:"Nok,omitempty"`
+	Npk     string   `json:"Npk,omitempty"`
+	Nsk     string   `json:"Nsk,omitempty"`
+	Nx      string   `json:"Nx,omitempty"`
+
+	// AEAD is the AEAD sealing the envelope, an extension of this library that the draft's vectors don't have.
+	AEAD string `json:"AEAD,omitempty"`
+}
+
+// Inputs are the inputs of a vector. KE1, ClientPrivateKey, ClientPublicKey, and MaskingKey are only set in the
+// vectors with fake credentials.
+type Inputs struct {
+	BlindLogin            HexBytes `json:"blind_login,omitempty"`
+	BlindRegistration     HexBytes `json:"blind_registration,omitempty"`
+	ClientIdentity        HexBytes `json:"client_identity,omitempty"`
+	Context               HexBytes `json:"context,omitempty"`
+	ClientKeyshare        HexBytes `json:"client_keyshare,omitempty"`
+	ClientNonce           HexBytes `json:"client_nonce,omitempty"`
+	ClientPrivateKeyshare HexBytes `json:"client_private_keyshare,omitempty"`
+	CredentialIdentifier  HexBytes `json:"credential_identifier,omitempty"`
+	EnvelopeNonce         HexBytes `json:"envelope_nonce,omitempty"`
+	MaskingNonce          HexBytes `json:"masking_nonce,omitempty"`
+	OprfKey               HexBytes `json:"oprf_key,omitempty"`
+	OprfSeed              HexBytes `json:"oprf_seed,omitempty"`
+	Password              HexBytes `json:"password,omitempty"`
+	ServerIdentity        HexBytes `json:"server_identity,omitempty"`
+	ServerKeyshare        HexBytes `json:"server_keyshare,omitempty"`
+	ServerNonce           HexBytes `json:"server_nonce,omitempty"`
+	ServerPrivateKey      HexBytes `json:"server_private_key,omitempty"`
+	ServerPrivateKeyshare HexBytes `json:"server_private_keyshare,omitempty"`
+	ServerPublicKey       HexBytes `json:"server_public_key,omitempty"`
+	KE1                   HexBytes `json:"KE1,omitempty"`
+	ClientPrivateKey      HexBytes `json:"client_private_key,omitempty"`
+	ClientPublicKey       HexBytes `json:"client_public_key,omitempty"`
+	MaskingKey            HexBytes `json:"masking_key,omitempty"`
+}
+
+// Intermediates are the intermediate values of a vector.
+type Intermediates struct {
+	AuthKey         HexBytes `json:"auth_key,omitempty"`
+	ClientMacKey    HexBytes `json:"client_mac_key,omitempty"`
+	ClientPublicKey HexBytes `json:"client_public_key,omitempty"`
+	Envelope        HexBytes `json:"envelope,omitempty"`
+	HandshakeSecret HexBytes `json:"handshake_secret,omitempty"`
+	MaskingKey      HexBytes `json:"masking_key,omitempty"`
+	OprfKey         HexBytes `json:"oprf_key,omitempty"`
+	RandomPWD       HexBytes `json:"randomized_pwd,omitempty"`
+	ServerMacKey    HexBytes `json:"server_mac_key,omitempty"`
+}
+
+// Outputs are the messages and keys a vector expects.
+type Outputs struct {
+	KE1                  HexBytes `json:"KE1,omitempty"`
+	KE2                  HexBytes `json:"KE2,omitempty"`
+	KE3                  HexBytes `json:"KE3,omitempty"`
+	ExportKey            HexBytes `json:"export_key,omitempty"`
+	RegistrationRequest  HexBytes `json:"registration_request,omitempty"`
+	RegistrationResponse HexBytes `json:"registration_response,omitempty"`
+	RegistrationRecord   HexBytes `json:"registration_upload,omitempty"`
+	SessionKey           HexBytes `json:"session_key,omitempty"`
+}
+
+// Vector is a test vector of the draft.
+type Vector struct {
+	Config        Config        `json:"config"`
+	Inputs        Inputs        `json:"inputs"`
+	Intermediates Intermediates `json:"intermediates"`
+	Outputs       Outputs       `json:"outputs"`
+}
+
+// Load decodes the JSON array of vectors read from r.
+func Load(r io.Reader) ([]*Vector, error) {
+	var v []*Vector
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding the test vectors: %w", err)
+	}
+
+	return v, nil
+}
+
+// LoadFile decodes the JSON array of vectors in the file.
+func LoadFile(path string) ([]*Vector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// Draft returns the draft's test vectors shipped with the package.
+func Draft() ([]*Vector, error) {
+	return Load(bytes.NewReader(draft))
+}
+
+// Name returns a name identifying the vector, e.g. for a subtest.
+func (v *Vector) Name() string {
+	return fmt.Sprintf("%s - %s - Fake:%s", v.Config.Name, v.Config.Group, v.Config.Fake)
+}
+
+// IsFake returns whether the vector is a login with fake credentials, for a client that isn't registered.
+func (v *Vector) IsFake() (bool, error) {
+	switch v.Config.Fake {
+	case "True":
+		return true, nil
+	case "False":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: %q", errFake, v.Config.Fake)
+	}
+}
+
+// Configuration returns the library's configuration of the vector's suite, or an error wrapping ErrUnsupported.
+func (v *Vector) Configuration() (*opaque.Configuration, error) {
+	return v.Config.Configuration()
+}
+
+// Configuration returns the library's configuration of the suite, or an error wrapping ErrUnsupported. OPRF may be
+// empty, and is otherwise checked against the group.
+func (c *Config) Configuration() (*opaque.Configuration, error) {
+	g, ok := groups[c.Group]
+	if !ok {
+		return nil, fmt.Errorf("%w: group %q", ErrUnsupported, c.Group)
+	}
+
+	if len(c.OPRF) != 0 && !bytes.Equal(c.OPRF, []byte{0, byte(g)}) {
+		return nil, fmt.Errorf("%w: OPRF %x", ErrUnsupported, []byte(c.OPRF))
+	}
+
+	protocol, ok := protocols[c.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: AKE %q", ErrUnsupported, c.Name)
+	}
+
+	k, ok := keyStretching[c.KSF]
+	if !ok {
+		return nil, fmt.Errorf("%w: KSF %q", ErrUnsupported, c.KSF)
+	}
+
+	aead, ok := aeads[c.AEAD]
+	if !ok {
+		return nil, fmt.Errorf("%w: AEAD %q", ErrUnsupported, c.AEAD)
+	}
+
+	conf := &opaque.Configuration{
+		OPRF:     g,
+		AKE:      g,
+		KSF:      k,
+		Protocol: protocol,
+		AEAD:     aead,
+		Context:  c.Context,
+	}
+
+	for _, h := range []struct {
+		hash   *crypto.Hash
+		name   string
+		prefix string
+	}{
+		{&conf.Hash, c.Hash, ""},
+		{&conf.KDF, c.KDF, "HKDF-"},
+		{&conf.MAC, c.MAC, "HMAC-"},
+	} {
+		if *h.hash, ok = hashes[strings.TrimPrefix(h.name, h.prefix)]; !ok || !strings.HasPrefix(h.name, h.prefix) {
+			return nil, fmt.Errorf("%w: hash function %q", ErrUnsupported, h.name)
+		}
+	}
+
+	if _, err := conf.Deserializer(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	return conf, nil
+}
+
+// The names of the suite's components in the vectors.
+var (
+	groups = map[string]opaque.Group{
+		"ristretto255":              opaque.RistrettoSha512,
+		"P256_XMD:SHA-256_SSWU_RO_": opaque.P256Sha256,
+		"P384_XMD:SHA-384_SSWU_RO_": opaque.P384Sha512,
+		"P521_XMD:SHA-512_SSWU_RO_": opaque.P521Sha512,
+	}
+
+	hashes = map[string]crypto.Hash{
+		"SHA256": crypto.SHA256,
+		"SHA384": crypto.SHA384,
+		"SHA512": crypto.SHA512,
+	}
+
+	aeads = map[string]opaque.EnvelopeAEAD{
+		"":                  opaque.NoAEAD,
+		"AES-256-GCM":       opaque.AES256GCM,
+		"ChaCha20-Poly1305": opaque.ChaCha20Poly1305,
+	}
+
+	keyStretching = map[string]ksf.Identifier{
+		"Identity": 0,
+		"Argon2id": ksf.Argon2id,
+		"Scrypt":   ksf.Scrypt,
+	}
+
+	protocols = map[string]opaque.Protocol{
+		"3DH":  opaque.TripleDH,
+		"HMQV": opaque.HMQV,
+	}
+)
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+//go:build js && wasm
+
+// Command wasm exposes OPAQUE to JavaScript, as the global opaque object, when run with Go's wasm_exec.js:
+//
+//	GOOS=js GOARCH=wasm go build -trimpath -ldflags="-s -w" -o opaque.wasm ./wasm
+//
+// or with make wasm. It wraps the mobile package: configurations are passed in their serialization, as returned by
+// opaque.defaultConfiguration(), and messages and keys as Uint8Arrays. The client and server objects returned by
+// opaque.newClient and opaque.newServer have the methods of the mobile Client and Server, in lower camel case, and
+// results with several values are returned as objects with the field names of their mobile counterparts. Empty,
+// null, or undefined identities default to the public keys.
+//
+// Panics can't cross into JavaScript, so failures are not thrown but returned: a function that fails returns an
+// Error, to be checked with instanceof.
+package main
+
+import (
+	"errors"
+	"syscall/js"
+
+	"github.com/bytemare/opaque/mobile"
+)
+
+var errNotBytes = errors.New("expected an Uint8Array")
+
+func main() {
+	js.Global().Set("opaque", js.ValueOf(map[string]any{
+		"defaultConfiguration": js.FuncOf(func(js.Value, []js.Value) any {
+			return bytesToJS(mobile.DefaultConfiguration())
+		}),
+		"generateKeyPair": function(func(args *arguments) any {
+			keys, err := mobile.GenerateKeyPair(args.bytes())
+			if err != nil {
+				return err
+			}
+
+			return map[string]any{"secretKey": bytesToJS(keys.SecretKey), "publicKey": bytesToJS(keys.PublicKey)}
+		}),
+		"generateOPRFSeed": function(func(args *arguments) any {
+			return result(mobile.GenerateOPRFSeed(args.bytes()))
+		}),
+		"newClient": function(func(args *arguments) any {
+			c, err := mobile.NewClient(args.bytes())
+			if err != nil {
+				return err
+			}
+
+			return clientObject(c)
+		}),
+		"newServer": function(func(args *arguments) any {
+			s, err := mobile.NewServer(args.bytes(), args.bytes(), args.bytes(), args.bytes(), args.bytes())
+			if err != nil {
+				return err
+			}
+
+			return serverObject(s)
+		}),
+	}))
+
+	select {}
+}
+
+func clientObject(c *mobile.Client) map[string]any {
+	return map[string]any{
+		"registrationInit": function(func(args *arguments) any {
+			return bytesToJS(c.RegistrationInit(args.bytes()))
+		}),
+		"registrationFinalize": function(func(args *arguments) any {
+			r, err := c.RegistrationFinalize(args.bytes(), args.bytes(), args.bytes())
+			if err != nil {
+				return err
+			}
+
+			return map[string]any{"record": bytesToJS(r.Record), "exportKey": bytesToJS(r.ExportKey)}
+		}),
+		"loginInit": function(func(args *arguments) any {
+			return bytesToJS(c.LoginInit(args.bytes()))
+		}),
+		"loginFinish": function(func(args *arguments) any {
+			r, err := c.LoginFinish(args.bytes(), args.bytes(), args.bytes())
+			if err != nil {
+				return err
+			}
+
+			return map[string]any{
+				"ke3":        bytesToJS(r.KE3),
+				"sessionKey": bytesToJS(r.SessionKey),
+				"exportKey":  bytesToJS(r.ExportKey),
+			}
+		}),
+		"wipe": js.FuncOf(func(js.Value, []js.Value) any {
+			c.Wipe()
+			return nil
+		}),
+	}
+}
+
+func serverObject(s *mobile.Server) map[string]any {
+	return map[string]any{
+		"registrationResponse": function(func(args *arguments) any {
+			return result(s.RegistrationResponse(args.bytes(), args.bytes()))
+		}),
+		"loginInit": function(func(args *arguments) any {
+			return result(s.LoginInit(args.bytes(), args.bytes(), args.bytes(), args.bytes()))
+		}),
+		"loginFinish": function(func(args *arguments) any {
+			return result(s.LoginFinish(args.bytes()))
+		}),
+		"wipe": js.FuncOf(func(js.Value, []js.Value) any {
+			s.Wipe()
+			return nil
+		}),
+	}
+}
+
+// arguments reads the byte array arguments of a call in order. A missing, null, or undefined argument is nil.
+type arguments []js.Value
+
+func (a *arguments) bytes() []byte {
+	if len(*a) == 0 {
+		return nil
+	}
+
+	v := (*a)[0]
+	*a = (*a)[1:]
+
+	if v.IsNull() || v.IsUndefined() {
+		return nil
+	}
+
+	b := make([]byte, v.Length())
+	js.CopyBytesToGo(b, v)
+
+	return b
+}
+
+// function returns a JavaScript function calling f, or returning an Error if an argument is not an Uint8Array or f
+// returns an error.
+func function(f func(args *arguments) any) js.Func {
+	return js.FuncOf(func(_ js.Value, values []js.Value) any {
+		for _, v := range values {
+			if !v.IsNull() && !v.IsUndefined() && !v.InstanceOf(js.Global().Get("Uint8Array")) {
+				return jsError(errNotBytes)
+			}
+		}
+
+		args := arguments(values)
+		ret := f(&args)
+
+		if err, ok := ret.(error); ok {
+			return jsError(err)
+		}
+
+		return ret
+	})
+}
+
+func result(b []byte, err error) any {
+	if err != nil {
+		return err
+	}
+
+	return bytesToJS(b)
+}
+
+func bytesToJS(b []byte) js.Value {
+	v := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(v, b)
+
+	return v
+}
+
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
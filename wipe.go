@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright (C) 2021 Daniel Bourdrez. All Rights Reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree or at
+// https://spdx.org/licenses/MIT.html
+
+package opaque
+
+import (
+	"github.com/bytemare/opaque/internal"
+)
+
+// The intermediate secrets of the protocol, like the randomized password, the handshake keys, and the MAC keys, are
+// zeroed as soon as they are used. The secrets that outlive a call, like the session key, are held by the Client and
+// Server until they are wiped. Scalars, like the OPRF blind and the ephemeral private keys, can't be zeroed in place,
+// and are only dropped.
+
+// Wipe resets the Client to its state after NewClient, zeroing its secret values. The session key, the server's
+// application message, and the application data obtained from the Client are zeroed too, and must be copied
+// beforehand if they are still needed. With LockedMemory, so are the export keys, whose memory is released.
+func (c *Client) Wipe() {
+	c.Ake.Wipe()
+	c.OPRF.Wipe()
+	c.conf.Hash.Reset()
+	c.conf.ChannelBinding = nil
+	internal.Zero(c.appData)
+	c.appData = nil
+	c.identities = [2][]byte{}
+	c.kat = nil
+	c.breach = nil
+	c.policy = nil
+	c.passwordErr = nil
+	c.logger = nil
+
+	for _, buf := range c.locked {
+		_ = buf.Destroy()
+	}
+
+	c.locked = nil
+}
+
+// Wipe resets the Server to its state after NewServer, zeroing its secret values. The session key and the expected
+// client MAC obtained from the Server are zeroed too, and must be copied beforehand if they are still needed.
+func (s *Server) Wipe() {
+	s.Ake.Wipe()
+	s.conf.Hash.Reset()
+	s.conf.ChannelBinding = nil
+	s.oprfInfo = nil
+	s.replay = nil
+	s.oprfKeys = nil
+	s.identities = [2][]byte{}
+	s.finished = false
+	s.responseBuffer = nil
+	s.kat = nil
+	s.logger = nil
+	s.wipeKeyMaterial()
+}
+
+// Wipe zeroes the re-authentication secret it was given and its state. The Reauthenticator must not be used
+// afterwards.
+func (r *Reauthenticator) Wipe() {
+	internal.Zero(r.secret)
+	internal.Zero(r.clientMac)
+	r.secret, r.clientMac, r.nonce = nil, nil, nil
+}